@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/security"
+	"github.com/happycollision/ribbin/internal/wrap"
+)
+
+// stdinIsTerminal reports whether ribbin can ask the user a question. Tests
+// replace it.
+var stdinIsTerminal = func() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// acknowledgeSharedDirs asks, once per directory, before wrapping anything
+// in a system or shared directory like /usr/local/bin (see
+// security.RequiresAcknowledgment), showing the command that undoes the
+// wrap. An acknowledged directory's listing is recorded for 'ribbin doctor'.
+// With allow, directories are acknowledged without asking. Returns why the
+// binaries in directories that weren't acknowledged can't be wrapped, by
+// path.
+func acknowledgeSharedDirs(paths []string, reader *bufio.Reader, allow, dryRun bool) map[string]error {
+	byDir := make(map[string][]string)
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if security.RequiresAcknowledgment(path) && !security.IsDirAcknowledged(dir) {
+			byDir[dir] = append(byDir[dir], path)
+		}
+	}
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	refused := make(map[string]error)
+	for _, dir := range dirs {
+		dirPaths := byDir[dir]
+		sort.Strings(dirPaths)
+		names := make([]string, len(dirPaths))
+		for i, path := range dirPaths {
+			names[i] = filepath.Base(path)
+		}
+		fmt.Fprintf(os.Stderr, "\nThis is the first wrap in %s, which every user of this machine runs programs from.\n", dir)
+		fmt.Fprintf(os.Stderr, "  Wrapping: %s\n", strings.Join(names, ", "))
+		fmt.Fprintf(os.Stderr, "  To undo:  %s\n", unwrapCommand(dirPaths))
+		if dryRun {
+			fmt.Fprintf(os.Stderr, "  (dry run: would ask to go ahead, and record a listing of %s first)\n", dir)
+			continue
+		}
+
+		var err error
+		switch {
+		case allow:
+		case !stdinIsTerminal():
+			err = fmt.Errorf("%w: wrapping in %s needs a one-time acknowledgment; run 'ribbin wrap' on a terminal to be asked, or pass --acknowledge-dir", ErrSecurityRejected, dir)
+		case !promptYesNo(reader, fmt.Sprintf("Wrap in %s? A listing of it is recorded first.", dir), false):
+			err = fmt.Errorf("%w: wrapping in %s was not acknowledged", ErrSecurityRejected, dir)
+		}
+		if err == nil {
+			ack, ackErr := security.AcknowledgeDir(dir)
+			if ackErr != nil {
+				err = fmt.Errorf("cannot record the acknowledgment of %s: %w", dir, ackErr)
+			} else {
+				fmt.Fprintf(os.Stderr, "Recorded a listing of %s (%d entries); 'ribbin doctor' compares the directory against it.\n", dir, len(ack.Listing))
+			}
+		}
+		if err != nil {
+			for _, path := range dirPaths {
+				refused[path] = err
+			}
+		}
+	}
+	return refused
+}
+
+// unwrapCommand returns the command that unwraps the binaries at paths
+func unwrapCommand(paths []string) string {
+	command := "ribbin unwrap"
+	for _, path := range paths {
+		if wrap.NeedsSudo(path) {
+			command += " --sudo"
+			break
+		}
+	}
+	return command + " --only " + wrap.ShellQuote(strings.Join(paths, ","))
+}
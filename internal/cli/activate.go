@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/happycollision/ribbin/internal/config"
 	"github.com/happycollision/ribbin/internal/process"
@@ -13,6 +15,10 @@ import (
 var activateConfig bool
 var activateShell bool
 var activateGlobal bool
+var activateTags []string
+var activateScopes []string
+var activatePrintHook string
+var activateUnalias bool
 
 var activateCmd = &cobra.Command{
 	Use:   "activate [config-files...]",
@@ -20,9 +26,32 @@ var activateCmd = &cobra.Command{
 	Long: `Activate ribbin interception.
 
 By default (--config), activates the nearest ribbin.jsonc for all shells.
+A config activation only applies to commands run where that exact file is
+the nearest config, so activating one project leaves other projects alone.
 With --shell, activates all configs for the current shell only.
 With --global, activates everything everywhere.
 
+A shell activation lasts until the shell exits. To have it removed the
+moment the shell exits, rather than on the next registry change, add the
+hook --print-hook prints to your shell's startup file. It activates the
+shell and deactivates it on exit. The shell is taken from $SHELL, or given
+as --print-hook=bash, zsh, fish, or sh.
+
+The hook also warns about aliases and functions, defined before it runs,
+that keep wrapped commands from running, like alias npm='pnpm' when npm is
+wrapped (see 'ribbin doctor --shell'). With --unalias, the hook removes
+them from the shell instead.
+
+With --tag, a config or shell activation only covers wrappers carrying one
+of the given tags; other wrappers run the original. Activating again
+without --tag covers every wrapper.
+
+With --scope, a config activation only covers directories in the named
+scopes of the config, so hardened rules can be rolled out to one part of a
+repository at a time; elsewhere, wrappers run the original. A scope nested
+in an activated one is only covered if it is named too. Activating again
+without --scope covers the whole config.
+
 Scope flags (mutually exclusive):
   --config   Activate config(s) for all shells (DEFAULT)
   --shell    Activate all configs for current shell only
@@ -32,7 +61,11 @@ Examples:
   ribbin activate                        # Activate nearest config
   ribbin activate ./a.jsonc ./b.jsonc    # Activate specific configs
   ribbin activate --shell                # Activate for this shell
-  ribbin activate --global               # Activate globally`,
+  eval "$(ribbin activate --shell --print-hook)"   # ...until it exits (~/.bashrc)
+  eval "$(ribbin activate --shell --print-hook --unalias)"   # ...and drop shadowing aliases
+  ribbin activate --global               # Activate globally
+  ribbin activate --tag danger           # Activate only wrappers tagged "danger"
+  ribbin activate --scope backend        # Activate only in the "backend" scope`,
 	Run: func(cmd *cobra.Command, args []string) {
 		printGlobalWarningIfActive()
 
@@ -51,26 +84,59 @@ Examples:
 			fmt.Fprintf(os.Stderr, "Error: --config, --shell, and --global are mutually exclusive\n")
 			os.Exit(1)
 		}
+		if len(activateTags) > 0 && activateGlobal {
+			fmt.Fprintf(os.Stderr, "Error: --tag can't be combined with --global\n")
+			os.Exit(1)
+		}
+		if len(activateScopes) > 0 && (activateShell || activateGlobal) {
+			fmt.Fprintf(os.Stderr, "Error: --scope only applies to config activation\n")
+			os.Exit(1)
+		}
+		if len(activateScopes) > 0 && len(activateTags) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: --scope can't be combined with --tag\n")
+			os.Exit(1)
+		}
+		if err := validateTagFlags(activateTags); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 
-		// Load registry
-		registry, err := config.LoadRegistry()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading registry: %v\n", err)
+		if activateUnalias && activatePrintHook == "" {
+			fmt.Fprintf(os.Stderr, "Error: --unalias only applies with --print-hook\n")
 			os.Exit(1)
 		}
 
+		// The hook activates the shell when evaluated, so nothing is done now
+		if activatePrintHook != "" {
+			if !activateShell {
+				fmt.Fprintf(os.Stderr, "Error: --print-hook only applies with --shell\n")
+				os.Exit(1)
+			}
+			shell := activatePrintHook
+			if shell == "auto" {
+				shell = detectHookShell()
+			}
+			hook, err := shellHook(shell, activateTags, activateUnalias)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Print(hook)
+			return
+		}
+
 		// Determine activation mode (default is --config)
 		if activateGlobal {
 			// Global activation
-			if registry.GlobalActive {
+			alreadyActive := false
+			updateRegistryOrExit(func(registry *config.Registry) {
+				alreadyActive = registry.GlobalActive
+				registry.GlobalActive = true
+			})
+			if alreadyActive {
 				fmt.Println("Ribbin is already globally active")
 				return
 			}
-			registry.GlobalActive = true
-			if err := config.SaveRegistry(registry); err != nil {
-				fmt.Fprintf(os.Stderr, "Error saving registry: %v\n", err)
-				os.Exit(1)
-			}
 			fmt.Println("Ribbin is now globally active")
 			return
 		}
@@ -85,25 +151,23 @@ Examples:
 				os.Exit(1)
 			}
 
-			// Check if already activated for this shell (idempotent)
-			if _, exists := registry.ShellActivations[shellPID]; exists {
-				fmt.Printf("Ribbin already activated for shell (PID %d)\n", shellPID)
-				return
-			}
-
-			// Prune dead shell activations
-			registry.PruneDeadShellActivations()
+			alreadyActive := false
+			updateRegistryOrExit(func(registry *config.Registry) {
+				// Check if already activated for this shell (idempotent)
+				if entry, exists := registry.ShellActivations[shellPID]; exists && sameTags(entry.Tags, activateTags) {
+					alreadyActive = true
+					return
+				}
 
-			// Add new shell activation entry
-			registry.AddShellActivation(shellPID)
+				// Add new shell activation entry, or replace one with other tags
+				registry.AddShellActivation(shellPID, activateTags...)
+			})
 
-			// Save registry
-			if err := config.SaveRegistry(registry); err != nil {
-				fmt.Fprintf(os.Stderr, "Error saving registry: %v\n", err)
-				os.Exit(1)
+			if alreadyActive {
+				fmt.Printf("Ribbin already activated for shell (PID %d)%s\n", shellPID, tagsSuffix(activateTags))
+				return
 			}
-
-			fmt.Printf("Ribbin activated for shell (PID %d)\n", shellPID)
+			fmt.Printf("Ribbin activated for shell (PID %d)%s\n", shellPID, tagsSuffix(activateTags))
 			return
 		}
 
@@ -119,8 +183,8 @@ Examples:
 				}
 				// Verify file exists
 				if _, err := os.Stat(absPath); os.IsNotExist(err) {
-					fmt.Fprintf(os.Stderr, "Error: config file not found: %s\n", absPath)
-					os.Exit(1)
+					fmt.Fprintf(os.Stderr, "Error: %v\n", errConfigFileNotFound(absPath))
+					os.Exit(ExitConfigNotFound)
 				}
 				configPaths = append(configPaths, absPath)
 			}
@@ -132,30 +196,66 @@ Examples:
 				os.Exit(1)
 			}
 			if configPath == "" {
-				fmt.Fprintf(os.Stderr, "No ribbin.jsonc found. Run 'ribbin init' to create one.\n")
-				os.Exit(1)
+				fmt.Fprintln(os.Stderr, errNoConfig)
+				os.Exit(ExitConfigNotFound)
 			}
 			configPaths = []string{configPath}
 		}
 
-		// Activate each config
-		activated := 0
-		alreadyActive := 0
+		// Warn about activations that can never match the config a wrapper resolves
 		for _, configPath := range configPaths {
-			if _, exists := registry.ConfigActivations[configPath]; exists {
-				fmt.Printf("Config already active: %s\n", configPath)
-				alreadyActive++
+			if warning := configActivationWarning(configPath); warning != "" {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+			}
+		}
+
+		// Refuse scopes a config doesn't define, as they would never match
+		if len(activateScopes) > 0 {
+			for _, configPath := range configPaths {
+				if err := validateScopeFlags(configPath, activateScopes); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		}
+
+		// Refuse to activate configs that opt into strictResolve and fail it.
+		// Configs that can't be loaded here are left to fail open at shim time, as before.
+		for _, configPath := range configPaths {
+			projectConfig, err := config.LoadProjectConfig(configPath)
+			if err != nil {
 				continue
 			}
-			registry.AddConfigActivation(configPath)
-			fmt.Printf("Activated config: %s\n", configPath)
-			activated++
+			if err := config.CheckStrictResolve(projectConfig, configPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: strict resolution failed for %s:\n%v\n", configPath, err)
+				os.Exit(1)
+			}
 		}
 
-		// Save registry
-		if err := config.SaveRegistry(registry); err != nil {
-			fmt.Fprintf(os.Stderr, "Error saving registry: %v\n", err)
-			os.Exit(1)
+		// Activate each config
+		activated := 0
+		alreadyActive := 0
+		var messages []string
+		updateRegistryOrExit(func(registry *config.Registry) {
+			activated, alreadyActive, messages = 0, 0, nil
+			for _, configPath := range configPaths {
+				suffix := tagsSuffix(activateTags) + scopesSuffix(activateScopes)
+				if entry, ok := registry.ConfigActivation(configPath); ok && sameTags(entry.Tags, activateTags) && sameTags(entry.Scopes, activateScopes) {
+					messages = append(messages, fmt.Sprintf("Config already active: %s%s", configPath, suffix))
+					alreadyActive++
+					continue
+				}
+				if len(activateScopes) > 0 {
+					registry.AddScopedConfigActivation(configPath, activateScopes...)
+				} else {
+					registry.AddConfigActivation(configPath, activateTags...)
+				}
+				messages = append(messages, fmt.Sprintf("Activated config: %s%s", configPath, suffix))
+				activated++
+			}
+		})
+		for _, msg := range messages {
+			fmt.Println(msg)
 		}
 
 		if activated > 0 {
@@ -168,8 +268,97 @@ Examples:
 	},
 }
 
+// validateTagFlags checks the tags given with --tag
+func validateTagFlags(tags []string) error {
+	for _, tag := range tags {
+		if err := config.ValidateTag(tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateScopeFlags checks that the config at configPath defines each
+// scope given with --scope
+func validateScopeFlags(configPath string, scopes []string) error {
+	projectConfig, err := config.LoadProjectConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("cannot load %s to check its scopes: %w", configPath, err)
+	}
+	for _, scope := range scopes {
+		if _, ok := projectConfig.Scopes[scope]; !ok {
+			names := make([]string, 0, len(projectConfig.Scopes))
+			for name := range projectConfig.Scopes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if len(names) == 0 {
+				return fmt.Errorf("%s has no scopes, so it has no scope %q", configPath, scope)
+			}
+			return fmt.Errorf("%s has no scope %q (scopes: %s)", configPath, scope, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// sameTags reports whether two activations cover the same tags (or scopes),
+// in any order
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// tagsSuffix describes the tags an activation is limited to, for messages
+func tagsSuffix(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (tags: %s)", strings.Join(tags, ", "))
+}
+
+// scopesSuffix describes the scopes an activation is limited to, for messages
+func scopesSuffix(scopes []string) string {
+	if len(scopes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (scopes: %s)", strings.Join(scopes, ", "))
+}
+
+// configActivationWarning explains why activating configPath would have no
+// effect, or returns "" if it is fine. Wrappers only fire for a config
+// activation when the config they resolve from the working directory is the
+// activated file, so an unrecognized file name or a config shadowed by a local
+// override in the same directory never matches.
+func configActivationWarning(configPath string) string {
+	if !config.IsConfigFileName(filepath.Base(configPath)) {
+		return fmt.Sprintf("%s is not a recognized config file name (e.g. %s), so wrappers never resolve to it and activating it has no effect",
+			configPath, config.ConfigFileName)
+	}
+	if resolved := config.ConfigInDir(filepath.Dir(configPath)); resolved != "" && resolved != configPath {
+		return fmt.Sprintf("%s takes precedence over %s, so wrappers resolve to it instead; activate it to enable wrappers in this project",
+			resolved, filepath.Base(configPath))
+	}
+	return ""
+}
+
 func init() {
 	activateCmd.Flags().BoolVar(&activateConfig, "config", false, "Activate config(s) for all shells (default if no flag specified)")
 	activateCmd.Flags().BoolVar(&activateShell, "shell", false, "Activate all configs for current shell only")
 	activateCmd.Flags().BoolVar(&activateGlobal, "global", false, "Activate everything everywhere")
+	activateCmd.Flags().StringSliceVar(&activateTags, "tag", nil, "Activate only wrappers with these tags (comma-separated or repeated)")
+	activateCmd.Flags().StringSliceVar(&activateScopes, "scope", nil, "Activate config(s) only in these named scopes (comma-separated or repeated)")
+	activateCmd.Flags().StringVar(&activatePrintHook, "print-hook", "", "With --shell, print shell code to eval that activates the shell and deactivates it on exit (bash, zsh, fish, sh; default from $SHELL)")
+	activateCmd.Flags().Lookup("print-hook").NoOptDefVal = "auto"
+	activateCmd.Flags().BoolVar(&activateUnalias, "unalias", false, "With --print-hook, have the hook remove aliases and functions keeping wrapped commands from running, instead of warning about them")
 }
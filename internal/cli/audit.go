@@ -41,6 +41,9 @@ Event types include:
   shim.install          - Wrapper installed
   shim.uninstall        - Wrapper uninstalled
   bypass.used           - RIBBIN_BYPASS=1 used
+  override.used         - Blocked command run from the override prompt
+  rescue.used           - RIBBIN_RESCUE=1 used
+  invocation.logged     - Command run through builtin:log-and-pass
   security.violation    - Security policy violated
   privileged.operation  - Operation performed as root
   config.load           - Configuration loaded
@@ -192,6 +195,8 @@ func runAuditSummary(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  Elevated Ops:         %d\n", summary.ElevatedOps)
 	fmt.Printf("  Security Violations:  %d\n", summary.SecurityViolations)
 	fmt.Printf("  Bypass Usages:        %d\n", summary.BypassUsages)
+	fmt.Printf("  Override Usages:      %d\n", summary.OverrideUsages)
+	fmt.Printf("  Rescue Usages:        %d\n", summary.RescueUsages)
 	fmt.Println()
 
 	// Show warnings if needed
@@ -200,6 +205,11 @@ func runAuditSummary(cmd *cobra.Command, args []string) error {
 		fmt.Fprintf(os.Stderr, "   Run 'ribbin audit show --type security.violation' for details\n\n")
 	}
 
+	if summary.RescueUsages > 0 {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: RIBBIN_RESCUE was used %d times\n", summary.RescueUsages)
+		fmt.Fprintf(os.Stderr, "   Run 'ribbin audit show --type rescue.used' for details\n\n")
+	}
+
 	if summary.BypassUsages > 10 {
 		fmt.Fprintf(os.Stderr, "ℹ️  Note: RIBBIN_BYPASS was used %d times\n", summary.BypassUsages)
 		fmt.Fprintf(os.Stderr, "   Run 'ribbin audit show --type bypass.used' for details\n\n")
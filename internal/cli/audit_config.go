@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var auditConfigJSON bool
+
+var auditConfigCmd = &cobra.Command{
+	Use:   "audit-config <path>",
+	Short: "Review a config without wrapping anything",
+	Long: `Review a config, such as a shared policy before extending it, without
+acting on it. Nothing is wrapped, the registry isn't read, and no file is
+written; remote extends are only read from the cache.
+
+The audit validates the config, resolves its scopes and extends, and lists
+every wrapper in effect at the root and in each scope with where it was
+defined. It then checks what the wrappers point at:
+  - paths that don't exist
+  - critical system binaries, and paths in system or forbidden directories
+  - redirect scripts and hook programs that don't exist, aren't executable,
+    are world-writable, or are outside the config's directory
+
+Exit codes:
+  0  no errors or dangers (warnings may be listed)
+  1  the config has errors, or wrappers or targets the security policy
+     refuses or only allows with confirmation
+  2  the config file doesn't exist
+
+Examples:
+  ribbin audit-config ./vendor/policy/ribbin.jsonc
+  ribbin audit-config team-policy.jsonc --json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath, err := filepath.Abs(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to resolve path %s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		if _, err := os.Stat(configPath); err != nil {
+			err = errConfigFileNotFound(configPath)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(ExitCode(err))
+		}
+
+		audit, err := config.AuditConfig(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if auditConfigJSON {
+			if err := printAuditJSON(os.Stdout, audit); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		} else {
+			printAudit(os.Stdout, audit)
+		}
+
+		if audit.HasProblems() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	auditConfigCmd.Flags().BoolVar(&auditConfigJSON, "json", false, "Print the audit as JSON")
+	rootCmd.AddCommand(auditConfigCmd)
+}
+
+// auditConfigOutput is the JSON output of audit-config
+type auditConfigOutput struct {
+	ConfigPath string                `json:"config_path"`
+	Files      []string              `json:"files"`
+	Wrappers   []auditedWrapperJSON  `json:"wrappers"`
+	Findings   []config.AuditFinding `json:"findings"`
+	OK         bool                  `json:"ok"`
+}
+
+type auditedWrapperJSON struct {
+	Fragment string         `json:"fragment"`
+	Command  string         `json:"command"`
+	Action   string         `json:"action"`
+	Paths    []string       `json:"paths,omitempty"`
+	Redirect string         `json:"redirect,omitempty"`
+	Source   shimSourceJSON `json:"source"`
+}
+
+func printAuditJSON(out io.Writer, audit *config.ConfigAudit) error {
+	output := auditConfigOutput{
+		ConfigPath: audit.ConfigPath,
+		Files:      append([]string{}, audit.Files...),
+		Wrappers:   []auditedWrapperJSON{},
+		Findings:   append([]config.AuditFinding{}, audit.Findings...),
+		OK:         !audit.HasProblems(),
+	}
+	for _, w := range audit.Wrappers {
+		output.Wrappers = append(output.Wrappers, auditedWrapperJSON{
+			Fragment: w.Fragment,
+			Command:  w.Command,
+			Action:   w.Config.Action,
+			Paths:    w.Config.Paths,
+			Redirect: w.Config.Redirect,
+			Source:   convertShimSourceToJSON(w.Source),
+		})
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(output); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return nil
+}
+
+// printAudit prints the wrappers of an audited config and its findings
+func printAudit(out io.Writer, audit *config.ConfigAudit) {
+	fmt.Fprintf(out, "Config: %s\n", audit.ConfigPath)
+	for _, file := range audit.Files {
+		fmt.Fprintf(out, "  uses %s\n", file)
+	}
+	fmt.Fprintln(out)
+
+	if len(audit.Wrappers) == 0 {
+		fmt.Fprintln(out, "No wrappers.")
+	} else {
+		fmt.Fprintln(out, "Wrappers:")
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  FRAGMENT\tCOMMAND\tACTION\tDEFINED IN")
+		for _, wrapper := range audit.Wrappers {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s#%s\n", wrapper.Fragment, wrapper.Command, wrapper.Config.Action,
+				wrapper.Source.FilePath, wrapper.Source.Fragment)
+		}
+		w.Flush()
+	}
+	fmt.Fprintln(out)
+
+	if len(audit.Findings) == 0 {
+		fmt.Fprintln(out, "✓ No problems found")
+		return
+	}
+	fmt.Fprintln(out, "Findings:")
+	for _, finding := range audit.Findings {
+		if finding.Location != "" {
+			fmt.Fprintf(out, "  %-7s  %s: %s\n", finding.Severity, finding.Location, finding.Message)
+		} else {
+			fmt.Fprintf(out, "  %-7s  %s\n", finding.Severity, finding.Message)
+		}
+	}
+	if audit.HasProblems() {
+		fmt.Fprintln(out, "\n✗ Not safe to adopt as it is")
+	} else {
+		fmt.Fprintln(out, "\n✓ No errors or dangers, only warnings")
+	}
+}
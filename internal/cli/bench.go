@@ -0,0 +1,334 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchIterations int
+	benchConfig     string
+	benchJSON       bool
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench [flags] [-- command [args...]]",
+	Short: "Measure the overhead a wrapper adds to running a command",
+	Long: `Measure the overhead a wrapper adds to running a command.
+
+Wraps a command in a throwaway directory and times running it directly,
+through the wrapper, and through the wrapper with the decision cache
+disabled. The command defaults to 'true', so the numbers are almost entirely
+process startup and ribbin's own work.
+
+The wrapper passes through to the original. Use --config to time the
+decisions of a real config instead: the command runs from the config's
+directory, with ribbin globally active in an isolated registry. Nothing in
+your registry, audit log, or cache is touched.
+
+Examples:
+  ribbin bench                          # Overhead on 'true'
+  ribbin bench -n 1000 -- cat /etc/hosts
+  ribbin bench --config ./ribbin.jsonc  # Decisions under a real config
+  ribbin bench --json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			args = []string{"true"}
+		}
+		if benchIterations < 1 {
+			fmt.Fprintf(os.Stderr, "Error: --iterations must be at least 1\n")
+			os.Exit(1)
+		}
+
+		configPath := ""
+		if benchConfig != "" {
+			abs, err := filepath.Abs(benchConfig)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to get absolute path: %v\n", err)
+				os.Exit(1)
+			}
+			if _, err := config.LoadProjectConfig(abs); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to load config: %v\n", err)
+				os.Exit(1)
+			}
+			configPath = abs
+		}
+
+		if !benchJSON {
+			fmt.Fprintf(os.Stderr, "Running %s %d times per mode...\n", strings.Join(args, " "), benchIterations)
+		}
+		results, err := runBench(args, configPath, benchIterations)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if benchJSON {
+			if err := printBenchJSON(os.Stdout, args, benchIterations, results); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		printBenchText(os.Stdout, results)
+	},
+}
+
+func init() {
+	benchCmd.Flags().IntVarP(&benchIterations, "iterations", "n", 200, "Runs per mode")
+	benchCmd.Flags().StringVar(&benchConfig, "config", "", "Time decisions under this config instead of a generated one")
+	benchCmd.Flags().BoolVar(&benchJSON, "json", false, "Output in JSON format")
+}
+
+// benchStats summarizes the run times of one mode
+type benchStats struct {
+	Mode   string        `json:"mode"`
+	Mean   time.Duration `json:"mean_ns"`
+	Median time.Duration `json:"median_ns"`
+	P95    time.Duration `json:"p95_ns"`
+	Min    time.Duration `json:"min_ns"`
+	// Overhead is the median minus the direct median
+	Overhead time.Duration `json:"overhead_ns"`
+}
+
+// summarizeDurations computes benchStats for a non-empty list of run times
+func summarizeDurations(mode string, durations []time.Duration) benchStats {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	p95 := (len(sorted)*95+99)/100 - 1
+	return benchStats{
+		Mode:   mode,
+		Mean:   total / time.Duration(len(sorted)),
+		Median: sorted[len(sorted)/2],
+		P95:    sorted[p95],
+		Min:    sorted[0],
+	}
+}
+
+// benchEnv is a throwaway wrapper around a command: a shim next to a
+// sidecar linking to the real binary, a config, and an isolated registry.
+type benchEnv struct {
+	dir      string
+	original string
+	shim     string
+	cwd      string
+	env      []string
+}
+
+// newBenchEnv sets up a wrapper for command under configPath ("" generates a
+// config that passes it through). Call cleanup when done.
+func newBenchEnv(command, configPath string) (*benchEnv, error) {
+	original, err := exec.LookPath(command)
+	if err != nil {
+		return nil, fmt.Errorf("command not found: %s", command)
+	}
+	if original, err = filepath.Abs(original); err != nil {
+		return nil, err
+	}
+	name := filepath.Base(original)
+	if name == "ribbin" || name == "ribbin-next" {
+		return nil, fmt.Errorf("can't benchmark ribbin itself")
+	}
+
+	ribbinPath, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("cannot locate ribbin executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(ribbinPath); err == nil {
+		ribbinPath = resolved
+	}
+
+	dir, err := os.MkdirTemp("", "ribbin-bench-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	b := &benchEnv{dir: dir, original: original}
+	if err := b.setup(name, ribbinPath, configPath); err != nil {
+		b.cleanup()
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *benchEnv) setup(name, ribbinPath, configPath string) error {
+	binDir := filepath.Join(b.dir, "bin")
+	projectDir := filepath.Join(b.dir, "project")
+	configHome := filepath.Join(b.dir, "config")
+	for _, dir := range []string{binDir, projectDir, filepath.Join(configHome, "ribbin")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+
+	b.shim = filepath.Join(binDir, name)
+	if err := os.Symlink(b.original, b.shim+".ribbin-original"); err != nil {
+		return fmt.Errorf("failed to create sidecar: %w", err)
+	}
+	if err := os.Symlink(ribbinPath, b.shim); err != nil {
+		return fmt.Errorf("failed to create shim: %w", err)
+	}
+
+	if configPath == "" {
+		configPath = filepath.Join(projectDir, "ribbin.jsonc")
+		content := fmt.Sprintf(`{"wrappers": {%q: {"action": "passthrough"}}}`, name)
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write config: %w", err)
+		}
+	}
+	b.cwd = filepath.Dir(configPath)
+
+	registry := &config.Registry{
+		Wrappers:          map[string]config.WrapperEntry{name: {Original: b.shim, Config: configPath}},
+		ShellActivations:  make(map[int]config.ShellActivationEntry),
+		ConfigActivations: make(map[string]config.ConfigActivationEntry),
+		GlobalActive:      true,
+	}
+	data, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(configHome, "ribbin", "registry.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write registry: %w", err)
+	}
+
+	// Keep the caller's environment, minus anything that changes what ribbin does
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "RIBBIN_") && !strings.HasPrefix(kv, "XDG_") {
+			b.env = append(b.env, kv)
+		}
+	}
+	b.env = append(b.env,
+		"XDG_CONFIG_HOME="+configHome,
+		"XDG_STATE_HOME="+filepath.Join(b.dir, "state"),
+		"XDG_CACHE_HOME="+filepath.Join(b.dir, "cache"),
+	)
+	return nil
+}
+
+func (b *benchEnv) cleanup() {
+	os.RemoveAll(b.dir)
+}
+
+// time runs path with args once and returns how long it took and its exit code
+func (b *benchEnv) time(path string, args []string, extraEnv ...string) (time.Duration, int, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Dir = b.cwd
+	cmd.Env = append(append([]string(nil), b.env...), extraEnv...)
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return elapsed, exitErr.ExitCode(), nil
+	}
+	return elapsed, 0, err
+}
+
+// runBench times args directly and through a wrapper, iterations times per mode
+func runBench(args []string, configPath string, iterations int) ([]benchStats, error) {
+	b, err := newBenchEnv(args[0], configPath)
+	if err != nil {
+		return nil, err
+	}
+	defer b.cleanup()
+
+	modes := []struct {
+		name string
+		path string
+		env  []string
+	}{
+		{"direct", b.original, nil},
+		{"wrapped", b.shim, nil},
+		{"wrapped, no decision cache", b.shim, []string{"RIBBIN_DECISION_CACHE=0"}},
+	}
+
+	// Every mode must behave the same, or the wrapper isn't passing through
+	_, wantCode, err := b.time(b.original, args[1:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", b.original, err)
+	}
+
+	var results []benchStats
+	for _, mode := range modes {
+		// One untimed run warms the page cache and the decision cache
+		if _, _, err := b.time(mode.path, args[1:], mode.env...); err != nil {
+			return nil, fmt.Errorf("failed to run %s: %w", mode.path, err)
+		}
+
+		durations := make([]time.Duration, 0, iterations)
+		for i := 0; i < iterations; i++ {
+			elapsed, code, err := b.time(mode.path, args[1:], mode.env...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to run %s: %w", mode.path, err)
+			}
+			if code != wantCode {
+				return nil, fmt.Errorf("%s exited %d %s but %d directly; is it blocked or redirected by the config?",
+					args[0], code, mode.name, wantCode)
+			}
+			durations = append(durations, elapsed)
+		}
+		results = append(results, summarizeDurations(mode.name, durations))
+	}
+
+	for i := range results {
+		results[i].Overhead = results[i].Median - results[0].Median
+	}
+	return results, nil
+}
+
+func printBenchText(w io.Writer, results []benchStats) {
+	fmt.Fprintf(w, "%-28s %10s %10s %10s %10s %10s\n", "MODE", "MEDIAN", "MEAN", "P95", "MIN", "OVERHEAD")
+	for _, r := range results {
+		overhead := "-"
+		if r.Mode != "direct" {
+			overhead = formatBenchDuration(r.Overhead)
+			if r.Overhead >= 0 {
+				overhead = "+" + overhead
+			}
+		}
+		fmt.Fprintf(w, "%-28s %10s %10s %10s %10s %10s\n", r.Mode,
+			formatBenchDuration(r.Median), formatBenchDuration(r.Mean),
+			formatBenchDuration(r.P95), formatBenchDuration(r.Min), overhead)
+	}
+}
+
+// formatBenchDuration formats d in milliseconds, which is the scale that matters here
+func formatBenchDuration(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
+
+// benchOutput is the JSON output of 'ribbin bench'
+type benchOutput struct {
+	Command    []string     `json:"command"`
+	Iterations int          `json:"iterations"`
+	Results    []benchStats `json:"results"`
+}
+
+func printBenchJSON(w io.Writer, args []string, iterations int, results []benchStats) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(benchOutput{Command: args, Iterations: iterations, Results: results}); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return nil
+}
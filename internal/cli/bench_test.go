@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestSummarizeDurations(t *testing.T) {
+	var durations []time.Duration
+	for i := 100; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+
+	got := summarizeDurations("direct", durations)
+	want := benchStats{
+		Mode:   "direct",
+		Mean:   50500 * time.Microsecond,
+		Median: 51 * time.Millisecond,
+		P95:    95 * time.Millisecond,
+		Min:    time.Millisecond,
+	}
+	if got != want {
+		t.Errorf("summarizeDurations = %+v, want %+v", got, want)
+	}
+	if durations[0] != 100*time.Millisecond {
+		t.Error("summarizeDurations should not reorder its input")
+	}
+
+	single := summarizeDurations("wrapped", []time.Duration{3 * time.Millisecond})
+	if single.Median != 3*time.Millisecond || single.P95 != 3*time.Millisecond {
+		t.Errorf("single run = %+v", single)
+	}
+}
+
+func TestPrintBenchText(t *testing.T) {
+	var buf bytes.Buffer
+	printBenchText(&buf, []benchStats{
+		{Mode: "direct", Median: time.Millisecond},
+		{Mode: "wrapped", Median: 3 * time.Millisecond, Overhead: 2 * time.Millisecond},
+		{Mode: "wrapped, no decision cache", Median: 900 * time.Microsecond, Overhead: -100 * time.Microsecond},
+	})
+	out := buf.String()
+
+	for _, want := range []string{"+2.00ms", "-0.10ms"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "+-") {
+		t.Errorf("negative overhead should not get a plus sign:\n%s", out)
+	}
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var bootstrapNonInteractive bool
+var bootstrapConfirmSystemDir bool
+var bootstrapAuto bool
+var bootstrapAcknowledgeDir bool
+
+var bootstrapCmd = &cobra.Command{
+	Use:   "bootstrap [config-files...]",
+	Short: "Wrap and globally activate ribbin in one step (for containers)",
+	Long: `Install the wrappers from the repository's ribbin.jsonc and activate
+ribbin globally, in one step.
+
+bootstrap is meant for Dockerfiles, devcontainer postCreateCommand and other
+provisioning scripts, where ribbin should be fully set up without anyone at
+the keyboard. It runs 'ribbin wrap' for the nearest ribbin.jsonc (or the
+given config files) and then 'ribbin activate --global'.
+
+With --non-interactive nothing is ever asked: policies come from flags and
+environment variables, and anything not allowed fails instead of prompting.
+Without it, bootstrap asks whether to allow system directories (unless
+already allowed) and whether to activate globally.
+
+Policies:
+  --confirm-system-dir           Allow wrapping in /bin, /usr/bin, etc.
+  RIBBIN_CONFIRM_SYSTEM_DIR=1    Same as --confirm-system-dir
+  --acknowledge-dir              Agree to the first wrap in /usr/local/bin, /opt, etc.
+  RIBBIN_ACKNOWLEDGE_DIR=1       Same as --acknowledge-dir
+  RIBBIN_NON_INTERACTIVE=1       Same as --non-interactive
+
+Wrapping is all-or-nothing as with 'ribbin wrap': if any binary fails to
+wrap, nothing is wrapped, ribbin is not activated, and bootstrap exits
+non-zero so the image build or container setup fails loudly.
+
+Examples:
+  # Dockerfile
+  RUN ribbin bootstrap --non-interactive --confirm-system-dir --acknowledge-dir
+
+  # devcontainer.json
+  "postCreateCommand": "ribbin bootstrap --non-interactive --auto"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		nonInteractive := bootstrapNonInteractive || os.Getenv("RIBBIN_NON_INTERACTIVE") == "1"
+		confirm := bootstrapConfirmSystemDir || os.Getenv("RIBBIN_CONFIRM_SYSTEM_DIR") == "1"
+
+		// Resolve the configs up front so a missing config fails before any prompt
+		var configPaths []string
+		for _, arg := range args {
+			absPath, err := filepath.Abs(arg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error resolving path %s: %v\n", arg, err)
+				os.Exit(1)
+			}
+			if _, err := os.Stat(absPath); err != nil {
+				fmt.Fprintln(os.Stderr, errConfigFileNotFound(absPath))
+				os.Exit(ExitConfigNotFound)
+			}
+			configPaths = append(configPaths, absPath)
+		}
+		if len(configPaths) == 0 {
+			configPath, err := config.FindProjectConfig()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error finding config: %v\n", err)
+				os.Exit(1)
+			}
+			if configPath == "" {
+				fmt.Fprintln(os.Stderr, errNoConfig)
+				os.Exit(ExitConfigNotFound)
+			}
+			configPaths = []string{configPath}
+		}
+
+		confirm, activate := bootstrapPolicy(nonInteractive, confirm, os.Stdin)
+
+		// Wrap with the resolved policy. wrap exits non-zero on failure,
+		// after rolling back, so activation below only runs on success.
+		confirmSystemDir = confirm
+		wrapAcknowledgeDir = bootstrapAcknowledgeDir || os.Getenv("RIBBIN_ACKNOWLEDGE_DIR") == "1"
+		wrapAuto = bootstrapAuto
+		wrapInteractive = false
+		wrapCmd.Run(wrapCmd, configPaths)
+
+		if !activate {
+			fmt.Println("\nNot activating. Run 'ribbin activate --global' when ready.")
+			return
+		}
+		updateRegistryOrExit(func(registry *config.Registry) {
+			registry.GlobalActive = true
+		})
+		fmt.Println("\nRibbin is now globally active")
+	},
+}
+
+// bootstrapPolicy decides whether bootstrap may wrap in system directories
+// and whether it activates globally. Non-interactive runs never read input:
+// system directories need confirm and activation always happens. Otherwise
+// the user is asked on in, skipping the system directory question if
+// confirm already allows it.
+func bootstrapPolicy(nonInteractive, confirm bool, in io.Reader) (allowSystemDir, activate bool) {
+	if nonInteractive {
+		return confirm, true
+	}
+	reader := bufio.NewReader(in)
+	if !confirm {
+		confirm = promptYesNo(reader, "Allow wrapping binaries in system directories like /usr/bin?", false)
+	}
+	return confirm, promptYesNo(reader, "Activate ribbin globally after wrapping?", true)
+}
+
+func init() {
+	bootstrapCmd.Flags().BoolVar(&bootstrapNonInteractive, "non-interactive", false,
+		"Never prompt; take policies from flags and environment variables (RIBBIN_NON_INTERACTIVE=1)")
+	bootstrapCmd.Flags().BoolVar(&bootstrapConfirmSystemDir, "confirm-system-dir", false,
+		"Allow wrapping in system directories like /usr/bin (RIBBIN_CONFIRM_SYSTEM_DIR=1)")
+	bootstrapCmd.Flags().BoolVar(&bootstrapAcknowledgeDir, "acknowledge-dir", false,
+		"Agree to the first wrap in shared directories like /usr/local/bin (RIBBIN_ACKNOWLEDGE_DIR=1)")
+	bootstrapCmd.Flags().BoolVar(&bootstrapAuto, "auto", false,
+		"For wrappers without paths, wrap every safe binary found (see 'ribbin wrap --auto')")
+	rootCmd.AddCommand(bootstrapCmd)
+}
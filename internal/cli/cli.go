@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/happycollision/ribbin/internal/cli/render"
 	"github.com/happycollision/ribbin/internal/config"
 	"github.com/happycollision/ribbin/internal/wrap"
 	"github.com/spf13/cobra"
@@ -23,6 +24,20 @@ func printGlobalWarningIfActive() {
 	}
 }
 
+// updateRegistryOrExit applies fn to the registry under the registry lock,
+// exiting with an error message and ExitCode(err) if the registry can't be
+// read or written.
+func updateRegistryOrExit(fn func(registry *config.Registry)) {
+	err := config.UpdateRegistry(func(registry *config.Registry) error {
+		fn(registry)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating registry: %v\n", err)
+		os.Exit(ExitCode(err))
+	}
+}
+
 // Version is set by ldflags at build time
 var Version = "dev"
 
@@ -49,6 +64,7 @@ For more information, see https://github.com/happycollision/ribbin`,
 func init() {
 	rootCmd.SetVersionTemplate(fmt.Sprintf("ribbin %s\n", Version))
 	rootCmd.Flags().BoolP("version", "V", false, "Print version information")
+	rootCmd.PersistentFlags().BoolVar(&render.NoColor, "no-color", false, "Don't color output (also off when NO_COLOR is set)")
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(wrapCmd)
 	rootCmd.AddCommand(unwrapCmd)
@@ -57,6 +73,12 @@ func init() {
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(recoverCmd)
 	rootCmd.AddCommand(findCmd)
+	rootCmd.AddCommand(snoozeCmd)
+	rootCmd.AddCommand(healCmd)
+	rootCmd.AddCommand(relinkCmd)
+	rootCmd.AddCommand(whichCmd)
+	rootCmd.AddCommand(benchCmd)
+	rootCmd.AddCommand(selfUpdateCmd)
 
 	// Set version for metadata in wrap package
 	wrap.Version = Version
@@ -1,15 +1,21 @@
 package cli
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/happycollision/ribbin/internal/testsafety"
 
 	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
 )
 
 // setupTestEnv creates a test environment with temp HOME and working directory
@@ -376,6 +382,67 @@ func TestDeactivateEverything(t *testing.T) {
 	})
 }
 
+func TestDeactivateStale(t *testing.T) {
+	tempHome, tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	liveConfig := createTestConfig(t, tempDir, `{"wrappers": {}}`)
+	missingConfig := filepath.Join(tempDir, "deleted", "ribbin.jsonc")
+	registry := &config.Registry{
+		Wrappers: make(map[string]config.WrapperEntry),
+		ShellActivations: map[int]config.ShellActivationEntry{
+			os.Getpid(): {PID: os.Getpid()},
+			99999999:    {PID: 99999999},
+		},
+		ConfigActivations: map[string]config.ConfigActivationEntry{
+			liveConfig:    {},
+			missingConfig: {},
+		},
+		GlobalActive: true,
+	}
+	createTestRegistry(t, tempHome, registry)
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	deactivateConfig, deactivateShell, deactivateGlobal = false, false, false
+	deactivateAll, deactivateEverything = false, false
+	deactivateStale = true
+	defer func() { deactivateStale = false }()
+	deactivateCmd.Run(deactivateCmd, []string{})
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	for _, want := range []string{"Removed 1 activation(s) of exited shells", missingConfig} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+
+	loaded, err := config.LoadRegistry()
+	if err != nil {
+		t.Fatalf("LoadRegistry error: %v", err)
+	}
+	if !loaded.GlobalActive {
+		t.Error("--stale should not turn off global mode")
+	}
+	if _, ok := loaded.ShellActivations[os.Getpid()]; !ok {
+		t.Error("live shell activation should be kept")
+	}
+	if !loaded.IsConfigActive(liveConfig) {
+		t.Error("existing config should stay active")
+	}
+	if loaded.IsConfigActive(missingConfig) {
+		t.Error("missing config should be deactivated")
+	}
+}
+
 func TestStatusCommand(t *testing.T) {
 	tempHome, _, cleanup := setupTestEnv(t)
 	defer cleanup()
@@ -534,3 +601,372 @@ func TestPrintGlobalWarningIfActive(t *testing.T) {
 		}
 	})
 }
+
+func TestSnoozeCommand(t *testing.T) {
+	tempHome, _, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	createTestRegistry(t, tempHome, &config.Registry{
+		Wrappers: map[string]config.WrapperEntry{
+			"/usr/local/bin/npm": {Original: "/usr/local/bin/npm", Config: "/project/ribbin.jsonc"},
+		},
+	})
+
+	resetSnoozeFlags := func() {
+		snoozeAll = false
+		snoozeClear = false
+		snoozeFor = 0
+		snoozeCmd.Flags().Lookup("for").Changed = false
+	}
+	defer resetSnoozeFlags()
+
+	runSnooze := func(args ...string) string {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		snoozeCmd.Run(snoozeCmd, args)
+
+		w.Close()
+		os.Stdout = oldStdout
+
+		var buf bytes.Buffer
+		buf.ReadFrom(r)
+		return buf.String()
+	}
+
+	t.Run("snoozes a command", func(t *testing.T) {
+		resetSnoozeFlags()
+		snoozeCmd.Flags().Set("for", "30m")
+
+		output := runSnooze("npm")
+		if !bytes.Contains([]byte(output), []byte("Snoozed npm")) {
+			t.Errorf("unexpected output: %q", output)
+		}
+
+		loaded, _ := config.LoadRegistry()
+		entry, ok := loaded.ActiveSnooze("npm", time.Now())
+		if !ok {
+			t.Fatal("npm should be snoozed")
+		}
+		if left := time.Until(entry.Until); left < 29*time.Minute || left > 30*time.Minute {
+			t.Errorf("unexpected snooze duration: %v", left)
+		}
+	})
+
+	t.Run("lists active snoozes", func(t *testing.T) {
+		resetSnoozeFlags()
+		output := runSnooze()
+		if !bytes.Contains([]byte(output), []byte("npm:")) {
+			t.Errorf("expected npm in snooze list, got %q", output)
+		}
+	})
+
+	t.Run("clear removes snoozes", func(t *testing.T) {
+		resetSnoozeFlags()
+		snoozeClear = true
+		runSnooze()
+
+		loaded, _ := config.LoadRegistry()
+		if _, ok := loaded.ActiveSnooze("npm", time.Now()); ok {
+			t.Error("npm snooze should be cleared")
+		}
+	})
+}
+
+func TestHealTargets(t *testing.T) {
+	registry := &config.Registry{Wrappers: map[string]config.WrapperEntry{}}
+	registry.AddWrapper(config.WrapperEntry{Original: "/project/node_modules/.bin/tsc", Config: "/project/ribbin.jsonc"})
+	registry.AddWrapper(config.WrapperEntry{Original: "/other/node_modules/.bin/tsc", Config: "/other/ribbin.jsonc"})
+	registry.AddWrapper(config.WrapperEntry{Original: "/usr/local/bin/npm", Config: "/project/ribbin.jsonc"})
+
+	t.Run("no args heals every wrapper in order", func(t *testing.T) {
+		paths, err := healTargets(registry, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"/other/node_modules/.bin/tsc", "/project/node_modules/.bin/tsc", "/usr/local/bin/npm"}
+		if strings.Join(paths, " ") != strings.Join(want, " ") {
+			t.Errorf("paths = %v, want %v", paths, want)
+		}
+	})
+
+	t.Run("a command name selects every binary with that name", func(t *testing.T) {
+		paths, err := healTargets(registry, []string{"tsc"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(paths) != 2 {
+			t.Errorf("paths = %v, want both tsc binaries", paths)
+		}
+	})
+
+	t.Run("a path selects one binary", func(t *testing.T) {
+		paths, err := healTargets(registry, []string{"/other/node_modules/.bin/tsc"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(paths) != 1 || paths[0] != "/other/node_modules/.bin/tsc" {
+			t.Errorf("paths = %v", paths)
+		}
+	})
+
+	t.Run("named commands must be wrapped", func(t *testing.T) {
+		_, err := healTargets(registry, []string{"yarn"})
+		if !errors.Is(err, ErrNotWrapped) {
+			t.Errorf("error = %v, want ErrNotWrapped", err)
+		}
+	})
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, 0},
+		{"other error", errors.New("boom"), ExitFailure},
+		{"no config", errNoConfig, ExitConfigNotFound},
+		{"config path missing", errConfigFileNotFound("/x/ribbin.jsonc"), ExitConfigNotFound},
+		{"wrapped not wrapped", fmt.Errorf("heal: %w", ErrNotWrapped), ExitNotWrapped},
+		{"security", fmt.Errorf("%w: system directory", ErrSecurityRejected), ExitSecurityRejected},
+		{"lock timeout", fmt.Errorf("cannot acquire lock: %w", security.ErrLockTimeout), ExitLockTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigActivationWarning(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	projectConfig := write("ribbin.jsonc")
+	if warning := configActivationWarning(projectConfig); warning != "" {
+		t.Errorf("unexpected warning for plain project config: %s", warning)
+	}
+
+	custom := write("custom.jsonc")
+	if warning := configActivationWarning(custom); !strings.Contains(warning, "not a recognized config file name") {
+		t.Errorf("expected unrecognized-name warning, got %q", warning)
+	}
+
+	local := write("ribbin.local.jsonc")
+	if warning := configActivationWarning(projectConfig); !strings.Contains(warning, local) {
+		t.Errorf("expected warning naming the local override, got %q", warning)
+	}
+	if warning := configActivationWarning(local); warning != "" {
+		t.Errorf("unexpected warning for the local override itself: %s", warning)
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"  \n", nil, false},
+		{"2", []int{1}, false},
+		{"3,1", []int{0, 2}, false},
+		{"1 2-4", []int{0, 1, 2, 3}, false},
+		{"2-3,3", []int{1, 2}, false},
+		{"all\n", []int{0, 1, 2, 3}, false},
+		{"0", nil, true},
+		{"5", nil, true},
+		{"3-2", nil, true},
+		{"tsc", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSelection(tt.input, 4)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSelection(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("parseSelection(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestPickWrappers(t *testing.T) {
+	registry := &config.Registry{
+		Wrappers: map[string]config.WrapperEntry{
+			"/project/node_modules/.bin/tsc": {Original: "/project/node_modules/.bin/tsc", Config: "/project/ribbin.jsonc"},
+			"/usr/local/bin/npm":             {Original: "/usr/local/bin/npm", Config: "/project/ribbin.jsonc"},
+			"/usr/local/bin/yarn":            {Original: "/usr/local/bin/yarn", Config: "/other/ribbin.jsonc"},
+		},
+	}
+
+	// The list is sorted by path: 1=tsc, 2=npm, 3=yarn
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"1,3\n", []string{"/project/node_modules/.bin/tsc", "/usr/local/bin/yarn"}},
+		{"2", []string{"/usr/local/bin/npm"}},
+		{"\n", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		old := os.Stdout
+		os.Stdout, _ = os.Open(os.DevNull)
+		got, err := pickWrappers(registry, bufio.NewReader(strings.NewReader(tt.input)))
+		os.Stdout = old
+
+		if err != nil {
+			t.Errorf("pickWrappers(%q) error = %v", tt.input, err)
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("pickWrappers(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestBootstrapPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		nonInteractive bool
+		confirm        bool
+		input          string
+		wantSystemDir  bool
+		wantActivate   bool
+	}{
+		{"non-interactive ignores input", true, false, "y\ny\n", false, true},
+		{"non-interactive with confirm", true, true, "", true, true},
+		{"asks both", false, false, "y\nn\n", true, false},
+		{"defaults", false, false, "\n\n", false, true},
+		{"confirm skips system dir question", false, true, "n\n", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := os.Stdout
+			os.Stdout, _ = os.Open(os.DevNull)
+			gotSystemDir, gotActivate := bootstrapPolicy(tt.nonInteractive, tt.confirm, strings.NewReader(tt.input))
+			os.Stdout = old
+
+			if gotSystemDir != tt.wantSystemDir || gotActivate != tt.wantActivate {
+				t.Errorf("bootstrapPolicy() = (%v, %v), want (%v, %v)", gotSystemDir, gotActivate, tt.wantSystemDir, tt.wantActivate)
+			}
+		})
+	}
+}
+
+func TestRegistryMigrateCommand(t *testing.T) {
+	tempHome, _, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	registryPath := filepath.Join(tempHome, ".config", "ribbin", "registry.json")
+	if err := os.MkdirAll(filepath.Dir(registryPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	v1 := `{"wrappers": {"tsc": {"original": "/project/node_modules/.bin/tsc", "config": "/project/ribbin.jsonc"}}}`
+	if err := os.WriteFile(registryPath, []byte(v1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	old := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	err := runRegistryMigrate(registryMigrateCmd, nil)
+	os.Stdout = old
+	if err != nil {
+		t.Fatalf("runRegistryMigrate error: %v", err)
+	}
+
+	if backup, err := os.ReadFile(registryPath + ".v1.bak"); err != nil || string(backup) != v1 {
+		t.Errorf("expected the v1 registry to be kept as a backup, got %q (%v)", backup, err)
+	}
+	data, _ := os.ReadFile(registryPath)
+	if !strings.Contains(string(data), `"version": 2`) || !strings.Contains(string(data), `"/project/node_modules/.bin/tsc": {`) {
+		t.Errorf("registry was not migrated:\n%s", data)
+	}
+}
+
+func TestRegistryImportCommand(t *testing.T) {
+	tempHome, _, cleanup := setupTestEnv(t)
+	defer cleanup()
+	defer func() { registryImportRewrap = false }()
+
+	// A project moved from /home/old to this home, reinstalled unwrapped
+	projectDir := filepath.Join(tempHome, "web")
+	binDir := filepath.Join(projectDir, "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	configPath := createTestConfig(t, projectDir, `{"wrappers": {"tool": {"action": "block", "paths": ["./bin/tool"]}}}`)
+	toolPath := filepath.Join(binDir, "tool")
+	if err := os.WriteFile(toolPath, []byte("#!/bin/sh\necho tool"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	bundlePath := filepath.Join(tempHome, "bundle.json")
+	bundle := `{
+  "version": 1,
+  "home": "/home/old",
+  "wrappers": [
+    {"original": "/home/old/web/bin/tool", "config": "/home/old/web/ribbin.jsonc"},
+    {"original": "/home/old/web/bin/gone", "config": "/home/old/web/ribbin.jsonc"}
+  ],
+  "config_activations": {"/home/old/web/ribbin.jsonc": {"activated_at": "2026-01-01T00:00:00Z", "tags": ["ci"]}},
+  "global_active": false
+}`
+	if err := os.WriteFile(bundlePath, []byte(bundle), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runImport := func() error {
+		old := os.Stdout
+		os.Stdout, _ = os.Open(os.DevNull)
+		defer func() { os.Stdout = old }()
+		return runRegistryImport(registryImportCmd, []string{bundlePath})
+	}
+
+	// Without --rewrap the unwrapped binary is only reported
+	if err := runImport(); err != nil {
+		t.Fatalf("runRegistryImport error: %v", err)
+	}
+	registry, err := config.LoadRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registry.Wrappers) != 0 {
+		t.Errorf("nothing should be tracked without --rewrap, got %+v", registry.Wrappers)
+	}
+	entry, ok := registry.ConfigActivation(configPath)
+	if !ok || len(entry.Tags) != 1 || entry.Tags[0] != "ci" {
+		t.Errorf("the moved config should be activated with its tags, got %+v", registry.ConfigActivations)
+	}
+
+	registryImportRewrap = true
+	if err := runImport(); err != nil {
+		t.Fatalf("runRegistryImport --rewrap error: %v", err)
+	}
+	registry, err = config.LoadRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := registry.Wrapper(toolPath); !ok || got.Config != configPath {
+		t.Errorf("tool should be tracked for %s, got %+v", configPath, registry.Wrappers)
+	}
+	if _, err := os.Lstat(toolPath + ".ribbin-original"); err != nil {
+		t.Errorf("tool should be wrapped: %v", err)
+	}
+	if len(registry.Wrappers) != 1 {
+		t.Errorf("the missing binary should be skipped, got %+v", registry.Wrappers)
+	}
+}
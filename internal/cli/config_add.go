@@ -56,7 +56,7 @@ func runConfigAdd(cmd *cobra.Command, args []string) error {
 		configPath = args[0]
 		cmdName = args[1]
 		if _, err := os.Stat(configPath); os.IsNotExist(err) {
-			return fmt.Errorf("config file not found: %s", configPath)
+			return errConfigFileNotFound(configPath)
 		}
 	} else {
 		cmdName = args[0]
@@ -65,7 +65,7 @@ func runConfigAdd(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to find config: %w", err)
 		}
 		if configPath == "" {
-			return fmt.Errorf("ribbin.jsonc not found. Run 'ribbin init' first.")
+			return errNoConfig
 		}
 	}
 
@@ -58,7 +58,7 @@ func runConfigEdit(cmd *cobra.Command, args []string) error {
 		configPath = args[0]
 		cmdName = args[1]
 		if _, err := os.Stat(configPath); os.IsNotExist(err) {
-			return fmt.Errorf("config file not found: %s", configPath)
+			return errConfigFileNotFound(configPath)
 		}
 	} else {
 		cmdName = args[0]
@@ -67,7 +67,7 @@ func runConfigEdit(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to find config: %w", err)
 		}
 		if configPath == "" {
-			return fmt.Errorf("ribbin.jsonc not found. Run 'ribbin init' first.")
+			return errNoConfig
 		}
 	}
 
@@ -126,8 +126,9 @@ func runConfigEdit(cmd *cobra.Command, args []string) error {
 	}
 
 	if cmd.Flags().Changed("redirect") {
-		oldValue := existingShim.Redirect
+		oldValue := existingShim.RedirectDisplay()
 		updatedShim.Redirect = editRedirect
+		updatedShim.RedirectArgv = nil
 		if oldValue == "" {
 			changes = append(changes, fmt.Sprintf("  redirect: (none) -> %q", editRedirect))
 		} else {
@@ -139,10 +140,11 @@ func runConfigEdit(cmd *cobra.Command, args []string) error {
 		if updatedShim.Action == "redirect" {
 			return fmt.Errorf("cannot clear redirect when action=redirect")
 		}
-		if existingShim.Redirect != "" {
-			changes = append(changes, fmt.Sprintf("  redirect: %q -> (cleared)", existingShim.Redirect))
+		if existingShim.HasRedirect() {
+			changes = append(changes, fmt.Sprintf("  redirect: %q -> (cleared)", existingShim.RedirectDisplay()))
 		}
 		updatedShim.Redirect = ""
+		updatedShim.RedirectArgv = nil
 	}
 
 	if cmd.Flags().Changed("paths") {
@@ -163,7 +165,7 @@ func runConfigEdit(cmd *cobra.Command, args []string) error {
 	}
 
 	// Validate: if action=redirect, redirect field must not be empty
-	if updatedShim.Action == "redirect" && updatedShim.Redirect == "" {
+	if updatedShim.Action == "redirect" && !updatedShim.HasRedirect() {
 		return fmt.Errorf("redirect field required when action=redirect")
 	}
 
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configExplainJSON bool
+
+var configExplainCmd = &cobra.Command{
+	Use:   "explain <command>",
+	Short: "Show how a command's wrapper is resolved in the current directory",
+	Long: `Show how a command's wrapper is resolved in the current directory.
+
+Lists every definition of the command's wrapper in the configs involved, in
+the order they merge: the user config, parent configs merged with
+"root": false, the nearest config's root wrappers (and their imports) and
+scopes, and the files scopes extend. Each is marked as the effective
+definition, overridden (merged here, but another wins), or not applied,
+with the reason: a scope that doesn't match this directory, a file no
+matching scope extends, and so on. The final action is shown last.
+
+'ribbin config show' lists the effective wrappers; this shows why one
+command's wrapper is what it is.
+
+Examples:
+  ribbin config explain npm
+  ribbin config explain npm --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runConfigExplain,
+}
+
+func init() {
+	configExplainCmd.Flags().BoolVar(&configExplainJSON, "json", false, "Output in JSON format")
+	configCmd.AddCommand(configExplainCmd)
+}
+
+// configExplainOutput is the JSON output of config explain
+type configExplainOutput struct {
+	Command        string                `json:"command"`
+	ConfigPath     string                `json:"config_path,omitempty"`
+	UserConfigPath string                `json:"user_config_path,omitempty"`
+	Scope          *scopeOutput          `json:"scope,omitempty"`
+	Candidates     []candidateOutputJSON `json:"candidates"`
+	Action         string                `json:"action,omitempty"`
+	Source         *shimSourceJSON       `json:"source,omitempty"`
+}
+
+type candidateOutputJSON struct {
+	FilePath string `json:"file_path"`
+	Fragment string `json:"fragment"`
+	Action   string `json:"action,omitempty"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+func runConfigExplain(cmd *cobra.Command, args []string) error {
+	ex, err := config.ExplainResolution(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to resolve config: %w", err)
+	}
+	if ex.ConfigPath == "" && ex.UserConfigPath == "" {
+		return errNoConfig
+	}
+
+	if configExplainJSON {
+		output := configExplainOutput{
+			Command:        ex.Command,
+			ConfigPath:     ex.ConfigPath,
+			UserConfigPath: ex.UserConfigPath,
+			Candidates:     []candidateOutputJSON{},
+		}
+		if ex.Scope != nil {
+			output.Scope = &scopeOutput{Name: ex.Scope.Name, Path: ex.Scope.Config.Path}
+		}
+		for _, c := range ex.Candidates {
+			output.Candidates = append(output.Candidates, candidateOutputJSON{
+				FilePath: c.Source.FilePath,
+				Fragment: c.Source.Fragment,
+				Action:   c.Config.Action,
+				Status:   c.Status,
+				Reason:   c.Reason,
+			})
+		}
+		if ex.Effective != nil {
+			output.Action = ex.Effective.Config.Effective(time.Now()).Action
+			source := convertShimSourceToJSON(ex.Effective.Source)
+			output.Source = &source
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(output); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+		return nil
+	}
+
+	printExplanation(os.Stdout, ex)
+	return nil
+}
+
+// printExplanation prints the resolution table for a command
+func printExplanation(out io.Writer, ex *config.ResolutionExplanation) {
+	fmt.Fprintf(out, "Command: %s\n", ex.Command)
+	if ex.ConfigPath != "" {
+		fmt.Fprintf(out, "Config:  %s\n", ex.ConfigPath)
+		if ex.Scope != nil {
+			scopePath := ex.Scope.Config.Path
+			if scopePath == "" {
+				scopePath = "."
+			}
+			fmt.Fprintf(out, "Scope:   %s (path: %s)\n", ex.Scope.Name, scopePath)
+		} else {
+			fmt.Fprintf(out, "Scope:   (root)\n")
+		}
+	} else {
+		fmt.Fprintf(out, "Config:  (none, only the user config applies)\n")
+	}
+	if ex.UserConfigPath != "" {
+		fmt.Fprintf(out, "User:    %s\n", ex.UserConfigPath)
+	}
+
+	fmt.Fprintln(out)
+	if len(ex.Candidates) == 0 {
+		fmt.Fprintf(out, "No config defines a wrapper for '%s'; it runs unwrapped.\n", ex.Command)
+		return
+	}
+
+	fmt.Fprintln(out, "Definitions, in merge order:")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  SOURCE\tACTION\tSTATUS")
+	for _, c := range ex.Candidates {
+		action := c.Config.Action
+		if action == "" {
+			action = "-"
+		}
+		status := c.Status
+		if c.Reason != "" {
+			status += ": " + c.Reason
+		}
+		fmt.Fprintf(w, "  %s#%s\t%s\t%s\n", c.Source.FilePath, c.Source.Fragment, action, status)
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	if ex.Effective == nil {
+		fmt.Fprintf(out, "Result: no definition applies here, so '%s' runs unwrapped.\n", ex.Command)
+		return
+	}
+	effective := ex.Effective.Config
+	fmt.Fprintf(out, "Result: %s (from %s#%s)\n", effective.Action, ex.Effective.Source.FilePath, ex.Effective.Source.Fragment)
+	if effective.Expired(time.Now()) {
+		fmt.Fprintf(out, "        expired on %s, so it acts as %s\n", effective.Expires, effective.ActionAfterExpiry())
+	}
+}
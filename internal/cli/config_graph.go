@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configGraphDOT bool
+
+var configGraphCmd = &cobra.Command{
+	Use:   "graph [config-path]",
+	Short: "Show how root wrappers and scopes inherit through extends",
+	Long: `Show how root wrappers and scopes inherit through extends.
+
+Draws one tree for the root wrappers and one for each scope, following
+extends through local fragments, external files and remote configs. Each
+node lists the wrappers it defines; wrappers that lose to another source are
+marked with the source that wins at the top of the tree.
+
+If no path is provided, uses the nearest config.
+
+Use --dot for Graphviz output:
+  ribbin config graph --dot | dot -Tsvg > graph.svg
+
+Examples:
+  ribbin config graph
+  ribbin config graph ./ribbin.jsonc
+  ribbin config graph --dot`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runConfigGraph,
+}
+
+func init() {
+	configGraphCmd.Flags().BoolVar(&configGraphDOT, "dot", false, "Output in Graphviz DOT format")
+	configCmd.AddCommand(configGraphCmd)
+}
+
+func runConfigGraph(cmd *cobra.Command, args []string) error {
+	var configPath string
+	var err error
+
+	if len(args) > 0 {
+		configPath, err = filepath.Abs(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path: %w", err)
+		}
+	} else {
+		configPath, err = config.FindProjectConfig()
+		if err != nil {
+			return fmt.Errorf("failed to find config: %w", err)
+		}
+		if configPath == "" {
+			return errNoConfig
+		}
+	}
+
+	cfg, err := config.LoadProjectConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	graphs := config.BuildExtendsGraph(cfg, configPath)
+	if configGraphDOT {
+		writeGraphDOT(os.Stdout, configPath, graphs)
+	} else {
+		writeGraphText(os.Stdout, configPath, graphs)
+	}
+	return nil
+}
+
+// writeGraphText draws each graph as an indented tree
+func writeGraphText(w io.Writer, configPath string, graphs []*config.GraphNode) {
+	fmt.Fprintf(w, "Config: %s\n", configPath)
+	for _, graph := range graphs {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, graphNodeLine(configPath, graph))
+		writeGraphChildren(w, configPath, graph, "")
+	}
+}
+
+func writeGraphChildren(w io.Writer, configPath string, node *config.GraphNode, prefix string) {
+	for i, child := range node.Extends {
+		branch, indent := "├── ", "│   "
+		if i == len(node.Extends)-1 {
+			branch, indent = "└── ", "    "
+		}
+		fmt.Fprintf(w, "%s%s%s\n", prefix, branch, graphNodeLine(configPath, child))
+		writeGraphChildren(w, configPath, child, prefix+indent)
+	}
+}
+
+// graphNodeLine describes a node on one line: what it is and the wrappers it defines
+func graphNodeLine(configPath string, node *config.GraphNode) string {
+	line := graphNodeLabel(configPath, node)
+	if node.Err != nil {
+		return line + "  ERROR: " + node.Err.Error()
+	}
+	if len(node.Wrappers) == 0 {
+		return line + "  (no wrappers)"
+	}
+	return line + "  " + strings.Join(graphWrapperLabels(configPath, node), ", ")
+}
+
+// graphNodeLabel names a node by how it was referenced, noting its file when
+// it isn't configPath and its path when it is a scope
+func graphNodeLabel(configPath string, node *config.GraphNode) string {
+	label := node.Ref
+	if node.FilePath != "" && node.FilePath != configPath && node.Ref == node.Fragment {
+		label += " [" + node.FilePath + "]"
+	}
+	if node.ScopePath != "" {
+		label += " (path: " + node.ScopePath + ")"
+	}
+	return label
+}
+
+// graphWrapperLabels lists a node's wrappers, marking overridden ones
+func graphWrapperLabels(configPath string, node *config.GraphNode) []string {
+	labels := make([]string, 0, len(node.Wrappers))
+	for _, name := range node.Wrappers {
+		if winner, ok := node.Overridden[name]; ok {
+			labels = append(labels, fmt.Sprintf("%s (overridden by %s)", name, graphSourceLabel(configPath, winner)))
+		} else {
+			labels = append(labels, name)
+		}
+	}
+	return labels
+}
+
+// graphSourceLabel names a wrapper source, leaving out the file for configPath
+func graphSourceLabel(configPath string, source config.ShimSource) string {
+	if source.FilePath == configPath {
+		return source.Fragment
+	}
+	return source.FilePath + "#" + source.Fragment
+}
+
+// writeGraphDOT writes the graphs in Graphviz DOT format, one cluster per
+// graph. Edges are numbered in extends order; later ones win.
+func writeGraphDOT(w io.Writer, configPath string, graphs []*config.GraphNode) {
+	fmt.Fprintln(w, "digraph ribbin {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box, fontname=\"monospace\"];")
+
+	id := 0
+	var writeNode func(node *config.GraphNode, indent string) string
+	writeNode = func(node *config.GraphNode, indent string) string {
+		id++
+		nodeID := fmt.Sprintf("n%d", id)
+
+		lines := []string{graphNodeLabel(configPath, node)}
+		attrs := ""
+		if node.Err != nil {
+			lines = append(lines, "ERROR: "+node.Err.Error())
+			attrs = ", color=red"
+		} else {
+			lines = append(lines, graphWrapperLabels(configPath, node)...)
+		}
+		fmt.Fprintf(w, "%s%s [label=%s%s];\n", indent, nodeID, dotQuote(strings.Join(lines, "\n")+"\n"), attrs)
+
+		for i, child := range node.Extends {
+			childID := writeNode(child, indent)
+			fmt.Fprintf(w, "%s%s -> %s [label=\"%d\"];\n", indent, nodeID, childID, i+1)
+		}
+		return nodeID
+	}
+
+	for i, graph := range graphs {
+		fmt.Fprintf(w, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(w, "    label=%s;\n", dotQuote(graph.Ref))
+		writeNode(graph, "    ")
+		fmt.Fprintln(w, "  }")
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// dotQuote quotes s as a DOT string, with newlines as left-aligned line breaks
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\l`)
+	return `"` + s + `"`
+}
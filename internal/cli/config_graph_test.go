@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func testGraphs(configPath string) []*config.GraphNode {
+	return []*config.GraphNode{
+		{Ref: "root", FilePath: configPath, Fragment: "root", Wrappers: []string{"npm"}},
+		{
+			Ref: "root.app", FilePath: configPath, Fragment: "root.app", ScopePath: "apps/app",
+			Wrappers: []string{"tsc"},
+			Extends: []*config.GraphNode{
+				{
+					Ref: "root", FilePath: configPath, Fragment: "root",
+					Wrappers: []string{"npm", "tsc"},
+					Overridden: map[string]config.ShimSource{
+						"tsc": {FilePath: configPath, Fragment: "root.app"},
+					},
+				},
+				{Ref: "./missing.jsonc", Err: errors.New("file not found")},
+			},
+		},
+	}
+}
+
+func TestWriteGraphText(t *testing.T) {
+	configPath := "/project/ribbin.jsonc"
+	var buf bytes.Buffer
+	writeGraphText(&buf, configPath, testGraphs(configPath))
+
+	want := `Config: /project/ribbin.jsonc
+
+root  npm
+
+root.app (path: apps/app)  tsc
+├── root  npm, tsc (overridden by root.app)
+└── ./missing.jsonc  ERROR: file not found
+`
+	if buf.String() != want {
+		t.Errorf("writeGraphText output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteGraphDOT(t *testing.T) {
+	configPath := "/project/ribbin.jsonc"
+	var buf bytes.Buffer
+	writeGraphDOT(&buf, configPath, testGraphs(configPath))
+	out := buf.String()
+
+	for _, want := range []string{
+		"digraph ribbin {",
+		`subgraph cluster_1 {`,
+		`label="root.app";`,
+		`n2 [label="root.app (path: apps/app)\ltsc\l"];`,
+		`n3 [label="root\lnpm\ltsc (overridden by root.app)\l"];`,
+		`n2 -> n3 [label="1"];`,
+		`n4 [label="./missing.jsonc\lERROR: file not found\l", color=red];`,
+		`n2 -> n4 [label="2"];`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -45,7 +45,7 @@ func runConfigList(cmd *cobra.Command, args []string) error {
 	if len(args) > 0 {
 		configPath = args[0]
 		if _, err := os.Stat(configPath); os.IsNotExist(err) {
-			return fmt.Errorf("config file not found: %s", configPath)
+			return errConfigFileNotFound(configPath)
 		}
 	} else {
 		configPath, err = config.FindProjectConfig()
@@ -53,7 +53,7 @@ func runConfigList(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to find config: %w", err)
 		}
 		if configPath == "" {
-			return fmt.Errorf("No ribbin.jsonc found. Run 'ribbin init' to create one.")
+			return errNoConfig
 		}
 	}
 
@@ -129,14 +129,14 @@ func formatDetails(shimCfg config.ShimConfig) string {
 	var parts []string
 
 	// Add redirect target if present
-	if shimCfg.Redirect != "" {
-		parts = append(parts, shimCfg.Redirect)
+	if shimCfg.HasRedirect() {
+		parts = append(parts, shimCfg.RedirectDisplay())
 	}
 
 	// Add message if present
 	if shimCfg.Message != "" {
 		// For redirects, show message in parentheses if there's already a redirect
-		if shimCfg.Redirect != "" {
+		if shimCfg.HasRedirect() {
 			parts = append(parts, fmt.Sprintf("(%s)", shimCfg.Message))
 		} else {
 			parts = append(parts, shimCfg.Message)
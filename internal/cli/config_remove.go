@@ -44,7 +44,7 @@ func runConfigRemove(cmd *cobra.Command, args []string) error {
 		configPath = args[0]
 		cmdName = args[1]
 		if _, err := os.Stat(configPath); os.IsNotExist(err) {
-			return fmt.Errorf("config file not found: %s", configPath)
+			return errConfigFileNotFound(configPath)
 		}
 	} else {
 		cmdName = args[0]
@@ -53,7 +53,7 @@ func runConfigRemove(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to find config: %w", err)
 		}
 		if configPath == "" {
-			return fmt.Errorf("ribbin.jsonc not found. Run 'ribbin init' first.")
+			return errNoConfig
 		}
 	}
 
@@ -77,8 +77,8 @@ func runConfigRemove(cmd *cobra.Command, args []string) error {
 		if shimCfg.Message != "" {
 			fmt.Printf("  Message: %s\n", shimCfg.Message)
 		}
-		if shimCfg.Redirect != "" {
-			fmt.Printf("  Redirect: %s\n", shimCfg.Redirect)
+		if shimCfg.HasRedirect() {
+			fmt.Printf("  Redirect: %s\n", shimCfg.RedirectDisplay())
 		}
 		if len(shimCfg.Paths) > 0 {
 			fmt.Printf("  Paths: %s\n", strings.Join(shimCfg.Paths, ", "))
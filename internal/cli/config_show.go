@@ -6,7 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 
+	"github.com/happycollision/ribbin/internal/cli/render"
 	"github.com/happycollision/ribbin/internal/config"
 	"github.com/spf13/cobra"
 )
@@ -78,7 +80,7 @@ func runConfigShow(cmd *cobra.Command, args []string) error {
 		// Use specified config file
 		configPath = args[0]
 		if _, err := os.Stat(configPath); os.IsNotExist(err) {
-			return fmt.Errorf("config file not found: %s", configPath)
+			return errConfigFileNotFound(configPath)
 		}
 		configPath, err = filepath.Abs(configPath)
 		if err != nil {
@@ -116,7 +118,7 @@ func runConfigShow(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to get effective config: %w", err)
 		}
 		if configPath == "" {
-			return fmt.Errorf("No ribbin.jsonc found. Run 'ribbin init' to create one.")
+			return errNoConfig
 		}
 	}
 
@@ -167,7 +169,7 @@ func convertResolvedShimToJSON(resolved config.ResolvedShim) resolvedShimJSON {
 	result := resolvedShimJSON{
 		Action:   resolved.Config.Action,
 		Message:  resolved.Config.Message,
-		Redirect: resolved.Config.Redirect,
+		Redirect: resolved.Config.RedirectDisplay(),
 		Paths:    resolved.Config.Paths,
 		Source:   convertShimSourceToJSON(resolved.Source),
 	}
@@ -187,8 +189,10 @@ func convertShimSourceToJSON(source config.ShimSource) shimSourceJSON {
 }
 
 func outputShowText(configPath string, matchedScope *config.MatchedScope, shims map[string]config.ResolvedShim) error {
+	r := render.Stdout()
+
 	// Print config file path
-	fmt.Printf("Config: %s\n", configPath)
+	r.Printf("Config: %s\n", r.Paint(render.Cyan, configPath))
 
 	// Print scope info
 	if matchedScope != nil {
@@ -196,62 +200,89 @@ func outputShowText(configPath string, matchedScope *config.MatchedScope, shims
 		if scopePath == "" {
 			scopePath = "."
 		}
-		fmt.Printf("Scope:  %s (path: %s)\n", matchedScope.Name, scopePath)
+		r.Printf("Scope:  %s (path: %s)\n", matchedScope.Name, scopePath)
 	} else {
-		fmt.Printf("Scope:  (root)\n")
+		r.Printf("Scope:  (root)\n")
 	}
 
 	// Check if there are any wrappers
 	if len(shims) == 0 {
-		fmt.Println("\nNo effective wrappers configured")
+		r.Println("\nNo effective wrappers configured")
 		return nil
 	}
 
-	// Get sorted command names for consistent output
-	commands := make([]string, 0, len(shims))
-	for cmd := range shims {
-		commands = append(commands, cmd)
-	}
-	sort.Strings(commands)
-
-	fmt.Println("\nEffective wrappers:")
-
-	for _, cmd := range commands {
-		resolved := shims[cmd]
-		fmt.Printf("  %s\n", cmd)
-		fmt.Printf("    action:  %s\n", resolved.Config.Action)
-
-		if resolved.Config.Message != "" {
-			fmt.Printf("    message: %q\n", resolved.Config.Message)
+	// Group the wrappers by the file defining them, the nearest config first
+	byFile := make(map[string][]string)
+	var files []string
+	for cmd, resolved := range shims {
+		file := resolved.Source.FilePath
+		if _, seen := byFile[file]; !seen {
+			files = append(files, file)
 		}
-
-		if resolved.Config.Redirect != "" {
-			fmt.Printf("    redirect: %s\n", resolved.Config.Redirect)
+		byFile[file] = append(byFile[file], cmd)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if (files[i] == configPath) != (files[j] == configPath) {
+			return files[i] == configPath
 		}
-
-		if len(resolved.Config.Paths) > 0 {
-			fmt.Printf("    paths: %v\n", resolved.Config.Paths)
+		return files[i] < files[j]
+	})
+
+	r.Println()
+	r.Heading("Effective wrappers:")
+	for i, file := range files {
+		if i > 0 {
+			r.Println()
 		}
-
-		// Print source with fragment
-		fmt.Printf("    source:  %s#%s\n", resolved.Source.FilePath, resolved.Source.Fragment)
-
-		// Print override chain if present
-		if resolved.Source.Overrode != nil {
-			printOverrideChain(resolved.Source.Overrode, 1)
+		commands := byFile[file]
+		sort.Strings(commands)
+		r.Printf("  %s\n", r.Paint(render.Cyan, file))
+
+		table := r.Table(4, "COMMAND", "ACTION", "FRAGMENT", "DETAILS")
+		for _, cmd := range commands {
+			resolved := shims[cmd]
+			table.StyledRow(
+				render.Cell{Text: cmd, Style: render.Bold},
+				render.Cell{Text: resolved.Config.Action, Style: actionStyle(resolved.Config.Action)},
+				render.Cell{Text: resolved.Source.Fragment},
+				render.Cell{Text: wrapperDetails(resolved.Config)},
+			)
+			for source := resolved.Source.Overrode; source != nil; source = source.Overrode {
+				table.StyledRow(render.Cell{}, render.Cell{}, render.Cell{},
+					render.Cell{Text: fmt.Sprintf("(overrides %s#%s)", source.FilePath, source.Fragment), Style: render.Dim})
+			}
 		}
+		table.Flush()
 	}
 
 	return nil
 }
 
-func printOverrideChain(source *config.ShimSource, depth int) {
-	indent := "             " // aligns with "source:  "
-	for i := 0; i < depth; i++ {
-		indent += "  "
+// wrapperDetails summarizes a wrapper's message, redirect and paths for a
+// table cell
+func wrapperDetails(wrapper config.ShimConfig) string {
+	var details []string
+	if wrapper.Message != "" {
+		details = append(details, fmt.Sprintf("%q", wrapper.Message))
 	}
-	fmt.Printf("%s(overrides %s#%s)\n", indent, source.FilePath, source.Fragment)
-	if source.Overrode != nil {
-		printOverrideChain(source.Overrode, depth+1)
+	if wrapper.HasRedirect() {
+		details = append(details, "redirect: "+wrapper.RedirectDisplay())
+	}
+	if len(wrapper.Paths) > 0 {
+		details = append(details, "paths: "+strings.Join(wrapper.Paths, ", "))
+	}
+	return strings.Join(details, "; ")
+}
+
+// actionStyle is the color an action is shown in
+func actionStyle(action string) render.Style {
+	switch action {
+	case "block":
+		return render.Red
+	case "warn":
+		return render.Yellow
+	case "redirect":
+		return render.Cyan
 	}
+	return render.Plain
 }
@@ -8,20 +8,35 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var configValidateStrict bool
+
 var configValidateCmd = &cobra.Command{
 	Use:   "validate [path]",
 	Short: "Validate a ribbin.jsonc config file",
-	Long: `Validate a ribbin.jsonc config file against the JSON schema.
+	Long: `Validate a ribbin.jsonc config file.
 
 If no path is provided, validates the nearest ribbin.jsonc.
 
+Checks performed:
+  - JSONC syntax and JSON schema (required fields, valid actions)
+  - extends references resolve (files exist, fragments exist, no cycles)
+  - passthrough.invocationRegexp entries compile
+  - scope paths are safe, exist, and are reachable
+  - wrapper paths are absolute or start with ./ or ../
+  - unknown properties (warnings)
+
+Errors and warnings include line/column positions where possible.
+
+Use --strict to treat warnings as errors.
+
 Exit codes:
-  0 - Valid (may include warnings about unknown properties)
-  1 - Invalid (schema validation failed)`,
+  0 - Valid (may include warnings unless --strict)
+  1 - Invalid (errors, or warnings with --strict)`,
 	RunE: runConfigValidate,
 }
 
 func init() {
+	configValidateCmd.Flags().BoolVar(&configValidateStrict, "strict", false, "Treat warnings (unknown properties, unreachable scopes, odd paths) as errors")
 	configCmd.AddCommand(configValidateCmd)
 }
 
@@ -37,26 +52,28 @@ func runConfigValidate(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to find config: %w", err)
 		}
 		if configPath == "" {
-			return fmt.Errorf("no ribbin.jsonc found. Run 'ribbin init' to create one")
+			return errNoConfig
 		}
 	}
 
 	fmt.Printf("Validating %s...\n", configPath)
 
-	// Read the file
-	content, err := os.ReadFile(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
-	}
-
 	// Validate and get both errors and warnings
-	errors, warnings := config.ValidateAgainstSchemaWithDetails(content)
+	errors, warnings := config.ValidateConfigFile(configPath)
 
-	if len(errors) > 0 {
+	if len(errors) > 0 || (configValidateStrict && len(warnings) > 0) {
 		fmt.Println("\u2717 Invalid")
-		fmt.Println("\nErrors:")
-		for _, e := range errors {
-			fmt.Printf("  - %s\n", e)
+		if len(errors) > 0 {
+			fmt.Println("\nErrors:")
+			for _, e := range errors {
+				fmt.Printf("  - %s\n", e)
+			}
+		}
+		if len(warnings) > 0 {
+			fmt.Println("\nWarnings (errors in --strict mode):")
+			for _, w := range warnings {
+				fmt.Printf("  - %s\n", w)
+			}
 		}
 		os.Exit(1)
 	}
@@ -64,7 +81,7 @@ func runConfigValidate(cmd *cobra.Command, args []string) error {
 	fmt.Println("\u2713 Valid")
 
 	if len(warnings) > 0 {
-		fmt.Println("\nUnknown properties (harmless, but not part of schema):")
+		fmt.Println("\nWarnings (harmless, but probably not what you meant):")
 		for _, w := range warnings {
 			fmt.Printf("  - %s\n", w)
 		}
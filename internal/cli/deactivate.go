@@ -14,6 +14,7 @@ var deactivateShell bool
 var deactivateGlobal bool
 var deactivateAll bool
 var deactivateEverything bool
+var deactivateStale bool
 
 var deactivateCmd = &cobra.Command{
 	Use:   "deactivate [config-files...]",
@@ -28,6 +29,11 @@ Scope flags (mutually exclusive):
 Modifier flags:
   --all         Deactivate ALL items in the chosen scope
   --everything  Nuclear option: deactivate global + all shells + all configs
+  --stale       Only remove activations that can no longer fire
+
+Shell activations of shells that have exited are removed automatically.
+Activations of config files that no longer exist are kept until --stale
+removes them, since a config can briefly disappear while switching branches.
 
 Examples:
   ribbin deactivate                        # Deactivate nearest config
@@ -37,7 +43,8 @@ Examples:
   ribbin deactivate --shell                # Deactivate current shell
   ribbin deactivate --shell --all          # Deactivate ALL shells
   ribbin deactivate --global               # Turn off global mode
-  ribbin deactivate --everything           # Nuclear: global + all shells + all configs`,
+  ribbin deactivate --everything           # Nuclear: global + all shells + all configs
+  ribbin deactivate --stale                # Clean up deleted configs and exited shells`,
 	Run: func(cmd *cobra.Command, args []string) {
 		printGlobalWarningIfActive()
 
@@ -47,6 +54,11 @@ Examples:
 			return
 		}
 
+		if deactivateStale {
+			runDeactivateStale()
+			return
+		}
+
 		// Check for mutually exclusive scope flags
 		scopeCount := 0
 		if deactivateConfig {
@@ -63,25 +75,18 @@ Examples:
 			os.Exit(1)
 		}
 
-		// Load registry
-		registry, err := config.LoadRegistry()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading registry: %v\n", err)
-			os.Exit(1)
-		}
-
 		// Determine mode
 		if deactivateGlobal {
 			// Turn off global mode
-			if !registry.GlobalActive {
+			wasActive := false
+			updateRegistryOrExit(func(registry *config.Registry) {
+				wasActive = registry.GlobalActive
+				registry.GlobalActive = false
+			})
+			if !wasActive {
 				fmt.Println("Global mode is already inactive")
 				return
 			}
-			registry.GlobalActive = false
-			if err := config.SaveRegistry(registry); err != nil {
-				fmt.Fprintf(os.Stderr, "Error saving registry: %v\n", err)
-				os.Exit(1)
-			}
 			fmt.Println("Global mode deactivated")
 			return
 		}
@@ -90,31 +95,30 @@ Examples:
 			// Shell deactivation
 			if deactivateAll {
 				// Deactivate all shells
-				count := len(registry.ShellActivations)
+				count := 0
+				updateRegistryOrExit(func(registry *config.Registry) {
+					count = len(registry.ShellActivations)
+					registry.ClearShellActivations()
+				})
 				if count == 0 {
 					fmt.Println("No active shell activations")
 					return
 				}
-				registry.ClearShellActivations()
-				if err := config.SaveRegistry(registry); err != nil {
-					fmt.Fprintf(os.Stderr, "Error saving registry: %v\n", err)
-					os.Exit(1)
-				}
 				fmt.Printf("Deactivated %d shell activation(s)\n", count)
 				return
 			}
 
 			// Deactivate current shell only
 			shellPID := os.Getppid()
-			if _, exists := registry.ShellActivations[shellPID]; !exists {
+			existed := false
+			updateRegistryOrExit(func(registry *config.Registry) {
+				_, existed = registry.ShellActivations[shellPID]
+				registry.RemoveShellActivation(shellPID)
+			})
+			if !existed {
 				fmt.Printf("Shell (PID %d) is not activated\n", shellPID)
 				return
 			}
-			registry.RemoveShellActivation(shellPID)
-			if err := config.SaveRegistry(registry); err != nil {
-				fmt.Fprintf(os.Stderr, "Error saving registry: %v\n", err)
-				os.Exit(1)
-			}
 			fmt.Printf("Deactivated shell (PID %d)\n", shellPID)
 			return
 		}
@@ -122,16 +126,15 @@ Examples:
 		// Config deactivation (default scope)
 		if deactivateAll {
 			// Deactivate all configs
-			count := len(registry.ConfigActivations)
+			count := 0
+			updateRegistryOrExit(func(registry *config.Registry) {
+				count = len(registry.ConfigActivations)
+				registry.ClearConfigActivations()
+			})
 			if count == 0 {
 				fmt.Println("No active config activations")
 				return
 			}
-			registry.ClearConfigActivations()
-			if err := config.SaveRegistry(registry); err != nil {
-				fmt.Fprintf(os.Stderr, "Error saving registry: %v\n", err)
-				os.Exit(1)
-			}
 			fmt.Printf("Deactivated %d config(s)\n", count)
 			return
 		}
@@ -156,8 +159,8 @@ Examples:
 				os.Exit(1)
 			}
 			if configPath == "" {
-				fmt.Fprintf(os.Stderr, "No ribbin.jsonc found. Run 'ribbin init' to create one.\n")
-				os.Exit(1)
+				fmt.Fprintln(os.Stderr, errNoConfig)
+				os.Exit(ExitConfigNotFound)
 			}
 			configPaths = []string{configPath}
 		}
@@ -165,21 +168,22 @@ Examples:
 		// Deactivate each config
 		deactivated := 0
 		notActive := 0
-		for _, configPath := range configPaths {
-			if _, exists := registry.ConfigActivations[configPath]; !exists {
-				fmt.Printf("Config not active: %s\n", configPath)
-				notActive++
-				continue
+		var messages []string
+		updateRegistryOrExit(func(registry *config.Registry) {
+			deactivated, notActive, messages = 0, 0, nil
+			for _, configPath := range configPaths {
+				if !registry.IsConfigActive(configPath) {
+					messages = append(messages, fmt.Sprintf("Config not active: %s", configPath))
+					notActive++
+					continue
+				}
+				registry.RemoveConfigActivation(configPath)
+				messages = append(messages, fmt.Sprintf("Deactivated config: %s", configPath))
+				deactivated++
 			}
-			registry.RemoveConfigActivation(configPath)
-			fmt.Printf("Deactivated config: %s\n", configPath)
-			deactivated++
-		}
-
-		// Save registry
-		if err := config.SaveRegistry(registry); err != nil {
-			fmt.Fprintf(os.Stderr, "Error saving registry: %v\n", err)
-			os.Exit(1)
+		})
+		for _, msg := range messages {
+			fmt.Println(msg)
 		}
 
 		if deactivated > 0 {
@@ -193,28 +197,20 @@ Examples:
 }
 
 func runDeactivateEverything() {
-	// Load registry
-	registry, err := config.LoadRegistry()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading registry: %v\n", err)
-		os.Exit(1)
-	}
-
 	// Track what was deactivated
-	globalWasActive := registry.GlobalActive
-	shellCount := len(registry.ShellActivations)
-	configCount := len(registry.ConfigActivations)
+	var globalWasActive bool
+	var shellCount, configCount int
 
 	// Nuclear option: clear everything
-	registry.GlobalActive = false
-	registry.ClearShellActivations()
-	registry.ClearConfigActivations()
-
-	// Save registry
-	if err := config.SaveRegistry(registry); err != nil {
-		fmt.Fprintf(os.Stderr, "Error saving registry: %v\n", err)
-		os.Exit(1)
-	}
+	updateRegistryOrExit(func(registry *config.Registry) {
+		globalWasActive = registry.GlobalActive
+		shellCount = len(registry.ShellActivations)
+		configCount = len(registry.ConfigActivations)
+
+		registry.GlobalActive = false
+		registry.ClearShellActivations()
+		registry.ClearConfigActivations()
+	})
 
 	// Report what was cleared
 	fmt.Println("Deactivated everything:")
@@ -235,10 +231,34 @@ func runDeactivateEverything() {
 	}
 }
 
+func runDeactivateStale() {
+	var deadShells int
+	var missingConfigs []string
+	updateRegistryOrExit(func(registry *config.Registry) {
+		deadShells = registry.PrunedShellActivations()
+		missingConfigs = registry.PruneMissingConfigActivations()
+	})
+
+	if deadShells == 0 && len(missingConfigs) == 0 {
+		fmt.Println("No stale activations")
+		return
+	}
+	if deadShells > 0 {
+		fmt.Printf("Removed %d activation(s) of exited shells\n", deadShells)
+	}
+	if len(missingConfigs) > 0 {
+		fmt.Printf("Removed %d activation(s) of missing configs:\n", len(missingConfigs))
+		for _, path := range missingConfigs {
+			fmt.Printf("  - %s\n", path)
+		}
+	}
+}
+
 func init() {
 	deactivateCmd.Flags().BoolVar(&deactivateConfig, "config", false, "Deactivate config(s) (default if no scope flag specified)")
 	deactivateCmd.Flags().BoolVar(&deactivateShell, "shell", false, "Deactivate shell activation(s)")
 	deactivateCmd.Flags().BoolVar(&deactivateGlobal, "global", false, "Turn off global mode")
 	deactivateCmd.Flags().BoolVar(&deactivateAll, "all", false, "Deactivate ALL items in the chosen scope")
 	deactivateCmd.Flags().BoolVar(&deactivateEverything, "everything", false, "Nuclear option: deactivate global + all shells + all configs")
+	deactivateCmd.Flags().BoolVar(&deactivateStale, "stale", false, "Only remove activations of exited shells and missing configs")
 }
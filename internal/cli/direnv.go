@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var direnvExport bool
+var direnvWrite bool
+var direnvTags []string
+
+// Markers delimit the ribbin section of an .envrc, so 'ribbin direnv
+// --write' can update it in place
+const (
+	direnvBeginMarker = "# >>> ribbin direnv >>>"
+	direnvEndMarker   = "# <<< ribbin direnv <<<"
+)
+
+var direnvCmd = &cobra.Command{
+	Use:   "direnv",
+	Short: "Activate ribbin per directory through direnv",
+	Long: `Print a snippet for a project's .envrc that activates ribbin while the
+shell is in the project, for projects that already use direnv
+(https://direnv.net) instead of a ribbin shell hook.
+
+The snippet runs 'ribbin direnv --export' each time direnv loads the
+.envrc. That sets RIBBIN_ACTIVATE to the nearest config, which activates
+it for every command started from the shell until direnv unloads it on
+leaving the project. It also puts ribbin's shadow directory first on PATH
+once binaries are wrapped by shadowing ('ribbin wrap --shadow-signed'), and
+has direnv watch the config files, so editing a config, or adding a
+ribbin.local.jsonc, reloads the environment. When the config requires
+commands to be wrapped ("require"), it creates their sentinels and puts
+them on PATH, so running one unwrapped says to run 'ribbin wrap'. The
+snippet itself never needs updating, and does nothing for teammates
+without ribbin.
+
+With --tag, only wrappers with one of the tags are activated
+(RIBBIN_ACTIVATE_TAGS).
+
+With --write, the snippet is added to the .envrc next to the config, or
+updated there if 'ribbin direnv --write' added it before; run 'direnv
+allow' afterwards, as direnv asks whenever the .envrc changes.
+
+Examples:
+  ribbin direnv >> .envrc            # Add the snippet yourself
+  ribbin direnv --write              # Add or update it in the config's .envrc
+  ribbin direnv --write --tag safety # Activate only wrappers tagged "safety"
+  ribbin direnv --export             # Print what direnv loads here`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if direnvExport && direnvWrite {
+			fmt.Fprintf(os.Stderr, "Error: --export and --write cannot be combined\n")
+			os.Exit(1)
+		}
+		if err := validateTagFlags(direnvTags); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		env, err := wrap.Direnv(cwd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if env.ConfigPath == "" {
+			fmt.Fprintln(os.Stderr, errNoConfig)
+			os.Exit(ExitConfigNotFound)
+		}
+
+		block := direnvBlock(direnvTags)
+		switch {
+		case direnvExport:
+			fmt.Print(direnvExports(env, direnvTags))
+		case direnvWrite:
+			envrcPath := filepath.Join(filepath.Dir(env.ConfigPath), ".envrc")
+			updated, err := writeEnvrcBlock(envrcPath, block)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if !updated {
+				fmt.Printf("%s already up to date\n", envrcPath)
+				return
+			}
+			fmt.Printf("Updated %s\n", envrcPath)
+			fmt.Println("Run 'direnv allow' to load it.")
+		default:
+			fmt.Print(block)
+		}
+	},
+}
+
+// direnvBlock returns the marked .envrc section that loads 'ribbin direnv
+// --export', skipped where ribbin isn't installed. has is from direnv's
+// standard library.
+func direnvBlock(tags []string) string {
+	export := "ribbin direnv --export"
+	if len(tags) > 0 {
+		export += " --tag " + strings.Join(tags, ",")
+	}
+	return fmt.Sprintf("%s\nif has ribbin; then\n  eval \"$(%s)\"\nfi\n%s\n",
+		direnvBeginMarker, export, direnvEndMarker)
+}
+
+// direnvExports returns the .envrc code that sets up env, using watch_file
+// and PATH_add from direnv's standard library
+func direnvExports(env *wrap.DirenvEnv, tags []string) string {
+	var b strings.Builder
+	if len(env.Watch) > 0 {
+		quoted := make([]string, len(env.Watch))
+		for i, path := range env.Watch {
+			quoted[i] = wrap.ShellQuote(path)
+		}
+		fmt.Fprintf(&b, "watch_file %s\n", strings.Join(quoted, " "))
+	}
+	// PATH_add puts each directory first, so the shadow directory goes
+	// before the sentinels
+	if env.SentinelDir != "" {
+		fmt.Fprintf(&b, "PATH_add %s\n", wrap.ShellQuote(env.SentinelDir))
+	}
+	if env.ShadowDir != "" {
+		fmt.Fprintf(&b, "PATH_add %s\n", wrap.ShellQuote(env.ShadowDir))
+	}
+	fmt.Fprintf(&b, "export %s=%s\n", wrap.ActivateEnv, wrap.ShellQuote(env.ConfigPath))
+	if len(tags) > 0 {
+		fmt.Fprintf(&b, "export %s=%s\n", wrap.ActivateTagsEnv, wrap.ShellQuote(strings.Join(tags, ",")))
+	} else {
+		fmt.Fprintf(&b, "unset %s\n", wrap.ActivateTagsEnv)
+	}
+	return b.String()
+}
+
+// writeEnvrcBlock adds block to the .envrc at envrcPath, creating it if
+// needed or replacing the block added before. Returns false if the .envrc
+// already contained exactly this block.
+func writeEnvrcBlock(envrcPath, block string) (bool, error) {
+	data, err := os.ReadFile(envrcPath)
+	if os.IsNotExist(err) {
+		return true, os.WriteFile(envrcPath, []byte(block), 0644)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	content := string(data)
+	var updated string
+	if existing, ok := findMarkedBlock(content, direnvBeginMarker, direnvEndMarker); ok {
+		if existing == block {
+			return false, nil
+		}
+		updated = strings.Replace(content, existing, block, 1)
+	} else {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if content != "" {
+			content += "\n"
+		}
+		updated = content + block
+	}
+
+	info, err := os.Stat(envrcPath)
+	if err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(envrcPath, []byte(updated), info.Mode())
+}
+
+func init() {
+	direnvCmd.Flags().BoolVar(&direnvExport, "export", false,
+		"Print the environment for direnv to load here (what the .envrc snippet runs)")
+	direnvCmd.Flags().BoolVar(&direnvWrite, "write", false,
+		"Add the snippet to the .envrc next to the config, or update it there")
+	direnvCmd.Flags().StringSliceVar(&direnvTags, "tag", nil,
+		"Activate only wrappers with these tags (comma-separated or repeated)")
+
+	rootCmd.AddCommand(direnvCmd)
+}
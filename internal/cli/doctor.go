@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var doctorShell string
+var doctorFromStdin bool
+var doctorQuiet bool
+var doctorUnalias bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check shared directories, or your shell, for what undermines ribbin",
+	Long: `Check the system and shared directories you agreed to wrap in, like
+/usr/local/bin, against the listing ribbin recorded when you agreed.
+
+Changes ribbin makes are expected: wrappers in place of the binaries that
+were listed, and the .ribbin-original and .ribbin-meta files next to them.
+Anything else added, removed or changed since is reported, so you can tell
+whether wrapping or unwrapping ever went wrong, or something else changed
+the directory. Installing or upgrading software there shows up too.
+
+Exits non-zero if anything unexpected changed.
+
+With --shell, checks your shell instead, for aliases and functions named
+after wrapped commands. The shell runs those instead of looking the command
+up in PATH, so an alias like npm='pnpm' never reaches ribbin's wrapper. An
+alias running the command itself, like rm='rm -i', still does and is only
+listed. The shell is taken from $SHELL, or given as --shell=bash, zsh, fish,
+or sh, and is started interactively to read its startup files. With
+--from-stdin, the listing is read from stdin instead; the hook printed by
+'ribbin activate --shell --print-hook' uses it to check the live shell.
+
+Exits non-zero if an alias or function keeps a wrapped command from running.
+
+Shell flags:
+  --from-stdin   Read the output of 'alias' and 'declare -F' from stdin
+  --quiet        Print nothing unless a wrapped command is kept from running
+  --unalias      Print the code that removes those aliases and functions
+
+Examples:
+  ribbin doctor                          # Check shared directories
+  ribbin doctor --shell                  # Check aliases from your startup files
+  { alias -p; declare -F; } | ribbin doctor --shell=bash --from-stdin`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if doctorShell != "" {
+			runShellDoctor()
+			return
+		}
+		if doctorFromStdin || doctorQuiet || doctorUnalias {
+			fmt.Fprintf(os.Stderr, "Error: --from-stdin, --quiet and --unalias only apply with --shell\n")
+			os.Exit(1)
+		}
+
+		acks, err := security.LoadAcknowledgedDirs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(acks) == 0 {
+			fmt.Println("No shared directories have been wrapped in")
+			return
+		}
+
+		dirs := make([]string, 0, len(acks))
+		for dir := range acks {
+			dirs = append(dirs, dir)
+		}
+		sort.Strings(dirs)
+
+		problems := 0
+		for _, dir := range dirs {
+			ack := acks[dir]
+			fmt.Printf("%s (listed %s)\n", dir, ack.AcknowledgedAt.Local().Format("2006-01-02 15:04"))
+			listing, err := security.ListDir(dir)
+			if err != nil {
+				fmt.Printf("  ✗ cannot list it: %v\n", err)
+				problems++
+				continue
+			}
+			diff := unexpectedDirChanges(dir, ack.Listing, listing)
+			if diff.Empty() {
+				fmt.Println("  ✓ nothing changed apart from ribbin's wrappers")
+				continue
+			}
+			problems++
+			for _, name := range diff.Added {
+				fmt.Printf("  + %s\n", name)
+			}
+			for _, name := range diff.Removed {
+				fmt.Printf("  - %s\n", name)
+			}
+			for _, name := range diff.Changed {
+				fmt.Printf("  ~ %s\n", name)
+			}
+		}
+
+		if problems > 0 {
+			fmt.Printf("\n%d of %d directories changed in ways ribbin didn't make (+ added, - removed, ~ changed)\n", problems, len(dirs))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	doctorCmd.Flags().StringVar(&doctorShell, "shell", "", "Check the shell for aliases and functions shadowing wrapped commands (bash, zsh, fish, sh; default from $SHELL)")
+	doctorCmd.Flags().Lookup("shell").NoOptDefVal = "auto"
+	doctorCmd.Flags().BoolVar(&doctorFromStdin, "from-stdin", false, "With --shell, read the shell's alias and function listing from stdin")
+	doctorCmd.Flags().BoolVar(&doctorQuiet, "quiet", false, "With --shell, print nothing unless a wrapped command is kept from running")
+	doctorCmd.Flags().BoolVar(&doctorUnalias, "unalias", false, "With --shell, print code removing the aliases and functions keeping wrapped commands from running")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// runShellDoctor reports the aliases and functions of a shell that are
+// named after wrapped commands, for 'ribbin doctor --shell'
+func runShellDoctor() {
+	shell := doctorShell
+	if shell == "auto" {
+		shell = detectHookShell()
+	}
+
+	var defs []shellDefinition
+	if doctorFromStdin {
+		if _, err := shellDefinitionsCommand(shell); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defs = parseShellDefinitions(os.Stdin)
+	} else {
+		var err error
+		if defs, err = listShellDefinitions(shell); err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing the aliases of %s: %v\n", shell, err)
+			os.Exit(1)
+		}
+	}
+
+	registry, err := config.LoadRegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading registry: %v\n", err)
+		os.Exit(ExitCode(err))
+	}
+	shadows := findShellShadows(defs, registry)
+	var bypassing []shellShadow
+	for _, shadow := range shadows {
+		if shadow.Bypasses {
+			bypassing = append(bypassing, shadow)
+		}
+	}
+
+	// The code goes to stdout for the hook to eval; the notes to stderr
+	if doctorUnalias {
+		for _, shadow := range bypassing {
+			fmt.Println(removeShadowCommand(shell, shadow))
+			fmt.Fprintf(os.Stderr, "ribbin: removed %s %s, which kept the wrapped %s from running\n", shadow.Kind, shadow.Name, shadow.Name)
+		}
+		return
+	}
+
+	if len(shadows) == 0 || (doctorQuiet && len(bypassing) == 0) {
+		if !doctorQuiet {
+			fmt.Printf("✓ no aliases or functions in %s shadow wrapped commands\n", shell)
+		}
+		return
+	}
+	fmt.Printf("ribbin: aliases and functions in %s named after wrapped commands:\n", shell)
+	for _, shadow := range shadows {
+		switch {
+		case shadow.Bypasses:
+			fmt.Printf("  ✗ %s\n", describeShadow(shadow))
+		case !doctorQuiet:
+			fmt.Printf("  ✓ %s\n", describeShadow(shadow))
+		}
+	}
+	if len(bypassing) == 0 {
+		return
+	}
+	fmt.Println("To remove them from this shell, run:")
+	for _, shadow := range bypassing {
+		fmt.Printf("  %s\n", removeShadowCommand(shell, shadow))
+	}
+	fmt.Println("and delete them from your shell's startup files, or add --unalias to the")
+	fmt.Println("'ribbin activate --shell --print-hook' line there to have them removed")
+	os.Exit(1)
+}
+
+// unexpectedDirChanges returns how dir changed from the before listing to
+// the after listing, leaving out what wrapping does: ribbin's own files
+// added, and a listed binary replaced by a wrapper whose original is that
+// binary.
+func unexpectedDirChanges(dir string, before, after []security.DirEntryInfo) security.DirListingDiff {
+	full := security.DiffDirListing(before, after)
+	diff := security.DirListingDiff{Removed: full.Removed}
+
+	old := make(map[string]security.DirEntryInfo, len(before))
+	for _, entry := range before {
+		old[entry.Name] = entry
+	}
+	for _, name := range full.Added {
+		if !strings.Contains(name, ".ribbin-") {
+			diff.Added = append(diff.Added, name)
+		}
+	}
+	for _, name := range full.Changed {
+		if !wrapsListedBinary(filepath.Join(dir, name), old[name]) {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	return diff
+}
+
+// wrapsListedBinary reports whether path is now a wrapper whose original is
+// the binary listed as entry. An entry listed as a symlink can't be told
+// apart from a later upgrade, so any wrapper of it counts.
+func wrapsListedBinary(path string, entry security.DirEntryInfo) bool {
+	if wrapped, err := wrap.IsAlreadyShimmed(path); err != nil || !wrapped {
+		return false
+	}
+	if entry.SHA256 == "" {
+		return true
+	}
+	meta, err := wrap.LoadMetadata(path)
+	return err == nil && meta.OriginalHash == "sha256:"+entry.SHA256
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// Exit codes are part of ribbin's interface: scripts can rely on them to
+// tell "nothing to do" apart from a hard failure. Keep them in sync with
+// docs/reference/cli-commands.md#exit-codes.
+const (
+	ExitFailure          = 1 // Any other error
+	ExitConfigNotFound   = 2 // No ribbin config where one was needed
+	ExitNotWrapped       = 3 // A named command, or a target of 'ribbin verify', isn't wrapped
+	ExitSecurityRejected = 4 // A binary failed ribbin's security checks
+	ExitLockTimeout      = 5 // Another ribbin process held a lock too long
+)
+
+var (
+	// ErrConfigNotFound is returned when no config is found, either walking up
+	// from the current directory or at a path given on the command line.
+	ErrConfigNotFound = errors.New("config not found")
+	// ErrNotWrapped is returned when a command named on the command line
+	// isn't in the registry.
+	ErrNotWrapped = errors.New("not wrapped")
+	// ErrSecurityRejected is returned when a binary fails the security checks
+	// ribbin runs before wrapping it.
+	ErrSecurityRejected = errors.New("rejected by security checks")
+	// ErrLockTimeout is returned when the registry or a binary stays locked
+	// by another ribbin process past the timeout.
+	ErrLockTimeout = security.ErrLockTimeout
+)
+
+// ExitCode returns the exit code for an error returned by a command.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrConfigNotFound):
+		return ExitConfigNotFound
+	case errors.Is(err, ErrNotWrapped):
+		return ExitNotWrapped
+	case errors.Is(err, ErrSecurityRejected):
+		return ExitSecurityRejected
+	case errors.Is(err, ErrLockTimeout):
+		return ExitLockTimeout
+	default:
+		return ExitFailure
+	}
+}
+
+// configNotFoundError reports a missing config and matches ErrConfigNotFound.
+// An empty path means no config was found walking up from the current
+// directory.
+type configNotFoundError struct {
+	path string
+}
+
+func (e *configNotFoundError) Error() string {
+	if e.path == "" {
+		return "No ribbin.jsonc found. Run 'ribbin init' to create one."
+	}
+	return "config file not found: " + e.path
+}
+
+func (e *configNotFoundError) Is(target error) bool {
+	return target == ErrConfigNotFound
+}
+
+// errNoConfig is returned when no config is found walking up from the
+// current directory.
+var errNoConfig error = &configNotFoundError{}
+
+// errConfigFileNotFound is returned when a config path given on the command
+// line doesn't exist.
+func errConfigFileNotFound(path string) error {
+	return &configNotFoundError{path: path}
+}
@@ -1,15 +1,27 @@
 package cli
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/happycollision/ribbin/internal/cli/render"
 	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/wrap"
 	"github.com/spf13/cobra"
 )
 
+// discoveredOrphanConfig is the config recorded for orphaned sidecars that
+// 'ribbin find' adds to the registry
+const discoveredOrphanConfig = "(discovered orphan)"
+
 var findAll bool
+var findRestore bool
+var findAdopt string
+var findInteractive bool
 
 var findCmd = &cobra.Command{
 	Use:   "find [directory]",
@@ -19,30 +31,59 @@ var findCmd = &cobra.Command{
 This command searches for:
   - .ribbin-original sidecar files (indicating wrapped binaries)
   - .ribbin-meta metadata files
-  - ribbin.jsonc and ribbin.local.jsonc config files
+  - ribbin.jsonc and ribbin.local.jsonc config files (and .toml/.yaml variants)
 
-By default, searches the current directory and subdirectories.
-You can specify a different directory to search, or use --all to search the entire system.
+By default, searches the current directory and subdirectories, and the
+"searchPaths" of the nearest config and your settings. You can specify a
+different directory to search, or use --all to search the entire system.
 
 This is useful for diagnosing ribbin state and finding orphaned wrappers that
 may have been left behind from interrupted operations or manual file changes.
 
+Orphans (sidecars not in the registry, or only tracked as discovered
+orphans) are added to the registry for tracking. To repair them instead:
+  --restore          Put the original back over the broken wrapper (a binary
+                     reinstalled over the wrapper is kept, and the stale
+                     sidecar removed)
+  --adopt <config>   Re-create the wrapper, pointing at this ribbin, and
+                     register it under the given config
+  --interactive      Choose restore, adopt (into --adopt or the nearest
+                     config) or skip for each orphan
+
 Examples:
   ribbin find                    # Search current directory recursively
   ribbin find /usr/local/bin     # Search specific directory
-  ribbin find --all              # Search entire system (may be slow)`,
+  ribbin find --all              # Search entire system (may be slow)
+  ribbin find --restore ./bin    # Restore originals of orphans in ./bin
+  ribbin find --adopt ribbin.jsonc node_modules/.bin
+  ribbin find -i                 # Decide what to do with each orphan`,
 	RunE: runFind,
 }
 
 func init() {
 	findCmd.Flags().BoolVar(&findAll, "all", false, "Search entire system instead of current directory")
+	findCmd.Flags().BoolVar(&findRestore, "restore", false, "Restore the originals of orphaned wrappers")
+	findCmd.Flags().StringVar(&findAdopt, "adopt", "", "Re-create orphaned wrappers and register them under this config")
+	findCmd.Flags().BoolVarP(&findInteractive, "interactive", "i", false, "Choose restore, adopt or skip for each orphan")
 }
 
 func runFind(cmd *cobra.Command, args []string) error {
 	printGlobalWarningIfActive()
 
-	// Determine search root
+	if findRestore && findAdopt != "" {
+		return fmt.Errorf("--restore and --adopt can't be combined (use --interactive to choose per orphan)")
+	}
+	repairing := findRestore || findAdopt != "" || findInteractive
+
+	// Resolve the adopt config before the (possibly slow) search
+	adoptConfig, err := findAdoptConfig()
+	if err != nil {
+		return err
+	}
+
+	// Determine search roots
 	var searchRoot string
+	var searchPaths []string
 	if findAll {
 		fmt.Println("⚠️  Searching your entire system for ribbin artifacts...")
 		fmt.Println("This may take a while depending on your filesystem size.")
@@ -70,11 +111,25 @@ func runFind(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("failed to get current directory: %w", err)
 		}
-		fmt.Printf("Searching %s for ribbin artifacts...\n\n", searchRoot)
+		configPath, err := config.FindProjectConfig()
+		if err != nil {
+			return fmt.Errorf("failed to find config: %w", err)
+		}
+		if searchPaths, err = config.SearchPaths(configPath); err != nil {
+			return fmt.Errorf("failed to load search paths: %w", err)
+		}
+		fmt.Printf("Searching %s for ribbin artifacts...\n", searchRoot)
+		for _, dir := range searchPaths {
+			fmt.Printf("Searching search path %s...\n", dir)
+		}
+		fmt.Println()
 	}
 
 	// Load registry to compare against
 	registry, err := config.LoadRegistry()
+	if err != nil && repairing {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
 	if err != nil {
 		fmt.Printf("Warning: failed to load registry: %v\n", err)
 		fmt.Println("Continuing with search (registry comparison unavailable)")
@@ -88,9 +143,12 @@ func runFind(cmd *cobra.Command, args []string) error {
 	var configFiles []string
 	var knownSidecars []string
 	var unknownSidecars []string
+	var trackedOrphans []string
 
-	// Walk the directory tree
-	err = filepath.Walk(searchRoot, func(path string, info os.FileInfo, err error) error {
+	// Walk the directory trees, visiting each directory once when one root
+	// contains another
+	visited := make(map[string]bool)
+	walk := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			// Skip directories we can't access
 			if os.IsPermission(err) {
@@ -104,68 +162,244 @@ func runFind(cmd *cobra.Command, args []string) error {
 			return filepath.SkipDir
 		}
 
+		if info.IsDir() {
+			if visited[path] {
+				return filepath.SkipDir
+			}
+			visited[path] = true
+		}
+
 		// Check if it's a ribbin artifact
 		name := info.Name()
 
-		if filepath.Ext(name) == ".ribbin-original" {
+		if !info.IsDir() && wrap.IsSidecar(path) {
 			sidecars = append(sidecars, path)
 
 			// Check if this is tracked in registry
-			originalPath := path[:len(path)-len(".ribbin-original")]
-			isKnown := false
-			for _, entry := range registry.Wrappers {
-				if entry.Original == originalPath {
-					isKnown = true
-					break
-				}
-			}
+			originalPath := wrap.BinaryForSidecar(path)
+			entry, isKnown := registry.Wrapper(originalPath)
+			isTrackedOrphan := isKnown && entry.Config == discoveredOrphanConfig
 
 			if isKnown {
 				knownSidecars = append(knownSidecars, path)
+				if isTrackedOrphan {
+					trackedOrphans = append(trackedOrphans, path)
+				}
 			} else {
 				unknownSidecars = append(unknownSidecars, path)
 			}
 		} else if filepath.Ext(name) == ".ribbin-meta" {
 			metadataFiles = append(metadataFiles, path)
-		} else if name == "ribbin.jsonc" || name == "ribbin.local.jsonc" {
+		} else if config.IsConfigFileName(name) {
 			configFiles = append(configFiles, path)
 		}
 
 		return nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("error during search: %w", err)
+	}
+	for _, root := range append([]string{searchRoot}, searchPaths...) {
+		if root != searchRoot {
+			if _, err := os.Stat(root); err != nil {
+				continue
+			}
+		}
+		if err := filepath.Walk(root, walk); err != nil {
+			return fmt.Errorf("error during search: %w", err)
+		}
 	}
 
-	// Add unknown/orphaned sidecars to the registry so we don't have to search again
-	if len(unknownSidecars) > 0 {
-		for _, sidecar := range unknownSidecars {
-			originalPath := sidecar[:len(sidecar)-len(".ribbin-original")]
-			commandName := filepath.Base(originalPath)
-
-			// Add to registry with empty config to mark as "discovered orphan"
-			registry.Wrappers[commandName] = config.WrapperEntry{
-				Original: originalPath,
-				Config:   "(discovered orphan)", // Mark as discovered, not from a config file
+	// Print results
+	printFindResults(render.Stdout(), registry, sidecars, metadataFiles, configFiles, knownSidecars, unknownSidecars)
+
+	// Repair orphans if asked to. Orphans left alone are tracked below.
+	registryBefore := registry.CloneWrappers()
+	untracked := unknownSidecars
+	if repairing {
+		orphans := append(append([]string{}, unknownSidecars...), trackedOrphans...)
+		skipped, err := repairOrphans(orphans, adoptConfig, registry)
+		if err != nil {
+			return err
+		}
+		untracked = nil
+		for _, sidecar := range skipped {
+			for _, unknown := range unknownSidecars {
+				if sidecar == unknown {
+					untracked = append(untracked, sidecar)
+				}
 			}
 		}
+	}
+
+	// Save repairs, and add remaining orphaned sidecars to the registry so
+	// we don't have to search again
+	if repairing || len(untracked) > 0 {
+		err := config.UpdateRegistry(func(latest *config.Registry) error {
+			latest.MergeWrapperChanges(registryBefore, registry.Wrappers)
+			for _, sidecar := range untracked {
+				originalPath := wrap.BinaryForSidecar(sidecar)
+
+				// Don't clobber an entry another process registered while we searched
+				if _, exists := latest.Wrapper(originalPath); exists {
+					continue
+				}
 
-		// Save the updated registry
-		if err := config.SaveRegistry(registry); err != nil {
+				// Add to registry with a placeholder config to mark as "discovered orphan"
+				latest.AddWrapper(config.WrapperEntry{
+					Original: originalPath,
+					Config:   discoveredOrphanConfig, // Mark as discovered, not from a config file
+				})
+			}
+			return nil
+		})
+		if err != nil {
 			fmt.Printf("Warning: failed to save registry: %v\n", err)
-		} else {
-			fmt.Printf("\nAdded %d orphaned sidecar(s) to registry for tracking.\n", len(unknownSidecars))
+		} else if len(untracked) > 0 {
+			fmt.Printf("Added %d orphaned sidecar(s) to registry for tracking.\n", len(untracked))
 		}
 	}
 
-	// Print results
-	printFindResults(sidecars, metadataFiles, configFiles, knownSidecars, unknownSidecars)
-
 	return nil
 }
 
-// searchForSidecars walks a directory tree and finds all .ribbin-original files
+// findAdoptConfig returns the absolute path of the --adopt config, or with
+// --interactive alone the nearest config (empty if there is none).
+func findAdoptConfig() (string, error) {
+	if findAdopt == "" {
+		if !findInteractive {
+			return "", nil
+		}
+		return config.FindProjectConfig()
+	}
+	configPath, err := filepath.Abs(findAdopt)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", findAdopt, err)
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		return "", errConfigFileNotFound(configPath)
+	}
+	if _, err := config.LoadProjectConfig(configPath); err != nil {
+		return "", fmt.Errorf("failed to load config %s: %w", configPath, err)
+	}
+	return configPath, nil
+}
+
+// repairOrphans restores or adopts each orphaned sidecar according to
+// --restore, --adopt and --interactive, recording the changes in registry.
+// Returns the sidecars that were skipped or couldn't be repaired.
+func repairOrphans(sidecars []string, adoptConfig string, registry *config.Registry) ([]string, error) {
+	if len(sidecars) == 0 {
+		return nil, nil
+	}
+
+	var ribbinPath string
+	if adoptConfig != "" {
+		execPath, err := os.Executable()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get executable path: %w", err)
+		}
+		if ribbinPath, err = filepath.EvalSymlinks(execPath); err != nil {
+			return nil, fmt.Errorf("failed to resolve executable path: %w", err)
+		}
+	}
+
+	fmt.Println("Repairing orphans")
+	fmt.Println("=================")
+	fmt.Println()
+
+	reader := bufio.NewReader(os.Stdin)
+	var skipped []string
+	var restored, adopted, failed int
+	for _, sidecar := range sidecars {
+		binaryPath := wrap.BinaryForSidecar(sidecar)
+
+		choice := "r"
+		if findAdopt != "" {
+			choice = "a"
+		}
+		if findInteractive {
+			choice = pickOrphanRepair(binaryPath, adoptConfig, reader)
+		}
+
+		switch choice {
+		case "r":
+			outcome, err := wrap.ForceUnwrap(binaryPath, registry)
+			if err != nil {
+				fmt.Printf("  Failed to restore %s: %v\n", binaryPath, err)
+				skipped = append(skipped, sidecar)
+				failed++
+				continue
+			}
+			fmt.Printf("  %s: %s\n", binaryPath, outcome)
+			restored++
+		case "a":
+			if err := wrap.Adopt(binaryPath, ribbinPath, adoptConfig, registry); err != nil {
+				fmt.Printf("  Failed to adopt %s: %v\n", binaryPath, err)
+				skipped = append(skipped, sidecar)
+				failed++
+				continue
+			}
+			fmt.Printf("  %s: adopted into %s\n", binaryPath, adoptConfig)
+			if note := adoptConfigNote(adoptConfig, filepath.Base(binaryPath)); note != "" {
+				fmt.Printf("    %s\n", note)
+			}
+			adopted++
+		default:
+			fmt.Printf("  %s: skipped\n", binaryPath)
+			skipped = append(skipped, sidecar)
+		}
+	}
+
+	fmt.Printf("\nRepaired: %d restored, %d adopted, %d skipped, %d failed\n",
+		restored, adopted, len(skipped)-failed, failed)
+	return skipped, nil
+}
+
+// pickOrphanRepair asks what to do with the orphaned wrapper at binaryPath.
+// Returns "r" (restore), "a" (adopt into adoptConfig, offered only if set)
+// or "s" (skip).
+func pickOrphanRepair(binaryPath, adoptConfig string, reader *bufio.Reader) string {
+	fmt.Printf("Orphaned wrapper %s\n", binaryPath)
+	fmt.Println("  [r] restore the original")
+	if adoptConfig != "" {
+		fmt.Printf("  [a] adopt into %s\n", adoptConfig)
+	}
+	fmt.Println("  [s] skip (keep tracking it)")
+	fmt.Print("Choose: ")
+
+	input, err := reader.ReadString('\n')
+	if err != nil && input == "" {
+		fmt.Println()
+		return "s"
+	}
+	switch choice := strings.ToLower(strings.TrimSpace(input)); choice {
+	case "r", "restore":
+		return "r"
+	case "a", "adopt":
+		if adoptConfig != "" {
+			return "a"
+		}
+	}
+	return "s"
+}
+
+// adoptConfigNote warns when configPath defines no wrapper for command, in
+// which case the adopted wrapper just passes through.
+func adoptConfigNote(configPath, command string) string {
+	projectConfig, err := config.LoadProjectConfig(configPath)
+	if err != nil {
+		return ""
+	}
+	if _, ok := projectConfig.Wrappers[command]; ok {
+		return ""
+	}
+	for _, scope := range projectConfig.Scopes {
+		if _, ok := scope.Wrappers[command]; ok {
+			return ""
+		}
+	}
+	return fmt.Sprintf("Note: %s defines no wrapper for '%s', so it will pass through", filepath.Base(configPath), command)
+}
+
+// searchForSidecars walks a directory tree and finds all sidecars, in either layout
 func searchForSidecars(searchRoot string) ([]string, error) {
 	var sidecars []string
 
@@ -184,7 +418,7 @@ func searchForSidecars(searchRoot string) ([]string, error) {
 		}
 
 		// Check if it's a ribbin sidecar
-		if filepath.Ext(info.Name()) == ".ribbin-original" {
+		if !info.IsDir() && wrap.IsSidecar(path) {
 			sidecars = append(sidecars, path)
 		}
 
@@ -194,55 +428,78 @@ func searchForSidecars(searchRoot string) ([]string, error) {
 	return sidecars, err
 }
 
-func printFindResults(sidecars, metadataFiles, configFiles, knownSidecars, unknownSidecars []string) {
-	fmt.Println("Search Results")
-	fmt.Println("==============")
-	fmt.Println()
+// printFindResults lists what the search found, with the known wrapped
+// binaries grouped by the config the registry has them wrapped for
+func printFindResults(r *render.Renderer, registry *config.Registry, sidecars, metadataFiles, configFiles, knownSidecars, unknownSidecars []string) {
+	r.Heading("Search Results")
+	r.Println("==============")
+	r.Println()
 
 	if len(sidecars) == 0 && len(metadataFiles) == 0 && len(configFiles) == 0 {
-		fmt.Println("No ribbin artifacts found.")
+		r.Println("No ribbin artifacts found.")
 		return
 	}
 
 	if len(configFiles) > 0 {
-		fmt.Println("Config Files:")
+		r.Heading("Config Files:")
 		for _, path := range configFiles {
-			fmt.Printf("  %s\n", path)
+			r.Printf("  %s\n", r.Paint(render.Cyan, path))
 		}
-		fmt.Println()
+		r.Println()
 	}
 
 	if len(knownSidecars) > 0 {
-		fmt.Println("✓ Known Wrapped Binaries (tracked in registry):")
+		r.Println(r.Paint(render.Green, "✓ Known Wrapped Binaries (tracked in registry):"))
+		table := r.Table(2, "CONFIG", "BINARY")
+		table.TrimLeft = true
+		byConfig := make(map[string][]string)
+		var configPaths []string
 		for _, path := range knownSidecars {
-			originalPath := path[:len(path)-len(".ribbin-original")]
-			fmt.Printf("  %s\n", originalPath)
+			originalPath := wrap.BinaryForSidecar(path)
+			entry, _ := registry.Wrapper(originalPath)
+			if _, seen := byConfig[entry.Config]; !seen {
+				configPaths = append(configPaths, entry.Config)
+			}
+			byConfig[entry.Config] = append(byConfig[entry.Config], originalPath)
 		}
-		fmt.Println()
+		sort.Strings(configPaths)
+		for _, configPath := range configPaths {
+			label := render.Cell{Text: configPath, Style: render.Cyan}
+			if configPath == discoveredOrphanConfig {
+				label.Style = render.Yellow
+			}
+			for _, originalPath := range byConfig[configPath] {
+				table.StyledRow(label, render.Cell{Text: originalPath})
+				label = render.Cell{}
+			}
+		}
+		table.Flush()
+		r.Println()
 	}
 
 	if len(unknownSidecars) > 0 {
-		fmt.Println("⚠️  Unknown/Orphaned Wrapped Binaries (NOT in registry):")
+		r.Println(r.Paint(render.Yellow, "⚠️  Unknown/Orphaned Wrapped Binaries (NOT in registry):"))
 		for _, path := range unknownSidecars {
-			originalPath := path[:len(path)-len(".ribbin-original")]
-			fmt.Printf("  %s\n", originalPath)
+			originalPath := wrap.BinaryForSidecar(path)
+			r.Printf("  %s\n", originalPath)
 		}
-		fmt.Println()
-		fmt.Println("These sidecars may be orphaned from interrupted operations.")
-		fmt.Println("To clean them up, run:")
-		fmt.Println("  ribbin unwrap --all --find")
-		fmt.Println()
+		r.Println()
+		r.Println("These sidecars may be orphaned from interrupted operations.")
+		r.Println("To restore the originals, or keep them wrapped under a config, run:")
+		r.Println("  ribbin find --restore [directory]")
+		r.Println("  ribbin find --adopt <config> [directory]")
+		r.Println()
 	}
 
 	if len(metadataFiles) > 0 {
-		fmt.Println("Metadata Files:")
+		r.Heading("Metadata Files:")
 		for _, path := range metadataFiles {
-			fmt.Printf("  %s\n", path)
+			r.Printf("  %s\n", r.Paint(render.Dim, path))
 		}
-		fmt.Println()
+		r.Println()
 	}
 
 	// Summary
-	fmt.Printf("Summary: %d config(s), %d wrapped binary(ies) (%d known, %d orphaned), %d metadata file(s)\n",
+	r.Printf("Summary: %d config(s), %d wrapped binary(ies) (%d known, %d orphaned), %d metadata file(s)\n",
 		len(configFiles), len(sidecars), len(knownSidecars), len(unknownSidecars), len(metadataFiles))
 }
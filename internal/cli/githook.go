@@ -0,0 +1,285 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var githookRewrap bool
+
+// Markers delimit the ribbin section of a hook so it can coexist with other
+// hook content and be updated or removed without touching the rest.
+const (
+	githookBeginMarker = "# >>> ribbin githook >>>"
+	githookEndMarker   = "# <<< ribbin githook <<<"
+)
+
+// githookCommands maps each hook ribbin can install to the commands it runs.
+// Every block is skipped when ribbin isn't on PATH, so teammates without
+// ribbin can still commit.
+var githookCommands = map[string]string{
+	// Refuse to commit a config that doesn't validate
+	"pre-commit": "ribbin config validate || exit 1",
+	// Refuse to push a config whose wrappers couldn't be installed
+	"pre-push": "ribbin wrap --dry-run || exit 1",
+	// Package manager installs replace node_modules/.bin entries; put the
+	// wrappers back. Git ignores the exit status of these hooks.
+	"post-checkout": "ribbin heal && ribbin wrap --keep-going",
+	"post-merge":    "ribbin heal && ribbin wrap --keep-going",
+}
+
+// Hook sets installed by 'ribbin githook install'
+var (
+	githookCheckHooks  = []string{"pre-commit", "pre-push"}
+	githookRewrapHooks = []string{"post-checkout", "post-merge"}
+)
+
+var githookCmd = &cobra.Command{
+	Use:   "githook",
+	Short: "Manage git hooks that keep ribbin config and wrappers healthy",
+	Long: `Manage git hooks that keep ribbin config and wrappers healthy.
+
+Subcommands:
+  install    Add ribbin checks to this repository's git hooks
+  uninstall  Remove ribbin checks from this repository's git hooks`,
+}
+
+var githookInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Add ribbin checks to this repository's git hooks",
+	Long: `Add ribbin checks to this repository's git hooks.
+
+Installs:
+  pre-commit     ribbin config validate
+  pre-push       ribbin wrap --dry-run
+
+With --rewrap, also installs post-checkout and post-merge hooks that run
+'ribbin heal' and 'ribbin wrap --keep-going', because package manager
+installs after a checkout or pull frequently replace wrapped
+node_modules/.bin entries.
+
+Existing hooks are kept: ribbin adds a marked section to them, and running
+install again updates that section in place. The hooks skip themselves when
+ribbin isn't installed. Honors core.hooksPath.
+
+Examples:
+  ribbin githook install            # Validate on commit, dry-run wrap on push
+  ribbin githook install --rewrap   # Also re-wrap after checkout and pull`,
+	Run: func(cmd *cobra.Command, args []string) {
+		hooksDir, err := gitHooksDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.MkdirAll(hooksDir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating hooks directory: %v\n", err)
+			os.Exit(1)
+		}
+
+		hooks := githookCheckHooks
+		if githookRewrap {
+			hooks = append(append([]string{}, githookCheckHooks...), githookRewrapHooks...)
+		}
+
+		failed := false
+		for _, hook := range hooks {
+			hookPath := filepath.Join(hooksDir, hook)
+			updated, err := installHookBlock(hookPath, githookBlock(hook))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to install %s hook: %v\n", hook, err)
+				failed = true
+				continue
+			}
+			if updated {
+				fmt.Printf("Installed %s hook: %s\n", hook, hookPath)
+			} else {
+				fmt.Printf("%s hook already up to date\n", hook)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var githookUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove ribbin checks from this repository's git hooks",
+	Long: `Remove ribbin checks from this repository's git hooks.
+
+Only the section added by 'ribbin githook install' is removed. A hook file
+that contained nothing else is deleted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		hooksDir, err := gitHooksDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		removed := 0
+		for _, hook := range append(append([]string{}, githookCheckHooks...), githookRewrapHooks...) {
+			hookPath := filepath.Join(hooksDir, hook)
+			ok, err := removeHookBlock(hookPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to update %s hook: %v\n", hook, err)
+				os.Exit(1)
+			}
+			if ok {
+				fmt.Printf("Removed ribbin from %s hook\n", hook)
+				removed++
+			}
+		}
+		if removed == 0 {
+			fmt.Println("No ribbin git hooks installed")
+		}
+	},
+}
+
+// gitHooksDir returns the absolute path of the current repository's hooks
+// directory, honoring core.hooksPath.
+func gitHooksDir() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return "", fmt.Errorf("not inside a git repository")
+	}
+	return filepath.Abs(strings.TrimSpace(string(out)))
+}
+
+// githookBlock returns the marked section ribbin adds to a hook.
+func githookBlock(hook string) string {
+	return fmt.Sprintf("%s\nif command -v ribbin >/dev/null 2>&1; then\n  %s\nfi\n%s\n",
+		githookBeginMarker, githookCommands[hook], githookEndMarker)
+}
+
+// installHookBlock adds block to the hook at hookPath, creating the hook if
+// needed or replacing a previously installed block. Returns false if the hook
+// already contained exactly this block.
+func installHookBlock(hookPath, block string) (bool, error) {
+	data, err := os.ReadFile(hookPath)
+	if os.IsNotExist(err) {
+		return true, os.WriteFile(hookPath, []byte("#!/bin/sh\n\n"+block), 0755)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	content := string(data)
+	if !isShellHook(content) {
+		return false, fmt.Errorf("%s is not a shell script; add this to it manually:\n%s", hookPath, block)
+	}
+
+	var updated string
+	if existing, ok := findHookBlock(content); ok {
+		if existing == block {
+			return false, nil
+		}
+		updated = strings.Replace(content, existing, block, 1)
+	} else {
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		updated = content + "\n" + block
+	}
+
+	info, err := os.Stat(hookPath)
+	if err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(hookPath, []byte(updated), info.Mode()); err != nil {
+		return false, err
+	}
+	// Make sure the hook is executable, or git silently ignores it
+	return true, os.Chmod(hookPath, info.Mode()|0111)
+}
+
+// removeHookBlock removes ribbin's block from the hook at hookPath, deleting
+// the hook if nothing but the shebang is left. Returns false if the hook
+// doesn't exist or has no ribbin block.
+func removeHookBlock(hookPath string) (bool, error) {
+	data, err := os.ReadFile(hookPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	block, ok := findHookBlock(string(data))
+	if !ok {
+		return false, nil
+	}
+
+	remaining := strings.Replace(string(data), block, "", 1)
+	if isEmptyHook(remaining) {
+		return true, os.Remove(hookPath)
+	}
+	remaining = strings.TrimRight(remaining, "\n") + "\n"
+	return true, os.WriteFile(hookPath, []byte(remaining), 0755)
+}
+
+// findHookBlock returns ribbin's marked block within hook content, including
+// the markers and trailing newline.
+func findHookBlock(content string) (string, bool) {
+	return findMarkedBlock(content, githookBeginMarker, githookEndMarker)
+}
+
+// findMarkedBlock returns the block between the begin and end markers
+// within content, including the markers and trailing newline.
+func findMarkedBlock(content, beginMarker, endMarker string) (string, bool) {
+	start := strings.Index(content, beginMarker)
+	if start == -1 {
+		return "", false
+	}
+	end := strings.Index(content[start:], endMarker)
+	if end == -1 {
+		return "", false
+	}
+	end += start + len(endMarker)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return content[start:end], true
+}
+
+// isShellHook reports whether hook content is a POSIX shell script that ribbin
+// can safely append to. Files without a shebang are run by git with sh.
+func isShellHook(content string) bool {
+	firstLine, _, _ := strings.Cut(content, "\n")
+	if !strings.HasPrefix(firstLine, "#!") {
+		return true
+	}
+	for _, shell := range []string{"sh", "bash", "zsh", "dash"} {
+		if strings.HasSuffix(firstLine, "/"+shell) || strings.HasSuffix(firstLine, " "+shell) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyHook reports whether hook content has nothing left but a shebang,
+// blank lines and comments.
+func isEmptyHook(content string) bool {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	githookInstallCmd.Flags().BoolVar(&githookRewrap, "rewrap", false,
+		"Also re-wrap binaries after checkout and pull (post-checkout and post-merge hooks)")
+
+	githookCmd.AddCommand(githookInstallCmd)
+	githookCmd.AddCommand(githookUninstallCmd)
+	rootCmd.AddCommand(githookCmd)
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestInstallHookBlock(t *testing.T) {
+	block := githookBlock("pre-commit")
+
+	t.Run("creates a new executable hook", func(t *testing.T) {
+		hookPath := filepath.Join(t.TempDir(), "pre-commit")
+
+		updated, err := installHookBlock(hookPath, block)
+		if err != nil || !updated {
+			t.Fatalf("installHookBlock() = %v, %v; want true, nil", updated, err)
+		}
+
+		data, _ := os.ReadFile(hookPath)
+		if !strings.HasPrefix(string(data), "#!/bin/sh\n") || !strings.Contains(string(data), "ribbin config validate") {
+			t.Errorf("unexpected hook content:\n%s", data)
+		}
+		info, _ := os.Stat(hookPath)
+		if info.Mode()&0111 == 0 {
+			t.Error("hook should be executable")
+		}
+	})
+
+	t.Run("appends to an existing hook and is idempotent", func(t *testing.T) {
+		hookPath := filepath.Join(t.TempDir(), "pre-commit")
+		existing := "#!/usr/bin/env bash\nnpx lint-staged"
+		if err := os.WriteFile(hookPath, []byte(existing), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := installHookBlock(hookPath, block); err != nil {
+			t.Fatalf("installHookBlock() error = %v", err)
+		}
+		updated, err := installHookBlock(hookPath, block)
+		if err != nil || updated {
+			t.Errorf("second install = %v, %v; want false, nil", updated, err)
+		}
+
+		data, _ := os.ReadFile(hookPath)
+		if !strings.HasPrefix(string(data), existing+"\n") {
+			t.Errorf("existing hook content should be kept:\n%s", data)
+		}
+		if strings.Count(string(data), githookBeginMarker) != 1 {
+			t.Errorf("block should appear once:\n%s", data)
+		}
+		info, _ := os.Stat(hookPath)
+		if info.Mode()&0111 == 0 {
+			t.Error("hook should be made executable")
+		}
+	})
+
+	t.Run("replaces an outdated block", func(t *testing.T) {
+		hookPath := filepath.Join(t.TempDir(), "pre-commit")
+		old := "#!/bin/sh\n" + githookBeginMarker + "\nold command\n" + githookEndMarker + "\necho after\n"
+		if err := os.WriteFile(hookPath, []byte(old), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := installHookBlock(hookPath, block); err != nil {
+			t.Fatalf("installHookBlock() error = %v", err)
+		}
+		data, _ := os.ReadFile(hookPath)
+		if strings.Contains(string(data), "old command") || !strings.Contains(string(data), "echo after") {
+			t.Errorf("block should be replaced in place:\n%s", data)
+		}
+	})
+
+	t.Run("refuses non-shell hooks", func(t *testing.T) {
+		hookPath := filepath.Join(t.TempDir(), "pre-commit")
+		if err := os.WriteFile(hookPath, []byte("#!/usr/bin/env node\nconsole.log('hi')\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := installHookBlock(hookPath, block); err == nil {
+			t.Error("expected error for a node hook")
+		}
+	})
+}
+
+func TestRemoveHookBlock(t *testing.T) {
+	t.Run("deletes a hook ribbin created", func(t *testing.T) {
+		hookPath := filepath.Join(t.TempDir(), "pre-push")
+		if _, err := installHookBlock(hookPath, githookBlock("pre-push")); err != nil {
+			t.Fatal(err)
+		}
+
+		removed, err := removeHookBlock(hookPath)
+		if err != nil || !removed {
+			t.Fatalf("removeHookBlock() = %v, %v; want true, nil", removed, err)
+		}
+		if _, err := os.Stat(hookPath); !os.IsNotExist(err) {
+			t.Error("hook with nothing else in it should be deleted")
+		}
+	})
+
+	t.Run("keeps other hook content", func(t *testing.T) {
+		hookPath := filepath.Join(t.TempDir(), "pre-push")
+		if err := os.WriteFile(hookPath, []byte("#!/bin/sh\nmake test\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := installHookBlock(hookPath, githookBlock("pre-push")); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := removeHookBlock(hookPath); err != nil {
+			t.Fatalf("removeHookBlock() error = %v", err)
+		}
+		data, _ := os.ReadFile(hookPath)
+		if string(data) != "#!/bin/sh\nmake test\n" {
+			t.Errorf("hook should be restored, got:\n%s", data)
+		}
+	})
+
+	t.Run("missing hook is not an error", func(t *testing.T) {
+		removed, err := removeHookBlock(filepath.Join(t.TempDir(), "pre-push"))
+		if err != nil || removed {
+			t.Errorf("removeHookBlock() = %v, %v; want false, nil", removed, err)
+		}
+	})
+}
+
+func TestGitHooksDir(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	_, tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if _, err := gitHooksDir(); err == nil {
+		t.Error("expected error outside a git repository")
+	}
+
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, out)
+	}
+	hooksDir, err := gitHooksDir()
+	if err != nil {
+		t.Fatalf("gitHooksDir() error = %v", err)
+	}
+	want, _ := filepath.EvalSymlinks(filepath.Join(tempDir, ".git", "hooks"))
+	got, _ := filepath.EvalSymlinks(hooksDir)
+	if got != want {
+		t.Errorf("gitHooksDir() = %q, want %q", hooksDir, want)
+	}
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var healDryRun bool
+var healDiscard bool
+
+var healCmd = &cobra.Command{
+	Use:   "heal [commands...]",
+	Short: "Repair wrappers broken by package manager upgrades",
+	Long: `Repair wrappers broken by package manager upgrades.
+
+When brew, apt, npm or another package manager upgrades a wrapped command,
+it usually does one of two things:
+
+  - Replaces the wrapper symlink with the new binary. The command silently
+    stops being wrapped, and the old .ribbin-original sidecar is left behind.
+  - Rewrites the file the sidecar points to. The wrapper still works, but
+    its metadata no longer matches the original (see 'ribbin unwrap').
+
+'ribbin heal' checks every wrapper in the registry (or just the named
+commands), re-wraps freshly installed binaries, and refreshes metadata.
+Stale sidecars are archived as <command>.ribbin-stale, or deleted with
+--discard.
+
+Set RIBBIN_AUTO_HEAL=1 to have wrappers refresh their own metadata when
+they notice the original changed. Replaced wrappers can't notice anything,
+since ribbin no longer runs, so run 'ribbin heal' after upgrades.
+
+Examples:
+  ribbin heal                  # Heal every wrapper in the registry
+  ribbin heal tsc node         # Heal specific commands
+  ribbin heal --dry-run        # Show what would be healed
+  ribbin heal --discard        # Delete stale sidecars instead of archiving`,
+	Run: func(cmd *cobra.Command, args []string) {
+		printGlobalWarningIfActive()
+
+		registry, err := config.LoadRegistry()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading registry: %v\n", err)
+			os.Exit(ExitCode(err))
+		}
+		registryBefore := registry.CloneWrappers()
+
+		execPath, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting executable path: %v\n", err)
+			os.Exit(1)
+		}
+		ribbinPath, err := filepath.EvalSymlinks(execPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error resolving executable path: %v\n", err)
+			os.Exit(1)
+		}
+
+		paths, err := healTargets(registry, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(ExitCode(err))
+		}
+		if len(paths) == 0 {
+			fmt.Println("No wrappers in the registry")
+			return
+		}
+
+		var healed, healthy, failed int
+		for _, path := range paths {
+			entry := registry.Wrappers[path]
+			name := filepath.Base(path)
+
+			if healDryRun {
+				check := wrap.CheckHeal(entry.Original, ribbinPath)
+				if check.Status == wrap.HealNotNeeded {
+					healthy++
+					continue
+				}
+				fmt.Printf("Would heal %s (%s): %s\n", name, entry.Original, check.Status)
+				continue
+			}
+
+			check, err := wrap.Heal(entry.Original, ribbinPath, registry, entry.Config, healDiscard)
+			switch {
+			case err != nil:
+				fmt.Printf("Failed to heal %s (%s): %v\n", name, entry.Original, err)
+				failed++
+			case check.Status == wrap.HealNotNeeded:
+				healthy++
+			case check.Status == wrap.HealMissing:
+				fmt.Printf("Skipping %s: %s no longer exists (run 'ribbin unwrap --all' to clean up)\n", name, entry.Original)
+				failed++
+			case check.Status == wrap.HealRibbinMoved:
+				fmt.Printf("Skipping %s: the ribbin binary it points at was moved (run 'ribbin relink')\n", name)
+				failed++
+			default:
+				fmt.Printf("Healed %s (%s): %s\n", name, entry.Original, check.Status)
+				healed++
+			}
+		}
+
+		if healDryRun {
+			fmt.Printf("\n%d healthy, %d would be healed\n", healthy, len(paths)-healthy)
+			return
+		}
+
+		updateRegistryOrExit(func(latest *config.Registry) {
+			latest.MergeWrapperChanges(registryBefore, registry.Wrappers)
+		})
+
+		fmt.Printf("\n%d healed, %d healthy, %d failed\n", healed, healthy, failed)
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// healTargets returns the paths of the registry wrappers to heal, sorted.
+// With no args every wrapper is a target. Each arg is a command name, which
+// selects every wrapped binary with that name, or the path of a wrapped
+// binary; either must be in the registry.
+func healTargets(registry *config.Registry, args []string) ([]string, error) {
+	if len(args) == 0 {
+		return registry.WrapperPaths(), nil
+	}
+
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		var paths []string
+		if entry, ok := registry.Wrapper(arg); ok && strings.ContainsRune(arg, filepath.Separator) {
+			paths = []string{entry.Original}
+		} else {
+			for _, entry := range registry.WrappersNamed(arg) {
+				paths = append(paths, entry.Original)
+			}
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("%s is %w (see 'ribbin status')", arg, ErrNotWrapped)
+		}
+		for _, path := range paths {
+			seen[path] = true
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func init() {
+	healCmd.Flags().BoolVar(&healDryRun, "dry-run", false, "Show what would be healed without making changes")
+	healCmd.Flags().BoolVar(&healDiscard, "discard", false, "Delete stale sidecars instead of archiving them as .ribbin-stale")
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var ideCmd = &cobra.Command{
+	Use:   "ide [path]",
+	Short: "Print the wrappers in effect for a file as JSON, for editors",
+	Long: `Print, as a single JSON object, what ribbin does with commands run for a
+file or directory (the current directory by default): the governing config
+and scope, and each wrapped command's action, message and redirect, whether
+it is wrapped on this machine, and whether ribbin is active for it there.
+
+Editor plugins run it for the file being edited to warn about a blocked
+command, say in a package.json script, before it is ever run in a terminal.
+The path doesn't need to exist. The output is a stable format, described in
+docs/reference/editor-integration.md: "format_version" only changes when a
+field is removed or changes meaning, and new fields may appear at any time.
+
+Shell activations count only when 'ribbin ide' runs in an activated shell,
+as an editor usually doesn't; global and config activations always count.
+
+Exits non-zero, with the error on stderr, if a config can't be read.
+
+Examples:
+  ribbin ide                        # Wrappers for the current directory
+  ribbin ide src/app/package.json   # Wrappers for commands run for a file`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "."
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		registry, err := config.LoadRegistry()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading registry: %v\n", err)
+			os.Exit(ExitCode(err))
+		}
+		report, err := wrap.DescribeWorkspace(path, registry)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(ideCmd)
+}
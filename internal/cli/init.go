@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,6 +14,11 @@ var initCmd = &cobra.Command{
 	Short: "Initialize ribbin in the current directory",
 	Long: `Create a ribbin.jsonc configuration file in the current directory.
 
+ribbin init looks for package.json, lockfiles, go.mod, Cargo.toml and
+.tool-versions to work out what kind of project this is, then offers
+starter wrappers (e.g. blocking npm and yarn in a pnpm repository). Each
+suggestion is confirmed interactively unless --yes is given.
+
 The generated file contains commented examples showing how to configure
 wrappers for common use cases.
 
@@ -31,10 +37,18 @@ to install them.
 Example:
   ribbin init
   # Edit ribbin.jsonc to configure your wrappers
-  ribbin wrap`,
+  ribbin wrap
+
+  ribbin init --yes --wrap   # Accept all suggestions and install them`,
 	RunE: runInit,
 }
 
+var (
+	initForce bool
+	initYes   bool
+	initWrap  bool
+)
+
 // LatestSchemaVersion is the current schema version used by ribbin init.
 // Update this when releasing a new schema version.
 const LatestSchemaVersion = "v1"
@@ -43,7 +57,8 @@ const LatestSchemaVersion = "v1"
 // Update the version in the path when releasing a new schema version.
 const LatestSchemaURL = "https://github.com/happycollision/ribbin/schemas/" + LatestSchemaVersion + "/ribbin.schema.json"
 
-const defaultConfig = `{
+// initConfigHeader is the opening of every generated ribbin.jsonc, up to the wrappers block.
+const initConfigHeader = `{
   "$schema": "` + LatestSchemaURL + `",
 
   // ribbin - Intercept commands and redirect to project-approved alternatives
@@ -57,13 +72,10 @@ const defaultConfig = `{
   //
   // Run 'ribbin config --example' to see a comprehensive example config.
 
-  "wrappers": {
-    // "npm": {
-    //   "action": "block",
-    //   "message": "This project uses pnpm"
-    // }
-  }
+`
 
+// initConfigFooter closes every generated ribbin.jsonc, after the wrappers block.
+const initConfigFooter = `
   // Scopes: Apply different rules to different directories.
   // Great for monorepos, or defining config outside the project entirely.
   //   Monorepo guide:     https://github.com/happycollision/ribbin/blob/main/docs/how-to/monorepo-scopes.md
@@ -72,6 +84,14 @@ const defaultConfig = `{
 }
 `
 
+const defaultConfig = initConfigHeader + `  "wrappers": {
+    // "npm": {
+    //   "action": "block",
+    //   "message": "This project uses pnpm"
+    // }
+  }
+` + initConfigFooter
+
 func runInit(cmd *cobra.Command, args []string) error {
 	printGlobalWarningIfActive()
 
@@ -83,18 +103,63 @@ func runInit(cmd *cobra.Command, args []string) error {
 	configPath := filepath.Join(cwd, "ribbin.jsonc")
 
 	// Check if file already exists
-	if _, err := os.Stat(configPath); err == nil {
-		return fmt.Errorf("ribbin.jsonc already exists in %s", cwd)
+	if _, err := os.Stat(configPath); err == nil && !initForce {
+		return fmt.Errorf("ribbin.jsonc already exists in %s (use --force to overwrite)", cwd)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+
+	// Detect the project type and offer starter wrappers
+	info := detectProject(cwd)
+	if found := info.describe(); len(found) > 0 {
+		fmt.Println("Detected:")
+		for _, f := range found {
+			fmt.Printf("  - %s\n", f)
+		}
+		fmt.Println()
 	}
 
-	// Write the default config
-	if err := os.WriteFile(configPath, []byte(defaultConfig), 0644); err != nil {
+	var accepted []wrapperSuggestion
+	for _, s := range suggestWrappers(info) {
+		if initYes || promptYesNo(reader, fmt.Sprintf("Block '%s'? (%s)", s.Command, s.Reason), true) {
+			accepted = append(accepted, s)
+		}
+	}
+
+	// Write the config
+	if err := os.WriteFile(configPath, []byte(generateInitConfig(accepted)), 0644); err != nil {
 		return fmt.Errorf("failed to write ribbin.jsonc: %w", err)
 	}
 
 	fmt.Printf("Created %s\n", configPath)
-	fmt.Println("\nEdit the file to add your wrapper configurations, then run 'ribbin wrap' to install them.")
-	fmt.Println("Run 'ribbin config --example' to see a comprehensive example config.")
+	if len(info.WorkspaceManifests) > 0 {
+		fmt.Println("This is a workspace root: add \"workspaces\": true to give each package a scope of its own.")
+	}
 
+	if len(accepted) == 0 {
+		fmt.Println("\nEdit the file to add your wrapper configurations, then run 'ribbin wrap' to install them.")
+		fmt.Println("Run 'ribbin config --example' to see a comprehensive example config.")
+		return nil
+	}
+
+	fmt.Printf("Added %d wrapper(s).\n", len(accepted))
+
+	runWrap := initWrap
+	if !runWrap && !initYes {
+		runWrap = promptYesNo(reader, "Run 'ribbin wrap' now?", false)
+	}
+	if !runWrap {
+		fmt.Println("\nRun 'ribbin wrap' to install them.")
+		return nil
+	}
+
+	fmt.Println()
+	wrapCmd.Run(wrapCmd, []string{configPath})
 	return nil
 }
+
+func init() {
+	initCmd.Flags().BoolVar(&initForce, "force", false, "Overwrite an existing ribbin.jsonc")
+	initCmd.Flags().BoolVarP(&initYes, "yes", "y", false, "Accept all suggested wrappers without prompting")
+	initCmd.Flags().BoolVar(&initWrap, "wrap", false, "Run 'ribbin wrap' after creating the config")
+}
@@ -0,0 +1,296 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// projectInfo describes what ribbin init detected about the current directory
+type projectInfo struct {
+	// PackageManager is the JS package manager in use ("pnpm", "yarn", "npm", "bun"), or empty
+	PackageManager string
+	// HasPackageJSON is true when package.json exists
+	HasPackageJSON bool
+	// HasTypeScript is true when typescript is a (dev) dependency
+	HasTypeScript bool
+	// Scripts are the package.json scripts
+	Scripts map[string]string
+	// HasGoMod is true when go.mod exists
+	HasGoMod bool
+	// HasCargo is true when Cargo.toml exists
+	HasCargo bool
+	// ToolVersions maps tool names from .tool-versions to their versions
+	ToolVersions map[string]string
+	// WorkspaceManifests are the workspace manifests making the directory a
+	// monorepo root, like pnpm-workspace.yaml
+	WorkspaceManifests []string
+}
+
+// wrapperSuggestion is a wrapper that ribbin init offers to add
+type wrapperSuggestion struct {
+	// Command is the command name to wrap
+	Command string
+	// Reason is a short comment explaining why the wrapper is suggested
+	Reason string
+	// Message is the block message
+	Message string
+	// Paths restricts the wrapper to project-local binaries
+	Paths []string
+	// Passthrough lists invocations that should be allowed through
+	Passthrough []string
+}
+
+// packageJSON is the subset of package.json that init cares about
+type packageJSON struct {
+	PackageManager  string            `json:"packageManager"`
+	Scripts         map[string]string `json:"scripts"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+}
+
+// lockfilePackageManagers maps lockfile names to the package manager that writes them,
+// in the order they are checked
+var lockfilePackageManagers = []struct {
+	file    string
+	manager string
+}{
+	{"pnpm-lock.yaml", "pnpm"},
+	{"yarn.lock", "yarn"},
+	{"bun.lockb", "bun"},
+	{"package-lock.json", "npm"},
+}
+
+// jsPackageManagers lists all package managers init knows how to block
+var jsPackageManagers = []string{"npm", "pnpm", "yarn", "bun"}
+
+// detectProject inspects dir for well-known project files.
+func detectProject(dir string) *projectInfo {
+	info := &projectInfo{
+		Scripts:      make(map[string]string),
+		ToolVersions: make(map[string]string),
+	}
+
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	info.HasGoMod = exists("go.mod")
+	info.HasCargo = exists("Cargo.toml")
+	info.WorkspaceManifests = config.WorkspaceManifests(dir)
+
+	// .tool-versions (asdf/mise): "<tool> <version>" per line
+	if data, err := os.ReadFile(filepath.Join(dir, ".tool-versions")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			fields := strings.Fields(line)
+			version := ""
+			if len(fields) > 1 {
+				version = fields[1]
+			}
+			info.ToolVersions[fields[0]] = version
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		info.HasPackageJSON = true
+		var pkg packageJSON
+		if json.Unmarshal(data, &pkg) == nil {
+			if pkg.Scripts != nil {
+				info.Scripts = pkg.Scripts
+			}
+			_, inDeps := pkg.Dependencies["typescript"]
+			_, inDevDeps := pkg.DevDependencies["typescript"]
+			info.HasTypeScript = inDeps || inDevDeps
+
+			// "packageManager": "pnpm@8.15.0" is the most explicit signal
+			if name, _, ok := strings.Cut(pkg.PackageManager, "@"); ok {
+				info.PackageManager = name
+			}
+		}
+	}
+
+	// Fall back to lockfiles, then to .tool-versions
+	if info.PackageManager == "" && info.HasPackageJSON {
+		for _, lf := range lockfilePackageManagers {
+			if exists(lf.file) {
+				info.PackageManager = lf.manager
+				break
+			}
+		}
+	}
+	if info.PackageManager == "" {
+		for _, pm := range []string{"pnpm", "yarn", "bun"} {
+			if _, ok := info.ToolVersions[pm]; ok {
+				info.PackageManager = pm
+				break
+			}
+		}
+	}
+
+	return info
+}
+
+// describe returns a human-readable summary of what was detected.
+func (p *projectInfo) describe() []string {
+	var found []string
+	if p.HasPackageJSON {
+		desc := "Node.js project (package.json)"
+		if p.PackageManager != "" {
+			desc = fmt.Sprintf("Node.js project using %s", p.PackageManager)
+		}
+		if p.HasTypeScript {
+			desc += " with TypeScript"
+		}
+		found = append(found, desc)
+	}
+	if p.HasGoMod {
+		found = append(found, "Go module (go.mod)")
+	}
+	if p.HasCargo {
+		found = append(found, "Rust crate (Cargo.toml)")
+	}
+	if len(p.WorkspaceManifests) > 0 {
+		found = append(found, fmt.Sprintf("Workspace root (%s)", strings.Join(p.WorkspaceManifests, ", ")))
+	}
+	if len(p.ToolVersions) > 0 {
+		tools := make([]string, 0, len(p.ToolVersions))
+		for tool := range p.ToolVersions {
+			tools = append(tools, tool)
+		}
+		sort.Strings(tools)
+		found = append(found, fmt.Sprintf(".tool-versions (%s)", strings.Join(tools, ", ")))
+	}
+	return found
+}
+
+// suggestWrappers returns sensible starter wrappers for a detected project.
+func suggestWrappers(info *projectInfo) []wrapperSuggestion {
+	var suggestions []wrapperSuggestion
+
+	if pm := info.PackageManager; pm != "" {
+		// Block the other package managers so lockfiles don't drift
+		for _, other := range jsPackageManagers {
+			if other == pm {
+				continue
+			}
+			suggestions = append(suggestions, wrapperSuggestion{
+				Command: other,
+				Reason:  fmt.Sprintf("This project uses %s", pm),
+				Message: fmt.Sprintf("This project uses %s. Run '%s install' instead.", pm, pm),
+			})
+		}
+	}
+
+	// Block direct tsc when there's a typecheck script to use instead
+	if info.HasTypeScript {
+		runner := info.PackageManager
+		if runner == "" {
+			runner = "npm"
+		}
+		if _, ok := info.Scripts["typecheck"]; ok {
+			// Let package scripts that call tsc keep working
+			var passthrough []string
+			scriptNames := make([]string, 0, len(info.Scripts))
+			for name := range info.Scripts {
+				scriptNames = append(scriptNames, name)
+			}
+			sort.Strings(scriptNames)
+			for _, name := range scriptNames {
+				if strings.Contains(info.Scripts[name], "tsc") {
+					passthrough = append(passthrough, fmt.Sprintf("%s run %s", runner, name))
+				}
+			}
+
+			suggestions = append(suggestions, wrapperSuggestion{
+				Command:     "tsc",
+				Reason:      "Type checking should go through the project script",
+				Message:     fmt.Sprintf("Use '%s run typecheck' instead", runner),
+				Paths:       []string{"./node_modules/.bin/tsc"},
+				Passthrough: passthrough,
+			})
+		}
+	}
+
+	return suggestions
+}
+
+// generateInitConfig renders a ribbin.jsonc containing the accepted suggestions.
+// With no suggestions it returns the default commented template.
+func generateInitConfig(suggestions []wrapperSuggestion) string {
+	if len(suggestions) == 0 {
+		return defaultConfig
+	}
+
+	quote := func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b)
+	}
+	quoteList := func(items []string) string {
+		quoted := make([]string, len(items))
+		for i, item := range items {
+			quoted[i] = quote(item)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+
+	var b strings.Builder
+	b.WriteString(initConfigHeader)
+	b.WriteString("  \"wrappers\": {\n")
+	for i, s := range suggestions {
+		fmt.Fprintf(&b, "    // %s\n", s.Reason)
+		fmt.Fprintf(&b, "    %s: {\n", quote(s.Command))
+		fmt.Fprintf(&b, "      \"action\": \"block\",\n")
+		fmt.Fprintf(&b, "      \"message\": %s", quote(s.Message))
+		if len(s.Paths) > 0 {
+			fmt.Fprintf(&b, ",\n      \"paths\": %s", quoteList(s.Paths))
+		}
+		if len(s.Passthrough) > 0 {
+			fmt.Fprintf(&b, ",\n      \"passthrough\": {\n        \"invocation\": %s\n      }", quoteList(s.Passthrough))
+		}
+		b.WriteString("\n    }")
+		if i < len(suggestions)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("  }\n")
+	b.WriteString(initConfigFooter)
+	return b.String()
+}
+
+// promptYesNo asks a yes/no question on stdout and reads the answer from reader.
+// An empty answer or a read error (e.g. stdin is not interactive) returns defaultYes.
+func promptYesNo(reader *bufio.Reader, question string, defaultYes bool) bool {
+	hint := "[y/N]"
+	if defaultYes {
+		hint = "[Y/n]"
+	}
+	fmt.Printf("%s %s ", question, hint)
+
+	response, err := reader.ReadString('\n')
+	if err != nil && response == "" {
+		fmt.Println()
+		return defaultYes
+	}
+
+	response = strings.TrimSpace(strings.ToLower(response))
+	switch response {
+	case "":
+		return defaultYes
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
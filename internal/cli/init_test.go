@@ -99,3 +99,192 @@ func TestInitCreatedConfigPassesValidation(t *testing.T) {
 		t.Errorf("init-created config failed schema validation: %v", err)
 	}
 }
+
+func TestDefaultConfigUnchangedByTemplateSplit(t *testing.T) {
+	// With no suggestions, init must still produce the commented default template
+	if got := generateInitConfig(nil); got != defaultConfig {
+		t.Errorf("generateInitConfig(nil) should return defaultConfig, got:\n%s", got)
+	}
+}
+
+func TestDetectProject(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]string
+		wantPM     string
+		wantTS     bool
+		wantGo     bool
+		wantCargo  bool
+		wantWS     []string
+		wantBlocks []string
+	}{
+		{
+			name:       "empty directory",
+			files:      map[string]string{},
+			wantBlocks: nil,
+		},
+		{
+			name: "packageManager field wins over lockfile",
+			files: map[string]string{
+				"package.json":      `{"packageManager": "pnpm@8.15.0"}`,
+				"package-lock.json": `{}`,
+			},
+			wantPM:     "pnpm",
+			wantBlocks: []string{"npm", "yarn", "bun"},
+		},
+		{
+			name: "npm lockfile",
+			files: map[string]string{
+				"package.json":      `{}`,
+				"package-lock.json": `{}`,
+			},
+			wantPM:     "npm",
+			wantBlocks: []string{"pnpm", "yarn", "bun"},
+		},
+		{
+			name: "yarn from tool-versions",
+			files: map[string]string{
+				"package.json":   `{}`,
+				".tool-versions": "nodejs 20.11.0\nyarn 1.22.19\n",
+			},
+			wantPM:     "yarn",
+			wantBlocks: []string{"npm", "pnpm", "bun"},
+		},
+		{
+			name: "typescript with typecheck script",
+			files: map[string]string{
+				"package.json":   `{"scripts": {"typecheck": "tsc --noEmit", "build": "tsc -p ."}, "devDependencies": {"typescript": "^5"}}`,
+				"pnpm-lock.yaml": "",
+			},
+			wantPM:     "pnpm",
+			wantTS:     true,
+			wantBlocks: []string{"npm", "yarn", "bun", "tsc"},
+		},
+		{
+			name: "go and rust get no suggestions",
+			files: map[string]string{
+				"go.mod":     "module example.com/x\n",
+				"Cargo.toml": "[package]\n",
+			},
+			wantGo:    true,
+			wantCargo: true,
+		},
+		{
+			name: "workspace root",
+			files: map[string]string{
+				"package.json":        `{"packageManager": "pnpm@9.0.0"}`,
+				"pnpm-workspace.yaml": "packages:\n  - packages/*\n",
+				"turbo.json":          `{}`,
+			},
+			wantPM:     "pnpm",
+			wantWS:     []string{"pnpm-workspace.yaml", "turbo.json"},
+			wantBlocks: []string{"npm", "yarn", "bun"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			info := detectProject(dir)
+			if info.PackageManager != tt.wantPM {
+				t.Errorf("PackageManager = %q, want %q", info.PackageManager, tt.wantPM)
+			}
+			if info.HasTypeScript != tt.wantTS {
+				t.Errorf("HasTypeScript = %v, want %v", info.HasTypeScript, tt.wantTS)
+			}
+			if info.HasGoMod != tt.wantGo || info.HasCargo != tt.wantCargo {
+				t.Errorf("HasGoMod = %v, HasCargo = %v", info.HasGoMod, info.HasCargo)
+			}
+			if strings.Join(info.WorkspaceManifests, ",") != strings.Join(tt.wantWS, ",") {
+				t.Errorf("WorkspaceManifests = %v, want %v", info.WorkspaceManifests, tt.wantWS)
+			}
+
+			suggestions := suggestWrappers(info)
+			var got []string
+			for _, s := range suggestions {
+				got = append(got, s.Command)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantBlocks, ",") {
+				t.Errorf("suggested %v, want %v", got, tt.wantBlocks)
+			}
+
+			// Whatever is suggested must produce a valid config
+			content := generateInitConfig(suggestions)
+			if err := config.ValidateAgainstSchema([]byte(content), config.ValidationStrict); err != nil {
+				t.Errorf("generated config failed schema validation: %v\n%s", err, content)
+			}
+		})
+	}
+}
+
+func TestSuggestWrappers_TscPassthrough(t *testing.T) {
+	info := &projectInfo{
+		PackageManager: "pnpm",
+		HasTypeScript:  true,
+		Scripts: map[string]string{
+			"typecheck": "tsc --noEmit",
+			"build":     "tsc -p . && vite build",
+			"lint":      "eslint .",
+		},
+	}
+
+	var tsc *wrapperSuggestion
+	for _, s := range suggestWrappers(info) {
+		if s.Command == "tsc" {
+			s := s
+			tsc = &s
+		}
+	}
+	if tsc == nil {
+		t.Fatal("expected a tsc suggestion")
+	}
+	want := []string{"pnpm run build", "pnpm run typecheck"}
+	if strings.Join(tsc.Passthrough, ",") != strings.Join(want, ",") {
+		t.Errorf("Passthrough = %v, want %v", tsc.Passthrough, want)
+	}
+	if tsc.Message != "Use 'pnpm run typecheck' instead" {
+		t.Errorf("Message = %q", tsc.Message)
+	}
+}
+
+func TestInitYesWritesSuggestedWrappers(t *testing.T) {
+	_, tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := os.WriteFile(filepath.Join(tempDir, "package.json"), []byte(`{"packageManager": "pnpm@9.0.0"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	initYes = true
+	defer func() { initYes = false }()
+
+	if err := runInit(initCmd, []string{}); err != nil {
+		t.Fatalf("runInit failed: %v", err)
+	}
+
+	cfg, err := config.LoadProjectConfig(filepath.Join(tempDir, "ribbin.jsonc"))
+	if err != nil {
+		t.Fatalf("failed to load generated config: %v", err)
+	}
+	for _, cmd := range []string{"npm", "yarn", "bun"} {
+		w, ok := cfg.Wrappers[cmd]
+		if !ok {
+			t.Errorf("expected %s wrapper", cmd)
+			continue
+		}
+		if w.Action != "block" || !strings.Contains(w.Message, "pnpm install") {
+			t.Errorf("%s wrapper = %+v", cmd, w)
+		}
+	}
+
+	// A second init without --force must refuse to overwrite
+	if err := runInit(initCmd, []string{}); err == nil {
+		t.Error("expected error when ribbin.jsonc already exists")
+	}
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var metricsPrometheus bool
+
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Show how often each wrapper has intercepted a command",
+	Long: `Show how often each wrapper has intercepted a command, by outcome
+(blocked, pass, redirect).
+
+Metrics are opt-in. Enable them in your user settings file,
+~/.config/ribbin/settings.jsonc, optionally naming where to export them:
+
+  {
+    "metrics": {
+      "enabled": true,
+      "statsd": "127.0.0.1:8125",
+      "otlp": "http://127.0.0.1:4318",
+      "prometheusTextfile": "/var/lib/node_exporter/textfile/ribbin.prom"
+    }
+  }
+
+Counts are kept in ribbin's state directory. Each interception sends an
+increment to statsd, posts the cumulative counts to the OTLP/HTTP collector,
+and rewrites the Prometheus textfile. Exporting is best-effort and never
+stops the command; set RIBBIN_VERBOSE=1 to see export errors.
+
+Examples:
+  ribbin metrics                # Show the counts
+  ribbin metrics --prometheus   # Print the counts in the Prometheus format
+  ribbin metrics reset          # Start counting from zero`,
+	Args: cobra.NoArgs,
+	RunE: runMetrics,
+}
+
+var metricsResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Delete the interception counts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := wrap.ResetMetricCounts(); err != nil {
+			return fmt.Errorf("failed to reset metrics: %w", err)
+		}
+		fmt.Println("Metrics reset")
+		return nil
+	},
+}
+
+func init() {
+	metricsCmd.Flags().BoolVar(&metricsPrometheus, "prometheus", false, "Print the counts in the Prometheus text format")
+	metricsCmd.AddCommand(metricsResetCmd)
+	rootCmd.AddCommand(metricsCmd)
+}
+
+func runMetrics(cmd *cobra.Command, args []string) error {
+	counts, err := wrap.LoadMetricCounts()
+	if err != nil {
+		return fmt.Errorf("failed to load metrics: %w", err)
+	}
+	if metricsPrometheus {
+		os.Stdout.Write(wrap.FormatPrometheus(counts))
+		return nil
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+	if !settings.MetricsEnabled() {
+		settingsPath, _ := config.SettingsPath()
+		fmt.Printf("Metrics are disabled (set \"metrics\": {\"enabled\": true} in %s)\n", settingsPath)
+	} else {
+		printMetricsExporters(settings.Metrics)
+	}
+
+	samples := counts.Samples()
+	if len(samples) == 0 {
+		fmt.Println("No interceptions counted")
+		return nil
+	}
+	fmt.Printf("Interceptions since %s:\n", counts.Since.Local().Format("2006-01-02 15:04"))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  COMMAND\tOUTCOME\tCOUNT")
+	for _, sample := range samples {
+		fmt.Fprintf(w, "  %s\t%s\t%d\n", sample.Command, sample.Outcome, sample.Count)
+	}
+	return w.Flush()
+}
+
+// printMetricsExporters lists where metrics are sent
+func printMetricsExporters(m *config.MetricsSettings) {
+	fmt.Println("Metrics are enabled")
+	if m.Statsd != "" {
+		fmt.Printf("  statsd:              %s\n", m.Statsd)
+	}
+	if m.OTLP != "" {
+		fmt.Printf("  otlp:                %s\n", m.OTLPMetricsURL())
+	}
+	if m.PrometheusTextfile != "" {
+		fmt.Printf("  prometheus textfile: %s\n", m.PrometheusTextfile)
+	}
+	fmt.Println()
+}
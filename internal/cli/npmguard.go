@@ -0,0 +1,407 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+// npmGuardScript is what 'ribbin npm-guard install' adds to the postinstall script
+const npmGuardScript = "ribbin npm-guard run"
+
+var npmGuardCmd = &cobra.Command{
+	Use:   "npm-guard",
+	Short: "Re-wrap node_modules binaries after every package install",
+	Long: `Re-wrap node_modules binaries after every package install.
+
+npm, pnpm, yarn and bun recreate node_modules/.bin on every install, which
+silently removes wrappers for tools like tsc. npm-guard adds a postinstall
+script to package.json that puts them back.
+
+Subcommands:
+  install    Add the postinstall script to package.json
+  uninstall  Remove the postinstall script from package.json
+  run        Heal and re-wrap node_modules wrappers (what postinstall runs)`,
+}
+
+var npmGuardInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Add a postinstall script that re-wraps node_modules binaries",
+	Long: `Add a postinstall script that re-wraps node_modules binaries.
+
+Adds 'ribbin npm-guard run' to the "postinstall" script of the nearest
+package.json. An existing postinstall script is kept and runs first.
+
+Package managers run the project's own postinstall script after every
+install, including pnpm, whose onlyBuiltDependencies setting only applies to
+dependencies. Installs with --ignore-scripts skip it; run 'ribbin npm-guard
+run' by hand afterwards.
+
+Examples:
+  ribbin npm-guard install
+  pnpm install                 # tsc is wrapped again afterwards`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pkgPath, err := findPackageJSON()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		changed, err := updatePackageScript(pkgPath, "postinstall", addNpmGuard)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating %s: %v\n", pkgPath, err)
+			os.Exit(1)
+		}
+		if !changed {
+			fmt.Printf("npm-guard already installed in %s\n", pkgPath)
+			return
+		}
+		fmt.Printf("Added '%s' to the postinstall script in %s\n", npmGuardScript, pkgPath)
+	},
+}
+
+var npmGuardUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the npm-guard postinstall script",
+	Long: `Remove the npm-guard postinstall script.
+
+Only the part added by 'ribbin npm-guard install' is removed; the rest of
+an existing postinstall script is kept.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pkgPath, err := findPackageJSON()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		changed, err := updatePackageScript(pkgPath, "postinstall", removeNpmGuard)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating %s: %v\n", pkgPath, err)
+			os.Exit(1)
+		}
+		if !changed {
+			fmt.Printf("npm-guard is not installed in %s\n", pkgPath)
+			return
+		}
+		fmt.Printf("Removed npm-guard from %s\n", pkgPath)
+	},
+}
+
+var npmGuardRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Heal and re-wrap node_modules wrappers",
+	Long: `Heal and re-wrap node_modules wrappers.
+
+Runs 'ribbin heal' for every wrapper under this project's node_modules, then
+'ribbin wrap --keep-going' for the nearest config so binaries added by the
+install are wrapped too.
+
+Problems are reported as warnings and never fail the command, so a broken
+wrapper can't break 'npm install'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pkgPath, err := findPackageJSON()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ribbin npm-guard: %v\n", err)
+			return
+		}
+		projectDir := filepath.Dir(pkgPath)
+
+		execPath, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ribbin npm-guard: cannot locate ribbin: %v\n", err)
+			return
+		}
+		ribbinPath, err := filepath.EvalSymlinks(execPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ribbin npm-guard: cannot locate ribbin: %v\n", err)
+			return
+		}
+
+		healNodeModules(projectDir, ribbinPath)
+
+		configPath, err := config.FindProjectConfig()
+		if err != nil || configPath == "" {
+			fmt.Fprintf(os.Stderr, "ribbin npm-guard: no ribbin config found, nothing to wrap\n")
+			return
+		}
+
+		wrapProc := exec.Command(ribbinPath, "wrap", "--keep-going", configPath)
+		wrapProc.Stdout = os.Stdout
+		wrapProc.Stderr = os.Stderr
+		if err := wrapProc.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "ribbin npm-guard: 'ribbin wrap' reported problems (%v); run it directly for details\n", err)
+		}
+	},
+}
+
+// healNodeModules heals every registry wrapper inside projectDir/node_modules,
+// reporting failures as warnings.
+func healNodeModules(projectDir, ribbinPath string) {
+	registry, err := config.LoadRegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ribbin npm-guard: cannot load registry: %v\n", err)
+		return
+	}
+	registryBefore := registry.CloneWrappers()
+
+	paths := nodeModulesWrappers(registry, projectDir)
+	for _, path := range paths {
+		entry := registry.Wrappers[path]
+		name := filepath.Base(path)
+		check, err := wrap.Heal(entry.Original, ribbinPath, registry, entry.Config, false)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "ribbin npm-guard: failed to heal %s: %v\n", name, err)
+		case check.Status == wrap.HealShimReplaced || check.Status == wrap.HealSidecarChanged:
+			fmt.Printf("Healed %s (%s): %s\n", name, entry.Original, check.Status)
+		}
+	}
+
+	if len(paths) == 0 {
+		return
+	}
+	err = config.UpdateRegistry(func(latest *config.Registry) error {
+		latest.MergeWrapperChanges(registryBefore, registry.Wrappers)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ribbin npm-guard: cannot update registry: %v\n", err)
+	}
+}
+
+// nodeModulesWrappers returns the paths of registry wrappers whose binary is
+// inside projectDir/node_modules, sorted.
+func nodeModulesWrappers(registry *config.Registry, projectDir string) []string {
+	nodeModules := filepath.Join(projectDir, "node_modules") + string(filepath.Separator)
+	if resolved, err := filepath.EvalSymlinks(projectDir); err == nil {
+		projectDir = resolved
+	}
+	resolvedNodeModules := filepath.Join(projectDir, "node_modules") + string(filepath.Separator)
+
+	var paths []string
+	for _, path := range registry.WrapperPaths() {
+		if strings.HasPrefix(path, nodeModules) || strings.HasPrefix(path, resolvedNodeModules) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// findPackageJSON returns the nearest package.json, searching from the
+// current directory upward.
+func findPackageJSON() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		candidate := filepath.Join(dir, "package.json")
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no package.json found")
+		}
+		dir = parent
+	}
+}
+
+// addNpmGuard appends npmGuardScript to a postinstall script. Returns false if
+// it is already there.
+func addNpmGuard(script string) (string, bool) {
+	if hasNpmGuard(script) {
+		return script, false
+	}
+	if strings.TrimSpace(script) == "" {
+		return npmGuardScript, true
+	}
+	return script + " && " + npmGuardScript, true
+}
+
+// removeNpmGuard removes what addNpmGuard added. Returns false if there was
+// nothing to remove.
+func removeNpmGuard(script string) (string, bool) {
+	if script == npmGuardScript {
+		return "", true
+	}
+	if rest, ok := strings.CutSuffix(script, " && "+npmGuardScript); ok {
+		return rest, true
+	}
+	return script, false
+}
+
+func hasNpmGuard(script string) bool {
+	return script == npmGuardScript || strings.HasSuffix(script, " && "+npmGuardScript)
+}
+
+// updatePackageScript rewrites one entry of the "scripts" object in a
+// package.json, keeping key order and indentation. fn receives the current
+// script ("" if absent) and returns the new one; an empty result deletes the
+// script. Returns false if fn reported no change.
+func updatePackageScript(pkgPath, name string, fn func(script string) (string, bool)) (bool, error) {
+	data, err := os.ReadFile(pkgPath)
+	if err != nil {
+		return false, err
+	}
+
+	pkg, err := parseOrderedObject(data)
+	if err != nil {
+		return false, fmt.Errorf("invalid package.json: %w", err)
+	}
+
+	scripts := &orderedObject{values: map[string]json.RawMessage{}}
+	if raw, ok := pkg.values["scripts"]; ok {
+		if scripts, err = parseOrderedObject(raw); err != nil {
+			return false, fmt.Errorf("invalid \"scripts\" in package.json: %w", err)
+		}
+	}
+
+	var current string
+	if raw, ok := scripts.values[name]; ok {
+		if err := json.Unmarshal(raw, &current); err != nil {
+			return false, fmt.Errorf("script %q is not a string", name)
+		}
+	}
+
+	updated, changed := fn(current)
+	if !changed {
+		return false, nil
+	}
+
+	if updated == "" {
+		scripts.delete(name)
+	} else {
+		value, err := marshalNoEscape(updated)
+		if err != nil {
+			return false, err
+		}
+		scripts.set(name, value)
+	}
+
+	if len(scripts.keys) == 0 {
+		pkg.delete("scripts")
+	} else {
+		pkg.set("scripts", scripts.marshal())
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, pkg.marshal(), "", detectJSONIndent(data)); err != nil {
+		return false, err
+	}
+	out.WriteByte('\n')
+
+	info, err := os.Stat(pkgPath)
+	if err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(pkgPath, out.Bytes(), info.Mode())
+}
+
+// orderedObject is a JSON object that remembers its key order, so files like
+// package.json can be edited without reshuffling them.
+type orderedObject struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func parseOrderedObject(data []byte) (*orderedObject, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	obj := &orderedObject{values: map[string]json.RawMessage{}}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		obj.set(key, value)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (o *orderedObject) set(key string, value json.RawMessage) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *orderedObject) delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// marshal returns the object as JSON with its values copied verbatim
+func (o *orderedObject) marshal() []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := marshalNoEscape(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(o.values[key])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// marshalNoEscape marshals v without escaping &, < and >, which are common in
+// shell scripts.
+func marshalNoEscape(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// detectJSONIndent returns the indentation used by the first indented line of
+// a JSON document, defaulting to two spaces.
+func detectJSONIndent(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
+
+func init() {
+	npmGuardCmd.AddCommand(npmGuardInstallCmd)
+	npmGuardCmd.AddCommand(npmGuardUninstallCmd)
+	npmGuardCmd.AddCommand(npmGuardRunCmd)
+	rootCmd.AddCommand(npmGuardCmd)
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestUpdatePackageScript(t *testing.T) {
+	writePkg := func(t *testing.T, content string) string {
+		t.Helper()
+		pkgPath := filepath.Join(t.TempDir(), "package.json")
+		if err := os.WriteFile(pkgPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return pkgPath
+	}
+	readPkg := func(t *testing.T, pkgPath string) string {
+		t.Helper()
+		data, err := os.ReadFile(pkgPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	t.Run("adds scripts object keeping key order and indent", func(t *testing.T) {
+		pkgPath := writePkg(t, "{\n    \"name\": \"app\",\n    \"version\": \"1.0.0\",\n    \"devDependencies\": {\n        \"typescript\": \"^5.0.0\"\n    }\n}\n")
+
+		changed, err := updatePackageScript(pkgPath, "postinstall", addNpmGuard)
+		if err != nil || !changed {
+			t.Fatalf("updatePackageScript() = %v, %v; want true, nil", changed, err)
+		}
+
+		want := "{\n    \"name\": \"app\",\n    \"version\": \"1.0.0\",\n    \"devDependencies\": {\n        \"typescript\": \"^5.0.0\"\n    },\n    \"scripts\": {\n        \"postinstall\": \"ribbin npm-guard run\"\n    }\n}\n"
+		if got := readPkg(t, pkgPath); got != want {
+			t.Errorf("package.json =\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("chains an existing postinstall and is idempotent", func(t *testing.T) {
+		pkgPath := writePkg(t, `{
+  "scripts": {
+    "build": "tsc -p . && echo <done>",
+    "postinstall": "husky install"
+  }
+}
+`)
+		if _, err := updatePackageScript(pkgPath, "postinstall", addNpmGuard); err != nil {
+			t.Fatal(err)
+		}
+		changed, err := updatePackageScript(pkgPath, "postinstall", addNpmGuard)
+		if err != nil || changed {
+			t.Errorf("second install = %v, %v; want false, nil", changed, err)
+		}
+
+		want := `{
+  "scripts": {
+    "build": "tsc -p . && echo <done>",
+    "postinstall": "husky install && ribbin npm-guard run"
+  }
+}
+`
+		if got := readPkg(t, pkgPath); got != want {
+			t.Errorf("package.json =\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("uninstall restores the original script", func(t *testing.T) {
+		pkgPath := writePkg(t, `{
+  "scripts": {
+    "postinstall": "husky install && ribbin npm-guard run"
+  }
+}
+`)
+		changed, err := updatePackageScript(pkgPath, "postinstall", removeNpmGuard)
+		if err != nil || !changed {
+			t.Fatalf("updatePackageScript() = %v, %v; want true, nil", changed, err)
+		}
+		if got, want := readPkg(t, pkgPath), "{\n  \"scripts\": {\n    \"postinstall\": \"husky install\"\n  }\n}\n"; got != want {
+			t.Errorf("package.json =\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("uninstall drops scripts it emptied", func(t *testing.T) {
+		pkgPath := writePkg(t, `{"name": "app", "scripts": {"postinstall": "ribbin npm-guard run"}}`)
+		if _, err := updatePackageScript(pkgPath, "postinstall", removeNpmGuard); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := readPkg(t, pkgPath), "{\n  \"name\": \"app\"\n}\n"; got != want {
+			t.Errorf("package.json =\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("uninstall without guard changes nothing", func(t *testing.T) {
+		original := `{"scripts": {"postinstall": "husky install"}}`
+		pkgPath := writePkg(t, original)
+		changed, err := updatePackageScript(pkgPath, "postinstall", removeNpmGuard)
+		if err != nil || changed {
+			t.Errorf("updatePackageScript() = %v, %v; want false, nil", changed, err)
+		}
+		if got := readPkg(t, pkgPath); got != original {
+			t.Errorf("package.json should be untouched, got:\n%s", got)
+		}
+	})
+
+	t.Run("rejects invalid package.json", func(t *testing.T) {
+		pkgPath := writePkg(t, `["not", "an", "object"]`)
+		if _, err := updatePackageScript(pkgPath, "postinstall", addNpmGuard); err == nil {
+			t.Error("expected error for non-object package.json")
+		}
+	})
+}
+
+func TestNodeModulesWrappers(t *testing.T) {
+	projectDir := t.TempDir()
+	tsc := filepath.Join(projectDir, "node_modules", ".bin", "tsc")
+	eslint := filepath.Join(projectDir, "node_modules", ".bin", "eslint")
+	other := filepath.Join(projectDir+"-other", "node_modules", ".bin", "other")
+	registry := &config.Registry{
+		Wrappers: map[string]config.WrapperEntry{
+			tsc:                  {Original: tsc},
+			eslint:               {Original: eslint},
+			"/usr/local/bin/npm": {Original: "/usr/local/bin/npm"},
+			other:                {Original: other},
+		},
+	}
+
+	paths := nodeModulesWrappers(registry, projectDir)
+	if len(paths) != 2 || paths[0] != eslint || paths[1] != tsc {
+		t.Errorf("nodeModulesWrappers() = %v, want [%s %s]", paths, eslint, tsc)
+	}
+}
@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var nukeYes bool
+var nukeDryRun bool
+
+var nukeCmd = &cobra.Command{
+	Use:   "nuke",
+	Short: "Remove every trace of ribbin from this machine",
+	Long: `Remove every trace of ribbin, so it can be uninstalled cleanly.
+
+ribbin nuke:
+  1. Restores every wrapped binary in the registry
+  2. Restores or cleans up leftover sidecars and metadata files it finds in
+     common binary directories, next to wrapped binaries, and in the
+     node_modules/.bin of known configs
+  3. Clears all activations and snoozes
+  4. Deletes ribbin's config, state and cache directories (registry, audit
+     log, caches)
+
+A binary reinstalled over its wrapper is kept and its stale sidecar removed.
+The plan is shown first and must be confirmed, unless --yes is given.
+
+If any binary can't be restored, ribbin's state is kept (minus activations)
+so that 'ribbin nuke' can be run again once the problem is fixed.
+
+The ribbin binary itself is not removed; uninstall it the way you installed it.
+
+Examples:
+  ribbin nuke --dry-run    # Show what would be removed
+  ribbin nuke              # Show the plan and ask before removing
+  ribbin nuke --yes        # Remove everything without asking`,
+	Args: cobra.NoArgs,
+	RunE: runNuke,
+}
+
+func init() {
+	nukeCmd.Flags().BoolVarP(&nukeYes, "yes", "y", false, "Don't ask for confirmation")
+	nukeCmd.Flags().BoolVar(&nukeDryRun, "dry-run", false, "Show what would be removed without changing anything")
+	rootCmd.AddCommand(nukeCmd)
+}
+
+func runNuke(cmd *cobra.Command, args []string) error {
+	registry, err := config.LoadRegistry()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+	registryBefore := registry.CloneWrappers()
+
+	searchDirs, err := nukeSearchDirs(registry)
+	if err != nil {
+		return err
+	}
+	targets := nukeTargets(registry, searchDirs)
+	stateDirs := nukeStateDirs()
+
+	fmt.Println("ribbin nuke will:")
+	if len(targets) == 0 {
+		fmt.Println("  Restore nothing (no wrapped binaries or leftovers found)")
+	} else {
+		fmt.Printf("  Restore or clean up %d binaries:\n", len(targets))
+		for _, path := range targets {
+			fmt.Printf("    %s\n", path)
+		}
+	}
+	fmt.Println("  Clear all activations and snoozes")
+	if len(stateDirs) > 0 {
+		fmt.Println("  Delete ribbin's directories:")
+		for _, dir := range stateDirs {
+			fmt.Printf("    %s\n", dir)
+		}
+	}
+	fmt.Println()
+
+	if nukeDryRun {
+		fmt.Println("Dry run: nothing was changed")
+		return nil
+	}
+	if !nukeYes && !promptYesNo(bufio.NewReader(os.Stdin), "Proceed?", false) {
+		fmt.Println("Aborted: nothing was changed")
+		return nil
+	}
+
+	var failed int
+	for _, path := range targets {
+		outcome, err := wrap.ForceUnwrap(path, registry)
+		if err != nil {
+			fmt.Printf("Failed %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: %s\n", path, outcome)
+	}
+
+	if failed > 0 {
+		// Keep the registry so the remaining wrappers can still be found,
+		// but switch everything off
+		err := config.UpdateRegistry(func(latest *config.Registry) error {
+			latest.MergeWrapperChanges(registryBefore, registry.Wrappers)
+			latest.GlobalActive = false
+			latest.ClearShellActivations()
+			latest.ClearConfigActivations()
+			latest.ClearSnoozes()
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save registry: %w", err)
+		}
+		return fmt.Errorf("%d binaries could not be restored; ribbin's state was kept so 'ribbin nuke' can be run again", failed)
+	}
+
+	for _, dir := range stateDirs {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", dir, err)
+		}
+		fmt.Printf("Removed %s\n", dir)
+	}
+
+	fmt.Println("\nAll traces of ribbin were removed.")
+	if execPath, err := os.Executable(); err == nil {
+		fmt.Printf("To finish, uninstall the ribbin binary itself (%s).\n", execPath)
+	}
+	return nil
+}
+
+// nukeSearchDirs returns the directories searched for leftover sidecars:
+// common binary directories, the directories of registered wrappers, and
+// node_modules/.bin next to every config the registry knows about.
+func nukeSearchDirs(registry *config.Registry) ([]string, error) {
+	dirs, err := commonBinDirs()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range registry.Wrappers {
+		dirs = append(dirs, filepath.Dir(entry.Original))
+		if entry.Config != "" {
+			dirs = append(dirs, filepath.Join(filepath.Dir(entry.Config), "node_modules", ".bin"))
+		}
+	}
+	for configPath := range registry.ConfigActivations {
+		dirs = append(dirs, filepath.Join(filepath.Dir(configPath), "node_modules", ".bin"))
+	}
+	return dirs, nil
+}
+
+// nukeTargets returns the registered wrappers and the leftovers found in
+// searchDirs, sorted and without duplicates.
+func nukeTargets(registry *config.Registry, searchDirs []string) []string {
+	seen := make(map[string]bool)
+	for _, entry := range registry.Wrappers {
+		seen[entry.Original] = true
+	}
+	for _, path := range wrap.FindLeftovers(searchDirs) {
+		seen[path] = true
+	}
+
+	targets := make([]string, 0, len(seen))
+	for path := range seen {
+		targets = append(targets, path)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// nukeStateDirs returns ribbin's config, state and cache directories that exist
+func nukeStateDirs() []string {
+	var dirs []string
+	for _, get := range []func() (string, error){security.GetConfigDir, security.GetStateDir, security.GetCacheDir} {
+		dir, err := get()
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(dir); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/happycollision/ribbin/internal/security"
+	"github.com/spf13/cobra"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Keep separate wrap states for different clients",
+	Long: `Keep separate registries and settings, say one per client, on one machine.
+
+Each profile has its own registry (which binaries are wrapped, and which
+configs and shells are activated) and its own settings.jsonc. The default
+profile keeps them in ~/.config/ribbin itself; any other in
+~/.config/ribbin/profiles/<name>. Security settings and the user config are
+shared by every profile.
+
+RIBBIN_PROFILE picks the profile for one shell or command, overriding the
+one chosen with 'ribbin profile switch'. A binary wrapped under one profile
+runs unwrapped under another, which has no record of it.`,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the profiles, marking the active one",
+	Long: `List the profiles, marking the active one with *.
+
+Examples:
+  ribbin profile list`,
+	Args: cobra.NoArgs,
+	RunE: runProfileList,
+}
+
+var profileCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create an empty profile",
+	Long: `Create a profile with an empty registry and no settings.
+
+Names use letters, digits, '-' and '_'. Creating a profile doesn't switch
+to it.
+
+Examples:
+  ribbin profile create work
+  ribbin profile switch work`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProfileCreate,
+}
+
+var profileSwitchCmd = &cobra.Command{
+	Use:   "switch <name>",
+	Short: "Use another profile from now on",
+	Long: `Use another profile whenever RIBBIN_PROFILE isn't set. Switch to
+"default" to go back to the files in ~/.config/ribbin itself.
+
+Wrappers installed under the previous profile stay in place, and run their
+commands unwrapped until it is switched back to.
+
+Examples:
+  ribbin profile switch personal
+  ribbin profile switch default`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProfileSwitch,
+}
+
+func init() {
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileCreateCmd)
+	profileCmd.AddCommand(profileSwitchCmd)
+	rootCmd.AddCommand(profileCmd)
+}
+
+func runProfileList(cmd *cobra.Command, args []string) error {
+	active, source, err := security.ActiveProfile()
+	if err != nil {
+		return err
+	}
+	names, err := security.ListProfiles()
+	if err != nil {
+		return fmt.Errorf("failed to list profiles: %w", err)
+	}
+	for _, name := range names {
+		if name != active {
+			fmt.Printf("  %s\n", name)
+		} else if source != "" {
+			fmt.Printf("* %s (set by %s)\n", name, source)
+		} else {
+			fmt.Printf("* %s\n", name)
+		}
+	}
+	if exists, err := security.ProfileExists(active); err == nil && !exists {
+		fmt.Printf("* %s (set by %s; not created yet)\n", active, source)
+	}
+	return nil
+}
+
+func runProfileCreate(cmd *cobra.Command, args []string) error {
+	dir, err := security.CreateProfile(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created profile %s in %s\n", args[0], dir)
+	fmt.Printf("Use it with 'ribbin profile switch %s', or RIBBIN_PROFILE=%s for one shell\n", args[0], args[0])
+	return nil
+}
+
+func runProfileSwitch(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := security.ValidateProfileName(name); err != nil {
+		return err
+	}
+	if err := security.SwitchProfile(name); err != nil {
+		return err
+	}
+	fmt.Printf("Switched to profile %s\n", name)
+	if env := os.Getenv(security.ProfileEnvVar); env != "" && env != name {
+		fmt.Fprintf(os.Stderr, "Note: %s=%s is set, so this shell keeps using %s\n", security.ProfileEnvVar, env, env)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Print a compact status for shell prompts",
+	Long: `Print a compact status for embedding in a shell prompt.
+
+Prints ⛔ and the number of wrappers ribbin governs in the current directory,
+e.g. ⛔3, or nothing if ribbin isn't active here. No newline is printed and
+errors are silent, so the output can go straight into PS1.
+
+To stay fast, only the wrappers' decision cache is read, never a config.
+The cache for a directory is filled the first time a wrapped command runs
+there (and again after the config changes), so until then nothing is shown.
+
+Examples:
+  PS1='$(ribbin prompt) \w \$ '     # bash
+
+  # starship.toml
+  [custom.ribbin]
+  command = "ribbin prompt"
+  when = true`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return
+		}
+		registry, err := config.LoadRegistry()
+		if err != nil {
+			return
+		}
+		names, _ := wrap.ActiveWrappers(registry, cwd)
+		if len(names) > 0 {
+			fmt.Printf("⛔%d", len(names))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(promptCmd)
+}
@@ -0,0 +1,410 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var registryImportRewrap bool
+
+var registryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Maintain ribbin's registry",
+	Long: `Maintain the registry of wrapped binaries and activations
+(~/.config/ribbin/registry.json), and carry it to another machine.`,
+}
+
+var registryMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the registry to the current format",
+	Long: `Upgrade the registry file to the current format.
+
+Older registries are upgraded automatically whenever ribbin changes them, so
+this is only needed to upgrade one up front. Version 1 registries keyed
+wrappers by command name, so two wrapped binaries with the same name (say,
+the tsc of two projects) collided; version 2 keys them by the path of the
+wrapped binary.
+
+The old file is kept next to the registry as registry.json.v<N>.bak.
+
+Examples:
+  ribbin registry migrate`,
+	Args: cobra.NoArgs,
+	RunE: runRegistryMigrate,
+}
+
+var registryRepairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Recover from a corrupt registry",
+	Long: `Recover from a registry.json that can't be parsed, as one cut short by a
+full disk.
+
+A wrapper that finds the registry corrupt moves it aside, as
+registry.json.corrupt-<time>, and runs its command unwrapped, warning on
+stderr; repair does the same first if that hasn't happened yet. It then
+recovers the wrappers and activations from the snapshot kept with the
+quarantined registry, which is the last registry ribbin wrote in full, and
+adds them to the registry. Wrappers that are no longer installed are left
+out.
+
+The quarantined registry is kept, renamed to end in .repaired. Without a
+snapshot to recover from, the wrappers stay installed but untracked; find
+them with 'ribbin find --all'.
+
+Examples:
+  ribbin registry repair`,
+	Args: cobra.NoArgs,
+	RunE: runRegistryRepair,
+}
+
+var registryExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the registry as a bundle for another machine",
+	Long: `Write the wrapped binaries, the configs they were wrapped for, and the
+config and global activations to stdout as JSON, for 'ribbin registry import'
+on another machine.
+
+Shell activations and snoozes belong to this machine's processes and are
+left out, as are orphans tracked by 'ribbin find', which have no config.
+
+Examples:
+  ribbin registry export > ribbin-bundle.json`,
+	Args: cobra.NoArgs,
+	RunE: runRegistryExport,
+}
+
+var registryImportCmd = &cobra.Command{
+	Use:   "import <bundle.json>",
+	Short: "Restore wrappers and activations from an exported bundle",
+	Long: `Read a bundle written by 'ribbin registry export' ("-" for stdin) and add
+what still holds on this machine to the registry.
+
+Paths under the exporting user's home directory are moved to yours. Each
+wrapped binary is then checked: one whose config or binary doesn't exist here
+is skipped, and one that is already a wrapper is tracked again. A binary that
+exists but isn't wrapped, as after a fresh install, is only reported unless
+--rewrap is given, which wraps it for its config. Configs activated with
+'ribbin activate --config' are activated again if they exist, as is global
+activation.
+
+Examples:
+  ribbin registry import ribbin-bundle.json
+  ribbin registry import ribbin-bundle.json --rewrap`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRegistryImport,
+}
+
+func init() {
+	registryImportCmd.Flags().BoolVar(&registryImportRewrap, "rewrap", false,
+		"Wrap binaries from the bundle that exist here but aren't wrapped")
+
+	registryCmd.AddCommand(registryExportCmd)
+	registryCmd.AddCommand(registryImportCmd)
+	registryCmd.AddCommand(registryMigrateCmd)
+	registryCmd.AddCommand(registryRepairCmd)
+	rootCmd.AddCommand(registryCmd)
+}
+
+func runRegistryMigrate(cmd *cobra.Command, args []string) error {
+	path, err := config.RegistryPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		fmt.Println("No registry yet; it will be created in the current format")
+		return nil
+	}
+
+	var from int
+	var backup string
+	err = config.UpdateRegistry(func(registry *config.Registry) error {
+		from = registry.MigratedFrom()
+		if from == 0 {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		backup = fmt.Sprintf("%s.v%d.bak", path, from)
+		return os.WriteFile(backup, data, 0644)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to migrate registry: %w", err)
+	}
+
+	if from == 0 {
+		fmt.Printf("Registry is already at version %d\n", config.RegistryVersion)
+		return nil
+	}
+	fmt.Printf("Migrated registry from version %d to %d\n", from, config.RegistryVersion)
+	fmt.Printf("The old registry was kept at %s\n", backup)
+	return nil
+}
+
+func runRegistryRepair(cmd *cobra.Command, args []string) error {
+	if _, err := config.LoadRegistry(); errors.Is(err, config.ErrRegistryCorrupt) {
+		moved, err := config.QuarantineRegistry()
+		if err != nil {
+			return err
+		}
+		if moved != "" {
+			fmt.Printf("Moved the corrupt registry to %s\n", moved)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	quarantined, err := config.QuarantinedRegistries()
+	if err != nil {
+		return err
+	}
+	if len(quarantined) == 0 {
+		fmt.Println("The registry is fine; nothing to repair")
+		return nil
+	}
+	recovered, source, err := config.RecoverRegistry()
+	if err != nil {
+		return err
+	}
+	if recovered == nil {
+		fmt.Println("No copy of the registry could be read, so nothing was recovered.")
+		fmt.Println("Wrapped binaries stay wrapped but untracked. Find them with 'ribbin find --all',")
+		fmt.Println("and track them again with 'ribbin find --adopt <config> <dir>'.")
+	} else if err := mergeRecoveredRegistry(recovered, source); err != nil {
+		return err
+	}
+
+	for _, path := range quarantined {
+		if err := config.MarkRepaired(path); err != nil {
+			return fmt.Errorf("failed to mark %s repaired: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// mergeRecoveredRegistry adds the wrappers of a registry recovered from
+// source that are still installed, and its activations, to the registry
+func mergeRecoveredRegistry(recovered *config.Registry, source string) error {
+	recovered.PruneDeadShellActivations()
+	var wrappers []config.WrapperEntry
+	var gone int
+	for _, path := range recovered.WrapperPaths() {
+		if wrapped, err := wrap.IsAlreadyShimmed(path); err != nil || !wrapped {
+			gone++
+			continue
+		}
+		wrappers = append(wrappers, recovered.Wrappers[path])
+	}
+
+	var added, activated int
+	err := config.UpdateRegistry(func(latest *config.Registry) error {
+		for _, entry := range wrappers {
+			if _, ok := latest.Wrapper(entry.Original); !ok {
+				latest.AddWrapper(entry)
+				added++
+			}
+		}
+		for path, entry := range recovered.ConfigActivations {
+			if _, ok := latest.ConfigActivations[path]; !ok {
+				latest.ConfigActivations[path] = entry
+				activated++
+			}
+		}
+		for pid, entry := range recovered.ShellActivations {
+			if _, ok := latest.ShellActivations[pid]; !ok {
+				latest.ShellActivations[pid] = entry
+				activated++
+			}
+		}
+		latest.GlobalActive = latest.GlobalActive || recovered.GlobalActive
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save registry: %w", err)
+	}
+
+	fmt.Printf("Recovered from %s:\n", source)
+	fmt.Printf("  %d wrapper(s), %d activation(s)", added, activated)
+	if recovered.GlobalActive {
+		fmt.Printf(", global activation")
+	}
+	fmt.Println()
+	if gone > 0 {
+		fmt.Printf("  %d wrapper(s) no longer installed were left out\n", gone)
+	}
+	return nil
+}
+
+func runRegistryExport(cmd *cobra.Command, args []string) error {
+	registry, err := config.LoadRegistry()
+	if err != nil {
+		return err
+	}
+	home, _ := os.UserHomeDir()
+	data, err := json.MarshalIndent(registry.Export(home), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// importOutcome is what 'ribbin registry import' did with a bundle's wrapper
+type importOutcome int
+
+const (
+	importTracked importOutcome = iota
+	importWrapped
+	importUnwrapped
+	importSkipped
+	importFailed
+)
+
+func runRegistryImport(cmd *cobra.Command, args []string) error {
+	var in io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	bundle, err := config.ReadRegistryBundle(in)
+	if err != nil {
+		return err
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		from := bundle.Home
+		if bundle.MoveHome(home) {
+			fmt.Printf("Moving paths under %s to %s\n", from, home)
+		}
+	}
+
+	registry, err := config.LoadRegistry()
+	if err != nil {
+		return err
+	}
+	registryBefore := registry.CloneWrappers()
+
+	var ribbinPath string
+	if registryImportRewrap {
+		execPath, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("cannot find the ribbin binary: %w", err)
+		}
+		if ribbinPath, err = filepath.EvalSymlinks(execPath); err != nil {
+			return fmt.Errorf("cannot find the ribbin binary: %w", err)
+		}
+	}
+
+	counts := make(map[importOutcome]int)
+	for _, entry := range bundle.Wrappers {
+		outcome, detail := importWrapper(entry, registry, ribbinPath)
+		counts[outcome]++
+		switch outcome {
+		case importTracked:
+			fmt.Printf("Tracking '%s' (already wrapped)\n", entry.Original)
+		case importWrapped:
+			fmt.Printf("Wrapped '%s'\n", entry.Original)
+		case importUnwrapped:
+			fmt.Printf("Not wrapped: '%s' (use --rewrap to wrap it)\n", entry.Original)
+		case importSkipped:
+			fmt.Printf("Skipping '%s': %s\n", entry.Original, detail)
+		case importFailed:
+			fmt.Printf("Failed to wrap '%s': %s\n", entry.Original, detail)
+		}
+	}
+
+	// Activations only apply to configs that exist here
+	var activated []string
+	for _, path := range sortedActivationPaths(bundle.ConfigActivations) {
+		if _, err := os.Stat(path); err != nil {
+			fmt.Printf("Skipping activation of '%s': config not found\n", path)
+			continue
+		}
+		activated = append(activated, path)
+	}
+
+	err = config.UpdateRegistry(func(latest *config.Registry) error {
+		latest.MergeWrapperChanges(registryBefore, registry.Wrappers)
+		for _, path := range activated {
+			if entry := bundle.ConfigActivations[path]; len(entry.Scopes) > 0 {
+				latest.AddScopedConfigActivation(path, entry.Scopes...)
+			} else {
+				latest.AddConfigActivation(path, entry.Tags...)
+			}
+		}
+		if bundle.GlobalActive {
+			latest.GlobalActive = true
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save registry: %w", err)
+	}
+
+	for _, path := range activated {
+		fmt.Printf("Activated config '%s'\n", path)
+	}
+	if bundle.GlobalActive {
+		fmt.Println("Activated ribbin globally")
+	}
+	fmt.Printf("\nSummary: %d tracked, %d wrapped, %d not wrapped, %d skipped, %d failed\n",
+		counts[importTracked], counts[importWrapped], counts[importUnwrapped], counts[importSkipped], counts[importFailed])
+	if counts[importFailed] > 0 {
+		return fmt.Errorf("%d binaries failed to wrap", counts[importFailed])
+	}
+	return nil
+}
+
+// importWrapper checks a bundle's wrapper against this machine and records
+// it in registry if it is wrapped here, wrapping it first with --rewrap.
+// Returns what happened, and why for a skip or failure.
+func importWrapper(entry config.WrapperEntry, registry *config.Registry, ribbinPath string) (importOutcome, string) {
+	if _, err := os.Stat(entry.Config); err != nil {
+		return importSkipped, fmt.Sprintf("config %s not found", entry.Config)
+	}
+	if _, err := os.Lstat(entry.Original); err != nil {
+		return importSkipped, "binary not found"
+	}
+	wrapped, err := wrap.IsAlreadyShimmed(entry.Original)
+	if err != nil {
+		return importSkipped, err.Error()
+	}
+	if wrapped {
+		registry.AddWrapper(entry)
+		return importTracked, ""
+	}
+	if !registryImportRewrap {
+		return importUnwrapped, ""
+	}
+	if err := security.ValidateBinaryForShim(entry.Original, false); err != nil {
+		return importFailed, err.Error()
+	}
+	if err := wrap.Install(entry.Original, ribbinPath, registry, entry.Config); err != nil {
+		return importFailed, err.Error()
+	}
+	return importWrapped, ""
+}
+
+// sortedActivationPaths returns the configs of activations, sorted
+func sortedActivationPaths(activations map[string]config.ConfigActivationEntry) []string {
+	paths := make([]string, 0, len(activations))
+	for path := range activations {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
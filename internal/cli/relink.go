@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var relinkRibbinPath string
+var relinkDryRun bool
+
+var relinkCmd = &cobra.Command{
+	Use:   "relink [commands...]",
+	Short: "Re-point wrappers after moving or reinstalling ribbin",
+	Long: `Re-point wrappers after moving or reinstalling ribbin.
+
+Wrappers are symlinks to the ribbin binary. If ribbin is moved or
+reinstalled somewhere else, they point at the old location and stop working
+(or keep running the old ribbin).
+
+'ribbin relink' checks every wrapper in the registry (or just the named
+commands) and atomically re-points wrappers whose ribbin binary is missing,
+or that still point at the ribbin they were wrapped with, to this ribbin
+binary (or --ribbin-path).
+
+Wrappers that a package manager replaced are left alone; see 'ribbin heal'.
+
+Examples:
+  ribbin relink                                  # Re-point to this ribbin
+  ribbin relink --dry-run                        # Show what would change
+  ribbin relink --ribbin-path /usr/local/bin/ribbin`,
+	Run: func(cmd *cobra.Command, args []string) {
+		registry, err := config.LoadRegistry()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading registry: %v\n", err)
+			os.Exit(ExitCode(err))
+		}
+
+		ribbinPath, err := relinkTarget()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		paths, err := healTargets(registry, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(ExitCode(err))
+		}
+		if len(paths) == 0 {
+			fmt.Println("No wrappers in the registry")
+			return
+		}
+
+		var relinked, healthy, skipped, failed int
+		for _, path := range paths {
+			entry := registry.Wrappers[path]
+			name := filepath.Base(path)
+
+			var check wrap.RelinkCheck
+			var changed bool
+			if relinkDryRun {
+				check = wrap.CheckRelink(entry.Original, ribbinPath)
+				changed = check.Status == wrap.RelinkBroken || check.Status == wrap.RelinkOtherRibbin
+			} else {
+				check, changed, err = wrap.Relink(entry.Original, ribbinPath)
+			}
+
+			switch {
+			case err != nil:
+				fmt.Printf("Failed to relink %s (%s): %v\n", name, entry.Original, err)
+				failed++
+			case changed:
+				verb := "Relinked"
+				if relinkDryRun {
+					verb = "Would relink"
+				}
+				fmt.Printf("%s %s (%s): %s at %s\n", verb, name, entry.Original, check.Status, check.Target)
+				relinked++
+			case check.Status == wrap.RelinkNotNeeded:
+				healthy++
+			case check.Status == wrap.RelinkReplaced:
+				fmt.Printf("Skipping %s: %s is no longer a ribbin wrapper (run 'ribbin heal')\n", name, entry.Original)
+				skipped++
+			default:
+				fmt.Printf("Skipping %s: %s\n", name, check.Status)
+				skipped++
+			}
+		}
+
+		if relinkDryRun {
+			fmt.Printf("\n%d ok, %d would be relinked, %d skipped\n", healthy, relinked, skipped)
+			return
+		}
+
+		fmt.Printf("\n%d relinked, %d ok, %d skipped, %d failed\n", relinked, healthy, skipped, failed)
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// relinkTarget returns the absolute, resolved ribbin binary to point wrappers
+// at: --ribbin-path if given, otherwise the running executable
+func relinkTarget() (string, error) {
+	path := relinkRibbinPath
+	if path == "" {
+		execPath, err := os.Executable()
+		if err != nil {
+			return "", fmt.Errorf("cannot locate ribbin executable: %w", err)
+		}
+		path = execPath
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	resolved, err := filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %s: %w", absPath, err)
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+		return "", fmt.Errorf("%s is not an executable file", resolved)
+	}
+	return resolved, nil
+}
+
+func init() {
+	relinkCmd.Flags().StringVar(&relinkRibbinPath, "ribbin-path", "", "Ribbin binary to point wrappers at (default: this one)")
+	relinkCmd.Flags().BoolVar(&relinkDryRun, "dry-run", false, "Show what would be relinked without making changes")
+}
@@ -0,0 +1,51 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// progressBarWidth is how many columns the bar itself takes up
+const progressBarWidth = 30
+
+// Progress is a bar that shows how much of a long operation is done. It is
+// redrawn in place, so it is only shown on a terminal; elsewhere it writes
+// nothing.
+type Progress struct {
+	out   io.Writer
+	label string
+	total int
+	shown bool
+}
+
+// NewProgress returns a Progress for total items, drawn on standard error so
+// it stays out of output that is piped or parsed
+func NewProgress(label string, total int) *Progress {
+	p := &Progress{label: label, total: total}
+	if isTerminal(os.Stderr) && os.Getenv("TERM") != "dumb" {
+		p.out = os.Stderr
+	}
+	return p
+}
+
+// Update redraws the bar with done of the total items finished
+func (p *Progress) Update(done int) {
+	if p.out == nil || p.total == 0 {
+		return
+	}
+	filled := done * progressBarWidth / p.total
+	fmt.Fprintf(p.out, "\r%s [%s%s] %d/%d", p.label,
+		strings.Repeat("#", filled), strings.Repeat(" ", progressBarWidth-filled), done, p.total)
+	p.shown = true
+}
+
+// Done clears the bar, leaving the line for the output that follows
+func (p *Progress) Done() {
+	if p.out == nil || !p.shown {
+		return
+	}
+	fmt.Fprint(p.out, "\r\x1b[K")
+	p.shown = false
+}
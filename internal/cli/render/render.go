@@ -0,0 +1,155 @@
+// Package render formats the human output of ribbin's commands: colors that
+// are dropped when the output isn't a terminal, and tables whose columns
+// line up and fit the terminal's width.
+package render
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// NoColor turns colors off, as the global --no-color flag does
+var NoColor bool
+
+// Style is how a piece of text is shown
+type Style int
+
+const (
+	Plain Style = iota
+	Bold
+	Dim
+	Red
+	Green
+	Yellow
+	Cyan
+)
+
+var styleCodes = map[Style]string{
+	Bold:   "1",
+	Dim:    "2",
+	Red:    "31",
+	Green:  "32",
+	Yellow: "33",
+	Cyan:   "36",
+}
+
+// ansiPattern matches the escape sequences Paint adds
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// Renderer writes styled output to a writer
+type Renderer struct {
+	out io.Writer
+	// Color is whether styles are shown
+	Color bool
+	// Width is the width of the terminal, or 0 if lines may be any length
+	Width int
+}
+
+// New returns a Renderer for out. Colors are shown only if out is a
+// terminal, NO_COLOR isn't set, TERM isn't "dumb", and --no-color wasn't
+// given. Tables are fitted to $COLUMNS, or else the terminal's width; output
+// that isn't a terminal isn't fitted.
+func New(out io.Writer) *Renderer {
+	terminal := isTerminal(out)
+	r := &Renderer{
+		out:   out,
+		Color: terminal && !NoColor && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb",
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		r.Width = columns
+	} else if terminal {
+		r.Width = terminalWidth(out.(*os.File))
+	}
+	return r
+}
+
+// Stdout returns a Renderer for standard output
+func Stdout() *Renderer {
+	return New(os.Stdout)
+}
+
+// isTerminal reports whether out is a character device, such as a terminal
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Paint returns text in the given style, or unchanged without colors
+func (r *Renderer) Paint(style Style, text string) string {
+	code, ok := styleCodes[style]
+	if !r.Color || !ok || text == "" {
+		return text
+	}
+	return "\x1b[" + code + "m" + text + "\x1b[0m"
+}
+
+// Printf writes formatted text
+func (r *Renderer) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(r.out, format, args...)
+}
+
+// Println writes its arguments and a newline
+func (r *Renderer) Println(args ...interface{}) {
+	fmt.Fprintln(r.out, args...)
+}
+
+// Heading writes a section title in bold
+func (r *Renderer) Heading(title string) {
+	fmt.Fprintln(r.out, r.Paint(Bold, title))
+}
+
+// Field writes an indented "label: value" line, padding labels to width so
+// the values of consecutive fields line up
+func (r *Renderer) Field(indent, width int, label, value string) {
+	fmt.Fprintf(r.out, "%s%s %s\n", strings.Repeat(" ", indent), padRight(label+":", width+1), value)
+}
+
+// VisibleWidth returns the number of columns text takes up in a terminal,
+// not counting styles
+func VisibleWidth(text string) int {
+	return utf8.RuneCountInString(ansiPattern.ReplaceAllString(text, ""))
+}
+
+// padRight pads text with spaces to width visible columns
+func padRight(text string, width int) string {
+	if pad := width - VisibleWidth(text); pad > 0 {
+		return text + strings.Repeat(" ", pad)
+	}
+	return text
+}
+
+// Truncate shortens text to width columns, replacing the end with "…".
+// Styles are dropped from text that is shortened.
+func Truncate(text string, width int) string {
+	if width <= 0 || VisibleWidth(text) <= width {
+		return text
+	}
+	runes := []rune(ansiPattern.ReplaceAllString(text, ""))
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// TruncateLeft shortens text to width columns, replacing the start with
+// "…", which keeps the end of a path. Styles are dropped from text that is
+// shortened.
+func TruncateLeft(text string, width int) string {
+	if width <= 0 || VisibleWidth(text) <= width {
+		return text
+	}
+	runes := []rune(ansiPattern.ReplaceAllString(text, ""))
+	if width == 1 {
+		return "…"
+	}
+	return "…" + string(runes[len(runes)-width+1:])
+}
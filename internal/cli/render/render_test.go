@@ -0,0 +1,89 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestTableAlignsColumns(t *testing.T) {
+	var out bytes.Buffer
+	r := &Renderer{out: &out}
+	table := r.Table(2, "COMMAND", "BINARY")
+	table.Row("npm", "/usr/local/bin/npm")
+	table.Row("tsc", "/repo/node_modules/.bin/tsc")
+	table.Row("", "/repo/packages/a/node_modules/.bin/tsc")
+	table.Flush()
+
+	want := `  COMMAND  BINARY
+  npm      /usr/local/bin/npm
+  tsc      /repo/node_modules/.bin/tsc
+           /repo/packages/a/node_modules/.bin/tsc
+`
+	if out.String() != want {
+		t.Errorf("table =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestTableFitsWidth(t *testing.T) {
+	var out bytes.Buffer
+	r := &Renderer{out: &out, Width: 30}
+	table := r.Table(0)
+	table.TrimLeft = true
+	table.Row("tsc", "/repo/packages/web/node_modules/.bin/tsc")
+	table.Flush()
+
+	line := strings.TrimSuffix(out.String(), "\n")
+	if VisibleWidth(line) != 30 {
+		t.Errorf("line %q is %d columns, want 30", line, VisibleWidth(line))
+	}
+	if !strings.HasPrefix(line, "tsc  …") || !strings.HasSuffix(line, "/node_modules/.bin/tsc") {
+		t.Errorf("line %q should keep the end of the path", line)
+	}
+}
+
+func TestColorsOnlyWhenEnabled(t *testing.T) {
+	var out bytes.Buffer
+	r := &Renderer{out: &out, Color: true}
+	table := r.Table(0)
+	table.StyledRow(Cell{Text: "npm", Style: Red}, Cell{Text: "block"})
+	table.Flush()
+	if out.String() != "\x1b[31mnpm\x1b[0m  block\n" {
+		t.Errorf("colored row = %q", out.String())
+	}
+
+	if got := (&Renderer{}).Paint(Red, "npm"); got != "npm" {
+		t.Errorf("Paint without color = %q", got)
+	}
+}
+
+func TestNewRespectsNoColor(t *testing.T) {
+	t.Setenv("COLUMNS", "100")
+	t.Setenv("NO_COLOR", "1")
+	r := New(&bytes.Buffer{})
+	if r.Color {
+		t.Error("colors on for output that isn't a terminal")
+	}
+	if r.Width != 100 {
+		t.Errorf("Width = %d, want $COLUMNS", r.Width)
+	}
+
+	t.Setenv("COLUMNS", "")
+	if r := New(&bytes.Buffer{}); r.Width != 0 {
+		t.Errorf("Width = %d, want 0 for output that isn't a terminal", r.Width)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := Truncate("use pnpm instead", 8); got != "use pnp…" {
+		t.Errorf("Truncate = %q", got)
+	}
+	if got := TruncateLeft("/a/b/c/tsc", 6); got != "…c/tsc" {
+		t.Errorf("TruncateLeft = %q", got)
+	}
+	if got := Truncate("short", 10); got != "short" {
+		t.Errorf("Truncate = %q", got)
+	}
+}
@@ -0,0 +1,127 @@
+package render
+
+import (
+	"strings"
+)
+
+// minLastColumn is the narrowest the last column of a table is shortened to
+const minLastColumn = 12
+
+// Cell is a table cell's text and style
+type Cell struct {
+	Text  string
+	Style Style
+}
+
+// Table lines up rows in columns. The last column is shortened to fit the
+// terminal, so it should hold the longest, least important text.
+type Table struct {
+	r       *Renderer
+	indent  string
+	headers []string
+	rows    [][]Cell
+	// TrimLeft shortens the last column from the start rather than the
+	// end, keeping the end of paths
+	TrimLeft bool
+}
+
+// Table starts a table indented by indent spaces. The headers are shown
+// dimmed above the rows; with none, there's no header line.
+func (r *Renderer) Table(indent int, headers ...string) *Table {
+	return &Table{r: r, indent: strings.Repeat(" ", indent), headers: headers}
+}
+
+// Row adds a row of plain cells
+func (t *Table) Row(cells ...string) {
+	row := make([]Cell, len(cells))
+	for i, text := range cells {
+		row[i] = Cell{Text: text}
+	}
+	t.rows = append(t.rows, row)
+}
+
+// StyledRow adds a row of cells with their own styles
+func (t *Table) StyledRow(cells ...Cell) {
+	t.rows = append(t.rows, cells)
+}
+
+// Flush writes the table
+func (t *Table) Flush() {
+	columns := len(t.headers)
+	for _, row := range t.rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	if columns == 0 {
+		return
+	}
+
+	widths := make([]int, columns)
+	measure := func(i int, text string) {
+		if w := VisibleWidth(text); w > widths[i] {
+			widths[i] = w
+		}
+	}
+	for i, header := range t.headers {
+		measure(i, header)
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			measure(i, cell.Text)
+		}
+	}
+
+	// Fit the last column into what the others leave of the terminal
+	lastWidth := 0
+	if t.r.Width > 0 {
+		used := len(t.indent)
+		for _, w := range widths[:columns-1] {
+			used += w + 2
+		}
+		lastWidth = t.r.Width - used
+		if lastWidth < minLastColumn {
+			lastWidth = minLastColumn
+		}
+	}
+
+	write := func(cells []Cell, style Style) {
+		var line strings.Builder
+		line.WriteString(t.indent)
+		for i, cell := range cells {
+			text := cell.Text
+			if i == columns-1 {
+				if t.TrimLeft {
+					text = TruncateLeft(text, lastWidth)
+				} else {
+					text = Truncate(text, lastWidth)
+				}
+			}
+			text = t.r.Paint(styleOr(cell.Style, style), text)
+			if i < columns-1 {
+				text = padRight(text, widths[i]+2)
+			}
+			line.WriteString(text)
+		}
+		t.r.Println(strings.TrimRight(line.String(), " "))
+	}
+
+	if len(t.headers) > 0 {
+		header := make([]Cell, len(t.headers))
+		for i, text := range t.headers {
+			header[i] = Cell{Text: text}
+		}
+		write(header, Dim)
+	}
+	for _, row := range t.rows {
+		write(row, Plain)
+	}
+}
+
+// styleOr returns style, or fallback if style is Plain
+func styleOr(style, fallback Style) Style {
+	if style == Plain {
+		return fallback
+	}
+	return style
+}
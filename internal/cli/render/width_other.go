@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package render
+
+import "os"
+
+// terminalWidth returns 0, as the width of terminals isn't known here
+func terminalWidth(f *os.File) int {
+	return 0
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/security"
+	"github.com/spf13/cobra"
+)
+
+var securityShowJSON bool
+
+var securityCmd = &cobra.Command{
+	Use:   "security",
+	Short: "Inspect the rules for which binaries may be wrapped",
+	Long: `Inspect the rules for which binaries may be wrapped.
+
+ribbin never wraps critical binaries (shells, sudo, ssh, ...) and asks for
+--confirm-system-dir before wrapping in system directories like /usr/bin.
+The first wrap in a shared directory like /usr/local/bin or /opt asks once
+for that directory (see 'ribbin doctor'). Your security settings file,
+~/.config/ribbin/security.jsonc, can add directories to allow, e.g. a tools
+directory on build hosts, directories to forbid, and more shared directories:
+
+  {
+    "allowedDirs": ["/srv/tools/bin"],
+    "forbiddenDirs": ["/opt/vendor/bin"],
+    "sharedDirs": ["/srv/shared"]
+  }
+
+Allowed directories need no confirmation or acknowledgment, even inside a
+system or shared directory.
+Forbidden directories are never wrapped in, even inside an allowed one. The
+critical binaries can't be allowed. The file is refused if other users can
+write to it.`,
+}
+
+var securityShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective security policy",
+	Long: `Show the effective security policy: the built-in critical binaries and
+system and shared directories, plus the directories your security settings
+allow and forbid.
+
+Examples:
+  ribbin security show          # Show the policy
+  ribbin security show --json   # Print it as JSON`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		settingsPath, err := security.SecuritySettingsPath()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		policy, err := security.LoadSecurityConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading security settings: %v\n", err)
+			os.Exit(1)
+		}
+
+		if securityShowJSON {
+			data, err := json.MarshalIndent(struct {
+				SettingsFile     string   `json:"settingsFile"`
+				CriticalBinaries []string `json:"criticalBinaries"`
+				SystemDirs       []string `json:"systemDirs"`
+				SharedDirs       []string `json:"sharedDirs"`
+				AllowedDirs      []string `json:"allowedDirs"`
+				ForbiddenDirs    []string `json:"forbiddenDirs"`
+			}{settingsPath, policy.CriticalBinaries, policy.SystemDirs, nonNil(policy.SharedDirs), nonNil(policy.AllowedDirs), nonNil(policy.ForbiddenDirs)}, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		if _, err := os.Stat(settingsPath); err != nil {
+			fmt.Printf("Security settings: %s (not found, using the defaults)\n", settingsPath)
+		} else {
+			fmt.Printf("Security settings: %s\n", settingsPath)
+		}
+		fmt.Println()
+		fmt.Println("Critical binaries (never wrapped, can't be allowed):")
+		fmt.Printf("  %s\n", strings.Join(policy.CriticalBinaries, ", "))
+		printSecurityDirs("System directories (need --confirm-system-dir):", policy.SystemDirs)
+		printSecurityDirs("Shared directories (need a one-time acknowledgment):", policy.SharedDirs)
+		printSecurityDirs("Allowed directories (from security settings):", policy.AllowedDirs)
+		printSecurityDirs("Forbidden directories (from security settings):", policy.ForbiddenDirs)
+	},
+}
+
+func printSecurityDirs(heading string, dirs []string) {
+	fmt.Println()
+	fmt.Println(heading)
+	if len(dirs) == 0 {
+		fmt.Println("  (none)")
+		return
+	}
+	for _, dir := range dirs {
+		fmt.Printf("  %s\n", dir)
+	}
+}
+
+// nonNil returns s, or an empty slice if s is nil, so it marshals as []
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func init() {
+	securityShowCmd.Flags().BoolVar(&securityShowJSON, "json", false, "Print the policy as JSON")
+	securityCmd.AddCommand(securityShowCmd)
+	rootCmd.AddCommand(securityCmd)
+}
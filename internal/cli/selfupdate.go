@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+	"github.com/happycollision/ribbin/internal/selfupdate"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selfUpdateCheck   bool
+	selfUpdateVersion string
+	selfUpdateForce   bool
+	selfUpdatePath    string
+)
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Update ribbin to the latest release",
+	Long: `Update ribbin to the latest release.
+
+Downloads the release archive for this platform from GitHub, verifies it
+against the release's SHA-256 checksums, checks that the new binary runs, and
+atomically replaces the running ribbin binary with it. Wrappers keep working
+throughout, since they are symlinks to that path.
+
+With --path, the new binary is installed there instead, and every wrapper
+that pointed at the old binary is re-pointed to the new one. The old binary is
+left in place.
+
+Wrappers whose recorded ribbin binary no longer exists (because ribbin was
+moved) are re-pointed to the updated binary as well.
+
+Examples:
+  ribbin self-update                       # Update to the latest release
+  ribbin self-update --check               # Only report whether one is available
+  ribbin self-update --version v0.2.0      # Install a specific release
+  ribbin self-update --path ~/.local/bin/ribbin`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runSelfUpdate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateCheck, "check", false, "Only check whether an update is available")
+	selfUpdateCmd.Flags().StringVar(&selfUpdateVersion, "version", "", "Install this release tag instead of the latest")
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateForce, "force", false, "Reinstall even if already up to date, or over a development build")
+	selfUpdateCmd.Flags().StringVar(&selfUpdatePath, "path", "", "Install the new binary here and re-point wrappers to it")
+}
+
+func runSelfUpdate() error {
+	current, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot locate ribbin executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(current); err == nil {
+		current = resolved
+	}
+
+	target := current
+	if selfUpdatePath != "" {
+		if target, err = filepath.Abs(selfUpdatePath); err != nil {
+			return fmt.Errorf("failed to get absolute path: %w", err)
+		}
+	}
+
+	var release selfupdate.Release
+	if selfUpdateVersion != "" {
+		release = selfupdate.Release{Tag: "v" + strings.TrimPrefix(selfUpdateVersion, "v")}
+	} else if release, err = selfupdate.LatestRelease(); err != nil {
+		return err
+	}
+
+	upToDate := release.IsCurrent(Version)
+	if selfUpdateCheck {
+		if upToDate {
+			fmt.Printf("ribbin %s is up to date\n", Version)
+		} else {
+			fmt.Printf("ribbin %s is available (running %s)\n", release.Version(), Version)
+			fmt.Println("Run 'ribbin self-update' to install it.")
+		}
+		return nil
+	}
+
+	if !selfUpdateForce {
+		if upToDate && target == current {
+			fmt.Printf("ribbin %s is already up to date\n", Version)
+			return nil
+		}
+		if Version == "dev" {
+			return fmt.Errorf("this is a development build; use --force to replace it with release %s", release.Version())
+		}
+	}
+
+	fmt.Printf("Downloading ribbin %s for this platform...\n", release.Version())
+	binary, err := selfupdate.Download(release)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Checksum verified")
+
+	err = selfupdate.Install(binary, target)
+	security.LogPrivilegedOperation("self_update", target, err == nil, err)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Installed ribbin %s at %s\n", release.Version(), target)
+
+	relinked, failed := relinkWrappers(target, current)
+	if relinked > 0 {
+		fmt.Printf("Re-pointed %d wrapper(s) to %s\n", relinked, target)
+	}
+	if target != current {
+		fmt.Printf("The previous binary at %s was left in place.\n", current)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d wrapper(s) could not be re-pointed; run 'ribbin heal' or wrap them again", failed)
+	}
+	return nil
+}
+
+// relinkWrappers re-points registered wrappers at target (see
+// wrap.RelinkShim). Returns how many were relinked and how many failed.
+func relinkWrappers(target, previous string) (relinked, failed int) {
+	registry, err := config.LoadRegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot load registry to re-point wrappers: %v\n", err)
+		return 0, 1
+	}
+
+	for _, binaryPath := range registry.WrapperPaths() {
+		changed, err := wrap.RelinkShim(binaryPath, target, previous)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot re-point %s: %v\n", binaryPath, err)
+			failed++
+			continue
+		}
+		if changed {
+			relinked++
+		}
+	}
+	return relinked, failed
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/serve"
+	"github.com/spf13/cobra"
+)
+
+var serveStatus bool
+var servePort int
+
+var serveCmd = &cobra.Command{
+	Use:   "serve --status",
+	Short: "Serve ribbin's status as JSON over HTTP on localhost",
+	Long: `Run a read-only HTTP server on localhost that answers with ribbin's state
+as JSON, so editor plugins and dashboards can poll it instead of running
+ribbin again and again. --status is required, to make the choice explicit.
+
+Endpoints:
+  GET /status     Activations, snoozes, how many binaries are wrapped
+  GET /registry   The registry, as stored in registry.json
+  GET /audit      Audit events; ?since=24h, ?type=bypass.used, ?limit=50
+
+The server listens on 127.0.0.1 only. When it starts, it writes a new random
+token to ~/.local/state/ribbin/serve-token, readable only by you, and every
+request must send it:
+
+  curl -H "Authorization: Bearer $(cat ~/.local/state/ribbin/serve-token)" \
+    http://127.0.0.1:7471/status
+
+Requests without the token, with a Host other than localhost, or with a
+method other than GET are refused. The token file is removed when the
+server stops.
+
+Examples:
+  ribbin serve --status               # Serve on 127.0.0.1:7471
+  ribbin serve --status --port 9000   # Serve on another port`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !serveStatus {
+			fmt.Fprintf(os.Stderr, "Error: nothing to serve; pass --status to serve ribbin's status\n")
+			os.Exit(1)
+		}
+		if servePort < 0 || servePort > 65535 {
+			fmt.Fprintf(os.Stderr, "Error: --port must be between 0 and 65535\n")
+			os.Exit(1)
+		}
+
+		listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(servePort)))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		token, err := serve.NewToken()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing the token file: %v\n", err)
+			os.Exit(1)
+		}
+		tokenPath, _ := serve.TokenPath()
+		defer os.Remove(tokenPath)
+
+		server := &http.Server{
+			Handler:           serve.Handler(token, Version),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-stop
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(ctx)
+		}()
+
+		fmt.Printf("Serving ribbin status on http://%s\n", listener.Addr())
+		fmt.Printf("Token: %s\n", tokenPath)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Remove(tokenPath)
+			os.Exit(1)
+		}
+		fmt.Println("Stopped serving")
+	},
+}
+
+func init() {
+	serveCmd.Flags().BoolVar(&serveStatus, "status", false,
+		"Serve read-only status, registry and audit endpoints")
+	serveCmd.Flags().IntVar(&servePort, "port", serve.DefaultPort,
+		"Port to listen on, on 127.0.0.1 (0 picks a free one)")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/wrap"
+)
+
+// Shells 'ribbin activate --shell --print-hook' writes a hook for
+var hookShells = []string{"bash", "zsh", "fish", "sh"}
+
+// detectHookShell returns the shell to write a hook for when --print-hook
+// names none: the basename of $SHELL, or "sh" for shells without their own
+// hook
+func detectHookShell() string {
+	name := filepath.Base(os.Getenv("SHELL"))
+	for _, shell := range hookShells {
+		if name == shell {
+			return shell
+		}
+	}
+	return "sh"
+}
+
+// shellHook returns the code that 'eval' runs in a shell to activate ribbin
+// for it and deactivate it again when the shell exits. The activation runs
+// in the shell itself, not in the command substitution printing the hook,
+// so the shell's own PID is recorded.
+//
+// The hook also passes the shell's aliases and functions to 'ribbin doctor
+// --shell', which warns about those keeping wrapped commands from running,
+// or with unalias, has the shell remove them. Only the ones defined before
+// the hook runs are seen.
+//
+// Once binaries are wrapped by shadowing ('ribbin wrap --shadow-signed'),
+// the hook also puts ribbin's shadow directory at the front of PATH, and
+// once a config requires commands to be wrapped, the sentinels that notice
+// them run unwrapped.
+func shellHook(shell string, tags []string, unalias bool) (string, error) {
+	activate := "command ribbin activate --shell"
+	if len(tags) > 0 {
+		activate += " --tag " + strings.Join(tags, ",")
+	}
+	deactivate := "command ribbin deactivate --shell"
+	listing, err := shellDefinitionsCommand(shell)
+	if err != nil {
+		return "", fmt.Errorf("no hook for shell %q (supported: %s)", shell, strings.Join(hookShells, ", "))
+	}
+	check := fmt.Sprintf("%s | command ribbin doctor --shell=%s --from-stdin", listing, shell)
+	switch {
+	case !unalias:
+		check += " --quiet >&2"
+	case shell == "fish":
+		check += " --unalias | source"
+	default:
+		check = `eval "$(` + check + ` --unalias)"`
+	}
+	activate += " >/dev/null\n" + check
+	// Each setup goes in front of the code so far, so the shadow directory
+	// ends up before the sentinels on PATH
+	for _, pathDir := range []func() (string, error){wrap.ShadowDir, wrap.SentinelDir} {
+		if dir, err := pathDir(); err == nil {
+			if _, err := os.Stat(dir); err == nil {
+				activate = prependPathSetup(shell, dir) + "\n" + activate
+			}
+		}
+	}
+
+	switch shell {
+	case "bash":
+		// Run after any EXIT trap already set, rather than replacing it
+		return fmt.Sprintf(`%[1]s
+if [ -z "${__ribbin_exit_hook-}" ]; then
+  __ribbin_exit_hook=1
+  __ribbin_previous_exit_trap() { eval "set -- $(trap -p EXIT)"; printf '%%s' "${3-}"; }
+  trap -- "%[2]s >/dev/null 2>&1; $(__ribbin_previous_exit_trap)" EXIT
+  unset -f __ribbin_previous_exit_trap
+fi
+`, activate, deactivate), nil
+	case "zsh":
+		return fmt.Sprintf(`%s
+__ribbin_deactivate() { %s >/dev/null 2>&1 }
+(( ${zshexit_functions[(Ie)__ribbin_deactivate]} )) || zshexit_functions+=(__ribbin_deactivate)
+`, activate, deactivate), nil
+	case "fish":
+		return fmt.Sprintf(`%s
+function __ribbin_deactivate --on-event fish_exit
+    %s >/dev/null 2>&1
+end
+`, activate, deactivate), nil
+	case "sh":
+		// POSIX sh can't list the trap already set, so this replaces it
+		return fmt.Sprintf(`%s
+trap '%s >/dev/null 2>&1' EXIT
+`, activate, deactivate), nil
+	default:
+		return "", fmt.Errorf("no hook for shell %q (supported: %s)", shell, strings.Join(hookShells, ", "))
+	}
+}
+
+// prependPathSetup returns the code that puts dir at the front of PATH in
+// shell, unless PATH has it already
+func prependPathSetup(shell, dir string) string {
+	quoted := wrap.ShellQuote(dir)
+	if shell == "fish" {
+		return fmt.Sprintf("contains -- %[1]s $PATH; or set -gx PATH %[1]s $PATH", quoted)
+	}
+	return fmt.Sprintf(`case ":$PATH:" in *:%[1]s:*) ;; *) PATH=%[1]s:$PATH; export PATH ;; esac`, quoted)
+}
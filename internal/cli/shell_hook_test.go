@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+	"github.com/happycollision/ribbin/internal/wrap"
+)
+
+func TestShellHook(t *testing.T) {
+	// runHook evaluates the hook in shell with a ribbin that logs how it's
+	// called, runs script after it, and returns the log
+	runHook := func(t *testing.T, shell, script string, tags []string, unalias bool) string {
+		t.Helper()
+		shellPath, err := exec.LookPath(shell)
+		if err != nil {
+			t.Skipf("%s not installed", shell)
+		}
+		hook, err := shellHook(shell, tags, unalias)
+		if err != nil {
+			t.Fatalf("shellHook(%q) error: %v", shell, err)
+		}
+
+		dir := t.TempDir()
+		logPath := filepath.Join(dir, "calls.log")
+		fakeRibbin := "#!/bin/sh\necho \"$*\" >> " + logPath + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "ribbin"), []byte(fakeRibbin), 0755); err != nil {
+			t.Fatal(err)
+		}
+		hookPath := filepath.Join(dir, "hook")
+		if err := os.WriteFile(hookPath, []byte(hook), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := exec.Command(shellPath, "-c", script+"\neval \"$(cat "+hookPath+")\"\necho running >> "+logPath)
+		cmd.Env = append(os.Environ(), "PATH="+dir+":"+os.Getenv("PATH"))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s failed: %v\n%s", shell, err, output)
+		}
+		calls, _ := os.ReadFile(logPath)
+		return string(calls)
+	}
+
+	t.Run("bash activates, then deactivates on exit after the existing trap", func(t *testing.T) {
+		calls := runHook(t, "bash", `trap 'echo "previous trap" >> "$(dirname "$(command -v ribbin)")/calls.log"' EXIT`, []string{"danger"}, false)
+		want := "activate --shell --tag danger\ndoctor --shell=bash --from-stdin --quiet\nrunning\ndeactivate --shell\nprevious trap\n"
+		if calls != want {
+			t.Errorf("calls = %q, want %q", calls, want)
+		}
+	})
+
+	t.Run("bash hook evaluated twice deactivates once", func(t *testing.T) {
+		calls := runHook(t, "bash", `eval "$(cat "$(dirname "$(command -v ribbin)")/hook")"`, nil, false)
+		if strings.Count(calls, "deactivate --shell") != 1 {
+			t.Errorf("calls = %q, want one deactivation", calls)
+		}
+	})
+
+	t.Run("sh activates and deactivates on exit", func(t *testing.T) {
+		calls := runHook(t, "sh", "", nil, false)
+		if calls != "activate --shell\ndoctor --shell=sh --from-stdin --quiet\nrunning\ndeactivate --shell\n" {
+			t.Errorf("calls = %q", calls)
+		}
+	})
+
+	t.Run("bash hook with --unalias asks for the code removing shadows", func(t *testing.T) {
+		calls := runHook(t, "bash", "", nil, true)
+		if !strings.Contains(calls, "doctor --shell=bash --from-stdin --unalias\n") {
+			t.Errorf("calls = %q", calls)
+		}
+	})
+
+	t.Run("zsh and fish hooks", func(t *testing.T) {
+		for _, shell := range []string{"zsh", "fish"} {
+			hook, err := shellHook(shell, nil, false)
+			if err != nil || !strings.Contains(hook, "command ribbin deactivate --shell") {
+				t.Errorf("shellHook(%q) = %q, %v", shell, hook, err)
+			}
+		}
+	})
+
+	t.Run("hook puts the shadow directory first on PATH once it exists", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		if hook, _ := shellHook("sh", nil, false); strings.Contains(hook, "PATH=") {
+			t.Errorf("hook sets PATH without a shadow directory:\n%s", hook)
+		}
+		dir, err := wrap.ShadowDir()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		hook, _ := shellHook("sh", nil, false)
+		if !strings.Contains(hook, prependPathSetup("sh", dir)) {
+			t.Errorf("hook doesn't set PATH:\n%s", hook)
+		}
+
+		// Evaluated twice, the directory is added once
+		setup := prependPathSetup("sh", dir)
+		output, err := exec.Command("sh", "-c", setup+"\n"+setup+"\necho \"$PATH\"").Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path := strings.TrimSpace(string(output)); !strings.HasPrefix(path, dir+":") || strings.Count(path, dir) != 1 {
+			t.Errorf("PATH = %q, want %s first, once", path, dir)
+		}
+	})
+
+	t.Run("hook puts the shadow directory before the sentinels", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		shadowDir, _ := wrap.ShadowDir()
+		sentinelDir, _ := wrap.SentinelDir()
+		for _, dir := range []string{shadowDir, sentinelDir} {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		hook, _ := shellHook("sh", nil, false)
+		shadow := strings.Index(hook, prependPathSetup("sh", shadowDir))
+		sentinel := strings.Index(hook, prependPathSetup("sh", sentinelDir))
+		if shadow < 0 || sentinel < 0 || sentinel > shadow {
+			t.Errorf("hook should prepend the sentinels, then the shadow directory:\n%s", hook)
+		}
+	})
+
+	t.Run("unknown shell", func(t *testing.T) {
+		if _, err := shellHook("tcsh", nil, false); err == nil {
+			t.Error("shellHook(tcsh) should fail")
+		}
+	})
+}
+
+func TestShellShadows(t *testing.T) {
+	registry := &config.Registry{Wrappers: map[string]config.WrapperEntry{}}
+	for _, path := range []string{"/usr/local/bin/npm", "/usr/local/bin/rm", "/usr/local/bin/tsc", "/usr/local/bin/git"} {
+		registry.AddWrapper(config.WrapperEntry{Original: path})
+	}
+
+	// listing runs shell's alias and function listing after script
+	listing := func(t *testing.T, shell, script string) []shellDefinition {
+		t.Helper()
+		shellPath, err := exec.LookPath(shell)
+		if err != nil {
+			t.Skipf("%s not installed", shell)
+		}
+		command, err := shellDefinitionsCommand(shell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := exec.Command(shellPath, "-c", script+"\n"+command).Output()
+		if err != nil {
+			t.Fatalf("%s failed: %v", shell, err)
+		}
+		return parseShellDefinitions(strings.NewReader(string(output)))
+	}
+	script := `alias npm='pnpm --silent'
+alias rm='rm -i'
+alias git="/usr/local/bin/git -c 'color.ui=auto'"
+alias ls='ls --color'
+tsc() { :; }`
+
+	for _, shell := range []string{"bash", "sh"} {
+		t.Run(shell, func(t *testing.T) {
+			var got []string
+			for _, shadow := range findShellShadows(listing(t, shell, script), registry) {
+				got = append(got, fmt.Sprintf("%s %s %v", shadow.Kind, shadow.Name, shadow.Bypasses))
+			}
+			want := []string{"alias git false", "alias npm true", "alias rm false"}
+			if shell == "bash" {
+				want = append(want, "function tsc true")
+			}
+			if strings.Join(got, "; ") != strings.Join(want, "; ") {
+				t.Errorf("shadows = %q, want %q", got, want)
+			}
+		})
+	}
+
+	t.Run("zsh and dash listings", func(t *testing.T) {
+		defs := parseShellDefinitions(strings.NewReader("alias -g L='| less'\nnpm=pnpm\ndeclare -fx tsc\ndeclare -f \n"))
+		want := []shellDefinition{{Kind: "alias", Name: "L", Value: "| less"}, {Kind: "alias", Name: "npm", Value: "pnpm"}, {Kind: "function", Name: "tsc"}}
+		if fmt.Sprint(defs) != fmt.Sprint(want) {
+			t.Errorf("defs = %v, want %v", defs, want)
+		}
+	})
+
+	t.Run("removal code", func(t *testing.T) {
+		alias := shellShadow{shellDefinition: shellDefinition{Kind: "alias", Name: "npm"}}
+		function := shellShadow{shellDefinition: shellDefinition{Kind: "function", Name: "tsc"}}
+		for _, tt := range []struct {
+			shell  string
+			shadow shellShadow
+			want   string
+		}{
+			{"bash", alias, "unalias npm"},
+			{"zsh", function, "unset -f tsc"},
+			{"fish", function, "functions -e tsc"},
+		} {
+			if got := removeShadowCommand(tt.shell, tt.shadow); got != tt.want {
+				t.Errorf("removeShadowCommand(%s, %s) = %q, want %q", tt.shell, tt.shadow.Name, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestDetectHookShell(t *testing.T) {
+	for shell, want := range map[string]string{
+		"/bin/bash":           "bash",
+		"/usr/local/bin/fish": "fish",
+		"/bin/zsh":            "zsh",
+		"/bin/dash":           "sh",
+		"":                    "sh",
+	} {
+		t.Setenv("SHELL", shell)
+		if got := detectHookShell(); got != want {
+			t.Errorf("SHELL=%q: detectHookShell() = %q, want %q", shell, got, want)
+		}
+	}
+}
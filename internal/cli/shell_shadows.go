@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/wrap"
+)
+
+// shellDefinition is an alias or function defined in a shell
+type shellDefinition struct {
+	Kind string // "alias" or "function"
+	Name string
+	// Value is what an alias expands to; it is empty for functions
+	Value string
+}
+
+// shellShadow is an alias or function named after a wrapped command, which
+// the shell runs instead of looking the command up in PATH
+type shellShadow struct {
+	shellDefinition
+	// Bypasses is true unless the alias is known to run the wrapper anyway,
+	// like alias rm='rm -i'. A function's body isn't listed, so a function
+	// always counts as bypassing.
+	Bypasses bool
+}
+
+// shellDefinitionsCommand returns shell code that lists the aliases and
+// functions defined in shell, in the form parseShellDefinitions reads
+func shellDefinitionsCommand(shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return "{ alias -p; declare -F; }", nil
+	case "zsh":
+		return "{ alias -L; printf 'declare -f %s\\n' ${(k)functions}; }", nil
+	case "fish":
+		// fish aliases are functions
+		return "printf 'declare -f %s\\n' (functions -n)", nil
+	case "sh":
+		// POSIX sh has no way to list functions
+		return "alias", nil
+	default:
+		return "", fmt.Errorf("can't list the aliases of shell %q (supported: %s)", shell, strings.Join(hookShells, ", "))
+	}
+}
+
+// listShellDefinitions starts shell interactively, so it reads its startup
+// files, and returns the aliases and functions they define
+func listShellDefinitions(shell string) ([]shellDefinition, error) {
+	listing, err := shellDefinitionsCommand(shell)
+	if err != nil {
+		return nil, err
+	}
+	shellPath := os.Getenv("SHELL")
+	if filepath.Base(shellPath) != shell {
+		if shellPath, err = exec.LookPath(shell); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// Startup files complain about the missing terminal on stderr; only the
+	// listing matters
+	output, err := exec.CommandContext(ctx, shellPath, "-i", "-c", listing).Output()
+	if err != nil {
+		return nil, fmt.Errorf("running %s: %w", shellPath, err)
+	}
+	return parseShellDefinitions(strings.NewReader(string(output))), nil
+}
+
+// parseShellDefinitions reads alias listings, as printed by 'alias' in the
+// shells ribbin supports, and function listings as printed by 'declare -F'.
+// Lines it doesn't recognize are skipped.
+func parseShellDefinitions(r io.Reader) []shellDefinition {
+	var defs []shellDefinition
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// declare -f name, or declare -fx name for an exported function
+		if fields[0] == "declare" {
+			if len(fields) >= 3 && strings.HasPrefix(fields[1], "-f") {
+				defs = append(defs, shellDefinition{Kind: "function", Name: fields[len(fields)-1]})
+			}
+			continue
+		}
+
+		// alias name='value' (bash, zsh's alias -L) or name='value' (dash);
+		// zsh marks global and suffix aliases with -g and -s
+		rest := line
+		if fields[0] == "alias" {
+			rest = strings.TrimSpace(strings.TrimPrefix(rest, "alias"))
+			for strings.HasPrefix(rest, "-") {
+				flag, after, _ := strings.Cut(rest, " ")
+				rest = strings.TrimSpace(after)
+				if flag == "--" {
+					break
+				}
+			}
+		}
+		name, value, ok := strings.Cut(rest, "=")
+		if !ok || name == "" || strings.ContainsAny(name, " \t'\"") {
+			continue
+		}
+		defs = append(defs, shellDefinition{Kind: "alias", Name: name, Value: shellUnquote(value)})
+	}
+	return defs
+}
+
+// shellUnquote removes the quoting a shell prints around an alias's value:
+// single quotes, double quotes and backslashes
+func shellUnquote(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				b.WriteString(s[i+1:])
+				return b.String()
+			}
+			b.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case '"':
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\", s[i+1]) >= 0 {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// findShellShadows returns the definitions named after a command wrapped
+// in the registry, sorted by name
+func findShellShadows(defs []shellDefinition, registry *config.Registry) []shellShadow {
+	var shadows []shellShadow
+	seen := make(map[shellDefinition]bool)
+	for _, def := range defs {
+		if seen[def] || !registry.HasCommand(def.Name) {
+			continue
+		}
+		seen[def] = true
+		shadow := shellShadow{shellDefinition: def, Bypasses: true}
+		if def.Kind == "alias" {
+			command := aliasCommand(def.Value)
+			_, wrapped := registry.Wrappers[filepath.Clean(command)]
+			shadow.Bypasses = command != def.Name && !(filepath.IsAbs(command) && wrapped)
+		}
+		shadows = append(shadows, shadow)
+	}
+	sort.SliceStable(shadows, func(i, j int) bool { return shadows[i].Name < shadows[j].Name })
+	return shadows
+}
+
+// aliasCommand returns the command an alias's value runs, skipping variable
+// assignments, 'command', and the backslash that stops alias expansion
+func aliasCommand(value string) string {
+	for _, word := range strings.Fields(value) {
+		if word == "command" || (strings.Contains(word, "=") && !strings.HasPrefix(word, "=")) {
+			continue
+		}
+		return strings.TrimPrefix(word, `\`)
+	}
+	return ""
+}
+
+// describeShadow returns a line explaining what running shadow's name does
+func describeShadow(shadow shellShadow) string {
+	if shadow.Kind == "function" {
+		return fmt.Sprintf("function %s runs instead of the wrapped %s, unless it calls 'command %s'", shadow.Name, shadow.Name, shadow.Name)
+	}
+	definition := fmt.Sprintf("alias %s=%s", shadow.Name, wrap.ShellQuote(shadow.Value))
+	if !shadow.Bypasses {
+		return fmt.Sprintf("%s still runs the wrapped %s", definition, shadow.Name)
+	}
+	return fmt.Sprintf("%s runs %s, not the wrapped %s", definition, aliasCommand(shadow.Value), shadow.Name)
+}
+
+// removeShadowCommand returns the code that removes shadow from shell
+func removeShadowCommand(shell string, shadow shellShadow) string {
+	name := wrap.ShellQuote(shadow.Name)
+	switch {
+	case shadow.Kind == "alias":
+		return "unalias " + name
+	case shell == "fish":
+		return "functions -e " + name
+	default:
+		return "unset -f " + name
+	}
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/happycollision/ribbin/internal/cli/render"
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var restoreSnapshotDryRun bool
+
+var restoreSnapshotCmd = &cobra.Command{
+	Use:   "restore-snapshot [id]",
+	Short: "Bring a directory back to a snapshot taken before a bulk wrap",
+	Long: `Bring a directory back to a snapshot taken before a bulk wrap.
+
+Before 'ribbin wrap' touches more than snapshotThreshold binaries (10 by
+default, set in ~/.config/ribbin/settings.jsonc) in one directory, it
+records what the directory holds: each file's name, mode and hash, and
+each symlink's target. Restoring a snapshot unwraps the binaries wrapped
+since, recreates symlinks and resets modes. A file whose contents changed
+some other way can't be restored, as snapshots don't keep contents, and
+files added since are listed but left in place.
+
+Without an id, lists the snapshots. The last 20 are kept.
+
+Examples:
+  ribbin restore-snapshot                                  # List snapshots
+  ribbin restore-snapshot 20261017-091200-a1b2c3 --dry-run # Show what would change
+  ribbin restore-snapshot 20261017-091200-a1b2c3`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runRestoreSnapshot,
+}
+
+func init() {
+	restoreSnapshotCmd.Flags().BoolVar(&restoreSnapshotDryRun, "dry-run", false, "Show what would change without changing anything")
+	rootCmd.AddCommand(restoreSnapshotCmd)
+}
+
+func runRestoreSnapshot(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return listSnapshots()
+	}
+
+	snap, err := wrap.LoadSnapshot(args[0])
+	if err != nil {
+		return err
+	}
+	registry, err := config.LoadRegistry()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+	registryBefore := registry.CloneWrappers()
+
+	result := wrap.RestoreSnapshot(snap, registry, restoreSnapshotDryRun)
+	if !restoreSnapshotDryRun && len(result.Restored) > 0 {
+		updateRegistryOrExit(func(latest *config.Registry) {
+			latest.MergeWrapperChanges(registryBefore, registry.Wrappers)
+		})
+	}
+
+	verb := "Restored"
+	if restoreSnapshotDryRun {
+		verb = "Would restore"
+	}
+	for _, path := range result.Restored {
+		fmt.Printf("%s %s\n", verb, path)
+	}
+	for _, failure := range result.Failed {
+		fmt.Fprintf(os.Stderr, "Cannot restore %s\n", failure)
+	}
+	if len(result.Extra) > 0 {
+		fmt.Printf("\nAdded since the snapshot, left in place:\n")
+		for _, path := range result.Extra {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+	fmt.Printf("\n%s: %d restored, %d unchanged, %d failed\n", snap.Dir, len(result.Restored), result.Unchanged, len(result.Failed))
+	if len(result.Failed) > 0 {
+		return errors.New("some entries couldn't be restored")
+	}
+	return nil
+}
+
+// listSnapshots prints the saved snapshots, newest first
+func listSnapshots() error {
+	snapshots, err := wrap.ListSnapshots()
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshots")
+		return nil
+	}
+	r := render.Stdout()
+	table := r.Table(0, "ID", "TAKEN", "ENTRIES", "DIRECTORY")
+	table.TrimLeft = true
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snap := snapshots[i]
+		table.Row(snap.ID, formatTimeAgo(snap.TakenAt), fmt.Sprint(len(snap.Entries)), snap.Dir)
+	}
+	table.Flush()
+	return nil
+}
+
+// snapshotBulkDirs snapshots each directory where paths has more binaries
+// than the snapshotThreshold setting, before they are wrapped. Failing to
+// snapshot only warns.
+func snapshotBulkDirs(paths []string) {
+	threshold := config.DefaultSnapshotThreshold
+	if settings, err := config.LoadSettings(); err == nil && settings.SnapshotThreshold != nil {
+		threshold = *settings.SnapshotThreshold
+	}
+	for _, dir := range wrap.BulkDirs(paths, threshold) {
+		snap, err := wrap.TakeSnapshot(dir, "ribbin wrap")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot snapshot %s: %v\n", dir, err)
+			continue
+		}
+		fmt.Printf("Snapshot %s of %s taken; undo this wrap there with 'ribbin restore-snapshot %s'\n", snap.ID, dir, snap.ID)
+	}
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var snoozeFor time.Duration
+var snoozeAll bool
+var snoozeClear bool
+
+// maxSnoozeDuration caps how long a snooze may last, so a forgotten snooze
+// behaves like a forgotten RIBBIN_BYPASS for at most a day.
+const maxSnoozeDuration = 24 * time.Hour
+
+var snoozeCmd = &cobra.Command{
+	Use:   "snooze [commands...]",
+	Short: "Temporarily bypass wrappers for a limited time",
+	Long: `Temporarily bypass wrappers for one or more commands.
+
+While a command is snoozed, its wrapper passes through to the original
+command and prints a reminder with the time remaining. Snoozes are stored
+in your user registry and expire on their own, unlike RIBBIN_BYPASS=1,
+which is easy to export in a shell and forget about.
+
+With no arguments, lists active snoozes.
+
+Examples:
+  ribbin snooze npm --for 30m         # Bypass npm for 30 minutes
+  ribbin snooze npm yarn --for 1h     # Bypass several commands
+  ribbin snooze --all --for 15m       # Bypass every wrapper
+  ribbin snooze                       # List active snoozes
+  ribbin snooze --clear npm           # End the npm snooze early
+  ribbin snooze --clear               # End all snoozes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		printGlobalWarningIfActive()
+
+		if snoozeAll && len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: specify commands or --all, not both\n")
+			os.Exit(1)
+		}
+
+		now := time.Now()
+		targets := args
+		if snoozeAll {
+			targets = []string{config.SnoozeAllKey}
+		}
+
+		if snoozeClear {
+			updateRegistryOrExit(func(registry *config.Registry) {
+				if len(targets) == 0 {
+					registry.ClearSnoozes()
+				}
+				for _, name := range targets {
+					registry.RemoveSnooze(name)
+				}
+				registry.PruneExpiredSnoozes(now)
+			})
+			if len(targets) == 0 {
+				fmt.Println("Cleared all snoozes")
+			} else {
+				for _, name := range targets {
+					fmt.Printf("Cleared snooze for %s\n", snoozeLabel(name))
+				}
+			}
+			return
+		}
+
+		if len(targets) == 0 {
+			registry, err := config.LoadRegistry()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading registry: %v\n", err)
+				os.Exit(ExitCode(err))
+			}
+			registry.PruneExpiredSnoozes(now)
+			printSnoozes(registry, now)
+			return
+		}
+
+		if !cmd.Flags().Changed("for") {
+			fmt.Fprintf(os.Stderr, "Error: --for is required (e.g. --for 30m)\n")
+			os.Exit(1)
+		}
+		if snoozeFor <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: --for must be a positive duration\n")
+			os.Exit(1)
+		}
+		if snoozeFor > maxSnoozeDuration {
+			fmt.Fprintf(os.Stderr, "Error: --for cannot exceed %s (use 'ribbin deactivate' or 'ribbin unwrap' for longer breaks)\n", maxSnoozeDuration)
+			os.Exit(1)
+		}
+
+		var notWrapped []string
+		entries := make(map[string]config.SnoozeEntry)
+		updateRegistryOrExit(func(registry *config.Registry) {
+			registry.PruneExpiredSnoozes(now)
+			notWrapped = nil
+			for _, name := range targets {
+				if name != config.SnoozeAllKey {
+					if !registry.HasCommand(name) {
+						notWrapped = append(notWrapped, name)
+					}
+				}
+				entries[name] = registry.AddSnooze(name, snoozeFor)
+			}
+		})
+
+		for _, name := range notWrapped {
+			fmt.Fprintf(os.Stderr, "Warning: %s is not currently wrapped\n", name)
+		}
+		if enforcedBy := wrap.EnforcedBy(); enforcedBy != "" {
+			fmt.Fprintf(os.Stderr, "Warning: snoozes are ignored here, bypasses are disabled by %s\n", enforcedBy)
+		}
+		for _, name := range targets {
+			entry := entries[name]
+			fmt.Printf("Snoozed %s until %s (%s)\n", snoozeLabel(name), entry.Until.Format("15:04"), entry.Remaining(now))
+		}
+	},
+}
+
+// snoozeLabel returns a display name for a Snoozes key.
+func snoozeLabel(name string) string {
+	if name == config.SnoozeAllKey {
+		return "all commands"
+	}
+	return name
+}
+
+// printSnoozes lists active snoozes in a stable order.
+func printSnoozes(registry *config.Registry, now time.Time) {
+	if len(registry.Snoozes) == 0 {
+		fmt.Println("No active snoozes")
+		return
+	}
+
+	names := make([]string, 0, len(registry.Snoozes))
+	for name := range registry.Snoozes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("Active snoozes:")
+	for _, name := range names {
+		entry := registry.Snoozes[name]
+		fmt.Printf("  %s: %s left (until %s)\n", snoozeLabel(name), entry.Remaining(now), entry.Until.Format("15:04"))
+	}
+}
+
+func init() {
+	snoozeCmd.Flags().DurationVar(&snoozeFor, "for", 0, "How long to snooze (e.g. 30m, 2h)")
+	snoozeCmd.Flags().BoolVar(&snoozeAll, "all", false, "Snooze every wrapped command")
+	snoozeCmd.Flags().BoolVar(&snoozeClear, "clear", false, "End snoozes early instead of creating one")
+}
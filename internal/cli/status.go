@@ -3,12 +3,20 @@ package cli
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/happycollision/ribbin/internal/cli/render"
 	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+	"github.com/happycollision/ribbin/internal/wrap"
 	"github.com/spf13/cobra"
 )
 
+var statusStats bool
+
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show ribbin activation status",
@@ -19,9 +27,19 @@ Displays the current state of ribbin including:
   - Shell activation(s) with PIDs
   - Config activation(s) with paths
   - Wrapped tools and their mappings
+  - Wrappers whose "expires" date has passed
+  - Sidecars in the "searchPaths" of the nearest config and your settings
+    that aren't in the registry
+
+With --stats, it also shows how often each wrapper was hit, per project
+and across all of them: the times it blocked, passed through or
+redirected the command, when it was last hit, and the commands that ran
+it most often when it blocked. They come from the execution log, which
+is kept while metrics are enabled (see 'ribbin metrics').
 
-Example:
-  ribbin status`,
+Examples:
+  ribbin status
+  ribbin status --stats`,
 	Run: func(cmd *cobra.Command, args []string) {
 		printGlobalWarningIfActive()
 
@@ -29,57 +47,78 @@ Example:
 		registry, err := config.LoadRegistry()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error loading registry: %v\n", err)
-			os.Exit(1)
+			os.Exit(ExitCode(err))
 		}
 
-		// Prune dead shell activations for accurate status
-		registry.PruneDeadShellActivations()
+		r := render.Stdout()
+		r.Heading("Ribbin Status")
+		r.Println("=============")
+		r.Println()
 
-		fmt.Println("Ribbin Status")
-		fmt.Println("=============")
-		fmt.Println()
+		if name, source, err := security.ActiveProfile(); err == nil && name != security.DefaultProfile {
+			r.Printf("Profile: %s (set by %s)\n\n", name, source)
+		}
 
 		// Activation section
-		fmt.Println("Activation:")
+		r.Heading("Activation:")
 
 		// Global status
 		if registry.GlobalActive {
-			fmt.Println("  Global:  active")
+			r.Field(2, 7, "Global", r.Paint(render.Yellow, "active"))
 		} else {
-			fmt.Println("  Global:  inactive")
+			r.Field(2, 7, "Global", r.Paint(render.Dim, "inactive"))
 		}
 
 		// Shell activations
 		if len(registry.ShellActivations) == 0 {
-			fmt.Println("  Shell:   inactive")
+			r.Field(2, 7, "Shell", r.Paint(render.Dim, "inactive"))
 		} else {
-			fmt.Printf("  Shell:   %d active\n", len(registry.ShellActivations))
+			r.Field(2, 7, "Shell", r.Paint(render.Green, fmt.Sprintf("%d active", len(registry.ShellActivations))))
 			for pid, entry := range registry.ShellActivations {
 				ago := formatTimeAgo(entry.ActivatedAt)
-				fmt.Printf("    - PID %d (activated %s)\n", pid, ago)
+				r.Printf("    - PID %d (activated %s%s)\n", pid, ago, activationTagsNote(entry.Tags))
 			}
 		}
 
 		// Config activations
 		if len(registry.ConfigActivations) == 0 {
-			fmt.Println("  Configs: none active")
+			r.Field(2, 7, "Configs", r.Paint(render.Dim, "none active"))
 		} else {
-			fmt.Printf("  Configs: %d active\n", len(registry.ConfigActivations))
+			r.Field(2, 7, "Configs", r.Paint(render.Green, fmt.Sprintf("%d active", len(registry.ConfigActivations))))
 			for path, entry := range registry.ConfigActivations {
 				ago := formatTimeAgo(entry.ActivatedAt)
-				fmt.Printf("    - %s (activated %s)\n", path, ago)
+				missing := ""
+				if _, err := os.Stat(path); os.IsNotExist(err) {
+					missing = r.Paint(render.Red, ", missing - run 'ribbin deactivate --stale'")
+				}
+				r.Printf("    - %s (activated %s%s%s)\n", path, ago, activationTagsNote(entry.Tags)+activationScopesNote(entry.Scopes), missing)
+			}
+		}
+
+		// Snoozes
+		registry.PruneExpiredSnoozes(time.Now())
+		if len(registry.Snoozes) > 0 {
+			r.Field(2, 7, "Snoozed", r.Paint(render.Yellow, fmt.Sprintf("%d", len(registry.Snoozes))))
+			snoozed := make([]string, 0, len(registry.Snoozes))
+			for name := range registry.Snoozes {
+				snoozed = append(snoozed, name)
+			}
+			sort.Strings(snoozed)
+			for _, name := range snoozed {
+				r.Printf("    - %s (%s left)\n", snoozeLabel(name), registry.Snoozes[name].Remaining(time.Now()))
 			}
 		}
 
 		// Wrapped tools section - separate known from discovered orphans
-		fmt.Println()
-		fmt.Println("Wrapped Tools:")
+		r.Println()
+		r.Heading("Wrapped Tools:")
 
 		var knownWrappers []config.WrapperEntry
 		var discoveredOrphans []config.WrapperEntry
 
-		for _, entry := range registry.Wrappers {
-			if entry.Config == "(discovered orphan)" {
+		for _, path := range registry.WrapperPaths() {
+			entry := registry.Wrappers[path]
+			if entry.Config == discoveredOrphanConfig {
 				discoveredOrphans = append(discoveredOrphans, entry)
 			} else {
 				knownWrappers = append(knownWrappers, entry)
@@ -87,36 +126,234 @@ Example:
 		}
 
 		if len(knownWrappers) == 0 && len(discoveredOrphans) == 0 {
-			fmt.Println("  (none)")
+			r.Println("  (none)")
 		} else {
 			if len(knownWrappers) > 0 {
-				fmt.Println("  Known wrappers:")
-				for _, entry := range knownWrappers {
-					fmt.Printf("    %s\n", entry.Original)
-					fmt.Printf("      (from %s)\n", entry.Config)
-				}
+				printKnownWrappers(r, knownWrappers)
 			}
 
 			if len(discoveredOrphans) > 0 {
 				if len(knownWrappers) > 0 {
-					fmt.Println()
+					r.Println()
 				}
-				fmt.Printf("  ⚠️  Discovered orphans (%d):\n", len(discoveredOrphans))
+				r.Println(r.Paint(render.Yellow, fmt.Sprintf("  ⚠️  Discovered orphans (%d):", len(discoveredOrphans))))
 				for _, entry := range discoveredOrphans {
-					fmt.Printf("    %s\n", entry.Original)
+					r.Printf("    %s\n", entry.Original)
 				}
-				fmt.Println()
-				fmt.Println("  These were found by 'ribbin find' but not created by a config file.")
-				fmt.Println("  To clean them up, run:")
-				fmt.Println("    ribbin unwrap --all")
+				r.Println()
+				r.Println("  These were found by 'ribbin find' but not created by a config file.")
+				r.Println("  To restore the originals, or keep them wrapped under a config, run:")
+				r.Println("    ribbin find --restore <dir>")
+				r.Println("    ribbin find --adopt <config> <dir>")
 			}
 		}
 
-		fmt.Println()
-		fmt.Println("💡 Tip: Run 'ribbin find --all' to search your entire system for unknown sidecars.")
+		printExpiredWrappers(r, registry)
+		printSearchPathOrphans(r, registry)
+		if statusStats {
+			printUsageStats(r)
+		}
+
+		r.Println()
+		r.Println(r.Paint(render.Dim, "💡 Tip: Run 'ribbin find --all' to search your entire system for unknown sidecars."))
 	},
 }
 
+func init() {
+	statusCmd.Flags().BoolVar(&statusStats, "stats", false, "Show how often each wrapper was hit, from the execution log")
+}
+
+// printUsageStats lists the interceptions in the execution log by project
+// and wrapper, then totals them across projects
+func printUsageStats(r *render.Renderer) {
+	r.Println()
+	r.Heading("Usage Statistics:")
+	records, err := wrap.LoadExecRecords()
+	if err != nil {
+		r.Println(r.Paint(render.Red, fmt.Sprintf("  Cannot read the execution log: %v", err)))
+		return
+	}
+	if len(records) == 0 {
+		settings, err := config.LoadSettings()
+		if err == nil && !settings.MetricsEnabled() {
+			settingsPath, _ := config.SettingsPath()
+			r.Printf("  (none: the execution log is only kept with \"metrics\": {\"enabled\": true} in %s)\n", settingsPath)
+		} else {
+			r.Println("  (none)")
+		}
+		return
+	}
+	r.Printf("  Since %s\n", records[0].Time.Local().Format("2006-01-02 15:04"))
+
+	perConfig := wrap.AggregateExecStats(records, true)
+	for i := 0; i < len(perConfig); {
+		configPath := perConfig[i].Config
+		j := i
+		for j < len(perConfig) && perConfig[j].Config == configPath {
+			j++
+		}
+		r.Println()
+		if configPath == "" {
+			configPath = "(no config)"
+		}
+		r.Printf("  %s\n", configPath)
+		printStatsTable(r, perConfig[i:j])
+		i = j
+	}
+
+	r.Println()
+	r.Println("  All projects")
+	printStatsTable(r, wrap.AggregateExecStats(records, false))
+}
+
+// printStatsTable lists the stats of each wrapper, each followed by the
+// commands that ran it most often when it blocked
+func printStatsTable(r *render.Renderer, stats []wrap.WrapperStats) {
+	table := r.Table(4, "COMMAND", "BLOCKED", "PASSED", "REDIRECTED", "LAST HIT")
+	for _, s := range stats {
+		table.Row(s.Command, fmt.Sprint(s.Blocked), fmt.Sprint(s.Passed), fmt.Sprint(s.Redirected), formatTimeAgo(s.LastHit))
+	}
+	table.Flush()
+	for _, s := range stats {
+		if len(s.Parents) == 0 {
+			continue
+		}
+		parents := make([]string, len(s.Parents))
+		for i, p := range s.Parents {
+			parents[i] = fmt.Sprintf("%s (%d)", render.Truncate(p.Command, 40), p.Count)
+		}
+		r.Printf("    %s blocked from: %s\n", s.Command, strings.Join(parents, ", "))
+	}
+}
+
+// printExpiredWrappers lists the wrappers past their "expires" date in the
+// configs that are active or have binaries wrapped, so they can be removed.
+// Prints nothing if there are none.
+func printExpiredWrappers(r *render.Renderer, registry *config.Registry) {
+	seen := make(map[string]bool)
+	var configPaths []string
+	addConfig := func(path string) {
+		if path != "" && path != discoveredOrphanConfig && !seen[path] {
+			seen[path] = true
+			configPaths = append(configPaths, path)
+		}
+	}
+	for path := range registry.ConfigActivations {
+		addConfig(path)
+	}
+	for _, entry := range registry.Wrappers {
+		addConfig(entry.Config)
+	}
+	sort.Strings(configPaths)
+
+	now := time.Now()
+	table := r.Table(4)
+	expired := 0
+	for _, configPath := range configPaths {
+		projectConfig, err := config.LoadProjectConfig(configPath)
+		if err != nil {
+			continue
+		}
+		describe := func(name string, wrapper config.WrapperConfig, where string) {
+			if !wrapper.Expired(now) {
+				return
+			}
+			behavior := "warns"
+			if wrapper.ActionAfterExpiry() == config.ExpiredPassthrough {
+				behavior = "passes through"
+			}
+			table.Row(fmt.Sprintf("%s%s (expired %s, now %s)", name, where, wrapper.Expires, behavior), "in "+configPath)
+			expired++
+		}
+		for _, name := range sortedWrapperNames(projectConfig.Wrappers) {
+			describe(name, projectConfig.Wrappers[name], "")
+		}
+		scopeNames := make([]string, 0, len(projectConfig.Scopes))
+		for scopeName := range projectConfig.Scopes {
+			scopeNames = append(scopeNames, scopeName)
+		}
+		sort.Strings(scopeNames)
+		for _, scopeName := range scopeNames {
+			scope := projectConfig.Scopes[scopeName]
+			for _, name := range sortedWrapperNames(scope.Wrappers) {
+				describe(name, scope.Wrappers[name], fmt.Sprintf(" [scope %s]", scopeName))
+			}
+		}
+	}
+	if expired == 0 {
+		return
+	}
+
+	r.Println()
+	r.Println(r.Paint(render.Yellow, fmt.Sprintf("⚠️  Expired wrappers (%d):", expired)))
+	table.TrimLeft = true
+	table.Flush()
+	r.Println("  Remove them from their configs once the migration they were for is done.")
+}
+
+// sortedWrapperNames returns the command names of wrappers in order
+func sortedWrapperNames(wrappers map[string]config.WrapperConfig) []string {
+	names := make([]string, 0, len(wrappers))
+	for name := range wrappers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printSearchPathOrphans lists the sidecars in the configured search paths
+// that the registry doesn't know about. Prints nothing without search paths.
+func printSearchPathOrphans(r *render.Renderer, registry *config.Registry) {
+	configPath, err := config.FindProjectConfig()
+	if err != nil {
+		return
+	}
+	searchPaths, err := config.SearchPaths(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load search paths: %v\n", err)
+		return
+	}
+	if len(searchPaths) == 0 {
+		return
+	}
+
+	var orphans []string
+	seen := make(map[string]bool)
+	for _, dir := range searchPaths {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		sidecars, err := searchForSidecars(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to search %s: %v\n", dir, err)
+			continue
+		}
+		for _, sidecar := range sidecars {
+			binaryPath := wrap.BinaryForSidecar(sidecar)
+			if _, known := registry.Wrapper(binaryPath); known || seen[binaryPath] {
+				continue
+			}
+			seen[binaryPath] = true
+			orphans = append(orphans, binaryPath)
+		}
+	}
+
+	r.Println()
+	r.Heading(fmt.Sprintf("Search Paths (%d):", len(searchPaths)))
+	for _, dir := range searchPaths {
+		r.Printf("  %s\n", dir)
+	}
+	if len(orphans) == 0 {
+		r.Println("  No orphaned sidecars found.")
+		return
+	}
+	r.Println(r.Paint(render.Yellow, fmt.Sprintf("  ⚠️  Orphaned wrapped binaries (NOT in registry) (%d):", len(orphans))))
+	for _, path := range orphans {
+		r.Printf("    %s\n", path)
+	}
+	r.Println("  Run 'ribbin find' to track them, or 'ribbin find --restore <dir>' to restore the originals.")
+}
+
 // formatTimeAgo returns a human-readable string like "2h ago" or "15m ago"
 func formatTimeAgo(t time.Time) string {
 	d := time.Since(t)
@@ -144,3 +381,66 @@ func formatTimeAgo(t time.Time) string {
 	}
 	return fmt.Sprintf("%dd ago", days)
 }
+
+// activationTagsNote describes the tags an activation is limited to, as a
+// note inside the parentheses of a status line
+func activationTagsNote(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return ", tags: " + strings.Join(tags, ", ")
+}
+
+// activationScopesNote describes the scopes a config activation is limited
+// to, like activationTagsNote
+func activationScopesNote(scopes []string) string {
+	if len(scopes) == 0 {
+		return ""
+	}
+	return ", scopes: " + strings.Join(scopes, ", ")
+}
+
+// printKnownWrappers lists wrappers grouped by the config they were wrapped
+// for, then by command name. A command wrapped at several paths (say, the
+// node_modules/.bin/tsc of each package in a monorepo) is named once with
+// each of its binaries beside it.
+func printKnownWrappers(r *render.Renderer, entries []config.WrapperEntry) {
+	byConfig := make(map[string][]config.WrapperEntry)
+	var configPaths []string
+	for _, entry := range entries {
+		if _, seen := byConfig[entry.Config]; !seen {
+			configPaths = append(configPaths, entry.Config)
+		}
+		byConfig[entry.Config] = append(byConfig[entry.Config], entry)
+	}
+	sort.Strings(configPaths)
+
+	for i, configPath := range configPaths {
+		if i > 0 {
+			r.Println()
+		}
+		group := byConfig[configPath]
+		sort.SliceStable(group, func(i, j int) bool {
+			return filepath.Base(group[i].Original) < filepath.Base(group[j].Original)
+		})
+		label := configPath
+		if label == "" {
+			label = "(no config)"
+		}
+		r.Printf("  %s %s\n", r.Paint(render.Cyan, label), r.Paint(render.Dim, fmt.Sprintf("(%d wrapped)", len(group))))
+
+		table := r.Table(4, "COMMAND", "BINARY")
+		table.TrimLeft = true
+		previous := ""
+		for _, entry := range group {
+			name := filepath.Base(entry.Original)
+			command := render.Cell{Text: name, Style: render.Bold}
+			if name == previous {
+				command = render.Cell{}
+			}
+			previous = name
+			table.StyledRow(command, render.Cell{Text: entry.Original})
+		}
+		table.Flush()
+	}
+}
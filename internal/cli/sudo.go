@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/happycollision/ribbin/internal/wrap"
+)
+
+// printSudoPlans lists the commands --sudo runs as root, before any of them
+// run, so the user knows what sudo is asking a password for
+func printSudoPlans(plans []*wrap.SudoPlan, dryRun bool) {
+	if dryRun {
+		fmt.Println("\nWould run these commands with sudo:")
+	} else {
+		fmt.Println("\nRunning these commands with sudo:")
+	}
+	for _, plan := range plans {
+		for _, step := range plan.Steps {
+			fmt.Printf("  %s\n", step)
+		}
+	}
+	fmt.Println()
+}
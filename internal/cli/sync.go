@@ -0,0 +1,337 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var syncWatch bool
+var syncIfDue bool
+var syncSignKey string
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [config-file]",
+	Short: "Pull the signed organization policy named in the config",
+	Long: `Pull the organization policy named in the config's "sync" section, verify
+its signature, and re-wrap whatever it changed.
+
+The policy is an ordinary ribbin config whose root wrappers are merged into
+the config before its imports, so the repository can still override them.
+It is fetched from an https:// or github: url, or from a file in a git
+repository, together with a detached signature at the same location with
+".sig" appended. A policy is only used if its signature verifies against
+the config's publicKey:
+
+  "sync": {
+    "url": "https://policies.example.com/ribbin/policy.jsonc",
+    "publicKey": "<base64 ed25519 public key>",
+    "interval": "1h"
+  }
+
+When the policy adds a wrapper or changes its paths, the binaries are
+wrapped with 'ribbin wrap'. Binaries the policy no longer wraps are unwrapped.
+
+--watch keeps running and syncs every interval (default 1h), for a systemd
+unit, launchd agent or container sidecar. --if-due only syncs when the last
+sync is older than the interval, for cron or a shell rc file.
+
+Publishers create a key pair with 'ribbin sync keygen' and sign each policy
+with 'ribbin sync sign'.
+
+Examples:
+  ribbin sync                 # Sync the nearest config's policy
+  ribbin sync --watch         # Keep syncing every interval
+  ribbin sync --if-due        # Sync only if the interval has passed`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSync,
+}
+
+var syncKeygenCmd = &cobra.Command{
+	Use:   "keygen <private-key-file>",
+	Short: "Create a key pair for signing policies",
+	Long: `Create an ed25519 key pair for signing policies.
+
+The private key is written to the given file, which must not exist yet. Keep
+it with whoever publishes the policy. The public key is printed, to be put in
+each config's "sync.publicKey".
+
+Examples:
+  ribbin sync keygen ./policy-signing.key`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSyncKeygen,
+}
+
+var syncSignCmd = &cobra.Command{
+	Use:   "sign --key <private-key-file> <policy-file>",
+	Short: "Sign a policy for publishing",
+	Long: `Sign a policy with a private key from 'ribbin sync keygen'.
+
+The signature is written next to the policy with ".sig" appended. Publish
+both files together.
+
+Examples:
+  ribbin sync sign --key ./policy-signing.key policy.jsonc`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSyncSign,
+}
+
+func init() {
+	syncCmd.Flags().BoolVar(&syncWatch, "watch", false, "Keep running and sync every interval")
+	syncCmd.Flags().BoolVar(&syncIfDue, "if-due", false, "Only sync if the last sync is older than the interval")
+	syncSignCmd.Flags().StringVar(&syncSignKey, "key", "", "Private key file from 'ribbin sync keygen'")
+	syncSignCmd.MarkFlagRequired("key")
+
+	syncCmd.AddCommand(syncKeygenCmd)
+	syncCmd.AddCommand(syncSignCmd)
+	rootCmd.AddCommand(syncCmd)
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	configPath, err := syncConfigPath(args)
+	if err != nil {
+		return err
+	}
+
+	if !syncWatch {
+		return syncOnce(configPath, syncIfDue)
+	}
+
+	for {
+		if err := syncOnce(configPath, false); err != nil {
+			fmt.Fprintf(os.Stderr, "ribbin sync: %v\n", err)
+		}
+		interval := time.Hour
+		if settings, err := loadSyncSettings(configPath); err == nil {
+			interval, _ = settings.SyncInterval()
+		}
+		time.Sleep(interval)
+	}
+}
+
+// syncConfigPath returns the absolute path of the config given on the
+// command line, or of the nearest config.
+func syncConfigPath(args []string) (string, error) {
+	if len(args) == 0 {
+		configPath, err := config.FindProjectConfig()
+		if err != nil {
+			return "", fmt.Errorf("failed to find config: %w", err)
+		}
+		if configPath == "" {
+			return "", errNoConfig
+		}
+		return configPath, nil
+	}
+	configPath, err := filepath.Abs(args[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", args[0], err)
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		return "", errConfigFileNotFound(configPath)
+	}
+	return configPath, nil
+}
+
+// loadSyncSettings returns the config's sync section.
+func loadSyncSettings(configPath string) (*config.SyncConfig, error) {
+	projectConfig, err := config.LoadProjectConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config %s: %w", configPath, err)
+	}
+	if projectConfig.Sync == nil {
+		return nil, fmt.Errorf("%s has no \"sync\" section", configPath)
+	}
+	if err := projectConfig.Sync.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", configPath, err)
+	}
+	return projectConfig.Sync, nil
+}
+
+// syncOnce pulls and verifies the config's policy, and re-wraps the targets
+// it changed. With ifDue, nothing happens until the interval has passed
+// since the last sync.
+func syncOnce(configPath string, ifDue bool) error {
+	settings, err := loadSyncSettings(configPath)
+	if err != nil {
+		return err
+	}
+	if ifDue {
+		interval, _ := settings.SyncInterval()
+		if last := config.LastSynced(configPath, settings); time.Since(last) < interval {
+			fmt.Printf("Policy synced %s ago; next sync due in %s\n",
+				time.Since(last).Round(time.Second), (interval - time.Since(last)).Round(time.Second))
+			return nil
+		}
+	}
+
+	before, err := syncTargets(configPath)
+	if err != nil {
+		return err
+	}
+
+	data, err := config.FetchPolicy(settings)
+	if err != nil {
+		return fmt.Errorf("failed to sync policy: %w", err)
+	}
+	changed, err := config.StoreSyncedPolicy(configPath, settings, data)
+	if err != nil {
+		return fmt.Errorf("failed to store policy: %w", err)
+	}
+	if !changed {
+		fmt.Printf("Policy from %s is unchanged\n", settings.Source())
+		return nil
+	}
+	fmt.Printf("Synced policy from %s\n", settings.Source())
+
+	after, err := syncTargets(configPath)
+	if err != nil {
+		return err
+	}
+	return rewrapChanged(configPath, before, after)
+}
+
+// syncTargets returns the paths configured for each wrapper of the config
+// (root and scopes), as 'ribbin wrap' sees them.
+func syncTargets(configPath string) (map[string][]string, error) {
+	projectConfig, err := config.LoadProjectConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config %s: %w", configPath, err)
+	}
+	targets := make(map[string][]string)
+	for name, wrapperCfg := range projectConfig.Wrappers {
+		targets[name] = wrapperCfg.Paths
+	}
+	for _, scope := range projectConfig.Scopes {
+		for name, wrapperCfg := range scope.Wrappers {
+			targets[name] = wrapperCfg.Paths
+		}
+	}
+	return targets, nil
+}
+
+// rewrapChanged unwraps the config's binaries for wrappers the policy
+// removed or re-pointed, then runs 'ribbin wrap' if it added or re-pointed
+// any.
+func rewrapChanged(configPath string, before, after map[string][]string) error {
+	var added, removed, changed []string
+	for name, paths := range after {
+		oldPaths, ok := before[name]
+		switch {
+		case !ok:
+			added = append(added, name)
+		case !slices.Equal(oldPaths, paths):
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	fmt.Printf("Wrappers: %d added, %d re-pointed, %d removed\n", len(added), len(changed), len(removed))
+
+	if stale := append(removed, changed...); len(stale) > 0 {
+		if err := unwrapForConfig(configPath, stale); err != nil {
+			return err
+		}
+	}
+	if len(added) == 0 && len(changed) == 0 {
+		return nil
+	}
+
+	execPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+	wrapRun := exec.Command(execPath, "wrap", configPath)
+	wrapRun.Stdout = os.Stdout
+	wrapRun.Stderr = os.Stderr
+	if err := wrapRun.Run(); err != nil {
+		return fmt.Errorf("failed to wrap the policy's changes: %w", err)
+	}
+	return nil
+}
+
+// unwrapForConfig unwraps the binaries wrapped for the config at configPath
+// under any of names.
+func unwrapForConfig(configPath string, names []string) error {
+	registry, err := config.LoadRegistry()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+	registryBefore := registry.CloneWrappers()
+
+	var results []wrap.UnwrapResult
+	for _, entry := range registry.WrappersForConfig(configPath) {
+		if slices.Contains(names, filepath.Base(entry.Original)) {
+			results = append(results, unwrapSinglePath(entry.Original, registry))
+		}
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	err = config.UpdateRegistry(func(latest *config.Registry) error {
+		latest.MergeWrapperChanges(registryBefore, registry.Wrappers)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save registry: %w", err)
+	}
+	printUnwrapSummary(results)
+	return nil
+}
+
+func runSyncKeygen(cmd *cobra.Command, args []string) error {
+	keyPath := args[0]
+	if _, err := os.Lstat(keyPath); err == nil {
+		return fmt.Errorf("%s already exists", keyPath)
+	}
+
+	publicKey, privateKey, err := config.GenerateSigningKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	if err := os.WriteFile(keyPath, []byte(privateKey+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+
+	fmt.Printf("Wrote private key to %s (keep it secret)\n", keyPath)
+	fmt.Println()
+	fmt.Println("Add the public key to each config's sync section:")
+	fmt.Printf("  \"publicKey\": %q\n", publicKey)
+	return nil
+}
+
+func runSyncSign(cmd *cobra.Command, args []string) error {
+	policyPath := args[0]
+	encoded, err := os.ReadFile(syncSignKey)
+	if err != nil {
+		return fmt.Errorf("failed to read key: %w", err)
+	}
+	privateKey, err := config.ParseSigningKey(string(encoded))
+	if err != nil {
+		return fmt.Errorf("%s: %w", syncSignKey, err)
+	}
+	if _, err := config.LoadExtendsConfig(policyPath); err != nil {
+		return fmt.Errorf("%s is not a valid config: %w", policyPath, err)
+	}
+	data, err := os.ReadFile(policyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read policy: %w", err)
+	}
+
+	sigPath := policyPath + ".sig"
+	if err := os.WriteFile(sigPath, config.SignPolicy(data, privateKey), 0644); err != nil {
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+	fmt.Printf("Wrote signature to %s\n", sigPath)
+	return nil
+}
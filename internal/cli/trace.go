@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var traceLast int
+var traceCommand string
+
+var traceCmd = &cobra.Command{
+	Use:   "trace",
+	Short: "Inspect wrapper decision traces",
+	Long: `Inspect wrapper decision traces.
+
+Set RIBBIN_TRACE to a file path and every wrapper run in that environment
+appends a record of how it decided what to do: the command line, the
+original binary, the registry state, the config and scope it found, where
+the wrapper was defined, each check it made, and the outcome. Use an
+absolute path, since wrappers run in many directories.
+
+Examples:
+  RIBBIN_TRACE=/tmp/ribbin-trace.json npm test
+  ribbin trace explain /tmp/ribbin-trace.json
+`,
+}
+
+var traceExplainCmd = &cobra.Command{
+	Use:   "explain <file>",
+	Short: "Pretty-print a RIBBIN_TRACE file",
+	Long: `Pretty-print the traces recorded in a RIBBIN_TRACE file, oldest first.
+
+Examples:
+  ribbin trace explain /tmp/ribbin-trace.json              # Every invocation
+  ribbin trace explain /tmp/ribbin-trace.json --last 1     # The latest one
+  ribbin trace explain /tmp/ribbin-trace.json --command tsc`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		traces, err := wrap.ReadTraces(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		traces = filterTraces(traces, traceCommand, traceLast)
+		if len(traces) == 0 {
+			fmt.Println("No traces recorded")
+			return
+		}
+		for i, trace := range traces {
+			if i > 0 {
+				fmt.Println()
+			}
+			printTrace(os.Stdout, trace)
+		}
+	},
+}
+
+// filterTraces keeps the traces for command (all if empty), then the last n
+// of those (all if n <= 0)
+func filterTraces(traces []wrap.Trace, command string, n int) []wrap.Trace {
+	if command != "" {
+		var matching []wrap.Trace
+		for _, trace := range traces {
+			if trace.Command == command {
+				matching = append(matching, trace)
+			}
+		}
+		traces = matching
+	}
+	if n > 0 && len(traces) > n {
+		traces = traces[len(traces)-n:]
+	}
+	return traces
+}
+
+// printTrace writes one trace in the layout of 'ribbin which'
+func printTrace(w io.Writer, trace wrap.Trace) {
+	fmt.Fprintf(w, "%s  %s -> %s\n", trace.Time.Local().Format("2006-01-02 15:04:05.000"), trace.Command, trace.Outcome)
+	fmt.Fprintf(w, "  Argv:     %s\n", strings.Join(trace.Argv, " "))
+	fmt.Fprintf(w, "  Cwd:      %s\n", trace.Cwd)
+	fmt.Fprintf(w, "  Process:  pid %d, parent %d, ribbin %s\n", trace.PID, trace.PPID, trace.RibbinVersion)
+	fmt.Fprintf(w, "  Original: %s\n", trace.OriginalPath)
+
+	if r := trace.Registry; r != nil {
+		var activation []string
+		if r.GlobalActive {
+			activation = append(activation, "global")
+		}
+		if len(r.ActiveConfigs) > 0 {
+			activation = append(activation, fmt.Sprintf("configs %s", strings.Join(r.ActiveConfigs, ", ")))
+		}
+		if len(r.ShellActivations) > 0 {
+			activation = append(activation, fmt.Sprintf("shells %s", strings.Trim(fmt.Sprint(r.ShellActivations), "[]")))
+		}
+		if len(activation) == 0 {
+			activation = append(activation, "none")
+		}
+		fmt.Fprintf(w, "  Active:   %s\n", strings.Join(activation, "; "))
+		if r.Wrapper != nil {
+			fmt.Fprintf(w, "  Registry: wrapped by %s\n", r.Wrapper.Config)
+		}
+		if r.Snooze != nil {
+			fmt.Fprintf(w, "  Snoozed:  until %s\n", r.Snooze.Until.Local().Format("15:04:05"))
+		}
+	}
+
+	if trace.ConfigPath != "" {
+		config := trace.ConfigPath
+		if trace.DecisionCache != "" {
+			config += fmt.Sprintf(" (decision cache %s)", trace.DecisionCache)
+		}
+		fmt.Fprintf(w, "  Config:   %s\n", config)
+		if trace.Scope != nil {
+			fmt.Fprintf(w, "  Scope:    %s (%s)\n", trace.Scope.Name, trace.Scope.Path)
+		} else {
+			fmt.Fprintf(w, "  Scope:    (root)\n")
+		}
+	}
+	if trace.Wrapper != nil {
+		wrapper := trace.Wrapper.Action
+		if len(trace.Provenance) > 0 {
+			wrapper += fmt.Sprintf(" from %s#%s", trace.Provenance[0].File, trace.Provenance[0].Fragment)
+		}
+		fmt.Fprintf(w, "  Wrapper:  %s\n", wrapper)
+		for _, source := range trace.Provenance[min(1, len(trace.Provenance)):] {
+			fmt.Fprintf(w, "            overrides %s#%s\n", source.File, source.Fragment)
+		}
+	}
+
+	fmt.Fprintf(w, "\n  Decision: %s\n", trace.Outcome)
+	if trace.Reason != "" {
+		fmt.Fprintf(w, "    %s\n", trace.Reason)
+	}
+	fmt.Fprintf(w, "  Checks:\n")
+	for i, s := range trace.Steps {
+		fmt.Fprintf(w, "    %d. %-14s %s\n", i+1, s.Check+":", s.Result)
+	}
+}
+
+func init() {
+	traceExplainCmd.Flags().IntVar(&traceLast, "last", 0, "Only show the last N traces")
+	traceExplainCmd.Flags().StringVar(&traceCommand, "command", "", "Only show traces for this command")
+
+	traceCmd.AddCommand(traceExplainCmd)
+	rootCmd.AddCommand(traceCmd)
+}
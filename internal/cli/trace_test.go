@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+	"github.com/happycollision/ribbin/internal/wrap"
+)
+
+func TestFilterTraces(t *testing.T) {
+	traces := []wrap.Trace{{Command: "tsc"}, {Command: "npm"}, {Command: "tsc", Outcome: "PASS"}, {Command: "npm"}}
+
+	if got := filterTraces(traces, "", 0); len(got) != 4 {
+		t.Errorf("no filter: got %d traces", len(got))
+	}
+	if got := filterTraces(traces, "tsc", 0); len(got) != 2 {
+		t.Errorf("--command tsc: got %d traces", len(got))
+	}
+	if got := filterTraces(traces, "tsc", 1); len(got) != 1 || got[0].Outcome != "PASS" {
+		t.Errorf("--command tsc --last 1: got %+v", got)
+	}
+	if got := filterTraces(traces, "", 10); len(got) != 4 {
+		t.Errorf("--last 10: got %d traces", len(got))
+	}
+}
+
+func TestPrintTrace(t *testing.T) {
+	var buf bytes.Buffer
+	printTrace(&buf, wrap.Trace{
+		Command:    "tsc",
+		Argv:       []string{"tsc", "--noEmit"},
+		Registry:   &wrap.TraceRegistry{GlobalActive: true},
+		ConfigPath: "/p/ribbin.jsonc",
+		Scope:      &wrap.TraceScope{Name: "app", Path: "apps/app"},
+		Wrapper:    &config.ShimConfig{Action: "block"},
+		Provenance: []wrap.TraceSource{{File: "/p/ribbin.jsonc", Fragment: "root.app"}, {File: "/p/ribbin.jsonc", Fragment: "root"}},
+		Steps:      []wrap.TraceStep{{Check: "RIBBIN_BYPASS", Result: "not set"}},
+		Outcome:    "BLOCKED",
+		Reason:     "use pnpm typecheck",
+	})
+	out := buf.String()
+
+	for _, want := range []string{
+		"tsc -> BLOCKED",
+		"Argv:     tsc --noEmit",
+		"Active:   global",
+		"Scope:    app (apps/app)",
+		"Wrapper:  block from /p/ribbin.jsonc#root.app",
+		"overrides /p/ribbin.jsonc#root",
+		"use pnpm typecheck",
+		"1. RIBBIN_BYPASS: not set",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
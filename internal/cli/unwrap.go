@@ -2,12 +2,14 @@ package cli
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/happycollision/ribbin/internal/cli/render"
 	"github.com/happycollision/ribbin/internal/config"
 	"github.com/happycollision/ribbin/internal/security"
 	"github.com/happycollision/ribbin/internal/wrap"
@@ -16,6 +18,10 @@ import (
 
 var unwrapGlobal bool
 var unwrapFind bool
+var unwrapOnly []string
+var unwrapInteractive bool
+var unwrapJobs int
+var unwrapSudo bool
 
 var unwrapCmd = &cobra.Command{
 	Use:   "unwrap [config-files...]",
@@ -28,23 +34,43 @@ You can also specify config file paths explicitly.
 Use flags to control which wrappers are removed:
   --all          Remove all wrappers tracked in the registry
   --find         Search entire system for orphaned wrappers (requires --all)
+  --only         Remove only the named commands (every wrapped binary with
+                 that name) or wrapped paths from the registry
+  --interactive  Pick which registry wrappers to remove from a list
 
 For each wrapped command, ribbin:
   1. Removes the symlink at the command's path
   2. Renames <original>.ribbin-original back to <original>
   3. Updates the registry
 
+Binaries are restored several at a time (--jobs). Those whose original was
+reinstalled or changed since wrapping are handled one at a time first, as
+they may ask what to do.
+
+With --sudo, symlink wrappers in directories you can't write, as made by
+'ribbin wrap --sudo', are restored by running the commands listed first
+through sudo: rm for the symlink, mv for the original, and rm for the
+metadata.
+
 Examples:
   ribbin unwrap                         # Remove wrappers from nearest ribbin.jsonc
   ribbin unwrap ./a.jsonc ./b.jsonc     # Remove wrappers from specific configs
   ribbin unwrap --all                   # Remove all wrappers in the registry
-  ribbin unwrap --all --find            # Remove all wrappers + search for orphaned ones`,
+  ribbin unwrap --all --find            # Remove all wrappers + search for orphaned ones
+  ribbin unwrap --only tsc,npm          # Remove just these wrappers
+  ribbin unwrap --only ./packages/web/node_modules/.bin/tsc
+  ribbin unwrap -i                      # Choose wrappers to remove from a list
+  ribbin unwrap --sudo --only /usr/local/bin/node  # Restore a binary through sudo`,
 	RunE: runUnwrap,
 }
 
 func init() {
 	unwrapCmd.Flags().BoolVar(&unwrapGlobal, "all", false, "Remove all wrappers tracked in the registry, not just those in ribbin.jsonc")
 	unwrapCmd.Flags().BoolVar(&unwrapFind, "find", false, "Search entire system for orphaned wrappers (requires --all)")
+	unwrapCmd.Flags().StringSliceVar(&unwrapOnly, "only", nil, "Remove only these wrapped commands or paths (comma-separated)")
+	unwrapCmd.Flags().BoolVarP(&unwrapInteractive, "interactive", "i", false, "Choose which wrappers to remove from a list")
+	unwrapCmd.Flags().BoolVar(&unwrapSudo, "sudo", false, "Restore wrappers in directories you can't write by running the few commands needed there through sudo")
+	unwrapCmd.Flags().IntVarP(&unwrapJobs, "jobs", "j", 0, "How many binaries to restore at once (default: a few per CPU)")
 }
 
 // commonBinDirs returns common binary directories to search for wrappers.
@@ -67,11 +93,12 @@ func commonBinDirs() ([]string, error) {
 func runUnwrap(cmd *cobra.Command, args []string) error {
 	printGlobalWarningIfActive()
 
-	// Load registry
+	// Load registry snapshot; changes are merged back under lock at the end
 	registry, err := config.LoadRegistry()
 	if err != nil {
 		return fmt.Errorf("failed to load registry: %w", err)
 	}
+	registryBefore := registry.CloneWrappers()
 
 	// Determine paths to unwrap based on flags and args
 	var pathsToUnwrap []string
@@ -81,7 +108,20 @@ func runUnwrap(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("--find requires --all flag")
 	}
 
-	if unwrapGlobal {
+	if len(unwrapOnly) > 0 || unwrapInteractive {
+		if unwrapGlobal || len(args) > 0 {
+			return fmt.Errorf("--only and --interactive pick from the registry and can't be combined with --all or config files")
+		}
+
+		if len(unwrapOnly) > 0 {
+			pathsToUnwrap, err = healTargets(registry, unwrapOnly)
+		} else {
+			pathsToUnwrap, err = pickWrappers(registry, bufio.NewReader(os.Stdin))
+		}
+		if err != nil {
+			return err
+		}
+	} else if unwrapGlobal {
 		// Use paths from registry
 		for _, entry := range registry.Wrappers {
 			pathsToUnwrap = append(pathsToUnwrap, entry.Original)
@@ -102,7 +142,7 @@ func runUnwrap(cmd *cobra.Command, args []string) error {
 			// Add orphaned sidecars (not already in registry)
 			registryCount := len(pathsToUnwrap)
 			for _, sidecar := range searchedSidecars {
-				originalPath := strings.TrimSuffix(sidecar, ".ribbin-original")
+				originalPath := wrap.BinaryForSidecar(sidecar)
 				// Check if already in pathsToUnwrap
 				alreadyAdded := false
 				for _, existing := range pathsToUnwrap {
@@ -139,7 +179,7 @@ func runUnwrap(cmd *cobra.Command, args []string) error {
 				return fmt.Errorf("failed to find project config: %w", err)
 			}
 			if configPath == "" {
-				return fmt.Errorf("no ribbin.jsonc found. Run 'ribbin init' to create one")
+				return errNoConfig
 			}
 			configPaths = []string{configPath}
 		}
@@ -166,13 +206,21 @@ func runUnwrap(cmd *cobra.Command, args []string) error {
 				}
 			}
 
-			// For each command in project config (root + scopes), find its path in registry
+			// Binaries with the same name wrapped for other configs (say, the
+			// tsc of another package) are left alone
+			wrappedHere := make(map[string][]string)
+			for _, entry := range registry.WrappersForConfig(configPath) {
+				name := filepath.Base(entry.Original)
+				wrappedHere[name] = append(wrappedHere[name], entry.Original)
+			}
+
+			// For each command in project config (root + scopes), find its paths in registry
 			for commandName := range allCommandNames {
-				if entry, ok := registry.Wrappers[commandName]; ok {
-					pathsToUnwrap = append(pathsToUnwrap, entry.Original)
-				} else {
+				if paths := wrappedHere[commandName]; len(paths) > 0 {
+					pathsToUnwrap = append(pathsToUnwrap, paths...)
+				} else if !registry.HasCommand(commandName) {
 					// Try to find the command in PATH and check if it has a sidecar
-					path, err := exec.LookPath(commandName)
+					path, err := wrap.ResolveCommand(commandName)
 					if err == nil {
 						// Only add if it looks like it was wrapped (has sidecar)
 						if wrap.HasSidecar(path) {
@@ -189,17 +237,72 @@ func runUnwrap(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	// Track results
-	var results []wrap.UnwrapResult
+	// Unwrap the binaries that may need a decision one at a time, then the
+	// rest on a pool. Results stay in the order of pathsToUnwrap.
+	results := make([]wrap.UnwrapResult, len(pathsToUnwrap))
+	var plain []string
+	plainIndex := make(map[string]int)
+	var sudoPlans []*wrap.SudoPlan
+	sudoIndex := make(map[string]int)
+	for i, path := range pathsToUnwrap {
+		if unwrapSudo && wrap.NeedsSudo(path) {
+			plan, err := wrap.PlanSudoUninstall(path)
+			if err != nil {
+				results[i] = wrap.UnwrapResult{BinaryPath: path, Error: err}
+				continue
+			}
+			sudoPlans = append(sudoPlans, plan)
+			sudoIndex[path] = i
+			continue
+		}
+		if isPlainUnwrap(path) {
+			plain = append(plain, path)
+			plainIndex[path] = i
+			continue
+		}
+		results[i] = unwrapSinglePath(path, registry)
+	}
+
+	progress := render.NewProgress("Unwrapping", len(plain))
+	pool := &wrap.Pool{
+		Workers:  unwrapJobs,
+		Progress: func(done, _ int) { progress.Update(done) },
+		Verb:     "unwrap",
+	}
+	batchErr := &wrap.BatchError{}
+	if err := wrap.UninstallAll(plain, registry, pool); err != nil && !errors.As(err, &batchErr) {
+		progress.Done()
+		return err
+	}
+	progress.Done()
+	for _, path := range plain {
+		result := wrap.UnwrapResult{BinaryPath: path, Success: true}
+		if err := batchErr.ErrFor(path); err != nil {
+			result.Success = false
+			result.Error = err
+		}
+		results[plainIndex[path]] = result
+	}
 
-	// Unwrap each path
-	for _, path := range pathsToUnwrap {
-		result := unwrapSinglePath(path, registry)
-		results = append(results, result)
+	// Restore the binaries that need sudo, after showing what it will run
+	if len(sudoPlans) > 0 {
+		printSudoPlans(sudoPlans, false)
+	}
+	for _, plan := range sudoPlans {
+		result := wrap.UnwrapResult{BinaryPath: plan.BinaryPath, Success: true}
+		if err := wrap.SudoUninstall(plan, registry); err != nil {
+			result.Success = false
+			result.Error = err
+		}
+		results[sudoIndex[plan.BinaryPath]] = result
 	}
 
-	// Save registry
-	if err := config.SaveRegistry(registry); err != nil {
+	// Merge our removals into the registry without losing concurrent changes
+	err = config.UpdateRegistry(func(latest *config.Registry) error {
+		latest.MergeWrapperChanges(registryBefore, registry.Wrappers)
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to save registry: %w", err)
 	}
 
@@ -209,27 +312,114 @@ func runUnwrap(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// pickWrappers lists the wrappers in the registry and asks which to remove.
+// Returns the chosen binary paths, or none if the user cancels.
+func pickWrappers(registry *config.Registry, reader *bufio.Reader) ([]string, error) {
+	paths := registry.WrapperPaths()
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	fmt.Println("Wrapped commands:")
+	fmt.Println()
+	for i, path := range paths {
+		entry := registry.Wrappers[path]
+		fmt.Printf("  %2d. %-12s %s\n", i+1, filepath.Base(path), entry.Original)
+		if entry.Config != "" {
+			fmt.Printf("      %-12s config: %s\n", "", entry.Config)
+		}
+	}
+	fmt.Println()
+	fmt.Print("Unwrap which? (e.g. 1,3 or 2-4, 'all', Enter to cancel): ")
+
+	input, err := reader.ReadString('\n')
+	if err != nil && input == "" {
+		fmt.Println()
+		return nil, nil
+	}
+
+	indexes, err := parseSelection(input, len(paths))
+	if err != nil {
+		return nil, err
+	}
+
+	chosen := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		chosen = append(chosen, paths[i])
+	}
+	return chosen, nil
+}
+
+// parseSelection parses a list picker answer like "1,3-5" or "all" into
+// zero-based indexes for a list of n items, in order and without duplicates.
+func parseSelection(input string, n int) ([]int, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(input, "all") {
+		indexes := make([]int, n)
+		for i := range indexes {
+			indexes[i] = i
+		}
+		return indexes, nil
+	}
+
+	selected := make([]bool, n)
+	for _, part := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' }) {
+		lo, hi := part, part
+		if before, after, ok := strings.Cut(part, "-"); ok {
+			lo, hi = before, after
+		}
+		start, err1 := strconv.Atoi(lo)
+		end, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || start < 1 || end > n || start > end {
+			return nil, fmt.Errorf("invalid selection %q: choose numbers between 1 and %d", part, n)
+		}
+		for i := start; i <= end; i++ {
+			selected[i-1] = true
+		}
+	}
+
+	var indexes []int
+	for i, ok := range selected {
+		if ok {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
+
+// isPlainUnwrap reports whether path is a wrapper that can be restored
+// without a decision: it isn't a leftover sidecar of a reinstalled tool, and
+// its original hasn't changed since it was wrapped
+func isPlainUnwrap(path string) bool {
+	info, err := os.Lstat(path)
+	isWrapper := err == nil && (info.Mode()&os.ModeSymlink != 0 || wrap.IsCopyShim(path))
+	if wrap.HasSidecar(path) && !isWrapper {
+		return false
+	}
+	hasConflict, _, _ := wrap.CheckHashConflict(path)
+	return !hasConflict
+}
+
 // unwrapSinglePath handles unwrapping a single binary with conflict detection
 func unwrapSinglePath(path string, registry *config.Registry) wrap.UnwrapResult {
 	result := wrap.UnwrapResult{BinaryPath: path}
 
 	// Check if sidecar exists
-	sidecarPath := path + ".ribbin-original"
-	hasSidecar := false
-	if _, err := os.Stat(sidecarPath); err == nil {
-		hasSidecar = true
-	}
+	hasSidecar := wrap.HasSidecar(path)
 
-	// Check if binary is a symlink
+	// Check if binary is a symlink, or a copy of ribbin in the copy shim mode
 	info, err := os.Lstat(path)
-	isSymlink := false
-	if err == nil && info.Mode()&os.ModeSymlink != 0 {
-		isSymlink = true
+	isWrapper := false
+	if err == nil && (info.Mode()&os.ModeSymlink != 0 || wrap.IsCopyShim(path)) {
+		isWrapper = true
 	}
 
-	// Handle inconsistent state: sidecar exists but binary is not a symlink
+	// Handle inconsistent state: sidecar exists but binary is not a wrapper
 	// This happens when a tool is reinstalled after wrapping
-	if hasSidecar && !isSymlink {
+	if hasSidecar && !isWrapper {
 		fmt.Printf("Cleaning up orphaned sidecar for %s (tool was reinstalled)\n", filepath.Base(path))
 		err := wrap.CleanupSidecarFiles(path, registry)
 		if err != nil {
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var verifyJSON bool
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [config-file]",
+	Short: "Check that a config's wrappers are installed and active",
+	Long: `Check that the wrappers of a config are in effect on this machine: every
+binary they target is wrapped, and ribbin is active for them. Exits non-zero
+otherwise, listing the binaries that are missing wrappers, for a CI step or a
+pre-push hook.
+
+Uses the nearest ribbin.jsonc unless a config file is given. A wrapper's
+targets are its paths, or the command on PATH if it has none, plus any
+binary 'ribbin wrap --auto' wrapped for it. Binaries that aren't installed
+are listed but don't fail the check, except commands the config requires
+to be wrapped ("require": {"wrapped": [...]}), which must be installed and
+wrapped.
+
+With --json, the report is printed as JSON, e.g. to upload as a CI artifact.
+
+Exit codes:
+  0  every installed target is wrapped and active
+  3  a target isn't wrapped, or no activation covers it, or a required
+     command isn't installed
+
+Examples:
+  ribbin verify                       # Check the nearest config
+  ribbin verify --json > ribbin.json  # Keep a report for CI`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath, err := verifyConfigPath(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(ExitCode(err))
+		}
+
+		registry, err := config.LoadRegistry()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading registry: %v\n", err)
+			os.Exit(ExitCode(err))
+		}
+
+		report, err := wrap.CheckCoverage(configPath, registry)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config %s: %v\n", configPath, err)
+			os.Exit(1)
+		}
+
+		if verifyJSON {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+		} else {
+			printVerifyReport(report)
+		}
+
+		if !report.OK {
+			os.Exit(ExitNotWrapped)
+		}
+	},
+}
+
+func init() {
+	verifyCmd.Flags().BoolVar(&verifyJSON, "json", false, "Print the report as JSON")
+	rootCmd.AddCommand(verifyCmd)
+}
+
+// verifyConfigPath returns the config named on the command line, or the
+// nearest one
+func verifyConfigPath(args []string) (string, error) {
+	if len(args) == 0 {
+		configPath, err := config.FindProjectConfig()
+		if err != nil {
+			return "", err
+		}
+		if configPath == "" {
+			return "", errNoConfig
+		}
+		return configPath, nil
+	}
+	configPath, err := filepath.Abs(args[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", args[0], err)
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		return "", errConfigFileNotFound(configPath)
+	}
+	return configPath, nil
+}
+
+func printVerifyReport(report *wrap.CoverageReport) {
+	fmt.Printf("Config: %s\n", report.Config)
+	if report.Activation != "" {
+		fmt.Printf("Active: %s\n", report.Activation)
+	} else {
+		fmt.Println("Active: no (run 'ribbin activate')")
+	}
+	fmt.Println()
+
+	for _, target := range report.Targets {
+		mark := "✓"
+		switch target.Status {
+		case wrap.TargetNotWrapped, wrap.TargetInactive:
+			mark = "✗"
+		case wrap.TargetNotFound:
+			mark = "-"
+			if target.Required {
+				mark = "✗"
+			}
+		}
+		fmt.Printf("  %s %s\n", mark, target)
+	}
+
+	failing := report.Failing()
+	if len(failing) == 0 {
+		fmt.Printf("\nAll %d wrapper target(s) are in effect\n", len(report.Targets))
+		return
+	}
+	fmt.Printf("\n%d wrapper target(s) not in effect. Run 'ribbin wrap' and 'ribbin activate' to fix.\n", len(failing))
+}
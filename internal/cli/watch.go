@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var watchInterval time.Duration
+var watchOnce bool
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [config-files...]",
+	Short: "Re-wrap binaries when installs recreate them",
+	Long: `Keep running and wrap a config's binaries again whenever an install or
+update recreates them, so a reinstall can't silently remove protection until
+someone reruns 'ribbin wrap'.
+
+Uses the nearest ribbin.jsonc unless config files are given. ribbin polls
+the directories holding the binaries the wrappers target, the config's
+node_modules/.bin, the mise and asdf shim directories and ~/.local/bin, and
+the config files themselves. When one changes, every installed target that
+isn't wrapped is wrapped again, as 'ribbin wrap --refresh' would. A
+wrapper's targets are found as by 'ribbin verify'. Binaries in system
+directories are never wrapped by watch.
+
+The first poll wraps anything not wrapped yet. With --once, ribbin exits
+after it, e.g. for a cron job or a post-install hook. Otherwise it runs until
+interrupted.
+
+Examples:
+  ribbin watch                   # Watch the nearest config
+  ribbin watch ./a.jsonc ./b.jsonc
+  ribbin watch --interval 10s    # Poll less often
+  ribbin watch --once            # Re-wrap what needs it, then exit`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if watchInterval <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: --interval must be positive\n")
+			os.Exit(1)
+		}
+
+		printGlobalWarningIfActive()
+
+		var configPaths []string
+		if len(args) > 0 {
+			for _, arg := range args {
+				absPath, err := filepath.Abs(arg)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error resolving path %s: %v\n", arg, err)
+					os.Exit(1)
+				}
+				if _, err := os.Stat(absPath); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", errConfigFileNotFound(absPath))
+					os.Exit(ExitConfigNotFound)
+				}
+				configPaths = append(configPaths, absPath)
+			}
+		} else {
+			configPath, err := config.FindProjectConfig()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error finding config: %v\n", err)
+				os.Exit(1)
+			}
+			if configPath == "" {
+				fmt.Fprintln(os.Stderr, errNoConfig)
+				os.Exit(ExitConfigNotFound)
+			}
+			configPaths = []string{configPath}
+		}
+
+		execPath, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting executable path: %v\n", err)
+			os.Exit(1)
+		}
+		ribbinPath, err := filepath.EvalSymlinks(execPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error resolving executable path: %v\n", err)
+			os.Exit(1)
+		}
+
+		watcher := wrap.NewWatcher(configPaths)
+		if watchOnce {
+			if failed := watchPoll(watcher, configPaths, ribbinPath); failed > 0 {
+				os.Exit(1)
+			}
+			return
+		}
+
+		for _, configPath := range configPaths {
+			fmt.Printf("Watching %s\n", configPath)
+		}
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			watchPoll(watcher, configPaths, ribbinPath)
+			select {
+			case <-stop:
+				fmt.Println("Stopped watching")
+				return
+			case <-ticker.C:
+			}
+		}
+	},
+}
+
+// watchPoll re-wraps the configs' binaries if anything the watcher polls
+// changed, printing what it did. Problems are reported rather than fatal, so
+// the watch keeps going. Returns how many binaries failed to wrap.
+func watchPoll(watcher *wrap.Watcher, configPaths []string, ribbinPath string) int {
+	registry, err := config.LoadRegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading registry: %v\n", err)
+		return 0
+	}
+	if !watcher.Changed(registry) {
+		return 0
+	}
+	registryBefore := registry.CloneWrappers()
+
+	var wrapped, failed int
+	for _, configPath := range configPaths {
+		results, err := wrap.RewrapConfig(configPath, ribbinPath, registry)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config %s: %v\n", configPath, err)
+			continue
+		}
+		for _, result := range results {
+			stamp := time.Now().Format("15:04:05")
+			if result.Err != nil {
+				fmt.Printf("%s Failed to wrap '%s': %v\n", stamp, result.Path, result.Err)
+				failed++
+				continue
+			}
+			fmt.Printf("%s Wrapped '%s'\n", stamp, result.Path)
+			wrapped++
+		}
+	}
+
+	if wrapped > 0 {
+		err := config.UpdateRegistry(func(latest *config.Registry) error {
+			latest.MergeWrapperChanges(registryBefore, registry.Wrappers)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating registry: %v\n", err)
+		}
+	}
+	return failed
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 2*time.Second, "How often to check for recreated binaries")
+	watchCmd.Flags().BoolVar(&watchOnce, "once", false, "Re-wrap what needs it once, then exit")
+	rootCmd.AddCommand(watchCmd)
+}
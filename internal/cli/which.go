@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/happycollision/ribbin/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+var whichJSON bool
+
+var whichCmd = &cobra.Command{
+	Use:   "which <command> [-- args...]",
+	Short: "Explain what ribbin would do with a command, and why",
+	Long: `Explain what ribbin would do with a command, and why.
+
+Reports where the command and its original binary live, which config and
+scope govern it from the current directory, the effective wrapper and where
+it was defined, and the decision ribbin would make if the command ran now.
+
+Every check the wrapper makes is listed in order (bypass, activation,
+scope, verification, passthrough rules, snooze), up to the one that decides
+the outcome. Passthrough rules are matched against this shell's ancestors.
+
+Arguments after "--" are the command's, for wrappers limited by
+argPathPatterns to invocations naming certain files.
+
+Examples:
+  ribbin which tsc                       # Why is tsc blocked here?
+  ribbin which npm --json                # Machine-readable explanation
+  ribbin which rm -- -f prod.tfstate     # Would this rm be blocked?`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ex, err := wrap.Explain(args[0], args[1:]...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if whichJSON {
+			if err := printWhichJSON(ex); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		printWhichText(ex)
+	},
+}
+
+// whichOutput is the JSON output of 'ribbin which'
+type whichOutput struct {
+	Command     string            `json:"command"`
+	BinaryPath  string            `json:"binary_path,omitempty"`
+	Wrapped     bool              `json:"wrapped"`
+	SidecarPath string            `json:"sidecar_path,omitempty"`
+	ConfigPath  string            `json:"config_path,omitempty"`
+	Scope       *scopeOutput      `json:"scope,omitempty"`
+	Wrapper     *resolvedShimJSON `json:"wrapper,omitempty"`
+	Outcome     string            `json:"outcome"`
+	Reason      string            `json:"reason"`
+	Steps       []whichStepJSON   `json:"steps"`
+}
+
+type whichStepJSON struct {
+	Check  string `json:"check"`
+	Result string `json:"result"`
+}
+
+func printWhichJSON(ex *wrap.Explanation) error {
+	out := whichOutput{
+		Command:     ex.Command,
+		BinaryPath:  ex.BinaryPath,
+		Wrapped:     ex.Wrapped,
+		SidecarPath: ex.SidecarPath,
+		ConfigPath:  ex.ConfigPath,
+		Outcome:     ex.Outcome,
+		Reason:      ex.Reason,
+		Steps:       []whichStepJSON{},
+	}
+	if ex.Scope != nil {
+		out.Scope = &scopeOutput{Name: ex.Scope.Name, Path: ex.Scope.Config.Path}
+	}
+	if ex.Wrapper != nil {
+		wrapper := convertResolvedShimToJSON(*ex.Wrapper)
+		out.Wrapper = &wrapper
+	}
+	for _, s := range ex.Steps {
+		out.Steps = append(out.Steps, whichStepJSON{Check: s.Check, Result: s.Result})
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(out); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return nil
+}
+
+func printWhichText(ex *wrap.Explanation) {
+	fmt.Printf("%s\n", ex.Command)
+
+	binary := ex.BinaryPath
+	if binary == "" {
+		binary = "(not found)"
+	} else if ex.Wrapped {
+		binary += " (wrapped)"
+	} else {
+		binary += " (not wrapped)"
+	}
+	fmt.Printf("  Binary:   %s\n", binary)
+	if ex.SidecarPath != "" {
+		fmt.Printf("  Original: %s\n", ex.SidecarPath)
+	}
+	if ex.Registry != nil {
+		fmt.Printf("  Registry: wrapped by %s\n", ex.Registry.Config)
+	}
+
+	if ex.ConfigPath == "" {
+		fmt.Printf("  Config:   (none found)\n")
+	} else {
+		fmt.Printf("  Config:   %s\n", ex.ConfigPath)
+		if ex.Scope != nil {
+			fmt.Printf("  Scope:    %s (%s)\n", ex.Scope.Name, ex.Scope.Config.Path)
+		} else {
+			fmt.Printf("  Scope:    (root)\n")
+		}
+	}
+	if ex.Wrapper != nil {
+		fmt.Printf("  Wrapper:  %s from %s#%s\n", ex.Wrapper.Config.Action, ex.Wrapper.Source.FilePath, ex.Wrapper.Source.Fragment)
+		if ex.Wrapper.Source.Overrode != nil {
+			fmt.Printf("            overrides %s#%s\n", ex.Wrapper.Source.Overrode.FilePath, ex.Wrapper.Source.Overrode.Fragment)
+		}
+		if ex.Wrapper.Config.Message != "" {
+			fmt.Printf("  Message:  %s\n", ex.Wrapper.Config.Message)
+		}
+	}
+
+	fmt.Printf("\nDecision: %s\n", ex.Outcome)
+	if ex.Reason != "" {
+		fmt.Printf("  %s\n", ex.Reason)
+	}
+
+	fmt.Printf("\nChecks:\n")
+	for i, s := range ex.Steps {
+		fmt.Printf("  %d. %-14s %s\n", i+1, s.Check+":", s.Result)
+	}
+}
+
+func init() {
+	whichCmd.Flags().BoolVar(&whichJSON, "json", false, "Output in JSON format")
+}
@@ -1,10 +1,15 @@
 package cli
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/happycollision/ribbin/internal/cli/render"
 	"github.com/happycollision/ribbin/internal/config"
 	"github.com/happycollision/ribbin/internal/security"
 	"github.com/happycollision/ribbin/internal/wrap"
@@ -12,6 +17,20 @@ import (
 )
 
 var confirmSystemDir bool
+var wrapStrict bool
+var wrapKeepGoing bool
+var wrapDryRun bool
+var wrapAuto bool
+var wrapInteractive bool
+var wrapTags []string
+var wrapRefresh bool
+var wrapPathsFrom string
+var wrapMessage string
+var wrapForce bool
+var wrapJobs int
+var wrapSudo bool
+var wrapAcknowledgeDir bool
+var wrapShadowSigned bool
 
 var wrapCmd = &cobra.Command{
 	Use:   "wrap [config-files...]",
@@ -26,20 +45,120 @@ For each command, ribbin:
   2. Renames it to <original>.ribbin-original
   3. Creates a symlink to ribbin in its place
 
+A wrapper without "paths" wraps the first match on PATH. With --auto or
+--interactive, ribbin instead discovers every binary with that name in the
+project's node_modules/.bin, on PATH, in mise and asdf shim directories, and
+in the "searchPaths" of the config and your settings.
+--auto wraps every candidate that is safe to wrap (not a critical binary, and
+not in a system directory unless --confirm-system-dir is given);
+--interactive lists the candidates and asks which to wrap.
+
 When the wrapped command is later invoked, ribbin intercepts the call and
 takes the configured action (block, warn, or redirect) or passes through to
 the original binary.
 
+With --tag, only wrappers carrying one of the given tags are wrapped.
+
+A config with "sidecarLayout": "project" keeps the originals of binaries under
+its directory in .ribbin/originals there, so a package manager reinstalling
+node_modules can't delete them. After a reinstall replaces wrapped binaries,
+--refresh wraps them again, replacing the originals kept from before and
+including every binary the registry has wrapped for the config.
+
+With --paths-from, ribbin wraps the binaries listed in a file (or stdin, with
+"-") instead of the config's: one absolute path per line, as printed by
+'which -a'. Blank lines and lines starting with # are ignored. Each binary is
+wrapped under the config's wrapper for its name, since wrappers apply by
+command name. For a name the config has no wrapper for, a block wrapper
+limited to the listed paths is added to the config first, with --message as
+its message.
+
+Wrapping is all-or-nothing: if any binary fails to wrap, everything wrapped
+by this run is unwrapped again and ribbin exits with an error. Use
+--keep-going to wrap what can be wrapped and report failures instead.
+
+A binary with other hard links isn't wrapped without --force: only the path
+being wrapped is replaced, so the other links stay unwrapped, and a package
+manager rewriting the file through one of them changes the original. ribbin
+also warns about binaries that running processes are using, as an install
+may still be writing them.
+
+Binaries are wrapped several at a time (--jobs, by default a few per CPU),
+never two in the same directory at once; the results are reported in order
+once all are done, with a progress bar on a terminal meanwhile.
+
+A binary in a directory you can't write, like /usr/local/bin, isn't wrapped
+without --sudo. With it, ribbin lists the commands that make the changes
+there (mv to rename the original, ln to create the symlink, and tee to write
+the metadata) and runs just those through sudo, after the other binaries are
+wrapped; ribbin itself, and your registry, stay unprivileged. Each command is
+recorded in the audit log. --sudo keeps the original next to the binary, so
+it can't be combined with the directory sidecar layout or the copy shim mode.
+
+The first wrap in a system or shared directory (/usr/local/bin, /opt, and the
+"sharedDirs" of security.jsonc) asks once for that directory, showing the
+command that undoes it. Agreeing records a listing of the directory, which
+'ribbin doctor' compares it against later. Without a terminal to ask on,
+pass --acknowledge-dir.
+
+On macOS, renaming a binary signed with a certificate, or running it from
+outside its app bundle, can set off Gatekeeper prompts and notarization
+checks, so ribbin warns before wrapping one. With --shadow-signed, signed
+binaries inside an app bundle are wrapped by shadowing instead: the binary is
+left as it is, and a wrapper with its name goes in ribbin's shadow directory
+(~/.local/state/ribbin/bin), which the hook from 'ribbin activate --shell
+--print-hook' puts at the front of PATH. The wrapper runs the binary from
+its own path. Only one binary per name can be shadowed, and only commands
+found through PATH see the wrapper.
+
 Security:
   - Critical system binaries (bash, sudo, ssh) are never wrapped
   - System directories (/bin, /usr/bin, /sbin) require --confirm-system-dir flag
+  - Shared directories (/usr/local, /opt) need a one-time acknowledgment
   - All other directories are allowed by default
 
 Examples:
   ribbin wrap                            # Wrap commands from nearest ribbin.jsonc
   ribbin wrap ./a.jsonc ./b.jsonc        # Wrap commands from specific configs
-  ribbin wrap --confirm-system-dir       # Allow wrapping in /bin, /usr/bin, etc.`,
+  ribbin wrap --confirm-system-dir       # Allow wrapping in /bin, /usr/bin, etc.
+  ribbin wrap --strict                   # Refuse to wrap if the config has any validation problems
+  ribbin wrap --keep-going               # Don't roll back when one binary fails
+  ribbin wrap --jobs 1                   # Wrap one binary at a time
+  ribbin wrap --sudo --confirm-system-dir  # Wrap in /usr/local/bin through sudo
+  ribbin wrap --dry-run                  # Check what would be wrapped without changing anything
+  ribbin wrap --auto                     # Wrap every safe binary found for wrappers without paths
+  ribbin wrap -i                         # Choose which discovered binaries to wrap
+  ribbin wrap --tag node                 # Wrap only wrappers tagged "node"
+  ribbin wrap --shadow-signed            # Shadow signed app binaries instead of renaming them
+  pnpm install && ribbin wrap --refresh  # Re-wrap binaries the install replaced
+  which -a node | ribbin wrap --paths-from - --message "Use the node from mise"`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if wrapAuto && wrapInteractive {
+			fmt.Fprintf(os.Stderr, "Error: --auto and --interactive cannot be combined\n")
+			os.Exit(1)
+		}
+
+		if err := validateTagFlags(wrapTags); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var listedPaths []string
+		if wrapPathsFrom != "" {
+			if wrapAuto || wrapInteractive || len(wrapTags) > 0 || len(args) > 1 {
+				fmt.Fprintf(os.Stderr, "Error: --paths-from cannot be combined with --auto, --interactive, --tag, or several configs\n")
+				os.Exit(1)
+			}
+			var err error
+			if listedPaths, err = readPathList(wrapPathsFrom); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		} else if wrapMessage != "" {
+			fmt.Fprintf(os.Stderr, "Error: --message only applies with --paths-from\n")
+			os.Exit(1)
+		}
+
 		printGlobalWarningIfActive()
 
 		// Step 1: Check for Local Development Mode
@@ -76,18 +195,20 @@ Examples:
 				os.Exit(1)
 			}
 			if configPath == "" {
-				fmt.Fprintf(os.Stderr, "No ribbin.jsonc found. Run 'ribbin init' to create one.\n")
-				os.Exit(1)
+				fmt.Fprintln(os.Stderr, errNoConfig)
+				os.Exit(ExitConfigNotFound)
 			}
 			configPaths = []string{configPath}
 		}
 
-		// Step 3: Load registry
+		// Step 3: Load registry snapshot. Installs record into this snapshot
+		// and are merged into the on-disk registry under lock at the end.
 		registry, err := config.LoadRegistry()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error loading registry: %v\n", err)
-			os.Exit(1)
+			os.Exit(ExitCode(err))
 		}
+		registryBefore := registry.CloneWrappers()
 
 		// Step 4: Get ribbin binary path
 		execPath, err := os.Executable()
@@ -101,15 +222,228 @@ Examples:
 			os.Exit(1)
 		}
 
-		// Step 5: Process each config file
+		// Step 5: Process each config file. Installs go through a transaction so
+		// that a failure part way through unwraps everything this run wrapped.
 		var wrapped, skipped, failed int
 		var refusedOutsideRepo []string
+		tx := wrap.NewTransaction(registry, ribbinPath)
+
+		// addedWrappers are the block wrappers --paths-from added to a config
+		type addedWrapper struct{ configPath, name string }
+		var addedWrappers []addedWrapper
+
+		// rollback undoes everything this run wrapped, and the wrappers it
+		// added to configs. Used on any failure unless --keep-going was given.
+		// Returns false if some of it could not be undone.
+		rollback := func() bool {
+			ok := true
+			for _, added := range addedWrappers {
+				if err := config.RemoveShim(added.configPath, added.name); err != nil {
+					fmt.Fprintf(os.Stderr, "Cannot remove the wrapper for '%s' added to %s: %v\n", added.name, added.configPath, err)
+					ok = false
+				}
+			}
+			addedWrappers = nil
+			installed := tx.Installed()
+			if len(installed) == 0 {
+				return ok
+			}
+			fmt.Fprintf(os.Stderr, "Rolling back %d wrapper(s) installed by this run...\n", len(installed))
+			if err := tx.Rollback(); err != nil {
+				fmt.Fprintf(os.Stderr, "Rollback incomplete:\n%v\n", err)
+				fmt.Fprintf(os.Stderr, "Run 'ribbin recover' to restore remaining binaries.\n")
+				ok = false
+			}
+			// Record whatever rollback could not undo
+			updateRegistryOrExit(func(latest *config.Registry) {
+				latest.MergeWrapperChanges(registryBefore, registry.Wrappers)
+			})
+			return ok
+		}
 
+		// abort rolls back and exits when a binary fails to wrap, unless --keep-going was given
+		abort := func(cause error) {
+			if wrapKeepGoing {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "\nError: %v\n", cause)
+			if wrapDryRun {
+				os.Exit(ExitCode(cause))
+			}
+			if rollback() {
+				fmt.Fprintf(os.Stderr, "Nothing was wrapped. Use --keep-going to wrap what can be wrapped.\n")
+			} else {
+				fmt.Fprintf(os.Stderr, "Some wrappers installed by this run are still installed. Use --keep-going to wrap what can be wrapped.\n")
+			}
+			os.Exit(ExitCode(cause))
+		}
+		abortPath := func(path string, cause error) {
+			abort(fmt.Errorf("failed to wrap '%s': %w", path, cause))
+		}
+
+		// jobs are the binaries that passed the checks, wrapped on a pool
+		// once every config has been read
+		var jobs []*wrapJob
+		queued := make(map[string]bool)
+
+		// sudoJobs are the binaries in directories only root can write,
+		// wrapped through sudo one at a time after the others
+		var sudoJobs []*wrapJob
+
+		// wrapPath checks the binary at path and queues it to be wrapped for
+		// configPath, reporting why if it isn't
+		wrapPath := func(path, configPath string) {
+			// Check if command exists at this path
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				fmt.Printf("Warning: path '%s' does not exist, skipping\n", path)
+				return
+			}
+
+			// Check if path is a symlink and display information
+			info, err := os.Lstat(path)
+			if err != nil {
+				fmt.Printf("Warning: cannot stat '%s': %v, skipping\n", path, err)
+				return
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				symlinkInfo, err := security.GetSymlinkInfo(path)
+				if err != nil {
+					abortPath(path, fmt.Errorf("%w: unsafe symlink: %v", ErrSecurityRejected, err))
+					fmt.Printf("Skipping unsafe symlink '%s': %v\n", path, err)
+					failed++
+					return
+				}
+				if symlinkInfo.ChainDepth > 0 {
+					fmt.Printf("%s is a symlink ", filepath.Base(path))
+					if symlinkInfo.ChainDepth > 1 {
+						fmt.Printf("(depth %d) ", symlinkInfo.ChainDepth)
+					}
+					fmt.Printf("-> %s\n", symlinkInfo.FinalTarget)
+				}
+			}
+
+			// Check Local Development Mode restrictions
+			if localDevCtx != nil && localDevCtx.IsLocalDev {
+				if err := localDevCtx.ValidateBinaryPath(path); err != nil {
+					refusedOutsideRepo = append(refusedOutsideRepo, path)
+					skipped++
+					return
+				}
+			}
+
+			// Validate binary for wrapping (security check)
+			if err := security.ValidateBinaryForShim(path, confirmSystemDir); err != nil {
+				abortPath(path, fmt.Errorf("%w: %v", ErrSecurityRejected, err))
+				fmt.Printf("Failed to wrap '%s': %v\n", path, err)
+				failed++
+				return
+			}
+
+			// Warn if in confirmation directory
+			if security.RequiresConfirmation(path) && confirmSystemDir {
+				fmt.Fprintf(os.Stderr, "WARNING: Wrapping binary in system directory\n")
+				fmt.Fprintf(os.Stderr, "   Path: %s\n", path)
+				fmt.Fprintf(os.Stderr, "   This may affect all users on the system\n\n")
+			}
+
+			// Check if already wrapped
+			alreadyWrapped, err := wrap.IsAlreadyShimmed(path)
+			if err != nil {
+				fmt.Printf("Warning: could not check if '%s' is wrapped: %v\n", path, err)
+				return
+			}
+			if alreadyWrapped || queued[path] || wrap.IsShadowed(path) {
+				fmt.Printf("Skipping '%s': already wrapped\n", path)
+				skipped++
+				return
+			}
+
+			// Refuse to wrap ribbin itself, checked here so --dry-run reports it
+			if wrap.IsRibbin(path, ribbinPath) {
+				err := &wrap.SelfWrapError{Path: path}
+				abortPath(path, fmt.Errorf("%w: %v", ErrSecurityRejected, err))
+				fmt.Printf("Failed to wrap '%s': %v\n", path, err)
+				failed++
+				return
+			}
+
+			queued[path] = true
+			job := &wrapJob{path: path, configPath: configPath}
+
+			// A signed binary may upset Gatekeeper once renamed; one in an
+			// app bundle can be shadowed on PATH instead, which changes
+			// nothing where it is
+			signature, err := wrap.CodeSignatureOf(path)
+			if err != nil {
+				job.warnings = append(job.warnings, fmt.Sprintf("Warning: could not check the code signature of '%s': %v", path, err))
+			}
+			if signature.Gatekept() {
+				if wrapShadowSigned && wrap.InAppBundle(path) {
+					job.shadow = true
+					jobs = append(jobs, job)
+					return
+				}
+				hint := "wrap it only if it still runs when renamed"
+				if wrap.InAppBundle(path) {
+					hint = "use --shadow-signed to wrap it through PATH instead"
+				}
+				job.warnings = append(job.warnings, fmt.Sprintf("Warning: '%s' is signed by %s; renaming it may set off Gatekeeper prompts (%s)", path, signature.Signer(), hint))
+			}
+
+			if wrap.NeedsSudo(path) {
+				if !wrapSudo {
+					err := fmt.Errorf("permission denied: cannot write %s (use --sudo to make the changes there through sudo)", filepath.Dir(path))
+					abortPath(path, err)
+					fmt.Printf("Failed to wrap '%s': %v\n", path, err)
+					failed++
+					return
+				}
+				plan, err := wrap.PlanSudoInstall(path, ribbinPath, configPath)
+				if err != nil {
+					abortPath(path, err)
+					fmt.Printf("Failed to wrap '%s': %v\n", path, err)
+					failed++
+					return
+				}
+				job.sudoPlan = plan
+				sudoJobs = append(sudoJobs, job)
+				return
+			}
+			jobs = append(jobs, job)
+		}
+
+		// required are the commands the configs require to be wrapped, which
+		// get sentinels for when they are run unwrapped later
+		var required []string
+
+		reader := bufio.NewReader(os.Stdin)
 		for _, configPath := range configPaths {
 			// Load project config
 			projectConfig, err := config.LoadProjectConfig(configPath)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error loading config %s: %v\n", configPath, err)
+				rollback()
+				os.Exit(1)
+			}
+
+			// With --strict, refuse to wrap configs that have any validation errors or warnings
+			if wrapStrict {
+				errs, warnings := config.ValidateConfigFile(configPath)
+				if problems := append(append([]string{}, errs...), warnings...); len(problems) > 0 {
+					fmt.Fprintf(os.Stderr, "Error: %s failed strict validation:\n", configPath)
+					for _, p := range problems {
+						fmt.Fprintf(os.Stderr, "  - %s\n", p)
+					}
+					fmt.Fprintf(os.Stderr, "\nRun 'ribbin config validate %s' for details.\n", configPath)
+					rollback()
+					os.Exit(1)
+				}
+			}
+
+			// Refuse to wrap anything if strictResolve is on and the config doesn't fully resolve
+			if err := config.CheckStrictResolve(projectConfig, configPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: strict resolution failed for %s:\n%v\n", configPath, err)
+				rollback()
 				os.Exit(1)
 			}
 
@@ -117,6 +451,36 @@ Examples:
 				fmt.Printf("Processing %s...\n", configPath)
 			}
 
+			// With --paths-from, wrap the listed binaries instead of the
+			// config's, adding block wrappers for names it has none for
+			if wrapPathsFrom != "" {
+				for _, name := range sortedPathNames(listedPaths) {
+					paths := pathsNamed(listedPaths, name)
+					if !hasWrapperNamed(projectConfig, name) {
+						if wrapDryRun {
+							fmt.Printf("Would add a block wrapper for '%s' to %s\n", name, configPath)
+						} else {
+							rule := config.ShimConfig{Action: "block", Message: wrapMessage, Paths: paths}
+							if err := config.AddShim(configPath, name, rule); err != nil {
+								fmt.Fprintf(os.Stderr, "Error adding a wrapper for '%s' to %s: %v\n", name, configPath, err)
+								rollback()
+								os.Exit(1)
+							}
+							addedWrappers = append(addedWrappers, addedWrapper{configPath, name})
+							fmt.Printf("Added a block wrapper for '%s' to %s\n", name, configPath)
+						}
+					}
+					for _, path := range paths {
+						wrapPath(path, configPath)
+					}
+				}
+				continue
+			}
+
+			if projectConfig.Require != nil {
+				required = append(required, projectConfig.Require.Wrapped...)
+			}
+
 			// Collect all wrappers from root and scopes
 			allWrappers := make(map[string]config.WrapperConfig)
 
@@ -137,17 +501,33 @@ Examples:
 				}
 			}
 
+			searchPaths, err := config.SearchPaths(configPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading search paths for %s: %v\n", configPath, err)
+				rollback()
+				os.Exit(1)
+			}
+
+			names := make([]string, 0, len(allWrappers))
 			for name, wrapperCfg := range allWrappers {
+				// With --tag, skip wrappers that carry none of the tags
+				if len(wrapTags) > 0 && !wrapperCfg.HasAnyTag(wrapTags) {
+					continue
+				}
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				wrapperCfg := allWrappers[name]
 				var paths []string
 
-				// If Paths is empty, resolve via wrap.ResolveCommand
+				// If Paths is empty, find the binary on PATH or discover candidates
 				if len(wrapperCfg.Paths) == 0 {
-					resolvedPath, err := wrap.ResolveCommand(name)
-					if err != nil {
-						fmt.Printf("Warning: command '%s' not found in PATH, skipping\n", name)
+					paths = discoverWrapPaths(name, filepath.Dir(configPath), searchPaths, reader)
+					if len(paths) == 0 {
 						continue
 					}
-					paths = []string{resolvedPath}
 				} else {
 					// Resolve relative paths relative to the config file's directory
 					configDir := filepath.Dir(configPath)
@@ -162,91 +542,133 @@ Examples:
 					}
 				}
 
-				// Process each path
+				// With --refresh, also re-wrap what was wrapped for this
+				// config before, like discovered node_modules/.bin binaries
+				if wrapRefresh {
+					paths = appendRegisteredPaths(paths, registry, configPath, name)
+				}
+
 				for _, path := range paths {
-					// Check if command exists at this path
-					if _, err := os.Stat(path); os.IsNotExist(err) {
-						fmt.Printf("Warning: path '%s' does not exist, skipping\n", path)
-						continue
-					}
+					wrapPath(path, configPath)
+				}
+			}
+		}
 
-					// Check if path is a symlink and display information
-					info, err := os.Lstat(path)
-					if err != nil {
-						fmt.Printf("Warning: cannot stat '%s': %v, skipping\n", path, err)
+		// Ask once before wrapping in each shared directory like /usr/local/bin
+		var queuedPaths []string
+		for _, job := range append(append([]*wrapJob{}, jobs...), sudoJobs...) {
+			if !job.shadow {
+				queuedPaths = append(queuedPaths, job.path)
+			}
+		}
+		if refused := acknowledgeSharedDirs(queuedPaths, reader, wrapAcknowledgeDir, wrapDryRun); len(refused) > 0 {
+			keep := func(queue []*wrapJob) []*wrapJob {
+				var kept []*wrapJob
+				for _, job := range queue {
+					if err, ok := refused[job.path]; ok {
+						abortPath(job.path, err)
+						fmt.Printf("Failed to wrap '%s': %v\n", job.path, err)
+						failed++
 						continue
 					}
-					if info.Mode()&os.ModeSymlink != 0 {
-						symlinkInfo, err := security.GetSymlinkInfo(path)
-						if err != nil {
-							fmt.Printf("Skipping unsafe symlink '%s': %v\n", path, err)
-							failed++
-							continue
-						}
-						if symlinkInfo.ChainDepth > 0 {
-							fmt.Printf("%s is a symlink ", filepath.Base(path))
-							if symlinkInfo.ChainDepth > 1 {
-								fmt.Printf("(depth %d) ", symlinkInfo.ChainDepth)
-							}
-							fmt.Printf("-> %s\n", symlinkInfo.FinalTarget)
-						}
-					}
+					kept = append(kept, job)
+				}
+				return kept
+			}
+			jobs = keep(jobs)
+			sudoJobs = keep(sudoJobs)
+		}
 
-					// Check Local Development Mode restrictions
-					if localDevCtx != nil && localDevCtx.IsLocalDev {
-						if err := localDevCtx.ValidateBinaryPath(path); err != nil {
-							refusedOutsideRepo = append(refusedOutsideRepo, path)
-							skipped++
-							continue
-						}
-					}
+		// Snapshot the directories this wrap touches many binaries in, for
+		// 'ribbin restore-snapshot'
+		if !wrapDryRun {
+			var snapshotPaths []string
+			for _, job := range append(append([]*wrapJob{}, jobs...), sudoJobs...) {
+				if !job.shadow {
+					snapshotPaths = append(snapshotPaths, job.path)
+				}
+			}
+			snapshotBulkDirs(snapshotPaths)
+		}
 
-					// Validate binary for wrapping (security check)
-					if err := security.ValidateBinaryForShim(path, confirmSystemDir); err != nil {
-						fmt.Printf("Failed to wrap '%s': %v\n", path, err)
-						failed++
-						continue
-					}
+		// Wrap the queued binaries, several at a time
+		paths := make([]string, len(jobs))
+		byPath := make(map[string]*wrapJob, len(jobs))
+		for i, job := range jobs {
+			paths[i] = job.path
+			byPath[job.path] = job
+		}
+		progress := render.NewProgress("Wrapping", len(paths))
+		pool := &wrap.Pool{
+			Workers:     wrapJobs,
+			StopOnError: !wrapKeepGoing,
+			Progress:    func(done, _ int) { progress.Update(done) },
+			Verb:        "wrap",
+		}
+		batchErr := pool.Run(paths, func(path string) error {
+			return byPath[path].run(tx)
+		})
+		progress.Done()
 
-					// Warn if in confirmation directory
-					if security.RequiresConfirmation(path) && confirmSystemDir {
-						fmt.Fprintf(os.Stderr, "WARNING: Wrapping binary in system directory\n")
-						fmt.Fprintf(os.Stderr, "   Path: %s\n", path)
-						fmt.Fprintf(os.Stderr, "   This may affect all users on the system\n\n")
-					}
+		for _, job := range jobs {
+			job.report()
+		}
+		if batchErr != nil {
+			abort(batchErr)
+		}
 
-					// Check if already wrapped
-					alreadyWrapped, err := wrap.IsAlreadyShimmed(path)
-					if err != nil {
-						fmt.Printf("Warning: could not check if '%s' is wrapped: %v\n", path, err)
-						continue
-					}
-					if alreadyWrapped {
-						fmt.Printf("Skipping '%s': already wrapped\n", path)
-						skipped++
-						continue
-					}
+		// Wrap the binaries that need sudo, after showing what it will run
+		if len(sudoJobs) > 0 {
+			plans := make([]*wrap.SudoPlan, len(sudoJobs))
+			for i, job := range sudoJobs {
+				plans[i] = job.sudoPlan
+			}
+			printSudoPlans(plans, wrapDryRun)
+		}
+		for _, job := range sudoJobs {
+			job.run(tx)
+			job.report()
+			if job.err != nil {
+				abortPath(job.path, job.err)
+			}
+		}
+		jobs = append(jobs, sudoJobs...)
+
+		for _, job := range jobs {
+			switch {
+			case job.err != nil:
+				failed++
+			case job.started:
+				wrapped++
+			}
+		}
 
-					// Install wrapper
-					if err := wrap.Install(path, ribbinPath, registry, configPath); err != nil {
-						fmt.Printf("Failed to wrap '%s': %v\n", path, err)
-						failed++
-						continue
-					}
+		tx.Commit()
 
-					fmt.Printf("Wrapped '%s'\n", path)
-					wrapped++
-				}
+		if wrapDryRun {
+			fmt.Printf("\nSummary (dry run): %d would be wrapped, %d skipped, %d failed\n", wrapped, skipped, failed)
+			if failed > 0 {
+				os.Exit(1)
 			}
+			return
 		}
 
-		// Step 6: Save registry
-		if err := config.SaveRegistry(registry); err != nil {
-			fmt.Fprintf(os.Stderr, "Error saving registry: %v\n", err)
-			os.Exit(1)
+		// Step 6: Merge our changes into the registry without losing entries
+		// written concurrently by other ribbin processes
+		updateRegistryOrExit(func(latest *config.Registry) {
+			latest.MergeWrapperChanges(registryBefore, registry.Wrappers)
+		})
+
+		// Step 7: Keep sentinels for required commands, which say how to set
+		// the project up if they are ever run unwrapped, as after
+		// reinstalling node_modules
+		if len(required) > 0 {
+			if err := wrap.InstallSentinels(required, ribbinPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
 		}
 
-		// Step 7: Report refused paths in Local Development Mode
+		// Step 8: Report refused paths in Local Development Mode
 		if len(refusedOutsideRepo) > 0 {
 			fmt.Printf("\nRefusing to wrap tools outside the repository:\n")
 			for _, path := range refusedOutsideRepo {
@@ -254,10 +676,10 @@ Examples:
 			}
 		}
 
-		// Step 8: Print summary
+		// Step 9: Print summary
 		fmt.Printf("\nSummary: %d wrapped, %d skipped, %d failed\n", wrapped, skipped, failed)
 
-		// Step 9: Print warning about unwrapping before uninstall
+		// Step 10: Print warning about unwrapping before uninstall
 		if wrapped > 0 {
 			fmt.Fprintf(os.Stderr, "\nIMPORTANT: Run 'ribbin unwrap --global --search' (or 'ribbin recover')\n")
 			fmt.Fprintf(os.Stderr, "before uninstalling ribbin. Failure to do so will result in recoverable,\n")
@@ -266,7 +688,322 @@ Examples:
 	},
 }
 
+// wrapJob is a binary queued to be wrapped, and what happened when it was
+type wrapJob struct {
+	path       string
+	configPath string
+	// sudoPlan is set for a binary wrapped through sudo
+	sudoPlan *wrap.SudoPlan
+	// shadow is set for a signed binary wrapped by shadowing it on PATH
+	shadow bool
+	// started is set once the job has run
+	started bool
+	// warnings go to stderr and notes to stdout, before the outcome
+	warnings []string
+	notes    []string
+	err      error
+}
+
+// run wraps the binary, or in a dry run only inspects it. Output is kept
+// for report, as jobs run at once.
+func (j *wrapJob) run(tx *wrap.Transaction) error {
+	j.started = true
+	j.err = j.wrap(tx)
+	return j.err
+}
+
+func (j *wrapJob) wrap(tx *wrap.Transaction) error {
+	if j.shadow {
+		if wrapDryRun {
+			return nil
+		}
+		return tx.InstallShadow(j.path, j.configPath)
+	}
+
+	// Other hard links stay unwrapped, and a process using the binary may be
+	// an install that hasn't finished
+	usage := wrap.InspectBinary(j.path)
+	if usage.Links > 1 {
+		if !wrapForce {
+			return fmt.Errorf("%s has %d hard links; the others would stay unwrapped and could change the original (use --force to wrap anyway)", j.path, usage.Links)
+		}
+		j.warnings = append(j.warnings, fmt.Sprintf("Warning: '%s' has %d hard links; only this one is wrapped", j.path, usage.Links))
+	}
+	if len(usage.PIDs) > 0 {
+		j.warnings = append(j.warnings, fmt.Sprintf("Warning: '%s' is in use by process %s; if an install is still writing it, run 'ribbin heal' once it finishes", j.path, joinPIDs(usage.PIDs)))
+	}
+
+	if wrapDryRun {
+		return nil
+	}
+
+	// Replace an original kept from before a reinstall
+	if wrapRefresh {
+		discarded, err := wrap.DiscardStaleProjectSidecar(j.path, j.configPath)
+		if err != nil {
+			return err
+		}
+		if discarded {
+			j.notes = append(j.notes, fmt.Sprintf("Refreshing '%s' (reinstalled since it was wrapped)", j.path))
+		}
+	}
+
+	if j.sudoPlan != nil {
+		return tx.InstallSudo(j.sudoPlan, j.configPath)
+	}
+	return tx.Install(j.path, j.configPath)
+}
+
+// report prints the job's warnings, notes and outcome; nothing if it never
+// ran
+func (j *wrapJob) report() {
+	if !j.started {
+		return
+	}
+	for _, warning := range j.warnings {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+	for _, note := range j.notes {
+		fmt.Println(note)
+	}
+	switch {
+	case j.err != nil:
+		// Without --keep-going the failures are reported together
+		if wrapKeepGoing {
+			fmt.Printf("Failed to wrap '%s': %v\n", j.path, j.err)
+		}
+	case j.shadow && wrapDryRun:
+		fmt.Printf("Would wrap '%s' by shadowing it on PATH\n", j.path)
+	case wrapDryRun:
+		fmt.Printf("Would wrap '%s'\n", j.path)
+	case j.shadow:
+		shadowPath, _ := wrap.ShadowPath(j.path)
+		fmt.Printf("Wrapped '%s' by shadowing it with %s\n", j.path, shadowPath)
+		if !wrap.ShadowFirstOnPath(j.path) {
+			fmt.Printf("  Put %s before %s in PATH for the wrapper to run, as the hook from 'ribbin activate --shell --print-hook' does\n",
+				filepath.Dir(shadowPath), filepath.Dir(j.path))
+		}
+	default:
+		fmt.Printf("Wrapped '%s'\n", j.path)
+	}
+}
+
+// discoverWrapPaths returns the binaries to wrap for a wrapper without
+// paths. By default that is the first match on PATH. With --auto it is every
+// discovered candidate that is safe to wrap, and with --interactive the
+// candidates the user picks, including those in the config's search paths.
+// Problems are reported and yield no paths.
+func discoverWrapPaths(name, projectDir string, searchPaths []string, reader *bufio.Reader) []string {
+	if !wrapAuto && !wrapInteractive {
+		if resolvedPath, err := wrap.ResolveCommand(name); err == nil {
+			return []string{resolvedPath}
+		}
+		candidates := wrap.DiscoverCommand(name, projectDir, searchPaths)
+		if len(candidates) == 0 {
+			fmt.Printf("Warning: command '%s' not found in PATH, skipping\n", name)
+			return nil
+		}
+		fmt.Printf("Warning: command '%s' not found in PATH, skipping. Found elsewhere:\n", name)
+		for _, c := range candidates {
+			fmt.Printf("  %s (%s)\n", c.Path, c.Source)
+		}
+		fmt.Printf("  Use --auto or --interactive to wrap these, or list them in \"paths\".\n")
+		return nil
+	}
+
+	candidates := wrap.DiscoverCommand(name, projectDir, searchPaths)
+	if len(candidates) == 0 {
+		fmt.Printf("Warning: command '%s' not found in node_modules/.bin, PATH, mise/asdf shims, or search paths, skipping\n", name)
+		return nil
+	}
+
+	if wrapInteractive {
+		chosen, err := pickCandidates(name, candidates, reader)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v, skipping '%s'\n", err, name)
+			return nil
+		}
+		return chosen
+	}
+
+	var paths []string
+	for _, c := range candidates {
+		if err := security.ValidateBinaryForShim(c.Path, confirmSystemDir); err != nil {
+			fmt.Printf("Not wrapping discovered '%s' (%s): not safe to wrap automatically\n", c.Path, c.Source)
+			continue
+		}
+		fmt.Printf("Discovered '%s' (%s)\n", c.Path, c.Source)
+		paths = append(paths, c.Path)
+	}
+	return paths
+}
+
+// joinPIDs lists process IDs for a message
+func joinPIDs(pids []int) string {
+	parts := make([]string, len(pids))
+	for i, pid := range pids {
+		parts[i] = strconv.Itoa(pid)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// readPathList reads the binaries to wrap for --paths-from from a file, or
+// stdin for "-": one absolute path per line, skipping blank lines, lines
+// starting with #, and repeats
+func readPathList(source string) ([]string, error) {
+	in := os.Stdin
+	if source != "-" {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(in)
+	for line := 1; scanner.Scan(); line++ {
+		path := strings.TrimSpace(scanner.Text())
+		if path == "" || strings.HasPrefix(path, "#") {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			return nil, fmt.Errorf("%s:%d: %q is not an absolute path", source, line, path)
+		}
+		path = filepath.Clean(path)
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no paths in %s", source)
+	}
+	return paths, nil
+}
+
+// sortedPathNames returns the command names of paths, sorted and unique
+func sortedPathNames(paths []string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, path := range paths {
+		if name := filepath.Base(path); !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pathsNamed returns the paths whose command name is name, in order
+func pathsNamed(paths []string, name string) []string {
+	var named []string
+	for _, path := range paths {
+		if filepath.Base(path) == name {
+			named = append(named, path)
+		}
+	}
+	return named
+}
+
+// hasWrapperNamed reports whether the config has a wrapper for name, at the
+// root or in any scope
+func hasWrapperNamed(projectConfig *config.ProjectConfig, name string) bool {
+	if _, ok := projectConfig.Wrappers[name]; ok {
+		return true
+	}
+	for _, scope := range projectConfig.Scopes {
+		if _, ok := scope.Wrappers[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// appendRegisteredPaths adds the binaries named name that the registry has
+// wrapped for configPath to paths, unless already there
+func appendRegisteredPaths(paths []string, registry *config.Registry, configPath, name string) []string {
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		seen[path] = true
+	}
+	for _, entry := range registry.WrappersNamed(name) {
+		if entry.Config == configPath && !seen[entry.Original] {
+			seen[entry.Original] = true
+			paths = append(paths, entry.Original)
+		}
+	}
+	return paths
+}
+
+// pickCandidates lists the discovered binaries for name and reads which to
+// wrap from reader. Returns nil if the user chose none.
+func pickCandidates(name string, candidates []wrap.Candidate, reader *bufio.Reader) ([]string, error) {
+	fmt.Printf("\nFound %d binaries for '%s':\n", len(candidates), name)
+	for i, c := range candidates {
+		note := ""
+		if err := security.ValidateBinaryForShim(c.Path, confirmSystemDir); err != nil {
+			note = "  (not safe to wrap)"
+		} else if wrapped, _ := wrap.IsAlreadyShimmed(c.Path); wrapped {
+			note = "  (already wrapped)"
+		}
+		fmt.Printf("  %2d. %-12s %s%s\n", i+1, c.Source, c.Path, note)
+	}
+	fmt.Print("Wrap which? (e.g. 1,3 or 2-4, 'all', Enter to skip): ")
+
+	input, err := reader.ReadString('\n')
+	if err != nil && input == "" {
+		fmt.Println()
+		return nil, nil
+	}
+
+	indexes, err := parseSelection(input, len(candidates))
+	if err != nil {
+		return nil, err
+	}
+
+	chosen := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		chosen = append(chosen, candidates[i].Path)
+	}
+	return chosen, nil
+}
+
 func init() {
 	wrapCmd.Flags().BoolVar(&confirmSystemDir, "confirm-system-dir", false,
 		"Allow wrapping in system directories like /usr/local/bin (requires understanding security implications)")
+	wrapCmd.Flags().BoolVar(&wrapAcknowledgeDir, "acknowledge-dir", false,
+		"Agree to the first wrap in a shared directory like /usr/local/bin without being asked")
+	wrapCmd.Flags().BoolVar(&wrapStrict, "strict", false,
+		"Refuse to wrap if the config has validation errors or warnings (see 'ribbin config validate')")
+	wrapCmd.Flags().BoolVar(&wrapKeepGoing, "keep-going", false,
+		"Keep wrapping after a failure instead of rolling back everything this run wrapped")
+	wrapCmd.Flags().BoolVar(&wrapDryRun, "dry-run", false,
+		"Show what would be wrapped without making changes; exits non-zero if anything would fail")
+	wrapCmd.Flags().BoolVar(&wrapAuto, "auto", false,
+		"For wrappers without paths, wrap every safe binary found in node_modules/.bin, PATH, mise/asdf shims, and search paths")
+	wrapCmd.Flags().BoolVarP(&wrapInteractive, "interactive", "i", false,
+		"For wrappers without paths, choose which discovered binaries to wrap")
+	wrapCmd.Flags().BoolVar(&wrapRefresh, "refresh", false,
+		"Re-wrap binaries a package manager reinstalled, replacing the originals kept from before")
+	wrapCmd.Flags().BoolVar(&wrapForce, "force", false,
+		"Wrap binaries that have other hard links")
+	wrapCmd.Flags().StringVar(&wrapPathsFrom, "paths-from", "",
+		"Wrap the absolute paths listed in this file, one per line, or stdin with \"-\"")
+	wrapCmd.Flags().StringVar(&wrapMessage, "message", "",
+		"Message for the block wrappers --paths-from adds")
+	wrapCmd.Flags().BoolVar(&wrapSudo, "sudo", false,
+		"Wrap binaries in directories you can't write by running the few commands needed there through sudo")
+	wrapCmd.Flags().BoolVar(&wrapShadowSigned, "shadow-signed", false,
+		"On macOS, wrap signed binaries in app bundles by shadowing them on PATH instead of renaming them")
+	wrapCmd.Flags().IntVarP(&wrapJobs, "jobs", "j", 0,
+		"How many binaries to wrap at once (default: a few per CPU)")
+	wrapCmd.Flags().StringSliceVar(&wrapTags, "tag", nil,
+		"Wrap only wrappers with these tags (comma-separated or repeated)")
 }
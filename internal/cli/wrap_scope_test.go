@@ -234,7 +234,7 @@ func TestWrapCommandWithScopeWrappers(t *testing.T) {
 	// Check that all three commands are in the registry
 	expectedCommands := []string{"tsc", "eslint", "jest"}
 	for _, cmd := range expectedCommands {
-		if _, exists := registry.Wrappers[cmd]; !exists {
+		if !registry.HasCommand(cmd) {
 			t.Errorf("expected command %s to be in registry", cmd)
 		}
 	}
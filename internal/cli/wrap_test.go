@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/security"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestReadPathList(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		t.Helper()
+		path := filepath.Join(dir, "paths.txt")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	paths, err := readPathList(write("/usr/local/bin/node\n\n# nvm\n  /home/me/.nvm/bin/node  \n/usr/local/bin//node\n/opt/bin/npm\n"))
+	if err != nil {
+		t.Fatalf("readPathList error: %v", err)
+	}
+	want := []string{"/usr/local/bin/node", "/home/me/.nvm/bin/node", "/opt/bin/npm"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+	if names := sortedPathNames(paths); !reflect.DeepEqual(names, []string{"node", "npm"}) {
+		t.Errorf("sortedPathNames = %v", names)
+	}
+	if named := pathsNamed(paths, "node"); !reflect.DeepEqual(named, want[:2]) {
+		t.Errorf("pathsNamed = %v", named)
+	}
+
+	if _, err := readPathList(write("/usr/bin/node\nbin/node\n")); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("expected an error for the relative path on line 2, got %v", err)
+	}
+	if _, err := readPathList(write("# nothing\n")); err == nil {
+		t.Error("expected an error for an empty list")
+	}
+}
+
+func TestAcknowledgeSharedDirs(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	sharedDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(configHome, "ribbin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	settings := `{"sharedDirs": ["` + sharedDir + `"]}`
+	if err := os.WriteFile(filepath.Join(configHome, "ribbin", security.SecurityFileName), []byte(settings), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldTerminal := stdinIsTerminal
+	t.Cleanup(func() { stdinIsTerminal = oldTerminal })
+	stdinIsTerminal = func() bool { return false }
+
+	shared := filepath.Join(sharedDir, "tsc")
+	private := filepath.Join(t.TempDir(), "tsc")
+	paths := []string{shared, private}
+
+	refused := acknowledgeSharedDirs(paths, nil, false, true)
+	if len(refused) != 0 || security.IsDirAcknowledged(sharedDir) {
+		t.Errorf("a dry run should neither refuse nor acknowledge, got %v", refused)
+	}
+
+	refused = acknowledgeSharedDirs(paths, nil, false, false)
+	if _, ok := refused[shared]; !ok || len(refused) != 1 {
+		t.Fatalf("without a terminal the shared directory should be refused, got %v", refused)
+	}
+	if ExitCode(refused[shared]) != ExitSecurityRejected {
+		t.Errorf("refusal should be a security rejection: %v", refused[shared])
+	}
+
+	if refused := acknowledgeSharedDirs(paths, nil, true, false); len(refused) != 0 {
+		t.Errorf("--acknowledge-dir should allow the shared directory, got %v", refused)
+	}
+	if !security.IsDirAcknowledged(sharedDir) {
+		t.Error("the shared directory should now be acknowledged")
+	}
+	if refused := acknowledgeSharedDirs(paths, nil, false, false); len(refused) != 0 {
+		t.Errorf("an acknowledged directory should not be asked about again, got %v", refused)
+	}
+}
+
+func TestUnexpectedDirChanges(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"tsc", "node"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\necho "+name+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, err := security.ListDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wrap tsc the way ribbin does, and change the rest behind its back
+	tsc := filepath.Join(dir, "tsc")
+	if err := os.Rename(tsc, tsc+".ribbin-original"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/usr/local/bin/ribbin", tsc); err != nil {
+		t.Fatal(err)
+	}
+	meta := `{"original_hash": "sha256:` + before[1].SHA256 + `"}`
+	if err := os.WriteFile(tsc+".ribbin-meta", []byte(meta), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "node"), []byte("#!/bin/sh\necho other\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "curl"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := security.ListDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := unexpectedDirChanges(dir, before, after)
+	want := security.DirListingDiff{Added: []string{"curl"}, Changed: []string{"node"}}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("unexpectedDirChanges = %+v, want %+v", diff, want)
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A wrapper with argPathPatterns only applies when the command operates on
+// a matching file, e.g. blocking rm only for Terraform state:
+//
+//	"rm": { "action": "block", "argPathPatterns": ["*.tfstate", "migrations/**"] }
+//
+// Each argument (or the value of a --flag=value argument) is resolved
+// against the working directory. A pattern without a slash matches the
+// file's base name anywhere. Other patterns match the file's path relative
+// to the config's directory, or its absolute path if they start with "/".
+// "**" matches any number of directories, including none.
+
+// ValidateArgPathPattern checks that pattern is a usable argPathPatterns entry
+func ValidateArgPathPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("pattern is empty")
+	}
+	for _, segment := range strings.Split(strings.TrimPrefix(pattern, "/"), "/") {
+		if segment == ".." {
+			return fmt.Errorf("pattern %q must not contain '..'", pattern)
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// MatchArgPaths returns the first argument naming a file that matches one of
+// the wrapper's argPathPatterns, for a command run in cwd under the config
+// in configDir. ok is false if none does.
+func (w WrapperConfig) MatchArgPaths(args []string, configDir, cwd string) (arg string, ok bool) {
+	operands := false
+	for _, a := range args {
+		file := a
+		switch {
+		case operands:
+		case a == "--":
+			operands = true
+			continue
+		case strings.HasPrefix(a, "-"):
+			// Only a flag's value can name a file: --state=prod.tfstate
+			_, value, found := strings.Cut(a, "=")
+			if !found {
+				continue
+			}
+			file = value
+		}
+		if file == "" {
+			continue
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(cwd, file)
+		}
+		file = filepath.Clean(file)
+		for _, pattern := range w.ArgPathPatterns {
+			if matchArgPathPattern(pattern, file, configDir) {
+				return a, true
+			}
+		}
+	}
+	return "", false
+}
+
+// matchArgPathPattern reports whether the absolute, clean path file matches
+// pattern (see MatchArgPaths).
+func matchArgPathPattern(pattern, file, configDir string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	switch {
+	case !strings.Contains(pattern, "/"):
+		matched, _ := path.Match(pattern, filepath.Base(file))
+		return matched
+	case strings.HasPrefix(pattern, "/"):
+		return matchSegments(splitPath(pattern), splitPath(filepath.ToSlash(file)))
+	}
+	rel, err := filepath.Rel(configDir, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	return matchSegments(splitPath(pattern), splitPath(filepath.ToSlash(rel)))
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" || p == "." {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches zero or more segments.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
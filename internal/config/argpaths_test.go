@@ -0,0 +1,40 @@
+package config
+
+import (
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestMatchArgPaths(t *testing.T) {
+	w := WrapperConfig{ArgPathPatterns: []string{"*.tfstate", "migrations/**", "/etc/hosts"}}
+	configDir := "/repo"
+
+	tests := []struct {
+		name    string
+		cwd     string
+		args    []string
+		wantArg string
+	}{
+		{"base name", "/repo/infra", []string{"-f", "prod.tfstate"}, "prod.tfstate"},
+		{"base name in another directory", "/repo", []string{"infra/prod.tfstate"}, "infra/prod.tfstate"},
+		{"relative to the config", "/repo/migrations", []string{"2024/001.sql"}, "2024/001.sql"},
+		{"double star matches no directories", "/repo", []string{"migrations"}, "migrations"},
+		{"flag value", "/repo", []string{"--state=prod.tfstate"}, "--state=prod.tfstate"},
+		{"after --", "/repo", []string{"--", "-weird.tfstate"}, "-weird.tfstate"},
+		{"absolute", "/tmp", []string{"/etc/hosts"}, "/etc/hosts"},
+		{"dotdot resolved", "/repo/src", []string{"../migrations/001.sql"}, "../migrations/001.sql"},
+		{"no match", "/repo", []string{"-rf", "node_modules"}, ""},
+		{"flags are not files", "/repo", []string{"-x.tfstate"}, ""},
+		{"outside the config directory", "/other", []string{"migrations/001.sql"}, ""},
+		{"absolute pattern must match whole path", "/tmp", []string{"/etc/hosts.bak"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arg, ok := w.MatchArgPaths(tt.args, configDir, tt.cwd)
+			if arg != tt.wantArg || ok != (tt.wantArg != "") {
+				t.Errorf("MatchArgPaths(%q) = %q, %v; want %q", tt.args, arg, ok, tt.wantArg)
+			}
+		})
+	}
+}
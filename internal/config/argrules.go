@@ -0,0 +1,219 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// A wrapper's argRules give some invocations a different action than the
+// wrapper's own. The first rule that matches wins; when none does, the
+// wrapper's action applies. Rules match on leading arguments, on the
+// package an npx-style command downloads and runs, or both:
+//
+//	"npx": {
+//	  "action": "passthrough",
+//	  "argRules": [
+//	    { "packages": ["create-vite", "@myorg/*"], "action": "passthrough" },
+//	    { "packages": ["*"], "action": "block",
+//	      "message": "{package} is not an approved generator. Use one of: {approved}" }
+//	  ]
+//	}
+//
+// Wrapping npx, bunx, npm, pnpm and yarn this way governs the binaries they
+// fetch on the fly, which are never installed anywhere ribbin could wrap.
+
+// ArgRule overrides a wrapper's action for matching invocations
+type ArgRule struct {
+	// Args are glob patterns the invocation's leading arguments must match,
+	// in order, e.g. ["dlx"] or ["run", "deploy*"]
+	Args []string `json:"args,omitempty"`
+	// Packages are glob patterns for the package the invocation runs (see
+	// EphemeralPackage). "*" matches any package, scoped ones included.
+	Packages []string `json:"packages,omitempty"`
+	// Action replaces the wrapper's: "block", "warn" or "passthrough"
+	Action string `json:"action"`
+	// Message replaces the wrapper's message, if set
+	Message string `json:"message,omitempty"`
+}
+
+// ValidateArgRule checks that rule can match something and has a usable
+// action
+func ValidateArgRule(rule ArgRule) []string {
+	var problems []string
+	if len(rule.Args) == 0 && len(rule.Packages) == 0 {
+		problems = append(problems, "rule needs \"args\" or \"packages\" to match")
+	}
+	switch rule.Action {
+	case "block", "warn", "passthrough":
+	default:
+		problems = append(problems, fmt.Sprintf("action must be \"block\", \"warn\" or \"passthrough\", got %q", rule.Action))
+	}
+	for _, pattern := range append(append([]string{}, rule.Args...), rule.Packages...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid pattern %q: %v", pattern, err))
+		}
+	}
+	return problems
+}
+
+// MatchArgRules returns the first of the wrapper's argRules matching the
+// invocation of command with args
+func (w WrapperConfig) MatchArgRules(command string, args []string) (ArgRule, bool) {
+	pkg, hasPackage := EphemeralPackage(command, args)
+rules:
+	for _, rule := range w.ArgRules {
+		if len(rule.Args) > len(args) {
+			continue
+		}
+		for i, pattern := range rule.Args {
+			if matched, _ := path.Match(pattern, args[i]); !matched {
+				continue rules
+			}
+		}
+		if len(rule.Packages) > 0 && !(hasPackage && matchPackage(rule.Packages, pkg)) {
+			continue
+		}
+		return rule, true
+	}
+	return ArgRule{}, false
+}
+
+// String describes what the rule matches, e.g. `args [dlx], packages [*]`
+func (r ArgRule) String() string {
+	var parts []string
+	if len(r.Args) > 0 {
+		parts = append(parts, fmt.Sprintf("args [%s]", strings.Join(r.Args, " ")))
+	}
+	if len(r.Packages) > 0 {
+		parts = append(parts, fmt.Sprintf("packages [%s]", strings.Join(r.Packages, " ")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// WithArgRule returns the wrapper as rule makes it: rule's action, and its
+// message if it has one
+func (w WrapperConfig) WithArgRule(rule ArgRule) WrapperConfig {
+	w.Action = rule.Action
+	if rule.Message != "" {
+		w.Message = rule.Message
+		w.Messages = nil
+	}
+	return w
+}
+
+// ApprovedPackages lists, for {approved} in messages, the package patterns
+// of the wrapper's passthrough argRules
+func (w WrapperConfig) ApprovedPackages() []string {
+	var approved []string
+	for _, rule := range w.ArgRules {
+		if rule.Action == "passthrough" {
+			approved = append(approved, rule.Packages...)
+		}
+	}
+	return approved
+}
+
+func matchPackage(patterns []string, pkg string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		if matched, _ := path.Match(pattern, pkg); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ephemeralRunners are the commands that download a package and run it,
+// by the argument that makes them do so ("" when they always do)
+var ephemeralRunners = map[string][]string{
+	"npx":  {""},
+	"bunx": {""},
+	"npm":  {"exec", "x", "create", "init"},
+	"pnpm": {"dlx", "create"},
+	"yarn": {"dlx", "create"},
+	"bun":  {"x", "create"},
+}
+
+// npxCallFlags are the flags of npx and the dlx commands that take a
+// command to run as the next argument
+var npxCallFlags = map[string]bool{"-c": true, "--call": true}
+
+// EphemeralPackage returns the name of the package the invocation of
+// command with args downloads and runs, without its version: the package
+// of `npx create-react-app@5`, `pnpm dlx -p cowsay cowsay hi` or
+// `npm create vite` (create-vite). ok is false for any other invocation.
+func EphemeralPackage(command string, args []string) (pkg string, ok bool) {
+	subcommands, known := ephemeralRunners[command]
+	if !known {
+		return "", false
+	}
+	sub := ""
+	if subcommands[0] != "" {
+		if len(args) == 0 {
+			return "", false
+		}
+		sub, args = args[0], args[1:]
+		if !slices.Contains(subcommands, sub) {
+			return "", false
+		}
+	}
+
+	if sub == "create" || sub == "init" {
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "-") {
+				return createPackage(packageName(arg)), true
+			}
+		}
+		return "", false
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if i+1 < len(args) {
+				return packageName(args[i+1]), true
+			}
+			return "", false
+		case strings.HasPrefix(arg, "--package="):
+			return packageName(strings.TrimPrefix(arg, "--package=")), true
+		case arg == "-p" || arg == "--package":
+			if i+1 < len(args) {
+				return packageName(args[i+1]), true
+			}
+			return "", false
+		case npxCallFlags[arg]:
+			i++
+		case strings.HasPrefix(arg, "-"):
+		default:
+			return packageName(arg), true
+		}
+	}
+	return "", false
+}
+
+// packageName strips the version from a package spec: "@scope/pkg@1.2"
+// becomes "@scope/pkg"
+func packageName(spec string) string {
+	if at := strings.LastIndex(spec, "@"); at > 0 {
+		return spec[:at]
+	}
+	return spec
+}
+
+// createPackage returns the package `npm create <initializer>` runs:
+// "vite" is create-vite, "@scope" is @scope/create and "@scope/app" is
+// @scope/create-app
+func createPackage(initializer string) string {
+	if scope, name, scoped := strings.Cut(initializer, "/"); scoped && strings.HasPrefix(scope, "@") {
+		return scope + "/create-" + name
+	}
+	if strings.HasPrefix(initializer, "@") {
+		return initializer + "/create"
+	}
+	return "create-" + initializer
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestEphemeralPackage(t *testing.T) {
+	tests := []struct {
+		command string
+		args    string
+		want    string
+	}{
+		{"npx", "create-react-app my-app", "create-react-app"},
+		{"npx", "--yes create-react-app@5.0.1 my-app", "create-react-app"},
+		{"npx", "-p @angular/cli@17 ng new app", "@angular/cli"},
+		{"npx", "--package=cowsay cowsay hi", "cowsay"},
+		{"npx", "-c eslint", ""},
+		{"bunx", "@biomejs/biome check", "@biomejs/biome"},
+		{"pnpm", "dlx create-vite app", "create-vite"},
+		{"pnpm", "install", ""},
+		{"pnpm", "create vite", "create-vite"},
+		{"yarn", "create @scope/app", "@scope/create-app"},
+		{"npm", "init @scope", "@scope/create"},
+		{"npm", "init -y", ""},
+		{"npm", "exec -- prettier --write .", "prettier"},
+		{"npm", "run build", ""},
+		{"cat", "file", ""},
+	}
+	for _, tt := range tests {
+		got, ok := EphemeralPackage(tt.command, strings.Fields(tt.args))
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("EphemeralPackage(%s %s) = %q, %v, want %q", tt.command, tt.args, got, ok, tt.want)
+		}
+	}
+}
+
+func TestMatchArgRules(t *testing.T) {
+	w := WrapperConfig{
+		Action:  "passthrough",
+		Message: "wrapper message",
+		ArgRules: []ArgRule{
+			{Packages: []string{"create-vite", "@myorg/*"}, Action: "passthrough"},
+			{Args: []string{"dlx"}, Packages: []string{"*"}, Action: "block", Message: "{package} is not approved: {approved}"},
+			{Args: []string{"publish*"}, Action: "warn"},
+		},
+	}
+
+	tests := []struct {
+		args   string
+		action string
+	}{
+		{"dlx create-vite app", "passthrough"},
+		{"dlx @myorg/gen", "passthrough"},
+		{"dlx create-react-app app", "block"},
+		{"dlx @other/gen", "block"},
+		{"publish --access public", "warn"},
+		{"install", ""},
+	}
+	for _, tt := range tests {
+		rule, ok := w.MatchArgRules("pnpm", strings.Fields(tt.args))
+		if tt.action == "" {
+			if ok {
+				t.Errorf("pnpm %s matched %s, want no rule", tt.args, rule)
+			}
+			continue
+		}
+		if !ok || rule.Action != tt.action {
+			t.Errorf("pnpm %s matched %q (%v), want %q", tt.args, rule.Action, ok, tt.action)
+		}
+	}
+
+	rule, _ := w.MatchArgRules("pnpm", []string{"dlx", "create-react-app"})
+	blocked := w.WithArgRule(rule)
+	if blocked.Action != "block" {
+		t.Errorf("WithArgRule action = %q", blocked.Action)
+	}
+	message := blocked.RenderMessage("", MessageVars{Command: "pnpm", Package: "create-react-app"})
+	if message != "create-react-app is not approved: create-vite, @myorg/*" {
+		t.Errorf("message = %q", message)
+	}
+}
+
+func TestValidateArgRule(t *testing.T) {
+	tests := []struct {
+		rule    ArgRule
+		wantErr string
+	}{
+		{ArgRule{Packages: []string{"*"}, Action: "block"}, ""},
+		{ArgRule{Action: "block"}, "needs \"args\" or \"packages\""},
+		{ArgRule{Args: []string{"dlx"}, Action: "redirect"}, "action must be"},
+		{ArgRule{Args: []string{"[dlx"}, Action: "warn"}, "invalid pattern"},
+	}
+	for _, tt := range tests {
+		problems := ValidateArgRule(tt.rule)
+		if tt.wantErr == "" {
+			if len(problems) > 0 {
+				t.Errorf("ValidateArgRule(%+v) = %v, want none", tt.rule, problems)
+			}
+			continue
+		}
+		if len(problems) == 0 || !strings.Contains(strings.Join(problems, "; "), tt.wantErr) {
+			t.Errorf("ValidateArgRule(%+v) = %v, want %q", tt.rule, problems, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// Auditing reviews a config without acting on it, e.g. a shared policy
+// before extending it: it validates the config, resolves its scopes and
+// extends, lists the wrappers that result, and checks what they point at.
+// Nothing is written; remote extends are only read from the local cache.
+
+// Severities of an AuditFinding
+const (
+	// AuditError makes the config unusable as it is
+	AuditError = "error"
+	// AuditDanger is a wrapper or target the security policy refuses or
+	// only allows with confirmation
+	AuditDanger = "danger"
+	// AuditWarning is worth a look but works
+	AuditWarning = "warning"
+)
+
+// AuditFinding is one problem found by AuditConfig
+type AuditFinding struct {
+	Severity string `json:"severity"`
+	// Location is where the problem is: a wrapper as "fragment/command",
+	// or empty for the config as a whole
+	Location string `json:"location,omitempty"`
+	Message  string `json:"message"`
+}
+
+// AuditedWrapper is a wrapper in effect at the root or in a scope of an
+// audited config
+type AuditedWrapper struct {
+	// Fragment is "root" or "root.<scope>"
+	Fragment string
+	Command  string
+	Config   WrapperConfig
+	Source   ShimSource
+}
+
+// ConfigAudit is the result of AuditConfig
+type ConfigAudit struct {
+	ConfigPath string
+	// Files are the other config files the config extends or imports
+	Files    []string
+	Wrappers []AuditedWrapper
+	Findings []AuditFinding
+}
+
+// HasProblems reports whether the audit found errors or dangers
+func (a *ConfigAudit) HasProblems() bool {
+	for _, finding := range a.Findings {
+		if finding.Severity != AuditWarning {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditConfig audits the config at configPath, which may have any name.
+// Remote extends must already be cached; it never fetches them.
+func AuditConfig(configPath string) (*ConfigAudit, error) {
+	absPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return nil, err
+	}
+	audit := &ConfigAudit{ConfigPath: absPath}
+	add := func(severity, location, format string, args ...interface{}) {
+		audit.Findings = append(audit.Findings, AuditFinding{severity, location, fmt.Sprintf(format, args...)})
+	}
+
+	restore := offline()
+	defer restore()
+
+	errors, warnings := ValidateConfigFile(absPath)
+	for _, e := range errors {
+		add(AuditError, "", "%s", e)
+	}
+	for _, w := range warnings {
+		add(AuditWarning, "", "%s", w)
+	}
+
+	cfg, err := LoadExtendsConfig(absPath)
+	if err != nil {
+		if len(errors) == 0 {
+			add(AuditError, "", "%v", err)
+		}
+		return audit, nil
+	}
+
+	resolver := NewResolver()
+	fragments := map[string]map[string]ResolvedShim{}
+	if shims, err := resolver.ResolveEffectiveShimsWithProvenance(cfg, absPath, nil, ""); err == nil {
+		fragments["root"] = shims
+	}
+	for _, name := range sortedKeys(cfg.Scopes) {
+		scope := cfg.Scopes[name]
+		shims, err := resolver.ResolveEffectiveShimsWithProvenance(cfg, absPath, &scope, name)
+		if err != nil {
+			// ValidateConfigFile has reported it
+			continue
+		}
+		fragments["root."+name] = shims
+	}
+
+	files := map[string]bool{}
+	for _, fragment := range sortedKeys(fragments) {
+		for _, command := range sortedKeys(fragments[fragment]) {
+			resolved := fragments[fragment][command]
+			audit.Wrappers = append(audit.Wrappers, AuditedWrapper{
+				Fragment: fragment,
+				Command:  command,
+				Config:   resolved.Config,
+				Source:   resolved.Source,
+			})
+			for source := &resolved.Source; source != nil; source = source.Overrode {
+				if source.FilePath != absPath {
+					files[source.FilePath] = true
+				}
+			}
+		}
+	}
+	for _, path := range append(resolver.LoadedFiles(), cfg.ImportedFiles()...) {
+		if path != absPath {
+			files[path] = true
+		}
+	}
+	audit.Files = sortedKeys(files)
+
+	// A wrapper in effect in several fragments is checked once per definition
+	checked := map[string]bool{}
+	for _, w := range audit.Wrappers {
+		key := sourceLabel(w.Source) + "/" + w.Command
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+		audit.Findings = append(audit.Findings, auditWrapper(w, absPath)...)
+	}
+	sortFindings(audit.Findings)
+	return audit, nil
+}
+
+// auditWrapper checks a wrapper's binaries, redirect and hooks. Relative
+// paths are taken from the directory of configPath, as the config using the
+// wrapper would.
+func auditWrapper(w AuditedWrapper, configPath string) []AuditFinding {
+	var findings []AuditFinding
+	configDir := filepath.Dir(configPath)
+	location := w.Source.Fragment + "/" + w.Command
+	if w.Source.FilePath != configPath {
+		location = displayPath(w.Source.FilePath, configDir) + "#" + location
+	}
+	add := func(severity, format string, args ...interface{}) {
+		findings = append(findings, AuditFinding{severity, location, fmt.Sprintf(format, args...)})
+	}
+
+	if security.IsCriticalSystemBinary(w.Command) {
+		add(AuditDanger, "wraps %s, a critical system binary ribbin refuses to wrap", w.Command)
+	}
+
+	for _, p := range w.Config.Paths {
+		path := absFrom(p, configDir)
+		if _, err := os.Stat(path); err != nil {
+			add(AuditWarning, "path %s does not exist", path)
+		}
+		if filepath.Base(path) != w.Command && security.IsCriticalSystemBinary(path) {
+			add(AuditDanger, "path %s is a critical system binary ribbin refuses to wrap", path)
+		}
+		switch category, _ := security.GetDirectoryCategory(path); category {
+		case security.CategoryForbidden:
+			add(AuditDanger, "path %s is in a directory your security settings forbid wrapping in", path)
+		case security.CategoryRequiresConfirmation:
+			add(AuditDanger, "path %s is in a system directory; wrapping it needs --confirm-system-dir", path)
+		}
+	}
+
+	if w.Config.Action == "redirect" && w.Config.HasRedirect() {
+		if _, builtin := w.Config.BuiltinRedirect(); builtin {
+			// Runs inside the wrapper; validation checks the name
+		} else if w.Config.IsInlineRedirect() {
+			if words, err := w.Config.RedirectCommand(); err == nil {
+				findings = append(findings, auditProgram(location, "redirect", words[0], configDir)...)
+			}
+		} else {
+			findings = append(findings, auditProgram(location, "redirect", w.Config.Redirect, configDir)...)
+		}
+	}
+
+	if hooks := w.Config.Hooks; hooks != nil {
+		for _, hook := range []struct{ name, command string }{{"before", hooks.Before}, {"after", hooks.After}} {
+			if hook.command == "" {
+				continue
+			}
+			if words, err := ParseHookCommand(hook.command); err == nil {
+				findings = append(findings, auditProgram(location, "hooks."+hook.name, words[0], configDir)...)
+			}
+		}
+	}
+	return findings
+}
+
+// auditProgram checks a program a redirect or hook runs. Paths must exist
+// and be executable; bare names are looked up on PATH when the wrapper runs,
+// so only what they are is checked.
+func auditProgram(location, field, program string, configDir string) []AuditFinding {
+	var findings []AuditFinding
+	add := func(severity, format string, args ...interface{}) {
+		findings = append(findings, AuditFinding{severity, location, field + ": " + fmt.Sprintf(format, args...)})
+	}
+
+	if security.IsCriticalSystemBinary(program) {
+		add(AuditDanger, "runs %s, a critical system binary", filepath.Base(program))
+	}
+	if !strings.Contains(program, "/") {
+		return findings
+	}
+
+	path := absFrom(program, configDir)
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		add(AuditError, "%s does not exist", path)
+	case !info.Mode().IsRegular():
+		add(AuditError, "%s is not a regular file", path)
+	case info.Mode().Perm()&0111 == 0:
+		add(AuditError, "%s is not executable", path)
+	case info.Mode().Perm()&0002 != 0:
+		add(AuditDanger, "%s is world-writable, so anyone could change what it runs", path)
+	}
+	if within, err := security.IsWithinDirectory(path, configDir); err == nil && !within {
+		add(AuditWarning, "%s is outside the config's directory", path)
+	}
+	return findings
+}
+
+// displayPath returns path relative to dir if it is inside it
+func displayPath(path, dir string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return "./" + rel
+	}
+	return path
+}
+
+// absFrom returns path made absolute against dir
+func absFrom(path, dir string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(dir, path)
+}
+
+// offline keeps remote extends from being fetched until the returned
+// function is called
+func offline() (restore func()) {
+	previous, set := os.LookupEnv("RIBBIN_OFFLINE")
+	os.Setenv("RIBBIN_OFFLINE", "1")
+	return func() {
+		if set {
+			os.Setenv("RIBBIN_OFFLINE", previous)
+		} else {
+			os.Unsetenv("RIBBIN_OFFLINE")
+		}
+	}
+}
+
+// sortFindings orders findings by severity, then location
+func sortFindings(findings []AuditFinding) {
+	rank := map[string]int{AuditError: 0, AuditDanger: 1, AuditWarning: 2}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if rank[findings[i].Severity] != rank[findings[j].Severity] {
+			return rank[findings[i].Severity] < rank[findings[j].Severity]
+		}
+		return findings[i].Location < findings[j].Location
+	})
+}
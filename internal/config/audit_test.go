@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestAuditConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "ok.sh"), []byte("#!/bin/sh\n"), 0755)
+	os.WriteFile(filepath.Join(dir, "plain.sh"), []byte("#!/bin/sh\n"), 0644)
+	sharedPath := filepath.Join(dir, "shared.jsonc")
+	os.WriteFile(sharedPath, []byte(`{"wrappers": {"tsc": {"action": "redirect", "redirect": "./ok.sh"}}}`), 0644)
+	configPath := filepath.Join(dir, "policy.jsonc")
+	os.WriteFile(configPath, []byte(`{
+  "wrappers": {
+    "npm": {"action": "block", "message": "use pnpm", "paths": ["./node_modules/.bin/npm"]},
+    "sudo": {"action": "block", "message": "no"},
+    "yarn": {"action": "redirect", "redirect": "./plain.sh"},
+    "pnpm": {"action": "redirect", "redirect": "./missing.sh"}
+  },
+  "scopes": {
+    "web": {"path": ".", "extends": ["root", "./shared.jsonc"], "wrappers": {}}
+  }
+}`), 0644)
+
+	audit, err := AuditConfig(configPath)
+	if err != nil {
+		t.Fatalf("AuditConfig error: %v", err)
+	}
+	if os.Getenv("RIBBIN_OFFLINE") != "" {
+		t.Error("RIBBIN_OFFLINE was left set")
+	}
+
+	if len(audit.Files) != 1 || audit.Files[0] != sharedPath {
+		t.Errorf("Files = %v, want [%s]", audit.Files, sharedPath)
+	}
+	var wrappers []string
+	for _, w := range audit.Wrappers {
+		wrappers = append(wrappers, w.Fragment+"/"+w.Command)
+	}
+	want := "root/npm root/pnpm root/sudo root/yarn root.web/npm root.web/pnpm root.web/sudo root.web/tsc root.web/yarn"
+	if got := strings.Join(wrappers, " "); got != want {
+		t.Errorf("wrappers = %s\nwant %s", got, want)
+	}
+
+	findings := map[string]string{}
+	for _, f := range audit.Findings {
+		findings[f.Location+" "+f.Message] = f.Severity
+	}
+	for text, severity := range map[string]string{
+		"root/sudo wraps sudo, a critical system binary ribbin refuses to wrap":            AuditDanger,
+		"root/pnpm redirect: " + filepath.Join(dir, "missing.sh") + " does not exist":      AuditError,
+		"root/yarn redirect: " + filepath.Join(dir, "plain.sh") + " is not executable":     AuditError,
+		"root/npm path " + filepath.Join(dir, "node_modules/.bin/npm") + " does not exist": AuditWarning,
+	} {
+		if got, ok := findings[text]; !ok || got != severity {
+			t.Errorf("missing %s finding %q in %v", severity, text, audit.Findings)
+		}
+	}
+	for text := range findings {
+		if strings.Contains(text, "tsc") {
+			t.Errorf("unexpected finding for the extended tsc wrapper: %s", text)
+		}
+		if strings.Count(text, "sudo") > 2 {
+			t.Errorf("wrapper audited more than once: %s", text)
+		}
+	}
+	if !audit.HasProblems() {
+		t.Error("HasProblems() = false")
+	}
+	if audit.Findings[0].Severity != AuditError {
+		t.Errorf("findings not sorted by severity: %v", audit.Findings)
+	}
+}
+
+func TestAuditConfigInvalid(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "ribbin.jsonc")
+	os.WriteFile(configPath, []byte(`{"wrappers": {"npm": {"action": "block"}}, "scopes": {"a": {"extends": ["./nope.jsonc"]}}}`), 0644)
+
+	audit, err := AuditConfig(configPath)
+	if err != nil {
+		t.Fatalf("AuditConfig error: %v", err)
+	}
+	if !audit.HasProblems() || audit.Findings[0].Severity != AuditError {
+		t.Errorf("findings = %v, want an error for the missing extends file", audit.Findings)
+	}
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BundleVersion is the current format of a registry bundle
+const BundleVersion = 1
+
+// RegistryBundle is the part of a registry that is worth carrying to another
+// machine, as written by 'ribbin registry export': which binaries are wrapped
+// for which configs, and the activations that outlive a shell. Shell
+// activations and snoozes are left out, as they belong to running processes
+// and the moment.
+type RegistryBundle struct {
+	// Version is the bundle format (see BundleVersion)
+	Version int `json:"version"`
+	// ExportedAt is when the bundle was written
+	ExportedAt time.Time `json:"exported_at"`
+	// Home is the home directory of the exporting user, so that paths under
+	// it can be moved to the importing user's
+	Home string `json:"home,omitempty"`
+	// Wrappers are the wrapped binaries, sorted by path
+	Wrappers []WrapperEntry `json:"wrappers"`
+	// ConfigActivations are the configs activated with 'ribbin activate --config'
+	ConfigActivations map[string]ConfigActivationEntry `json:"config_activations,omitempty"`
+	// GlobalActive is whether ribbin was activated everywhere
+	GlobalActive bool `json:"global_active"`
+}
+
+// Export returns the registry's bundle. home is recorded so paths under it
+// can be rewritten on import. Wrappers without a config, like the orphans
+// 'ribbin find' tracks, are left out: there is nothing to wrap them for.
+func (r *Registry) Export(home string) *RegistryBundle {
+	bundle := &RegistryBundle{
+		Version:      BundleVersion,
+		ExportedAt:   time.Now(),
+		Home:         home,
+		Wrappers:     make([]WrapperEntry, 0, len(r.Wrappers)),
+		GlobalActive: r.GlobalActive,
+	}
+	for _, path := range r.WrapperPaths() {
+		if entry := r.Wrappers[path]; filepath.IsAbs(entry.Config) {
+			bundle.Wrappers = append(bundle.Wrappers, entry)
+		}
+	}
+	if len(r.ConfigActivations) > 0 {
+		bundle.ConfigActivations = make(map[string]ConfigActivationEntry, len(r.ConfigActivations))
+		for path, entry := range r.ConfigActivations {
+			bundle.ConfigActivations[path] = entry
+		}
+	}
+	return bundle
+}
+
+// ReadRegistryBundle parses a bundle, refusing formats newer than this
+// ribbin understands
+func ReadRegistryBundle(in io.Reader) (*RegistryBundle, error) {
+	var bundle RegistryBundle
+	if err := json.NewDecoder(in).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("not a registry bundle: %w", err)
+	}
+	if bundle.Version < 1 {
+		return nil, fmt.Errorf("not a registry bundle: no version")
+	}
+	if bundle.Version > BundleVersion {
+		return nil, fmt.Errorf("registry bundle version %d is newer than this ribbin supports (%d); upgrade ribbin", bundle.Version, BundleVersion)
+	}
+	for _, entry := range bundle.Wrappers {
+		if !filepath.IsAbs(entry.Original) || !filepath.IsAbs(entry.Config) {
+			return nil, fmt.Errorf("registry bundle has a relative path: %s", entry.Original)
+		}
+	}
+	return &bundle, nil
+}
+
+// MoveHome rewrites the bundle's paths under the exporting user's home to
+// lie under home instead, for a machine where the user's home is elsewhere.
+// Returns whether anything was rewritten.
+func (b *RegistryBundle) MoveHome(home string) bool {
+	if b.Home == "" || home == "" || filepath.Clean(b.Home) == filepath.Clean(home) {
+		return false
+	}
+	from := filepath.Clean(b.Home)
+	move := func(path string) string {
+		if path == from {
+			return filepath.Clean(home)
+		}
+		if rest, ok := strings.CutPrefix(path, from+string(filepath.Separator)); ok {
+			return filepath.Join(home, rest)
+		}
+		return path
+	}
+
+	for i, entry := range b.Wrappers {
+		b.Wrappers[i].Original = move(entry.Original)
+		b.Wrappers[i].Config = move(entry.Config)
+	}
+	sort.Slice(b.Wrappers, func(i, j int) bool { return b.Wrappers[i].Original < b.Wrappers[j].Original })
+	if len(b.ConfigActivations) > 0 {
+		moved := make(map[string]ConfigActivationEntry, len(b.ConfigActivations))
+		for path, entry := range b.ConfigActivations {
+			moved[move(path)] = entry
+		}
+		b.ConfigActivations = moved
+	}
+	b.Home = home
+	return true
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestRegistryExport(t *testing.T) {
+	registry := newRegistry()
+	registry.AddWrapper(WrapperEntry{Original: "/home/ann/web/node_modules/.bin/tsc", Config: "/home/ann/web/ribbin.jsonc"})
+	registry.AddWrapper(WrapperEntry{Original: "/usr/local/bin/npm", Config: "/home/ann/web/ribbin.jsonc"})
+	registry.AddWrapper(WrapperEntry{Original: "/opt/bin/old", Config: "(discovered orphan)"})
+	registry.AddShellActivation(1234)
+	registry.ConfigActivations["/home/ann/web/ribbin.jsonc"] = ConfigActivationEntry{Tags: []string{"node"}}
+	registry.GlobalActive = true
+
+	bundle := registry.Export("/home/ann")
+	if bundle.Version != BundleVersion || bundle.Home != "/home/ann" || !bundle.GlobalActive {
+		t.Errorf("bundle = %+v", bundle)
+	}
+	if len(bundle.Wrappers) != 2 || bundle.Wrappers[0].Original != "/home/ann/web/node_modules/.bin/tsc" || bundle.Wrappers[1].Original != "/usr/local/bin/npm" {
+		t.Errorf("Wrappers = %+v, want the two with a config, sorted", bundle.Wrappers)
+	}
+	if len(bundle.ConfigActivations) != 1 {
+		t.Errorf("ConfigActivations = %+v", bundle.ConfigActivations)
+	}
+}
+
+func TestReadRegistryBundle(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"valid", `{"version": 1, "wrappers": [{"original": "/bin/x", "config": "/p/ribbin.jsonc"}]}`, ""},
+		{"not JSON", `wrappers`, "not a registry bundle"},
+		{"no version", `{"wrappers": []}`, "no version"},
+		{"newer", `{"version": 99, "wrappers": []}`, "upgrade ribbin"},
+		{"relative path", `{"version": 1, "wrappers": [{"original": "bin/x", "config": "/p/ribbin.jsonc"}]}`, "relative path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadRegistryBundle(strings.NewReader(tt.input))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ReadRegistryBundle() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadRegistryBundle() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegistryBundleMoveHome(t *testing.T) {
+	bundle := &RegistryBundle{
+		Home: "/home/ann",
+		Wrappers: []WrapperEntry{
+			{Original: "/home/ann/web/node_modules/.bin/tsc", Config: "/home/ann/web/ribbin.jsonc"},
+			{Original: "/home/anna/bin/tool", Config: "/home/anna/ribbin.jsonc"},
+			{Original: "/usr/local/bin/npm", Config: "/home/ann/web/ribbin.jsonc"},
+		},
+		ConfigActivations: map[string]ConfigActivationEntry{"/home/ann/web/ribbin.jsonc": {}},
+	}
+	if !bundle.MoveHome("/Users/ann") {
+		t.Fatal("MoveHome() should report a move")
+	}
+	want := []WrapperEntry{
+		{Original: "/Users/ann/web/node_modules/.bin/tsc", Config: "/Users/ann/web/ribbin.jsonc"},
+		{Original: "/home/anna/bin/tool", Config: "/home/anna/ribbin.jsonc"},
+		{Original: "/usr/local/bin/npm", Config: "/Users/ann/web/ribbin.jsonc"},
+	}
+	for i, entry := range want {
+		if bundle.Wrappers[i] != entry {
+			t.Errorf("Wrappers[%d] = %+v, want %+v", i, bundle.Wrappers[i], entry)
+		}
+	}
+	if _, ok := bundle.ConfigActivations["/Users/ann/web/ribbin.jsonc"]; !ok {
+		t.Errorf("ConfigActivations = %+v, want the config moved", bundle.ConfigActivations)
+	}
+	if bundle.MoveHome("/Users/ann") {
+		t.Error("MoveHome() to the same home should change nothing")
+	}
+}
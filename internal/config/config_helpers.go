@@ -4,15 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-
-	"github.com/tailscale/hujson"
 )
 
-// AddShim adds a new shim configuration to the ribbin.jsonc file.
+// AddShim adds a new shim configuration to the config file.
 // Returns an error if the command already exists.
 func AddShim(configPath, cmdName string, shimConfig ShimConfig) error {
 	// Load existing config
-	config, err := LoadProjectConfig(configPath)
+	config, err := loadConfigFile(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -38,7 +36,7 @@ func AddShim(configPath, cmdName string, shimConfig ShimConfig) error {
 // Returns an error if the command doesn't exist.
 func RemoveShim(configPath, cmdName string) error {
 	// Load existing config
-	config, err := LoadProjectConfig(configPath)
+	config, err := loadConfigFile(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -59,7 +57,7 @@ func RemoveShim(configPath, cmdName string) error {
 // Returns an error if the command doesn't exist.
 func UpdateShim(configPath, cmdName string, shimConfig ShimConfig) error {
 	// Load existing config
-	config, err := LoadProjectConfig(configPath)
+	config, err := loadConfigFile(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -91,13 +89,11 @@ func atomicWrite(configPath string, config *ProjectConfig) error {
 		}
 	}
 
-	// Encode config to JSON with indentation
-	data, err := json.MarshalIndent(config, "", "  ")
+	// Encode config in the same format as the file
+	data, err := encodeConfig(configPath, config)
 	if err != nil {
 		return fmt.Errorf("failed to encode config: %w", err)
 	}
-	// Add trailing newline
-	data = append(data, '\n')
 
 	// Write to temporary file
 	tmpPath := configPath + ".tmp"
@@ -112,11 +108,11 @@ func atomicWrite(configPath string, config *ProjectConfig) error {
 		return fmt.Errorf("failed to read temp file for validation: %w", err)
 	}
 
-	// Use hujson to standardize (in case we ever add comments)
-	standardJSON, err := hujson.Standardize(testData)
+	// Standardize using the config's format (in case we ever add comments)
+	standardJSON, err := StandardizeConfig(configPath, testData)
 	if err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("validation failed (invalid JSONC): %w", err)
+		return fmt.Errorf("validation failed: %w", err)
 	}
 
 	var testConfig ProjectConfig
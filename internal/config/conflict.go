@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Several sources can define the same wrapper: a config's imports, the
+// extends of a scope, the scope's own wrappers, a parent config it merges
+// with, and the user config. By default the later one wins, in the order
+// they are merged. A config's "onConflict" changes that:
+//
+//	"last"     the later definition wins (the default), ignoring priority
+//	"highest"  the definition with the higher "priority" wins, the later one on a tie
+//	"error"    the higher priority wins; definitions that differ with the
+//	           same priority are an error
+//
+// A config's policy governs the merges made while resolving it, including
+// those inside the files it extends or imports, the merge of its wrappers
+// over its parent config's, and the merge of the user config beneath it.
+
+// Conflict policies for ProjectConfig.OnConflict
+const (
+	OnConflictLast    = "last"
+	OnConflictHighest = "highest"
+	OnConflictError   = "error"
+)
+
+// ErrWrapperConflict is returned when onConflict is "error" and two
+// definitions of a wrapper differ with the same priority
+var ErrWrapperConflict = errors.New("conflicting wrapper definitions")
+
+// laterWins reports whether incoming, a definition of the wrapper name merged
+// after existing, takes its place under policy. describe names the two
+// sources for the error when they conflict.
+func laterWins(policy, name string, existing, incoming WrapperConfig, describe func() (earlier, later string)) (bool, error) {
+	if policy != OnConflictHighest && policy != OnConflictError {
+		return true, nil
+	}
+	if incoming.Priority != existing.Priority {
+		return incoming.Priority > existing.Priority, nil
+	}
+	if policy == OnConflictError && !reflect.DeepEqual(existing, incoming) {
+		earlier, later := describe()
+		return false, fmt.Errorf("%w: %q is defined by %s and %s, both with priority %d (set a higher \"priority\" on the one that should win)",
+			ErrWrapperConflict, name, earlier, later, incoming.Priority)
+	}
+	return true, nil
+}
+
+// sourceLabel describes where a wrapper definition came from in errors
+func sourceLabel(source ShimSource) string {
+	return source.FilePath + "#" + source.Fragment
+}
+
+// governedBy makes config's onConflict the policy for merges until the
+// returned function restores the previous one
+func (r *Resolver) governedBy(config *ProjectConfig) func() {
+	previous := r.onConflict
+	r.onConflict = config.OnConflict
+	return func() { r.onConflict = previous }
+}
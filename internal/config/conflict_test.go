@@ -0,0 +1,158 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestResolveEffectiveShims_OnConflict(t *testing.T) {
+	newConfig := func(onConflict string, rootPriority, ownPriority int) *ProjectConfig {
+		return &ProjectConfig{
+			OnConflict: onConflict,
+			Wrappers: map[string]ShimConfig{
+				"npm": {Action: "block", Message: "org policy", Priority: rootPriority},
+				"tsc": {Action: "block", Message: "same everywhere"},
+			},
+			Scopes: map[string]ScopeConfig{
+				"web": {
+					Extends: []string{"root"},
+					Wrappers: map[string]ShimConfig{
+						"npm": {Action: "passthrough", Priority: ownPriority},
+						"tsc": {Action: "block", Message: "same everywhere"},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		onConflict   string
+		rootPriority int
+		ownPriority  int
+		wantAction   string
+		wantErr      bool
+	}{
+		{"last ignores priority", OnConflictLast, 10, 0, "passthrough", false},
+		{"default is last", "", 10, 0, "passthrough", false},
+		{"highest priority wins", OnConflictHighest, 10, 0, "block", false},
+		{"highest tie goes to last", OnConflictHighest, 0, 0, "passthrough", false},
+		{"error resolved by priority", OnConflictError, 0, 5, "passthrough", false},
+		{"error on a tie", OnConflictError, 0, 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newConfig(tt.onConflict, tt.rootPriority, tt.ownPriority)
+			scope := config.Scopes["web"]
+
+			shims, err := NewResolver().ResolveEffectiveShims(config, "/project/ribbin.jsonc", &scope)
+			resolved, provErr := NewResolver().ResolveEffectiveShimsWithProvenance(config, "/project/ribbin.jsonc", &scope, "web")
+			if tt.wantErr {
+				if !errors.Is(err, ErrWrapperConflict) || !errors.Is(provErr, ErrWrapperConflict) {
+					t.Fatalf("expected conflict errors, got %v and %v", err, provErr)
+				}
+				return
+			}
+			if err != nil || provErr != nil {
+				t.Fatalf("unexpected errors: %v, %v", err, provErr)
+			}
+			if shims["npm"].Action != tt.wantAction || resolved["npm"].Config.Action != tt.wantAction {
+				t.Errorf("npm action = %q (provenance %q), want %q", shims["npm"].Action, resolved["npm"].Config.Action, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestResolveEffectiveShimsWithProvenance_KeptDefinitionRecordsOverride(t *testing.T) {
+	config := &ProjectConfig{
+		OnConflict: OnConflictHighest,
+		Wrappers: map[string]ShimConfig{
+			"npm": {Action: "block", Priority: 10},
+		},
+		Scopes: map[string]ScopeConfig{
+			"web": {
+				Extends:  []string{"root"},
+				Wrappers: map[string]ShimConfig{"npm": {Action: "passthrough"}},
+			},
+		},
+	}
+	scope := config.Scopes["web"]
+	resolved, err := NewResolver().ResolveEffectiveShimsWithProvenance(config, "/project/ribbin.jsonc", &scope, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := resolved["npm"].Source
+	if source.Fragment != "root" || source.Overrode == nil || source.Overrode.Fragment != "root.web" {
+		t.Errorf("npm should come from root, overriding root.web; got %+v", source)
+	}
+}
+
+func TestImportsOnConflict(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	writeFile("org.jsonc", `{"wrappers": {"npm": {"action": "block", "message": "org", "priority": 100}}}`)
+	writeFile("team.jsonc", `{"wrappers": {"npm": {"action": "block", "message": "team"}}}`)
+
+	configPath := writeFile("ribbin.jsonc", `{
+  "onConflict": "highest",
+  "imports": ["./org.jsonc", "./team.jsonc"],
+  "wrappers": {"npm": {"action": "passthrough"}}
+}`)
+	cfg, err := LoadProjectConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig error: %v", err)
+	}
+	if got := cfg.Wrappers["npm"]; got.Message != "org" {
+		t.Errorf("the org's higher priority npm should win, got %+v", got)
+	}
+	if names := cfg.OwnWrappers(configPath); len(names) != 0 {
+		t.Errorf("the config's own npm lost, so it has no own wrappers in effect, got %v", names)
+	}
+
+	writeFile("ribbin.jsonc", `{
+  "onConflict": "error",
+  "imports": ["./team.jsonc"],
+  "wrappers": {"npm": {"action": "passthrough"}}
+}`)
+	if _, err := LoadProjectConfig(configPath); !errors.Is(err, ErrWrapperConflict) {
+		t.Errorf("expected a conflict error, got %v", err)
+	}
+}
+
+func TestMergeUserWrappers_OnConflict(t *testing.T) {
+	user := map[string]ResolvedShim{
+		"sudo": {Config: ShimConfig{Action: "block", Priority: 1}, Source: ShimSource{FilePath: "/home/me/ribbin.jsonc", Fragment: UserFragment}},
+	}
+	project := map[string]ResolvedShim{
+		"sudo": {Config: ShimConfig{Action: "passthrough"}, Source: ShimSource{FilePath: "/project/ribbin.jsonc", Fragment: "root"}},
+	}
+
+	merged, err := MergeUserWrappers(user, project, OnConflictLast)
+	if err != nil || merged["sudo"].Config.Action != "passthrough" {
+		t.Errorf("by default the project wins, got %+v, %v", merged["sudo"], err)
+	}
+
+	merged, err = MergeUserWrappers(user, project, OnConflictHighest)
+	if err != nil || merged["sudo"].Config.Action != "block" {
+		t.Errorf("the user's higher priority should win, got %+v, %v", merged["sudo"], err)
+	}
+
+	user["sudo"] = ResolvedShim{Config: ShimConfig{Action: "block"}, Source: user["sudo"].Source}
+	if _, err := MergeUserWrappers(user, project, OnConflictError); !errors.Is(err, ErrWrapperConflict) {
+		t.Errorf("expected a conflict error, got %v", err)
+	}
+	if _, err := MergeUserShims(map[string]ShimConfig{"sudo": {Action: "block"}}, map[string]ShimConfig{"sudo": {Action: "passthrough"}}, OnConflictError); !errors.Is(err, ErrWrapperConflict) {
+		t.Errorf("expected a conflict error without provenance, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// envNamePattern matches environment variable names a wrapper may set
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// envPatternPattern matches the names and globs of a wrapper's env "unset"
+// and "allowOnly"
+var envPatternPattern = regexp.MustCompile(`^[A-Za-z0-9_*?\[\]^-]+$`)
+
+// Keys of a wrapper's env that scrub the caller's environment when their
+// value is a list rather than a string
+const (
+	EnvUnsetKey     = "unset"
+	EnvAllowOnlyKey = "allowOnly"
+)
+
+// ValidateEnvName checks that name can be set through a wrapper or scope
+// "env". RIBBIN_* variables are reserved, since ribbin sets them for redirect
+// targets and reads them in wrappers.
+func ValidateEnvName(name string) error {
+	if !envNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid environment variable name %q", name)
+	}
+	if strings.HasPrefix(strings.ToUpper(name), "RIBBIN_") {
+		return fmt.Errorf("%s is reserved: RIBBIN_* variables can't be set through env", name)
+	}
+	return nil
+}
+
+// withScopeEnv returns shim with scopeEnv added to its env. Variables the
+// wrapper sets itself take precedence over the scope's.
+func withScopeEnv(shim ShimConfig, scopeEnv map[string]string) ShimConfig {
+	if len(scopeEnv) == 0 {
+		return shim
+	}
+	env := make(map[string]string, len(scopeEnv)+len(shim.Env))
+	for name, value := range scopeEnv {
+		env[name] = value
+	}
+	for name, value := range shim.Env {
+		env[name] = value
+	}
+	shim.Env = env
+	return shim
+}
+
+// decodeWrapperEnv reads a wrapper's "env": variables to set, with string
+// values, and the "unset" and "allowOnly" lists. A string "unset" or
+// "allowOnly" is a variable of that name.
+func (w *WrapperConfig) decodeWrapperEnv(data json.RawMessage) error {
+	w.Env = nil
+	w.EnvUnset = nil
+	w.EnvAllowOnly = nil
+	if trimmed := strings.TrimSpace(string(data)); trimmed == "" || trimmed == "null" {
+		return nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("env must be an object: %w", err)
+	}
+	for name, value := range raw {
+		if strings.HasPrefix(strings.TrimSpace(string(value)), "[") {
+			var list *[]string
+			switch name {
+			case EnvUnsetKey:
+				list = &w.EnvUnset
+			case EnvAllowOnlyKey:
+				list = &w.EnvAllowOnly
+			default:
+				return fmt.Errorf("env: %s must be a string", name)
+			}
+			if err := json.Unmarshal(value, list); err != nil {
+				return fmt.Errorf("env: %s must be a list of variable names: %w", name, err)
+			}
+			if *list == nil {
+				*list = []string{}
+			}
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return fmt.Errorf("env: %s must be a string", name)
+		}
+		if w.Env == nil {
+			w.Env = make(map[string]string)
+		}
+		w.Env[name] = s
+	}
+	return nil
+}
+
+// encodeWrapperEnv returns a wrapper's "env" as decodeWrapperEnv reads it
+func (w WrapperConfig) encodeWrapperEnv() map[string]interface{} {
+	if len(w.Env) == 0 && w.EnvUnset == nil && w.EnvAllowOnly == nil {
+		return nil
+	}
+	env := make(map[string]interface{}, len(w.Env)+2)
+	for name, value := range w.Env {
+		env[name] = value
+	}
+	if w.EnvUnset != nil {
+		env[EnvUnsetKey] = w.EnvUnset
+	}
+	if w.EnvAllowOnly != nil {
+		env[EnvAllowOnlyKey] = w.EnvAllowOnly
+	}
+	return env
+}
+
+// ValidateEnvPattern checks a name or glob of a wrapper's env "unset" or
+// "allowOnly". RIBBIN_* variables are reserved: ribbin passes them on
+// whatever the lists say.
+func ValidateEnvPattern(pattern string) error {
+	if !envPatternPattern.MatchString(pattern) {
+		return fmt.Errorf("invalid environment variable name or pattern %q", pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	if strings.HasPrefix(strings.ToUpper(pattern), "RIBBIN_") {
+		return fmt.Errorf("%s is reserved: RIBBIN_* variables are always passed on", pattern)
+	}
+	return nil
+}
+
+// MatchEnvPattern reports whether the variable name matches one of the
+// names or globs of a wrapper's env "unset" or "allowOnly"
+func MatchEnvPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ScrubsEnv reports whether the wrapper removes variables from the caller's
+// environment
+func (w WrapperConfig) ScrubsEnv() bool {
+	return len(w.EnvUnset) > 0 || w.EnvAllowOnly != nil
+}
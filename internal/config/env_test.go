@@ -0,0 +1,69 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestWrapperEnv(t *testing.T) {
+	input := `{"action":"passthrough","env":{"TF_IN_AUTOMATION":"1","allowOnly":["PATH","TF_*"],"unset":["AWS_*"]}}`
+	var w WrapperConfig
+	if err := json.Unmarshal([]byte(input), &w); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(w.Env, map[string]string{"TF_IN_AUTOMATION": "1"}) {
+		t.Errorf("Env = %v", w.Env)
+	}
+	if !reflect.DeepEqual(w.EnvUnset, []string{"AWS_*"}) || !reflect.DeepEqual(w.EnvAllowOnly, []string{"PATH", "TF_*"}) {
+		t.Errorf("EnvUnset = %v, EnvAllowOnly = %v", w.EnvUnset, w.EnvAllowOnly)
+	}
+	if out, err := json.Marshal(w); err != nil || string(out) != input {
+		t.Errorf("round trip = %s, %v; want %s", out, err, input)
+	}
+
+	t.Run("strings are variables", func(t *testing.T) {
+		var w WrapperConfig
+		if err := json.Unmarshal([]byte(`{"env":{"unset":"1"}}`), &w); err != nil {
+			t.Fatal(err)
+		}
+		if w.Env["unset"] != "1" || w.ScrubsEnv() {
+			t.Errorf("Env = %v, EnvUnset = %v", w.Env, w.EnvUnset)
+		}
+	})
+
+	t.Run("an empty allowOnly passes nothing on", func(t *testing.T) {
+		var w WrapperConfig
+		if err := json.Unmarshal([]byte(`{"env":{"allowOnly":[]}}`), &w); err != nil {
+			t.Fatal(err)
+		}
+		if w.EnvAllowOnly == nil || !w.ScrubsEnv() {
+			t.Errorf("EnvAllowOnly = %#v", w.EnvAllowOnly)
+		}
+	})
+
+	for _, bad := range []string{`{"env":{"PATH":["/bin"]}}`, `{"env":{"NPM_TOKEN":1}}`, `{"env":{"unset":[1]}}`} {
+		var w WrapperConfig
+		if err := json.Unmarshal([]byte(bad), &w); err == nil {
+			t.Errorf("Unmarshal(%s) should fail", bad)
+		}
+	}
+}
+
+func TestMatchEnvPattern(t *testing.T) {
+	patterns := []string{"NPM_TOKEN", "AWS_*"}
+	for name, want := range map[string]bool{
+		"NPM_TOKEN":         true,
+		"AWS_PROFILE":       true,
+		"AWS_":              true,
+		"NPM_TOKEN_OLD":     false,
+		"MY_AWS_PROFILE":    false,
+		"GOOGLE_CREDENTIAL": false,
+	} {
+		if got := MatchEnvPattern(patterns, name); got != want {
+			t.Errorf("MatchEnvPattern(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ExpiresLayout is the format of WrapperConfig.Expires
+const ExpiresLayout = "2006-01-02"
+
+// Actions for WrapperConfig.ExpiredAction
+const (
+	ExpiredWarn        = "warn"
+	ExpiredPassthrough = "passthrough"
+)
+
+// ExpiryDate parses the wrapper's "expires" date, at the start of that day
+// in local time. The zero time means it never expires.
+func (w WrapperConfig) ExpiryDate() (time.Time, error) {
+	if w.Expires == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.ParseInLocation(ExpiresLayout, w.Expires, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD", w.Expires)
+	}
+	return date, nil
+}
+
+// Expired reports whether the wrapper's "expires" date has been reached at
+// now. A wrapper with an invalid date never expires.
+func (w WrapperConfig) Expired(now time.Time) bool {
+	date, err := w.ExpiryDate()
+	return err == nil && !date.IsZero() && !now.Before(date)
+}
+
+// ActionAfterExpiry returns the action an expired wrapper takes instead of
+// its own: ExpiredWarn unless ExpiredAction says otherwise.
+func (w WrapperConfig) ActionAfterExpiry() string {
+	if w.ExpiredAction == "" {
+		return ExpiredWarn
+	}
+	return w.ExpiredAction
+}
+
+// Effective returns the wrapper as it applies at now: once it has expired,
+// its action is replaced by ActionAfterExpiry, and onlyFrom no longer
+// limits who may run it.
+func (w WrapperConfig) Effective(now time.Time) WrapperConfig {
+	if w.Expired(now) {
+		w.Action = w.ActionAfterExpiry()
+		w.OnlyFrom = nil
+	}
+	return w
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestWrapperExpiry(t *testing.T) {
+	before := time.Date(2025, 8, 31, 23, 59, 0, 0, time.Local)
+	on := time.Date(2025, 9, 1, 0, 0, 0, 0, time.Local)
+
+	w := WrapperConfig{Action: "block", Expires: "2025-09-01"}
+	if w.Expired(before) {
+		t.Error("wrapper should not be expired the day before")
+	}
+	if got := w.Effective(before).Action; got != "block" {
+		t.Errorf("action before expiry = %q, want block", got)
+	}
+	if !w.Expired(on) {
+		t.Error("wrapper should be expired from the start of its date")
+	}
+	if got := w.Effective(on).Action; got != ExpiredWarn {
+		t.Errorf("action after expiry = %q, want %q", got, ExpiredWarn)
+	}
+
+	w.ExpiredAction = ExpiredPassthrough
+	if got := w.Effective(on).Action; got != ExpiredPassthrough {
+		t.Errorf("action after expiry = %q, want %q", got, ExpiredPassthrough)
+	}
+
+	if (WrapperConfig{Action: "block"}).Expired(on) {
+		t.Error("a wrapper without a date never expires")
+	}
+	invalid := WrapperConfig{Action: "block", Expires: "September 1"}
+	if _, err := invalid.ExpiryDate(); err == nil {
+		t.Error("expected an error for an invalid date")
+	}
+	if invalid.Expired(on) {
+		t.Error("a wrapper with an invalid date never expires")
+	}
+}
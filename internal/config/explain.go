@@ -0,0 +1,195 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Candidate statuses in a ResolutionExplanation
+const (
+	// CandidateEffective is the definition that takes effect
+	CandidateEffective = "effective"
+	// CandidateOverridden was merged for this directory but lost to the
+	// effective definition
+	CandidateOverridden = "overridden"
+	// CandidateNotApplied isn't merged for this directory at all
+	CandidateNotApplied = "not applied"
+)
+
+// WrapperCandidate is one definition of a wrapper that could apply to a
+// command, and what became of it
+type WrapperCandidate struct {
+	// Source is where the definition is, without an Overrode chain
+	Source ShimSource
+	// Config is the definition as written
+	Config ShimConfig
+	// Status is CandidateEffective, CandidateOverridden or CandidateNotApplied
+	Status string
+	// Reason explains the status
+	Reason string
+}
+
+// ResolutionExplanation is how a command's wrapper is resolved in a
+// directory: every definition of it in the configs involved, and which one
+// takes effect
+type ResolutionExplanation struct {
+	Command string
+	// ConfigPath is the nearest project config, empty if there is none
+	ConfigPath string
+	// UserConfigPath is the user config, empty if there is none
+	UserConfigPath string
+	// Scope is the scope of ConfigPath matching the directory, nil for root
+	Scope *MatchedScope
+	// Candidates are in the order the configs merge: the user config, then
+	// parent configs, then the project config, then extended files
+	Candidates []WrapperCandidate
+	// Effective is the definition in effect, nil if the command has none
+	Effective *ResolvedShim
+}
+
+// ExplainResolution explains how the wrapper for command is resolved in the
+// current directory, as GetEffectiveConfigForCwd resolves it.
+func ExplainResolution(command string) (*ResolutionExplanation, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	configPath, matchedScope, shims, err := GetEffectiveConfigForCwd()
+	if err != nil {
+		return nil, err
+	}
+	userPath, err := FindUserConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	ex := &ResolutionExplanation{Command: command, UserConfigPath: userPath}
+	if configPath != userPath {
+		ex.ConfigPath = configPath
+		ex.Scope = matchedScope
+	}
+	if resolved, ok := shims[command]; ok {
+		ex.Effective = &resolved
+	}
+
+	// Every definition that was merged is on the effective one's chain
+	merged := make(map[string]bool)
+	var chain []ShimSource
+	if ex.Effective != nil {
+		for source := &ex.Effective.Source; source != nil; source = source.Overrode {
+			merged[sourceLabel(*source)] = true
+			chain = append(chain, *source)
+		}
+	}
+
+	seen := make(map[string]bool)
+	add := func(source ShimSource, shim ShimConfig, unmerged string) {
+		source.Overrode = nil
+		label := sourceLabel(source)
+		if seen[label] {
+			return
+		}
+		seen[label] = true
+		candidate := WrapperCandidate{Source: source, Config: shim}
+		switch {
+		case ex.Effective != nil && label == sourceLabel(ex.Effective.Source):
+			candidate.Status = CandidateEffective
+		case merged[label]:
+			candidate.Status = CandidateOverridden
+			candidate.Reason = sourceLabel(ex.Effective.Source) + " takes precedence"
+		default:
+			candidate.Status = CandidateNotApplied
+			candidate.Reason = unmerged
+		}
+		ex.Candidates = append(ex.Candidates, candidate)
+	}
+
+	resolver := NewResolver()
+
+	// addRoot adds a config's root definition, and those of the files it
+	// imports
+	addRoot := func(cfg *ProjectConfig, path, fragment, unmerged string) {
+		if _, ok := cfg.Wrappers[command]; !ok {
+			return
+		}
+		for source := cfg.WrapperSource(command, path); ; source = *source.Overrode {
+			if source.FilePath == path {
+				source.Fragment = fragment
+				add(source, cfg.Wrappers[command], unmerged)
+			} else if imported, err := resolver.loadExternalConfig(source.FilePath); err == nil {
+				add(source, imported.Wrappers[command], unmerged)
+			}
+			if source.Overrode == nil {
+				break
+			}
+		}
+	}
+
+	if userPath != "" {
+		if userConfig, err := resolver.loadExternalConfig(userPath); err == nil {
+			addRoot(userConfig, userPath, UserFragment, "not used in this directory")
+		}
+	}
+
+	if ex.ConfigPath != "" {
+		configChain, err := ConfigChain(ex.ConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(configChain) - 1; i >= 0; i-- {
+			path := configChain[i]
+			cfg, err := LoadProjectConfig(path)
+			if err != nil {
+				return nil, err
+			}
+			resolver.store(path, cfg)
+			scope := FindMatchingScope(cfg, filepath.Dir(path), cwd)
+
+			rootReason := "not used in this directory"
+			if scope != nil {
+				rootReason = fmt.Sprintf("scope %q matches this directory and doesn't extend root", scope.Name)
+			}
+			addRoot(cfg, path, "root", rootReason)
+
+			for _, name := range sortedKeys(cfg.Scopes) {
+				shim, ok := cfg.Scopes[name].Wrappers[command]
+				if !ok {
+					continue
+				}
+				reason := fmt.Sprintf("scope %q doesn't match this directory", name)
+				if scope != nil && scope.Name == name {
+					reason = fmt.Sprintf("excluded by the tags of scope %q", name)
+				}
+				add(ShimSource{FilePath: path, Fragment: "root." + name}, shim, reason)
+			}
+			// Resolve every scope so the files they extend are loaded
+			resolver.ResolveAllScopes(cfg, path)
+		}
+	}
+
+	// Definitions in extended files
+	var extended []string
+	for path := range resolver.cache {
+		if path != userPath {
+			extended = append(extended, path)
+		}
+	}
+	sort.Strings(extended)
+	for _, path := range extended {
+		cfg := resolver.cache[path].config
+		addRoot(cfg, path, "root", "not extended by a scope matching this directory")
+		for _, name := range sortedKeys(cfg.Scopes) {
+			if shim, ok := cfg.Scopes[name].Wrappers[command]; ok {
+				add(ShimSource{FilePath: path, Fragment: "root." + name}, shim, "not extended by a scope matching this directory")
+			}
+		}
+	}
+
+	// Anything else merged, whose definition couldn't be read back
+	for _, source := range chain {
+		add(source, ShimConfig{}, "")
+	}
+	return ex, nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestExplainResolution(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", t.TempDir())
+	projectDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	origDir, _ := os.Getwd()
+	defer os.Chdir(origDir)
+
+	userPath := filepath.Join(configHome, "ribbin", ConfigFileName)
+	os.MkdirAll(filepath.Dir(userPath), 0755)
+	os.WriteFile(userPath, []byte(`{"wrappers": {"npm": {"action": "warn"}}}`), 0644)
+
+	configPath := filepath.Join(projectDir, ConfigFileName)
+	sharedPath := filepath.Join(projectDir, "shared.jsonc")
+	os.WriteFile(sharedPath, []byte(`{"wrappers": {"npm": {"action": "redirect", "redirect": "./npm.sh"}}}`), 0644)
+	os.WriteFile(configPath, []byte(`{
+  "wrappers": {"npm": {"action": "block", "message": "use pnpm"}},
+  "scopes": {
+    "api": {"path": "apps/api", "extends": ["./shared.jsonc"], "wrappers": {}},
+    "docs": {"path": "apps/docs", "wrappers": {"npm": {"action": "passthrough"}}},
+    "web": {"path": "apps/web", "extends": ["root"], "wrappers": {"npm": {"action": "block", "message": "use bun"}}}
+  }
+}`), 0644)
+	webDir := filepath.Join(projectDir, "apps", "web")
+	os.MkdirAll(webDir, 0755)
+	if err := os.Chdir(webDir); err != nil {
+		t.Fatal(err)
+	}
+
+	ex, err := ExplainResolution("npm")
+	if err != nil {
+		t.Fatalf("ExplainResolution error: %v", err)
+	}
+	if ex.ConfigPath != configPath || ex.UserConfigPath != userPath {
+		t.Errorf("paths = %q, %q", ex.ConfigPath, ex.UserConfigPath)
+	}
+	if ex.Scope == nil || ex.Scope.Name != "web" {
+		t.Fatalf("scope = %+v, want web", ex.Scope)
+	}
+	if ex.Effective == nil || ex.Effective.Config.Message != "use bun" {
+		t.Fatalf("effective = %+v, want the web scope's", ex.Effective)
+	}
+
+	want := []struct {
+		label  string
+		status string
+	}{
+		{userPath + "#user", CandidateOverridden},
+		{configPath + "#root", CandidateOverridden},
+		{configPath + "#root.docs", CandidateNotApplied},
+		{configPath + "#root.web", CandidateEffective},
+		{sharedPath + "#root", CandidateNotApplied},
+	}
+	if len(ex.Candidates) != len(want) {
+		t.Fatalf("got %d candidates, want %d: %+v", len(ex.Candidates), len(want), ex.Candidates)
+	}
+	for i, w := range want {
+		c := ex.Candidates[i]
+		if label := sourceLabel(c.Source); label != w.label || c.Status != w.status {
+			t.Errorf("candidate %d = %s %s (%s), want %s %s", i, label, c.Status, c.Reason, w.label, w.status)
+		}
+	}
+	if reason := ex.Candidates[2].Reason; reason != `scope "docs" doesn't match this directory` {
+		t.Errorf("docs reason = %q", reason)
+	}
+
+	t.Run("command without a wrapper", func(t *testing.T) {
+		ex, err := ExplainResolution("yarn")
+		if err != nil {
+			t.Fatalf("ExplainResolution error: %v", err)
+		}
+		if ex.Effective != nil || len(ex.Candidates) != 0 {
+			t.Errorf("expected nothing for yarn, got %+v", ex)
+		}
+	})
+}
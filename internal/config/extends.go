@@ -1,11 +1,86 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/happycollision/ribbin/internal/security"
 )
 
+// ExtendsList is a scope's extends entries. In a config file each entry is
+// a reference string, or an object pinning an external file to the SHA-256
+// of its content:
+//
+//	"extends": ["root", {"path": "./team/ribbin.jsonc", "sha256": "<hex>"}]
+//
+// A pinned entry is kept as its reference with a "?sha256=<hex>" pin, the
+// form ParseExtendsRef reads.
+type ExtendsList []string
+
+// UnmarshalJSON reads extends entries written as strings or pinned objects
+func (l *ExtendsList) UnmarshalJSON(data []byte) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	list := make(ExtendsList, 0, len(items))
+	for _, item := range items {
+		var ref string
+		if err := json.Unmarshal(item, &ref); err == nil {
+			list = append(list, ref)
+			continue
+		}
+
+		var pinned struct {
+			Path   string `json:"path"`
+			SHA256 string `json:"sha256"`
+		}
+		decoder := json.NewDecoder(bytes.NewReader(item))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&pinned); err != nil {
+			return fmt.Errorf("extends entries must be strings or {\"path\", \"sha256\"} objects: %w", err)
+		}
+		ref, err := pinnedRef(pinned.Path, pinned.SHA256)
+		if err != nil {
+			return err
+		}
+		list = append(list, ref)
+	}
+	*l = list
+	return nil
+}
+
+// pinnedRef returns the reference for an extends entry pinning path to checksum
+func pinnedRef(path, checksum string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("pinned extends entry needs a path")
+	}
+	if isLocalRef(path) {
+		return "", fmt.Errorf("extends %q is in the same file; only external files can be pinned", path)
+	}
+	if checksum == "" {
+		return path, nil
+	}
+
+	location, fragment := splitFileAndFragment(path)
+	if _, existing, err := splitChecksum(location); err != nil || existing != "" {
+		return "", fmt.Errorf("extends %q is already pinned with ?sha256=", path)
+	}
+	sep := "?"
+	if strings.Contains(location, "?") {
+		sep = "&"
+	}
+	ref := location + sep + "sha256=" + strings.ToLower(checksum)
+	if fragment != "" {
+		ref += "#" + fragment
+	}
+	return ref, nil
+}
+
 // ExtendsRef represents a parsed extends reference.
 // Extends references allow scopes to inherit shims from other sources.
 type ExtendsRef struct {
@@ -18,6 +93,16 @@ type ExtendsRef struct {
 	Fragment string
 	// IsLocal is true for same-file references ("root" or "root.scope-name").
 	IsLocal bool
+	// URL is the https URL to fetch for remote references (https:// or github:).
+	// FilePath is empty until the resolver fetches it (see FetchRemoteConfig).
+	URL string
+	// Checksum is the pinned hex SHA-256 of an external config, if any.
+	Checksum string
+}
+
+// IsRemote returns true if the reference points at a remote config.
+func (r *ExtendsRef) IsRemote() bool {
+	return r.URL != ""
 }
 
 // ParseExtendsRef parses an extends reference string and returns an ExtendsRef.
@@ -30,6 +115,10 @@ type ExtendsRef struct {
 //   - "../other.jsonc" → file path resolved relative to configDir, fragment="" (entire file)
 //   - "./file.jsonc#root.x" → file path resolved, fragment="root.x"
 //   - "/abs/path/ribbin.jsonc" → absolute path, fragment=""
+//   - "https://example.com/policy.jsonc#root.x" → remote, fragment="root.x"
+//   - "github:org/repo/path/ribbin.jsonc@v1" → remote, fetched from raw.githubusercontent.com
+//   - "https://...?sha256=<hex>" → remote, pinned to the given checksum
+//   - "./file.jsonc?sha256=<hex>#root.x" → file path resolved, pinned to the given checksum
 func ParseExtendsRef(ref string, configDir string) (*ExtendsRef, error) {
 	if ref == "" {
 		return nil, fmt.Errorf("extends reference cannot be empty")
@@ -44,8 +133,21 @@ func ParseExtendsRef(ref string, configDir string) (*ExtendsRef, error) {
 		}, nil
 	}
 
-	// It's a file reference, possibly with a fragment
+	// Remote references (https:// or github:), possibly pinned and with a fragment
+	if isRemoteRef(ref) {
+		remote, err := parseRemoteRef(ref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extends reference %q: %w", ref, err)
+		}
+		return remote, nil
+	}
+
+	// It's a file reference, possibly pinned and with a fragment
 	filePath, fragment := splitFileAndFragment(ref)
+	filePath, checksum, err := splitChecksum(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extends reference %q: %w", ref, err)
+	}
 
 	if filePath == "" {
 		return nil, fmt.Errorf("invalid extends reference %q: missing file path", ref)
@@ -61,6 +163,7 @@ func ParseExtendsRef(ref string, configDir string) (*ExtendsRef, error) {
 		FilePath: resolvedPath,
 		Fragment: fragment,
 		IsLocal:  false,
+		Checksum: checksum,
 	}, nil
 }
 
@@ -115,3 +218,28 @@ func resolveFilePath(filePath string, configDir string) (string, error) {
 	resolved := filepath.Join(configDir, filePath)
 	return filepath.Clean(resolved), nil
 }
+
+// loadPinnedExtendsConfig loads a config file referenced via a pinned
+// extends entry, refusing it unless its content hashes to checksum. The
+// content checked is the content parsed.
+func loadPinnedExtendsConfig(path, checksum string) (*ProjectConfig, error) {
+	if err := security.ValidateExtendsConfigPath(path); err != nil {
+		return nil, fmt.Errorf("invalid extends config path: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if got := sha256Hex(data); got != checksum {
+		return nil, fmt.Errorf("%w for %s: expected sha256 %s, got %s", ErrChecksumMismatch, path, checksum, got)
+	}
+
+	config, err := parseConfigData(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyImports(config, path); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
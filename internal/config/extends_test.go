@@ -1,6 +1,9 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -293,3 +296,98 @@ func TestSplitFileAndFragment(t *testing.T) {
 		})
 	}
 }
+
+func TestExtendsList_UnmarshalJSON(t *testing.T) {
+	pin := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name    string
+		json    string
+		want    []string
+		wantErr string
+	}{
+		{"strings", `["root", "./team.jsonc"]`, []string{"root", "./team.jsonc"}, ""},
+		{"pinned file", `[{"path": "./team.jsonc", "sha256": "` + pin + `"}]`, []string{"./team.jsonc?sha256=" + pin}, ""},
+		{"pinned file with fragment", `[{"path": "./team.jsonc#root.web", "sha256": "` + strings.ToUpper(pin) + `"}]`, []string{"./team.jsonc?sha256=" + pin + "#root.web"}, ""},
+		{"pinned remote with query", `[{"path": "https://example.com/p.jsonc?v=2", "sha256": "` + pin + `"}]`, []string{"https://example.com/p.jsonc?v=2&sha256=" + pin}, ""},
+		{"same file can't be pinned", `[{"path": "root.web", "sha256": "` + pin + `"}]`, nil, "only external files"},
+		{"pinned twice", `[{"path": "https://example.com/p.jsonc?sha256=` + pin + `", "sha256": "` + pin + `"}]`, nil, "already pinned"},
+		{"misspelled key", `[{"path": "./team.jsonc", "sha265": "` + pin + `"}]`, nil, "unknown field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ExtendsList
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal error: %v", err)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExtendsRef_PinnedFile(t *testing.T) {
+	pin := strings.Repeat("ab", 32)
+	ref, err := ParseExtendsRef("./team.jsonc?sha256="+pin+"#root.web", "/project")
+	if err != nil {
+		t.Fatalf("ParseExtendsRef error: %v", err)
+	}
+	if ref.FilePath != "/project/team.jsonc" || ref.Fragment != "root.web" || ref.Checksum != pin {
+		t.Errorf("got %+v", ref)
+	}
+
+	if _, err := ParseExtendsRef("./team.jsonc?sha256=abc", "/project"); err == nil {
+		t.Error("expected an error for a malformed pin")
+	}
+}
+
+func TestResolveEffectiveShims_PinnedFile(t *testing.T) {
+	dir := t.TempDir()
+	teamPath := filepath.Join(dir, "team.jsonc")
+	team := []byte(`{"wrappers": {"npm": {"action": "block", "message": "Use pnpm"}}}`)
+	if err := os.WriteFile(teamPath, team, 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "ribbin.jsonc")
+
+	resolve := func(pin string) (map[string]ShimConfig, error) {
+		t.Helper()
+		content := `{"scopes": {"app": {"path": ".", "extends": [{"path": "./team.jsonc", "sha256": "` + pin + `"}]}}}`
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadProjectConfig(configPath)
+		if err != nil {
+			t.Fatalf("LoadProjectConfig error: %v", err)
+		}
+		scope := cfg.Scopes["app"]
+		if _, err := NewResolver().ResolveEffectiveShimsWithProvenance(cfg, configPath, &scope, "app"); err != nil {
+			return nil, err
+		}
+		return NewResolver().ResolveEffectiveShims(cfg, configPath, &scope)
+	}
+
+	shims, err := resolve(sha256Hex(team))
+	if err != nil {
+		t.Fatalf("matching pin: unexpected error: %v", err)
+	}
+	if shims["npm"].Action != "block" {
+		t.Errorf("npm should be inherited from the pinned file, got %+v", shims)
+	}
+
+	// The shared file is changed after it was pinned
+	if err := os.WriteFile(teamPath, []byte(`{"wrappers": {"npm": {"action": "passthrough"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolve(sha256Hex(team)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("tampered file: error = %v, want ErrChecksumMismatch", err)
+	}
+}
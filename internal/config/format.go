@@ -0,0 +1,140 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/tailscale/hujson"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFormat identifies the on-disk syntax of a config file.
+type ConfigFormat int
+
+const (
+	// FormatJSONC is JSON with comments (ribbin.jsonc, and any .json/.jsonc file)
+	FormatJSONC ConfigFormat = iota
+	// FormatTOML is TOML (ribbin.toml)
+	FormatTOML
+	// FormatYAML is YAML (ribbin.yaml, and any .yml file)
+	FormatYAML
+)
+
+// String returns the human-readable name of the format.
+func (f ConfigFormat) String() string {
+	switch f {
+	case FormatTOML:
+		return "TOML"
+	case FormatYAML:
+		return "YAML"
+	default:
+		return "JSONC"
+	}
+}
+
+// DetectFormat determines the config format from a file's extension.
+// Unknown extensions are treated as JSONC, which was the only format
+// before TOML and YAML support was added.
+func DetectFormat(path string) ConfigFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return FormatTOML
+	case ".yaml", ".yml":
+		return FormatYAML
+	default:
+		return FormatJSONC
+	}
+}
+
+// StandardizeConfig converts config file content in any supported format to
+// standard JSON. All formats share the JSON field names, so the rest of the
+// loading and validation pipeline is format-agnostic.
+func StandardizeConfig(path string, data []byte) ([]byte, error) {
+	switch DetectFormat(path) {
+	case FormatTOML:
+		var doc map[string]interface{}
+		if _, err := toml.Decode(string(data), &doc); err != nil {
+			return nil, fmt.Errorf("invalid TOML: %w", err)
+		}
+		return json.Marshal(doc)
+
+	case FormatYAML:
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		if doc == nil {
+			// An empty YAML document is an empty config
+			doc = map[string]interface{}{}
+		}
+		return json.Marshal(doc)
+
+	default:
+		standardJSON, err := hujson.Standardize(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSONC: %w", err)
+		}
+		return standardJSON, nil
+	}
+}
+
+// encodeConfig serializes a config in the format matching the file extension.
+// The config is first encoded as JSON so all formats use the same field names.
+func encodeConfig(path string, config *ProjectConfig) ([]byte, error) {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	format := DetectFormat(path)
+	if format == FormatJSONC {
+		return append(data, '\n'), nil
+	}
+
+	// Decode into a generic map, keeping integers as integers (TOML and YAML
+	// would otherwise render passthrough depth as a float)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	normalized := normalizeJSONNumbers(doc)
+
+	if format == FormatTOML {
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(normalized); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return yaml.Marshal(normalized)
+}
+
+// normalizeJSONNumbers recursively replaces json.Number values with int64 or float64.
+func normalizeJSONNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = normalizeJSONNumbers(item)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = normalizeJSONNumbers(item)
+		}
+		return val
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	default:
+		return v
+	}
+}
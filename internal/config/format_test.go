@@ -0,0 +1,231 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+const tomlConfig = `
+[wrappers.npm]
+action = "block"
+message = "Use pnpm"
+
+[wrappers.tsc]
+action = "block"
+paths = ["./node_modules/.bin/tsc"]
+
+[wrappers.tsc.passthrough]
+invocation = ["pnpm run typecheck"]
+depth = 2
+
+[scopes.frontend]
+path = "apps/frontend"
+extends = ["root", "./base.yaml"]
+
+[scopes.frontend.wrappers.yarn]
+action = "block"
+`
+
+const yamlConfig = `
+wrappers:
+  npm:
+    action: block
+    message: Use pnpm
+  tsc:
+    action: block
+    paths: ["./node_modules/.bin/tsc"]
+    passthrough:
+      invocation: ["pnpm run typecheck"]
+      depth: 2
+scopes:
+  frontend:
+    path: apps/frontend
+    extends: ["root", "./base.toml"]
+    wrappers:
+      yarn:
+        action: block
+`
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		path string
+		want ConfigFormat
+	}{
+		{"/p/ribbin.jsonc", FormatJSONC},
+		{"/p/base.json", FormatJSONC},
+		{"/p/ribbin.toml", FormatTOML},
+		{"/p/ribbin.local.toml", FormatTOML},
+		{"/p/ribbin.yaml", FormatYAML},
+		{"/p/base.yml", FormatYAML},
+		{"/p/noext", FormatJSONC},
+	}
+
+	for _, tt := range tests {
+		if got := DetectFormat(tt.path); got != tt.want {
+			t.Errorf("DetectFormat(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLoadProjectConfig_TOMLAndYAML(t *testing.T) {
+	tests := []struct {
+		fileName string
+		content  string
+	}{
+		{TOMLConfigFileName, tomlConfig},
+		{YAMLConfigFileName, yamlConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), tt.fileName)
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			cfg, err := LoadProjectConfig(configPath)
+			if err != nil {
+				t.Fatalf("LoadProjectConfig error = %v", err)
+			}
+
+			if cfg.Wrappers["npm"].Message != "Use pnpm" {
+				t.Errorf("npm message = %q, want %q", cfg.Wrappers["npm"].Message, "Use pnpm")
+			}
+			tsc := cfg.Wrappers["tsc"]
+			if len(tsc.Paths) != 1 || tsc.Paths[0] != "./node_modules/.bin/tsc" {
+				t.Errorf("tsc paths = %v", tsc.Paths)
+			}
+			if tsc.Passthrough == nil || tsc.Passthrough.Depth == nil || *tsc.Passthrough.Depth != 2 {
+				t.Errorf("tsc passthrough depth not parsed: %+v", tsc.Passthrough)
+			}
+			frontend, ok := cfg.Scopes["frontend"]
+			if !ok {
+				t.Fatal("expected frontend scope")
+			}
+			if frontend.Path != "apps/frontend" || len(frontend.Extends) != 2 {
+				t.Errorf("frontend scope = %+v", frontend)
+			}
+			if _, ok := frontend.Wrappers["yarn"]; !ok {
+				t.Error("expected yarn wrapper in frontend scope")
+			}
+		})
+	}
+}
+
+func TestResolveExtends_AcrossFormats(t *testing.T) {
+	dir := t.TempDir()
+
+	// JSONC config extends a TOML file, which extends a YAML file
+	if err := os.WriteFile(filepath.Join(dir, "base.yaml"), []byte("wrappers:\n  rm:\n    action: block\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tomlBase := "[wrappers.curl]\naction = \"warn\"\n\n[scopes.inner]\nextends = [\"./base.yaml\"]\n"
+	if err := os.WriteFile(filepath.Join(dir, "team.toml"), []byte(tomlBase), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, ConfigFileName)
+	content := `{"scopes": {"app": {"path": ".", "extends": ["./team.toml"]}}}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadProjectConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig error = %v", err)
+	}
+	scope := cfg.Scopes["app"]
+	shims, err := NewResolver().ResolveEffectiveShims(cfg, configPath, &scope)
+	if err != nil {
+		t.Fatalf("ResolveEffectiveShims error = %v", err)
+	}
+
+	if shims["curl"].Action != "warn" {
+		t.Errorf("expected curl from TOML base, got %+v", shims["curl"])
+	}
+	if shims["rm"].Action != "block" {
+		t.Errorf("expected rm from YAML base, got %+v", shims["rm"])
+	}
+}
+
+func TestAddShim_PreservesFormat(t *testing.T) {
+	tests := []struct {
+		fileName string
+		content  string
+	}{
+		{TOMLConfigFileName, tomlConfig},
+		{YAMLConfigFileName, yamlConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), tt.fileName)
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			if err := AddShim(configPath, "curl", ShimConfig{Action: "warn", Message: "careful"}); err != nil {
+				t.Fatalf("AddShim error = %v", err)
+			}
+
+			// The rewritten file must still parse in its own format
+			cfg, err := LoadProjectConfig(configPath)
+			if err != nil {
+				t.Fatalf("LoadProjectConfig after AddShim error = %v", err)
+			}
+			if cfg.Wrappers["curl"].Message != "careful" {
+				t.Errorf("curl wrapper not added: %+v", cfg.Wrappers["curl"])
+			}
+			tsc := cfg.Wrappers["tsc"]
+			if tsc.Passthrough == nil || tsc.Passthrough.Depth == nil || *tsc.Passthrough.Depth != 2 {
+				t.Errorf("tsc passthrough depth lost on rewrite: %+v", tsc.Passthrough)
+			}
+		})
+	}
+}
+
+func TestFindProjectConfig_Formats(t *testing.T) {
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(origDir)
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(""), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(name string) {
+		t.Helper()
+		found, err := FindProjectConfig()
+		if err != nil {
+			t.Fatalf("FindProjectConfig error = %v", err)
+		}
+		if found != filepath.Join(dir, name) {
+			t.Errorf("found %q, want %q", found, name)
+		}
+	}
+
+	write(YAMLConfigFileName)
+	expect(YAMLConfigFileName)
+
+	write(TOMLConfigFileName)
+	expect(TOMLConfigFileName)
+
+	write(ConfigFileName)
+	expect(ConfigFileName)
+
+	write(LocalYAMLConfigFileName)
+	expect(LocalYAMLConfigFileName)
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// GraphNode is one node of a config's extends graph: a config's root
+// wrappers, a scope, or a whole external file. A config's imports are
+// extended by its root node. Built by BuildExtendsGraph.
+type GraphNode struct {
+	// Ref is how the node was referenced: "root", "root.<scope>", or an extends or imports entry
+	Ref string
+	// FilePath is the config file holding the node (the cached copy for remote configs)
+	FilePath string
+	// Fragment is "root", "root.<scope>", or "" for a whole file
+	Fragment string
+	// ScopePath is the directory a scope applies to, if it has one
+	ScopePath string
+	// Wrappers are the wrappers the node defines itself, sorted
+	Wrappers []string
+	// Overridden maps each of Wrappers that doesn't take effect at the top of
+	// the graph to the source that wins there
+	Overridden map[string]ShimSource
+	// Extends are the nodes this node inherits from, in order
+	Extends []*GraphNode
+	// Err is set if the node can't be resolved (missing file or fragment, cycle)
+	Err error
+}
+
+// BuildExtendsGraph returns one graph per place wrappers take effect in
+// config: its root wrappers, then each scope in name order. Each graph
+// follows the scope's extends, and marks wrappers that are overridden on the
+// way up using the same provenance as ResolveEffectiveShimsWithProvenance.
+func BuildExtendsGraph(config *ProjectConfig, configPath string) []*GraphNode {
+	r := NewResolver()
+
+	fragments := []string{"root"}
+	for _, name := range sortedKeys(config.Scopes) {
+		fragments = append(fragments, "root."+name)
+	}
+
+	graphs := make([]*GraphNode, 0, len(fragments))
+	for _, fragment := range fragments {
+		node := r.buildGraphNode(config, configPath, fragment, fragment, make(map[string]bool))
+
+		var effective map[string]ResolvedShim
+		var err error
+		if fragment == "root" {
+			effective, err = r.ResolveEffectiveShimsWithProvenance(config, configPath, nil, "")
+		} else {
+			name := strings.TrimPrefix(fragment, "root.")
+			scope := config.Scopes[name]
+			effective, err = r.ResolveEffectiveShimsWithProvenance(config, configPath, &scope, name)
+		}
+		if err == nil {
+			markOverridden(node, effective)
+		} else if !graphHasError(node) {
+			node.Err = err
+		}
+		graphs = append(graphs, node)
+	}
+	return graphs
+}
+
+// buildGraphNode builds the node for fragment of config, following extends.
+// fragment "" means the whole file, which merges its root wrappers and every scope.
+func (r *Resolver) buildGraphNode(config *ProjectConfig, configPath, fragment, ref string, visited map[string]bool) *GraphNode {
+	node := &GraphNode{Ref: ref, FilePath: configPath, Fragment: fragment}
+
+	visitKey := configPath + "#" + fragment
+	if visited[visitKey] {
+		node.Err = fmt.Errorf("%w: %s", ErrCyclicExtends, visitKey)
+		return node
+	}
+	visited[visitKey] = true
+	defer func() { visited[visitKey] = false }()
+
+	switch fragment {
+	case "root":
+		node.Wrappers = config.OwnWrappers(configPath)
+		node.Extends = r.buildImportNodes(config, configPath, visited)
+		return node
+	case "":
+		node.Wrappers = config.OwnWrappers(configPath)
+		node.Extends = r.buildImportNodes(config, configPath, visited)
+		for _, name := range sortedKeys(config.Scopes) {
+			node.Extends = append(node.Extends, r.buildGraphNode(config, configPath, "root."+name, "root."+name, visited))
+		}
+		return node
+	}
+
+	scopeName := strings.TrimPrefix(fragment, "root.")
+	scope, ok := config.Scopes[scopeName]
+	if !ok {
+		node.Err = fmt.Errorf("scope %q not found in config", scopeName)
+		return node
+	}
+	node.ScopePath = scope.Path
+	node.Wrappers = sortedKeys(scope.Wrappers)
+
+	for _, extRef := range scope.Extends {
+		node.Extends = append(node.Extends, r.buildExtendsNode(config, configPath, extRef, visited))
+	}
+	return node
+}
+
+// buildExtendsNode builds the node an extends entry of the config at
+// configPath points to.
+func (r *Resolver) buildExtendsNode(config *ProjectConfig, configPath, extRef string, visited map[string]bool) *GraphNode {
+	failed := func(err error) *GraphNode {
+		return &GraphNode{Ref: extRef, Err: err}
+	}
+
+	ref, err := ParseExtendsRef(extRef, filepath.Dir(configPath))
+	if err != nil {
+		return failed(err)
+	}
+	if ref.IsLocal {
+		return r.buildGraphNode(config, configPath, ref.Fragment, extRef, visited)
+	}
+
+	extConfig, err := r.loadExtendsRef(ref)
+	if err != nil {
+		return failed(err)
+	}
+	return r.buildGraphNode(extConfig, ref.FilePath, ref.Fragment, extRef, visited)
+}
+
+// buildImportNodes builds the root nodes of the files the config at
+// configPath imports, starting with its synced policy if it has one.
+func (r *Resolver) buildImportNodes(config *ProjectConfig, configPath string, visited map[string]bool) []*GraphNode {
+	refs := config.Imports
+	if policyPath, ok := syncedPolicyImport(config, configPath); ok {
+		refs = append([]string{policyPath}, refs...)
+	}
+
+	var nodes []*GraphNode
+	for _, ref := range refs {
+		path, err := resolveFilePath(ref, filepath.Dir(configPath))
+		if err != nil {
+			nodes = append(nodes, &GraphNode{Ref: ref, Err: fmt.Errorf("invalid import %q: %w", ref, err)})
+			continue
+		}
+		imported, err := r.loadExternalConfig(path)
+		if err != nil {
+			nodes = append(nodes, &GraphNode{Ref: ref, Err: fmt.Errorf("failed to load import %q: %w", ref, err)})
+			continue
+		}
+		nodes = append(nodes, r.buildGraphNode(imported, path, "root", ref, visited))
+	}
+	return nodes
+}
+
+// markOverridden records, for every node in the graph, which of its own
+// wrappers lose to another source in the effective wrappers at the top.
+func markOverridden(node *GraphNode, effective map[string]ResolvedShim) {
+	ownFragment := node.Fragment
+	if ownFragment == "" {
+		ownFragment = "root"
+	}
+	for _, name := range node.Wrappers {
+		winner, ok := effective[name]
+		if !ok || (winner.Source.FilePath == node.FilePath && winner.Source.Fragment == ownFragment) {
+			continue
+		}
+		if node.Overridden == nil {
+			node.Overridden = make(map[string]ShimSource)
+		}
+		node.Overridden[name] = ShimSource{FilePath: winner.Source.FilePath, Fragment: winner.Source.Fragment}
+	}
+	for _, child := range node.Extends {
+		markOverridden(child, effective)
+	}
+}
+
+// graphHasError returns true if node or anything it extends has an error
+func graphHasError(node *GraphNode) bool {
+	if node.Err != nil {
+		return true
+	}
+	for _, child := range node.Extends {
+		if graphHasError(child) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestBuildExtendsGraph(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "ribbin.jsonc")
+	basePath := filepath.Join(tmpDir, "base.jsonc")
+	if err := os.WriteFile(basePath, []byte(`{"wrappers": {"curl": {"action": "block"}, "rm": {"action": "warn"}}}`), 0644); err != nil {
+		t.Fatalf("failed to write base config: %v", err)
+	}
+
+	config := &ProjectConfig{
+		Wrappers: map[string]ShimConfig{
+			"npm": {Action: "block"},
+			"tsc": {Action: "block"},
+		},
+		Scopes: map[string]ScopeConfig{
+			"hardened": {
+				Wrappers: map[string]ShimConfig{"rm": {Action: "block"}},
+			},
+			"app": {
+				Path:     "app",
+				Extends:  []string{"root", "./base.jsonc", "root.hardened"},
+				Wrappers: map[string]ShimConfig{"tsc": {Action: "passthrough"}},
+			},
+			"broken": {
+				Extends: []string{"root.missing"},
+			},
+		},
+	}
+
+	graphs := BuildExtendsGraph(config, configPath)
+
+	var refs []string
+	for _, g := range graphs {
+		refs = append(refs, g.Ref)
+	}
+	if want := []string{"root", "root.app", "root.broken", "root.hardened"}; !slices.Equal(refs, want) {
+		t.Fatalf("graph refs = %v, want %v", refs, want)
+	}
+
+	app := graphs[1]
+	if app.ScopePath != "app" || !slices.Equal(app.Wrappers, []string{"tsc"}) {
+		t.Errorf("app node = %+v", app)
+	}
+	if len(app.Extends) != 3 {
+		t.Fatalf("app extends %d nodes, want 3", len(app.Extends))
+	}
+
+	root, base, hardened := app.Extends[0], app.Extends[1], app.Extends[2]
+	if winner, ok := root.Overridden["tsc"]; !ok || winner.Fragment != "root.app" {
+		t.Errorf("root tsc should be overridden by root.app, got %+v", root.Overridden)
+	}
+	if _, ok := root.Overridden["npm"]; ok {
+		t.Error("root npm is not overridden")
+	}
+	if base.FilePath != basePath || base.Fragment != "" {
+		t.Errorf("base node = %+v, want whole file %s", base, basePath)
+	}
+	if winner, ok := base.Overridden["rm"]; !ok || winner.Fragment != "root.hardened" || winner.FilePath != configPath {
+		t.Errorf("base rm should be overridden by root.hardened, got %+v", base.Overridden)
+	}
+	if len(hardened.Overridden) != 0 {
+		t.Errorf("hardened wrappers all take effect, got overridden %+v", hardened.Overridden)
+	}
+
+	broken := graphs[2]
+	if broken.Err != nil || len(broken.Extends) != 1 || broken.Extends[0].Err == nil {
+		t.Errorf("expected the error on the missing fragment only, got %+v", broken)
+	}
+
+	t.Run("cycle", func(t *testing.T) {
+		cyclic := &ProjectConfig{
+			Scopes: map[string]ScopeConfig{
+				"a": {Extends: []string{"root.b"}},
+				"b": {Extends: []string{"root.a"}},
+			},
+		}
+		a := BuildExtendsGraph(cyclic, configPath)[1]
+		if len(a.Extends) != 1 || len(a.Extends[0].Extends) != 1 {
+			t.Fatalf("unexpected graph shape: %+v", a)
+		}
+		if err := a.Extends[0].Extends[0].Err; !errors.Is(err, ErrCyclicExtends) {
+			t.Errorf("expected ErrCyclicExtends, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// ErrCyclicImports is returned when a cycle is detected in imports
+var ErrCyclicImports = errors.New("cyclic imports detected")
+
+// ImportedFiles returns the absolute paths of the files read for the
+// config's imports (including imports of imports), in the order read.
+func (c *ProjectConfig) ImportedFiles() []string {
+	return c.imported
+}
+
+// WrapperSource returns where the root wrapper name of the config at
+// configPath came from: an imported file, or the config itself (recording
+// the import it overrode, if any).
+func (c *ProjectConfig) WrapperSource(name, configPath string) ShimSource {
+	if source, ok := c.wrapperSources[name]; ok {
+		return source
+	}
+	return ShimSource{FilePath: configPath, Fragment: "root"}
+}
+
+// OwnWrappers returns the names of the root wrappers written in the config
+// itself rather than merged in from its imports, sorted.
+func (c *ProjectConfig) OwnWrappers(configPath string) []string {
+	absPath, _ := filepath.Abs(configPath)
+	var names []string
+	for _, name := range sortedKeys(c.Wrappers) {
+		source, ok := c.wrapperSources[name]
+		if !ok || source.FilePath == absPath {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// applyImports merges the root wrappers of the files in config.Imports into
+// config.Wrappers, recording their provenance. Imports are merged in order,
+// so later imports override earlier ones, and the config's own wrappers
+// override all of them.
+//
+// A policy synced with 'ribbin sync' is merged first, as if it were the
+// first import.
+func applyImports(config *ProjectConfig, configPath string) error {
+	absPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return err
+	}
+	refs := config.Imports
+	if policyPath, ok := syncedPolicyImport(config, absPath); ok {
+		refs = append([]string{policyPath}, refs...)
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	return applyImportsFrom(config, absPath, refs, []string{absPath})
+}
+
+// applyImportsFrom is applyImports for the config at absPath with the
+// import references refs, where stack is the chain of configs importing it
+// (ending in absPath) for cycle detection.
+func applyImportsFrom(config *ProjectConfig, absPath string, refs []string, stack []string) error {
+	merged := make(map[string]WrapperConfig)
+	sources := make(map[string]ShimSource)
+	seen := make(map[string]bool)
+
+	// merge adds a definition of a wrapper merged after any earlier ones,
+	// under the config's conflict policy
+	merge := func(name string, wrapper WrapperConfig, source ShimSource) error {
+		existing, ok := sources[name]
+		if !ok {
+			merged[name] = wrapper
+			sources[name] = source
+			return nil
+		}
+		wins, err := laterWins(config.OnConflict, name, merged[name], wrapper, func() (string, string) {
+			return sourceLabel(existing), sourceLabel(source)
+		})
+		if err != nil {
+			return err
+		}
+		if wins {
+			merged[name] = wrapper
+			sources[name] = withOverrode(source, existing)
+		} else {
+			sources[name] = withOverrode(existing, source)
+		}
+		return nil
+	}
+
+	for _, ref := range refs {
+		imported, importPath, err := loadImport(ref, absPath, stack)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range append([]string{importPath}, imported.imported...) {
+			if !seen[path] {
+				seen[path] = true
+				config.imported = append(config.imported, path)
+			}
+		}
+
+		for _, name := range sortedKeys(imported.Wrappers) {
+			if err := merge(name, imported.Wrappers[name], imported.WrapperSource(name, importPath)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The config's own wrappers override everything imported, unless its
+	// onConflict policy says otherwise
+	for _, name := range sortedKeys(config.Wrappers) {
+		wrapper := config.Wrappers[name]
+		if _, ok := sources[name]; !ok {
+			merged[name] = wrapper
+			continue
+		}
+		if err := merge(name, wrapper, ShimSource{FilePath: absPath, Fragment: "root"}); err != nil {
+			return err
+		}
+	}
+
+	config.Wrappers = merged
+	config.wrapperSources = sources
+	return nil
+}
+
+// loadImport loads the file ref (relative to the importing config at
+// importerPath) with its own imports applied. Returns the config and its
+// absolute path.
+func loadImport(ref, importerPath string, stack []string) (*ProjectConfig, string, error) {
+	path, err := resolveFilePath(ref, filepath.Dir(importerPath))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid import %q: %w", ref, err)
+	}
+	for _, importer := range stack {
+		if importer == path {
+			return nil, "", fmt.Errorf("%w: %s", ErrCyclicImports, strings.Join(append(stack, path), " -> "))
+		}
+	}
+
+	if err := security.ValidateExtendsConfigPath(path); err != nil {
+		return nil, "", fmt.Errorf("invalid import %q: %w", ref, err)
+	}
+	imported, err := parseConfigFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load import %q: %w", ref, err)
+	}
+	if len(imported.Imports) > 0 {
+		if err := applyImportsFrom(imported, path, imported.Imports, append(stack[:len(stack):len(stack)], path)); err != nil {
+			return nil, "", err
+		}
+	}
+	return imported, path, nil
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestLoadProjectConfig_Imports(t *testing.T) {
+	writeConfig := func(t *testing.T, path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("merges in order with own wrappers winning", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, "ribbin.jsonc")
+		nodePath := filepath.Join(dir, "policies", "node.jsonc")
+		gitPath := filepath.Join(dir, "policies", "git.jsonc")
+		writeConfig(t, nodePath, `{"wrappers": {"npm": {"action": "block", "message": "node"}, "npx": {"action": "warn"}}}`)
+		writeConfig(t, gitPath, `{"wrappers": {"npm": {"action": "warn", "message": "git"}, "git": {"action": "warn"}}}`)
+		writeConfig(t, configPath, `{
+			"imports": ["./policies/node.jsonc", "./policies/git.jsonc"],
+			"wrappers": {"npx": {"action": "block", "message": "own"}, "rm": {"action": "block"}}
+		}`)
+
+		cfg, err := LoadProjectConfig(configPath)
+		if err != nil {
+			t.Fatalf("LoadProjectConfig error: %v", err)
+		}
+		if len(cfg.Wrappers) != 4 {
+			t.Fatalf("expected 4 wrappers, got %v", cfg.Wrappers)
+		}
+		if cfg.Wrappers["npm"].Message != "git" {
+			t.Errorf("npm should come from the later import, got %q", cfg.Wrappers["npm"].Message)
+		}
+		if cfg.Wrappers["npx"].Message != "own" {
+			t.Errorf("npx should come from the config itself, got %q", cfg.Wrappers["npx"].Message)
+		}
+
+		npm := cfg.WrapperSource("npm", configPath)
+		if npm.FilePath != gitPath || npm.Overrode == nil || npm.Overrode.FilePath != nodePath {
+			t.Errorf("npm source = %+v", npm)
+		}
+		npx := cfg.WrapperSource("npx", configPath)
+		if npx.FilePath != configPath || npx.Overrode == nil || npx.Overrode.FilePath != nodePath {
+			t.Errorf("npx source = %+v", npx)
+		}
+		if rm := cfg.WrapperSource("rm", configPath); rm.FilePath != configPath || rm.Overrode != nil {
+			t.Errorf("rm source = %+v", rm)
+		}
+
+		if own := cfg.OwnWrappers(configPath); len(own) != 2 || own[0] != "npx" || own[1] != "rm" {
+			t.Errorf("OwnWrappers = %v, want [npx rm]", own)
+		}
+		if files := cfg.ImportedFiles(); len(files) != 2 || files[0] != nodePath || files[1] != gitPath {
+			t.Errorf("ImportedFiles = %v", files)
+		}
+	})
+
+	t.Run("follows nested imports", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, "ribbin.jsonc")
+		basePath := filepath.Join(dir, "policies", "base.jsonc")
+		writeConfig(t, basePath, `{"wrappers": {"curl": {"action": "block"}}}`)
+		writeConfig(t, filepath.Join(dir, "policies", "all.jsonc"), `{"imports": ["./base.jsonc"]}`)
+		writeConfig(t, configPath, `{"imports": ["./policies/all.jsonc"]}`)
+
+		cfg, err := LoadProjectConfig(configPath)
+		if err != nil {
+			t.Fatalf("LoadProjectConfig error: %v", err)
+		}
+		if _, ok := cfg.Wrappers["curl"]; !ok {
+			t.Fatal("expected curl from the nested import")
+		}
+		if source := cfg.WrapperSource("curl", configPath); source.FilePath != basePath {
+			t.Errorf("curl source = %+v, want %s", source, basePath)
+		}
+		if len(cfg.ImportedFiles()) != 2 {
+			t.Errorf("ImportedFiles = %v", cfg.ImportedFiles())
+		}
+	})
+
+	t.Run("detects cycles", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, "ribbin.jsonc")
+		writeConfig(t, filepath.Join(dir, "a.jsonc"), `{"imports": ["./b.jsonc"]}`)
+		writeConfig(t, filepath.Join(dir, "b.jsonc"), `{"imports": ["./a.jsonc"]}`)
+		writeConfig(t, configPath, `{"imports": ["./a.jsonc"]}`)
+
+		_, err := LoadProjectConfig(configPath)
+		if !errors.Is(err, ErrCyclicImports) {
+			t.Fatalf("expected ErrCyclicImports, got %v", err)
+		}
+	})
+
+	t.Run("edits leave imported wrappers out of the file", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, "ribbin.jsonc")
+		writeConfig(t, filepath.Join(dir, "node.jsonc"), `{"wrappers": {"npm": {"action": "block"}}}`)
+		writeConfig(t, configPath, `{"imports": ["./node.jsonc"], "wrappers": {}}`)
+
+		if err := AddShim(configPath, "rm", ShimConfig{Action: "block"}); err != nil {
+			t.Fatalf("AddShim error: %v", err)
+		}
+		raw, err := loadConfigFile(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := raw.Wrappers["npm"]; ok {
+			t.Error("imported npm wrapper was written into the config")
+		}
+		if len(raw.Imports) != 1 {
+			t.Errorf("imports were not kept: %v", raw.Imports)
+		}
+	})
+}
+
+func TestValidateConfigFile_Imports(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "ribbin.jsonc")
+	if err := os.WriteFile(filepath.Join(dir, "scoped.jsonc"), []byte(`{"scopes": {"x": {"path": "."}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{
+  "imports": ["./missing.jsonc", "policies.jsonc", "./scoped.jsonc"]
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	errs, warnings := ValidateConfigFile(configPath)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	if !containsSubstring(errs, "/imports/0") || !containsSubstring(errs, "/imports/1") {
+		t.Errorf("errors should point at the bad imports: %v", errs)
+	}
+	if !containsSubstring(warnings, "/imports/2") {
+		t.Errorf("expected a warning about scopes in the import: %v", warnings)
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// A wrapper's message is a template, and can be translated, so a shared
+// extends file can give hundreds of repos the same linkable messages:
+//
+//	"npm": {
+//	  "action": "block",
+//	  "message": "Use pnpm instead of '{command} {args}'. See {docsUrl}",
+//	  "messages": { "fr": "Utilisez pnpm au lieu de '{command} {args}'. Voir {docsUrl}" },
+//	  "docsUrl": "https://wiki.example.com/js/pnpm"
+//	}
+
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([_-][A-Za-z0-9]+)?$`)
+
+// ValidateLocale checks that locale can key "messages", e.g. "fr" or "pt_BR"
+func ValidateLocale(locale string) error {
+	if !localePattern.MatchString(locale) {
+		return fmt.Errorf("invalid locale %q: use a language code like \"fr\", optionally with a region like \"pt_BR\"", locale)
+	}
+	return nil
+}
+
+// ValidateDocsURL checks that docsURL is an absolute http(s) URL
+func ValidateDocsURL(docsURL string) error {
+	u, err := url.Parse(docsURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid docsUrl %q: must be an http or https URL", docsURL)
+	}
+	return nil
+}
+
+// MessageVars are the values of a message's placeholders besides {docsUrl},
+// which comes from the wrapper
+type MessageVars struct {
+	Command    string   // {command}
+	Args       []string // {args}, joined with spaces
+	Scope      string   // {scope}, empty outside any scope
+	ConfigPath string   // {configPath}
+	Package    string   // {package}, for commands that run a package (see EphemeralPackage)
+	// Dir is where the command runs, searched up to the config's directory
+	// for the versions {pinned} names (see FindPinnedVersion). The config's
+	// directory is used when it is empty.
+	Dir string
+}
+
+// LocalizedMessage returns the wrapper's message for locale, a POSIX locale
+// name like "fr_CA.UTF-8": the translation for "fr_CA", else the one for
+// "fr", else Message. Locale keys match case-insensitively, with '-' and
+// '_' alike.
+func (w WrapperConfig) LocalizedMessage(locale string) string {
+	locale, _, _ = strings.Cut(locale, ".")
+	locale, _, _ = strings.Cut(locale, "@")
+	locale = normalizeLocale(locale)
+	if locale == "" || locale == "c" || locale == "posix" || len(w.Messages) == 0 {
+		return w.Message
+	}
+
+	language, _, _ := strings.Cut(locale, "_")
+	fallback, hasFallback := "", false
+	for key, message := range w.Messages {
+		switch normalizeLocale(key) {
+		case locale:
+			return message
+		case language:
+			fallback, hasFallback = message, true
+		}
+	}
+	if hasFallback {
+		return fallback
+	}
+	return w.Message
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "-", "_"))
+}
+
+// RenderMessage returns the wrapper's message for locale (see
+// LocalizedMessage) with its placeholders filled in. Unknown placeholders
+// are left as they are.
+func (w WrapperConfig) RenderMessage(locale string, vars MessageVars) string {
+	message := strings.NewReplacer(
+		"{command}", vars.Command,
+		"{args}", strings.Join(vars.Args, " "),
+		"{scope}", vars.Scope,
+		"{configPath}", vars.ConfigPath,
+		"{package}", vars.Package,
+		"{approved}", strings.Join(w.ApprovedPackages(), ", "),
+		"{docsUrl}", w.DocsURL,
+	).Replace(w.LocalizedMessage(locale))
+	return renderPinned(message, vars)
+}
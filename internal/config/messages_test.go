@@ -0,0 +1,49 @@
+package config
+
+import (
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestLocalizedMessage(t *testing.T) {
+	w := WrapperConfig{
+		Message:  "Use pnpm",
+		Messages: map[string]string{"fr": "Utilisez pnpm", "pt-BR": "Use o pnpm (BR)", "pt": "Use o pnpm"},
+	}
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"fr_FR.UTF-8", "Utilisez pnpm"},
+		{"fr", "Utilisez pnpm"},
+		{"pt_BR.UTF-8", "Use o pnpm (BR)"},
+		{"pt_PT", "Use o pnpm"},
+		{"de_DE.UTF-8@euro", "Use pnpm"},
+		{"C.UTF-8", "Use pnpm"},
+		{"", "Use pnpm"},
+	}
+	for _, tt := range tests {
+		if got := w.LocalizedMessage(tt.locale); got != tt.want {
+			t.Errorf("LocalizedMessage(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMessage(t *testing.T) {
+	w := WrapperConfig{
+		Message: "'{command} {args}' is blocked in {scope} by {configPath}. See {docsUrl} {unknown}",
+		DocsURL: "https://wiki.example.com/pnpm",
+	}
+	got := w.RenderMessage("", MessageVars{
+		Command:    "npm",
+		Args:       []string{"install", "lodash"},
+		Scope:      "frontend",
+		ConfigPath: "/repo/ribbin.jsonc",
+	})
+	want := "'npm install lodash' is blocked in frontend by /repo/ribbin.jsonc. See https://wiki.example.com/pnpm {unknown}"
+	if got != want {
+		t.Errorf("RenderMessage() = %q, want %q", got, want)
+	}
+}
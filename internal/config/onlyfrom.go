@@ -0,0 +1,23 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A wrapper with onlyFrom may only be run by the commands it names, such as
+// a build system, and blocks when run any other way, as from a shell:
+//
+//	"tsc": { "action": "passthrough", "onlyFrom": ["make", "just"] }
+//
+// Ancestor processes are matched by command name, however they were
+// launched (see process.NormalizeCommand).
+
+// ValidateOnlyFromName checks that name can be used in "onlyFrom": a
+// command name, without a directory or arguments.
+func ValidateOnlyFromName(name string) error {
+	if name == "" || strings.ContainsAny(name, "/ \t") {
+		return fmt.Errorf("invalid command name %q: use a name like \"make\", without a path or arguments", name)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A config shared through version control can't name absolute paths like
+// /Users/alice/bin/tsc, which only exist on one machine. Paths in a config
+// may instead start from a path variable, expanded when the config loads:
+//
+//	${HOME}          the user's home directory
+//	${PROJECT_ROOT}  the directory of the config
+//	${NODE_BIN}      ${PROJECT_ROOT}/node_modules/.bin
+//
+// They are expanded in wrapper "paths", scope "path" and "searchPaths".
+
+// PathVars are the path variables a config's paths may use
+var PathVars = []string{"HOME", "PROJECT_ROOT", "NODE_BIN"}
+
+var pathVarPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// machineSpecificPattern matches the start of a path in someone's home
+// directory on macOS, Linux or Windows
+var machineSpecificPattern = regexp.MustCompile(`^(/Users/[^/]+|/home/[^/]+|[A-Za-z]:[\\/]Users[\\/][^\\/]+)([\\/]|$)`)
+
+// pathVarValue returns the value of the path variable name for a config in
+// configDir
+func pathVarValue(name, configDir string) (string, error) {
+	switch name {
+	case "HOME":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot expand ${HOME}: %w", err)
+		}
+		return home, nil
+	case "PROJECT_ROOT":
+		return configDir, nil
+	case "NODE_BIN":
+		return filepath.Join(configDir, "node_modules", ".bin"), nil
+	}
+	return "", fmt.Errorf("unknown path variable ${%s}: use %s", name, strings.Join(quotedPathVars(), ", "))
+}
+
+func quotedPathVars() []string {
+	quoted := make([]string, len(PathVars))
+	for i, name := range PathVars {
+		quoted[i] = "${" + name + "}"
+	}
+	return quoted
+}
+
+// ExpandPathVars expands the path variables in path, for a config in
+// configDir. A path without any is returned as it is.
+func ExpandPathVars(path, configDir string) (string, error) {
+	if !strings.Contains(path, "${") {
+		return path, nil
+	}
+	if abs, err := filepath.Abs(configDir); err == nil {
+		configDir = abs
+	}
+	var expandErr error
+	expanded := pathVarPattern.ReplaceAllStringFunc(path, func(match string) string {
+		value, err := pathVarValue(match[2:len(match)-1], configDir)
+		if err != nil && expandErr == nil {
+			expandErr = err
+		}
+		return value
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return filepath.Clean(expanded), nil
+}
+
+// expandConfigPaths expands the path variables in the paths of config,
+// loaded from configPath. Paths with unknown variables are left as written
+// for 'ribbin config validate' to report.
+func expandConfigPaths(config *ProjectConfig, configPath string) {
+	configDir := filepath.Dir(configPath)
+	expand := func(path string) string {
+		if expanded, err := ExpandPathVars(path, configDir); err == nil {
+			return expanded
+		}
+		return path
+	}
+	expandWrappers := func(wrappers map[string]WrapperConfig) {
+		for name, wrapper := range wrappers {
+			if len(wrapper.Paths) == 0 {
+				continue
+			}
+			paths := make([]string, len(wrapper.Paths))
+			for i, path := range wrapper.Paths {
+				paths[i] = expand(path)
+			}
+			wrapper.Paths = paths
+			wrappers[name] = wrapper
+		}
+	}
+
+	expandWrappers(config.Wrappers)
+	for name, scope := range config.Scopes {
+		scope.Path = expand(scope.Path)
+		expandWrappers(scope.Wrappers)
+		config.Scopes[name] = scope
+	}
+	for i, path := range config.SearchPaths {
+		config.SearchPaths[i] = expand(path)
+	}
+}
+
+// MachineSpecificPath reports whether path is an absolute path in the
+// project or in someone's home directory, which won't be the same on other
+// machines, and suggests a portable spelling of it for a config in configDir
+func MachineSpecificPath(path, configDir string) (bool, string) {
+	slashed := strings.ReplaceAll(path, `\`, "/")
+	if abs, err := filepath.Abs(configDir); err == nil {
+		configDir = abs
+	}
+	if rel, ok := pathWithin(slashed, filepath.ToSlash(configDir)); ok {
+		return true, "${PROJECT_ROOT}" + rel
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if rel, ok := pathWithin(slashed, filepath.ToSlash(home)); ok {
+			return true, "${HOME}" + rel
+		}
+	}
+	if match := machineSpecificPattern.FindString(path); match != "" {
+		return true, "${HOME}" + slashed[len(strings.TrimRight(match, `\/`)):]
+	}
+	return false, ""
+}
+
+// pathWithin returns what follows dir in path, if path is dir or within it
+func pathWithin(path, dir string) (string, bool) {
+	if dir == "" || dir == "/" {
+		return "", false
+	}
+	if path == dir {
+		return "", true
+	}
+	if strings.HasPrefix(path, dir+"/") {
+		return path[len(dir):], true
+	}
+	return "", false
+}
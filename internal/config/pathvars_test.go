@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestExpandPathVars(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project := "/work/app"
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "./bin/tsc", want: "./bin/tsc"},
+		{path: "${HOME}/bin/tsc", want: filepath.Join(home, "bin", "tsc")},
+		{path: "${PROJECT_ROOT}/tools", want: "/work/app/tools"},
+		{path: "${PROJECT_ROOT}", want: "/work/app"},
+		{path: "${NODE_BIN}/tsc", want: "/work/app/node_modules/.bin/tsc"},
+		{path: "${NODE_MODULES}/.bin/tsc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ExpandPathVars(tt.path, project)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ExpandPathVars(%q) = %q, want an error", tt.path, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ExpandPathVars(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadProjectConfigExpandsPathVars(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	if err := os.MkdirAll(filepath.Join(dir, "app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "ribbin.jsonc")
+	content := `{
+		"wrappers": {"tsc": {"action": "block", "paths": ["${NODE_BIN}/tsc"]}},
+		"scopes": {"app": {"path": "${PROJECT_ROOT}/app", "wrappers": {"npm": {"action": "warn", "paths": ["./bin/npm"]}}}},
+		"searchPaths": ["${PROJECT_ROOT}/tools"]
+	}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadProjectConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.Wrappers["tsc"].Paths[0], filepath.Join(dir, "node_modules", ".bin", "tsc"); got != want {
+		t.Errorf("tsc path = %q, want %q", got, want)
+	}
+	if got, want := cfg.Scopes["app"].Path, filepath.Join(dir, "app"); got != want {
+		t.Errorf("scope path = %q, want %q", got, want)
+	}
+	if got := cfg.Scopes["app"].Wrappers["npm"].Paths[0]; got != "./bin/npm" {
+		t.Errorf("npm path = %q, want it as written", got)
+	}
+	if got, want := cfg.SearchPaths[0], filepath.Join(dir, "tools"); got != want {
+		t.Errorf("search path = %q, want %q", got, want)
+	}
+
+	// Editing the config writes the variables back as they were written
+	if err := AddShim(configPath, "cat", WrapperConfig{Action: "block"}); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := loadConfigFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := raw.Wrappers["tsc"].Paths[0]; got != "${NODE_BIN}/tsc" {
+		t.Errorf("written tsc path = %q, want ${NODE_BIN}/tsc", got)
+	}
+}
+
+func TestMachineSpecificPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project := "/work/app"
+
+	tests := []struct {
+		path     string
+		specific bool
+		portable string
+	}{
+		{path: "/usr/local/bin/tsc"},
+		{path: "./bin/tsc"},
+		{path: "${HOME}/bin/tsc"},
+		{path: "/Users/alice/bin/tsc", specific: true, portable: "${HOME}/bin/tsc"},
+		{path: "/home/alice", specific: true, portable: "${HOME}"},
+		{path: `C:\Users\alice\tools`, specific: true, portable: "${HOME}/tools"},
+		{path: filepath.Join(home, "bin", "tsc"), specific: true, portable: "${HOME}/bin/tsc"},
+		{path: "/work/app/node_modules/.bin/tsc", specific: true, portable: "${PROJECT_ROOT}/node_modules/.bin/tsc"},
+	}
+	for _, tt := range tests {
+		specific, portable := MachineSpecificPath(tt.path, project)
+		if specific != tt.specific || portable != tt.portable {
+			t.Errorf("MachineSpecificPath(%q) = %v, %q; want %v, %q", tt.path, specific, portable, tt.specific, tt.portable)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// A message blocking node can name the version the project pins, as in
+// "Use node {pinned} via mise", read from the files version managers use:
+//
+//	.nvmrc, .node-version, .python-version, .ruby-version
+//	.tool-versions (asdf and mise)
+//	package.json "engines" and "packageManager"
+//
+// The nearest directory pinning the tool wins, from where the command runs
+// up to the config's directory.
+
+// PinnedVersion is a tool's version as a project pins it
+type PinnedVersion struct {
+	Version string
+	// Source is the file that pins it
+	Source string
+}
+
+// runtimeTools maps commands to the runtime whose version {pinned} names
+// for them, so a message blocking npm can name the pinned node
+var runtimeTools = map[string]string{
+	"npm": "node", "npx": "node", "corepack": "node",
+	"python3": "python", "pip": "python", "pip3": "python",
+	"gem": "ruby", "bundle": "ruby", "bundler": "ruby",
+}
+
+// toolAliases maps the names .tool-versions may use to ribbin's
+var toolAliases = map[string]string{"nodejs": "node", "golang": "go"}
+
+// versionFiles maps the files holding just one tool's version to the tool
+var versionFiles = []struct{ file, tool string }{
+	{".nvmrc", "node"},
+	{".node-version", "node"},
+	{".python-version", "python"},
+	{".ruby-version", "ruby"},
+}
+
+var pinnedPattern = regexp.MustCompile(`\{pinned(?::([A-Za-z0-9_.-]+))?\}`)
+
+// pinCache holds the versions pinned by the files of each directory read so
+// far. A wrapper renders at most a couple of messages, but 'ribbin ide' and
+// 'ribbin explain' render one per wrapper, for the same directories.
+var pinCache struct {
+	sync.Mutex
+	dirs map[string]map[string]PinnedVersion
+}
+
+// PinnedTool returns the tool whose version {pinned} names in a message
+// about command: its runtime, like node for npm, or the command itself
+func PinnedTool(command string) string {
+	if tool, ok := runtimeTools[command]; ok {
+		return tool
+	}
+	return command
+}
+
+// FindPinnedVersion returns the version of tool pinned in dir or the
+// directories above it up to stopDir. If dir isn't within stopDir, or
+// stopDir is empty, only dir is searched.
+func FindPinnedVersion(tool, dir, stopDir string) (PinnedVersion, bool) {
+	dir = filepath.Clean(dir)
+	if stopDir != "" {
+		stopDir = filepath.Clean(stopDir)
+		if rel, err := filepath.Rel(stopDir, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			stopDir = ""
+		}
+	}
+	for {
+		if pinned, ok := dirPins(dir)[tool]; ok {
+			return pinned, true
+		}
+		parent := filepath.Dir(dir)
+		if stopDir == "" || dir == stopDir || parent == dir {
+			return PinnedVersion{}, false
+		}
+		dir = parent
+	}
+}
+
+// dirPins returns the versions pinned by the files in dir, reading them
+// the first time dir is asked about
+func dirPins(dir string) map[string]PinnedVersion {
+	pinCache.Lock()
+	defer pinCache.Unlock()
+	if pins, ok := pinCache.dirs[dir]; ok {
+		return pins
+	}
+	if pinCache.dirs == nil {
+		pinCache.dirs = make(map[string]map[string]PinnedVersion)
+	}
+	pins := readPins(dir)
+	pinCache.dirs[dir] = pins
+	return pins
+}
+
+// readPins reads the versions pinned by the files in dir. A tool's own
+// version file comes first, then .tool-versions, then package.json.
+func readPins(dir string) map[string]PinnedVersion {
+	pins := make(map[string]PinnedVersion)
+	pin := func(tool, version, source string) {
+		version = strings.TrimSpace(version)
+		if _, ok := pins[tool]; !ok && version != "" {
+			pins[tool] = PinnedVersion{Version: version, Source: source}
+		}
+	}
+
+	for _, vf := range versionFiles {
+		path := filepath.Join(dir, vf.file)
+		if data, err := os.ReadFile(path); err == nil {
+			line, _, _ := strings.Cut(string(data), "\n")
+			pin(vf.tool, line, path)
+		}
+	}
+
+	// .tool-versions: "<tool> <version> [fallback versions]" per line
+	path := filepath.Join(dir, ".tool-versions")
+	if file, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			tool := fields[0]
+			if alias, ok := toolAliases[tool]; ok {
+				tool = alias
+			}
+			pin(tool, fields[1], path)
+		}
+		file.Close()
+	}
+
+	path = filepath.Join(dir, "package.json")
+	if data, err := os.ReadFile(path); err == nil {
+		var pkg struct {
+			Engines        map[string]string `json:"engines"`
+			PackageManager string            `json:"packageManager"`
+		}
+		if json.Unmarshal(data, &pkg) == nil {
+			for _, tool := range sortedKeys(pkg.Engines) {
+				pin(tool, pkg.Engines[tool], path)
+			}
+			// "packageManager": "pnpm@8.15.0+sha256.abc..."
+			if name, version, ok := strings.Cut(pkg.PackageManager, "@"); ok {
+				version, _, _ = strings.Cut(version, "+")
+				pin(name, version, path)
+			}
+		}
+	}
+	return pins
+}
+
+// renderPinned fills in the {pinned} and {pinned:<tool>} placeholders of
+// message with the versions pinned where vars' command runs. A tool that
+// isn't pinned leaves "" in its place.
+func renderPinned(message string, vars MessageVars) string {
+	if !strings.Contains(message, "{pinned") {
+		return message
+	}
+	stopDir := ""
+	if vars.ConfigPath != "" {
+		stopDir = filepath.Dir(vars.ConfigPath)
+	}
+	dir := vars.Dir
+	if dir == "" {
+		dir = stopDir
+	}
+	if dir == "" {
+		return pinnedPattern.ReplaceAllString(message, "")
+	}
+	return pinnedPattern.ReplaceAllStringFunc(message, func(match string) string {
+		tool := PinnedTool(vars.Command)
+		if sub := pinnedPattern.FindStringSubmatch(match); sub[1] != "" {
+			tool = sub[1]
+		}
+		pinned, _ := FindPinnedVersion(tool, dir, stopDir)
+		return pinned.Version
+	})
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestFindPinnedVersion(t *testing.T) {
+	project := t.TempDir()
+	app := filepath.Join(project, "packages", "app")
+	if err := os.MkdirAll(app, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(project, ".tool-versions"): "nodejs 18.19.0\npython 3.12.1 system # fallback\n",
+		filepath.Join(project, "package.json"):   `{"engines": {"node": ">=18", "npm": "^10"}, "packageManager": "pnpm@8.15.0+sha256.abc"}`,
+		filepath.Join(app, ".nvmrc"):             "20.11.0\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		tool, dir   string
+		wantVersion string
+		wantSource  string
+	}{
+		{"node", app, "20.11.0", filepath.Join(app, ".nvmrc")},
+		{"node", project, "18.19.0", filepath.Join(project, ".tool-versions")},
+		{"python", app, "3.12.1", filepath.Join(project, ".tool-versions")},
+		{"npm", app, "^10", filepath.Join(project, "package.json")},
+		{"pnpm", app, "8.15.0", filepath.Join(project, "package.json")},
+		{"ruby", app, "", ""},
+	}
+	for _, tt := range tests {
+		pinned, ok := FindPinnedVersion(tt.tool, tt.dir, project)
+		if ok != (tt.wantVersion != "") || pinned.Version != tt.wantVersion || pinned.Source != tt.wantSource {
+			t.Errorf("FindPinnedVersion(%s, %s) = %+v, %v; want %q from %s", tt.tool, tt.dir, pinned, ok, tt.wantVersion, tt.wantSource)
+		}
+	}
+
+	// Outside the config's directory, only the directory itself is searched
+	if pinned, ok := FindPinnedVersion("node", t.TempDir(), project); ok {
+		t.Errorf("FindPinnedVersion outside the project = %+v, want none", pinned)
+	}
+
+	t.Run("in messages", func(t *testing.T) {
+		w := WrapperConfig{Message: "Use node {pinned} via mise, or pnpm {pinned:pnpm}; ruby {pinned:ruby}."}
+		got := w.RenderMessage("", MessageVars{Command: "npm", ConfigPath: filepath.Join(project, "ribbin.jsonc"), Dir: app})
+		if want := "Use node 20.11.0 via mise, or pnpm 8.15.0; ruby ."; got != want {
+			t.Errorf("RenderMessage = %q, want %q", got, want)
+		}
+		// Without a directory, the config's is searched
+		got = w.RenderMessage("", MessageVars{Command: "node", ConfigPath: filepath.Join(project, "ribbin.jsonc")})
+		if want := "Use node 18.19.0 via mise, or pnpm 8.15.0; ruby ."; got != want {
+			t.Errorf("RenderMessage = %q, want %q", got, want)
+		}
+	})
+}
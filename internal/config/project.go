@@ -6,10 +6,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/happycollision/ribbin/internal/security"
-	"github.com/tailscale/hujson"
 )
 
 // ErrInvalidScopePath is returned when a scope path is invalid
@@ -25,20 +25,137 @@ type PassthroughConfig struct {
 	Depth *int `json:"depth,omitempty"`
 }
 
+// OnboardingConfig is a project's introduction for new contributors, shown
+// alongside the first block they hit
+type OnboardingConfig struct {
+	// Message explains why the project wraps commands and what to use instead
+	Message string `json:"message"`
+	// DocsURL links to the project's contributor documentation
+	DocsURL string `json:"docsUrl,omitempty"`
+}
+
+// RequireConfig is what a project needs set up on every machine working on
+// it. 'ribbin verify' fails until it is, and a required command run
+// unwrapped says how to set it up.
+type RequireConfig struct {
+	// Wrapped lists commands that must be wrapped, like "tsc"
+	Wrapped []string `json:"wrapped,omitempty"`
+}
+
+// HooksConfig defines commands run around the program a wrapper runs (the
+// original or the redirect target). Each is a command template like an
+// inline redirect.
+type HooksConfig struct {
+	// Before runs first; if it exits non-zero the program doesn't run
+	Before string `json:"before,omitempty"`
+	// After runs once the program exits, with its exit code in RIBBIN_EXIT_CODE
+	After string `json:"after,omitempty"`
+}
+
 // WrapperConfig defines the behavior for a wrapped command
 type WrapperConfig struct {
-	// Action is the behavior when the command is invoked: "block", "warn", "redirect"
+	// Action is the behavior when the command is invoked: "block", "warn",
+	// "redirect" or "passthrough"
 	Action string `json:"action"`
-	// Message is displayed when the command is blocked or warned
+	// Message is displayed when the command is blocked or warned. It may use
+	// placeholders like {command} (see MessageVars).
 	Message string `json:"message,omitempty"`
+	// Messages are translations of Message by locale (see LocalizedMessage)
+	Messages map[string]string `json:"messages,omitempty"`
+	// DocsURL links to documentation about the wrapper, for {docsUrl} in messages
+	DocsURL string `json:"docsUrl,omitempty"`
+	// InteractiveOverride lets a user at a terminal run a blocked command
+	// anyway with one keystroke, logged to the audit log. Enforcement turns
+	// it off.
+	InteractiveOverride bool `json:"interactiveOverride,omitempty"`
 	// Paths restricts the wrapper to specific binary paths
 	Paths []string `json:"paths,omitempty"`
-	// Redirect specifies the alternative command to execute (for "redirect" action)
+	// Redirect specifies the alternative command to execute (for "redirect" action):
+	// a script path, or a command template string containing placeholders like {args}
 	Redirect string `json:"redirect,omitempty"`
+	// RedirectArgv holds the redirect command when it is written as an argv array.
+	// It shares the "redirect" key with Redirect (see WrapperConfig.UnmarshalJSON).
+	RedirectArgv []string `json:"-"`
 	// Passthrough defines conditions for passing through to the original command
 	Passthrough *PassthroughConfig `json:"passthrough,omitempty"`
+	// OnlyFrom names the commands, like "make", allowed to run this one:
+	// unless one of them is an ancestor process, the wrapper blocks instead
+	// of taking its action
+	OnlyFrom []string `json:"onlyFrom,omitempty"`
+	// Verify checks the original binary against what was recorded at wrap time
+	// before running it: "hash", "size" or "none" (default)
+	Verify string `json:"verify,omitempty"`
+	// Exec is how the wrapper runs the original or redirect target: "replace"
+	// (default, replaces the wrapper process) or "spawn" (runs it as a child)
+	Exec string `json:"exec,omitempty"`
+	// RedirectMode is how the redirect target runs, overriding Exec for it:
+	// "replace" or "spawn" (ribbin stays resident, forwarding IO and signals)
+	RedirectMode string `json:"redirectMode,omitempty"`
+	// RedirectOnFailure is printed to stderr when a spawned redirect target
+	// exits non-zero. "{code}" is replaced with its exit code.
+	RedirectOnFailure string `json:"redirectOnFailure,omitempty"`
+	// Timeout stops the original or redirect target once it has run this
+	// long, a duration like "10m". The program is spawned to be timed (see
+	// ParseTimeout).
+	Timeout string `json:"timeout,omitempty"`
+	// TimeoutSignal is the signal that stops it: "TERM" (default), "INT",
+	// "HUP", "QUIT" or "KILL"
+	TimeoutSignal string `json:"timeoutSignal,omitempty"`
+	// TimeoutMessage is printed to stderr as it is stopped, with {timeout}
+	// and {signal} as well as the placeholders of messages
+	TimeoutMessage string `json:"timeoutMessage,omitempty"`
+	// VersionCheck blocks or warns when the original's version is out of policy
+	VersionCheck *VersionCheckConfig `json:"versionCheck,omitempty"`
+	// Env sets environment variables for the original or redirect target.
+	// Values may reference the caller's environment as $VAR or ${VAR}.
+	Env map[string]string `json:"env,omitempty"`
+	// EnvUnset lists variables of the caller's environment the original or
+	// redirect target doesn't get, by name or by a glob like "AWS_*". It is
+	// written as "unset" in env.
+	EnvUnset []string `json:"-"`
+	// EnvAllowOnly, when set, lists the only variables of the caller's
+	// environment the original or redirect target gets, by name or glob.
+	// It is written as "allowOnly" in env.
+	EnvAllowOnly []string `json:"-"`
+	// Tags group wrappers so 'ribbin wrap --tag' and 'ribbin activate --tag'
+	// can act on a subset of them
+	Tags []string `json:"tags,omitempty"`
+	// Hooks run commands before and after the program the wrapper runs
+	Hooks *HooksConfig `json:"hooks,omitempty"`
+	// ArgPathPatterns limits the wrapper to invocations with an argument
+	// naming a file that matches one of these globs (see MatchArgPaths)
+	ArgPathPatterns []string `json:"argPathPatterns,omitempty"`
+	// When limits the wrapper to certain times, like outside business hours
+	// or during a release freeze
+	When *WhenConfig `json:"when,omitempty"`
+	// ArgRules give matching invocations a different action, e.g. to only
+	// let npx run approved packages (see MatchArgRules)
+	ArgRules []ArgRule `json:"argRules,omitempty"`
+	// Priority ranks this definition against others of the same wrapper when
+	// the config's OnConflict is "highest" or "error"
+	Priority int `json:"priority,omitempty"`
+	// Expires is the date (YYYY-MM-DD) from which the wrapper stops taking
+	// its action and takes ExpiredAction instead, for rules that only last
+	// through a migration
+	Expires string `json:"expires,omitempty"`
+	// ExpiredAction is the action once Expires is reached: "warn" (default)
+	// or "passthrough"
+	ExpiredAction string `json:"expiredAction,omitempty"`
 }
 
+// Verification policies for WrapperConfig.Verify
+const (
+	VerifyNone = "none"
+	VerifySize = "size"
+	VerifyHash = "hash"
+)
+
+// Exec modes for WrapperConfig.Exec
+const (
+	ExecReplace = "replace"
+	ExecSpawn   = "spawn"
+)
+
 // ShimConfig is an alias for backwards compatibility during migration
 type ShimConfig = WrapperConfig
 
@@ -47,9 +164,14 @@ type ScopeConfig struct {
 	// Path is the directory path this scope applies to (relative to config dir, defaults to ".")
 	Path string `json:"path,omitempty"`
 	// Extends is a list of references to inherit wrappers from (see epic ribbin-3gj for syntax)
-	Extends []string `json:"extends,omitempty"`
+	Extends ExtendsList `json:"extends,omitempty"`
 	// Wrappers maps command names to their wrapper configurations within this scope
 	Wrappers map[string]WrapperConfig `json:"wrappers,omitempty"`
+	// Env sets environment variables for every wrapper in effect in this scope.
+	// A wrapper's own env takes precedence.
+	Env map[string]string `json:"env,omitempty"`
+	// Tags limits the scope to wrappers carrying at least one of these tags
+	Tags []string `json:"tags,omitempty"`
 }
 
 // ProjectConfig represents a ribbin.jsonc project configuration file
@@ -60,6 +182,65 @@ type ProjectConfig struct {
 	Wrappers map[string]WrapperConfig `json:"wrappers,omitempty"`
 	// Scopes maps scope names to their scoped configurations
 	Scopes map[string]ScopeConfig `json:"scopes,omitempty"`
+	// StrictResolve makes wrap and activate resolve every scope and extends chain
+	// up front, refusing to proceed on unknown keys or resolution errors
+	StrictResolve bool `json:"strictResolve,omitempty"`
+	// Root set to false merges this config with the nearest config in a parent
+	// directory (this config overrides it). Unset means true.
+	Root *bool `json:"root,omitempty"`
+	// Enforce makes wrappers ignore RIBBIN_BYPASS and snoozes, and log
+	// attempts to use them as security violations. A config that merges with
+	// this one can't turn it off.
+	Enforce bool `json:"enforce,omitempty"`
+	// Imports lists files whose root wrappers are merged into this config's
+	// root wrappers, in order. Later imports override earlier ones, and the
+	// config's own wrappers override all of them.
+	Imports []string `json:"imports,omitempty"`
+	// Sync pulls a signed organization policy whose root wrappers are merged
+	// in before Imports (see 'ribbin sync')
+	Sync *SyncConfig `json:"sync,omitempty"`
+	// SidecarLayout is where wrapping this config's binaries keeps their
+	// originals, overriding the user's settings. SidecarLayoutProject keeps
+	// them in the config's directory, out of reach of package managers.
+	SidecarLayout string `json:"sidecarLayout,omitempty"`
+	// OnConflict decides which of several definitions of a wrapper takes
+	// effect: "last" (default), "highest" priority, or "error" (see laterWins)
+	OnConflict string `json:"onConflict,omitempty"`
+	// SearchPaths are more directories for 'ribbin find', 'ribbin status'
+	// and wrapper discovery to search, with environment variables and ~
+	// expanded. Relative paths are relative to the config's directory.
+	SearchPaths []string `json:"searchPaths,omitempty"`
+	// Onboarding is shown, once per user, the first time a wrapper of this
+	// config blocks them
+	Onboarding *OnboardingConfig `json:"onboarding,omitempty"`
+	// Require lists what must be set up for the project, like the commands
+	// that must be wrapped
+	Require *RequireConfig `json:"require,omitempty"`
+	// Workspaces adds a scope extending root for each package of the
+	// workspace rooted at the config's directory (see applyWorkspaces)
+	Workspaces bool `json:"workspaces,omitempty"`
+
+	// imported lists every file read for Imports, recursively
+	imported []string
+	// wrapperSources records where root wrappers came from when Imports
+	// supplied or was overridden for them
+	wrapperSources map[string]ShimSource
+	// workspaceFiles lists the workspace manifests and directories read for
+	// Workspaces
+	workspaceFiles []string
+	// workspaceScopes maps the scopes Workspaces generated or gave a path to
+	// their package's directory
+	workspaceScopes map[string]string
+}
+
+// RequiresWrapped reports whether the config requires name to be wrapped
+func (c *ProjectConfig) RequiresWrapped(name string) bool {
+	return c.Require != nil && slices.Contains(c.Require.Wrapped, name)
+}
+
+// IsRoot returns true unless the config sets "root": false.
+func (c *ProjectConfig) IsRoot() bool {
+	return c.Root == nil || *c.Root
 }
 
 // ConfigFileName is the standard project configuration file name
@@ -69,101 +250,251 @@ const ConfigFileName = "ribbin.jsonc"
 // When present, it takes precedence over the standard config file.
 const LocalConfigFileName = "ribbin.local.jsonc"
 
+// TOMLConfigFileName and YAMLConfigFileName are alternative project config file
+// names with the same semantics as ribbin.jsonc.
+const (
+	TOMLConfigFileName = "ribbin.toml"
+	YAMLConfigFileName = "ribbin.yaml"
+)
+
+// LocalTOMLConfigFileName and LocalYAMLConfigFileName are the user-local
+// override variants of the TOML and YAML config files.
+const (
+	LocalTOMLConfigFileName = "ribbin.local.toml"
+	LocalYAMLConfigFileName = "ribbin.local.yaml"
+)
+
+// RootMarkerFileName marks a project boundary: config discovery from within
+// the directory holding it never looks above it, and a config there never
+// merges with one above it, even with "root": false. A vendored subrepo
+// gets one so the superproject's config can't govern it.
+const RootMarkerFileName = ".ribbin-root"
+
+// ConfigFileNames lists every recognized project config file name in lookup
+// order within a single directory. Local overrides come first, and JSONC is
+// preferred over TOML, which is preferred over YAML.
+var ConfigFileNames = []string{
+	LocalConfigFileName,
+	LocalTOMLConfigFileName,
+	LocalYAMLConfigFileName,
+	ConfigFileName,
+	TOMLConfigFileName,
+	YAMLConfigFileName,
+}
+
+// IsConfigFileName reports whether name is a recognized project config file name.
+func IsConfigFileName(name string) bool {
+	for _, n := range ConfigFileNames {
+		if name == n {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigInDir returns the config FindProjectConfig would pick in dir, applying
+// the same precedence (local overrides first, then ConfigFileNames order).
+// Returns an empty string if dir contains no config.
+func ConfigInDir(dir string) string {
+	for _, name := range ConfigFileNames {
+		configPath := filepath.Join(dir, name)
+		if _, err := os.Stat(configPath); err == nil {
+			return configPath
+		}
+	}
+	return ""
+}
+
 // FindProjectConfig walks up from the current working directory to find a ribbin config.
-// It prefers ribbin.local.jsonc over ribbin.jsonc when both exist in the same directory.
+// Within a directory, local overrides (ribbin.local.*) take precedence over standard
+// configs, and formats are tried in ConfigFileNames order. The walk stops at a
+// directory with a .ribbin-root marker (see RootMarkerFileName).
 // Returns the path to the config if found, or empty string if not found.
 func FindProjectConfig() (string, error) {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return "", err
 	}
+	return findConfigFrom(cwd)
+}
 
-	dir := cwd
-	for {
-		// Check for local config first (takes precedence)
-		localConfigPath := filepath.Join(dir, LocalConfigFileName)
-		if _, err := os.Stat(localConfigPath); err == nil {
-			// Validate config path before returning
-			if err := security.ValidateConfigPath(localConfigPath); err != nil {
-				return "", fmt.Errorf("unsafe config file at %s: %w", localConfigPath, err)
-			}
-			return localConfigPath, nil
+// FindProjectConfigFrom finds the nearest project config in dir or its
+// parents, like FindProjectConfig does for the current directory
+func FindProjectConfigFrom(dir string) (string, error) {
+	return findConfigFrom(dir)
+}
+
+// FindParentConfig finds the config that the config at configPath merges with
+// when it sets "root": false: the nearest config above configPath's directory.
+// Returns empty string if there is none, or if configPath's directory has a
+// .ribbin-root marker.
+func FindParentConfig(configPath string) (string, error) {
+	configDir := filepath.Dir(configPath)
+	dir := filepath.Dir(configDir)
+	if dir == configDir || hasRootMarker(configDir) {
+		return "", nil
+	}
+	return findConfigFrom(dir)
+}
+
+// ConfigBoundary returns the directory, dir or one above it, whose
+// .ribbin-root marker stops config discovery from dir before any config is
+// found. Returns empty string if discovery isn't stopped that way.
+func ConfigBoundary(dir string) string {
+	_, boundary, _ := searchConfigFrom(dir)
+	return boundary
+}
+
+// hasRootMarker reports whether dir has a .ribbin-root marker
+func hasRootMarker(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, RootMarkerFileName))
+	return err == nil
+}
+
+// ConfigChain returns configPath followed by each ancestor config it merges
+// with, nearest first. The chain ends at the first config that doesn't set
+// "root": false.
+func ConfigChain(configPath string) ([]string, error) {
+	chain := []string{configPath}
+	for path := configPath; ; {
+		cfg, err := LoadProjectConfig(path)
+		if err != nil {
+			return chain, err
 		}
+		if cfg.IsRoot() {
+			return chain, nil
+		}
+		parent, err := FindParentConfig(path)
+		if err != nil || parent == "" {
+			return chain, err
+		}
+		chain = append(chain, parent)
+		path = parent
+	}
+}
 
-		// Fall back to standard config
-		configPath := filepath.Join(dir, ConfigFileName)
-		if _, err := os.Stat(configPath); err == nil {
-			// Validate config path before returning
-			if err := security.ValidateConfigPath(configPath); err != nil {
-				return "", fmt.Errorf("unsafe config file at %s: %w", configPath, err)
+// EnforcingConfig returns the first config in configPath's chain (see
+// ConfigChain) that sets "enforce": true, or "" if none does.
+func EnforcingConfig(configPath string) (string, error) {
+	chain, err := ConfigChain(configPath)
+	for _, path := range chain {
+		cfg, loadErr := LoadProjectConfig(path)
+		if loadErr != nil {
+			return "", loadErr
+		}
+		if cfg.Enforce {
+			return path, nil
+		}
+	}
+	return "", err
+}
+
+// findConfigFrom walks up from dir to find a ribbin config, stopping at a
+// directory with a .ribbin-root marker.
+func findConfigFrom(dir string) (string, error) {
+	configPath, _, err := searchConfigFrom(dir)
+	return configPath, err
+}
+
+// searchConfigFrom walks up from dir to find a ribbin config. Without one,
+// boundary is the directory whose .ribbin-root marker stopped the search, if
+// any.
+func searchConfigFrom(dir string) (configPath, boundary string, err error) {
+	for {
+		for _, name := range ConfigFileNames {
+			configPath := filepath.Join(dir, name)
+			if _, err := os.Stat(configPath); err == nil {
+				// Validate config path before returning
+				if err := security.ValidateConfigPath(configPath); err != nil {
+					return "", "", fmt.Errorf("unsafe config file at %s: %w", configPath, err)
+				}
+				return configPath, "", nil
 			}
-			return configPath, nil
 		}
 
+		if hasRootMarker(dir) {
+			return "", dir, nil
+		}
 		parent := filepath.Dir(dir)
 		if parent == dir {
 			// Reached root without finding config
-			return "", nil
+			return "", "", nil
 		}
 		dir = parent
 	}
 }
 
-// LoadProjectConfig loads a project configuration from the specified path
+// LoadProjectConfig loads a project configuration from the specified path.
+// The format (JSONC, TOML, or YAML) is determined by the file extension.
+// Wrappers from "imports" are merged into the root wrappers, path
+// variables like ${PROJECT_ROOT} are expanded (see ExpandPathVars), and
+// with "workspaces" a scope is added for each workspace package.
 func LoadProjectConfig(path string) (*ProjectConfig, error) {
-	// Validate config path before loading
-	if err := security.ValidateConfigPath(path); err != nil {
-		return nil, fmt.Errorf("invalid config path: %w", err)
-	}
-
-	// Read the file
-	data, err := os.ReadFile(path)
+	config, err := loadConfigFile(path)
 	if err != nil {
 		return nil, err
 	}
-
-	// Parse JSONC (JSON with comments) to standard JSON
-	standardJSON, err := hujson.Standardize(data)
-	if err != nil {
-		return nil, fmt.Errorf("invalid JSONC: %w", err)
+	if err := applyImports(config, path); err != nil {
+		return nil, err
 	}
-
-	// Unmarshal JSON into config struct
-	var config ProjectConfig
-	if err := json.Unmarshal(standardJSON, &config); err != nil {
-		return nil, fmt.Errorf("invalid JSON: %w", err)
+	expandConfigPaths(config, path)
+	if err := applyWorkspaces(config, path); err != nil {
+		return nil, err
 	}
+	return config, nil
+}
 
-	// Validate scope paths
-	configDir := filepath.Dir(path)
-	for name, scope := range config.Scopes {
-		if err := ValidateScopePath(scope.Path, configDir); err != nil {
-			return nil, fmt.Errorf("scope %q: %w", name, err)
-		}
+// loadConfigFile loads a project configuration as written, without merging
+// in its imports or expanding path variables. Used when the config is edited and written back.
+func loadConfigFile(path string) (*ProjectConfig, error) {
+	// Validate config path before loading
+	if err := security.ValidateConfigPath(path); err != nil {
+		return nil, fmt.Errorf("invalid config path: %w", err)
 	}
-
-	return &config, nil
+	return parseConfigFile(path)
 }
 
 // LoadExtendsConfig loads a config file referenced via extends.
 // Unlike LoadProjectConfig, this allows any filename - extended configs
-// don't need to be named ribbin.jsonc or ribbin.local.jsonc.
+// don't need to be named ribbin.jsonc or ribbin.local.jsonc. Any supported
+// format can be extended, regardless of the format of the extending config.
 func LoadExtendsConfig(path string) (*ProjectConfig, error) {
 	// Use relaxed validation for extends (any filename allowed)
 	if err := security.ValidateExtendsConfigPath(path); err != nil {
 		return nil, fmt.Errorf("invalid extends config path: %w", err)
 	}
 
+	config, err := parseConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyImports(config, path); err != nil {
+		return nil, err
+	}
+	expandConfigPaths(config, path)
+	if err := applyWorkspaces(config, path); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// parseConfigFile reads and parses the config at path, whose path has
+// already been validated.
+func parseConfigFile(path string) (*ProjectConfig, error) {
 	// Read the file
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return parseConfigData(path, data)
+}
 
-	// Parse JSONC (JSON with comments) to standard JSON
-	standardJSON, err := hujson.Standardize(data)
+// parseConfigData parses the content of the config file at path.
+func parseConfigData(path string, data []byte) (*ProjectConfig, error) {
+	// Parse JSONC, TOML, or YAML to standard JSON
+	standardJSON, err := StandardizeConfig(path, data)
 	if err != nil {
-		return nil, fmt.Errorf("invalid JSONC: %w", err)
+		return nil, err
 	}
 
 	// Unmarshal JSON into config struct
@@ -185,13 +516,19 @@ func LoadExtendsConfig(path string) (*ProjectConfig, error) {
 
 // ValidateScopePath validates that a scope path is safe.
 // It must not contain ".." traversal and must resolve to a descendant of configDir.
-// Empty path is valid (defaults to ".").
+// Empty path is valid (defaults to "."), and path variables are expanded first.
 func ValidateScopePath(scopePath string, configDir string) error {
 	// Empty path defaults to ".", which is always valid
 	if scopePath == "" || scopePath == "." {
 		return nil
 	}
 
+	expanded, err := ExpandPathVars(scopePath, configDir)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidScopePath, err)
+	}
+	scopePath = expanded
+
 	// Reject paths containing ".." component
 	// This catches "../foo", "foo/../bar", "foo/..", etc.
 	cleaned := filepath.Clean(scopePath)
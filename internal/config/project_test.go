@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	_ "github.com/happycollision/ribbin/internal/testsafety"
@@ -100,6 +101,47 @@ func TestFindProjectConfig(t *testing.T) {
 		}
 	})
 
+	t.Run("stops at a .ribbin-root marker", func(t *testing.T) {
+		// super/ribbin.jsonc governs super/, but not super/vendor/lib, which is marked
+		superDir := filepath.Join(tmpDir, "super")
+		libDir := filepath.Join(superDir, "vendor", "lib")
+		srcDir := filepath.Join(libDir, "src")
+		if err := os.MkdirAll(srcDir, 0755); err != nil {
+			t.Fatalf("failed to create dirs: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(superDir, "ribbin.jsonc"), []byte("{\"wrappers\": {}}\n"), 0644); err != nil {
+			t.Fatalf("failed to create config: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(libDir, RootMarkerFileName), nil, 0644); err != nil {
+			t.Fatalf("failed to create marker: %v", err)
+		}
+
+		for _, dir := range []string{libDir, srcDir} {
+			found, err := FindProjectConfigFrom(dir)
+			if err != nil {
+				t.Fatalf("FindProjectConfigFrom error: %v", err)
+			}
+			if found != "" {
+				t.Errorf("from %s found %s, want nothing past the marker", dir, found)
+			}
+			if boundary := ConfigBoundary(dir); boundary != libDir {
+				t.Errorf("ConfigBoundary(%s) = %q, want %s", dir, boundary, libDir)
+			}
+		}
+
+		// The marked directory's own config is still found
+		libConfig := filepath.Join(libDir, "ribbin.jsonc")
+		if err := os.WriteFile(libConfig, []byte("{\"wrappers\": {}}\n"), 0644); err != nil {
+			t.Fatalf("failed to create config: %v", err)
+		}
+		if found, _ := FindProjectConfigFrom(srcDir); found != libConfig {
+			t.Errorf("found %s, want %s", found, libConfig)
+		}
+		if boundary := ConfigBoundary(srcDir); boundary != "" {
+			t.Errorf("ConfigBoundary with a config = %q, want none", boundary)
+		}
+	})
+
 	t.Run("prefers local config over standard config", func(t *testing.T) {
 		// Create a directory with both configs
 		projectDir := filepath.Join(tmpDir, "project-local")
@@ -649,3 +691,117 @@ func TestValidateScopePath(t *testing.T) {
 		}
 	})
 }
+
+func TestConfigChain(t *testing.T) {
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve symlinks: %v", err)
+	}
+
+	// top/ribbin.jsonc <- top/mid/ribbin.jsonc (root: false) <- top/mid/leaf/ribbin.jsonc (root: false)
+	// and a sibling top/mid/solo/ribbin.jsonc that stays a root
+	write := func(rel, content string) string {
+		path := filepath.Join(tmpDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		return path
+	}
+	top := write("top/ribbin.jsonc", `{"wrappers": {}}`)
+	mid := write("top/mid/ribbin.jsonc", `{"root": false}`)
+	leaf := write("top/mid/leaf/ribbin.jsonc", `{"root": false}`)
+	solo := write("top/mid/solo/ribbin.jsonc", `{"root": true}`)
+
+	tests := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{"root config", top, []string{top}},
+		{"explicit root", solo, []string{solo}},
+		{"one parent", mid, []string{mid, top}},
+		{"two parents", leaf, []string{leaf, mid, top}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConfigChain(tt.config)
+			if err != nil {
+				t.Fatalf("ConfigChain error: %v", err)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("ConfigChain = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("a .ribbin-root marker ends the chain", func(t *testing.T) {
+		vendored := write("top/vendor/lib/ribbin.jsonc", `{"root": false}`)
+		write("top/vendor/lib/"+RootMarkerFileName, "")
+		got, err := ConfigChain(vendored)
+		if err != nil {
+			t.Fatalf("ConfigChain error: %v", err)
+		}
+		if len(got) != 1 || got[0] != vendored {
+			t.Errorf("ConfigChain = %v, want [%s]", got, vendored)
+		}
+		_, warnings := ValidateConfigFile(vendored)
+		if len(warnings) != 1 || !strings.Contains(warnings[0], RootMarkerFileName) {
+			t.Errorf("warnings = %v, want one about the marker", warnings)
+		}
+	})
+
+	t.Run("no parent config", func(t *testing.T) {
+		orphan := write("orphan/ribbin.jsonc", `{"root": false}`)
+		got, err := ConfigChain(orphan)
+		if err != nil {
+			t.Fatalf("ConfigChain error: %v", err)
+		}
+		if len(got) != 1 || got[0] != orphan {
+			t.Errorf("ConfigChain = %v, want [%s]", got, orphan)
+		}
+	})
+}
+
+func TestEnforcingConfig(t *testing.T) {
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve symlinks: %v", err)
+	}
+	write := func(rel, content string) string {
+		path := filepath.Join(tmpDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		return path
+	}
+	top := write("top/ribbin.jsonc", `{"enforce": true}`)
+	child := write("top/child/ribbin.jsonc", `{"root": false, "enforce": false}`)
+	solo := write("top/solo/ribbin.jsonc", `{"wrappers": {}}`)
+
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"enforcing root", top, top},
+		{"child can't opt out", child, top},
+		{"separate root", solo, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EnforcingConfig(tt.config)
+			if err != nil {
+				t.Fatalf("EnforcingConfig error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("EnforcingConfig = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
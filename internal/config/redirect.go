@@ -0,0 +1,260 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// RedirectPlaceholders are the placeholders that inline redirect commands may use:
+//
+//	{args}     - all arguments passed to the wrapped command
+//	{arg0}     - the first argument passed to the wrapped command
+//	{cwd}      - the current working directory
+//	{original} - the path to the original (wrapped) binary
+var RedirectPlaceholders = []string{"{args}", "{arg0}", "{cwd}", "{original}"}
+
+// BuiltinRedirectPrefix starts a redirect to a target built into ribbin,
+// which the wrapper runs itself, like "builtin:trash"
+const BuiltinRedirectPrefix = "builtin:"
+
+// Redirect targets built into ribbin
+const (
+	// BuiltinTrash moves the files an rm-style command line names to the trash
+	BuiltinTrash = "trash"
+	// BuiltinConfirm asks y/N at the terminal, then runs the original
+	BuiltinConfirm = "confirm"
+	// BuiltinLogAndPass records the invocation in the audit log, then runs
+	// the original
+	BuiltinLogAndPass = "log-and-pass"
+)
+
+// BuiltinRedirects are the names of the redirect targets built into ribbin
+var BuiltinRedirects = []string{BuiltinTrash, BuiltinConfirm, BuiltinLogAndPass}
+
+// placeholderPattern matches anything that looks like a {placeholder}
+var placeholderPattern = regexp.MustCompile(`\{[A-Za-z0-9_]+\}`)
+
+// UnmarshalJSON accepts "redirect" as either a string (script path or command
+// template) or an array of strings (argv), and "unset" and "allowOnly" lists
+// in "env" (see decodeWrapperEnv).
+func (w *WrapperConfig) UnmarshalJSON(data []byte) error {
+	type plain WrapperConfig
+	var raw struct {
+		plain
+		Redirect json.RawMessage `json:"redirect,omitempty"`
+		Env      json.RawMessage `json:"env,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*w = WrapperConfig(raw.plain)
+	w.Redirect = ""
+	w.RedirectArgv = nil
+	if err := w.decodeWrapperEnv(raw.Env); err != nil {
+		return err
+	}
+
+	redirect := strings.TrimSpace(string(raw.Redirect))
+	if redirect == "" || redirect == "null" {
+		return nil
+	}
+	if strings.HasPrefix(redirect, "[") {
+		if err := json.Unmarshal(raw.Redirect, &w.RedirectArgv); err != nil {
+			return fmt.Errorf("redirect must be a string or an array of strings: %w", err)
+		}
+		return nil
+	}
+	if err := json.Unmarshal(raw.Redirect, &w.Redirect); err != nil {
+		return fmt.Errorf("redirect must be a string or an array of strings: %w", err)
+	}
+	return nil
+}
+
+// MarshalJSON writes RedirectArgv back as an array under the "redirect" key,
+// so configs keep whichever form they were written in, and EnvUnset and
+// EnvAllowOnly back into "env".
+func (w WrapperConfig) MarshalJSON() ([]byte, error) {
+	type plain WrapperConfig
+	out := struct {
+		plain
+		Redirect interface{}            `json:"redirect,omitempty"`
+		Env      map[string]interface{} `json:"env,omitempty"`
+	}{plain: plain(w), Env: w.encodeWrapperEnv()}
+
+	if len(w.RedirectArgv) > 0 {
+		out.Redirect = w.RedirectArgv
+	} else if w.Redirect != "" {
+		out.Redirect = w.Redirect
+	}
+	return json.Marshal(out)
+}
+
+// HasRedirect reports whether a redirect target is configured in any form.
+func (w WrapperConfig) HasRedirect() bool {
+	return w.Redirect != "" || len(w.RedirectArgv) > 0
+}
+
+// BuiltinRedirect returns the name of the target built into ribbin that the
+// redirect names, like "trash" for "builtin:trash"
+func (w WrapperConfig) BuiltinRedirect() (string, bool) {
+	if len(w.RedirectArgv) > 0 || !strings.HasPrefix(w.Redirect, BuiltinRedirectPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(w.Redirect, BuiltinRedirectPrefix), true
+}
+
+// IsBuiltinRedirect reports whether ribbin has a built-in redirect target
+// of this name
+func IsBuiltinRedirect(name string) bool {
+	return slices.Contains(BuiltinRedirects, name)
+}
+
+// IsInlineRedirect reports whether the redirect is an inline command (an argv
+// array or a string containing placeholders) rather than a script path.
+func (w WrapperConfig) IsInlineRedirect() bool {
+	if len(w.RedirectArgv) > 0 {
+		return true
+	}
+	return placeholderPattern.MatchString(w.Redirect)
+}
+
+// RedirectCommand returns the inline redirect command as argv words, with
+// placeholders not yet interpolated. Template strings are split on whitespace,
+// honoring single quotes, double quotes and backslash escapes; no shell is involved.
+func (w WrapperConfig) RedirectCommand() ([]string, error) {
+	var words []string
+	if len(w.RedirectArgv) > 0 {
+		words = w.RedirectArgv
+	} else {
+		var err error
+		words, err = splitCommandLine(w.Redirect)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkCommandWords(words, "redirect"); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// ParseHookCommand returns a hook command as argv words, with placeholders
+// not yet interpolated. Hooks are split like inline redirect templates and
+// may use the same placeholders.
+func ParseHookCommand(hook string) ([]string, error) {
+	words, err := splitCommandLine(hook)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCommandWords(words, "hook"); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// checkCommandWords checks that a command (a redirect or hook, named by
+// what) is not empty and only uses known placeholders.
+func checkCommandWords(words []string, what string) error {
+	if len(words) == 0 || words[0] == "" {
+		return fmt.Errorf("%s command is empty", what)
+	}
+	for _, word := range words {
+		for _, placeholder := range placeholderPattern.FindAllString(word, -1) {
+			if !isRedirectPlaceholder(placeholder) {
+				return fmt.Errorf("unknown placeholder %s in %s (available: %s)",
+					placeholder, what, strings.Join(RedirectPlaceholders, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// RedirectDisplay returns the redirect target as a single human-readable string.
+func (w WrapperConfig) RedirectDisplay() string {
+	if len(w.RedirectArgv) == 0 {
+		return w.Redirect
+	}
+	quoted := make([]string, len(w.RedirectArgv))
+	for i, word := range w.RedirectArgv {
+		if word == "" || strings.ContainsAny(word, " \t\n'\"\\") {
+			quoted[i] = fmt.Sprintf("%q", word)
+		} else {
+			quoted[i] = word
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+func isRedirectPlaceholder(s string) bool {
+	for _, p := range RedirectPlaceholders {
+		if s == p {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCommandLine splits a command template into words the way a POSIX shell
+// would for simple cases: whitespace separates words, quotes group them and
+// backslash escapes the next character (except inside single quotes).
+func splitCommandLine(s string) ([]string, error) {
+	var words []string
+	var current strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\'):
+				i++
+				current.WriteRune(runes[i])
+			default:
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("redirect command ends with a dangling backslash")
+			}
+			i++
+			current.WriteRune(runes[i])
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("redirect command has an unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestWrapperConfig_RedirectForms(t *testing.T) {
+	tests := []struct {
+		name        string
+		json        string
+		wantInline  bool
+		wantCommand []string
+		wantErr     bool
+	}{
+		{
+			name:       "script path",
+			json:       `{"action": "redirect", "redirect": "./scripts/npm.sh"}`,
+			wantInline: false,
+		},
+		{
+			name:       "built-in target",
+			json:       `{"action": "redirect", "redirect": "builtin:trash"}`,
+			wantInline: false,
+		},
+		{
+			name:        "template string",
+			json:        `{"action": "redirect", "redirect": "pnpm run {args}"}`,
+			wantInline:  true,
+			wantCommand: []string{"pnpm", "run", "{args}"},
+		},
+		{
+			name:        "template string with quotes",
+			json:        `{"action": "redirect", "redirect": "sh -c 'echo {arg0} && exit 1'"}`,
+			wantInline:  true,
+			wantCommand: []string{"sh", "-c", "echo {arg0} && exit 1"},
+		},
+		{
+			name:        "argv array",
+			json:        `{"action": "redirect", "redirect": ["pnpm", "exec", "{args}"]}`,
+			wantInline:  true,
+			wantCommand: []string{"pnpm", "exec", "{args}"},
+		},
+		{
+			name:       "unknown placeholder",
+			json:       `{"action": "redirect", "redirect": ["pnpm", "{argz}"]}`,
+			wantInline: true,
+			wantErr:    true,
+		},
+		{
+			name:       "unterminated quote",
+			json:       `{"action": "redirect", "redirect": "pnpm '{args}"}`,
+			wantInline: true,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w WrapperConfig
+			if err := json.Unmarshal([]byte(tt.json), &w); err != nil {
+				t.Fatalf("Unmarshal error = %v", err)
+			}
+			if !w.HasRedirect() {
+				t.Fatal("expected HasRedirect")
+			}
+			if w.IsInlineRedirect() != tt.wantInline {
+				t.Fatalf("IsInlineRedirect = %v, want %v", w.IsInlineRedirect(), tt.wantInline)
+			}
+			if !tt.wantInline {
+				return
+			}
+
+			got, err := w.RedirectCommand()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RedirectCommand error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && strings.Join(got, "|") != strings.Join(tt.wantCommand, "|") {
+				t.Errorf("RedirectCommand = %q, want %q", got, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func TestWrapperConfig_RedirectRoundTrip(t *testing.T) {
+	for _, input := range []string{
+		`{"action":"redirect","redirect":["pnpm","{args}"]}`,
+		`{"action":"redirect","redirect":"pnpm {args}"}`,
+	} {
+		var w WrapperConfig
+		if err := json.Unmarshal([]byte(input), &w); err != nil {
+			t.Fatalf("Unmarshal error = %v", err)
+		}
+		out, err := json.Marshal(w)
+		if err != nil {
+			t.Fatalf("Marshal error = %v", err)
+		}
+		if string(out) != input {
+			t.Errorf("round trip = %s, want %s", out, input)
+		}
+	}
+
+	var w WrapperConfig
+	if err := json.Unmarshal([]byte(`{"action":"redirect","redirect":42}`), &w); err == nil {
+		t.Error("expected error for non-string redirect")
+	}
+}
+
+func TestInlineRedirect_SchemaAndPersistence(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), ConfigFileName)
+	content := `{
+  "wrappers": {
+    "npm": {"action": "redirect", "redirect": ["pnpm", "{args}"]},
+    "yarn": {"action": "redirect", "redirect": "pnpm {args}"}
+  }
+}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidateAgainstSchema([]byte(content), ValidationStrict); err != nil {
+		t.Fatalf("inline redirects should pass strict schema: %v", err)
+	}
+
+	// Rewriting the file must keep the argv form
+	if err := AddShim(configPath, "bun", ShimConfig{Action: "block"}); err != nil {
+		t.Fatalf("AddShim error = %v", err)
+	}
+	cfg, err := LoadProjectConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig error = %v", err)
+	}
+	if got := cfg.Wrappers["npm"].RedirectArgv; strings.Join(got, "|") != "pnpm|{args}" {
+		t.Errorf("npm RedirectArgv after rewrite = %q", got)
+	}
+	if got := cfg.Wrappers["yarn"].Redirect; got != "pnpm {args}" {
+		t.Errorf("yarn Redirect after rewrite = %q", got)
+	}
+}
@@ -2,16 +2,26 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"syscall"
 	"time"
 
 	"github.com/happycollision/ribbin/internal/security"
 )
 
+// RegistryVersion is the current registry format. Version 1 (written without
+// a version field) keyed wrappers by command name, so two wrapped binaries
+// with the same name collided. Version 2 keys them by the absolute path of
+// the wrapped binary.
+const RegistryVersion = 2
+
 // WrapperEntry tracks an installed wrapper in the registry
 type WrapperEntry struct {
-	// Original is the path to the original command being wrapped
+	// Original is the absolute path of the wrapped command (also its key in
+	// Registry.Wrappers)
 	Original string `json:"original"`
 	// Config is the path to the ribbin.jsonc that defines this wrapper
 	Config string `json:"config"`
@@ -23,17 +33,37 @@ type ShellActivationEntry struct {
 	PID int `json:"pid"`
 	// ActivatedAt is when the session was activated
 	ActivatedAt time.Time `json:"activated_at"`
+	// Tags limits the activation to wrappers carrying one of these tags
+	Tags []string `json:"tags,omitempty"`
 }
 
 // ConfigActivationEntry tracks activation of a specific config file
 type ConfigActivationEntry struct {
 	// ActivatedAt is when the config was activated
 	ActivatedAt time.Time `json:"activated_at"`
+	// Tags limits the activation to wrappers carrying one of these tags
+	Tags []string `json:"tags,omitempty"`
+	// Scopes limits the activation to directories in these named scopes of
+	// the config
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// SnoozeEntry is a temporary, time-boxed bypass of a wrapped command
+type SnoozeEntry struct {
+	// Until is when the snooze expires and the wrapper fires again
+	Until time.Time `json:"until"`
+	// SnoozedAt is when the snooze was created
+	SnoozedAt time.Time `json:"snoozed_at"`
 }
 
+// SnoozeAllKey is the Snoozes key used for a snooze that covers every command
+const SnoozeAllKey = "*"
+
 // Registry is the global ribbin state stored in ~/.config/ribbin/registry.json
 type Registry struct {
-	// Wrappers maps command names to their wrapper entries
+	// Version is the registry format (see RegistryVersion)
+	Version int `json:"version"`
+	// Wrappers maps the absolute paths of wrapped binaries to their entries
 	Wrappers map[string]WrapperEntry `json:"wrappers"`
 	// ShellActivations tracks active shell sessions (all configs fire for this shell)
 	ShellActivations map[int]ShellActivationEntry `json:"shell_activations"`
@@ -41,6 +71,15 @@ type Registry struct {
 	ConfigActivations map[string]ConfigActivationEntry `json:"config_activations"`
 	// GlobalActive indicates if ribbin is globally enabled (everything fires everywhere)
 	GlobalActive bool `json:"global_active"`
+	// Snoozes maps command names (or SnoozeAllKey) to temporary bypasses
+	Snoozes map[string]SnoozeEntry `json:"snoozes,omitempty"`
+
+	// prunedShells counts the dead shell activations dropped when the
+	// registry was read
+	prunedShells int
+	// migratedFrom is the format version the registry was migrated from
+	// when it was read, or 0 if it was current
+	migratedFrom int
 }
 
 // RegistryPath returns the path to the global registry file.
@@ -49,7 +88,27 @@ func RegistryPath() (string, error) {
 	return security.ValidateRegistryPath()
 }
 
-// LoadRegistry loads the global registry, creating an empty one if it doesn't exist
+// registryLockTimeout is how long registry operations wait for the file lock.
+// Concurrent wraps in CI or multiple terminals queue up behind each other
+// rather than failing.
+const registryLockTimeout = 5 * time.Second
+
+// newRegistry returns an empty registry with all maps initialized
+func newRegistry() *Registry {
+	return &Registry{
+		Version:           RegistryVersion,
+		Wrappers:          make(map[string]WrapperEntry),
+		ShellActivations:  make(map[int]ShellActivationEntry),
+		ConfigActivations: make(map[string]ConfigActivationEntry),
+		GlobalActive:      false,
+	}
+}
+
+// LoadRegistry loads the global registry, creating an empty one if it doesn't exist.
+//
+// The result is a snapshot: saving it later with SaveRegistry overwrites any
+// changes made by other processes in between. Commands that modify the
+// registry should use UpdateRegistry instead.
 func LoadRegistry() (*Registry, error) {
 	path, err := RegistryPath()
 	if err != nil {
@@ -59,29 +118,68 @@ func LoadRegistry() (*Registry, error) {
 	// Check if file exists first (before acquiring lock)
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		// Return empty registry if file doesn't exist
-		return &Registry{
-			Wrappers:          make(map[string]WrapperEntry),
-			ShellActivations:  make(map[int]ShellActivationEntry),
-			ConfigActivations: make(map[string]ConfigActivationEntry),
-			GlobalActive:      false,
-		}, nil
+		return newRegistry(), nil
 	}
 
 	// SHARED LOCK for reading (allows concurrent reads)
-	lock, err := security.AcquireSharedLock(path, 5*time.Second)
+	lock, err := security.AcquireSharedLock(path, registryLockTimeout)
 	if err != nil {
 		return nil, err
 	}
 	defer lock.Release()
 
-	// Read registry
+	return readRegistryFile(path)
+}
+
+// UpdateRegistry performs a read-modify-write of the registry while holding
+// the exclusive registry lock, so concurrent wrap/unwrap/activate operations
+// cannot lose each other's entries. fn receives the current on-disk registry;
+// if it returns an error, nothing is written.
+func UpdateRegistry(fn func(r *Registry) error) error {
+	path, err := RegistryPath()
+	if err != nil {
+		return err
+	}
+
+	// Ensure directory exists (needed before lock file can be created)
+	if _, err := security.EnsureProfileDir(); err != nil {
+		return err
+	}
+
+	// EXCLUSIVE LOCK held across read, modify and write
+	lock, err := security.AcquireLock(path, registryLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	registry, err := readRegistryFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(registry); err != nil {
+		return err
+	}
+
+	return writeRegistryFile(path, registry)
+}
+
+// readRegistryFile reads and parses the registry. The caller must hold a lock.
+func readRegistryFile(path string) (*Registry, error) {
 	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return newRegistry(), nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	var registry Registry
 	if err := json.Unmarshal(data, &registry); err != nil {
+		return nil, corruptRegistryError(path, err)
+	}
+	if err := registry.migrate(); err != nil {
 		return nil, err
 	}
 
@@ -96,16 +194,203 @@ func LoadRegistry() (*Registry, error) {
 		registry.ConfigActivations = make(map[string]ConfigActivationEntry)
 	}
 
+	// Shells exit without deactivating, so drop their entries on every read.
+	// UpdateRegistry then writes the cleaned registry back.
+	registry.prunedShells = len(registry.PruneDeadShellActivations())
+
 	return &registry, nil
 }
 
-// PruneDeadShellActivations removes shell activation entries for processes that no longer exist.
-func (r *Registry) PruneDeadShellActivations() {
+// migrate upgrades a registry read from disk to RegistryVersion in place.
+// UpdateRegistry writes the upgraded registry back; LoadRegistry only
+// upgrades the copy in memory.
+func (r *Registry) migrate() error {
+	if r.Version > RegistryVersion {
+		return fmt.Errorf("registry format version %d is newer than this ribbin supports (%d); upgrade ribbin", r.Version, RegistryVersion)
+	}
+	if r.Version == RegistryVersion {
+		return nil
+	}
+
+	from := r.Version
+	if from == 0 {
+		from = 1
+	}
+	if from == 1 {
+		// Re-key wrappers from command name to wrapped path. Entries without
+		// a path can't be acted on and are dropped.
+		byPath := make(map[string]WrapperEntry, len(r.Wrappers))
+		for _, entry := range r.Wrappers {
+			if entry.Original == "" {
+				continue
+			}
+			entry.Original = wrapperKey(entry.Original)
+			byPath[entry.Original] = entry
+		}
+		r.Wrappers = byPath
+	}
+	r.Version = RegistryVersion
+	r.migratedFrom = from
+	return nil
+}
+
+// MigratedFrom returns the format version the registry was migrated from
+// when it was read, or 0 if it was already current.
+func (r *Registry) MigratedFrom() int {
+	return r.migratedFrom
+}
+
+// AddWrapper records entry, keyed by its Original path.
+func (r *Registry) AddWrapper(entry WrapperEntry) {
+	if r.Wrappers == nil {
+		r.Wrappers = make(map[string]WrapperEntry)
+	}
+	entry.Original = wrapperKey(entry.Original)
+	r.Wrappers[entry.Original] = entry
+}
+
+// RemoveWrapper removes the entry for the wrapped binary at binaryPath.
+func (r *Registry) RemoveWrapper(binaryPath string) {
+	delete(r.Wrappers, wrapperKey(binaryPath))
+}
+
+// Wrapper returns the entry for the wrapped binary at binaryPath, if any.
+func (r *Registry) Wrapper(binaryPath string) (WrapperEntry, bool) {
+	entry, ok := r.Wrappers[wrapperKey(binaryPath)]
+	return entry, ok
+}
+
+// CommandIndex maps each wrapped command name to the entries of every
+// binary with that name (e.g. the node_modules/.bin/tsc of several
+// packages), sorted by path.
+func (r *Registry) CommandIndex() map[string][]WrapperEntry {
+	index := make(map[string][]WrapperEntry)
+	for _, path := range r.WrapperPaths() {
+		name := filepath.Base(path)
+		index[name] = append(index[name], r.Wrappers[path])
+	}
+	return index
+}
+
+// WrappersNamed returns the entries of every wrapped binary called name,
+// sorted by path.
+func (r *Registry) WrappersNamed(name string) []WrapperEntry {
+	return r.CommandIndex()[name]
+}
+
+// WrappersForConfig returns the entries of the binaries wrapped for the
+// config at configPath, sorted by path.
+func (r *Registry) WrappersForConfig(configPath string) []WrapperEntry {
+	key := ConfigActivationKey(configPath)
+	var entries []WrapperEntry
+	for _, path := range r.WrapperPaths() {
+		entry := r.Wrappers[path]
+		if entry.Config == configPath || (entry.Config != "" && ConfigActivationKey(entry.Config) == key) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// LookupWrapper returns the entry for the wrapped binary at binaryPath or,
+// if that isn't registered, the first wrapped binary called name.
+func (r *Registry) LookupWrapper(name, binaryPath string) (WrapperEntry, bool) {
+	if binaryPath != "" {
+		if entry, ok := r.Wrapper(binaryPath); ok {
+			return entry, true
+		}
+	}
+	if entries := r.WrappersNamed(name); len(entries) > 0 {
+		return entries[0], true
+	}
+	return WrapperEntry{}, false
+}
+
+// HasCommand reports whether any wrapped binary is called name.
+func (r *Registry) HasCommand(name string) bool {
+	return len(r.WrappersNamed(name)) > 0
+}
+
+// wrapperKey returns the key a wrapped binary is stored under in
+// Registry.Wrappers: its absolute, cleaned path.
+func wrapperKey(binaryPath string) string {
+	if abs, err := filepath.Abs(binaryPath); err == nil {
+		return abs
+	}
+	return filepath.Clean(binaryPath)
+}
+
+// WrapperPaths returns the paths of all wrapped binaries, sorted.
+func (r *Registry) WrapperPaths() []string {
+	return sortedKeys(r.Wrappers)
+}
+
+// CloneWrappers returns a copy of the wrapper entries, for use as the "before"
+// side of MergeWrapperChanges.
+func (r *Registry) CloneWrappers() map[string]WrapperEntry {
+	clone := make(map[string]WrapperEntry, len(r.Wrappers))
+	for name, entry := range r.Wrappers {
+		clone[name] = entry
+	}
+	return clone
+}
+
+// MergeWrapperChanges applies the difference between before and after to r:
+// entries added or changed in after are set, and entries removed from after
+// are deleted. Entries this process never touched are left alone, so changes
+// made concurrently by other processes survive.
+//
+// This lets long operations like wrap and unwrap work on a snapshot without
+// holding the registry lock, then merge their results inside UpdateRegistry.
+func (r *Registry) MergeWrapperChanges(before, after map[string]WrapperEntry) {
+	if r.Wrappers == nil {
+		r.Wrappers = make(map[string]WrapperEntry)
+	}
+	for name, entry := range after {
+		if old, ok := before[name]; !ok || old != entry {
+			r.Wrappers[name] = entry
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			delete(r.Wrappers, name)
+		}
+	}
+}
+
+// PruneDeadShellActivations removes shell activation entries for processes
+// that no longer exist, and returns their PIDs.
+func (r *Registry) PruneDeadShellActivations() []int {
+	var pruned []int
 	for pid := range r.ShellActivations {
 		if !processExists(pid) {
 			delete(r.ShellActivations, pid)
+			pruned = append(pruned, pid)
 		}
 	}
+	return pruned
+}
+
+// PrunedShellActivations returns how many dead shell activations were
+// dropped when the registry was read.
+func (r *Registry) PrunedShellActivations() int {
+	return r.prunedShells
+}
+
+// PruneMissingConfigActivations removes activations of config files that no
+// longer exist, and returns their paths, sorted. These are not pruned
+// automatically, since a config can briefly disappear (e.g. while switching
+// git branches).
+func (r *Registry) PruneMissingConfigActivations() []string {
+	var pruned []string
+	for path := range r.ConfigActivations {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(r.ConfigActivations, path)
+			pruned = append(pruned, path)
+		}
+	}
+	sort.Strings(pruned)
+	return pruned
 }
 
 // ClearShellActivations removes all shell activations.
@@ -118,29 +403,80 @@ func (r *Registry) ClearConfigActivations() {
 	r.ConfigActivations = make(map[string]ConfigActivationEntry)
 }
 
-// AddConfigActivation adds a config to the activation set.
-func (r *Registry) AddConfigActivation(configPath string) {
+// ConfigActivationKey returns the key a config is stored under in
+// ConfigActivations: its absolute path with symlinks resolved, so that the
+// same file activated via different paths (e.g. /tmp vs /private/tmp on macOS)
+// matches the config the shim resolves at run time.
+func ConfigActivationKey(configPath string) string {
+	abs, err := filepath.Abs(configPath)
+	if err != nil {
+		return configPath
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
+
+// AddConfigActivation adds a config to the activation set. With tags, only
+// wrappers carrying one of them are activated.
+func (r *Registry) AddConfigActivation(configPath string, tags ...string) {
 	if r.ConfigActivations == nil {
 		r.ConfigActivations = make(map[string]ConfigActivationEntry)
 	}
-	r.ConfigActivations[configPath] = ConfigActivationEntry{
+	r.ConfigActivations[ConfigActivationKey(configPath)] = ConfigActivationEntry{
 		ActivatedAt: time.Now(),
+		Tags:        tags,
+	}
+}
+
+// AddScopedConfigActivation adds a config to the activation set, limited to
+// directories in the named scopes of the config. Elsewhere under the config,
+// wrappers run the original.
+func (r *Registry) AddScopedConfigActivation(configPath string, scopes ...string) {
+	if r.ConfigActivations == nil {
+		r.ConfigActivations = make(map[string]ConfigActivationEntry)
+	}
+	r.ConfigActivations[ConfigActivationKey(configPath)] = ConfigActivationEntry{
+		ActivatedAt: time.Now(),
+		Scopes:      scopes,
 	}
 }
 
 // RemoveConfigActivation removes a config from the activation set.
 func (r *Registry) RemoveConfigActivation(configPath string) {
 	delete(r.ConfigActivations, configPath)
+	delete(r.ConfigActivations, ConfigActivationKey(configPath))
+}
+
+// IsConfigActive reports whether configPath has been activated with
+// 'ribbin activate --config'. Entries written before activation keys were
+// canonicalized are matched by their literal path.
+func (r *Registry) IsConfigActive(configPath string) bool {
+	_, ok := r.ConfigActivation(configPath)
+	return ok
 }
 
-// AddShellActivation adds a shell activation for the given PID.
-func (r *Registry) AddShellActivation(pid int) {
+// ConfigActivation returns the activation entry for configPath, if it has
+// been activated (see IsConfigActive).
+func (r *Registry) ConfigActivation(configPath string) (ConfigActivationEntry, bool) {
+	if entry, ok := r.ConfigActivations[configPath]; ok {
+		return entry, true
+	}
+	entry, ok := r.ConfigActivations[ConfigActivationKey(configPath)]
+	return entry, ok
+}
+
+// AddShellActivation adds a shell activation for the given PID. With tags,
+// only wrappers carrying one of them are activated.
+func (r *Registry) AddShellActivation(pid int, tags ...string) {
 	if r.ShellActivations == nil {
 		r.ShellActivations = make(map[int]ShellActivationEntry)
 	}
 	r.ShellActivations[pid] = ShellActivationEntry{
 		PID:         pid,
 		ActivatedAt: time.Now(),
+		Tags:        tags,
 	}
 }
 
@@ -149,6 +485,78 @@ func (r *Registry) RemoveShellActivation(pid int) {
 	delete(r.ShellActivations, pid)
 }
 
+// AddSnooze bypasses cmdName (or every command, for SnoozeAllKey) until now+d.
+func (r *Registry) AddSnooze(cmdName string, d time.Duration) SnoozeEntry {
+	if r.Snoozes == nil {
+		r.Snoozes = make(map[string]SnoozeEntry)
+	}
+	now := time.Now()
+	entry := SnoozeEntry{
+		Until:     now.Add(d),
+		SnoozedAt: now,
+	}
+	r.Snoozes[cmdName] = entry
+	return entry
+}
+
+// RemoveSnooze cancels the snooze for cmdName (or SnoozeAllKey).
+func (r *Registry) RemoveSnooze(cmdName string) {
+	delete(r.Snoozes, cmdName)
+}
+
+// ClearSnoozes cancels all snoozes.
+func (r *Registry) ClearSnoozes() {
+	r.Snoozes = nil
+}
+
+// PruneExpiredSnoozes removes snoozes that have expired.
+func (r *Registry) PruneExpiredSnoozes(now time.Time) {
+	for name, entry := range r.Snoozes {
+		if !now.Before(entry.Until) {
+			delete(r.Snoozes, name)
+		}
+	}
+}
+
+// ActiveSnooze returns the snooze in effect for cmdName, if any. A per-command
+// snooze and a snooze of all commands may both apply; the later expiry wins.
+func (r *Registry) ActiveSnooze(cmdName string, now time.Time) (SnoozeEntry, bool) {
+	var best SnoozeEntry
+	found := false
+	for _, key := range []string{cmdName, SnoozeAllKey} {
+		entry, ok := r.Snoozes[key]
+		if !ok || !now.Before(entry.Until) {
+			continue
+		}
+		if !found || entry.Until.After(best.Until) {
+			best = entry
+			found = true
+		}
+	}
+	return best, found
+}
+
+// Remaining formats the time left on a snooze, e.g. "25m" or "1h30m".
+func (s SnoozeEntry) Remaining(now time.Time) string {
+	d := s.Until.Sub(now)
+	if d <= 0 {
+		return "expired"
+	}
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	d = d.Round(time.Minute)
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	if minutes == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh%02dm", hours, minutes)
+}
+
 // processExists checks if a process with the given PID exists.
 func processExists(pid int) bool {
 	// Sending signal 0 checks if process exists without affecting it
@@ -165,7 +573,8 @@ func processExists(pid int) bool {
 	return false
 }
 
-// SaveRegistry writes the registry to disk, creating directories as needed
+// SaveRegistry writes the registry to disk, creating directories as needed.
+// It replaces the whole file; prefer UpdateRegistry for read-modify-write.
 func SaveRegistry(r *Registry) error {
 	path, err := RegistryPath()
 	if err != nil {
@@ -173,20 +582,36 @@ func SaveRegistry(r *Registry) error {
 	}
 
 	// Ensure directory exists (needed before lock file can be created)
-	if _, err := security.EnsureConfigDir(); err != nil {
+	if _, err := security.EnsureProfileDir(); err != nil {
 		return err
 	}
 
 	// LOCK REGISTRY FILE
-	lock, err := security.AcquireLock(path, 5*time.Second)
+	lock, err := security.AcquireLock(path, registryLockTimeout)
 	if err != nil {
 		return err
 	}
 	defer lock.Release()
 
+	return writeRegistryFile(path, r)
+}
+
+// writeRegistryFile writes the registry via a temp file and rename. The caller
+// must hold the exclusive lock.
+func writeRegistryFile(path string, r *Registry) error {
+	// Registries built in memory without a version are written in the
+	// current format
+	current := *r
+	if err := current.migrate(); err != nil {
+		return err
+	}
+	// Drop the activations of shells that exited without deactivating,
+	// whatever the write is for
+	current.PruneDeadShellActivations()
+
 	// Write to temp file first
 	tmpPath := path + ".tmp"
-	data, err := json.MarshalIndent(r, "", "  ")
+	data, err := json.MarshalIndent(&current, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -195,20 +620,17 @@ func SaveRegistry(r *Registry) error {
 		return err
 	}
 
-	// Remove destination if it exists (safe because we hold the lock)
-	// This is necessary because AtomicRename uses O_EXCL which fails if file exists
-	if _, err := os.Stat(path); err == nil {
-		if err := os.Remove(path); err != nil {
-			os.Remove(tmpPath) // Cleanup temp file
-			return err
-		}
-	}
-
-	// ATOMIC RENAME
-	if err := security.AtomicRename(tmpPath, path); err != nil {
+	// ATOMIC RENAME over the existing file. rename(2) replaces the destination
+	// in one step, so readers never observe a missing registry (which they
+	// would treat as empty).
+	if err := os.Rename(tmpPath, path); err != nil {
 		os.Remove(tmpPath) // Cleanup
 		return err
 	}
 
+	// The snapshot only speeds up reads; without it they use the JSON
+	if err := writeRegistrySnapshot(path, &current); err != nil {
+		os.Remove(registrySnapshotPath(path))
+	}
 	return nil
 }
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// A registry.json that can't be parsed, as one cut short by a full disk,
+// would otherwise make every wrapper fail. Wrappers instead move it aside,
+// with its snapshot, as registry.json.corrupt-<time> and run their commands
+// unwrapped; 'ribbin registry repair' recovers the wrappers and activations
+// from the quarantined snapshot, the last registry written in full.
+
+// ErrRegistryCorrupt is returned for a registry.json that can't be parsed
+var ErrRegistryCorrupt = errors.New("registry is corrupt")
+
+// corruptMarker separates a quarantined registry's path from the time it
+// was quarantined
+const corruptMarker = ".corrupt-"
+
+// quarantinedSnapshotSuffix names the snapshot kept with a quarantined
+// registry
+const quarantinedSnapshotSuffix = ".snapshot"
+
+// repairedSuffix marks a quarantined registry 'ribbin registry repair' has
+// dealt with, which is kept for reference
+const repairedSuffix = ".repaired"
+
+// corruptRegistryError reports a registry.json that can't be parsed
+func corruptRegistryError(path string, err error) error {
+	return fmt.Errorf("%w: %s: %v; run 'ribbin registry repair'", ErrRegistryCorrupt, path, err)
+}
+
+// QuarantineRegistry moves a corrupt registry.json aside, with its
+// snapshot, so it no longer stands in the way. Returns where it was moved,
+// or "" if it was no longer corrupt, as when another process moved it first.
+func QuarantineRegistry() (string, error) {
+	path, err := RegistryPath()
+	if err != nil {
+		return "", err
+	}
+	lock, err := security.AcquireLock(path, registryLockTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer lock.Release()
+
+	if _, err := readRegistryFile(path); !errors.Is(err, ErrRegistryCorrupt) {
+		return "", nil
+	}
+
+	stamp := time.Now().UTC().Format("20060102-150405")
+	dest := path + corruptMarker + stamp
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(dest); os.IsNotExist(err) {
+			break
+		}
+		dest = fmt.Sprintf("%s%s%s-%d", path, corruptMarker, stamp, i)
+	}
+	if err := os.Rename(path, dest); err != nil {
+		return "", fmt.Errorf("cannot quarantine the registry: %w", err)
+	}
+	// The snapshot would be overwritten by the next write; kept, it is what
+	// the registry can be recovered from
+	if err := os.Rename(registrySnapshotPath(path), dest+quarantinedSnapshotSuffix); err != nil && !os.IsNotExist(err) {
+		os.Remove(registrySnapshotPath(path))
+	}
+	return dest, nil
+}
+
+// QuarantinedRegistries returns the paths of the quarantined registries not
+// yet repaired, oldest first
+func QuarantinedRegistries() ([]string, error) {
+	path, err := RegistryPath()
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(path + corruptMarker + "*")
+	if err != nil {
+		return nil, err
+	}
+	var quarantined []string
+	for _, match := range matches {
+		if !strings.HasSuffix(match, quarantinedSnapshotSuffix) && !strings.HasSuffix(match, repairedSuffix) {
+			quarantined = append(quarantined, match)
+		}
+	}
+	sort.Strings(quarantined)
+	return quarantined, nil
+}
+
+// RegistryQuarantined reports whether the registry was quarantined and
+// nothing has replaced it yet, so wrappers are running unwrapped
+func RegistryQuarantined() bool {
+	path, err := RegistryPath()
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return false
+	}
+	quarantined, err := QuarantinedRegistries()
+	return err == nil && len(quarantined) > 0
+}
+
+// RecoverRegistry reads the newest quarantined registry that can be
+// recovered: from its snapshot, or from the file itself if that has since
+// been fixed by hand. Returns the registry and the file it was read from,
+// or nil if none can be read.
+func RecoverRegistry() (*Registry, string, error) {
+	quarantined, err := QuarantinedRegistries()
+	if err != nil {
+		return nil, "", err
+	}
+	for i := len(quarantined) - 1; i >= 0; i-- {
+		snapshotPath := quarantined[i] + quarantinedSnapshotSuffix
+		if data, err := os.ReadFile(snapshotPath); err == nil {
+			if registry, _, err := decodeRegistrySnapshot(data); err == nil {
+				return registry, snapshotPath, nil
+			}
+		}
+		if registry, err := readRegistryFile(quarantined[i]); err == nil {
+			return registry, quarantined[i], nil
+		}
+	}
+	return nil, "", nil
+}
+
+// MarkRepaired renames a quarantined registry so it isn't recovered again,
+// and deletes its snapshot
+func MarkRepaired(quarantined string) error {
+	if err := os.Remove(quarantined + quarantinedSnapshotSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(quarantined, quarantined+repairedSuffix)
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestRegistryRepair(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path, err := RegistryPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := newRegistry()
+	registry.AddWrapper(WrapperEntry{Original: "/usr/local/bin/npm", Config: "/work/ribbin.jsonc"})
+	registry.ConfigActivations["/work/ribbin.jsonc"] = ConfigActivationEntry{}
+	if err := SaveRegistry(registry); err != nil {
+		t.Fatal(err)
+	}
+
+	if dest, err := QuarantineRegistry(); err != nil || dest != "" {
+		t.Fatalf("QuarantineRegistry on a good registry = %q, %v; want nothing done", dest, err)
+	}
+
+	// Cut short, as by a full disk
+	if err := os.WriteFile(path, []byte(`{"version": 2, "wrap`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRegistry(); !errors.Is(err, ErrRegistryCorrupt) {
+		t.Fatalf("LoadRegistry = %v, want ErrRegistryCorrupt", err)
+	}
+	if _, err := LoadRegistryReadOnly(); !errors.Is(err, ErrRegistryCorrupt) {
+		t.Fatalf("LoadRegistryReadOnly = %v, want ErrRegistryCorrupt", err)
+	}
+
+	dest, err := QuarantineRegistry()
+	if err != nil || dest == "" {
+		t.Fatalf("QuarantineRegistry = %q, %v", dest, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("registry.json should have been moved: %v", err)
+	}
+	if _, err := os.Stat(dest + quarantinedSnapshotSuffix); err != nil {
+		t.Errorf("the snapshot should have been kept with the quarantine: %v", err)
+	}
+	if !RegistryQuarantined() {
+		t.Error("RegistryQuarantined = false")
+	}
+	if again, err := QuarantineRegistry(); err != nil || again != "" {
+		t.Errorf("second QuarantineRegistry = %q, %v; want nothing done", again, err)
+	}
+	if loaded, err := LoadRegistry(); err != nil || len(loaded.Wrappers) != 0 {
+		t.Errorf("LoadRegistry after quarantine = %+v, %v; want an empty registry", loaded, err)
+	}
+
+	recovered, source, err := RecoverRegistry()
+	if err != nil || recovered == nil {
+		t.Fatalf("RecoverRegistry = %v, %v", recovered, err)
+	}
+	if source != dest+quarantinedSnapshotSuffix {
+		t.Errorf("recovered from %q, want the snapshot", source)
+	}
+	if !reflect.DeepEqual(recovered.Wrappers, registry.Wrappers) || len(recovered.ConfigActivations) != 1 {
+		t.Errorf("recovered = %+v, want %+v", recovered, registry)
+	}
+
+	if err := MarkRepaired(dest); err != nil {
+		t.Fatal(err)
+	}
+	if quarantined, err := QuarantinedRegistries(); err != nil || len(quarantined) != 0 {
+		t.Errorf("QuarantinedRegistries after repair = %v, %v", quarantined, err)
+	}
+	if _, err := os.Stat(dest + repairedSuffix); err != nil {
+		t.Errorf("the repaired quarantine should be kept: %v", err)
+	}
+	if recovered, _, err := RecoverRegistry(); err != nil || recovered != nil {
+		t.Errorf("RecoverRegistry after repair = %+v, %v; want nothing", recovered, err)
+	}
+}
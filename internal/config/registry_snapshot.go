@@ -0,0 +1,335 @@
+package config
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Every wrapped command reads the registry, so each write also leaves a
+// compact binary copy of it next to registry.json, which wrappers mmap and
+// decode without taking the registry lock or parsing JSON. The copy records
+// the size, modification time and inode of the registry.json it was made
+// from; when they don't match, as after an older ribbin wrote the registry,
+// or the copy's format isn't this ribbin's, readers fall back to the JSON.
+
+// registrySnapshotMagic starts every registry snapshot
+const registrySnapshotMagic = "RBNREG"
+
+// registrySnapshotFormat is the layout of the registry snapshot. Change it
+// whenever the encoding or the Registry fields change.
+const registrySnapshotFormat = 1
+
+// errStaleSnapshot is returned for a snapshot that doesn't match
+// registry.json
+var errStaleSnapshot = errors.New("registry snapshot is stale")
+
+// registrySnapshotPath returns the path of the snapshot of the registry at
+// path
+func registrySnapshotPath(path string) string {
+	return path + ".snapshot"
+}
+
+// registryFileID identifies one version of registry.json. Every write
+// renames a new file into place, so the inode changes even when the size
+// and modification time don't.
+type registryFileID struct {
+	Size    int64
+	ModTime int64
+	Inode   uint64
+}
+
+// statRegistryFile returns the ID of the registry file at path
+func statRegistryFile(path string) (registryFileID, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return registryFileID{}, err
+	}
+	id := registryFileID{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		id.Inode = uint64(st.Ino)
+	}
+	return id, nil
+}
+
+// LoadRegistryReadOnly loads the registry for reading only, from its
+// snapshot when that is current, without locking. Otherwise it falls back
+// to LoadRegistry. Wrappers use it on every run; commands that go on to
+// change the registry should use UpdateRegistry.
+func LoadRegistryReadOnly() (*Registry, error) {
+	path, err := RegistryPath()
+	if err != nil {
+		return nil, err
+	}
+	if registry, err := readRegistrySnapshot(path); err == nil {
+		return registry, nil
+	}
+	return LoadRegistry()
+}
+
+// readRegistrySnapshot maps and decodes the snapshot of the registry at
+// path, checking it against registry.json
+func readRegistrySnapshot(path string) (*Registry, error) {
+	f, err := os.Open(registrySnapshotPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return nil, errStaleSnapshot
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Munmap(data)
+
+	registry, source, err := decodeRegistrySnapshot(data)
+	if err != nil {
+		return nil, err
+	}
+	// The snapshot is written after registry.json, so one read mid-write
+	// is older than the JSON and doesn't match
+	if current, err := statRegistryFile(path); err != nil || current != source {
+		return nil, errStaleSnapshot
+	}
+
+	registry.prunedShells = len(registry.PruneDeadShellActivations())
+	return registry, nil
+}
+
+// writeRegistrySnapshot writes the snapshot of r, just written to path.
+// The caller must hold the exclusive lock.
+func writeRegistrySnapshot(path string, r *Registry) error {
+	source, err := statRegistryFile(path)
+	if err != nil {
+		return err
+	}
+	data, err := encodeRegistrySnapshot(r, source)
+	if err != nil {
+		return err
+	}
+	snapshotPath := registrySnapshotPath(path)
+	tmpPath := snapshotPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, snapshotPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// snapshotWriter builds a registry snapshot: fixed-size integers are
+// little-endian, and strings and times are length-prefixed
+type snapshotWriter struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (w *snapshotWriter) u32(v uint32) {
+	w.buf.Write(binary.LittleEndian.AppendUint32(nil, v))
+}
+
+func (w *snapshotWriter) u64(v uint64) {
+	w.buf.Write(binary.LittleEndian.AppendUint64(nil, v))
+}
+
+func (w *snapshotWriter) str(s string) {
+	w.u32(uint32(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *snapshotWriter) strs(list []string) {
+	w.u32(uint32(len(list)))
+	for _, s := range list {
+		w.str(s)
+	}
+}
+
+func (w *snapshotWriter) time(t time.Time) {
+	data, err := t.MarshalBinary()
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	w.str(string(data))
+}
+
+// encodeRegistrySnapshot encodes r, made from the registry.json source
+func encodeRegistrySnapshot(r *Registry, source registryFileID) ([]byte, error) {
+	w := &snapshotWriter{}
+	w.buf.WriteString(registrySnapshotMagic)
+	w.u32(registrySnapshotFormat)
+	w.u32(uint32(r.Version))
+	w.u64(uint64(source.Size))
+	w.u64(uint64(source.ModTime))
+	w.u64(source.Inode)
+	if r.GlobalActive {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+
+	w.u32(uint32(len(r.Wrappers)))
+	for key, entry := range r.Wrappers {
+		w.str(key)
+		w.str(entry.Original)
+		w.str(entry.Config)
+	}
+	w.u32(uint32(len(r.ShellActivations)))
+	for pid, entry := range r.ShellActivations {
+		w.u64(uint64(int64(pid)))
+		w.u64(uint64(int64(entry.PID)))
+		w.time(entry.ActivatedAt)
+		w.strs(entry.Tags)
+	}
+	w.u32(uint32(len(r.ConfigActivations)))
+	for key, entry := range r.ConfigActivations {
+		w.str(key)
+		w.time(entry.ActivatedAt)
+		w.strs(entry.Tags)
+		w.strs(entry.Scopes)
+	}
+	w.u32(uint32(len(r.Snoozes)))
+	for key, entry := range r.Snoozes {
+		w.str(key)
+		w.time(entry.Until)
+		w.time(entry.SnoozedAt)
+	}
+
+	if w.err != nil {
+		return nil, w.err
+	}
+	w.u32(crc32.ChecksumIEEE(w.buf.Bytes()))
+	return w.buf.Bytes(), nil
+}
+
+// snapshotReader decodes a registry snapshot. Reading past the end sets
+// err and returns zero values from then on.
+type snapshotReader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+var errCorruptSnapshot = errors.New("registry snapshot is corrupt")
+
+func (r *snapshotReader) next(n int) []byte {
+	if r.err != nil || n < 0 || r.off+n > len(r.data) {
+		r.err = errCorruptSnapshot
+		return nil
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *snapshotReader) u32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *snapshotReader) u64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+// count reads a length, which can't exceed what's left of the data
+func (r *snapshotReader) count() int {
+	n := int(r.u32())
+	if n > len(r.data)-r.off {
+		r.err = errCorruptSnapshot
+		return 0
+	}
+	return n
+}
+
+// str copies a string out, as the data is unmapped once decoded
+func (r *snapshotReader) str() string {
+	return string(r.next(r.count()))
+}
+
+func (r *snapshotReader) strs() []string {
+	n := r.count()
+	if n == 0 {
+		return nil
+	}
+	list := make([]string, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		list = append(list, r.str())
+	}
+	return list
+}
+
+func (r *snapshotReader) time() time.Time {
+	var t time.Time
+	if err := t.UnmarshalBinary(r.next(r.count())); err != nil && r.err == nil {
+		r.err = errCorruptSnapshot
+	}
+	return t
+}
+
+// decodeRegistrySnapshot decodes a snapshot, returning the registry and the
+// registry.json it was made from
+func decodeRegistrySnapshot(data []byte) (*Registry, registryFileID, error) {
+	var source registryFileID
+	if len(data) < len(registrySnapshotMagic)+4 || string(data[:len(registrySnapshotMagic)]) != registrySnapshotMagic {
+		return nil, source, errCorruptSnapshot
+	}
+	body := data[:len(data)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(data[len(body):]) {
+		return nil, source, errCorruptSnapshot
+	}
+
+	r := &snapshotReader{data: body, off: len(registrySnapshotMagic)}
+	if r.u32() != registrySnapshotFormat || int(r.u32()) != RegistryVersion {
+		return nil, source, errStaleSnapshot
+	}
+	source.Size = int64(r.u64())
+	source.ModTime = int64(r.u64())
+	source.Inode = r.u64()
+
+	registry := newRegistry()
+	if b := r.next(1); b != nil {
+		registry.GlobalActive = b[0] == 1
+	}
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		key := r.str()
+		registry.Wrappers[key] = WrapperEntry{Original: r.str(), Config: r.str()}
+	}
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		pid := int(int64(r.u64()))
+		registry.ShellActivations[pid] = ShellActivationEntry{PID: int(int64(r.u64())), ActivatedAt: r.time(), Tags: r.strs()}
+	}
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		key := r.str()
+		registry.ConfigActivations[key] = ConfigActivationEntry{ActivatedAt: r.time(), Tags: r.strs(), Scopes: r.strs()}
+	}
+	if n := r.count(); n > 0 {
+		registry.Snoozes = make(map[string]SnoozeEntry, n)
+		for ; n > 0 && r.err == nil; n-- {
+			key := r.str()
+			registry.Snoozes[key] = SnoozeEntry{Until: r.time(), SnoozedAt: r.time()}
+		}
+	}
+	if r.err == nil && r.off != len(body) {
+		r.err = errCorruptSnapshot
+	}
+	if r.err != nil {
+		return nil, source, r.err
+	}
+	return registry, source, nil
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestRegistrySnapshot(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path, err := RegistryPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	registry := newRegistry()
+	registry.GlobalActive = true
+	registry.AddWrapper(WrapperEntry{Original: "/usr/local/bin/npm", Config: "/work/ribbin.jsonc"})
+	registry.AddWrapper(WrapperEntry{Original: "/usr/local/bin/tsc"})
+	registry.ShellActivations[os.Getpid()] = ShellActivationEntry{PID: os.Getpid(), ActivatedAt: now, Tags: []string{"node"}}
+	registry.ConfigActivations["/work/ribbin.jsonc"] = ConfigActivationEntry{ActivatedAt: now, Scopes: []string{"web", "api"}}
+	registry.AddSnooze("npm", time.Hour)
+	if err := SaveRegistry(registry); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := readRegistrySnapshot(path)
+	if err != nil {
+		t.Fatalf("readRegistrySnapshot: %v", err)
+	}
+	if !loaded.GlobalActive || loaded.Version != RegistryVersion || !reflect.DeepEqual(loaded.Wrappers, registry.Wrappers) {
+		t.Errorf("loaded = %+v, want %+v", loaded, registry)
+	}
+	shell := loaded.ShellActivations[os.Getpid()]
+	if !shell.ActivatedAt.Equal(now) || !reflect.DeepEqual(shell.Tags, []string{"node"}) {
+		t.Errorf("shell activation = %+v", shell)
+	}
+	act := loaded.ConfigActivations["/work/ribbin.jsonc"]
+	if !act.ActivatedAt.Equal(now) || act.Tags != nil || !reflect.DeepEqual(act.Scopes, []string{"web", "api"}) {
+		t.Errorf("config activation = %+v", act)
+	}
+	if snooze, ok := loaded.ActiveSnooze("npm", now); !ok || !snooze.Until.Equal(registry.Snoozes["npm"].Until) {
+		t.Errorf("snooze = %+v, %v", snooze, ok)
+	}
+
+	t.Run("stale after registry.json is written without it", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte(`{"version": 2, "wrappers": {}, "global_active": false}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readRegistrySnapshot(path); err == nil {
+			t.Error("expected the snapshot to be stale")
+		}
+		loaded, err := LoadRegistryReadOnly()
+		if err != nil || loaded.GlobalActive || len(loaded.Wrappers) != 0 {
+			t.Errorf("LoadRegistryReadOnly = %+v, %v; want the JSON", loaded, err)
+		}
+	})
+
+	t.Run("corrupt snapshot falls back to the JSON", func(t *testing.T) {
+		if err := SaveRegistry(registry); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(registrySnapshotPath(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[len(data)/2] ^= 0xff
+		if err := os.WriteFile(registrySnapshotPath(path), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readRegistrySnapshot(path); err == nil {
+			t.Error("expected a corrupt snapshot to be refused")
+		}
+		loaded, err := LoadRegistryReadOnly()
+		if err != nil || len(loaded.Wrappers) != 2 {
+			t.Errorf("LoadRegistryReadOnly = %+v, %v; want the JSON", loaded, err)
+		}
+	})
+
+	t.Run("other format is refused", func(t *testing.T) {
+		data, err := encodeRegistrySnapshot(registry, registryFileID{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		binary.LittleEndian.PutUint32(data[len(registrySnapshotMagic):], registrySnapshotFormat+1)
+		body := data[:len(data)-4]
+		binary.LittleEndian.PutUint32(data[len(body):], crc32.ChecksumIEEE(body))
+		if _, _, err := decodeRegistrySnapshot(data); err != errStaleSnapshot {
+			t.Errorf("decode = %v, want errStaleSnapshot", err)
+		}
+	})
+
+	t.Run("truncated snapshot is refused", func(t *testing.T) {
+		data, err := encodeRegistrySnapshot(registry, registryFileID{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []int{0, 5, len(data) / 2, len(data) - 1} {
+			if _, _, err := decodeRegistrySnapshot(data[:n]); err == nil {
+				t.Errorf("decoding %d of %d bytes succeeded", n, len(data))
+			}
+		}
+	})
+}
+
+func BenchmarkLoadRegistry(b *testing.B) {
+	b.Setenv("HOME", b.TempDir())
+	registry := newRegistry()
+	for i := 0; i < 200; i++ {
+		registry.AddWrapper(WrapperEntry{Original: fmt.Sprintf("/opt/tools/bin/tool%d", i), Config: "/work/ribbin.jsonc"})
+	}
+	if err := SaveRegistry(registry); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("json", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := LoadRegistry(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("snapshot", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := LoadRegistryReadOnly(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
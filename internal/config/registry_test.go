@@ -2,8 +2,11 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -73,7 +76,8 @@ func TestLoadRegistry(t *testing.T) {
 				"cat": {Original: "/usr/bin/cat", Config: "/project/ribbin.jsonc"},
 			},
 			ShellActivations: map[int]ShellActivationEntry{
-				1234: {PID: 1234, ActivatedAt: time.Now()},
+				os.Getpid(): {PID: os.Getpid(), ActivatedAt: time.Now()},
+				99999999:    {PID: 99999999, ActivatedAt: time.Now()}, // dead, dropped on load
 			},
 			ConfigActivations: map[string]ConfigActivationEntry{
 				"/project/ribbin.jsonc": {ActivatedAt: time.Now()},
@@ -99,12 +103,15 @@ func TestLoadRegistry(t *testing.T) {
 		if !loaded.GlobalActive {
 			t.Error("GlobalActive should be true")
 		}
-		if _, exists := loaded.Wrappers["cat"]; !exists {
+		if !loaded.HasCommand("cat") {
 			t.Error("cat wrapper should exist")
 		}
 		if len(loaded.ShellActivations) != 1 {
 			t.Errorf("expected 1 shell activation, got %d", len(loaded.ShellActivations))
 		}
+		if loaded.PrunedShellActivations() != 1 {
+			t.Errorf("expected 1 pruned shell activation, got %d", loaded.PrunedShellActivations())
+		}
 		if len(loaded.ConfigActivations) != 1 {
 			t.Errorf("expected 1 config activation, got %d", len(loaded.ConfigActivations))
 		}
@@ -222,12 +229,229 @@ func TestSaveRegistry(t *testing.T) {
 		if !loaded.GlobalActive {
 			t.Error("GlobalActive should be true")
 		}
-		if _, exists := loaded.Wrappers["cat"]; !exists {
+		if !loaded.HasCommand("cat") {
 			t.Error("cat wrapper should exist")
 		}
 	})
 }
 
+func TestUpdateRegistry(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+
+	t.Run("concurrent updates do not lose entries", func(t *testing.T) {
+		const workers = 10
+		var wg sync.WaitGroup
+		errs := make(chan error, workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs <- UpdateRegistry(func(r *Registry) error {
+					name := fmt.Sprintf("cmd%d", i)
+					r.AddWrapper(WrapperEntry{Original: "/bin/" + name})
+					return nil
+				})
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("UpdateRegistry error: %v", err)
+			}
+		}
+
+		loaded, err := LoadRegistry()
+		if err != nil {
+			t.Fatalf("LoadRegistry error: %v", err)
+		}
+		if len(loaded.Wrappers) != workers {
+			t.Errorf("expected %d wrappers, got %d: %v", workers, len(loaded.Wrappers), loaded.Wrappers)
+		}
+	})
+
+	t.Run("error from fn leaves registry untouched", func(t *testing.T) {
+		err := UpdateRegistry(func(r *Registry) error {
+			r.GlobalActive = true
+			return fmt.Errorf("boom")
+		})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		loaded, _ := LoadRegistry()
+		if loaded.GlobalActive {
+			t.Error("registry should not be written when fn fails")
+		}
+	})
+}
+
+func TestRegistryMigration(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path, err := RegistryPath()
+	if err != nil {
+		t.Fatalf("RegistryPath error: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeV1 := func(t *testing.T) {
+		t.Helper()
+		v1 := `{
+  "wrappers": {
+    "tsc": {"original": "/project/node_modules/.bin/tsc", "config": "/project/ribbin.jsonc"},
+    "npm": {"original": "/usr/local/bin/npm", "config": "/project/ribbin.jsonc"},
+    "broken": {"original": "", "config": ""}
+  },
+  "shell_activations": {},
+  "config_activations": {},
+  "global_active": true
+}`
+		if err := os.WriteFile(path, []byte(v1), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("load migrates v1 in memory only", func(t *testing.T) {
+		writeV1(t)
+		loaded, err := LoadRegistry()
+		if err != nil {
+			t.Fatalf("LoadRegistry error: %v", err)
+		}
+		if loaded.Version != RegistryVersion || loaded.MigratedFrom() != 1 {
+			t.Errorf("Version = %d, MigratedFrom = %d", loaded.Version, loaded.MigratedFrom())
+		}
+		if got := loaded.WrapperPaths(); len(got) != 2 || got[0] != "/project/node_modules/.bin/tsc" || got[1] != "/usr/local/bin/npm" {
+			t.Errorf("WrapperPaths = %v", got)
+		}
+		if !loaded.GlobalActive {
+			t.Error("other state should survive migration")
+		}
+		data, _ := os.ReadFile(path)
+		if strings.Contains(string(data), `"version"`) {
+			t.Error("LoadRegistry should not rewrite the file")
+		}
+	})
+
+	t.Run("update writes v2", func(t *testing.T) {
+		writeV1(t)
+		if err := UpdateRegistry(func(r *Registry) error { return nil }); err != nil {
+			t.Fatalf("UpdateRegistry error: %v", err)
+		}
+		var raw struct {
+			Version  int                     `json:"version"`
+			Wrappers map[string]WrapperEntry `json:"wrappers"`
+		}
+		data, _ := os.ReadFile(path)
+		if err := json.Unmarshal(data, &raw); err != nil {
+			t.Fatal(err)
+		}
+		if raw.Version != RegistryVersion {
+			t.Errorf("version = %d, want %d", raw.Version, RegistryVersion)
+		}
+		if _, ok := raw.Wrappers["/usr/local/bin/npm"]; !ok {
+			t.Errorf("wrappers should be keyed by path: %v", raw.Wrappers)
+		}
+
+		loaded, _ := LoadRegistry()
+		if loaded.MigratedFrom() != 0 {
+			t.Errorf("MigratedFrom = %d after writing v2", loaded.MigratedFrom())
+		}
+	})
+
+	t.Run("refuses newer versions", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte(`{"version": 99, "wrappers": {}}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRegistry(); err == nil {
+			t.Error("expected error loading a registry from a newer ribbin")
+		}
+	})
+}
+
+func TestRegistryWrappersByPath(t *testing.T) {
+	r := newRegistry()
+	r.AddWrapper(WrapperEntry{Original: "/a/node_modules/.bin/tsc", Config: "/a/ribbin.jsonc"})
+	r.AddWrapper(WrapperEntry{Original: "/b/node_modules/.bin/tsc", Config: "/b/ribbin.jsonc"})
+	r.AddWrapper(WrapperEntry{Original: "/usr/bin/../bin/npm"})
+
+	if named := r.WrappersNamed("tsc"); len(named) != 2 {
+		t.Fatalf("two tsc binaries should not collide, got %v", named)
+	}
+	if entry, ok := r.Wrapper("/usr/bin/npm"); !ok || entry.Original != "/usr/bin/npm" {
+		t.Errorf("paths should be cleaned, got %+v", entry)
+	}
+	if entry, ok := r.LookupWrapper("tsc", "/b/node_modules/.bin/tsc"); !ok || entry.Config != "/b/ribbin.jsonc" {
+		t.Errorf("LookupWrapper by path = %+v", entry)
+	}
+	if entry, ok := r.LookupWrapper("tsc", "/elsewhere/tsc"); !ok || entry.Config != "/a/ribbin.jsonc" {
+		t.Errorf("LookupWrapper should fall back to the first by name, got %+v", entry)
+	}
+
+	r.RemoveWrapper("/a/node_modules/.bin/tsc")
+	if !r.HasCommand("tsc") || len(r.WrappersNamed("tsc")) != 1 {
+		t.Error("removing one tsc should leave the other")
+	}
+}
+
+func TestRegistryCommandIndex(t *testing.T) {
+	r := newRegistry()
+	for _, pkg := range []string{"/repo/packages/c", "/repo/packages/a", "/repo/packages/b"} {
+		r.AddWrapper(WrapperEntry{Original: pkg + "/node_modules/.bin/tsc", Config: pkg + "/ribbin.jsonc"})
+	}
+	r.AddWrapper(WrapperEntry{Original: "/usr/bin/npm", Config: "/repo/ribbin.jsonc"})
+
+	index := r.CommandIndex()
+	if len(index) != 2 {
+		t.Fatalf("expected 2 commands, got %v", index)
+	}
+	tsc := index["tsc"]
+	if len(tsc) != 3 {
+		t.Fatalf("expected 3 tsc binaries, got %v", tsc)
+	}
+	if tsc[0].Original != "/repo/packages/a/node_modules/.bin/tsc" || tsc[2].Original != "/repo/packages/c/node_modules/.bin/tsc" {
+		t.Errorf("tsc binaries should be sorted by path, got %v", tsc)
+	}
+
+	forB := r.WrappersForConfig("/repo/packages/b/ribbin.jsonc")
+	if len(forB) != 1 || forB[0].Original != "/repo/packages/b/node_modules/.bin/tsc" {
+		t.Errorf("WrappersForConfig should only return b's tsc, got %v", forB)
+	}
+}
+
+func TestMergeWrapperChanges(t *testing.T) {
+	before := map[string]WrapperEntry{
+		"tsc": {Original: "/bin/tsc", Config: "/a/ribbin.jsonc"},
+		"cat": {Original: "/bin/cat", Config: "/a/ribbin.jsonc"},
+	}
+	// This process wrapped npm and unwrapped cat
+	after := map[string]WrapperEntry{
+		"tsc": {Original: "/bin/tsc", Config: "/a/ribbin.jsonc"},
+		"npm": {Original: "/bin/npm", Config: "/a/ribbin.jsonc"},
+	}
+	// Meanwhile another process wrapped yarn and re-pointed tsc
+	latest := &Registry{Wrappers: map[string]WrapperEntry{
+		"tsc":  {Original: "/other/tsc", Config: "/b/ribbin.jsonc"},
+		"cat":  {Original: "/bin/cat", Config: "/a/ribbin.jsonc"},
+		"yarn": {Original: "/bin/yarn", Config: "/b/ribbin.jsonc"},
+	}}
+
+	latest.MergeWrapperChanges(before, after)
+
+	if _, ok := latest.Wrappers["npm"]; !ok {
+		t.Error("npm added by this process should be merged")
+	}
+	if _, ok := latest.Wrappers["cat"]; ok {
+		t.Error("cat removed by this process should be deleted")
+	}
+	if _, ok := latest.Wrappers["yarn"]; !ok {
+		t.Error("yarn added concurrently should survive")
+	}
+	if latest.Wrappers["tsc"].Original != "/other/tsc" {
+		t.Error("tsc untouched by this process should keep the concurrent change")
+	}
+}
+
 func TestPruneDeadShellActivations(t *testing.T) {
 	registry := &Registry{
 		Wrappers: make(map[string]WrapperEntry),
@@ -239,7 +463,10 @@ func TestPruneDeadShellActivations(t *testing.T) {
 		GlobalActive:      false,
 	}
 
-	registry.PruneDeadShellActivations()
+	pruned := registry.PruneDeadShellActivations()
+	if len(pruned) != 1 || pruned[0] != 99999999 {
+		t.Errorf("expected dead PID to be returned, got %v", pruned)
+	}
 
 	// PID 1 (init/launchd) should still exist
 	if _, exists := registry.ShellActivations[1]; !exists {
@@ -252,6 +479,101 @@ func TestPruneDeadShellActivations(t *testing.T) {
 	}
 }
 
+func TestPruneMissingConfigActivations(t *testing.T) {
+	tmpDir := t.TempDir()
+	existing := filepath.Join(tmpDir, "ribbin.jsonc")
+	if err := os.WriteFile(existing, []byte("{}"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	missingA := filepath.Join(tmpDir, "a", "ribbin.jsonc")
+	missingB := filepath.Join(tmpDir, "b", "ribbin.jsonc")
+
+	registry := &Registry{
+		ConfigActivations: map[string]ConfigActivationEntry{
+			existing: {ActivatedAt: time.Now()},
+			missingB: {ActivatedAt: time.Now()},
+			missingA: {ActivatedAt: time.Now()},
+		},
+	}
+
+	pruned := registry.PruneMissingConfigActivations()
+	if len(pruned) != 2 || pruned[0] != missingA || pruned[1] != missingB {
+		t.Errorf("expected missing configs in order, got %v", pruned)
+	}
+	if !registry.IsConfigActive(existing) {
+		t.Error("existing config should stay active")
+	}
+	if len(registry.ConfigActivations) != 1 {
+		t.Errorf("expected 1 config activation left, got %d", len(registry.ConfigActivations))
+	}
+}
+
+func TestUpdateRegistryWritesPrunedShells(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	err := UpdateRegistry(func(r *Registry) error {
+		r.AddShellActivation(os.Getpid())
+		r.AddShellActivation(99999999)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateRegistry error: %v", err)
+	}
+
+	// Any later write persists the cleanup
+	if err := UpdateRegistry(func(r *Registry) error { return nil }); err != nil {
+		t.Fatalf("UpdateRegistry error: %v", err)
+	}
+	path, err := RegistryPath()
+	if err != nil {
+		t.Fatalf("RegistryPath error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read registry: %v", err)
+	}
+	var onDisk Registry
+	if err := json.Unmarshal(data, &onDisk); err != nil {
+		t.Fatalf("failed to parse registry: %v", err)
+	}
+	if _, ok := onDisk.ShellActivations[99999999]; ok {
+		t.Error("dead shell activation should have been written out of the registry")
+	}
+	if _, ok := onDisk.ShellActivations[os.Getpid()]; !ok {
+		t.Error("live shell activation should be kept")
+	}
+}
+
+func TestSaveRegistryPrunesDeadShells(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	registry := newRegistry()
+	registry.AddShellActivation(os.Getpid())
+	registry.AddShellActivation(99999999)
+	if err := SaveRegistry(registry); err != nil {
+		t.Fatalf("SaveRegistry error: %v", err)
+	}
+
+	path, err := RegistryPath()
+	if err != nil {
+		t.Fatalf("RegistryPath error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read registry: %v", err)
+	}
+	var onDisk Registry
+	if err := json.Unmarshal(data, &onDisk); err != nil {
+		t.Fatalf("failed to parse registry: %v", err)
+	}
+	if _, ok := onDisk.ShellActivations[99999999]; ok {
+		t.Error("dead shell activation should not be written")
+	}
+	if _, ok := onDisk.ShellActivations[os.Getpid()]; !ok {
+		t.Error("live shell activation should be kept")
+	}
+}
+
 func TestConfigActivationHelpers(t *testing.T) {
 	registry := &Registry{
 		Wrappers:          make(map[string]WrapperEntry),
@@ -287,6 +609,59 @@ func TestConfigActivationHelpers(t *testing.T) {
 	})
 }
 
+func TestConfigActivationMatching(t *testing.T) {
+	dir := t.TempDir()
+	projectDir := filepath.Join(dir, "project")
+	if err := os.Mkdir(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(projectDir, "ribbin.jsonc")
+	if err := os.WriteFile(configPath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	linkDir := filepath.Join(dir, "link")
+	if err := os.Symlink(projectDir, linkDir); err != nil {
+		t.Fatal(err)
+	}
+	otherPath := filepath.Join(dir, "other", "ribbin.jsonc")
+
+	newTestRegistry := func() *Registry {
+		return &Registry{ConfigActivations: make(map[string]ConfigActivationEntry)}
+	}
+
+	t.Run("activation via symlinked path matches real path", func(t *testing.T) {
+		registry := newTestRegistry()
+		registry.AddConfigActivation(filepath.Join(linkDir, "ribbin.jsonc"))
+
+		if !registry.IsConfigActive(configPath) {
+			t.Error("config should be active via its real path")
+		}
+		if registry.IsConfigActive(otherPath) {
+			t.Error("a different project's config should not be active")
+		}
+	})
+
+	t.Run("deactivation via real path removes symlinked activation", func(t *testing.T) {
+		registry := newTestRegistry()
+		registry.AddConfigActivation(filepath.Join(linkDir, "ribbin.jsonc"))
+		registry.RemoveConfigActivation(configPath)
+
+		if registry.IsConfigActive(filepath.Join(linkDir, "ribbin.jsonc")) {
+			t.Error("config should no longer be active")
+		}
+	})
+
+	t.Run("legacy non-canonical entries still match", func(t *testing.T) {
+		registry := newTestRegistry()
+		legacyPath := filepath.Join(linkDir, "ribbin.jsonc")
+		registry.ConfigActivations[legacyPath] = ConfigActivationEntry{}
+
+		if !registry.IsConfigActive(legacyPath) {
+			t.Error("legacy entry should match its literal path")
+		}
+	})
+}
+
 func TestShellActivationHelpers(t *testing.T) {
 	registry := &Registry{
 		Wrappers:          make(map[string]WrapperEntry),
@@ -326,6 +701,77 @@ func TestShellActivationHelpers(t *testing.T) {
 	})
 }
 
+func TestSnoozeHelpers(t *testing.T) {
+	registry := &Registry{}
+	now := time.Now()
+
+	t.Run("AddSnooze makes command snoozed", func(t *testing.T) {
+		registry.AddSnooze("npm", 30*time.Minute)
+
+		if _, ok := registry.ActiveSnooze("npm", now); !ok {
+			t.Error("npm should be snoozed")
+		}
+		if _, ok := registry.ActiveSnooze("yarn", now); ok {
+			t.Error("yarn should not be snoozed")
+		}
+	})
+
+	t.Run("snooze of all commands applies to every command", func(t *testing.T) {
+		registry.AddSnooze(SnoozeAllKey, time.Hour)
+
+		entry, ok := registry.ActiveSnooze("yarn", now)
+		if !ok {
+			t.Fatal("yarn should be snoozed via all")
+		}
+		// npm has both; the later expiry wins
+		npmEntry, _ := registry.ActiveSnooze("npm", now)
+		if !npmEntry.Until.Equal(entry.Until) {
+			t.Errorf("expected npm to use the later all-commands expiry")
+		}
+		registry.RemoveSnooze(SnoozeAllKey)
+	})
+
+	t.Run("expired snoozes do not apply and are pruned", func(t *testing.T) {
+		later := now.Add(31 * time.Minute)
+		if _, ok := registry.ActiveSnooze("npm", later); ok {
+			t.Error("npm snooze should have expired")
+		}
+		registry.PruneExpiredSnoozes(later)
+		if len(registry.Snoozes) != 0 {
+			t.Errorf("expected expired snoozes to be pruned, got %v", registry.Snoozes)
+		}
+	})
+
+	t.Run("ClearSnoozes clears all", func(t *testing.T) {
+		registry.AddSnooze("a", time.Minute)
+		registry.AddSnooze("b", time.Minute)
+		registry.ClearSnoozes()
+		if _, ok := registry.ActiveSnooze("a", now); ok {
+			t.Error("snoozes should be cleared")
+		}
+	})
+}
+
+func TestSnoozeEntryRemaining(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{-time.Second, "expired"},
+		{45 * time.Second, "45s"},
+		{25 * time.Minute, "25m"},
+		{2 * time.Hour, "2h"},
+		{90 * time.Minute, "1h30m"},
+	}
+	for _, tt := range tests {
+		entry := SnoozeEntry{Until: now.Add(tt.d)}
+		if got := entry.Remaining(now); got != tt.want {
+			t.Errorf("Remaining(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
 func TestProcessExists(t *testing.T) {
 	t.Run("returns true for current process", func(t *testing.T) {
 		if !processExists(os.Getpid()) {
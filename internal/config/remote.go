@@ -0,0 +1,273 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// Remote extends references let a team share one policy across many repos:
+//
+//	"extends": ["https://example.com/ribbin/policy.jsonc#root.hardened"]
+//	"extends": ["github:acme/policies/ribbin.jsonc@v2"]
+//
+// Either form may be pinned to an exact file with a "?sha256=<hex>" suffix,
+// which ribbin strips before fetching. Fetched files are cached under
+// $XDG_CACHE_HOME/ribbin/extends so wrappers keep working offline.
+
+// remoteExtendsTTL is how long a cached unpinned remote config is used before
+// ribbin tries to fetch it again. Pinned configs never need refetching.
+const remoteExtendsTTL = time.Hour
+
+// remoteExtendsMaxSize caps the size of a fetched remote config.
+const remoteExtendsMaxSize = 1 << 20
+
+// githubRawBase is where github: references are fetched from
+const githubRawBase = "https://raw.githubusercontent.com"
+
+// remoteHTTPClient fetches remote configs. Tests replace it to talk to a local server.
+var remoteHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// sha256Pattern matches a hex-encoded SHA-256 checksum
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ErrChecksumMismatch is returned when an extended config, fetched or local,
+// doesn't match its pinned checksum
+var ErrChecksumMismatch = errors.New("extends config checksum mismatch")
+
+// isRemoteRef returns true if the reference points at a remote config.
+func isRemoteRef(ref string) bool {
+	return strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "github:")
+}
+
+// parseRemoteRef parses an https:// or github: extends reference.
+func parseRemoteRef(ref string) (*ExtendsRef, error) {
+	location, fragment := splitFileAndFragment(ref)
+
+	location, checksum, err := splitChecksum(location)
+	if err != nil {
+		return nil, err
+	}
+
+	var fetchURL string
+	if strings.HasPrefix(location, "github:") {
+		fetchURL, err = githubRawURL(strings.TrimPrefix(location, "github:"))
+	} else {
+		fetchURL, err = validateRemoteURL(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExtendsRef{
+		URL:      fetchURL,
+		Checksum: checksum,
+		Fragment: fragment,
+	}, nil
+}
+
+// splitChecksum removes a "sha256=<hex>" query parameter from a remote location
+// and returns it separately. Other query parameters are kept.
+func splitChecksum(location string) (rest, checksum string, err error) {
+	idx := strings.Index(location, "?")
+	if idx == -1 {
+		return location, "", nil
+	}
+
+	query, err := url.ParseQuery(location[idx+1:])
+	if err != nil {
+		return "", "", fmt.Errorf("invalid query: %w", err)
+	}
+	checksum = strings.ToLower(query.Get("sha256"))
+	if checksum == "" {
+		return location, "", nil
+	}
+	if !sha256Pattern.MatchString(checksum) {
+		return "", "", fmt.Errorf("sha256 must be 64 hex characters, got %q", checksum)
+	}
+
+	query.Del("sha256")
+	rest = location[:idx]
+	if len(query) > 0 {
+		rest += "?" + query.Encode()
+	}
+	return rest, checksum, nil
+}
+
+// validateRemoteURL checks that a remote config URL is a usable https URL.
+func validateRemoteURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("remote config must use https, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("remote config URL has no host")
+	}
+	if u.Path == "" || strings.HasSuffix(u.Path, "/") {
+		return "", fmt.Errorf("remote config URL must point at a file")
+	}
+	return u.String(), nil
+}
+
+// githubRawURL converts "org/repo/path/to/file.jsonc@ref" to a raw.githubusercontent.com URL.
+// Without "@ref", the repository's default branch (HEAD) is used.
+func githubRawURL(spec string) (string, error) {
+	gitRef := "HEAD"
+	if idx := strings.LastIndex(spec, "@"); idx != -1 {
+		gitRef = spec[idx+1:]
+		spec = spec[:idx]
+		if gitRef == "" {
+			return "", fmt.Errorf("empty git ref after '@'")
+		}
+	}
+
+	parts := strings.SplitN(spec, "/", 3)
+	if len(parts) < 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", fmt.Errorf("github reference must be github:org/repo/path/to/file, got %q", "github:"+spec)
+	}
+	if strings.Contains(parts[2], "..") {
+		return "", fmt.Errorf("path traversal in github reference: %q", parts[2])
+	}
+
+	return fmt.Sprintf("%s/%s/%s/%s/%s", githubRawBase, parts[0], parts[1], gitRef, parts[2]), nil
+}
+
+// remoteCachePath returns where the remote config at fetchURL is cached. The
+// file keeps the URL's extension so DetectFormat picks the right parser.
+func remoteCachePath(fetchURL string) (string, error) {
+	cacheDir, err := security.GetCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(fetchURL))
+	name := hex.EncodeToString(sum[:8])
+	if u, err := url.Parse(fetchURL); err == nil {
+		if base := path.Base(u.Path); base != "." && base != "/" {
+			name += "-" + base
+		}
+	}
+	return filepath.Join(cacheDir, "extends", name), nil
+}
+
+// FetchRemoteConfig returns a local path holding the remote config for ref,
+// fetching it if needed:
+//   - A pinned ref uses the cached copy whenever it matches the checksum.
+//   - An unpinned ref uses the cached copy for remoteExtendsTTL, then refetches.
+//   - If fetching fails (offline, server down) the cached copy is used with a
+//     warning. RIBBIN_OFFLINE=1 skips fetching entirely.
+//
+// Content that doesn't match a pinned checksum is never cached or used.
+func FetchRemoteConfig(ref *ExtendsRef) (string, error) {
+	cachePath, err := remoteCachePath(ref.URL)
+	if err != nil {
+		return "", fmt.Errorf("cannot locate extends cache: %w", err)
+	}
+
+	cached, cacheInfo, cacheErr := readCachedRemote(cachePath, ref.Checksum)
+	if cacheErr == nil {
+		fresh := ref.Checksum != "" || time.Since(cacheInfo.ModTime()) < remoteExtendsTTL
+		if fresh || os.Getenv("RIBBIN_OFFLINE") == "1" {
+			return cachePath, nil
+		}
+	} else if os.Getenv("RIBBIN_OFFLINE") == "1" {
+		return "", fmt.Errorf("%s is not cached and RIBBIN_OFFLINE=1 is set", ref.URL)
+	}
+
+	data, fetchErr := fetchRemote(ref.URL)
+	if fetchErr == nil && ref.Checksum != "" {
+		if got := sha256Hex(data); got != ref.Checksum {
+			return "", fmt.Errorf("%w for %s: expected sha256 %s, got %s", ErrChecksumMismatch, ref.URL, ref.Checksum, got)
+		}
+	}
+	if fetchErr != nil {
+		if cached != nil {
+			fmt.Fprintf(os.Stderr, "ribbin: could not refresh %s (%v), using cached copy from %s\n",
+				ref.URL, fetchErr, cacheInfo.ModTime().Format(time.RFC3339))
+			return cachePath, nil
+		}
+		return "", fmt.Errorf("cannot fetch %s: %w", ref.URL, fetchErr)
+	}
+
+	if err := writeCachedRemote(cachePath, data); err != nil {
+		return "", fmt.Errorf("cannot cache %s: %w", ref.URL, err)
+	}
+	return cachePath, nil
+}
+
+// readCachedRemote reads a cached remote config, rejecting it if it doesn't
+// match the pinned checksum (when there is one).
+func readCachedRemote(cachePath, checksum string) ([]byte, os.FileInfo, error) {
+	info, err := os.Stat(cachePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if checksum != "" && sha256Hex(data) != checksum {
+		return nil, nil, ErrChecksumMismatch
+	}
+	return data, info, nil
+}
+
+// fetchRemote downloads a remote config.
+func fetchRemote(fetchURL string) ([]byte, error) {
+	resp, err := remoteHTTPClient.Get(fetchURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, remoteExtendsMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > remoteExtendsMaxSize {
+		return nil, fmt.Errorf("remote config exceeds %d bytes", remoteExtendsMaxSize)
+	}
+	return data, nil
+}
+
+// writeCachedRemote stores fetched content via a temp file and rename, so a
+// concurrent reader never sees a partial file.
+func writeCachedRemote(cachePath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0700); err != nil {
+		return err
+	}
+	tmpPath := cachePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, cachePath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// sha256Hex returns the hex-encoded SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+const remotePolicy = `{
+  "wrappers": {
+    "npm": { "action": "block", "message": "from remote" }
+  },
+  "scopes": {
+    "strict": {
+      "wrappers": {
+        "curl": { "action": "block", "message": "strict remote" }
+      }
+    }
+  }
+}
+`
+
+var remotePolicySHA256 = sha256Hex([]byte(remotePolicy))
+
+// setupRemoteServer serves remotePolicy over TLS, points the remote client at
+// it and isolates the extends cache. It returns the server and a hit counter.
+func setupRemoteServer(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+	var hits int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path != "/policy.jsonc" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(remotePolicy))
+	}))
+	t.Cleanup(server.Close)
+
+	origClient := remoteHTTPClient
+	remoteHTTPClient = server.Client()
+	t.Cleanup(func() { remoteHTTPClient = origClient })
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("RIBBIN_OFFLINE", "")
+	return server, &hits
+}
+
+func TestParseExtendsRef_RemoteReferences(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name         string
+		ref          string
+		wantURL      string
+		wantChecksum string
+		wantFragment string
+	}{
+		{
+			name:    "https URL",
+			ref:     "https://example.com/ribbin/policy.jsonc",
+			wantURL: "https://example.com/ribbin/policy.jsonc",
+		},
+		{
+			name:         "https URL with fragment",
+			ref:          "https://example.com/policy.jsonc#root.strict",
+			wantURL:      "https://example.com/policy.jsonc",
+			wantFragment: "root.strict",
+		},
+		{
+			name:         "pinned https URL",
+			ref:          "https://example.com/policy.jsonc?sha256=" + sum + "#root",
+			wantURL:      "https://example.com/policy.jsonc",
+			wantChecksum: sum,
+			wantFragment: "root",
+		},
+		{
+			name:         "pinned URL keeps other query parameters",
+			ref:          "https://example.com/policy.jsonc?token=x&sha256=" + sum,
+			wantURL:      "https://example.com/policy.jsonc?token=x",
+			wantChecksum: sum,
+		},
+		{
+			name:    "github with ref",
+			ref:     "github:acme/policies/ribbin/base.jsonc@v2",
+			wantURL: "https://raw.githubusercontent.com/acme/policies/v2/ribbin/base.jsonc",
+		},
+		{
+			name:         "github default branch with fragment",
+			ref:          "github:acme/policies/base.jsonc#root.strict",
+			wantURL:      "https://raw.githubusercontent.com/acme/policies/HEAD/base.jsonc",
+			wantFragment: "root.strict",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExtendsRef(tt.ref, "/project")
+			if err != nil {
+				t.Fatalf("ParseExtendsRef(%q) error = %v", tt.ref, err)
+			}
+			if !got.IsRemote() || got.IsLocal || got.FilePath != "" {
+				t.Errorf("expected an unfetched remote ref, got %+v", got)
+			}
+			if got.URL != tt.wantURL {
+				t.Errorf("URL = %q, want %q", got.URL, tt.wantURL)
+			}
+			if got.Checksum != tt.wantChecksum {
+				t.Errorf("Checksum = %q, want %q", got.Checksum, tt.wantChecksum)
+			}
+			if got.Fragment != tt.wantFragment {
+				t.Errorf("Fragment = %q, want %q", got.Fragment, tt.wantFragment)
+			}
+		})
+	}
+}
+
+func TestParseExtendsRef_RemoteErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     string
+		wantErr string
+	}{
+		{"plain http", "http://example.com/policy.jsonc", "must use https"},
+		{"directory URL", "https://example.com/policies/", "must point at a file"},
+		{"short checksum", "https://example.com/policy.jsonc?sha256=abc", "64 hex characters"},
+		{"github without path", "github:acme/policies", "github:org/repo/path"},
+		{"github empty ref", "github:acme/policies/base.jsonc@", "empty git ref"},
+		{"github traversal", "github:acme/policies/../secrets.jsonc", "path traversal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExtendsRef(tt.ref, "/project")
+			if err == nil {
+				t.Fatalf("ParseExtendsRef(%q) expected error", tt.ref)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchRemoteConfig(t *testing.T) {
+	t.Run("fetches once and then uses the cache", func(t *testing.T) {
+		server, hits := setupRemoteServer(t)
+		ref := &ExtendsRef{URL: server.URL + "/policy.jsonc"}
+
+		path, err := FetchRemoteConfig(ref)
+		if err != nil {
+			t.Fatalf("FetchRemoteConfig error = %v", err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != remotePolicy {
+			t.Errorf("cached content = %q", data)
+		}
+		if !strings.HasSuffix(path, "policy.jsonc") {
+			t.Errorf("cache path %q should keep the file extension", path)
+		}
+
+		if _, err := FetchRemoteConfig(ref); err != nil {
+			t.Fatalf("second FetchRemoteConfig error = %v", err)
+		}
+		if got := atomic.LoadInt32(hits); got != 1 {
+			t.Errorf("server hit %d times, want 1", got)
+		}
+	})
+
+	t.Run("refetches a stale cache", func(t *testing.T) {
+		server, hits := setupRemoteServer(t)
+		ref := &ExtendsRef{URL: server.URL + "/policy.jsonc"}
+
+		path, err := FetchRemoteConfig(ref)
+		if err != nil {
+			t.Fatalf("FetchRemoteConfig error = %v", err)
+		}
+		old := time.Now().Add(-2 * remoteExtendsTTL)
+		os.Chtimes(path, old, old)
+
+		if _, err := FetchRemoteConfig(ref); err != nil {
+			t.Fatalf("FetchRemoteConfig error = %v", err)
+		}
+		if got := atomic.LoadInt32(hits); got != 2 {
+			t.Errorf("server hit %d times, want 2", got)
+		}
+	})
+
+	t.Run("falls back to stale cache when offline", func(t *testing.T) {
+		server, _ := setupRemoteServer(t)
+		ref := &ExtendsRef{URL: server.URL + "/policy.jsonc"}
+
+		path, err := FetchRemoteConfig(ref)
+		if err != nil {
+			t.Fatalf("FetchRemoteConfig error = %v", err)
+		}
+		old := time.Now().Add(-2 * remoteExtendsTTL)
+		os.Chtimes(path, old, old)
+		server.Close()
+
+		got, err := FetchRemoteConfig(ref)
+		if err != nil {
+			t.Fatalf("expected fallback to cache, got error = %v", err)
+		}
+		if got != path {
+			t.Errorf("path = %q, want cached %q", got, path)
+		}
+	})
+
+	t.Run("fails without a cache when unreachable", func(t *testing.T) {
+		server, _ := setupRemoteServer(t)
+		ref := &ExtendsRef{URL: server.URL + "/missing.jsonc"}
+
+		if _, err := FetchRemoteConfig(ref); err == nil {
+			t.Error("expected error for missing remote config")
+		}
+	})
+
+	t.Run("RIBBIN_OFFLINE never fetches", func(t *testing.T) {
+		server, hits := setupRemoteServer(t)
+		t.Setenv("RIBBIN_OFFLINE", "1")
+		ref := &ExtendsRef{URL: server.URL + "/policy.jsonc"}
+
+		if _, err := FetchRemoteConfig(ref); err == nil {
+			t.Error("expected error for uncached config with RIBBIN_OFFLINE=1")
+		}
+		if got := atomic.LoadInt32(hits); got != 0 {
+			t.Errorf("server hit %d times, want 0", got)
+		}
+	})
+
+	t.Run("pinned checksum must match", func(t *testing.T) {
+		server, _ := setupRemoteServer(t)
+
+		good := &ExtendsRef{URL: server.URL + "/policy.jsonc", Checksum: remotePolicySHA256}
+		if _, err := FetchRemoteConfig(good); err != nil {
+			t.Errorf("matching checksum: unexpected error = %v", err)
+		}
+
+		bad := &ExtendsRef{URL: server.URL + "/policy.jsonc", Checksum: strings.Repeat("0", 64)}
+		_, err := FetchRemoteConfig(bad)
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("mismatched checksum: error = %v, want ErrChecksumMismatch", err)
+		}
+	})
+}
+
+func TestResolveEffectiveShims_RemoteExtends(t *testing.T) {
+	server, _ := setupRemoteServer(t)
+
+	config := &ProjectConfig{
+		Scopes: map[string]ScopeConfig{
+			"app": {
+				Path:    "app",
+				Extends: []string{server.URL + "/policy.jsonc?sha256=" + remotePolicySHA256 + "#root.strict"},
+				Wrappers: map[string]ShimConfig{
+					"yarn": {Action: "block", Message: "local"},
+				},
+			},
+		},
+	}
+
+	scope := config.Scopes["app"]
+	result, err := NewResolver().ResolveEffectiveShims(config, "/project/ribbin.jsonc", &scope)
+	if err != nil {
+		t.Fatalf("ResolveEffectiveShims error = %v", err)
+	}
+	if result["curl"].Message != "strict remote" {
+		t.Errorf("curl should come from the remote scope, got %q", result["curl"].Message)
+	}
+	if result["yarn"].Message != "local" {
+		t.Errorf("yarn should come from the local scope, got %q", result["yarn"].Message)
+	}
+	if _, ok := result["npm"]; ok {
+		t.Error("npm should not be inherited (only root.strict was extended)")
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
 // ErrCyclicExtends is returned when a cycle is detected in extends references
@@ -30,15 +32,104 @@ type ResolvedShim struct {
 }
 
 // Resolver resolves effective shim configurations by processing extends inheritance.
+//
+// The external configs it loads, for extends and parent configs, are cached
+// for the resolver's lifetime, but each is checked against the size and
+// modification time of its file, and of the files it imports, whenever it is
+// used again. A long-lived resolver, as in 'ribbin watch', so reads a config
+// again once it is edited. Invalidate drops cached configs outright, for
+// callers that know of an edit a stat could miss.
 type Resolver struct {
 	// cache stores loaded external config files by their absolute path
-	cache map[string]*ProjectConfig
+	cache map[string]*cachedConfig
+	// remote is set once any remote extends reference has been resolved
+	remote bool
+	// onConflict is the conflict policy of the config being resolved
+	onConflict string
 }
 
 // NewResolver creates a new Resolver instance.
 func NewResolver() *Resolver {
 	return &Resolver{
-		cache: make(map[string]*ProjectConfig),
+		cache: make(map[string]*cachedConfig),
+	}
+}
+
+// cachedConfig is a config the resolver has loaded, with the stamps of the
+// files it was read from
+type cachedConfig struct {
+	config *ProjectConfig
+	stamps []fileStamp
+}
+
+// fileStamp identifies a version of a file. A file that couldn't be read
+// has a Size of -1, which no file matches.
+type fileStamp struct {
+	Path    string
+	ModTime time.Time
+	Size    int64
+}
+
+func stampFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{Path: path, Size: -1}
+	}
+	return fileStamp{Path: path, ModTime: info.ModTime(), Size: info.Size()}
+}
+
+// current returns true if the file still matches the stamp
+func (s fileStamp) current() bool {
+	now := stampFile(s.Path)
+	return now.Size == s.Size && now.ModTime.Equal(s.ModTime)
+}
+
+// cached returns the config cached for path, if its files haven't changed
+// since it was loaded. A stale entry is dropped.
+func (r *Resolver) cached(path string) (*ProjectConfig, bool) {
+	entry, ok := r.cache[path]
+	if !ok {
+		return nil, false
+	}
+	for _, stamp := range entry.stamps {
+		if !stamp.current() {
+			delete(r.cache, path)
+			return nil, false
+		}
+	}
+	return entry.config, true
+}
+
+// store caches config as loaded from path. The files are stamped after the
+// config was read, so an edit made in between is only picked up by a later
+// edit or Invalidate.
+func (r *Resolver) store(path string, config *ProjectConfig) {
+	entry := &cachedConfig{config: config, stamps: []fileStamp{stampFile(path)}}
+	for _, imported := range append(config.ImportedFiles(), config.WorkspaceFiles()...) {
+		entry.stamps = append(entry.stamps, stampFile(imported))
+	}
+	r.cache[path] = entry
+}
+
+// Invalidate drops the cached configs read from any of paths, including
+// configs that import one of them, so they are loaded again when next used.
+// With no paths, it drops every cached config.
+func (r *Resolver) Invalidate(paths ...string) {
+	if len(paths) == 0 {
+		clear(r.cache)
+		return
+	}
+	drop := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		drop[filepath.Clean(path)] = true
+	}
+	for path, entry := range r.cache {
+		for _, stamp := range entry.stamps {
+			if drop[stamp.Path] {
+				delete(r.cache, path)
+				break
+			}
+		}
 	}
 }
 
@@ -56,10 +147,36 @@ func (r *Resolver) ResolveEffectiveShims(
 	configPath string,
 	scope *ScopeConfig,
 ) (map[string]ShimConfig, error) {
+	defer r.governedBy(config)()
 	visited := make(map[string]bool)
 	return r.resolveEffectiveShimsInternal(config, configPath, scope, visited)
 }
 
+// ResolveAllScopes resolves the root wrappers and every scope's extends chain,
+// returning all resolution errors joined together. Scopes are checked in sorted
+// order so the error output is stable.
+func (r *Resolver) ResolveAllScopes(config *ProjectConfig, configPath string) error {
+	if _, err := r.ResolveEffectiveShims(config, configPath, nil); err != nil {
+		return fmt.Errorf("root: %w", err)
+	}
+
+	scopeNames := make([]string, 0, len(config.Scopes))
+	for name := range config.Scopes {
+		scopeNames = append(scopeNames, name)
+	}
+	sort.Strings(scopeNames)
+
+	var errs []error
+	for _, name := range scopeNames {
+		scope := config.Scopes[name]
+		if _, err := r.ResolveEffectiveShims(config, configPath, &scope); err != nil {
+			errs = append(errs, fmt.Errorf("scope %q: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // resolveEffectiveShimsInternal is the recursive implementation with cycle detection.
 func (r *Resolver) resolveEffectiveShimsInternal(
 	config *ProjectConfig,
@@ -96,14 +213,24 @@ func (r *Resolver) resolveEffectiveShimsInternal(
 		}
 
 		// Merge inherited shims (later overrides earlier)
-		for name, shim := range inherited {
-			result[name] = shim
+		if err := r.mergeShims(result, inherited, "extends "+extRef); err != nil {
+			return nil, err
 		}
 	}
 
 	// Merge scope's own wrappers (overrides all extends)
-	for name, shim := range scope.Wrappers {
-		result[name] = shim
+	if err := r.mergeShims(result, scope.Wrappers, "the scope's own wrappers in "+configPath); err != nil {
+		return nil, err
+	}
+
+	// The scope's tags limit which wrappers are in effect in it, and its env
+	// applies to every one that is
+	for name, shim := range result {
+		if !shim.InScope(scope.Tags) {
+			delete(result, name)
+			continue
+		}
+		result[name] = withScopeEnv(shim, scope.Env)
 	}
 
 	return result, nil
@@ -149,10 +276,9 @@ func (r *Resolver) resolveExternalRef(
 	ref *ExtendsRef,
 	visited map[string]bool,
 ) (map[string]ShimConfig, error) {
-	// Load the external config (with caching)
-	extConfig, err := r.loadExternalConfig(ref.FilePath)
+	extConfig, err := r.loadExtendsRef(ref)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load external config %q: %w", ref.FilePath, err)
+		return nil, err
 	}
 
 	if ref.Fragment == "" {
@@ -184,17 +310,91 @@ func (r *Resolver) resolveEntireFile(
 		if err != nil {
 			return nil, err
 		}
-		for name, shim := range scopeShims {
-			result[name] = shim
+		if err := r.mergeShims(result, scopeShims, "a scope of "+configPath); err != nil {
+			return nil, err
 		}
 	}
 
 	return result, nil
 }
 
-// loadExternalConfig loads a config file, using the cache if available.
+// mergeShims merges the shims of a later source, described by from, into
+// result under the resolver's conflict policy
+func (r *Resolver) mergeShims(result, later map[string]ShimConfig, from string) error {
+	for _, name := range sortedKeys(later) {
+		shim := later[name]
+		if existing, ok := result[name]; ok {
+			wins, err := laterWins(r.onConflict, name, existing, shim, func() (string, string) {
+				return "an earlier source", from
+			})
+			if err != nil {
+				return err
+			}
+			if !wins {
+				continue
+			}
+		}
+		result[name] = shim
+	}
+	return nil
+}
+
+// LoadedFiles returns the paths of the config files the resolver has loaded
+// so far, and the files they import, sorted. The config passed in to resolve
+// is not included.
+func (r *Resolver) LoadedFiles() []string {
+	files := make(map[string]bool)
+	for path, entry := range r.cache {
+		files[path] = true
+		for _, imported := range entry.config.ImportedFiles() {
+			files[imported] = true
+		}
+	}
+	return sortedKeys(files)
+}
+
+// UsedRemote returns true if the resolver has resolved a remote extends
+// reference. Remote configs can change without any local file changing.
+func (r *Resolver) UsedRemote() bool {
+	return r.remote
+}
+
+// fetchRemote fetches a remote config (see FetchRemoteConfig) and records that
+// the resolution depends on one.
+func (r *Resolver) fetchRemote(ref *ExtendsRef) (string, error) {
+	r.remote = true
+	return FetchRemoteConfig(ref)
+}
+
+// loadExtendsRef loads the external config an extends reference points at,
+// fetching a remote config into the local cache first. A pinned local file
+// is read and checked each time rather than taken from the cache.
+func (r *Resolver) loadExtendsRef(ref *ExtendsRef) (*ProjectConfig, error) {
+	if ref.IsRemote() {
+		path, err := r.fetchRemote(ref)
+		if err != nil {
+			return nil, err
+		}
+		ref.FilePath = path
+	} else if ref.Checksum != "" {
+		config, err := loadPinnedExtendsConfig(ref.FilePath, ref.Checksum)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load external config %q: %w", ref.FilePath, err)
+		}
+		r.store(ref.FilePath, config)
+		return config, nil
+	}
+
+	extConfig, err := r.loadExternalConfig(ref.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load external config %q: %w", ref.FilePath, err)
+	}
+	return extConfig, nil
+}
+
+// loadExternalConfig loads a config file, using the cache if it is current.
 func (r *Resolver) loadExternalConfig(path string) (*ProjectConfig, error) {
-	if config, ok := r.cache[path]; ok {
+	if config, ok := r.cached(path); ok {
 		return config, nil
 	}
 
@@ -203,7 +403,7 @@ func (r *Resolver) loadExternalConfig(path string) (*ProjectConfig, error) {
 		return nil, err
 	}
 
-	r.cache[path] = config
+	r.store(path, config)
 	return config, nil
 }
 
@@ -268,6 +468,7 @@ func (r *Resolver) ResolveEffectiveShimsWithProvenance(
 	scope *ScopeConfig,
 	scopeName string,
 ) (map[string]ResolvedShim, error) {
+	defer r.governedBy(config)()
 	visited := make(map[string]bool)
 	return r.resolveWithProvenanceInternal(config, configPath, scope, scopeName, visited)
 }
@@ -294,10 +495,7 @@ func (r *Resolver) resolveWithProvenanceInternal(
 		for name, shim := range config.Wrappers {
 			result[name] = ResolvedShim{
 				Config: shim,
-				Source: ShimSource{
-					FilePath: configPath,
-					Fragment: "root",
-				},
+				Source: config.WrapperSource(name, configPath),
 			}
 		}
 		return result, nil
@@ -321,30 +519,35 @@ func (r *Resolver) resolveWithProvenanceInternal(
 		}
 
 		// Merge inherited shims (later overrides earlier, tracking what was overridden)
-		for name, resolved := range inherited {
-			if existing, ok := result[name]; ok {
-				// Track what we're overriding
-				existingSource := existing.Source
-				resolved.Source.Overrode = &existingSource
-			}
-			result[name] = resolved
+		if err := r.mergeResolved(result, inherited); err != nil {
+			return nil, err
 		}
 	}
 
 	// Merge scope's own wrappers (overrides all extends)
+	own := make(map[string]ResolvedShim, len(scope.Wrappers))
 	for name, shim := range scope.Wrappers {
-		newResolved := ResolvedShim{
+		own[name] = ResolvedShim{
 			Config: shim,
 			Source: ShimSource{
 				FilePath: configPath,
 				Fragment: fragment,
 			},
 		}
-		if existing, ok := result[name]; ok {
-			existingSource := existing.Source
-			newResolved.Source.Overrode = &existingSource
+	}
+	if err := r.mergeResolved(result, own); err != nil {
+		return nil, err
+	}
+
+	// The scope's tags limit which wrappers are in effect in it, and its env
+	// applies to every one that is
+	for name, resolved := range result {
+		if !resolved.Config.InScope(scope.Tags) {
+			delete(result, name)
+			continue
 		}
-		result[name] = newResolved
+		resolved.Config = withScopeEnv(resolved.Config, scope.Env)
+		result[name] = resolved
 	}
 
 	return result, nil
@@ -371,10 +574,7 @@ func (r *Resolver) resolveLocalRefWithProvenance(
 		for name, shim := range config.Wrappers {
 			result[name] = ResolvedShim{
 				Config: shim,
-				Source: ShimSource{
-					FilePath: configPath,
-					Fragment: "root",
-				},
+				Source: config.WrapperSource(name, configPath),
 			}
 		}
 		return result, nil
@@ -396,10 +596,9 @@ func (r *Resolver) resolveExternalRefWithProvenance(
 	ref *ExtendsRef,
 	visited map[string]bool,
 ) (map[string]ResolvedShim, error) {
-	// Load the external config (with caching)
-	extConfig, err := r.loadExternalConfig(ref.FilePath)
+	extConfig, err := r.loadExtendsRef(ref)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load external config %q: %w", ref.FilePath, err)
+		return nil, err
 	}
 
 	if ref.Fragment == "" {
@@ -423,10 +622,7 @@ func (r *Resolver) resolveEntireFileWithProvenance(
 	for name, shim := range config.Wrappers {
 		result[name] = ResolvedShim{
 			Config: shim,
-			Source: ShimSource{
-				FilePath: configPath,
-				Fragment: "root",
-			},
+			Source: config.WrapperSource(name, configPath),
 		}
 	}
 
@@ -437,28 +633,149 @@ func (r *Resolver) resolveEntireFileWithProvenance(
 		if err != nil {
 			return nil, err
 		}
-		for name, resolved := range scopeShims {
-			if existing, ok := result[name]; ok {
-				existingSource := existing.Source
-				resolved.Source.Overrode = &existingSource
-			}
-			result[name] = resolved
+		if err := r.mergeResolved(result, scopeShims); err != nil {
+			return nil, err
 		}
 	}
 
 	return result, nil
 }
 
+// mergeResolved merges the shims of a later source into result under the
+// resolver's conflict policy. Each winner records the definition it
+// replaced in Overrode; a definition that keeps its place over a later one
+// gets that one appended to its Overrode chain.
+func (r *Resolver) mergeResolved(result, later map[string]ResolvedShim) error {
+	for _, name := range sortedKeys(later) {
+		resolved := later[name]
+		existing, ok := result[name]
+		if !ok {
+			result[name] = resolved
+			continue
+		}
+		wins, err := laterWins(r.onConflict, name, existing.Config, resolved.Config, func() (string, string) {
+			return sourceLabel(existing.Source), sourceLabel(resolved.Source)
+		})
+		if err != nil {
+			return err
+		}
+		if wins {
+			existingSource := existing.Source
+			resolved.Source.Overrode = &existingSource
+			result[name] = resolved
+		} else {
+			existing.Source = withOverrode(existing.Source, resolved.Source)
+			result[name] = existing
+		}
+	}
+	return nil
+}
+
+// ResolveForCwdWithProvenance resolves the effective shims for cwd under the
+// config at configPath, using the scope matching cwd. If the config sets
+// "root": false, the shims of its parent config (resolved the same way) are
+// merged in first and the config's own shims override them, with Overrode
+// recording what each one replaced.
+//
+// Returns the scope matched in config itself, or nil for root shims.
+func (r *Resolver) ResolveForCwdWithProvenance(
+	config *ProjectConfig,
+	configPath string,
+	cwd string,
+) (*MatchedScope, map[string]ResolvedShim, error) {
+	defer r.governedBy(config)()
+	matchedScope := FindMatchingScope(config, filepath.Dir(configPath), cwd)
+	var scope *ScopeConfig
+	var scopeName string
+	if matchedScope != nil {
+		scope = &matchedScope.Config
+		scopeName = matchedScope.Name
+	}
+
+	shims, err := r.ResolveEffectiveShimsWithProvenance(config, configPath, scope, scopeName)
+	if err != nil || config.IsRoot() {
+		return matchedScope, shims, err
+	}
+
+	parentPath, err := FindParentConfig(configPath)
+	if err != nil {
+		return matchedScope, nil, err
+	}
+	if parentPath == "" {
+		return matchedScope, shims, nil
+	}
+	parentConfig, err := r.loadExternalConfig(parentPath)
+	if err != nil {
+		return matchedScope, nil, fmt.Errorf("failed to load parent config %q: %w", parentPath, err)
+	}
+	_, inherited, err := r.ResolveForCwdWithProvenance(parentConfig, parentPath, cwd)
+	if err != nil {
+		return matchedScope, nil, fmt.Errorf("parent config %q: %w", parentPath, err)
+	}
+
+	// Merge own shims over inherited ones
+	for _, name := range sortedKeys(shims) {
+		resolved := shims[name]
+		existing, ok := inherited[name]
+		if !ok {
+			inherited[name] = resolved
+			continue
+		}
+		wins, err := laterWins(config.OnConflict, name, existing.Config, resolved.Config, func() (string, string) {
+			return sourceLabel(existing.Source), sourceLabel(resolved.Source)
+		})
+		if err != nil {
+			return matchedScope, nil, err
+		}
+		if wins {
+			resolved.Source = withOverrode(resolved.Source, existing.Source)
+			inherited[name] = resolved
+		} else {
+			existing.Source = withOverrode(existing.Source, resolved.Source)
+			inherited[name] = existing
+		}
+	}
+	return matchedScope, inherited, nil
+}
+
+// withOverrode returns a copy of source with overridden appended to the end
+// of its Overrode chain.
+func withOverrode(source, overridden ShimSource) ShimSource {
+	if source.Overrode == nil {
+		source.Overrode = &overridden
+		return source
+	}
+	rest := withOverrode(*source.Overrode, overridden)
+	source.Overrode = &rest
+	return source
+}
+
 // GetEffectiveConfigForCwd returns the effective shim configuration for the current working directory.
 // It finds the nearest config file, determines the matching scope, and resolves all shims with provenance.
+// The user config's wrappers are merged beneath them; where no project config
+// is found, configPath is the user config's.
 func GetEffectiveConfigForCwd() (configPath string, matchedScope *MatchedScope, shims map[string]ResolvedShim, err error) {
 	// Find the config file
 	configPath, err = FindProjectConfig()
 	if err != nil {
 		return "", nil, nil, err
 	}
+	userPath, err := FindUserConfig()
+	if err != nil {
+		return configPath, nil, nil, err
+	}
+	resolver := NewResolver()
+	var userShims map[string]ResolvedShim
+	if userPath != "" && userPath != configPath {
+		if userShims, err = resolver.ResolveUserWrappers(userPath); err != nil {
+			return configPath, nil, nil, err
+		}
+	}
 	if configPath == "" {
-		return "", nil, nil, nil // No config found, not an error
+		if userPath == "" {
+			return "", nil, nil, nil // No config found, not an error
+		}
+		return userPath, nil, userShims, nil
 	}
 
 	// Load the config
@@ -473,23 +790,12 @@ func GetEffectiveConfigForCwd() (configPath string, matchedScope *MatchedScope,
 		return configPath, nil, nil, err
 	}
 
-	// Find matching scope
-	configDir := filepath.Dir(configPath)
-	matchedScope = FindMatchingScope(config, configDir, cwd)
-
-	// Resolve effective shims with provenance
-	resolver := NewResolver()
-	var scope *ScopeConfig
-	var scopeName string
-	if matchedScope != nil {
-		scope = &matchedScope.Config
-		scopeName = matchedScope.Name
-	}
-
-	shims, err = resolver.ResolveEffectiveShimsWithProvenance(config, configPath, scope, scopeName)
+	// Resolve effective shims with provenance, merging parent configs if asked
+	matchedScope, shims, err = resolver.ResolveForCwdWithProvenance(config, configPath, cwd)
 	if err != nil {
 		return configPath, matchedScope, nil, err
 	}
 
-	return configPath, matchedScope, shims, nil
+	shims, err = MergeUserWrappers(userShims, shims, config.OnConflict)
+	return configPath, matchedScope, shims, err
 }
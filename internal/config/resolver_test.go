@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/happycollision/ribbin/internal/testsafety"
 )
@@ -92,6 +93,87 @@ func TestResolveEffectiveShims_ExtendsRoot(t *testing.T) {
 	}
 }
 
+func TestResolveEffectiveShims_ScopeEnv(t *testing.T) {
+	// Scope env applies to every wrapper in the scope, inherited ones too;
+	// a wrapper's own env wins, and the shared config maps are not modified
+	config := &ProjectConfig{
+		Wrappers: map[string]ShimConfig{
+			"git": {Action: "passthrough"},
+		},
+		Scopes: map[string]ScopeConfig{
+			"ci": {
+				Path:    "ci",
+				Extends: []string{"root"},
+				Env:     map[string]string{"GIT_PAGER": "cat", "CI": "1"},
+				Wrappers: map[string]ShimConfig{
+					"npm": {Action: "passthrough", Env: map[string]string{"CI": "true", "NPM_CONFIG_FUND": "false"}},
+				},
+			},
+		},
+	}
+
+	scope := config.Scopes["ci"]
+	result, err := NewResolver().ResolveEffectiveShims(config, "/project/ribbin.jsonc", &scope)
+	if err != nil {
+		t.Fatalf("ResolveEffectiveShims error = %v", err)
+	}
+
+	if got := result["git"].Env; got["GIT_PAGER"] != "cat" || got["CI"] != "1" {
+		t.Errorf("git env = %v, want the scope env", got)
+	}
+	if got := result["npm"].Env; got["CI"] != "true" || got["NPM_CONFIG_FUND"] != "false" || got["GIT_PAGER"] != "cat" {
+		t.Errorf("npm env = %v, want its own env over the scope env", got)
+	}
+	if config.Wrappers["git"].Env != nil || len(config.Scopes["ci"].Wrappers["npm"].Env) != 2 {
+		t.Error("resolving should not modify the config")
+	}
+
+	provenance, err := NewResolver().ResolveEffectiveShimsWithProvenance(config, "/project/ribbin.jsonc", &scope, "ci")
+	if err != nil {
+		t.Fatalf("ResolveEffectiveShimsWithProvenance error = %v", err)
+	}
+	if got := provenance["git"].Config.Env; got["GIT_PAGER"] != "cat" {
+		t.Errorf("git env with provenance = %v, want the scope env", got)
+	}
+}
+
+func TestResolveEffectiveShims_ScopeTags(t *testing.T) {
+	// Only wrappers carrying one of the scope's tags, inherited or not, are in effect
+	config := &ProjectConfig{
+		Wrappers: map[string]ShimConfig{
+			"rm":  {Action: "redirect", Redirect: "./safe-rm.sh", Tags: []string{"safety"}},
+			"npm": {Action: "block", Tags: []string{"migration"}},
+		},
+		Scopes: map[string]ScopeConfig{
+			"legacy": {
+				Path:    "legacy",
+				Extends: []string{"root"},
+				Tags:    []string{"safety"},
+				Wrappers: map[string]ShimConfig{
+					"curl": {Action: "warn"},
+				},
+			},
+		},
+	}
+
+	scope := config.Scopes["legacy"]
+	result, err := NewResolver().ResolveEffectiveShims(config, "/project/ribbin.jsonc", &scope)
+	if err != nil {
+		t.Fatalf("ResolveEffectiveShims error = %v", err)
+	}
+	if _, ok := result["rm"]; !ok || len(result) != 1 {
+		t.Errorf("result = %v, want only rm", result)
+	}
+
+	provenance, err := NewResolver().ResolveEffectiveShimsWithProvenance(config, "/project/ribbin.jsonc", &scope, "legacy")
+	if err != nil {
+		t.Fatalf("ResolveEffectiveShimsWithProvenance error = %v", err)
+	}
+	if _, ok := provenance["rm"]; !ok || len(provenance) != 1 {
+		t.Errorf("result with provenance = %v, want only rm", provenance)
+	}
+}
+
 func TestResolveEffectiveShims_MultipleExtends(t *testing.T) {
 	// extends = ["root", "root.hardened"] - order matters, later wins
 	config := &ProjectConfig{
@@ -457,6 +539,93 @@ func TestResolver_ConfigCaching(t *testing.T) {
 	}
 }
 
+func TestResolver_ReloadsEditedConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	externalPath := filepath.Join(tmpDir, "shared-base.jsonc")
+	importedPath := filepath.Join(tmpDir, "policy.jsonc")
+	mainPath := filepath.Join(tmpDir, "ribbin.jsonc")
+
+	// write replaces a file and moves its mtime on, as an edit a second
+	// later would, however coarse the filesystem's timestamps
+	var tick time.Duration
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		tick += time.Second
+		when := time.Now().Add(tick)
+		if err := os.Chtimes(path, when, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+	message := func(resolver *Resolver, name string) string {
+		t.Helper()
+		scope := ScopeConfig{Extends: []string{"./shared-base.jsonc"}}
+		shims, err := resolver.ResolveEffectiveShims(&ProjectConfig{}, mainPath, &scope)
+		if err != nil {
+			t.Fatalf("ResolveEffectiveShims error = %v", err)
+		}
+		return shims[name].Message
+	}
+
+	write(importedPath, `{"wrappers": {"imp": {"action": "block", "message": "v1"}}}`)
+	write(externalPath, `{"imports": ["./policy.jsonc"], "wrappers": {"ext": {"action": "block", "message": "v1"}}}`)
+	resolver := NewResolver()
+	if got := message(resolver, "ext"); got != "v1" {
+		t.Fatalf("ext message = %q, want v1", got)
+	}
+
+	t.Run("edited file is read again", func(t *testing.T) {
+		write(externalPath, `{"imports": ["./policy.jsonc"], "wrappers": {"ext": {"action": "block", "message": "v2"}}}`)
+		if got := message(resolver, "ext"); got != "v2" {
+			t.Errorf("ext message after edit = %q, want v2", got)
+		}
+	})
+
+	t.Run("edited import is read again", func(t *testing.T) {
+		write(importedPath, `{"wrappers": {"imp": {"action": "block", "message": "v2"}}}`)
+		if got := message(resolver, "imp"); got != "v2" {
+			t.Errorf("imp message after edit = %q, want v2", got)
+		}
+	})
+
+	t.Run("unchanged file stays cached", func(t *testing.T) {
+		cached := resolver.cache[externalPath]
+		message(resolver, "ext")
+		if resolver.cache[externalPath] != cached {
+			t.Error("unchanged config was loaded again")
+		}
+	})
+
+	t.Run("Invalidate drops configs importing a path", func(t *testing.T) {
+		resolver.Invalidate(filepath.Join(tmpDir, "other.jsonc"))
+		if len(resolver.cache) != 1 {
+			t.Fatalf("Invalidate of an unrelated path dropped %v", resolver.LoadedFiles())
+		}
+		resolver.Invalidate(importedPath)
+		if len(resolver.cache) != 0 {
+			t.Errorf("expected the importing config to be dropped, have %v", resolver.LoadedFiles())
+		}
+		message(resolver, "ext")
+		resolver.Invalidate()
+		if len(resolver.cache) != 0 {
+			t.Errorf("Invalidate() left %v", resolver.LoadedFiles())
+		}
+	})
+
+	t.Run("deleted file is an error", func(t *testing.T) {
+		message(resolver, "ext")
+		if err := os.Remove(externalPath); err != nil {
+			t.Fatal(err)
+		}
+		scope := ScopeConfig{Extends: []string{"./shared-base.jsonc"}}
+		if _, err := resolver.ResolveEffectiveShims(&ProjectConfig{}, mainPath, &scope); err == nil {
+			t.Error("expected an error once the extended file is gone")
+		}
+	})
+}
+
 // Tests for provenance tracking
 
 func TestResolveEffectiveShimsWithProvenance_RootOnly(t *testing.T) {
@@ -793,3 +962,137 @@ func TestFindMatchingScope_EmptyPath(t *testing.T) {
 		t.Errorf("match name = %q, want %q", match.Name, "global")
 	}
 }
+
+func TestResolveAllScopes_Valid(t *testing.T) {
+	config := &ProjectConfig{
+		Wrappers: map[string]ShimConfig{
+			"cat": {Action: "block"},
+		},
+		Scopes: map[string]ScopeConfig{
+			"hardened": {
+				Wrappers: map[string]ShimConfig{
+					"rm": {Action: "block"},
+				},
+			},
+			"frontend": {
+				Path:    "apps/frontend",
+				Extends: []string{"root", "root.hardened"},
+			},
+		},
+	}
+
+	resolver := NewResolver()
+	if err := resolver.ResolveAllScopes(config, "/project/ribbin.jsonc"); err != nil {
+		t.Errorf("ResolveAllScopes error = %v", err)
+	}
+}
+
+func TestResolveAllScopes_ReportsEveryBrokenScope(t *testing.T) {
+	config := &ProjectConfig{
+		Scopes: map[string]ScopeConfig{
+			"backend": {
+				Path:    "apps/backend",
+				Extends: []string{"root.typo"},
+			},
+			"frontend": {
+				Path:    "apps/frontend",
+				Extends: []string{"./missing.jsonc"},
+			},
+			"ok": {
+				Extends: []string{"root"},
+			},
+		},
+	}
+
+	resolver := NewResolver()
+	err := resolver.ResolveAllScopes(config, "/nonexistent/project/ribbin.jsonc")
+	if err == nil {
+		t.Fatal("expected error for broken scopes")
+	}
+
+	msg := err.Error()
+	if !strings.Contains(msg, `scope "backend"`) || !strings.Contains(msg, `scope "typo" not found`) {
+		t.Errorf("error should mention missing fragment in backend scope, got: %v", err)
+	}
+	if !strings.Contains(msg, `scope "frontend"`) {
+		t.Errorf("error should mention frontend scope, got: %v", err)
+	}
+	if strings.Contains(msg, `scope "ok"`) {
+		t.Errorf("error should not mention valid scope, got: %v", err)
+	}
+}
+
+func TestResolveForCwdWithProvenance_MergesParentConfig(t *testing.T) {
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve symlinks: %v", err)
+	}
+
+	parentPath := filepath.Join(tmpDir, "ribbin.jsonc")
+	parentConfig := `{
+		"wrappers": {
+			"npm": {"action": "block", "message": "parent npm"},
+			"tsc": {"action": "block", "message": "parent tsc"}
+		},
+		"scopes": {
+			"app": {
+				"path": "app",
+				"extends": ["root"],
+				"wrappers": {"curl": {"action": "block", "message": "parent app curl"}}
+			}
+		}
+	}`
+	childDir := filepath.Join(tmpDir, "app")
+	childPath := filepath.Join(childDir, "ribbin.jsonc")
+	childConfig := `{
+		"root": false,
+		"wrappers": {"tsc": {"action": "redirect", "redirect": "./tsc.sh"}}
+	}`
+	if err := os.MkdirAll(childDir, 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(parentPath, []byte(parentConfig), 0644); err != nil {
+		t.Fatalf("failed to write parent config: %v", err)
+	}
+	if err := os.WriteFile(childPath, []byte(childConfig), 0644); err != nil {
+		t.Fatalf("failed to write child config: %v", err)
+	}
+
+	child, err := LoadProjectConfig(childPath)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig error: %v", err)
+	}
+
+	_, shims, err := NewResolver().ResolveForCwdWithProvenance(child, childPath, childDir)
+	if err != nil {
+		t.Fatalf("ResolveForCwdWithProvenance error: %v", err)
+	}
+
+	// Inherited from the parent's root wrappers, through the parent's matching scope
+	if shims["npm"].Source.FilePath != parentPath || shims["npm"].Config.Message != "parent npm" {
+		t.Errorf("npm = %+v, want parent root wrapper", shims["npm"])
+	}
+	if shims["curl"].Source.FilePath != parentPath || shims["curl"].Source.Fragment != "root.app" {
+		t.Errorf("curl source = %+v, want %s#root.app", shims["curl"].Source, parentPath)
+	}
+
+	// Child overrides parent, keeping what it overrode
+	tsc := shims["tsc"]
+	if tsc.Config.Action != "redirect" || tsc.Source.FilePath != childPath {
+		t.Errorf("tsc = %+v, want child redirect", tsc)
+	}
+	if tsc.Source.Overrode == nil || tsc.Source.Overrode.FilePath != parentPath {
+		t.Errorf("tsc.Source.Overrode = %+v, want parent source", tsc.Source.Overrode)
+	}
+
+	t.Run("root config ignores parent", func(t *testing.T) {
+		child.Root = nil
+		_, shims, err := NewResolver().ResolveForCwdWithProvenance(child, childPath, childDir)
+		if err != nil {
+			t.Fatalf("ResolveForCwdWithProvenance error: %v", err)
+		}
+		if len(shims) != 1 {
+			t.Errorf("expected only the child's own wrapper, got %v", shims)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embedded so timezones work where the system has no zoneinfo, as in
+	// slim containers
+	_ "time/tzdata"
+)
+
+// WhenConfig holds conditions on when a wrapper is in effect
+type WhenConfig struct {
+	// Schedule lists the times the wrapper is in effect, each like
+	// "mon-fri 09:00-17:00", "sat,sun" or "2026-12-20..2027-01-02" (see
+	// ParseScheduleEntry). At other times the original runs.
+	Schedule []string `json:"schedule,omitempty"`
+	// Timezone is the IANA zone, like "America/New_York", the schedule is
+	// in. The default is the local time zone.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// ScheduleEntry is one parsed entry of WhenConfig.Schedule. A part left out
+// of the entry matches any time.
+type ScheduleEntry struct {
+	// Days holds the weekdays it covers, indexed by time.Weekday
+	Days    [7]bool
+	HasDays bool
+	// From and To are the first and last date it covers, as YYYYMMDD
+	From, To int
+	// Start and End are minutes after midnight. An End at or before Start
+	// runs past midnight into the next day.
+	Start, End int
+	HasTime    bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseScheduleEntry parses a schedule entry: up to one each of
+//
+//	days    mon-fri, sat,sun, fri-mon (wrapping around the week)
+//	dates   2026-12-24, or 2026-12-20..2027-01-02 (inclusive)
+//	times   09:00-17:00, or 18:00-09:00 (running past midnight)
+//
+// separated by spaces. Times running past midnight belong to the day they
+// start on, so "fri 18:00-09:00" covers Friday night until 9 on Saturday.
+func ParseScheduleEntry(entry string) (ScheduleEntry, error) {
+	var e ScheduleEntry
+	fields := strings.Fields(strings.ToLower(entry))
+	if len(fields) == 0 {
+		return e, fmt.Errorf("empty schedule entry")
+	}
+	var hasDates bool
+	for _, field := range fields {
+		var err error
+		switch {
+		case strings.Contains(field, ":"):
+			if e.HasTime {
+				return e, fmt.Errorf("invalid schedule entry %q: more than one time range", entry)
+			}
+			e.Start, e.End, err = parseTimeRange(field)
+			e.HasTime = true
+		case field[0] >= '0' && field[0] <= '9':
+			if hasDates {
+				return e, fmt.Errorf("invalid schedule entry %q: more than one date range", entry)
+			}
+			e.From, e.To, err = parseDateRange(field)
+			hasDates = true
+		default:
+			if e.HasDays {
+				return e, fmt.Errorf("invalid schedule entry %q: more than one list of days", entry)
+			}
+			e.Days, err = parseDays(field)
+			e.HasDays = true
+		}
+		if err != nil {
+			return e, fmt.Errorf("invalid schedule entry %q: %v", entry, err)
+		}
+	}
+	return e, nil
+}
+
+// parseDays parses a comma-separated list of days and day ranges
+func parseDays(field string) ([7]bool, error) {
+	var days [7]bool
+	for _, item := range strings.Split(field, ",") {
+		first, last, isRange := strings.Cut(item, "-")
+		from, ok := weekdays[first]
+		if !ok {
+			return days, fmt.Errorf("unknown day %q: use mon, tue, wed, thu, fri, sat or sun", first)
+		}
+		to := from
+		if isRange {
+			if to, ok = weekdays[last]; !ok {
+				return days, fmt.Errorf("unknown day %q: use mon, tue, wed, thu, fri, sat or sun", last)
+			}
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseDateRange parses a date, or two dates separated by ".."
+func parseDateRange(field string) (int, int, error) {
+	first, last, isRange := strings.Cut(field, "..")
+	if !isRange {
+		last = first
+	}
+	from, err := dateKey(first)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := dateKey(last)
+	if err != nil {
+		return 0, 0, err
+	}
+	if to < from {
+		return 0, 0, fmt.Errorf("%s is before %s", last, first)
+	}
+	return from, to, nil
+}
+
+func dateKey(value string) (int, error) {
+	date, err := time.Parse(ExpiresLayout, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q: use YYYY-MM-DD", value)
+	}
+	return date.Year()*10000 + int(date.Month())*100 + date.Day(), nil
+}
+
+// parseTimeRange parses HH:MM-HH:MM into minutes after midnight; the end
+// may be 24:00
+func parseTimeRange(field string) (int, int, error) {
+	first, last, ok := strings.Cut(field, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid time range %q: use HH:MM-HH:MM", field)
+	}
+	start, err := clockMinutes(first, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := clockMinutes(last, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+func clockMinutes(value string, allowMidnight bool) (int, error) {
+	h, m, ok := strings.Cut(value, ":")
+	hour, herr := strconv.Atoi(h)
+	minute, merr := strconv.Atoi(m)
+	switch {
+	case !ok || herr != nil || merr != nil || len(m) != 2 || minute < 0 || minute > 59 || hour < 0:
+	case hour < 24, allowMidnight && hour == 24 && minute == 0:
+		return hour*60 + minute, nil
+	}
+	return 0, fmt.Errorf("invalid time %q: use HH:MM", value)
+}
+
+// Matches reports whether the entry covers t, in t's location
+func (e ScheduleEntry) Matches(t time.Time) bool {
+	minutes := t.Hour()*60 + t.Minute()
+	if !e.HasTime {
+		return e.coversDay(t)
+	}
+	if e.Start < e.End {
+		return e.coversDay(t) && minutes >= e.Start && minutes < e.End
+	}
+	// A range past midnight started today, or yesterday
+	return (e.coversDay(t) && minutes >= e.Start) ||
+		(e.coversDay(t.AddDate(0, 0, -1)) && minutes < e.End)
+}
+
+func (e ScheduleEntry) coversDay(t time.Time) bool {
+	if e.HasDays && !e.Days[t.Weekday()] {
+		return false
+	}
+	key := t.Year()*10000 + int(t.Month())*100 + t.Day()
+	return e.From == 0 || (key >= e.From && key <= e.To)
+}
+
+// Location returns the time zone the schedule is in
+func (w *WhenConfig) Location() (*time.Location, error) {
+	if w.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q: use an IANA name like \"America/New_York\"", w.Timezone)
+	}
+	return loc, nil
+}
+
+// Scheduled reports whether the wrapper is in effect at now, and the
+// schedule entry that puts it in effect. A wrapper without a schedule is
+// always in effect, and so is one whose schedule or timezone is invalid,
+// rather than letting a mistake lift a freeze.
+func (w WrapperConfig) Scheduled(now time.Time) (string, bool) {
+	if w.When == nil || len(w.When.Schedule) == 0 {
+		return "", true
+	}
+	loc, err := w.When.Location()
+	if err != nil {
+		return "", true
+	}
+	now = now.In(loc)
+	for _, entry := range w.When.Schedule {
+		parsed, err := ParseScheduleEntry(entry)
+		if err != nil {
+			return "", true
+		}
+		if parsed.Matches(now) {
+			return entry, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestParseScheduleEntry(t *testing.T) {
+	invalid := map[string]string{
+		"":                       "empty",
+		"someday":                "unknown day",
+		"mon-fri mon":            "more than one list of days",
+		"25:00-26:00":            "invalid time",
+		"09:00":                  "use HH:MM-HH:MM",
+		"9:0-17:00":              "invalid time",
+		"2027-01-02..2026-12-20": "is before",
+		"2026-13-01":             "invalid date",
+	}
+	for entry, want := range invalid {
+		if _, err := ParseScheduleEntry(entry); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseScheduleEntry(%q) error = %v, want one containing %q", entry, err, want)
+		}
+	}
+
+	entry, err := ParseScheduleEntry("fri-mon 9:30-24:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for day, want := range map[time.Weekday]bool{time.Friday: true, time.Sunday: true, time.Monday: true, time.Tuesday: false} {
+		if entry.Days[day] != want {
+			t.Errorf("Days[%s] = %v, want %v", day, entry.Days[day], want)
+		}
+	}
+	if entry.Start != 570 || entry.End != 1440 {
+		t.Errorf("Start, End = %d, %d, want 570, 1440", entry.Start, entry.End)
+	}
+}
+
+func TestScheduled(t *testing.T) {
+	// 2026-12-18 is a Friday
+	at := func(value string) time.Time {
+		t.Helper()
+		when, err := time.ParseInLocation("2006-01-02 15:04", value, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return when
+	}
+	tests := []struct {
+		name     string
+		when     *WhenConfig
+		now      string
+		want     bool
+		wantName string
+	}{
+		{"no schedule", nil, "2026-12-18 12:00", true, ""},
+		{"weekend", &WhenConfig{Schedule: []string{"sat,sun"}}, "2026-12-19 12:00", true, "sat,sun"},
+		{"weekday", &WhenConfig{Schedule: []string{"sat,sun"}}, "2026-12-18 12:00", false, ""},
+		{"business hours", &WhenConfig{Schedule: []string{"mon-fri 09:00-17:00"}}, "2026-12-18 16:59", true, "mon-fri 09:00-17:00"},
+		{"after hours", &WhenConfig{Schedule: []string{"mon-fri 09:00-17:00"}}, "2026-12-18 17:00", false, ""},
+		{"overnight, started the day before", &WhenConfig{Schedule: []string{"fri 18:00-09:00"}}, "2026-12-19 08:00", true, "fri 18:00-09:00"},
+		{"overnight, not started", &WhenConfig{Schedule: []string{"fri 18:00-09:00"}}, "2026-12-18 08:00", false, ""},
+		{"freeze", &WhenConfig{Schedule: []string{"mon-fri 09:00-17:00", "2026-12-20..2027-01-02"}}, "2027-01-02 23:59", true, "2026-12-20..2027-01-02"},
+		{"after the freeze", &WhenConfig{Schedule: []string{"2026-12-20..2027-01-02"}}, "2027-01-03 00:00", false, ""},
+		{"timezone", &WhenConfig{Schedule: []string{"09:00-17:00"}, Timezone: "America/New_York"}, "2026-12-18 15:00", true, "09:00-17:00"},
+		{"timezone, before hours there", &WhenConfig{Schedule: []string{"09:00-17:00"}, Timezone: "America/New_York"}, "2026-12-18 13:00", false, ""},
+		{"invalid entry always applies", &WhenConfig{Schedule: []string{"someday"}}, "2026-12-18 12:00", true, ""},
+		{"invalid timezone always applies", &WhenConfig{Schedule: []string{"sat"}, Timezone: "Mars/Olympus"}, "2026-12-18 12:00", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := WrapperConfig{Action: "block", When: tt.when}
+			name, got := w.Scheduled(at(tt.now))
+			if got != tt.want || name != tt.wantName {
+				t.Errorf("Scheduled(%s) = %q, %v, want %q, %v", tt.now, name, got, tt.wantName, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandSearchPath expands environment variables ($VAR or ${VAR}) and a
+// leading ~ in a "searchPaths" entry. A relative result is resolved against
+// baseDir, or is an error if baseDir is empty.
+func ExpandSearchPath(path, baseDir string) (string, error) {
+	expanded := os.ExpandEnv(path)
+	if expanded == "~" || strings.HasPrefix(expanded, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot expand %q: %w", path, err)
+		}
+		expanded = filepath.Join(home, expanded[1:])
+	}
+	if expanded == "" {
+		return "", fmt.Errorf("search path %q is empty once expanded", path)
+	}
+	if !filepath.IsAbs(expanded) {
+		if baseDir == "" {
+			return "", fmt.Errorf("search path %q must be absolute", path)
+		}
+		expanded = filepath.Join(baseDir, expanded)
+	}
+	return filepath.Clean(expanded), nil
+}
+
+// SearchPaths returns the directories that 'ribbin find', 'ribbin status'
+// and wrapper discovery search besides their usual places: the
+// "searchPaths" of the config at configPath, relative ones resolved against
+// its directory, followed by those of the user's settings. configPath may
+// be empty. Duplicates are dropped; directories that don't exist are kept,
+// since they may be created later.
+func SearchPaths(configPath string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(source string, entries []string, baseDir string) error {
+		for _, entry := range entries {
+			path, err := ExpandSearchPath(entry, baseDir)
+			if err != nil {
+				return fmt.Errorf("%s: %w", source, err)
+			}
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+		return nil
+	}
+
+	if configPath != "" {
+		projectConfig, err := LoadProjectConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(configPath, projectConfig.SearchPaths, filepath.Dir(configPath)); err != nil {
+			return nil, err
+		}
+	}
+
+	settings, err := LoadSettings()
+	if err != nil {
+		return nil, err
+	}
+	settingsPath, err := SettingsPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := add(settingsPath, settings.SearchPaths, ""); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
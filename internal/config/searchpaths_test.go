@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestExpandSearchPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TOOLS_DIR", "/srv/tools")
+
+	tests := []struct {
+		path    string
+		baseDir string
+		want    string
+		wantErr string
+	}{
+		{"/opt/bin/", "", "/opt/bin", ""},
+		{"~/.local/bin", "", filepath.Join(home, ".local", "bin"), ""},
+		{"~", "", home, ""},
+		{"$TOOLS_DIR/bin", "", "/srv/tools/bin", ""},
+		{"${TOOLS_DIR}/node/bin", "", "/srv/tools/node/bin", ""},
+		{"tools/bin", "/project", "/project/tools/bin", ""},
+		{"tools/bin", "", "", "must be absolute"},
+		{"$RIBBIN_UNSET_SEARCH_PATH", "", "", "empty once expanded"},
+	}
+	for _, tt := range tests {
+		got, err := ExpandSearchPath(tt.path, tt.baseDir)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ExpandSearchPath(%q) error = %v, want %q", tt.path, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ExpandSearchPath(%q, %q) = %q, %v, want %q", tt.path, tt.baseDir, got, err, tt.want)
+		}
+	}
+}
+
+func TestSearchPaths(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", t.TempDir())
+	projectDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configPath := filepath.Join(projectDir, "ribbin.jsonc")
+	os.WriteFile(configPath, []byte(`{
+  "searchPaths": ["tools/bin", "/srv/shared/bin"],
+  "wrappers": {}
+}`), 0644)
+
+	paths, err := SearchPaths(configPath)
+	if err != nil {
+		t.Fatalf("SearchPaths error: %v", err)
+	}
+	want := []string{filepath.Join(projectDir, "tools", "bin"), "/srv/shared/bin"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("SearchPaths = %v, want %v", paths, want)
+	}
+
+	settingsPath := filepath.Join(configHome, "ribbin", SettingsFileName)
+	os.MkdirAll(filepath.Dir(settingsPath), 0755)
+	os.WriteFile(settingsPath, []byte(`{"searchPaths": ["/srv/shared/bin", "/opt/team/bin"]}`), 0644)
+	paths, err = SearchPaths(configPath)
+	if err != nil {
+		t.Fatalf("SearchPaths error: %v", err)
+	}
+	want = append(want, "/opt/team/bin")
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("SearchPaths with settings = %v, want %v", paths, want)
+	}
+
+	t.Run("no config", func(t *testing.T) {
+		paths, err := SearchPaths("")
+		if err != nil {
+			t.Fatalf("SearchPaths error: %v", err)
+		}
+		if strings.Join(paths, ",") != "/srv/shared/bin,/opt/team/bin" {
+			t.Errorf("SearchPaths = %v", paths)
+		}
+	})
+
+	t.Run("relative settings path", func(t *testing.T) {
+		os.WriteFile(settingsPath, []byte(`{"searchPaths": ["team/bin"]}`), 0644)
+		if _, err := LoadSettings(); err == nil || !strings.Contains(err.Error(), "must be absolute") {
+			t.Errorf("expected a relative settings search path to be refused, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// SettingsFileName is the user-level settings file in ribbin's config
+// directory (~/.config/ribbin, or $XDG_CONFIG_HOME/ribbin). Unlike a project
+// config, it applies to every project the user works in.
+const SettingsFileName = "settings.jsonc"
+
+// Settings are the user-level settings from SettingsFileName
+type Settings struct {
+	// Metrics opts in to counting wrapper interceptions
+	Metrics *MetricsSettings `json:"metrics,omitempty"`
+	// SidecarLayout is where wrapping keeps originals (SidecarLayoutAdjacent
+	// or SidecarLayoutDirectory). Empty means adjacent.
+	SidecarLayout string `json:"sidecarLayout,omitempty"`
+	// ShimMode is how wrappers are made (ShimModeSymlink or ShimModeCopy).
+	// Empty means symlink.
+	ShimMode string `json:"shimMode,omitempty"`
+	// SearchPaths are more directories for 'ribbin find', 'ribbin status'
+	// and wrapper discovery to search in every project, with environment
+	// variables and ~ expanded. They must be absolute.
+	SearchPaths []string `json:"searchPaths,omitempty"`
+	// MaxRedirectDepth is how many redirects may lead to one another, as
+	// when a redirect script runs a wrapped command that redirects too,
+	// before a wrapper refuses to redirect again. 0 means
+	// DefaultMaxRedirectDepth.
+	MaxRedirectDepth int `json:"maxRedirectDepth,omitempty"`
+	// BlockMessageInterval is how many seconds a wrapper waits before
+	// showing a command's full block message again in the same terminal
+	// session, printing a one-line reminder in between. 0 shows the full
+	// message every time; unset means DefaultBlockMessageInterval.
+	BlockMessageInterval *int `json:"blockMessageInterval,omitempty"`
+	// SnapshotThreshold is how many binaries a wrap may touch in one
+	// directory before the directory is snapshotted first, for 'ribbin
+	// restore-snapshot'. 0 never snapshots; unset means
+	// DefaultSnapshotThreshold.
+	SnapshotThreshold *int `json:"snapshotThreshold,omitempty"`
+}
+
+// DefaultMaxRedirectDepth is the most redirects that may lead to one another
+// unless the settings or RIBBIN_MAX_DEPTH say otherwise. Chains on purpose
+// are rarely more than two or three long; a loop reaches it at once.
+const DefaultMaxRedirectDepth = 8
+
+// DefaultBlockMessageInterval is how many seconds pass before a command's
+// full block message is shown again in a terminal session, unless the
+// settings or RIBBIN_BLOCK_MESSAGE_INTERVAL say otherwise. A build script
+// retrying a blocked command gets one box, then one line per attempt.
+const DefaultBlockMessageInterval = 30
+
+// DefaultSnapshotThreshold is how many binaries a wrap may touch in one
+// directory without snapshotting it, unless the settings say otherwise.
+// Wrapping a few commands by hand stays quick; wrapping a node_modules/.bin
+// wholesale gets a way back.
+const DefaultSnapshotThreshold = 10
+
+// Shim modes
+const (
+	// ShimModeSymlink makes a wrapper a symlink to the ribbin binary
+	ShimModeSymlink = "symlink"
+	// ShimModeCopy makes a wrapper a copy of the ribbin binary, for
+	// filesystems without symlinks, like some network filesystems and
+	// Docker volume mounts. The wrapper's metadata records the copy.
+	ShimModeCopy = "copy"
+)
+
+// Sidecar layouts
+const (
+	// SidecarLayoutAdjacent keeps an original next to its wrapper, as
+	// <binary>.ribbin-original
+	SidecarLayoutAdjacent = "adjacent"
+	// SidecarLayoutDirectory keeps an original in a content-addressed
+	// directory beside its wrapper, .ribbin/sidecars/<hash>/<binary>, which
+	// the wrapper's metadata points at
+	SidecarLayoutDirectory = "directory"
+	// SidecarLayoutProject keeps the originals of binaries under a project
+	// config's directory in .ribbin/originals there, keyed by their path
+	// relative to it, so reinstalling node_modules doesn't lose them. Only a
+	// project config can choose it.
+	SidecarLayoutProject = "project"
+)
+
+// MetricsSettings configure the opt-in interception metrics:
+//
+//	"metrics": {
+//	  "enabled": true,
+//	  "statsd": "127.0.0.1:8125",
+//	  "otlp": "http://127.0.0.1:4318",
+//	  "prometheusTextfile": "/var/lib/node_exporter/textfile/ribbin.prom"
+//	}
+//
+// Counts are always kept locally once enabled ('ribbin metrics'); each
+// exporter is optional.
+type MetricsSettings struct {
+	// Enabled turns counting on. Nothing is counted or sent without it.
+	Enabled bool `json:"enabled"`
+	// Statsd is the host:port of a statsd daemon to send a counter to (UDP)
+	Statsd string `json:"statsd,omitempty"`
+	// OTLP is the base URL of an OTLP/HTTP collector (metrics are posted to
+	// its /v1/metrics unless the URL has a path)
+	OTLP string `json:"otlp,omitempty"`
+	// PrometheusTextfile is a file to keep in the Prometheus text format,
+	// for node_exporter's textfile collector
+	PrometheusTextfile string `json:"prometheusTextfile,omitempty"`
+}
+
+// SettingsPath returns the path of the user's settings file, in the active
+// profile's directory
+func SettingsPath() (string, error) {
+	profileDir, err := security.GetProfileDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(profileDir, SettingsFileName), nil
+}
+
+// LoadSettings reads the user's settings file. A missing file is the same
+// as an empty one.
+func LoadSettings() (*Settings, error) {
+	settingsPath, err := SettingsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(settingsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Settings{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+
+	standardJSON, err := StandardizeConfig(settingsPath, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", settingsPath, err)
+	}
+	var settings Settings
+	if err := json.Unmarshal(standardJSON, &settings); err != nil {
+		return nil, fmt.Errorf("%s: invalid JSON: %w", settingsPath, err)
+	}
+	if settings.Metrics != nil {
+		if err := settings.Metrics.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", settingsPath, err)
+		}
+	}
+	switch settings.SidecarLayout {
+	case "", SidecarLayoutAdjacent, SidecarLayoutDirectory:
+	default:
+		return nil, fmt.Errorf("%s: sidecarLayout must be %q or %q, got %q", settingsPath, SidecarLayoutAdjacent, SidecarLayoutDirectory, settings.SidecarLayout)
+	}
+	switch settings.ShimMode {
+	case "", ShimModeSymlink, ShimModeCopy:
+	default:
+		return nil, fmt.Errorf("%s: shimMode must be %q or %q, got %q", settingsPath, ShimModeSymlink, ShimModeCopy, settings.ShimMode)
+	}
+	if settings.MaxRedirectDepth < 0 {
+		return nil, fmt.Errorf("%s: maxRedirectDepth must be at least 1, got %d", settingsPath, settings.MaxRedirectDepth)
+	}
+	if settings.BlockMessageInterval != nil && *settings.BlockMessageInterval < 0 {
+		return nil, fmt.Errorf("%s: blockMessageInterval must be 0 or more seconds, got %d", settingsPath, *settings.BlockMessageInterval)
+	}
+	if settings.SnapshotThreshold != nil && *settings.SnapshotThreshold < 0 {
+		return nil, fmt.Errorf("%s: snapshotThreshold must be 0 or more, got %d", settingsPath, *settings.SnapshotThreshold)
+	}
+	for _, path := range settings.SearchPaths {
+		if _, err := ExpandSearchPath(path, ""); err != nil {
+			return nil, fmt.Errorf("%s: searchPaths: %w", settingsPath, err)
+		}
+	}
+	return &settings, nil
+}
+
+// MetricsEnabled reports whether the settings opt in to metrics
+func (s *Settings) MetricsEnabled() bool {
+	return s.Metrics != nil && s.Metrics.Enabled
+}
+
+// Validate checks the exporters' addresses
+func (m *MetricsSettings) Validate() error {
+	if m.Statsd != "" {
+		if _, _, err := net.SplitHostPort(m.Statsd); err != nil {
+			return fmt.Errorf("metrics.statsd must be host:port, got %q", m.Statsd)
+		}
+	}
+	if m.OTLP != "" {
+		u, err := url.Parse(m.OTLP)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("metrics.otlp must be an http:// or https:// URL, got %q", m.OTLP)
+		}
+	}
+	if m.PrometheusTextfile != "" && !filepath.IsAbs(m.PrometheusTextfile) {
+		return fmt.Errorf("metrics.prometheusTextfile must be an absolute path, got %q", m.PrometheusTextfile)
+	}
+	return nil
+}
+
+// OTLPMetricsURL returns the URL to post OTLP metrics to
+func (m *MetricsSettings) OTLPMetricsURL() string {
+	u, err := url.Parse(m.OTLP)
+	if err != nil {
+		return m.OTLP
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/metrics"
+	}
+	return u.String()
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestLoadSettings(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	settingsPath := filepath.Join(configHome, "ribbin", SettingsFileName)
+
+	settings, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings without a file: %v", err)
+	}
+	if settings.MetricsEnabled() {
+		t.Error("metrics should be off without a settings file")
+	}
+
+	os.MkdirAll(filepath.Dir(settingsPath), 0755)
+	os.WriteFile(settingsPath, []byte(`{
+  "metrics": {
+    "enabled": true,
+    "otlp": "http://127.0.0.1:4318", // local collector
+  }
+}`), 0644)
+	settings, err = LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings error: %v", err)
+	}
+	if !settings.MetricsEnabled() {
+		t.Error("metrics should be enabled")
+	}
+	if got := settings.Metrics.OTLPMetricsURL(); got != "http://127.0.0.1:4318/v1/metrics" {
+		t.Errorf("OTLPMetricsURL = %q", got)
+	}
+
+	os.WriteFile(settingsPath, []byte(`{"metrics": {"enabled": true, "statsd": "localhost"}}`), 0644)
+	if _, err := LoadSettings(); err == nil || !strings.Contains(err.Error(), "host:port") {
+		t.Errorf("expected a statsd address error, got %v", err)
+	}
+
+	os.WriteFile(settingsPath, []byte(`{"shimMode": "copy"}`), 0644)
+	if settings, err := LoadSettings(); err != nil || settings.ShimMode != ShimModeCopy {
+		t.Errorf("expected the copy shim mode, got %+v, %v", settings, err)
+	}
+	os.WriteFile(settingsPath, []byte(`{"shimMode": "hardlink"}`), 0644)
+	if _, err := LoadSettings(); err == nil || !strings.Contains(err.Error(), "shimMode") {
+		t.Errorf("expected a shimMode error, got %v", err)
+	}
+}
+
+func TestMetricsSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings MetricsSettings
+		wantErr  string
+	}{
+		{"all exporters", MetricsSettings{Statsd: "127.0.0.1:8125", OTLP: "https://otel.example.com", PrometheusTextfile: "/var/lib/prom/ribbin.prom"}, ""},
+		{"otlp with path", MetricsSettings{OTLP: "http://localhost:4318/custom/metrics"}, ""},
+		{"otlp not a url", MetricsSettings{OTLP: "localhost:4318"}, "http:// or https://"},
+		{"relative textfile", MetricsSettings{PrometheusTextfile: "ribbin.prom"}, "absolute path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
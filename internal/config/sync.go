@@ -0,0 +1,326 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// A synced policy lets security engineering push wrappers to every repo
+// without a PR to each one:
+//
+//	"sync": {
+//	  "url": "https://policies.example.com/ribbin/policy.jsonc",
+//	  "publicKey": "<base64 ed25519 public key>",
+//	  "interval": "1h"
+//	}
+//
+// 'ribbin sync' fetches the policy along with its detached signature (the
+// same location with ".sig" appended), verifies it against publicKey, and
+// stores it under $XDG_STATE_HOME/ribbin/sync. The stored policy's root
+// wrappers are merged in before the config's imports, so the config (and its
+// imports) can still override them.
+
+// defaultSyncInterval is how often 'ribbin sync --watch' syncs when the
+// config doesn't set an interval.
+const defaultSyncInterval = time.Hour
+
+// signatureSuffix is appended to a policy's location to find its signature
+const signatureSuffix = ".sig"
+
+// ErrBadSignature is returned when a policy's signature doesn't verify
+var ErrBadSignature = errors.New("policy signature does not verify")
+
+// SyncConfig describes where to sync an organization policy from. Exactly
+// one of URL or Git is set.
+type SyncConfig struct {
+	// URL is an https:// or github:org/repo/path@ref location of the policy
+	URL string `json:"url,omitempty"`
+	// Git is a git repository holding the policy at Path
+	Git string `json:"git,omitempty"`
+	// Path is the policy's path inside the Git repository
+	Path string `json:"path,omitempty"`
+	// Ref is the branch or tag of the Git repository (default: its HEAD)
+	Ref string `json:"ref,omitempty"`
+	// PublicKey is the base64 ed25519 key the policy must be signed with
+	PublicKey string `json:"publicKey"`
+	// Interval is how often 'ribbin sync --watch' syncs, e.g. "30m"
+	Interval string `json:"interval,omitempty"`
+}
+
+// Source describes where the policy comes from, for messages.
+func (s *SyncConfig) Source() string {
+	if s.Git == "" {
+		return s.URL
+	}
+	source := s.Git + " " + s.Path
+	if s.Ref != "" {
+		source += "@" + s.Ref
+	}
+	return source
+}
+
+// Validate checks that the sync settings are complete and well formed.
+func (s *SyncConfig) Validate() error {
+	switch {
+	case s.URL == "" && s.Git == "":
+		return fmt.Errorf("sync needs a url or a git repository")
+	case s.URL != "" && s.Git != "":
+		return fmt.Errorf("sync takes a url or a git repository, not both")
+	case s.URL != "":
+		if _, err := parseRemoteRef(s.URL); err != nil {
+			return fmt.Errorf("invalid sync url: %w", err)
+		}
+	default:
+		if err := validateRepoPath(s.Path); err != nil {
+			return err
+		}
+	}
+	if _, err := s.publicKey(); err != nil {
+		return err
+	}
+	if _, err := s.SyncInterval(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SyncInterval returns how often to sync, defaulting to an hour.
+func (s *SyncConfig) SyncInterval() (time.Duration, error) {
+	if s.Interval == "" {
+		return defaultSyncInterval, nil
+	}
+	interval, err := time.ParseDuration(s.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sync interval %q: %w", s.Interval, err)
+	}
+	if interval < time.Minute {
+		return 0, fmt.Errorf("sync interval must be at least 1m, got %s", s.Interval)
+	}
+	return interval, nil
+}
+
+// publicKey decodes PublicKey.
+func (s *SyncConfig) publicKey() (ed25519.PublicKey, error) {
+	if s.PublicKey == "" {
+		return nil, fmt.Errorf("sync needs a publicKey to verify the policy")
+	}
+	key, err := base64.StdEncoding.DecodeString(s.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("sync publicKey must be a base64 ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// validateRepoPath checks the policy path inside a git repository.
+func validateRepoPath(repoPath string) error {
+	if repoPath == "" {
+		return fmt.Errorf("sync from git needs the policy's path in the repository")
+	}
+	if path.IsAbs(repoPath) || strings.Contains(repoPath, "..") {
+		return fmt.Errorf("sync path must be relative to the repository, got %q", repoPath)
+	}
+	return nil
+}
+
+// SyncedPolicyPath returns where the policy synced for the config at
+// configPath is stored. The file keeps the policy's extension so
+// DetectFormat picks the right parser.
+func SyncedPolicyPath(configPath string, s *SyncConfig) (string, error) {
+	stateDir, err := security.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(s.Path)
+	if s.Git == "" {
+		name = path.Base(strings.SplitN(s.URL, "?", 2)[0])
+	}
+	sum := sha256.Sum256([]byte(ConfigActivationKey(configPath)))
+	return filepath.Join(stateDir, "sync", fmt.Sprintf("%x-%s", sum[:8], name)), nil
+}
+
+// FetchPolicy downloads the policy and its signature, and verifies the
+// signature. Nothing is returned unless it verifies.
+func FetchPolicy(s *SyncConfig) ([]byte, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	key, _ := s.publicKey()
+
+	var data, sig []byte
+	var err error
+	if s.Git != "" {
+		data, sig, err = fetchGitPolicy(s)
+	} else {
+		data, sig, err = fetchURLPolicy(s.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := VerifyPolicy(data, sig, key); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Source(), err)
+	}
+	return data, nil
+}
+
+// fetchURLPolicy downloads a policy and the signature next to it.
+func fetchURLPolicy(location string) (data, sig []byte, err error) {
+	ref, err := parseRemoteRef(location)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err = fetchRemote(ref.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot fetch %s: %w", ref.URL, err)
+	}
+	if ref.Checksum != "" {
+		if got := sha256Hex(data); got != ref.Checksum {
+			return nil, nil, fmt.Errorf("%w for %s: expected sha256 %s, got %s", ErrChecksumMismatch, ref.URL, ref.Checksum, got)
+		}
+	}
+	sig, err = fetchRemote(ref.URL + signatureSuffix)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot fetch signature %s: %w", ref.URL+signatureSuffix, err)
+	}
+	return data, sig, nil
+}
+
+// fetchGitPolicy shallow-clones the repository and reads the policy and its
+// signature out of it.
+func fetchGitPolicy(s *SyncConfig) (data, sig []byte, err error) {
+	tmpDir, err := os.MkdirTemp("", "ribbin-sync-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if s.Ref != "" {
+		args = append(args, "--branch", s.Ref)
+	}
+	args = append(args, "--", s.Git, tmpDir)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		return nil, nil, fmt.Errorf("cannot clone %s: %v: %s", s.Git, err, strings.TrimSpace(string(out)))
+	}
+
+	policyPath := filepath.Join(tmpDir, filepath.FromSlash(s.Path))
+	if data, err = readLimited(policyPath); err != nil {
+		return nil, nil, fmt.Errorf("cannot read %s from %s: %w", s.Path, s.Git, err)
+	}
+	if sig, err = readLimited(policyPath + signatureSuffix); err != nil {
+		return nil, nil, fmt.Errorf("cannot read signature %s from %s: %w", s.Path+signatureSuffix, s.Git, err)
+	}
+	return data, sig, nil
+}
+
+// readLimited reads a file no bigger than a remote config may be.
+func readLimited(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > remoteExtendsMaxSize {
+		return nil, fmt.Errorf("file exceeds %d bytes", remoteExtendsMaxSize)
+	}
+	return os.ReadFile(path)
+}
+
+// VerifyPolicy checks a base64 detached signature of data against key.
+func VerifyPolicy(data, sig []byte, key ed25519.PublicKey) error {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("%w: signature is not base64", ErrBadSignature)
+	}
+	if !ed25519.Verify(key, data, decoded) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// SignPolicy returns the base64 detached signature of data, as stored in a
+// policy's .sig file.
+func SignPolicy(data []byte, privateKey ed25519.PrivateKey) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, data)) + "\n")
+}
+
+// GenerateSigningKey returns a new key pair for signing policies, base64
+// encoded: the public key goes in "sync.publicKey", the private key stays
+// with whoever publishes the policy.
+func GenerateSigningKey() (publicKey, privateKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(pub), base64.StdEncoding.EncodeToString(priv), nil
+}
+
+// ParseSigningKey decodes a base64 private key from GenerateSigningKey.
+func ParseSigningKey(encoded string) (ed25519.PrivateKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("not a base64 ed25519 private key")
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// StoreSyncedPolicy saves a verified policy for the config at configPath.
+// The policy must parse as a config. Returns whether it differs from the
+// policy stored before.
+func StoreSyncedPolicy(configPath string, s *SyncConfig, data []byte) (changed bool, err error) {
+	policyPath, err := SyncedPolicyPath(configPath, s)
+	if err != nil {
+		return false, err
+	}
+	if _, err := parseConfigData(policyPath, data); err != nil {
+		return false, fmt.Errorf("synced policy is not a valid config: %w", err)
+	}
+
+	previous, readErr := os.ReadFile(policyPath)
+	changed = readErr != nil || string(previous) != string(data)
+	if err := writeCachedRemote(policyPath, data); err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// LastSynced returns when the policy for the config at configPath was last
+// synced, or the zero time if it never was.
+func LastSynced(configPath string, s *SyncConfig) time.Time {
+	policyPath, err := SyncedPolicyPath(configPath, s)
+	if err != nil {
+		return time.Time{}
+	}
+	info, err := os.Stat(policyPath)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// syncedPolicyImport returns the stored synced policy for the config at
+// configPath, if the config syncs one and it has been synced.
+func syncedPolicyImport(config *ProjectConfig, configPath string) (string, bool) {
+	if config.Sync == nil {
+		return "", false
+	}
+	policyPath, err := SyncedPolicyPath(configPath, config.Sync)
+	if err != nil {
+		return "", false
+	}
+	if _, err := os.Stat(policyPath); err != nil {
+		return "", false
+	}
+	return policyPath, true
+}
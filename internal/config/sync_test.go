@@ -0,0 +1,215 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+// setupSyncKey returns a config sync section for a fresh key pair, and the
+// private key to sign policies with.
+func setupSyncKey(t *testing.T) (*SyncConfig, string) {
+	t.Helper()
+	publicKey, privateKey, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey error: %v", err)
+	}
+	return &SyncConfig{PublicKey: publicKey}, privateKey
+}
+
+func signPolicy(t *testing.T, privateKey, policy string) []byte {
+	t.Helper()
+	key, err := ParseSigningKey(privateKey)
+	if err != nil {
+		t.Fatalf("ParseSigningKey error: %v", err)
+	}
+	return SignPolicy([]byte(policy), key)
+}
+
+func TestSyncConfigValidate(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	tests := []struct {
+		name    string
+		sync    SyncConfig
+		wantErr string
+	}{
+		{"url", SyncConfig{URL: "https://example.com/policy.jsonc", PublicKey: key}, ""},
+		{"github", SyncConfig{URL: "github:acme/policies/ribbin.jsonc@v2", PublicKey: key, Interval: "30m"}, ""},
+		{"git", SyncConfig{Git: "git@example.com:acme/policies.git", Path: "ribbin/policy.jsonc", PublicKey: key}, ""},
+		{"no source", SyncConfig{PublicKey: key}, "needs a url or a git repository"},
+		{"both sources", SyncConfig{URL: "https://example.com/p.jsonc", Git: "/repo", Path: "p.jsonc", PublicKey: key}, "not both"},
+		{"plain http", SyncConfig{URL: "http://example.com/policy.jsonc", PublicKey: key}, "must use https"},
+		{"git without path", SyncConfig{Git: "/repo", PublicKey: key}, "needs the policy's path"},
+		{"git path traversal", SyncConfig{Git: "/repo", Path: "../policy.jsonc", PublicKey: key}, "relative to the repository"},
+		{"no key", SyncConfig{URL: "https://example.com/policy.jsonc"}, "needs a publicKey"},
+		{"bad key", SyncConfig{URL: "https://example.com/policy.jsonc", PublicKey: "c2hvcnQ="}, "ed25519 public key"},
+		{"bad interval", SyncConfig{URL: "https://example.com/policy.jsonc", PublicKey: key, Interval: "often"}, "invalid sync interval"},
+		{"short interval", SyncConfig{URL: "https://example.com/policy.jsonc", PublicKey: key, Interval: "5s"}, "at least 1m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sync.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchPolicy(t *testing.T) {
+	settings, privateKey := setupSyncKey(t)
+	sig := signPolicy(t, privateKey, remotePolicy)
+
+	serveSig := sig
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/policy.jsonc":
+			w.Write([]byte(remotePolicy))
+		case "/policy.jsonc.sig":
+			if serveSig == nil {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(serveSig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	origClient := remoteHTTPClient
+	remoteHTTPClient = server.Client()
+	t.Cleanup(func() { remoteHTTPClient = origClient })
+
+	settings.URL = server.URL + "/policy.jsonc"
+
+	t.Run("signed policy is returned", func(t *testing.T) {
+		data, err := FetchPolicy(settings)
+		if err != nil {
+			t.Fatalf("FetchPolicy error: %v", err)
+		}
+		if string(data) != remotePolicy {
+			t.Errorf("got %q", data)
+		}
+	})
+
+	t.Run("signature from another key is rejected", func(t *testing.T) {
+		_, otherKey := setupSyncKey(t)
+		serveSig = signPolicy(t, otherKey, remotePolicy)
+		t.Cleanup(func() { serveSig = sig })
+		if _, err := FetchPolicy(settings); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("expected ErrBadSignature, got %v", err)
+		}
+	})
+
+	t.Run("missing signature is an error", func(t *testing.T) {
+		serveSig = nil
+		t.Cleanup(func() { serveSig = sig })
+		if _, err := FetchPolicy(settings); err == nil || !strings.Contains(err.Error(), "signature") {
+			t.Errorf("expected a signature error, got %v", err)
+		}
+	})
+}
+
+func TestFetchPolicyFromGit(t *testing.T) {
+	settings, privateKey := setupSyncKey(t)
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "--quiet")
+	if err := os.MkdirAll(filepath.Join(repo, "ribbin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(repo, "ribbin", "policy.jsonc"), []byte(remotePolicy), 0644)
+	os.WriteFile(filepath.Join(repo, "ribbin", "policy.jsonc.sig"), signPolicy(t, privateKey, remotePolicy), 0644)
+	git("add", ".")
+	git("-c", "user.email=test@example.com", "-c", "user.name=Test", "commit", "--quiet", "-m", "policy")
+
+	settings.Git = repo
+	settings.Path = "ribbin/policy.jsonc"
+	data, err := FetchPolicy(settings)
+	if err != nil {
+		t.Fatalf("FetchPolicy error: %v", err)
+	}
+	if string(data) != remotePolicy {
+		t.Errorf("got %q", data)
+	}
+}
+
+func TestSyncedPolicyMerged(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "ribbin.jsonc")
+	os.WriteFile(configPath, []byte(`{
+  "sync": { "url": "https://example.com/policy.jsonc", "publicKey": "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=" },
+  "wrappers": {
+    "curl": { "action": "warn", "message": "repo override" }
+  }
+}`), 0644)
+
+	// Before the first sync the config stands alone
+	cfg, err := LoadProjectConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig error: %v", err)
+	}
+	if _, ok := cfg.Wrappers["npm"]; ok {
+		t.Fatal("nothing should be merged before the first sync")
+	}
+
+	policy := `{"wrappers": {
+    "npm": { "action": "block", "message": "from policy" },
+    "curl": { "action": "block", "message": "from policy" }
+  }}`
+	changed, err := StoreSyncedPolicy(configPath, cfg.Sync, []byte(policy))
+	if err != nil || !changed {
+		t.Fatalf("StoreSyncedPolicy = %v, %v", changed, err)
+	}
+	if changed, _ := StoreSyncedPolicy(configPath, cfg.Sync, []byte(policy)); changed {
+		t.Error("storing the same policy again should report no change")
+	}
+	if LastSynced(configPath, cfg.Sync).IsZero() {
+		t.Error("LastSynced should be set after storing")
+	}
+
+	cfg, err = LoadProjectConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig error: %v", err)
+	}
+	if cfg.Wrappers["npm"].Message != "from policy" {
+		t.Errorf("policy wrapper should be merged, got %+v", cfg.Wrappers["npm"])
+	}
+	if cfg.Wrappers["curl"].Message != "repo override" {
+		t.Errorf("the config should override the policy, got %+v", cfg.Wrappers["curl"])
+	}
+
+	policyPath, _ := SyncedPolicyPath(configPath, cfg.Sync)
+	if files := cfg.ImportedFiles(); len(files) != 1 || files[0] != policyPath {
+		t.Errorf("ImportedFiles = %v, want the synced policy", files)
+	}
+	if source := cfg.WrapperSource("npm", configPath); source.FilePath != policyPath {
+		t.Errorf("npm should come from the synced policy, got %+v", source)
+	}
+
+	if _, err := StoreSyncedPolicy(configPath, cfg.Sync, []byte("{ not json")); err == nil {
+		t.Error("an unparseable policy should not be stored")
+	}
+}
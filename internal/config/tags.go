@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// tagPattern matches tags a wrapper may carry
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidateTag checks that tag can be used in "tags" and with --tag.
+func ValidateTag(tag string) error {
+	if !tagPattern.MatchString(tag) {
+		return fmt.Errorf("invalid tag %q: use letters, digits, '-', '_' and '.'", tag)
+	}
+	return nil
+}
+
+// HasAnyTag reports whether the wrapper carries at least one of tags.
+func (w WrapperConfig) HasAnyTag(tags []string) bool {
+	for _, tag := range tags {
+		for _, own := range w.Tags {
+			if own == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// InScope reports whether the wrapper is in effect in a scope with
+// scopeTags: scopes with tags only keep the wrappers carrying one of them.
+func (w WrapperConfig) InScope(scopeTags []string) bool {
+	return len(scopeTags) == 0 || w.HasAnyTag(scopeTags)
+}
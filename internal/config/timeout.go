@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A wrapper with a timeout stops the program it runs, the original or the
+// redirect target, once it has run that long, as for a runaway build:
+//
+//	"webpack": { "action": "passthrough", "timeout": "10m", "timeoutSignal": "INT" }
+//
+// The program runs as a child of the wrapper to be timed, whatever "exec"
+// says.
+
+// TimeoutSignals are the signals "timeoutSignal" may name
+var TimeoutSignals = []string{"TERM", "INT", "HUP", "QUIT", "KILL"}
+
+// DefaultTimeoutSignal stops a program that ran past its timeout when the
+// wrapper names no other
+const DefaultTimeoutSignal = "TERM"
+
+// DefaultTimeoutMessage is printed when a program is stopped for running
+// past its timeout, unless the wrapper has a "timeoutMessage"
+const DefaultTimeoutMessage = "ribbin: '{command}' ran longer than {timeout}, stopping it with {signal}"
+
+// ParseTimeout parses a wrapper's "timeout", a duration like "10m",
+// "90s" or "1h30m"
+func ParseTimeout(value string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q: use a duration like \"10m\", \"90s\" or \"1h30m\"", value)
+	}
+	return timeout, nil
+}
+
+// NormalizeSignalName returns a signal name without its SIG prefix, in
+// upper case, like "TERM" for "sigterm"
+func NormalizeSignalName(name string) string {
+	return strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG")
+}
+
+// ValidateTimeoutSignal checks that name, like "TERM" or "SIGINT", is one
+// of TimeoutSignals
+func ValidateTimeoutSignal(name string) error {
+	if !slices.Contains(TimeoutSignals, NormalizeSignalName(name)) {
+		return fmt.Errorf("invalid signal %q: use %s", name, strings.Join(TimeoutSignals, ", "))
+	}
+	return nil
+}
+
+// TimeoutSignalName returns the signal that stops the program once it
+// runs past the timeout, without its SIG prefix
+func (w WrapperConfig) TimeoutSignalName() string {
+	if w.TimeoutSignal == "" {
+		return DefaultTimeoutSignal
+	}
+	return NormalizeSignalName(w.TimeoutSignal)
+}
+
+// RenderTimeoutMessage returns the wrapper's timeoutMessage, or
+// DefaultTimeoutMessage, with its placeholders filled in: those of messages,
+// plus {timeout} and {signal}
+func (w WrapperConfig) RenderTimeoutMessage(vars MessageVars) string {
+	message := w.TimeoutMessage
+	if message == "" {
+		message = DefaultTimeoutMessage
+	}
+	message = strings.NewReplacer(
+		"{timeout}", w.Timeout,
+		"{signal}", "SIG"+w.TimeoutSignalName(),
+	).Replace(message)
+	return WrapperConfig{Message: message, DocsURL: w.DocsURL}.RenderMessage("", vars)
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestParseTimeout(t *testing.T) {
+	for value, want := range map[string]time.Duration{"10m": 10 * time.Minute, "90s": 90 * time.Second, "1h30m": 90 * time.Minute} {
+		if got, err := ParseTimeout(value); err != nil || got != want {
+			t.Errorf("ParseTimeout(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "10", "0s", "-5m", "ten minutes"} {
+		if _, err := ParseTimeout(value); err == nil {
+			t.Errorf("ParseTimeout(%q) should fail", value)
+		}
+	}
+}
+
+func TestRenderTimeoutMessage(t *testing.T) {
+	w := WrapperConfig{Timeout: "10m", TimeoutSignal: "sigint"}
+	vars := MessageVars{Command: "webpack", Args: []string{"--watch"}}
+	if got, want := w.RenderTimeoutMessage(vars), "ribbin: 'webpack' ran longer than 10m, stopping it with SIGINT"; got != want {
+		t.Errorf("default message = %q, want %q", got, want)
+	}
+
+	w.TimeoutMessage = "{command} {args} took over {timeout}; see {docsUrl}"
+	w.DocsURL = "https://example.com/builds"
+	if got, want := w.RenderTimeoutMessage(vars), "webpack --watch took over 10m; see https://example.com/builds"; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+}
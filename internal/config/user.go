@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// The user config is a personal config in ribbin's config directory
+// (~/.config/ribbin/ribbin.jsonc, or a .toml/.yaml variant) whose root
+// wrappers apply in every directory. They are merged beneath the wrappers of
+// the project config in effect there, so a project can override them, and
+// their provenance fragment is UserFragment. Where no project config is
+// found, the user config governs on its own.
+
+// UserFragment is the provenance fragment of wrappers from the user config
+const UserFragment = "user"
+
+// userConfigFileNames are the names the user config may have, in lookup
+// order. There are no local overrides: the file is already personal.
+var userConfigFileNames = []string{ConfigFileName, TOMLConfigFileName, YAMLConfigFileName}
+
+// UserConfigCandidates returns the paths the user config is looked for at,
+// in lookup order.
+func UserConfigCandidates() ([]string, error) {
+	configDir, err := security.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(userConfigFileNames))
+	for i, name := range userConfigFileNames {
+		paths[i] = filepath.Join(configDir, name)
+	}
+	return paths, nil
+}
+
+// UserConfigPath returns where a new user config is created
+func UserConfigPath() (string, error) {
+	paths, err := UserConfigCandidates()
+	if err != nil {
+		return "", err
+	}
+	return paths[0], nil
+}
+
+// FindUserConfig returns the path of the user config, or "" if there is none.
+func FindUserConfig() (string, error) {
+	paths, err := UserConfigCandidates()
+	if err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			if err := security.ValidateConfigPath(path); err != nil {
+				return "", fmt.Errorf("unsafe user config at %s: %w", path, err)
+			}
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// IsUserConfig reports whether path is one of the user config's locations
+func IsUserConfig(path string) bool {
+	paths, err := UserConfigCandidates()
+	if err != nil {
+		return false
+	}
+	for _, candidate := range paths {
+		if candidate == path {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveUserWrappers returns the root wrappers of the user config at
+// userPath, with their provenance. Wrappers written in the user config
+// itself are labeled UserFragment; imported ones keep their import's source.
+func (r *Resolver) ResolveUserWrappers(userPath string) (map[string]ResolvedShim, error) {
+	userConfig, err := r.loadExternalConfig(userPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user config %q: %w", userPath, err)
+	}
+	result := make(map[string]ResolvedShim, len(userConfig.Wrappers))
+	for name, shim := range userConfig.Wrappers {
+		source := userConfig.WrapperSource(name, userPath)
+		if source.FilePath == userPath {
+			source.Fragment = UserFragment
+		}
+		result[name] = ResolvedShim{Config: shim, Source: source}
+	}
+	return result, nil
+}
+
+// MergeUserWrappers returns the project wrappers merged over the user
+// wrappers under the project config's onConflict policy, with Overrode
+// recording each definition the winner replaced.
+func MergeUserWrappers(user, project map[string]ResolvedShim, onConflict string) (map[string]ResolvedShim, error) {
+	merged := make(map[string]ResolvedShim, len(user)+len(project))
+	for name, resolved := range user {
+		merged[name] = resolved
+	}
+	for _, name := range sortedKeys(project) {
+		resolved := project[name]
+		existing, ok := user[name]
+		if !ok {
+			merged[name] = resolved
+			continue
+		}
+		wins, err := laterWins(onConflict, name, existing.Config, resolved.Config, func() (string, string) {
+			return sourceLabel(existing.Source), sourceLabel(resolved.Source)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if wins {
+			resolved.Source = withOverrode(resolved.Source, existing.Source)
+			merged[name] = resolved
+		} else {
+			existing.Source = withOverrode(existing.Source, resolved.Source)
+			merged[name] = existing
+		}
+	}
+	return merged, nil
+}
+
+// MergeUserShims is MergeUserWrappers without provenance
+func MergeUserShims(user, project map[string]ShimConfig, onConflict string) (map[string]ShimConfig, error) {
+	merged := make(map[string]ShimConfig, len(user)+len(project))
+	for name, shim := range user {
+		merged[name] = shim
+	}
+	for _, name := range sortedKeys(project) {
+		shim := project[name]
+		if existing, ok := user[name]; ok {
+			wins, err := laterWins(onConflict, name, existing, shim, func() (string, string) {
+				return "the user config", "the project config"
+			})
+			if err != nil {
+				return nil, err
+			}
+			if !wins {
+				continue
+			}
+		}
+		merged[name] = shim
+	}
+	return merged, nil
+}
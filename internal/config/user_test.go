@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestUserConfig(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	userDir := filepath.Join(configHome, "ribbin")
+
+	if path, err := FindUserConfig(); err != nil || path != "" {
+		t.Fatalf("FindUserConfig without a user config = %q, %v", path, err)
+	}
+
+	os.MkdirAll(userDir, 0755)
+	userPath := filepath.Join(userDir, "ribbin.toml")
+	os.WriteFile(filepath.Join(userDir, "policy.jsonc"), []byte(`{"wrappers": {"curl": {"action": "block"}}}`), 0644)
+	os.WriteFile(userPath, []byte(`imports = ["./policy.jsonc"]
+
+[wrappers.sudo]
+action = "block"
+message = "no sudo"
+
+[wrappers.npm]
+action = "block"
+message = "user npm"
+`), 0644)
+
+	path, err := FindUserConfig()
+	if err != nil || path != userPath {
+		t.Fatalf("FindUserConfig = %q, %v, want %q", path, err, userPath)
+	}
+	if !IsUserConfig(userPath) || IsUserConfig(filepath.Join(t.TempDir(), "ribbin.toml")) {
+		t.Error("IsUserConfig should only match the user config's locations")
+	}
+
+	user, err := NewResolver().ResolveUserWrappers(userPath)
+	if err != nil {
+		t.Fatalf("ResolveUserWrappers error: %v", err)
+	}
+	if source := user["sudo"].Source; source.FilePath != userPath || source.Fragment != UserFragment {
+		t.Errorf("sudo source = %+v, want %s#%s", source, userPath, UserFragment)
+	}
+	if source := user["curl"].Source; source.FilePath != filepath.Join(userDir, "policy.jsonc") {
+		t.Errorf("imported curl should keep its import's source, got %+v", source)
+	}
+
+	project := map[string]ResolvedShim{
+		"npm": {Config: ShimConfig{Action: "redirect"}, Source: ShimSource{FilePath: "/project/ribbin.jsonc", Fragment: "root"}},
+	}
+	merged, err := MergeUserWrappers(user, project, "")
+	if err != nil {
+		t.Fatalf("MergeUserWrappers error: %v", err)
+	}
+	if len(merged) != 3 {
+		t.Errorf("expected 3 merged wrappers, got %v", merged)
+	}
+	npm := merged["npm"]
+	if npm.Config.Action != "redirect" {
+		t.Errorf("the project's npm should win, got %+v", npm.Config)
+	}
+	if npm.Source.Overrode == nil || npm.Source.Overrode.Fragment != UserFragment {
+		t.Errorf("npm should record overriding the user config, got %+v", npm.Source)
+	}
+}
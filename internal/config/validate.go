@@ -1,8 +1,13 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
 	_ "embed"
@@ -49,10 +54,46 @@ func ValidateAgainstSchema(jsoncContent []byte, mode ValidationMode) error {
 	return nil
 }
 
+// CheckStrictResolve enforces the strictResolve setting for a loaded config.
+// It validates the file against the strict schema (rejecting unknown keys) and
+// resolves every scope and extends chain, so misconfigurations surface when the
+// config is wrapped or activated rather than when a shim later runs.
+// Returns nil immediately if strictResolve is not enabled.
+func CheckStrictResolve(cfg *ProjectConfig, configPath string) error {
+	if !cfg.StrictResolve {
+		return nil
+	}
+
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	content, err = StandardizeConfig(configPath, content)
+	if err != nil {
+		return err
+	}
+	if err := ValidateAgainstSchema(content, ValidationStrict); err != nil {
+		return err
+	}
+
+	if err := NewResolver().ResolveAllScopes(cfg, configPath); err != nil {
+		return fmt.Errorf("extends resolution failed:\n%w", err)
+	}
+
+	return nil
+}
+
 // ValidateAgainstSchemaWithDetails returns both hard errors and warnings.
 // Hard errors are loose validation failures (schema violations).
 // Warnings are strict-only failures (unknown properties).
 func ValidateAgainstSchemaWithDetails(jsoncContent []byte) (errors []string, warnings []string) {
+	return validateSchemaWithDetails(jsoncContent, true)
+}
+
+// validateSchemaWithDetails implements ValidateAgainstSchemaWithDetails. When
+// withPositions is false, line/column positions are omitted from messages
+// (used for TOML/YAML configs, whose JSON form doesn't match the source).
+func validateSchemaWithDetails(jsoncContent []byte, withPositions bool) (errors []string, warnings []string) {
 	// Strip comments using hujson
 	standardized, err := hujson.Standardize(jsoncContent)
 	if err != nil {
@@ -65,6 +106,18 @@ func ValidateAgainstSchemaWithDetails(jsoncContent []byte) (errors []string, war
 		return []string{fmt.Sprintf("failed to parse JSON: %v", err)}, nil
 	}
 
+	// Parse again keeping offsets so errors can report line/column positions
+	ast, err := hujson.Parse(jsoncContent)
+	if err != nil {
+		return []string{fmt.Sprintf("failed to parse JSONC: %v", err)}, nil
+	}
+	locate := func(segments []string) string {
+		if !withPositions {
+			return ""
+		}
+		return locateJSONPointer(&ast, jsoncContent, segments)
+	}
+
 	// First, validate with loose schema (original)
 	looseSchema, err := jsonschema.UnmarshalJSON(strings.NewReader(string(LooseSchemaBytes)))
 	if err != nil {
@@ -85,7 +138,7 @@ func ValidateAgainstSchemaWithDetails(jsoncContent []byte) (errors []string, war
 	if looseErr != nil {
 		// Extract error details
 		if validationErr, ok := looseErr.(*jsonschema.ValidationError); ok {
-			errors = extractValidationErrors(validationErr)
+			errors = extractValidationErrors(validationErr, locate)
 		} else {
 			errors = []string{looseErr.Error()}
 		}
@@ -112,7 +165,7 @@ func ValidateAgainstSchemaWithDetails(jsoncContent []byte) (errors []string, war
 	strictErr := strict.Validate(doc)
 	if strictErr != nil {
 		if validationErr, ok := strictErr.(*jsonschema.ValidationError); ok {
-			warnings = extractValidationErrors(validationErr)
+			warnings = extractValidationErrors(validationErr, locate)
 		} else {
 			warnings = []string{strictErr.Error()}
 		}
@@ -122,17 +175,21 @@ func ValidateAgainstSchemaWithDetails(jsoncContent []byte) (errors []string, war
 }
 
 // extractValidationErrors recursively extracts error messages from a ValidationError.
-func extractValidationErrors(err *jsonschema.ValidationError) []string {
+// The locate function maps an instance location to a "line N, column M" string.
+func extractValidationErrors(err *jsonschema.ValidationError, locate func([]string) string) []string {
 	var messages []string
 
 	// If this error has causes, recurse into them
 	if len(err.Causes) > 0 {
 		for _, cause := range err.Causes {
-			messages = append(messages, extractValidationErrors(cause)...)
+			messages = append(messages, extractValidationErrors(cause, locate)...)
 		}
 	} else {
 		// Leaf error - format it nicely
 		path := formatJSONPointer(err.InstanceLocation)
+		if pos := locate(err.InstanceLocation); pos != "" {
+			path = fmt.Sprintf("%s (%s)", path, pos)
+		}
 		messages = append(messages, fmt.Sprintf("%s: %s", path, err.Error()))
 	}
 
@@ -146,3 +203,520 @@ func formatJSONPointer(segments []string) string {
 	}
 	return "/" + strings.Join(segments, "/")
 }
+
+// locateJSONPointer returns the "line N, column M" position of the value at the
+// given JSON Pointer segments, or empty string if it can't be found.
+func locateJSONPointer(root *hujson.Value, content []byte, segments []string) string {
+	var ptr strings.Builder
+	for _, seg := range segments {
+		seg = strings.ReplaceAll(seg, "~", "~0")
+		seg = strings.ReplaceAll(seg, "/", "~1")
+		ptr.WriteString("/" + seg)
+	}
+
+	v := root.Find(ptr.String())
+	if v == nil {
+		return ""
+	}
+
+	line, column := lineColumn(content, v.StartOffset)
+	return fmt.Sprintf("line %d, column %d", line, column)
+}
+
+// lineColumn converts a byte offset into 1-based line and column numbers.
+func lineColumn(content []byte, offset int) (line, column int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	line = 1 + bytes.Count(content[:offset], []byte("\n"))
+	column = 1 + offset - (bytes.LastIndexByte(content[:offset], '\n') + 1)
+	return line, column
+}
+
+// ValidateConfigFile performs full validation of a config file: JSONC syntax,
+// schema conformance, and semantic checks that the schema can't express
+// (bad extends references, invalid regexes, unreachable scopes, odd paths).
+//
+// TOML and YAML files are supported; positions are only reported for JSONC.
+// Errors are problems that will cause wrong behavior at runtime. Warnings are
+// suspicious but harmless (unknown properties, unreachable scopes, unclean paths).
+// Messages include line/column positions where they can be determined.
+func ValidateConfigFile(path string) (errors []string, warnings []string) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return []string{fmt.Sprintf("failed to read config file: %v", err)}, nil
+	}
+
+	// TOML and YAML are converted to JSON up front; positions are only
+	// reported for JSONC, where they map directly back to the source
+	withPositions := DetectFormat(path) == FormatJSONC
+	if !withPositions {
+		content, err = StandardizeConfig(path, content)
+		if err != nil {
+			return []string{err.Error()}, nil
+		}
+	}
+
+	errors, warnings = validateSchemaWithDetails(content, withPositions)
+	if len(errors) > 0 {
+		return errors, warnings
+	}
+
+	// Schema passed, so the content is valid JSONC with the right shape
+	standardized, err := hujson.Standardize(content)
+	if err != nil {
+		return []string{fmt.Sprintf("failed to parse JSONC: %v", err)}, warnings
+	}
+	var cfg ProjectConfig
+	if err := json.Unmarshal(standardized, &cfg); err != nil {
+		// Standardize preserves byte offsets, so JSON errors map back to the source
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok && withPositions {
+			line, column := lineColumn(content, int(typeErr.Offset))
+			return []string{fmt.Sprintf("line %d, column %d: %v", line, column, err)}, warnings
+		}
+		return []string{fmt.Sprintf("failed to parse JSON: %v", err)}, warnings
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return []string{fmt.Sprintf("cannot resolve config path: %v", err)}, warnings
+	}
+
+	ast, err := hujson.Parse(content)
+	if err != nil {
+		return []string{fmt.Sprintf("failed to parse JSONC: %v", err)}, warnings
+	}
+	locate := func(segments ...string) string {
+		pointer := formatJSONPointer(segments)
+		if !withPositions {
+			return pointer
+		}
+		if pos := locateJSONPointer(&ast, content, segments); pos != "" {
+			return fmt.Sprintf("%s (%s)", pointer, pos)
+		}
+		return pointer
+	}
+
+	semErrors, semWarnings := validateSemantics(&cfg, absPath, locate)
+	return append(errors, semErrors...), append(warnings, semWarnings...)
+}
+
+// validateSemantics checks a parsed config for problems the JSON schema can't catch.
+// The locate function formats a JSON pointer with its source position.
+func validateSemantics(cfg *ProjectConfig, configPath string, locate func(segments ...string) string) (errors []string, warnings []string) {
+	configDir := filepath.Dir(configPath)
+
+	if !cfg.IsRoot() && hasRootMarker(configDir) {
+		warnings = append(warnings, fmt.Sprintf("%s: \"root\": false has no effect next to %s, which stops this config merging with the one above", locate("root"), RootMarkerFileName))
+	}
+
+	// Root wrappers
+	for _, name := range sortedKeys(cfg.Wrappers) {
+		e, w := validateWrapperSemantics(cfg.Wrappers[name], []string{"wrappers", name}, locate)
+		errors = append(errors, e...)
+		warnings = append(warnings, w...)
+		e, w = validateWrapperPaths(cfg.Wrappers[name], []string{"wrappers", name}, configDir, locate)
+		errors = append(errors, e...)
+		warnings = append(warnings, w...)
+	}
+
+	// Each import must load without cycles. Only its root wrappers are used.
+	for i, ref := range cfg.Imports {
+		imported, _, err := loadImport(ref, configPath, []string{configPath})
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", locate("imports", fmt.Sprint(i)), err))
+		} else if len(imported.Scopes) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: %q has scopes, which are ignored when importing; only its root wrappers are merged",
+				locate("imports", fmt.Sprint(i)), ref))
+		}
+	}
+
+	if cfg.Sync != nil {
+		if err := cfg.Sync.Validate(); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", locate("sync"), err))
+		}
+	}
+
+	switch cfg.SidecarLayout {
+	case "", SidecarLayoutAdjacent, SidecarLayoutDirectory, SidecarLayoutProject:
+	default:
+		errors = append(errors, fmt.Sprintf("%s: must be %q, %q or %q, got %q", locate("sidecarLayout"),
+			SidecarLayoutAdjacent, SidecarLayoutDirectory, SidecarLayoutProject, cfg.SidecarLayout))
+	}
+
+	if cfg.Onboarding != nil {
+		if strings.TrimSpace(cfg.Onboarding.Message) == "" {
+			errors = append(errors, fmt.Sprintf("%s: must not be empty", locate("onboarding", "message")))
+		}
+		if cfg.Onboarding.DocsURL != "" {
+			if err := ValidateDocsURL(cfg.Onboarding.DocsURL); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", locate("onboarding", "docsUrl"), err))
+			}
+		}
+	}
+
+	if cfg.Require != nil {
+		for i, name := range cfg.Require.Wrapped {
+			if strings.TrimSpace(name) == "" || strings.ContainsAny(name, `/\`) {
+				errors = append(errors, fmt.Sprintf("%s: must be a command name, like \"tsc\", got %q", locate("require", "wrapped", fmt.Sprint(i)), name))
+			}
+		}
+	}
+
+	for i, path := range cfg.SearchPaths {
+		if strings.TrimSpace(path) == "" {
+			errors = append(errors, fmt.Sprintf("%s: must not be empty", locate("searchPaths", fmt.Sprint(i))))
+		} else if w := machineSpecificWarning(path, configDir, locate("searchPaths", fmt.Sprint(i))); w != "" {
+			warnings = append(warnings, w)
+		}
+	}
+
+	switch cfg.OnConflict {
+	case "", OnConflictLast, OnConflictHighest, OnConflictError:
+	default:
+		errors = append(errors, fmt.Sprintf("%s: must be %q, %q or %q, got %q", locate("onConflict"),
+			OnConflictLast, OnConflictHighest, OnConflictError, cfg.OnConflict))
+	}
+
+	// Scopes for workspace packages are generated as the config loads; a
+	// declared scope named after a package takes the package's path
+	declaredScopes := make(map[string]bool)
+	for name := range cfg.Scopes {
+		declaredScopes[name] = true
+	}
+	if cfg.Workspaces {
+		if len(WorkspaceManifests(configDir)) == 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: %s has no %s, package.json workspaces, %s or %s [workspace], so no scopes are added",
+				locate("workspaces"), configDir, pnpmWorkspaceFile, goWorkFile, cargoFile))
+		} else if err := applyWorkspaces(cfg, configPath); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", locate("workspaces"), err))
+		}
+	}
+
+	// Collect local extends targets to find mixins nobody uses
+	extended := make(map[string]bool)
+	for _, scope := range cfg.Scopes {
+		for _, ext := range scope.Extends {
+			if isLocalRef(ext) {
+				extended[ext] = true
+			}
+		}
+	}
+
+	scopePaths := make(map[string]string)
+	resolver := NewResolver()
+	for _, scopeName := range sortedKeys(cfg.Scopes) {
+		if !declaredScopes[scopeName] {
+			continue
+		}
+		scope := cfg.Scopes[scopeName]
+		scopeLoc := []string{"scopes", scopeName}
+
+		// Scope path must be safe and should point somewhere real
+		if err := ValidateScopePath(scope.Path, configDir); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", locate(append(scopeLoc, "path")...), err))
+		} else if scope.Path == "" {
+			if !extended["root."+scopeName] {
+				warnings = append(warnings, fmt.Sprintf("%s: scope %q has no path and is not extended by any scope, so it never applies",
+					locate(scopeLoc...), scopeName))
+			}
+		} else {
+			if w := machineSpecificWarning(scope.Path, configDir, locate(append(scopeLoc, "path")...)); w != "" {
+				warnings = append(warnings, w)
+			}
+			absScopePath, _ := ExpandPathVars(scope.Path, configDir)
+			if !filepath.IsAbs(absScopePath) {
+				absScopePath = filepath.Join(configDir, absScopePath)
+			}
+			absScopePath = filepath.Clean(absScopePath)
+			if _, err := os.Stat(absScopePath); os.IsNotExist(err) {
+				warnings = append(warnings, fmt.Sprintf("%s: scope path %q does not exist", locate(append(scopeLoc, "path")...), scope.Path))
+			}
+			if other, ok := scopePaths[absScopePath]; ok {
+				warnings = append(warnings, fmt.Sprintf("%s: scope %q has the same path as scope %q; only one of them can match",
+					locate(append(scopeLoc, "path")...), scopeName, other))
+			} else {
+				scopePaths[absScopePath] = scopeName
+			}
+		}
+
+		// Each extends reference must parse and the whole chain must resolve
+		refsOK := true
+		for i, ext := range scope.Extends {
+			if _, err := ParseExtendsRef(ext, configDir); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", locate(append(scopeLoc, "extends", fmt.Sprint(i))...), err))
+				refsOK = false
+			}
+		}
+		if refsOK {
+			scopeCopy := scope
+			if _, err := resolver.ResolveEffectiveShims(cfg, configPath, &scopeCopy); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", locate(append(scopeLoc, "extends")...), err))
+			}
+		}
+
+		scopeAt := func(segments ...string) string {
+			return locate(append(append([]string{}, scopeLoc...), segments...)...)
+		}
+		errors = append(errors, validateEnv(scope.Env, scopeAt)...)
+		errors = append(errors, validateTags(scope.Tags, scopeAt)...)
+
+		for _, name := range sortedKeys(scope.Wrappers) {
+			e, w := validateWrapperSemantics(scope.Wrappers[name], append(scopeLoc, "wrappers", name), locate)
+			errors = append(errors, e...)
+			warnings = append(warnings, w...)
+			e, w = validateWrapperPaths(scope.Wrappers[name], append(scopeLoc, "wrappers", name), configDir, locate)
+			errors = append(errors, e...)
+			warnings = append(warnings, w...)
+		}
+	}
+
+	return errors, warnings
+}
+
+// validateWrapperSemantics checks a single wrapper for invalid regexes, odd paths,
+// and fields that will be silently ignored.
+func validateWrapperSemantics(w WrapperConfig, loc []string, locate func(segments ...string) string) (errors []string, warnings []string) {
+	at := func(segments ...string) string {
+		return locate(append(append([]string{}, loc...), segments...)...)
+	}
+
+	if w.InteractiveOverride && w.Action != "block" {
+		warnings = append(warnings, fmt.Sprintf("%s: interactiveOverride is ignored unless action is \"block\"", at("interactiveOverride")))
+	}
+
+	if w.HasRedirect() && w.Action != "redirect" {
+		warnings = append(warnings, fmt.Sprintf("%s: redirect is ignored unless action is \"redirect\"", at("redirect")))
+	}
+
+	if w.Action != "redirect" {
+		if w.RedirectMode != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: redirectMode is ignored unless action is \"redirect\"", at("redirectMode")))
+		}
+		if w.RedirectOnFailure != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: redirectOnFailure is ignored unless action is \"redirect\"", at("redirectOnFailure")))
+		}
+	} else if w.RedirectOnFailure != "" && w.RedirectMode != ExecSpawn && !(w.RedirectMode == "" && w.Exec == ExecSpawn) {
+		warnings = append(warnings, fmt.Sprintf("%s: redirectOnFailure only works when the redirect is spawned (\"redirectMode\": \"spawn\")", at("redirectOnFailure")))
+	}
+
+	if w.Timeout != "" {
+		if _, err := ParseTimeout(w.Timeout); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", at("timeout"), err))
+		}
+		switch {
+		case w.Action == "block":
+			warnings = append(warnings, fmt.Sprintf("%s: timeout is ignored when action is \"block\", as nothing runs", at("timeout")))
+		case w.Action == "redirect" && w.RedirectMode == ExecReplace, w.Exec == ExecReplace && (w.Action != "redirect" || w.RedirectMode == ""):
+			warnings = append(warnings, fmt.Sprintf("%s: a timeout spawns the program to time it, so \"replace\" is ignored", at("timeout")))
+		}
+	} else {
+		if w.TimeoutSignal != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: timeoutSignal is ignored without \"timeout\"", at("timeoutSignal")))
+		}
+		if w.TimeoutMessage != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: timeoutMessage is ignored without \"timeout\"", at("timeoutMessage")))
+		}
+	}
+	if w.TimeoutSignal != "" {
+		if err := ValidateTimeoutSignal(w.TimeoutSignal); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", at("timeoutSignal"), err))
+		}
+	}
+
+	if w.IsInlineRedirect() {
+		if _, err := w.RedirectCommand(); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", at("redirect"), err))
+		}
+	}
+	if name, ok := w.BuiltinRedirect(); ok {
+		if !IsBuiltinRedirect(name) {
+			errors = append(errors, fmt.Sprintf("%s: unknown built-in redirect %q (available: %s%s)", at("redirect"), w.Redirect,
+				BuiltinRedirectPrefix, strings.Join(BuiltinRedirects, ", "+BuiltinRedirectPrefix)))
+		} else if w.Action == "redirect" && (w.RedirectMode != "" || w.RedirectOnFailure != "") {
+			warnings = append(warnings, fmt.Sprintf("%s: redirectMode and redirectOnFailure are ignored by %s, which runs inside the wrapper", at("redirect"), w.Redirect))
+		}
+	}
+
+	if w.Hooks != nil {
+		for _, hook := range []struct{ name, command string }{{"before", w.Hooks.Before}, {"after", w.Hooks.After}} {
+			if hook.command == "" {
+				continue
+			}
+			if _, err := ParseHookCommand(hook.command); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", at("hooks", hook.name), err))
+			}
+		}
+	}
+
+	if w.Passthrough != nil {
+		for i, pattern := range w.Passthrough.InvocationRegexp {
+			if _, err := regexp.Compile(pattern); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: invalid regular expression %q: %v",
+					at("passthrough", "invocationRegexp", fmt.Sprint(i)), pattern, err))
+			}
+		}
+	}
+
+	for i, name := range w.OnlyFrom {
+		if err := ValidateOnlyFromName(name); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", at("onlyFrom", fmt.Sprint(i)), err))
+		}
+	}
+	if len(w.OnlyFrom) > 0 && w.Action == "block" {
+		warnings = append(warnings, fmt.Sprintf("%s: a blocked command stays blocked when run by one of these; use \"passthrough\" or \"warn\" to let them run it", at("onlyFrom")))
+	}
+
+	if w.When != nil {
+		if _, err := w.When.Location(); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", at("when", "timezone"), err))
+		}
+		for i, entry := range w.When.Schedule {
+			if _, err := ParseScheduleEntry(entry); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", at("when", "schedule", fmt.Sprint(i)), err))
+			}
+		}
+		if w.When.Timezone != "" && len(w.When.Schedule) == 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: timezone is ignored without a schedule", at("when", "timezone")))
+		}
+	}
+
+	for i, pattern := range w.ArgPathPatterns {
+		if err := ValidateArgPathPattern(pattern); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", at("argPathPatterns", fmt.Sprint(i)), err))
+		}
+	}
+
+	for i, rule := range w.ArgRules {
+		for _, problem := range ValidateArgRule(rule) {
+			errors = append(errors, fmt.Sprintf("%s: %s", at("argRules", fmt.Sprint(i)), problem))
+		}
+	}
+
+	for _, locale := range sortedKeys(w.Messages) {
+		if err := ValidateLocale(locale); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", at("messages", locale), err))
+		}
+	}
+	if w.DocsURL != "" {
+		if err := ValidateDocsURL(w.DocsURL); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", at("docsUrl"), err))
+		}
+	}
+
+	if _, err := w.ExpiryDate(); err != nil {
+		errors = append(errors, fmt.Sprintf("%s: %v", at("expires"), err))
+	}
+	switch w.ExpiredAction {
+	case "", ExpiredWarn, ExpiredPassthrough:
+		if w.ExpiredAction != "" && w.Expires == "" {
+			warnings = append(warnings, fmt.Sprintf("%s: expiredAction is ignored without \"expires\"", at("expiredAction")))
+		}
+	default:
+		errors = append(errors, fmt.Sprintf("%s: must be %q or %q, got %q", at("expiredAction"),
+			ExpiredWarn, ExpiredPassthrough, w.ExpiredAction))
+	}
+
+	errors = append(errors, validateEnv(w.Env, at)...)
+	for _, list := range []struct {
+		key      string
+		patterns []string
+	}{{EnvUnsetKey, w.EnvUnset}, {EnvAllowOnlyKey, w.EnvAllowOnly}} {
+		for i, pattern := range list.patterns {
+			if err := ValidateEnvPattern(pattern); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", at("env", list.key, fmt.Sprint(i)), err))
+			}
+		}
+	}
+	if w.EnvAllowOnly != nil && !MatchEnvPattern(w.EnvAllowOnly, "PATH") {
+		if _, set := w.Env["PATH"]; !set {
+			warnings = append(warnings, fmt.Sprintf("%s: PATH isn't allowed or set, so the program runs with no PATH", at("env", EnvAllowOnlyKey)))
+		}
+	}
+	errors = append(errors, validateTags(w.Tags, at)...)
+
+	if vc := w.VersionCheck; vc != nil {
+		if vc.Pattern != "" {
+			if _, err := regexp.Compile(vc.Pattern); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: invalid regular expression %q: %v",
+					at("versionCheck", "pattern"), vc.Pattern, err))
+			}
+		}
+		if vc.Range != "" {
+			if _, err := ParseVersionRange(vc.Range); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", at("versionCheck", "range"), err))
+			}
+		}
+		if w.Action == "block" {
+			warnings = append(warnings, fmt.Sprintf("%s: versionCheck is ignored when action is \"block\"", at("versionCheck")))
+		}
+	}
+
+	for i, p := range w.Paths {
+		pos := at("paths", fmt.Sprint(i))
+		switch {
+		case p == "":
+			errors = append(errors, fmt.Sprintf("%s: path is empty", pos))
+		case !filepath.IsAbs(p) && !strings.HasPrefix(p, "./") && !strings.HasPrefix(p, "../") && !strings.HasPrefix(p, "${"):
+			warnings = append(warnings, fmt.Sprintf("%s: relative path %q should start with './' or '../' (it is resolved from the config directory)", pos, p))
+		case filepath.Clean(p) != p && "./"+filepath.Clean(p) != p:
+			warnings = append(warnings, fmt.Sprintf("%s: path %q is not clean (did you mean %q?)", pos, p, filepath.Clean(p)))
+		}
+	}
+
+	return errors, warnings
+}
+
+// validateWrapperPaths checks that a wrapper's paths only use known path
+// variables, and warns about paths that only exist on this machine
+func validateWrapperPaths(w WrapperConfig, loc []string, configDir string, locate func(segments ...string) string) (errors []string, warnings []string) {
+	for i, p := range w.Paths {
+		pos := locate(append(append([]string{}, loc...), "paths", fmt.Sprint(i))...)
+		if _, err := ExpandPathVars(p, configDir); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", pos, err))
+		} else if w := machineSpecificWarning(p, configDir, pos); w != "" {
+			warnings = append(warnings, w)
+		}
+	}
+	return errors, warnings
+}
+
+// machineSpecificWarning warns, at pos, about a path specific to this
+// machine, or returns ""
+func machineSpecificWarning(path, configDir, pos string) string {
+	specific, portable := MachineSpecificPath(path, configDir)
+	if !specific {
+		return ""
+	}
+	return fmt.Sprintf("%s: %q is specific to this machine; use %q so the config works for everyone", pos, path, portable)
+}
+
+// validateEnv checks the variable names of a wrapper or scope env
+func validateEnv(env map[string]string, at func(segments ...string) string) (errors []string) {
+	for _, name := range sortedKeys(env) {
+		if err := ValidateEnvName(name); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", at("env", name), err))
+		}
+	}
+	return errors
+}
+
+// validateTags checks the tags of a wrapper or scope
+func validateTags(tags []string, at func(segments ...string) string) (errors []string) {
+	for i, tag := range tags {
+		if err := ValidateTag(tag); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", at("tags", fmt.Sprint(i)), err))
+		}
+	}
+	return errors
+}
+
+// sortedKeys returns the keys of a map in sorted order for stable output.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -2,7 +2,10 @@ package config
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	_ "github.com/happycollision/ribbin/internal/testsafety"
@@ -108,3 +111,585 @@ func TestValidationStrictRejectsExtraProperties(t *testing.T) {
 		t.Error("strict validation should reject extra properties")
 	}
 }
+
+func TestCheckStrictResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "disabled ignores broken extends",
+			content: `{
+				"scopes": {"a": {"extends": ["root.missing"]}}
+			}`,
+		},
+		{
+			name: "enabled with valid config",
+			content: `{
+				"strictResolve": true,
+				"wrappers": {"npm": {"action": "block"}},
+				"scopes": {"a": {"path": "a", "extends": ["root"]}}
+			}`,
+		},
+		{
+			name: "enabled rejects missing fragment",
+			content: `{
+				"strictResolve": true,
+				"scopes": {"a": {"extends": ["root.missing"]}}
+			}`,
+			wantErr: `scope "missing" not found`,
+		},
+		{
+			name: "enabled rejects unknown keys",
+			content: `{
+				"strictResolve": true,
+				"wrappers": {"npm": {"action": "block", "mesage": "typo"}}
+			}`,
+			wantErr: "unknown properties",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "ribbin.jsonc")
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			cfg, err := LoadProjectConfig(configPath)
+			if err != nil {
+				t.Fatalf("LoadProjectConfig error = %v", err)
+			}
+
+			err = CheckStrictResolve(cfg, configPath)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantErr     string
+		wantWarning string
+	}{
+		{
+			name: "valid config",
+			content: `{
+				"wrappers": {"npm": {"action": "block", "paths": ["./bin/npm", "/usr/bin/npm"]}},
+				"scopes": {
+					"hardened": {"wrappers": {"rm": {"action": "block"}}},
+					"app": {"path": ".", "extends": ["root", "root.hardened"]}
+				}
+			}`,
+		},
+		{
+			name: "invalid action reports position",
+			content: `{
+  "wrappers": {
+    "npm": {"action": "explode"}
+  }
+}`,
+			wantErr: "line 3, column 23",
+		},
+		{
+			name: "invalid regexp",
+			content: `{
+				"wrappers": {"tsc": {"action": "block", "passthrough": {"invocationRegexp": ["make (test"]}}}
+			}`,
+			wantErr: "invalid regular expression",
+		},
+		{
+			name: "bad extends fragment",
+			content: `{
+				"scopes": {"app": {"path": ".", "extends": ["root.nope"]}}
+			}`,
+			wantErr: `scope "nope" not found`,
+		},
+		{
+			name: "bad extends path",
+			content: `{
+				"scopes": {"app": {"path": ".", "extends": ["other.jsonc"]}}
+			}`,
+			wantErr: "relative path must start with",
+		},
+		{
+			name: "pinned extends that doesn't match",
+			content: `{
+				"scopes": {"app": {"path": ".", "extends": [{"path": "./ribbin.jsonc#root", "sha256": "` + strings.Repeat("0", 64) + `"}]}}
+			}`,
+			wantErr: "checksum mismatch",
+		},
+		{
+			name: "unreachable mixin",
+			content: `{
+				"scopes": {"orphan": {"wrappers": {"rm": {"action": "block"}}}}
+			}`,
+			wantWarning: "never applies",
+		},
+		{
+			name: "missing scope path",
+			content: `{
+				"scopes": {"app": {"path": "does/not/exist"}}
+			}`,
+			wantWarning: "does not exist",
+		},
+		{
+			name: "ambiguous relative wrapper path",
+			content: `{
+				"wrappers": {"tsc": {"action": "block", "paths": ["node_modules/.bin/tsc"]}}
+			}`,
+			wantWarning: "should start with './'",
+		},
+		{
+			name: "unclean wrapper path",
+			content: `{
+				"wrappers": {"tsc": {"action": "block", "paths": ["/usr//bin/tsc"]}}
+			}`,
+			wantWarning: "is not clean",
+		},
+		{
+			name: "wrapper path in a home directory",
+			content: `{
+				"wrappers": {"tsc": {"action": "block", "paths": ["/Users/alice/bin/tsc"]}}
+			}`,
+			wantWarning: `use "${HOME}/bin/tsc"`,
+		},
+		{
+			name: "search path in a Windows home directory",
+			content: `{
+				"searchPaths": ["C:\\Users\\alice\\tools"],
+				"wrappers": {}
+			}`,
+			wantWarning: `use "${HOME}/tools"`,
+		},
+		{
+			name: "path variables",
+			content: `{
+				"wrappers": {"tsc": {"action": "block", "paths": ["${NODE_BIN}/tsc", "${HOME}/bin/tsc"]}},
+				"searchPaths": ["${PROJECT_ROOT}/tools"],
+				"scopes": {"app": {"path": "${PROJECT_ROOT}"}}
+			}`,
+		},
+		{
+			name: "unknown path variable",
+			content: `{
+				"wrappers": {"tsc": {"action": "block", "paths": ["${NODE_MODULES}/.bin/tsc"]}}
+			}`,
+			wantErr: "unknown path variable ${NODE_MODULES}",
+		},
+		{
+			name: "ignored redirect field",
+			content: `{
+				"wrappers": {"tsc": {"action": "block", "redirect": "./x.sh"}}
+			}`,
+			wantWarning: "redirect is ignored",
+		},
+		{
+			name: "conflict resolved by priority",
+			content: `{
+				"onConflict": "error",
+				"wrappers": {"npm": {"action": "block", "priority": 10}},
+				"scopes": {"web": {"path": ".", "extends": ["root"], "wrappers": {"npm": {"action": "passthrough"}}}}
+			}`,
+		},
+		{
+			name: "conflict with the same priority",
+			content: `{
+				"onConflict": "error",
+				"wrappers": {"npm": {"action": "block"}},
+				"scopes": {"web": {"path": ".", "extends": ["root"], "wrappers": {"npm": {"action": "passthrough"}}}}
+			}`,
+			wantErr: "conflicting wrapper definitions",
+		},
+		{
+			name: "unknown conflict policy",
+			content: `{
+				"onConflict": "first",
+				"wrappers": {"npm": {"action": "block"}}
+			}`,
+			wantErr: "onConflict",
+		},
+		{
+			name: "interactive override",
+			content: `{
+				"wrappers": {"npm": {"action": "block", "interactiveOverride": true}}
+			}`,
+		},
+		{
+			name: "ignored interactive override",
+			content: `{
+				"wrappers": {"npm": {"action": "warn", "interactiveOverride": true}}
+			}`,
+			wantWarning: "interactiveOverride is ignored",
+		},
+		{
+			name: "spawned redirect with failure hint",
+			content: `{
+				"wrappers": {"deploy": {"action": "redirect", "redirect": "./deploy.sh", "redirectMode": "spawn", "redirectOnFailure": "exit {code}"}}
+			}`,
+		},
+		{
+			name: "invalid redirect mode",
+			content: `{
+				"wrappers": {"deploy": {"action": "redirect", "redirect": "./deploy.sh", "redirectMode": "fork"}}
+			}`,
+			wantErr: "redirectMode",
+		},
+		{
+			name: "failure hint without spawn",
+			content: `{
+				"wrappers": {"deploy": {"action": "redirect", "redirect": "./deploy.sh", "redirectOnFailure": "exit {code}"}}
+			}`,
+			wantWarning: "only works when the redirect is spawned",
+		},
+		{
+			name: "built-in redirect",
+			content: `{
+				"wrappers": {"rm": {"action": "redirect", "redirect": "builtin:trash"}}
+			}`,
+		},
+		{
+			name: "unknown built-in redirect",
+			content: `{
+				"wrappers": {"rm": {"action": "redirect", "redirect": "builtin:shred"}}
+			}`,
+			wantErr: "unknown built-in redirect",
+		},
+		{
+			name: "ignored redirect mode",
+			content: `{
+				"wrappers": {"deploy": {"action": "block", "redirectMode": "spawn"}}
+			}`,
+			wantWarning: "redirectMode is ignored",
+		},
+		{
+			name: "hooks",
+			content: `{
+				"wrappers": {"kubectl": {"action": "passthrough", "hooks": {"before": "./require-ticket.sh {args}", "after": "record-metric kubectl"}}}
+			}`,
+		},
+		{
+			name: "hook with unknown placeholder",
+			content: `{
+				"wrappers": {"kubectl": {"action": "passthrough", "hooks": {"after": "./record.sh {status}"}}}
+			}`,
+			wantErr: "unknown placeholder {status} in hook",
+		},
+		{
+			name: "project sidecar layout",
+			content: `{
+				"sidecarLayout": "project",
+				"wrappers": {"tsc": {"action": "block"}}
+			}`,
+		},
+		{
+			name: "unknown sidecar layout",
+			content: `{
+				"sidecarLayout": "nearby",
+				"wrappers": {"tsc": {"action": "block"}}
+			}`,
+			wantErr: "sidecarLayout",
+		},
+		{
+			name: "schedule",
+			content: `{
+				"wrappers": {"kubectl": {"action": "block", "when": {"schedule": ["mon-fri 18:00-09:00", "sat,sun"], "timezone": "Europe/Berlin"}}}
+			}`,
+		},
+		{
+			name: "invalid schedule",
+			content: `{
+				"wrappers": {"kubectl": {"action": "block", "when": {"schedule": ["weekends"]}}}
+			}`,
+			wantErr: "unknown day",
+		},
+		{
+			name: "unknown timezone",
+			content: `{
+				"wrappers": {"kubectl": {"action": "block", "when": {"schedule": ["sat"], "timezone": "Mars/Olympus"}}}
+			}`,
+			wantErr: "unknown timezone",
+		},
+		{
+			name: "timeout",
+			content: `{
+				"wrappers": {"webpack": {"action": "passthrough", "timeout": "10m", "timeoutSignal": "INT", "timeoutMessage": "{command} ran past {timeout}"}}
+			}`,
+		},
+		{
+			name: "invalid timeout",
+			content: `{
+				"wrappers": {"webpack": {"action": "passthrough", "timeout": "ten minutes"}}
+			}`,
+			wantErr: "timeout",
+		},
+		{
+			name: "unknown timeout signal",
+			content: `{
+				"wrappers": {"webpack": {"action": "passthrough", "timeout": "10m", "timeoutSignal": "STOP"}}
+			}`,
+			wantErr: "timeoutSignal",
+		},
+		{
+			name: "timeout of a replaced program",
+			content: `{
+				"wrappers": {"webpack": {"action": "passthrough", "exec": "replace", "timeout": "10m"}}
+			}`,
+			wantWarning: "\"replace\" is ignored",
+		},
+		{
+			name: "timeout signal without timeout",
+			content: `{
+				"wrappers": {"webpack": {"action": "passthrough", "timeoutSignal": "INT"}}
+			}`,
+			wantWarning: "timeoutSignal is ignored",
+		},
+		{
+			name: "only from",
+			content: `{
+				"wrappers": {"tsc": {"action": "passthrough", "onlyFrom": ["make", "just"]}}
+			}`,
+		},
+		{
+			name: "only from with a path",
+			content: `{
+				"wrappers": {"tsc": {"action": "passthrough", "onlyFrom": ["/usr/bin/make"]}}
+			}`,
+			wantErr: "does not match pattern",
+		},
+		{
+			name: "only from a blocked command",
+			content: `{
+				"wrappers": {"tsc": {"action": "block", "onlyFrom": ["make"]}}
+			}`,
+			wantWarning: "stays blocked",
+		},
+		{
+			name: "arg path patterns",
+			content: `{
+				"wrappers": {"rm": {"action": "block", "argPathPatterns": ["*.tfstate", "migrations/**", "/etc/hosts"]}}
+			}`,
+		},
+		{
+			name: "invalid arg path pattern",
+			content: `{
+				"wrappers": {"rm": {"action": "block", "argPathPatterns": ["[*.tfstate"]}}
+			}`,
+			wantErr: "invalid pattern",
+		},
+		{
+			name: "arg path pattern leaving the config directory",
+			content: `{
+				"wrappers": {"rm": {"action": "block", "argPathPatterns": ["../shared/*.tfstate"]}}
+			}`,
+			wantErr: "must not contain '..'",
+		},
+		{
+			name: "expiry",
+			content: `{
+				"wrappers": {"yarn": {"action": "block", "expires": "2025-09-01", "expiredAction": "passthrough"}}
+			}`,
+		},
+		{
+			name: "invalid expiry date",
+			content: `{
+				"wrappers": {"yarn": {"action": "block", "expires": "2025-02-30"}}
+			}`,
+			wantErr: "invalid date",
+		},
+		{
+			name: "expired action without expiry",
+			content: `{
+				"wrappers": {"yarn": {"action": "block", "expiredAction": "warn"}}
+			}`,
+			wantWarning: "expiredAction is ignored",
+		},
+		{
+			name: "localized message",
+			content: `{
+				"wrappers": {"npm": {
+					"action": "block",
+					"message": "Use pnpm, not {command}. See {docsUrl}",
+					"messages": {"fr": "Utilisez pnpm. Voir {docsUrl}", "pt_BR": "Use o pnpm"},
+					"docsUrl": "https://wiki.example.com/pnpm"
+				}}
+			}`,
+		},
+		{
+			name: "invalid message locale",
+			content: `{
+				"wrappers": {"npm": {"action": "block", "messages": {"french": "Utilisez pnpm"}}}
+			}`,
+			wantErr: "french",
+		},
+		{
+			name: "invalid docs url",
+			content: `{
+				"wrappers": {"npm": {"action": "block", "docsUrl": "wiki/pnpm"}}
+			}`,
+			wantErr: "must be an http or https URL",
+		},
+		{
+			name: "verify policy",
+			content: `{
+				"wrappers": {"node": {"action": "passthrough", "verify": "hash"}}
+			}`,
+		},
+		{
+			name: "invalid verify policy",
+			content: `{
+				"wrappers": {"node": {"action": "passthrough", "verify": "sha1"}}
+			}`,
+			wantErr: "verify",
+		},
+		{
+			name: "version check",
+			content: `{
+				"wrappers": {"node": {"action": "passthrough", "versionCheck": {"range": ">=20", "message": "See .nvmrc"}}}
+			}`,
+		},
+		{
+			name: "invalid version range",
+			content: `{
+				"wrappers": {"node": {"action": "passthrough", "versionCheck": {"range": ">=twenty"}}}
+			}`,
+			wantErr: "invalid version range",
+		},
+		{
+			name: "version check on blocked command",
+			content: `{
+				"wrappers": {"node": {"action": "block", "versionCheck": {"range": ">=20"}}}
+			}`,
+			wantWarning: "versionCheck is ignored",
+		},
+		{
+			name: "env",
+			content: `{
+				"wrappers": {"npm": {"action": "passthrough", "env": {"NPM_CONFIG_FUND": "false"}}},
+				"scopes": {"ci": {"path": ".", "extends": ["root"], "env": {"GIT_PAGER": "cat"}}}
+			}`,
+		},
+		{
+			name: "reserved env variable",
+			content: `{
+				"scopes": {"ci": {"path": ".", "env": {"RIBBIN_BYPASS": "1"}}}
+			}`,
+			wantErr: "RIBBIN_BYPASS is reserved",
+		},
+		{
+			name: "invalid env variable name",
+			content: `{
+				"wrappers": {"npm": {"action": "passthrough", "env": {"NPM-FUND": "false"}}}
+			}`,
+			wantErr: "NPM-FUND",
+		},
+		{
+			name: "env scrubbing",
+			content: `{
+				"wrappers": {"terraform": {"action": "passthrough", "env": {"unset": ["AWS_PROFILE", "AWS_*"], "allowOnly": ["PATH", "HOME", "TF_*"], "TF_IN_AUTOMATION": "1"}}}
+			}`,
+		},
+		{
+			name: "reserved env variable unset",
+			content: `{
+				"wrappers": {"terraform": {"action": "passthrough", "env": {"unset": ["RIBBIN_*"]}}}
+			}`,
+			wantErr: "RIBBIN_* is reserved",
+		},
+		{
+			name: "invalid env pattern",
+			content: `{
+				"wrappers": {"terraform": {"action": "passthrough", "env": {"unset": ["AWS_[KEY"]}}}
+			}`,
+			wantErr: "invalid pattern",
+		},
+		{
+			name: "allowOnly without PATH",
+			content: `{
+				"wrappers": {"terraform": {"action": "passthrough", "env": {"allowOnly": ["HOME"]}}}
+			}`,
+			wantWarning: "runs with no PATH",
+		},
+		{
+			name: "tags",
+			content: `{
+				"wrappers": {"npm": {"action": "block", "tags": ["node", "migration"]}},
+				"scopes": {"legacy": {"path": ".", "extends": ["root"], "tags": ["safety"]}}
+			}`,
+		},
+		{
+			name: "invalid tag",
+			content: `{
+				"wrappers": {"npm": {"action": "block", "tags": ["no spaces"]}}
+			}`,
+			wantErr: "tags",
+		},
+		{
+			name: "unknown property",
+			content: `{
+				"wrappers": {"tsc": {"action": "block", "mesage": "typo"}}
+			}`,
+			wantWarning: "mesage",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "ribbin.jsonc")
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			errs, warnings := ValidateConfigFile(configPath)
+
+			if tt.wantErr == "" && len(errs) > 0 {
+				t.Errorf("unexpected errors: %v", errs)
+			}
+			if tt.wantErr != "" && !containsSubstring(errs, tt.wantErr) {
+				t.Errorf("errors = %v, want one containing %q", errs, tt.wantErr)
+			}
+			if tt.wantWarning == "" && tt.wantErr == "" && len(warnings) > 0 {
+				t.Errorf("unexpected warnings: %v", warnings)
+			}
+			if tt.wantWarning != "" && !containsSubstring(warnings, tt.wantWarning) {
+				t.Errorf("warnings = %v, want one containing %q", warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func containsSubstring(messages []string, substr string) bool {
+	for _, m := range messages {
+		if strings.Contains(m, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateConfigFile_YAML(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "ribbin.yaml")
+	content := "wrappers:\n  npm:\n    action: explode\n"
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	errs, _ := ValidateConfigFile(configPath)
+	if !containsSubstring(errs, "/wrappers/npm/action") {
+		t.Errorf("errors = %v, want one for /wrappers/npm/action", errs)
+	}
+	if containsSubstring(errs, "line ") {
+		t.Errorf("YAML errors should not report JSON positions, got %v", errs)
+	}
+}
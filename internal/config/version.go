@@ -0,0 +1,265 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VersionCheckConfig restricts which versions of the original command a
+// wrapper lets run, by running the original to ask for its version.
+type VersionCheckConfig struct {
+	// Args are passed to the original to print its version (default ["--version"])
+	Args []string `json:"args,omitempty"`
+	// Pattern is a regular expression matched against the output. Its first
+	// capture group, or the whole match, is the version. Defaults to the first
+	// version number in the output.
+	Pattern string `json:"pattern,omitempty"`
+	// Range is the semver range the version must satisfy, e.g. ">=20" or
+	// "^18.17 || >=20". Without a range, the output only has to match Pattern.
+	Range string `json:"range,omitempty"`
+	// Action when the version is out of policy: "block" (default) or "warn"
+	Action string `json:"action,omitempty"`
+	// Message is shown when the version is out of policy
+	Message string `json:"message,omitempty"`
+}
+
+// Actions for VersionCheckConfig.Action
+const (
+	VersionCheckBlock = "block"
+	VersionCheckWarn  = "warn"
+)
+
+// defaultVersionPattern finds the first version number in command output
+var defaultVersionPattern = regexp.MustCompile(`\d+(?:\.\d+)+`)
+
+// VersionArgs returns the arguments that make the original print its version
+func (c *VersionCheckConfig) VersionArgs() []string {
+	if len(c.Args) == 0 {
+		return []string{"--version"}
+	}
+	return c.Args
+}
+
+// Blocks reports whether an out-of-policy version is blocked (not just warned about)
+func (c *VersionCheckConfig) Blocks() bool {
+	return c.Action != VersionCheckWarn
+}
+
+// Check matches a command's version output against the policy. It returns the
+// version found (if any), and an error describing why it is out of policy.
+func (c *VersionCheckConfig) Check(output string) (string, error) {
+	pattern := defaultVersionPattern
+	if c.Pattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(c.Pattern); err != nil {
+			return "", fmt.Errorf("invalid pattern %q: %w", c.Pattern, err)
+		}
+	}
+
+	match := pattern.FindStringSubmatch(output)
+	if match == nil {
+		return "", fmt.Errorf("no version matching %q in output %q", pattern, strings.TrimSpace(output))
+	}
+	found := match[0]
+	if len(match) > 1 {
+		found = match[1]
+	}
+
+	if c.Range == "" {
+		return found, nil
+	}
+	versionRange, err := ParseVersionRange(c.Range)
+	if err != nil {
+		return found, err
+	}
+	version, err := ParseVersion(found)
+	if err != nil {
+		return found, err
+	}
+	if !versionRange.Contains(version) {
+		return found, fmt.Errorf("version %s is not in range %q", found, c.Range)
+	}
+	return found, nil
+}
+
+// Version is a parsed semantic version. Missing minor or patch numbers are 0,
+// and pre-release and build suffixes are ignored.
+type Version [3]int
+
+// ParseVersion parses versions like "20", "v18.17.1" or "3.12.0rc1"
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	parts := strings.SplitN(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".", 3)
+	for i, part := range parts {
+		// Stop at the first non-digit ("1-beta", "0rc1", "2+build")
+		end := 0
+		for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		v[i], _ = strconv.Atoi(part[:end])
+		if end < len(part) {
+			break
+		}
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 if v is less than, equal to or greater than other
+func (v Version) Compare(other Version) int {
+	for i := range v {
+		switch {
+		case v[i] < other[i]:
+			return -1
+		case v[i] > other[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// VersionRange is a parsed semver range: alternatives separated by "||",
+// each a list of comparators that must all hold.
+type VersionRange [][]versionComparator
+
+type versionComparator struct {
+	op      string
+	version Version
+}
+
+// ParseVersionRange parses npm-style ranges. Comparators are =, >, >=, <, <=,
+// ^ (same major), ~ (same minor) and x wildcards (20, 20.x, 20.1.*), joined
+// by spaces (and) or "||" (or).
+func ParseVersionRange(s string) (VersionRange, error) {
+	var r VersionRange
+	for _, alternative := range strings.Split(s, "||") {
+		fields := strings.Fields(alternative)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid version range %q: empty alternative", s)
+		}
+		var set []versionComparator
+		for _, field := range fields {
+			comparators, err := parseComparator(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version range %q: %w", s, err)
+			}
+			set = append(set, comparators...)
+		}
+		r = append(r, set)
+	}
+	return r, nil
+}
+
+// parseComparator expands a single comparator into primitive ones
+func parseComparator(field string) ([]versionComparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(field, prefix) {
+			op = prefix
+			break
+		}
+	}
+	rest := strings.TrimPrefix(strings.TrimPrefix(field, op), "v")
+	if rest == "" {
+		return nil, fmt.Errorf("%q needs a version", field)
+	}
+	if rest == "*" || rest == "x" {
+		if op == "" || op == ">=" {
+			return nil, nil // any version
+		}
+		return nil, fmt.Errorf("%q needs a version", field)
+	}
+
+	// Count the numbers given before any wildcard
+	parts := strings.Split(rest, ".")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid version %q", rest)
+	}
+	given := 0
+	for _, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		given++
+	}
+	version, err := ParseVersion(strings.Join(parts[:given], "."))
+	if given == 0 || err != nil {
+		return nil, fmt.Errorf("invalid version %q", rest)
+	}
+
+	// next is the lowest version above the given prefix, e.g. 20.1 -> 20.2.0
+	next := version
+	if given < 3 {
+		next[given-1]++
+		for i := given; i < 3; i++ {
+			next[i] = 0
+		}
+	}
+
+	switch op {
+	case "", "=":
+		if given == 3 {
+			return []versionComparator{{"=", version}}, nil
+		}
+		return []versionComparator{{">=", version}, {"<", next}}, nil
+	case "^":
+		upper := Version{version[0] + 1, 0, 0}
+		if version[0] == 0 && given > 1 {
+			upper = Version{0, version[1] + 1, 0}
+		}
+		return []versionComparator{{">=", version}, {"<", upper}}, nil
+	case "~":
+		upper := Version{version[0], version[1] + 1, 0}
+		if given == 1 {
+			upper = Version{version[0] + 1, 0, 0}
+		}
+		return []versionComparator{{">=", version}, {"<", upper}}, nil
+	case ">":
+		if given < 3 {
+			return []versionComparator{{">=", next}}, nil
+		}
+	case "<=":
+		if given < 3 {
+			return []versionComparator{{"<", next}}, nil
+		}
+	}
+	return []versionComparator{{op, version}}, nil
+}
+
+// Contains reports whether v satisfies the range
+func (r VersionRange) Contains(v Version) bool {
+	for _, set := range r {
+		ok := true
+		for _, c := range set {
+			if !c.matches(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c versionComparator) matches(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Version
+	}{
+		{"20", Version{20, 0, 0}},
+		{"v18.17.1", Version{18, 17, 1}},
+		{"3.12.0rc1", Version{3, 12, 0}},
+		{"1.2-beta", Version{1, 2, 0}},
+		{"1.2.3.4", Version{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.input)
+		if err != nil {
+			t.Errorf("ParseVersion(%q) error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseVersion(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	if _, err := ParseVersion("latest"); err == nil {
+		t.Error("expected error for a version without numbers")
+	}
+}
+
+func TestVersionRangeContains(t *testing.T) {
+	tests := []struct {
+		rng     string
+		matches []string
+		misses  []string
+	}{
+		{">=20", []string{"20.0.0", "22.1.0"}, []string{"19.9.9"}},
+		{">20", []string{"21.0.0"}, []string{"20.9.9"}},
+		{"<=20.1", []string{"20.1.9"}, []string{"20.2.0"}},
+		{"<3", []string{"2.9.9"}, []string{"3.0.0"}},
+		{"20", []string{"20.0.0", "20.11.1"}, []string{"21.0.0"}},
+		{"20.x", []string{"20.5.0"}, []string{"19.0.0"}},
+		{"=1.2.3", []string{"1.2.3"}, []string{"1.2.4"}},
+		{"^18.17", []string{"18.17.0", "18.20.1"}, []string{"18.16.9", "19.0.0"}},
+		{"^0.2.3", []string{"0.2.9"}, []string{"0.3.0"}},
+		{"~1.2.3", []string{"1.2.9"}, []string{"1.3.0"}},
+		{">=1.2 <1.4", []string{"1.3.5"}, []string{"1.4.0", "1.1.0"}},
+		{"^18.17 || >=20", []string{"18.18.0", "20.0.0"}, []string{"19.1.0", "18.16.0"}},
+		{"*", []string{"0.0.1", "99.0.0"}, nil},
+	}
+	for _, tt := range tests {
+		r, err := ParseVersionRange(tt.rng)
+		if err != nil {
+			t.Errorf("ParseVersionRange(%q) error: %v", tt.rng, err)
+			continue
+		}
+		for _, v := range tt.matches {
+			if version, _ := ParseVersion(v); !r.Contains(version) {
+				t.Errorf("%q should contain %s", tt.rng, v)
+			}
+		}
+		for _, v := range tt.misses {
+			if version, _ := ParseVersion(v); r.Contains(version) {
+				t.Errorf("%q should not contain %s", tt.rng, v)
+			}
+		}
+	}
+
+	for _, bad := range []string{"", ">=", "||", "1.2.3.4", ">=abc"} {
+		if _, err := ParseVersionRange(bad); err == nil {
+			t.Errorf("ParseVersionRange(%q) should fail", bad)
+		}
+	}
+}
+
+func TestVersionCheckConfigCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   VersionCheckConfig
+		output  string
+		want    string
+		wantErr string
+	}{
+		{"default pattern in range", VersionCheckConfig{Range: ">=20"}, "v20.11.1\n", "20.11.1", ""},
+		{"default pattern out of range", VersionCheckConfig{Range: ">=20"}, "v18.19.0\n", "18.19.0", "not in range"},
+		{"version among other text", VersionCheckConfig{Range: "^3.11"}, "Python 3.12.1\n", "3.12.1", ""},
+		{"capture group", VersionCheckConfig{Pattern: `go(\d+\.\d+)`, Range: ">=1.22"}, "go version go1.21.5 linux/amd64", "1.21", "not in range"},
+		{"pattern only", VersionCheckConfig{Pattern: `Corepack`}, "pnpm 9.0.0 (Corepack)", "Corepack", ""},
+		{"no version found", VersionCheckConfig{Range: ">=20"}, "command not found", "", "no version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.check.Check(tt.output)
+			if got != tt.want {
+				t.Errorf("version = %q, want %q", got, tt.want)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// A config at the root of a monorepo can set "workspaces": true to get a
+// scope for each package of the workspace, found from the workspace
+// manifests next to it each time the config is loaded. Each generated scope
+// extends root, so the root wrappers apply in every package as before, and
+// is named after the package's directory. A scope the config declares
+// without a path gets a package's path when it has the package's name, so
+// packages can be given wrappers of their own by name.
+
+// Workspace manifests recognized in a workspace root
+const (
+	pnpmWorkspaceFile = "pnpm-workspace.yaml"
+	packageJSONFile   = "package.json"
+	turboFile         = "turbo.json"
+	goWorkFile        = "go.work"
+	cargoFile         = "Cargo.toml"
+)
+
+// workspaceSkipDirs are never searched for packages by a "**" pattern
+var workspaceSkipDirs = map[string]bool{"node_modules": true, "target": true, "vendor": true}
+
+// WorkspaceManifests returns the workspace manifests in dir that list
+// packages, or mark dir as a workspace root as turbo.json does
+func WorkspaceManifests(dir string) []string {
+	var found []string
+	for _, name := range []string{pnpmWorkspaceFile, packageJSONFile, turboFile, goWorkFile, cargoFile} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		switch name {
+		case packageJSONFile:
+			var pkg struct {
+				Workspaces json.RawMessage `json:"workspaces"`
+			}
+			if json.Unmarshal(data, &pkg) != nil || len(pkg.Workspaces) == 0 {
+				continue
+			}
+		case cargoFile:
+			var cargo struct {
+				Workspace *struct{} `toml:"workspace"`
+			}
+			if _, err := toml.Decode(string(data), &cargo); err != nil || cargo.Workspace == nil {
+				continue
+			}
+		}
+		found = append(found, name)
+	}
+	return found
+}
+
+// workspacePackages returns the slash-separated paths, relative to dir, of
+// the packages of the workspace rooted at dir, sorted. read lists the
+// manifests and the directories searched, whose changes can change the
+// packages.
+func workspacePackages(dir string) (packages []string, read []string, err error) {
+	seen := make(map[string]bool)
+	searched := make(map[string]bool)
+	add := func(manifest string, patterns []string, marker string) {
+		read = append(read, filepath.Join(dir, manifest))
+		for _, pkg := range expandWorkspacePatterns(dir, patterns, marker, searched) {
+			if !seen[pkg] {
+				seen[pkg] = true
+				packages = append(packages, pkg)
+			}
+		}
+	}
+
+	if data, readErr := os.ReadFile(filepath.Join(dir, pnpmWorkspaceFile)); readErr == nil {
+		var manifest struct {
+			Packages []string `yaml:"packages"`
+		}
+		if err := yaml.Unmarshal(data, &manifest); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", pnpmWorkspaceFile, err)
+		}
+		add(pnpmWorkspaceFile, manifest.Packages, packageJSONFile)
+	}
+
+	if data, readErr := os.ReadFile(filepath.Join(dir, packageJSONFile)); readErr == nil {
+		var pkg struct {
+			Workspaces json.RawMessage `json:"workspaces"`
+		}
+		if err := json.Unmarshal(data, &pkg); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", packageJSONFile, err)
+		}
+		// npm, yarn and bun list patterns; yarn also takes {"packages": [...]}
+		var patterns []string
+		if len(pkg.Workspaces) > 0 && json.Unmarshal(pkg.Workspaces, &patterns) != nil {
+			var nested struct {
+				Packages []string `json:"packages"`
+			}
+			if err := json.Unmarshal(pkg.Workspaces, &nested); err != nil {
+				return nil, nil, fmt.Errorf("%s: workspaces: %w", packageJSONFile, err)
+			}
+			patterns = nested.Packages
+		}
+		if len(patterns) > 0 {
+			add(packageJSONFile, patterns, packageJSONFile)
+		}
+	}
+
+	if data, readErr := os.ReadFile(filepath.Join(dir, goWorkFile)); readErr == nil {
+		uses, err := parseGoWorkUses(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", goWorkFile, err)
+		}
+		add(goWorkFile, uses, "go.mod")
+	}
+
+	if data, readErr := os.ReadFile(filepath.Join(dir, cargoFile)); readErr == nil {
+		var manifest struct {
+			Workspace *struct {
+				Members []string `toml:"members"`
+				Exclude []string `toml:"exclude"`
+			} `toml:"workspace"`
+		}
+		if _, err := toml.Decode(string(data), &manifest); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", cargoFile, err)
+		}
+		if manifest.Workspace != nil {
+			patterns := manifest.Workspace.Members
+			for _, exclude := range manifest.Workspace.Exclude {
+				patterns = append(patterns, "!"+exclude)
+			}
+			add(cargoFile, patterns, cargoFile)
+		}
+	}
+
+	sort.Strings(packages)
+	read = append(read, sortedKeys(searched)...)
+	return packages, read, nil
+}
+
+// parseGoWorkUses returns the directories of the use directives of a
+// go.work file
+func parseGoWorkUses(data []byte) ([]string, error) {
+	var uses []string
+	inBlock := false
+	for i, line := range strings.Split(string(data), "\n") {
+		if comment := strings.Index(line, "//"); comment >= 0 {
+			line = line[:comment]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case inBlock && fields[0] == ")":
+			inBlock = false
+			continue
+		case inBlock:
+		case fields[0] == "use" && len(fields) == 2 && fields[1] == "(":
+			inBlock = true
+			continue
+		case fields[0] == "use" && len(fields) == 2:
+			fields = fields[1:]
+		default:
+			continue
+		}
+		use := fields[0]
+		if strings.HasPrefix(use, `"`) || strings.HasPrefix(use, "`") {
+			unquoted, err := strconv.Unquote(use)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			use = unquoted
+		}
+		uses = append(uses, use)
+	}
+	return uses, nil
+}
+
+// expandWorkspacePatterns returns the directories under root matching the
+// patterns, relative and slash-separated, that contain marker. Patterns
+// starting with "!" exclude what they match; "*" matches within a path
+// segment and "**" any number of segments. Directories listed are added to
+// searched.
+func expandWorkspacePatterns(root string, patterns []string, marker string, searched map[string]bool) []string {
+	var include, exclude [][]string
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		pattern = path.Clean(strings.TrimPrefix(filepath.ToSlash(pattern), "./"))
+		if pattern == "." || path.IsAbs(pattern) || pattern == ".." || strings.HasPrefix(pattern, "../") {
+			continue
+		}
+		if negated {
+			exclude = append(exclude, strings.Split(pattern, "/"))
+		} else {
+			include = append(include, strings.Split(pattern, "/"))
+		}
+	}
+
+	var matches []string
+	var walk func(rel string, segments []string)
+	walk = func(rel string, segments []string) {
+		if len(segments) == 0 {
+			if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel), marker)); err == nil {
+				matches = append(matches, rel)
+			}
+			return
+		}
+		dir := filepath.Join(root, filepath.FromSlash(rel))
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		searched[dir] = true
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			name := entry.Name()
+			child := path.Join(rel, name)
+			if segments[0] == "**" {
+				if strings.HasPrefix(name, ".") || workspaceSkipDirs[name] {
+					continue
+				}
+				walk(child, segments)
+				continue
+			}
+			if ok, _ := path.Match(segments[0], name); ok {
+				walk(child, segments[1:])
+			}
+		}
+		if segments[0] == "**" {
+			walk(rel, segments[1:])
+		}
+	}
+	for _, segments := range include {
+		walk(".", segments)
+	}
+
+	var packages []string
+	seen := make(map[string]bool)
+	for _, match := range matches {
+		excluded := false
+		for _, segments := range exclude {
+			if matchWorkspacePattern(segments, strings.Split(match, "/")) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded && match != "." && !seen[match] {
+			seen[match] = true
+			packages = append(packages, match)
+		}
+	}
+	return packages
+}
+
+// matchWorkspacePattern reports whether the path segments match the
+// pattern segments, where "**" matches any number of segments
+func matchWorkspacePattern(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchWorkspacePattern(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchWorkspacePattern(pattern[1:], segments[1:])
+}
+
+// applyWorkspaces adds a scope for each package of the workspace rooted at
+// the config's directory, when the config sets "workspaces": true. Scopes
+// the config declares are kept: one without a path named after a package
+// gets its path, and no scope is generated for a package whose directory a
+// declared scope already has.
+func applyWorkspaces(config *ProjectConfig, configPath string) error {
+	if !config.Workspaces {
+		return nil
+	}
+	configDir := filepath.Dir(configPath)
+	packages, read, err := workspacePackages(configDir)
+	if err != nil {
+		return fmt.Errorf("workspaces: %w", err)
+	}
+	config.workspaceFiles = read
+	if len(packages) == 0 {
+		return nil
+	}
+	if config.Scopes == nil {
+		config.Scopes = make(map[string]ScopeConfig)
+	}
+
+	// Directories the declared scopes already have
+	declared := make(map[string]bool)
+	for _, scope := range config.Scopes {
+		if scope.Path == "" || scope.Path == "." {
+			continue
+		}
+		scopePath := scope.Path
+		if !filepath.IsAbs(scopePath) {
+			scopePath = filepath.Join(configDir, scopePath)
+		}
+		declared[filepath.Clean(scopePath)] = true
+	}
+
+	// Packages are named after their directory, or after their whole path
+	// where two directories share a name
+	baseCount := make(map[string]int)
+	for _, pkg := range packages {
+		baseCount[path.Base(pkg)]++
+	}
+
+	config.workspaceScopes = make(map[string]string)
+	for _, pkg := range packages {
+		if declared[filepath.Join(configDir, filepath.FromSlash(pkg))] {
+			continue
+		}
+		name := path.Base(pkg)
+		if baseCount[name] > 1 {
+			name = strings.ReplaceAll(pkg, "/", "-")
+		}
+		if scope, ok := config.Scopes[name]; ok {
+			if scope.Path == "" {
+				scope.Path = pkg
+				config.Scopes[name] = scope
+				config.workspaceScopes[name] = pkg
+			}
+			continue
+		}
+		config.Scopes[name] = ScopeConfig{Path: pkg, Extends: ExtendsList{"root"}}
+		config.workspaceScopes[name] = pkg
+	}
+	return nil
+}
+
+// WorkspaceFiles returns the workspace manifests and directories read to
+// generate the config's workspace scopes
+func (c *ProjectConfig) WorkspaceFiles() []string {
+	return c.workspaceFiles
+}
+
+// WorkspacePackage returns the package directory the scope name was given
+// by "workspaces", generated or filled in, relative to the config
+func (c *ProjectConfig) WorkspacePackage(name string) (string, bool) {
+	pkg, ok := c.workspaceScopes[name]
+	return pkg, ok
+}
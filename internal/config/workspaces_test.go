@@ -0,0 +1,198 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+// writeTree writes files, keyed by slash-separated path, under dir
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWorkspacePackages(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "pnpm-workspace.yaml with exclusions",
+			files: map[string]string{
+				"pnpm-workspace.yaml":                  "packages:\n  - 'packages/*'\n  - 'apps/**'\n  - '!**/test/**'\n",
+				"packages/ui/package.json":             `{}`,
+				"packages/docs/README.md":              "not a package",
+				"apps/web/package.json":                `{}`,
+				"apps/tools/cli/package.json":          `{}`,
+				"apps/test/e2e/package.json":           `{}`,
+				"apps/web/node_modules/x/package.json": `{}`,
+			},
+			want: []string{"apps/tools/cli", "apps/web", "packages/ui"},
+		},
+		{
+			name: "package.json workspaces, as yarn writes them",
+			files: map[string]string{
+				"package.json":            `{"workspaces": {"packages": ["libs/*"]}}`,
+				"turbo.json":              `{}`,
+				"libs/core/package.json":  `{}`,
+				"libs/extra/package.json": `{}`,
+			},
+			want: []string{"libs/core", "libs/extra"},
+		},
+		{
+			name: "go.work",
+			files: map[string]string{
+				"go.work":               "go 1.22\n\nuse ./cmd/server // the server\nuse (\n\t./lib\n\t\"./internal/tools\"\n\t.\n)\n",
+				"cmd/server/go.mod":     "module a\n",
+				"lib/go.mod":            "module b\n",
+				"internal/tools/go.mod": "module c\n",
+			},
+			want: []string{"cmd/server", "internal/tools", "lib"},
+		},
+		{
+			name: "Cargo workspace",
+			files: map[string]string{
+				"Cargo.toml":               "[workspace]\nmembers = [\"crates/*\"]\nexclude = [\"crates/old\"]\n",
+				"crates/parser/Cargo.toml": "[package]\n",
+				"crates/old/Cargo.toml":    "[package]\n",
+			},
+			want: []string{"crates/parser"},
+		},
+		{
+			name: "not a workspace",
+			files: map[string]string{
+				"package.json": `{"name": "app"}`,
+				"Cargo.toml":   "[package]\n",
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, tt.files)
+			packages, _, err := workspacePackages(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(packages, tt.want) {
+				t.Errorf("packages = %v, want %v", packages, tt.want)
+			}
+		})
+	}
+
+	t.Run("invalid manifest", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTree(t, dir, map[string]string{"pnpm-workspace.yaml": "packages: [\n"})
+		if _, _, err := workspacePackages(dir); err == nil || !strings.Contains(err.Error(), "pnpm-workspace.yaml") {
+			t.Errorf("err = %v, want one naming pnpm-workspace.yaml", err)
+		}
+	})
+}
+
+func TestWorkspaceScopes(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"pnpm-workspace.yaml":       "packages:\n  - apps/*\n  - packages/*\n",
+		"apps/web/package.json":     `{}`,
+		"packages/web/package.json": `{}`,
+		"packages/api/package.json": `{}`,
+		"packages/db/package.json":  `{}`,
+		"ribbin.jsonc": `{
+  "workspaces": true,
+  "wrappers": { "npm": { "action": "block", "message": "Use pnpm" } },
+  "scopes": {
+    "api": { "extends": ["root"], "wrappers": { "tsc": { "action": "block", "message": "Use pnpm typecheck" } } },
+    "database": { "path": "packages/db" }
+  }
+}`,
+	})
+	configPath := filepath.Join(dir, "ribbin.jsonc")
+
+	cfg, err := LoadProjectConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make(map[string]string)
+	for name, scope := range cfg.Scopes {
+		paths[name] = scope.Path
+	}
+	want := map[string]string{
+		"apps-web":     "apps/web",
+		"packages-web": "packages/web",
+		"api":          "packages/api",
+		"database":     "packages/db",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("scope paths = %v, want %v", paths, want)
+	}
+	if pkg, ok := cfg.WorkspacePackage("api"); !ok || pkg != "packages/api" {
+		t.Errorf("WorkspacePackage(api) = %q, %v", pkg, ok)
+	}
+	if _, ok := cfg.WorkspacePackage("database"); ok {
+		t.Error("a scope with its own path isn't from the workspace")
+	}
+
+	// Generated scopes extend root, and declared ones keep their wrappers
+	resolver := NewResolver()
+	for cwd, wantShims := range map[string][]string{
+		"apps/web":     {"npm"},
+		"packages/api": {"npm", "tsc"},
+	} {
+		matched := FindMatchingScope(cfg, dir, filepath.Join(dir, cwd))
+		if matched == nil {
+			t.Fatalf("no scope matches %s", cwd)
+		}
+		shims, err := resolver.ResolveEffectiveShims(cfg, configPath, &matched.Config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sortedKeys(shims); !reflect.DeepEqual(got, wantShims) {
+			t.Errorf("wrappers in %s = %v, want %v", cwd, got, wantShims)
+		}
+	}
+
+	t.Run("a new package is picked up on reload", func(t *testing.T) {
+		resolver.store(configPath, cfg)
+		writeTree(t, dir, map[string]string{"packages/auth/package.json": `{}`})
+		if _, ok := resolver.cached(configPath); ok {
+			t.Error("the cached config should be stale once a package is added")
+		}
+		cfg, err := LoadProjectConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scope, ok := cfg.Scopes["auth"]; !ok || scope.Path != "packages/auth" {
+			t.Errorf("auth scope = %+v, %v", scope, ok)
+		}
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		errs, warnings := ValidateConfigFile(configPath)
+		if len(errs) > 0 || len(warnings) > 0 {
+			t.Errorf("errors = %v, warnings = %v", errs, warnings)
+		}
+
+		other := t.TempDir()
+		writeTree(t, other, map[string]string{"ribbin.jsonc": `{"workspaces": true}`})
+		_, warnings = ValidateConfigFile(filepath.Join(other, "ribbin.jsonc"))
+		if len(warnings) != 1 || !strings.Contains(warnings[0], "no pnpm-workspace.yaml") {
+			t.Errorf("warnings = %v, want one about the missing manifest", warnings)
+		}
+	})
+}
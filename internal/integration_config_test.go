@@ -4,12 +4,14 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"testing"
 
 	_ "github.com/happycollision/ribbin/internal/testsafety"
 
 	"github.com/happycollision/ribbin/internal/config"
 	"github.com/happycollision/ribbin/internal/testutil"
+	"github.com/happycollision/ribbin/internal/wrap"
 )
 
 // TestConfigDiscovery tests finding ribbin.jsonc in parent directories
@@ -362,3 +364,312 @@ func TestProvenanceTracking(t *testing.T) {
 	// Cleanup
 	env.MustRunRibbin(env.ProjectDir, "unwrap")
 }
+
+// TestSyncPolicyFromGit tests 'ribbin sync' pulling a signed policy from a git
+// repository, wrapping what it adds and unwrapping what it drops.
+func TestSyncPolicyFromGit(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.InitGitRepo(env.ProjectDir)
+	env.BuildRibbin("")
+
+	npmPath := env.CreateMockBinary(env.BinDir, "npm")
+	curlPath := env.CreateMockBinary(env.BinDir, "curl")
+
+	// The security team's policy repository and signing key
+	policyRepo := env.CreateDir("policies")
+	env.InitGitRepo(policyRepo)
+	keyPath := filepath.Join(env.TmpDir, "signing.key")
+	output := env.MustRunRibbin(env.TmpDir, "sync", "keygen", keyPath)
+	match := regexp.MustCompile(`"publicKey": "([^"]+)"`).FindStringSubmatch(output)
+	if match == nil {
+		t.Fatalf("keygen should print the public key, got:\n%s", output)
+	}
+
+	publish := func(wrapper, path string) {
+		t.Helper()
+		policyPath := filepath.Join(policyRepo, "policy.jsonc")
+		policy := `{"wrappers": {"` + wrapper + `": {"action": "block", "message": "org policy", "paths": ["` + path + `"]}}}`
+		if err := os.WriteFile(policyPath, []byte(policy), 0644); err != nil {
+			t.Fatal(err)
+		}
+		env.MustRunRibbin(policyRepo, "sync", "sign", "--key", keyPath, policyPath)
+		env.GitAdd(policyRepo, ".")
+		env.GitCommit(policyRepo, "Update policy")
+	}
+
+	env.CreateConfig(env.ProjectDir, `{
+  "sync": { "git": "`+policyRepo+`", "path": "policy.jsonc", "publicKey": "`+match[1]+`" }
+}`)
+
+	// The first sync wraps what the policy lists
+	publish("npm", npmPath)
+	output = env.MustRunRibbin(env.ProjectDir, "sync")
+	env.AssertOutputContains(output, "Synced policy")
+	env.AssertFileExists(npmPath + ".ribbin-original")
+
+	// Nothing changed, nothing to do
+	output = env.MustRunRibbin(env.ProjectDir, "sync")
+	env.AssertOutputContains(output, "unchanged")
+
+	// The policy swaps npm for curl
+	publish("curl", curlPath)
+	env.MustRunRibbin(env.ProjectDir, "sync")
+	env.AssertFileNotExists(npmPath + ".ribbin-original")
+	env.AssertFileExists(curlPath + ".ribbin-original")
+
+	// A policy signed with another key is refused and the last one kept
+	otherKey := filepath.Join(env.TmpDir, "other.key")
+	env.MustRunRibbin(env.TmpDir, "sync", "keygen", otherKey)
+	keyPath = otherKey
+	publish("npm", npmPath)
+	output, err := env.RunRibbin(env.ProjectDir, "sync")
+	if err == nil {
+		t.Fatalf("sync should fail on a bad signature, got:\n%s", output)
+	}
+	env.AssertOutputContains(output, "signature")
+	env.AssertFileExists(curlPath + ".ribbin-original")
+	env.AssertFileNotExists(npmPath + ".ribbin-original")
+}
+
+// TestUserConfig tests that the user config's wrappers apply in every
+// directory, beneath the project config's.
+func TestUserConfig(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	userDir := filepath.Join(env.HomeDir, ".config", "ribbin")
+	if err := os.MkdirAll(userDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	npmPath := env.CreateMockBinary(env.BinDir, "npm")
+	curlPath := env.CreateMockBinary(env.BinDir, "curl")
+	userConfig := env.CreateConfig(userDir, `{
+  "wrappers": {
+    "npm": { "action": "block", "message": "user npm", "paths": ["`+npmPath+`"] },
+    "curl": { "action": "block", "message": "user curl", "paths": ["`+curlPath+`"] }
+  }
+}`)
+	env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "npm": { "action": "block", "message": "project npm" }
+  }
+}`)
+	elsewhere := env.CreateDir("elsewhere")
+
+	env.MustRunRibbin(env.ProjectDir, "wrap", userConfig)
+	env.AssertFileExists(npmPath + ".ribbin-original")
+	env.AssertFileExists(curlPath + ".ribbin-original")
+
+	run := func(dir, name string) string {
+		t.Helper()
+		cmd := exec.Command(name)
+		cmd.Dir = dir
+		cmd.Env = env.Environ()
+		output, _ := cmd.CombinedOutput()
+		return string(output)
+	}
+
+	// Activating the user config alone applies its wrappers everywhere,
+	// even where the project config isn't active
+	env.MustRunRibbin(env.ProjectDir, "activate", userConfig)
+	env.AssertOutputContains(run(elsewhere, "npm"), "user npm")
+	env.AssertOutputContains(run(env.ProjectDir, "npm"), "user npm")
+
+	// Where the project config is active, it overrides the user config
+	env.MustRunRibbin(env.ProjectDir, "activate")
+	env.AssertOutputContains(run(env.ProjectDir, "npm"), "project npm")
+	env.AssertOutputContains(run(env.ProjectDir, "curl"), "user curl")
+	env.AssertOutputContains(run(elsewhere, "npm"), "user npm")
+
+	output := env.MustRunRibbin(env.ProjectDir, "config", "show")
+	env.AssertOutputContains(output, "  "+userConfig+"\n")
+	env.AssertOutputContains(output, "(overrides "+userConfig+"#user)")
+
+	// Without the user config's activation, only the project's wrappers apply
+	env.MustRunRibbin(env.ProjectDir, "deactivate", userConfig)
+	env.AssertOutputNotContains(run(elsewhere, "npm"), "user npm")
+	env.AssertOutputContains(run(env.ProjectDir, "curl"), "user curl")
+}
+
+// TestLocalizedBlockMessages tests block messages with placeholders and
+// translations, shared through an extends file
+func TestLocalizedBlockMessages(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	sharedDir := env.CreateDir("shared")
+	env.CreateConfig(sharedDir, `{
+  "wrappers": {
+    "npm": {
+      "action": "block",
+      "message": "Use pnpm, not '{command} {args}' ({scope}). See {docsUrl}",
+      "messages": { "fr": "Utilisez pnpm, pas '{command} {args}'. Voir {docsUrl}" },
+      "docsUrl": "https://wiki.example.com/pnpm"
+    }
+  }
+}`)
+	configPath := env.CreateConfig(env.ProjectDir, `{
+  "scopes": {
+    "web": { "path": "web", "extends": ["../shared/ribbin.jsonc"] }
+  }
+}`)
+	webDir := env.CreateDir("project/web")
+	registry := env.NewRegistry()
+	registry.GlobalActive = true
+	npmPath := env.CreateMockBinary(env.BinDir, "npm")
+	if err := wrap.Install(npmPath, env.RibbinPath, registry, configPath); err != nil {
+		t.Fatalf("failed to install shim: %v", err)
+	}
+	env.SaveRegistry(registry)
+
+	run := func(locale string) string {
+		t.Helper()
+		cmd := exec.Command("npm", "install", "lodash")
+		cmd.Dir = webDir
+		cmd.Env = env.EnvironWith("LC_ALL=", "LC_MESSAGES=", "LANG="+locale)
+		output, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Fatalf("npm should be blocked, got: %s", output)
+		}
+		return string(output)
+	}
+
+	env.AssertOutputContains(run("en_US.UTF-8"), "Use pnpm, not 'npm install lodash' (web). See https://wiki.example.com/pnpm")
+	env.AssertOutputContains(run("fr_FR.UTF-8"), "Utilisez pnpm, pas 'npm install lodash'. Voir https://wiki.example.com/pnpm")
+}
+
+// TestWrapperExpires tests that a wrapper past its "expires" date warns
+// instead of blocking, and that status flags it
+func TestWrapperExpires(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	yarnPath := env.CreateMockBinaryWithOutput(env.BinDir, "yarn", "REAL_YARN: executed")
+	npmPath := env.CreateMockBinaryWithOutput(env.BinDir, "npm", "REAL_NPM: executed")
+	env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "yarn": { "action": "block", "message": "Use pnpm", "expires": "2020-01-01", "paths": ["`+yarnPath+`"] },
+    "npm": { "action": "block", "message": "Use pnpm", "expires": "2999-01-01", "paths": ["`+npmPath+`"] }
+  }
+}`)
+	env.MustRunRibbin(env.ProjectDir, "wrap")
+	env.MustRunRibbin(env.ProjectDir, "activate", "--global")
+
+	cmd := exec.Command("yarn", "install")
+	cmd.Dir = env.ProjectDir
+	cmd.Env = env.Environ()
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("an expired wrapper should not block: %v\n%s", err, output)
+	}
+	env.AssertOutputContains(string(output), "ribbin: warning: 'yarn': Use pnpm")
+	env.AssertOutputContains(string(output), "expired on 2020-01-01")
+	env.AssertOutputContains(string(output), "REAL_YARN: executed")
+
+	cmd = exec.Command("npm", "install")
+	cmd.Dir = env.ProjectDir
+	cmd.Env = env.Environ()
+	if output, err := cmd.CombinedOutput(); err == nil {
+		t.Fatalf("a wrapper before its expiry should still block:\n%s", output)
+	}
+
+	status := env.MustRunRibbin(env.ProjectDir, "status")
+	env.AssertOutputContains(status, "Expired wrappers (1)")
+	env.AssertOutputContains(status, "yarn (expired 2020-01-01, now warns)")
+	env.AssertOutputNotContains(status, "npm (expired")
+}
+
+// TestOnboardingOnFirstBlock tests that a project's onboarding message is
+// shown with the first block only
+func TestOnboardingOnFirstBlock(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	npmPath := env.CreateMockBinaryWithOutput(env.BinDir, "npm", "REAL_NPM: executed")
+	env.CreateConfig(env.ProjectDir, `{
+  "onboarding": {
+    "message": "This repo uses pnpm through corepack.",
+    "docsUrl": "https://example.com/contributing"
+  },
+  "wrappers": {
+    "npm": { "action": "block", "message": "Use pnpm", "paths": ["`+npmPath+`"] }
+  }
+}`)
+	env.MustRunRibbin(env.ProjectDir, "wrap")
+	env.MustRunRibbin(env.ProjectDir, "activate", "--global")
+
+	runNpm := func() string {
+		cmd := exec.Command("npm", "install")
+		cmd.Dir = env.ProjectDir
+		cmd.Env = env.Environ()
+		output, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Fatalf("npm should be blocked:\n%s", output)
+		}
+		return string(output)
+	}
+
+	output := runNpm()
+	env.AssertOutputContains(output, "Use pnpm")
+	env.AssertOutputContains(output, "This repo uses pnpm through corepack.")
+	env.AssertOutputContains(output, "More: https://example.com/contributing")
+
+	output = runNpm()
+	env.AssertOutputContains(output, "Use pnpm")
+	env.AssertOutputNotContains(output, "This repo uses pnpm through corepack.")
+}
+
+// TestArgRulesGovernNpx tests that argRules on a wrapped npx let approved
+// packages through and block the rest
+func TestArgRulesGovernNpx(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	npxPath := env.CreateMockBinaryWithOutput(env.BinDir, "npx", "REAL_NPX: executed")
+	env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "npx": {
+      "action": "passthrough",
+      "paths": ["`+npxPath+`"],
+      "argRules": [
+        { "packages": ["create-vite", "@myorg/*"], "action": "passthrough" },
+        { "packages": ["*"], "action": "block", "message": "{package} is not approved. Use one of: {approved}" }
+      ]
+    }
+  }
+}`)
+	env.MustRunRibbin(env.ProjectDir, "wrap")
+	env.MustRunRibbin(env.ProjectDir, "activate", "--global")
+
+	runNpx := func(args ...string) (string, error) {
+		cmd := exec.Command("npx", args...)
+		cmd.Dir = env.ProjectDir
+		cmd.Env = env.Environ()
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+
+	output, err := runNpx("create-vite@latest", "app")
+	if err != nil {
+		t.Fatalf("an approved package should run: %v\n%s", err, output)
+	}
+	env.AssertOutputContains(output, "REAL_NPX: executed")
+
+	output, err = runNpx("--yes", "create-react-app", "app")
+	if err == nil {
+		t.Fatalf("an unapproved package should be blocked:\n%s", output)
+	}
+	env.AssertOutputContains(output, "create-react-app is not approved. Use one of: create-vite, @myorg/*")
+	env.AssertOutputNotContains(output, "REAL_NPX")
+
+	output, err = runNpx("--version")
+	if err != nil {
+		t.Fatalf("npx without a package should fall back to the wrapper's action: %v\n%s", err, output)
+	}
+	env.AssertOutputContains(output, "REAL_NPX: executed")
+}
@@ -638,3 +638,93 @@ func TestPassthroughWithPnpmNx(t *testing.T) {
 
 	_ = configPath
 }
+
+// TestProjectSidecarLayoutSurvivesReinstall tests keeping originals in the
+// project's .ribbin directory, so wiping and reinstalling node_modules loses
+// nothing and 'ribbin wrap --refresh' wraps the reinstalled binaries again.
+func TestProjectSidecarLayoutSurvivesReinstall(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.BuildRibbin("")
+
+	nodeModules := filepath.Join(env.ProjectDir, "node_modules")
+	toolPath := filepath.Join(nodeModules, ".bin", "tool")
+	// install lays out node_modules like npm: .bin holds relative symlinks
+	install := func(version string) {
+		t.Helper()
+		if err := os.RemoveAll(nodeModules); err != nil {
+			t.Fatal(err)
+		}
+		for _, dir := range []string{filepath.Join(nodeModules, "tool", "bin"), filepath.Dir(toolPath)} {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		env.CreateMockBinaryWithOutput(filepath.Join(nodeModules, "tool", "bin"), "tool", "tool "+version)
+		if err := os.Symlink("../tool/bin/tool", toolPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(extraEnv ...string) (string, error) {
+		t.Helper()
+		cmd := exec.Command(toolPath)
+		cmd.Dir = env.ProjectDir
+		cmd.Env = env.EnvironWith(extraEnv...)
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+
+	install("1.0")
+	env.CreateConfig(env.ProjectDir, `{
+  "sidecarLayout": "project",
+  "wrappers": {
+    "tool": { "action": "block", "message": "Use the task runner", "paths": ["node_modules/.bin/tool"] }
+  }
+}`)
+	env.MustRunRibbin(env.ProjectDir, "wrap")
+	env.MustRunRibbin(env.ProjectDir, "activate", "--global")
+
+	stored := filepath.Join(env.ProjectDir, ".ribbin", "originals", "node_modules", ".bin", "tool")
+	env.AssertSymlink(toolPath, env.RibbinPath)
+	env.AssertFileExists(stored)
+	env.AssertFileExists(filepath.Join(env.ProjectDir, ".ribbin", ".gitignore"))
+	env.AssertFileNotExists(toolPath + ".ribbin-original")
+
+	output, err := run()
+	if err == nil {
+		t.Fatalf("tool should be blocked, got: %s", output)
+	}
+	env.AssertOutputContains(output, "Use the task runner")
+	output, err = run("RIBBIN_BYPASS=1")
+	if err != nil {
+		t.Fatalf("bypassed tool failed: %v\n%s", err, output)
+	}
+	env.AssertOutputContains(output, "tool 1.0")
+
+	// A frozen-lockfile install replaces node_modules wholesale
+	install("2.0")
+	output, err = env.RunRibbin(env.ProjectDir, "wrap")
+	if err == nil {
+		t.Fatalf("wrap should refuse to replace the kept original, got: %s", output)
+	}
+	env.AssertOutputContains(output, "--refresh")
+
+	output = env.MustRunRibbin(env.ProjectDir, "wrap", "--refresh")
+	env.AssertOutputContains(output, "Refreshing")
+	env.AssertSymlink(toolPath, env.RibbinPath)
+	if _, err := run(); err == nil {
+		t.Error("tool should be blocked again after --refresh")
+	}
+	output, err = run("RIBBIN_BYPASS=1")
+	if err != nil {
+		t.Fatalf("bypassed tool failed: %v\n%s", err, output)
+	}
+	env.AssertOutputContains(output, "tool 2.0")
+
+	env.MustRunRibbin(env.ProjectDir, "unwrap")
+	output, err = run()
+	if err != nil {
+		t.Fatalf("unwrapped tool failed: %v\n%s", err, output)
+	}
+	env.AssertOutputContains(output, "tool 2.0")
+	env.AssertFileNotExists(stored)
+}
@@ -137,7 +137,9 @@ func TestStatusFindStatusFlow(t *testing.T) {
 	json.Unmarshal(registryData, &registry)
 
 	// Remove cmd2 from registry
-	delete(registry.Wrappers, "cmd2")
+	for _, entry := range registry.WrappersNamed("cmd2") {
+		registry.RemoveWrapper(entry.Original)
+	}
 	newData, _ := json.MarshalIndent(registry, "", "  ")
 	os.WriteFile(registryPath, newData, 0644)
 
@@ -222,3 +224,84 @@ func TestOrphanedMetadataCleanup(t *testing.T) {
 
 	t.Log("Orphaned metadata cleanup test completed!")
 }
+
+// TestFindRestoreAndAdopt tests repairing orphaned sidecars with
+// 'ribbin find --adopt' and 'ribbin find --restore'
+func TestFindRestoreAndAdopt(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.InitGitRepo(env.ProjectDir)
+
+	cmd1 := env.CreateMockBinaryWithOutput(env.BinDir, "cmd1", "cmd1 output")
+	cmd2 := env.CreateMockBinaryWithOutput(env.BinDir, "cmd2", "cmd2 output")
+	env.BuildRibbin("")
+	ribbinPath, err := filepath.EvalSymlinks(env.RibbinPath)
+	if err != nil {
+		t.Fatalf("failed to resolve ribbin path: %v", err)
+	}
+
+	configPath := env.CreateBlockConfig(env.ProjectDir, "cmd1", "blocked", []string{cmd1, cmd2})
+	env.GitAdd(env.ProjectDir, "ribbin.jsonc")
+	env.GitCommit(env.ProjectDir, "Add config")
+	env.MustRunRibbin(env.ProjectDir, "wrap")
+
+	// Orphan both: forget them, and break cmd2's wrapper
+	env.SaveRegistry(env.NewRegistry())
+	os.Remove(cmd2)
+
+	output := env.MustRunRibbin(env.ProjectDir, "find", "--adopt", configPath, env.BinDir)
+	t.Logf("find --adopt output: %s", output)
+	env.AssertSymlink(cmd1, ribbinPath)
+	env.AssertSymlink(cmd2, ribbinPath)
+	registry := env.LoadRegistry()
+	for _, path := range []string{cmd1, cmd2} {
+		if entry := registry.Wrappers[path]; entry.Config != configPath {
+			t.Errorf("%s registered under %q, want %s", path, entry.Config, configPath)
+		}
+	}
+
+	// Orphan them again, then restore
+	env.SaveRegistry(env.NewRegistry())
+	output = env.MustRunRibbin(env.ProjectDir, "find", "--restore", env.BinDir)
+	t.Logf("find --restore output: %s", output)
+	for _, path := range []string{cmd1, cmd2} {
+		env.AssertNotSymlink(path)
+		env.AssertFileNotExists(path + ".ribbin-original")
+		env.AssertFileNotExists(path + ".ribbin-meta")
+	}
+	if registry := env.LoadRegistry(); len(registry.Wrappers) != 0 {
+		t.Errorf("expected no registered wrappers after restore, got %v", registry.Wrappers)
+	}
+}
+
+// TestSearchPathsOrphans tests that status and find look in the config's
+// searchPaths, outside the current directory
+func TestSearchPathsOrphans(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	toolPath := env.CreateMockBinary(env.BinDir, "test-cmd")
+	env.CreateConfig(env.ProjectDir, `{
+  "searchPaths": ["`+env.BinDir+`"],
+  "wrappers": {
+    "test-cmd": {"action": "block", "message": "Use proper-cmd", "paths": ["`+toolPath+`"]}
+  }
+}`)
+	env.MustRunRibbin(env.ProjectDir, "wrap")
+
+	registryPath := filepath.Join(env.HomeDir, ".config", "ribbin", "registry.json")
+	os.WriteFile(registryPath, []byte(`{"wrappers":{},"shell_activations":{},"config_activations":{},"global_active":false}`), 0644)
+
+	output := env.MustRunRibbin(env.ProjectDir, "status")
+	env.AssertOutputContains(output, "Search Paths (1)")
+	env.AssertOutputContains(output, "Orphaned wrapped binaries (NOT in registry) (1)")
+	env.AssertOutputContains(output, toolPath)
+
+	output = env.MustRunRibbin(env.ProjectDir, "find")
+	env.AssertOutputContains(output, "Searching search path "+env.BinDir)
+	env.AssertOutputContains(output, "Added 1 orphaned sidecar(s) to registry for tracking.")
+
+	output = env.MustRunRibbin(env.ProjectDir, "status")
+	env.AssertOutputContains(output, "No orphaned sidecars found.")
+}
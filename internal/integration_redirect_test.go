@@ -1,9 +1,11 @@
 package internal
 
 import (
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	_ "github.com/happycollision/ribbin/internal/testsafety"
@@ -105,6 +107,215 @@ func TestRedirectAction(t *testing.T) {
 	t.Log("Redirect action test completed successfully!")
 }
 
+// TestRedirectSpawnMode tests a spawned redirect target: stdin is streamed
+// to it, its exit code is passed on, and the failure hint follows a non-zero exit.
+func TestRedirectSpawnMode(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	scriptPath := filepath.Join(env.ProjectDir, "deploy.sh")
+	script := "#!/bin/sh\nread line\necho \"got: $line\"\nexit \"$1\"\n"
+	if err := os.WriteFile(scriptPath, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	configPath := env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "deploy": {
+      "action": "redirect",
+      "redirect": "./deploy.sh",
+      "redirectMode": "spawn",
+      "redirectOnFailure": "deploy failed with exit code {code}; see docs/deploy.md"
+    }
+  }
+}`)
+
+	deployPath := env.CreateMockBinary(env.BinDir, "deploy")
+	registry := env.NewRegistry()
+	registry.GlobalActive = true
+	if err := wrap.Install(deployPath, env.RibbinPath, registry, configPath); err != nil {
+		t.Fatalf("failed to install shim: %v", err)
+	}
+	env.SaveRegistry(registry)
+	env.ChdirProject()
+
+	run := func(code string) (string, int) {
+		t.Helper()
+		cmd := exec.Command("deploy", code)
+		cmd.Env = env.Environ()
+		cmd.Stdin = strings.NewReader("hello\n")
+		output, err := cmd.CombinedOutput()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			t.Fatalf("failed to run deploy: %v", err)
+		}
+		return string(output), cmd.ProcessState.ExitCode()
+	}
+
+	output, code := run("0")
+	if code != 0 {
+		t.Errorf("exit code = %d, want 0\n%s", code, output)
+	}
+	env.AssertOutputContains(output, "got: hello")
+	env.AssertOutputNotContains(output, "deploy failed")
+
+	output, code = run("3")
+	if code != 3 {
+		t.Errorf("exit code = %d, want 3\n%s", code, output)
+	}
+	env.AssertOutputContains(output, "got: hello")
+	env.AssertOutputContains(output, "deploy failed with exit code 3; see docs/deploy.md")
+}
+
+// TestRedirectChain tests redirects whose targets run wrapped commands that
+// redirect too: the exit status at the end of the chain reaches the caller,
+// each target sees its own RIBBIN_* variables, and a redirect loop stops at
+// the maximum depth with exit status 126.
+func TestRedirectChain(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	scripts := map[string]string{
+		// deploy runs the wrapped build and passes on how it exited
+		"deploy.sh": "#!/bin/sh\nbuild \"$@\"\nstatus=$?\necho \"build exited $status\"\nexit $status\n",
+		"build.sh":  "#!/bin/sh\necho \"$RIBBIN_COMMAND at depth $RIBBIN_DEPTH via $RIBBIN_REDIRECT_CHAIN\"\nexit 42\n",
+		// lint runs itself, a loop
+		"lint.sh": "#!/bin/sh\nexec lint \"$@\"\n",
+	}
+	for name, content := range scripts {
+		if err := os.WriteFile(filepath.Join(env.ProjectDir, name), []byte(content), 0755); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	configPath := env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "deploy": { "action": "redirect", "redirect": "./deploy.sh" },
+    "build": { "action": "redirect", "redirect": "./build.sh" },
+    "lint": { "action": "redirect", "redirect": "./lint.sh" }
+  }
+}`)
+
+	registry := env.NewRegistry()
+	registry.GlobalActive = true
+	for _, name := range []string{"deploy", "build", "lint"} {
+		path := env.CreateMockBinary(env.BinDir, name)
+		if err := wrap.Install(path, env.RibbinPath, registry, configPath); err != nil {
+			t.Fatalf("failed to install shim for %s: %v", name, err)
+		}
+	}
+	env.SaveRegistry(registry)
+	env.ChdirProject()
+
+	run := func(name string, extraEnv ...string) (string, int) {
+		t.Helper()
+		cmd := exec.Command(name)
+		cmd.Env = env.EnvironWith(extraEnv...)
+		output, err := cmd.CombinedOutput()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			t.Fatalf("failed to run %s: %v", name, err)
+		}
+		return string(output), cmd.ProcessState.ExitCode()
+	}
+
+	output, code := run("deploy")
+	if code != 42 {
+		t.Errorf("exit code = %d, want build's 42\n%s", code, output)
+	}
+	env.AssertOutputContains(output, "build at depth 2 via deploy:build")
+	env.AssertOutputContains(output, "build exited 42")
+
+	// Too deep for RIBBIN_MAX_DEPTH: build refuses, and deploy passes it on
+	output, code = run("deploy", "RIBBIN_MAX_DEPTH=1")
+	if code != wrap.RedirectDepthExitCode {
+		t.Errorf("exit code = %d, want %d\n%s", code, wrap.RedirectDepthExitCode, output)
+	}
+	env.AssertOutputContains(output, "not redirecting 'build': 1 redirects deep, the most allowed is 1 (deploy -> build)")
+	env.AssertOutputContains(output, "build exited 126")
+
+	output, code = run("lint")
+	if code != wrap.RedirectDepthExitCode {
+		t.Errorf("exit code = %d, want %d\n%s", code, wrap.RedirectDepthExitCode, output)
+	}
+	env.AssertOutputContains(output, "not redirecting 'lint': 8 redirects deep, the most allowed is 8")
+	env.AssertOutputContains(output, "lint -> lint -> lint")
+}
+
+// TestWrapperHooks tests before and after hooks: a before hook that exits
+// non-zero stops the command, and the after hook sees its exit code.
+func TestWrapperHooks(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	logPath := filepath.Join(env.ProjectDir, "hooks.log")
+	hooks := map[string]string{
+		"require-ticket.sh": "#!/bin/sh\n[ -n \"$TICKET\" ] || { echo \"set TICKET to run $RIBBIN_COMMAND $*\" >&2; exit 4; }\n",
+		"record.sh":         "#!/bin/sh\necho \"$RIBBIN_COMMAND exited $RIBBIN_EXIT_CODE\" >> " + logPath + "\n",
+	}
+	for name, content := range hooks {
+		if err := os.WriteFile(filepath.Join(env.ProjectDir, name), []byte(content), 0755); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	configPath := env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "deploy": {
+      "action": "passthrough",
+      "hooks": { "before": "./require-ticket.sh {args}", "after": "./record.sh" }
+    }
+  }
+}`)
+
+	deployPath := filepath.Join(env.BinDir, "deploy")
+	if err := os.WriteFile(deployPath, []byte("#!/bin/sh\necho deploying\nexit 2\n"), 0755); err != nil {
+		t.Fatalf("failed to create deploy: %v", err)
+	}
+	registry := env.NewRegistry()
+	registry.GlobalActive = true
+	if err := wrap.Install(deployPath, env.RibbinPath, registry, configPath); err != nil {
+		t.Fatalf("failed to install shim: %v", err)
+	}
+	env.SaveRegistry(registry)
+	env.ChdirProject()
+
+	run := func(extraEnv ...string) (string, int) {
+		t.Helper()
+		cmd := exec.Command("deploy", "prod")
+		cmd.Env = env.EnvironWith(extraEnv...)
+		output, err := cmd.CombinedOutput()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			t.Fatalf("failed to run deploy: %v", err)
+		}
+		return string(output), cmd.ProcessState.ExitCode()
+	}
+
+	// Without a ticket the before hook stops the command
+	output, code := run()
+	if code != 4 {
+		t.Errorf("exit code = %d, want the before hook's 4\n%s", code, output)
+	}
+	env.AssertOutputContains(output, "set TICKET to run deploy prod")
+	env.AssertOutputNotContains(output, "deploying")
+	env.AssertFileNotExists(logPath)
+
+	// With a ticket it runs, and the after hook records its exit code
+	output, code = run("TICKET=OPS-42")
+	if code != 2 {
+		t.Errorf("exit code = %d, want deploy's 2\n%s", code, output)
+	}
+	env.AssertOutputContains(output, "deploying")
+	log, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("after hook should have run: %v", err)
+	}
+	if string(log) != "deploy exited 2\n" {
+		t.Errorf("hook log = %q", log)
+	}
+}
+
 // TestShimPathResolution tests path resolution for shimmed commands
 func TestShimPathResolution(t *testing.T) {
 	env := testutil.SetupIntegrationEnv(t)
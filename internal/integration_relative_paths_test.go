@@ -116,7 +116,7 @@ func TestUnwrapWithRelativePathsFromDifferentDir(t *testing.T) {
 
 	// Verify registry is clean
 	registry := env.LoadRegistry()
-	if _, exists := registry.Wrappers["tsc"]; exists {
+	if _, exists := registry.LookupWrapper("tsc", ""); exists {
 		t.Error("expected tsc to be removed from registry after unwrap")
 	}
 
@@ -171,7 +171,7 @@ func TestWrapStoresAbsolutePaths(t *testing.T) {
 	// Load the registry and verify the path is stored as absolute
 	registry := env.LoadRegistry()
 
-	entry, exists := registry.Wrappers["tsc"]
+	entry, exists := registry.LookupWrapper("tsc", "")
 	if !exists {
 		t.Fatal("expected tsc entry in registry")
 	}
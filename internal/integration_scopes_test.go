@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	_ "github.com/happycollision/ribbin/internal/testsafety"
@@ -333,3 +334,44 @@ func TestScopeWrappersUnwrapWithoutAll(t *testing.T) {
 	_ = frontendDir
 	_ = configPath
 }
+
+// TestExplainEnv verifies that RIBBIN_EXPLAIN=1 makes a wrapper explain its
+// decision before acting: the config, scope, and where the wrapper came from
+func TestExplainEnv(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	frontendDir := env.CreateDir("project/frontend")
+	npmPath := env.CreateMockBinaryWithOutput(env.BinDir, "npm", "REAL_NPM: executed")
+	configPath := env.CreateConfig(env.ProjectDir, fmt.Sprintf(`{
+  "wrappers": {
+    "npm": { "action": "block", "message": "Use pnpm instead of npm", "paths": ["%s"] }
+  },
+  "scopes": {
+    "frontend": { "path": "frontend", "extends": ["root"] }
+  }
+}`, npmPath))
+	env.MustRunRibbin(env.ProjectDir, "wrap")
+	env.MustRunRibbin(env.ProjectDir, "activate", "--global")
+
+	cmd := exec.Command("npm", "install")
+	cmd.Dir = frontendDir
+	cmd.Env = env.EnvironWith("RIBBIN_EXPLAIN=1")
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("npm should be blocked: %s", output)
+	}
+	env.AssertOutputContains(string(output), "ribbin explain: npm -> BLOCKED")
+	env.AssertOutputContains(string(output), `scope "frontend"`)
+	env.AssertOutputContains(string(output), "defined in "+configPath+"#root")
+	if explain, block := strings.Index(string(output), "ribbin explain"), strings.Index(string(output), "Use pnpm"); explain > block {
+		t.Errorf("the explanation should come before the block message:\n%s", output)
+	}
+
+	cmd = exec.Command("npm", "install")
+	cmd.Dir = frontendDir
+	cmd.Env = env.Environ()
+	output, _ = cmd.CombinedOutput()
+	env.AssertOutputNotContains(string(output), "ribbin explain")
+}
@@ -1,9 +1,13 @@
 package internal
 
 import (
+	"bytes"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/happycollision/ribbin/internal/testsafety"
 
@@ -52,7 +56,7 @@ exit 1
 	env.AssertFileExists(sidecarPath)
 
 	// Verify registry was updated
-	if _, exists := registry.Wrappers["test-cmd"]; !exists {
+	if _, exists := registry.LookupWrapper("test-cmd", ""); !exists {
 		t.Error("registry should contain test-cmd entry")
 	}
 
@@ -78,7 +82,7 @@ exit 1
 	env.AssertFileNotExists(sidecarPath)
 
 	// Verify registry was updated
-	if _, exists := registry.Wrappers["test-cmd"]; exists {
+	if _, exists := registry.LookupWrapper("test-cmd", ""); exists {
 		t.Error("registry should not contain test-cmd entry after uninstall")
 	}
 
@@ -100,8 +104,8 @@ func TestRegistryPersistence(t *testing.T) {
 	// Create and save registry
 	registry := &config.Registry{
 		Wrappers: map[string]config.WrapperEntry{
-			"cat":  {Original: "/usr/bin/cat", Config: "/project/ribbin.jsonc"},
-			"node": {Original: "/usr/local/bin/node", Config: "/other/ribbin.jsonc"},
+			"/usr/bin/cat":        {Original: "/usr/bin/cat", Config: "/project/ribbin.jsonc"},
+			"/usr/local/bin/node": {Original: "/usr/local/bin/node", Config: "/other/ribbin.jsonc"},
 		},
 		ShellActivations:  make(map[int]config.ShellActivationEntry),
 		ConfigActivations: make(map[string]config.ConfigActivationEntry),
@@ -124,7 +128,7 @@ func TestRegistryPersistence(t *testing.T) {
 	if len(loaded.Wrappers) != 2 {
 		t.Errorf("expected 2 shims, got %d", len(loaded.Wrappers))
 	}
-	if loaded.Wrappers["cat"].Original != "/usr/bin/cat" {
+	if entry, _ := loaded.Wrapper("/usr/bin/cat"); entry.Config != "/project/ribbin.jsonc" {
 		t.Error("cat shim Original mismatch")
 	}
 }
@@ -218,9 +222,807 @@ func TestUnwrapInconsistentState(t *testing.T) {
 
 	// Registry should be cleaned
 	registry := env.LoadRegistry()
-	if _, exists := registry.Wrappers["tsc"]; exists {
+	if _, exists := registry.LookupWrapper("tsc", ""); exists {
 		t.Error("expected tsc to be removed from registry after unwrap")
 	}
 
 	t.Log("Unwrap inconsistent state test completed successfully!")
 }
+
+// TestWrapSameNameInSeveralPackages tests a monorepo where every package has
+// its own node_modules/.bin/tsc: each is wrapped, listed and unwrapped on its own.
+func TestWrapSameNameInSeveralPackages(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.InitGitRepo(env.ProjectDir)
+	env.BuildRibbin("")
+
+	packages := []string{"a", "b", "c"}
+	tscPaths := make(map[string]string)
+	for _, pkg := range packages {
+		pkgDir := filepath.Join(env.ProjectDir, "packages", pkg)
+		binDir := filepath.Join(pkgDir, "node_modules", ".bin")
+		if err := os.MkdirAll(binDir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", binDir, err)
+		}
+		tscPaths[pkg] = env.CreateMockBinaryWithOutput(binDir, "tsc", "tsc of "+pkg)
+		env.CreateBlockConfig(pkgDir, "tsc", "Use the package script", []string{tscPaths[pkg]})
+		env.MustRunRibbin(pkgDir, "wrap")
+	}
+
+	for _, pkg := range packages {
+		env.AssertFileExists(tscPaths[pkg] + ".ribbin-original")
+	}
+	registry := env.LoadRegistry()
+	if named := registry.WrappersNamed("tsc"); len(named) != len(packages) {
+		t.Fatalf("expected %d tsc wrappers, got %d", len(packages), len(named))
+	}
+
+	output := env.MustRunRibbin(env.ProjectDir, "status")
+	for _, pkg := range packages {
+		env.AssertOutputContains(output, tscPaths[pkg])
+	}
+	// Each package's binary is listed under the package's own config
+	for _, entry := range registry.WrappersNamed("tsc") {
+		env.AssertOutputContains(output, entry.Config+" (1 wrapped)")
+	}
+
+	// Unwrapping one package leaves the others wrapped
+	env.MustRunRibbin(filepath.Join(env.ProjectDir, "packages", "b"), "unwrap")
+	env.AssertFileNotExists(tscPaths["b"] + ".ribbin-original")
+	env.AssertFileExists(tscPaths["a"] + ".ribbin-original")
+	env.AssertFileExists(tscPaths["c"] + ".ribbin-original")
+
+	// --only accepts a single path
+	env.MustRunRibbin(env.ProjectDir, "unwrap", "--only", tscPaths["a"])
+	env.AssertFileNotExists(tscPaths["a"] + ".ribbin-original")
+	env.AssertFileExists(tscPaths["c"] + ".ribbin-original")
+
+	registry = env.LoadRegistry()
+	if named := registry.WrappersNamed("tsc"); len(named) != 1 || named[0].Original != tscPaths["c"] {
+		t.Errorf("only c's tsc should remain wrapped, got %v", named)
+	}
+}
+
+func TestInterceptionMetrics(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	configPath := env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "npm": { "action": "block", "message": "Use pnpm" },
+    "make": { "action": "passthrough" }
+  }
+}`)
+	registry := env.NewRegistry()
+	registry.GlobalActive = true
+	for _, name := range []string{"npm", "make"} {
+		if err := wrap.Install(env.CreateMockBinary(env.BinDir, name), env.RibbinPath, registry, configPath); err != nil {
+			t.Fatalf("failed to install shim: %v", err)
+		}
+	}
+	env.SaveRegistry(registry)
+	env.ChdirProject()
+
+	run := func(name string) {
+		t.Helper()
+		cmd := exec.Command(name)
+		cmd.Env = env.Environ()
+		cmd.Run()
+	}
+
+	// Nothing is counted until metrics are enabled
+	run("npm")
+	output := env.MustRunRibbin(env.ProjectDir, "metrics")
+	env.AssertOutputContains(output, "Metrics are disabled")
+	env.AssertOutputContains(output, "No interceptions counted")
+
+	textfile := filepath.Join(env.TmpDir, "ribbin.prom")
+	settingsDir := filepath.Join(env.HomeDir, ".config", "ribbin")
+	if err := os.MkdirAll(settingsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	settings := `{"metrics": {"enabled": true, "prometheusTextfile": "` + textfile + `"}}`
+	if err := os.WriteFile(filepath.Join(settingsDir, "settings.jsonc"), []byte(settings), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run("npm")
+	run("npm")
+	run("make")
+	// A bypass isn't an interception
+	cmd := exec.Command("make")
+	cmd.Env = env.EnvironWith("RIBBIN_BYPASS=1")
+	cmd.Run()
+
+	output = env.MustRunRibbin(env.ProjectDir, "metrics", "--prometheus")
+	env.AssertOutputContains(output, `ribbin_interceptions_total{command="npm",outcome="blocked"} 2`)
+	env.AssertOutputContains(output, `ribbin_interceptions_total{command="make",outcome="pass"} 1`)
+
+	data, err := os.ReadFile(textfile)
+	if err != nil {
+		t.Fatalf("textfile not written: %v", err)
+	}
+	if string(data) != output {
+		t.Errorf("textfile should match 'ribbin metrics --prometheus':\n%s", data)
+	}
+
+	env.MustRunRibbin(env.ProjectDir, "metrics", "reset")
+	output = env.MustRunRibbin(env.ProjectDir, "metrics")
+	env.AssertOutputContains(output, "Metrics are enabled")
+	env.AssertOutputContains(output, "No interceptions counted")
+}
+
+func TestArgPathPatterns(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	configPath := env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "rm": {
+      "action": "block",
+      "message": "Use terraform state rm",
+      "argPathPatterns": ["*.tfstate", "migrations/**"]
+    }
+  }
+}`)
+	registry := env.NewRegistry()
+	registry.GlobalActive = true
+	rmPath := env.CreateMockBinaryWithOutput(env.BinDir, "rm", "removed")
+	if err := wrap.Install(rmPath, env.RibbinPath, registry, configPath); err != nil {
+		t.Fatalf("failed to install shim: %v", err)
+	}
+	env.SaveRegistry(registry)
+	env.ChdirProject()
+	infraDir := env.CreateDir("project/infra")
+
+	run := func(dir string, args ...string) (string, error) {
+		t.Helper()
+		cmd := exec.Command("rm", args...)
+		cmd.Dir = dir
+		cmd.Env = env.Environ()
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+
+	output, err := run(infraDir, "-f", "prod.tfstate")
+	if err == nil {
+		t.Fatalf("rm of a state file should be blocked, got: %s", output)
+	}
+	env.AssertOutputContains(output, "Use terraform state rm")
+
+	output, err = run(infraDir, "-f", "../migrations/001.sql")
+	if err == nil {
+		t.Fatalf("rm of a migration should be blocked, got: %s", output)
+	}
+
+	output, err = run(infraDir, "-rf", "build")
+	if err != nil {
+		t.Fatalf("rm of other files should pass through: %v\n%s", err, output)
+	}
+	env.AssertOutputContains(output, "removed")
+
+	output = env.MustRunRibbin(infraDir, "which", "rm", "--", "-f", "prod.tfstate")
+	env.AssertOutputContains(output, `"prod.tfstate" matches`)
+	output = env.MustRunRibbin(infraDir, "which", "rm", "--", "-rf", "build")
+	env.AssertOutputContains(output, "no argument matches")
+}
+
+// TestDirectoryLayoutSidecars tests keeping originals in the content-addressed
+// sidecar store, beside a file that happens to be named like a sidecar.
+func TestDirectoryLayoutSidecars(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	terraformPath := env.CreateMockBinaryWithOutput(env.BinDir, "terraform", "real terraform")
+	backup := terraformPath + ".ribbin-original"
+	if err := os.WriteFile(backup, []byte("my backup"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	env.CreateBlockConfig(env.ProjectDir, "terraform", "Use the deploy pipeline", []string{terraformPath})
+
+	// The adjacent layout refuses to touch the backup
+	output, err := env.RunRibbin(env.ProjectDir, "wrap")
+	if err == nil {
+		t.Fatalf("wrap should fail over the backup, got: %s", output)
+	}
+	env.AssertOutputContains(output, "isn't a ribbin sidecar")
+
+	settingsDir := filepath.Join(env.HomeDir, ".config", "ribbin")
+	if err := os.MkdirAll(settingsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(settingsDir, "settings.jsonc"), []byte(`{"sidecarLayout": "directory"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env.MustRunRibbin(env.ProjectDir, "wrap")
+	env.MustRunRibbin(env.ProjectDir, "activate", "--global")
+	env.AssertSymlink(terraformPath, env.RibbinPath)
+
+	stored, _ := filepath.Glob(filepath.Join(env.BinDir, ".ribbin", "sidecars", "*", "terraform"))
+	if len(stored) != 1 {
+		t.Fatalf("expected one stored sidecar, got %v", stored)
+	}
+
+	cmd := exec.Command("terraform", "apply")
+	cmd.Dir = env.ProjectDir
+	cmd.Env = env.Environ()
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("terraform should be blocked, got: %s", out)
+	}
+	env.AssertOutputContains(string(out), "Use the deploy pipeline")
+
+	cmd = exec.Command("terraform", "apply")
+	cmd.Dir = env.ProjectDir
+	cmd.Env = env.EnvironWith("RIBBIN_BYPASS=1")
+	out, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("bypassed terraform failed: %v\n%s", err, out)
+	}
+	env.AssertOutputContains(string(out), "real terraform")
+
+	output = env.MustRunRibbin(env.ProjectDir, "find", env.BinDir)
+	env.AssertOutputContains(output, "Known Wrapped Binaries")
+
+	env.MustRunRibbin(env.ProjectDir, "unwrap")
+	env.AssertFileNotExists(filepath.Join(env.BinDir, ".ribbin"))
+	if data, _ := os.ReadFile(backup); string(data) != "my backup" {
+		t.Errorf("backup was changed: %q", data)
+	}
+	cmd = exec.Command("terraform")
+	cmd.Env = env.Environ()
+	out, _ = cmd.CombinedOutput()
+	env.AssertOutputContains(string(out), "real terraform")
+}
+
+// TestVerifyCommand tests 'ribbin verify' failing until every wrapper target
+// is wrapped and active.
+func TestVerifyCommand(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	npmPath := env.CreateMockBinary(env.BinDir, "npm")
+	env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "npm": { "action": "block", "message": "Use pnpm", "paths": ["`+npmPath+`"] },
+    "yarn": { "action": "block", "message": "Use pnpm" }
+  }
+}`)
+
+	verify := func(args ...string) (string, int) {
+		t.Helper()
+		cmd := exec.Command(env.RibbinPath, append([]string{"verify"}, args...)...)
+		cmd.Dir = env.ProjectDir
+		cmd.Env = env.Environ()
+		output, _ := cmd.CombinedOutput()
+		return string(output), cmd.ProcessState.ExitCode()
+	}
+
+	output, code := verify()
+	if code != 3 {
+		t.Fatalf("verify before wrapping exited %d, want 3:\n%s", code, output)
+	}
+	env.AssertOutputContains(output, "npm: not wrapped ("+npmPath+")")
+	env.AssertOutputContains(output, "yarn: not found on PATH")
+
+	env.MustRunRibbin(env.ProjectDir, "wrap")
+	output, code = verify()
+	if code != 3 {
+		t.Fatalf("verify before activating exited %d, want 3:\n%s", code, output)
+	}
+	env.AssertOutputContains(output, "npm: inactive")
+
+	env.MustRunRibbin(env.ProjectDir, "activate")
+	output, code = verify()
+	if code != 0 {
+		t.Fatalf("verify exited %d:\n%s", code, output)
+	}
+	env.AssertOutputContains(output, "All 2 wrapper target(s) are in effect")
+
+	output, _ = verify("--json")
+	env.AssertOutputContains(output, `"ok": true`)
+	env.AssertOutputContains(output, `"status": "wrapped"`)
+}
+
+// TestWatchCommand tests 'ribbin watch' wrapping binaries again after a
+// reinstall replaces their wrappers.
+func TestWatchCommand(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	npmPath := env.CreateMockBinary(env.BinDir, "npm")
+	env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "npm": { "action": "block", "message": "Use pnpm", "paths": ["`+npmPath+`"] }
+  }
+}`)
+
+	// reinstall replaces the wrapper with a fresh binary, as npm would
+	reinstall := func() {
+		t.Helper()
+		if err := os.Remove(npmPath); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(npmPath, []byte("#!/bin/sh\necho reinstalled npm\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	isWrapped := func() bool {
+		info, err := os.Lstat(npmPath)
+		return err == nil && info.Mode()&os.ModeSymlink != 0
+	}
+
+	output := env.MustRunRibbin(env.ProjectDir, "watch", "--once")
+	env.AssertOutputContains(output, "Wrapped '"+npmPath+"'")
+	if !isWrapped() {
+		t.Fatal("watch --once should wrap npm")
+	}
+
+	reinstall()
+	cmd := exec.Command(env.RibbinPath, "watch", "--interval", "100ms")
+	cmd.Dir = env.ProjectDir
+	cmd.Env = env.Environ()
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	waitFor := func(what string) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for !isWrapped() {
+			if time.Now().After(deadline) {
+				cmd.Process.Kill()
+				cmd.Wait()
+				t.Fatalf("watch did not wrap npm %s:\n%s", what, out.String())
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	waitFor("on start")
+
+	reinstall()
+	waitFor("after a reinstall")
+
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("watch should exit cleanly on interrupt: %v\n%s", err, out.String())
+	}
+	env.AssertOutputContains(out.String(), "Stopped watching")
+
+	content, _ := os.ReadFile(npmPath + ".ribbin-original")
+	if !strings.Contains(string(content), "reinstalled npm") {
+		t.Errorf("the sidecar should hold the reinstalled npm, got %q", content)
+	}
+}
+
+// TestSecuritySettings tests a user's security settings forbidding a
+// directory, and 'ribbin security show' listing it.
+func TestSecuritySettings(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	vendorDir := env.CreateDir("vendor/bin")
+	npmPath := env.CreateMockBinary(vendorDir, "npm")
+	env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "npm": { "action": "block", "message": "Use pnpm", "paths": ["`+npmPath+`"] }
+  }
+}`)
+
+	settingsDir := filepath.Join(env.HomeDir, ".config", "ribbin")
+	if err := os.MkdirAll(settingsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	settings := `{"allowedDirs": ["/srv/tools/bin"], "forbiddenDirs": ["` + vendorDir + `"]}`
+	if err := os.WriteFile(filepath.Join(settingsDir, "security.jsonc"), []byte(settings), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := env.MustRunRibbin(env.ProjectDir, "security", "show")
+	env.AssertOutputContains(output, "/srv/tools/bin")
+	env.AssertOutputContains(output, vendorDir)
+	env.AssertOutputContains(output, "sudo")
+
+	output, err := env.RunRibbin(env.ProjectDir, "wrap")
+	if err == nil {
+		t.Fatalf("wrapping in a forbidden directory should fail:\n%s", output)
+	}
+	env.AssertOutputContains(output, "forbidden by your security settings")
+	env.AssertNotSymlink(npmPath)
+}
+
+// TestCopyShimMode verifies wrapping with copies of ribbin instead of
+// symlinks, for filesystems without symlink support
+func TestCopyShimMode(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	terraformPath := env.CreateMockBinaryWithOutput(env.BinDir, "terraform", "real terraform")
+	env.CreateBlockConfig(env.ProjectDir, "terraform", "Use the deploy pipeline", []string{terraformPath})
+
+	settingsDir := filepath.Join(env.HomeDir, ".config", "ribbin")
+	if err := os.MkdirAll(settingsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(settingsDir, "settings.jsonc"), []byte(`{"shimMode": "copy"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env.MustRunRibbin(env.ProjectDir, "wrap")
+	env.MustRunRibbin(env.ProjectDir, "activate", "--global")
+	env.AssertNotSymlink(terraformPath)
+	env.AssertFileExists(terraformPath + ".ribbin-original")
+
+	cmd := exec.Command("terraform", "apply")
+	cmd.Dir = env.ProjectDir
+	cmd.Env = env.Environ()
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("terraform should be blocked, got: %s", out)
+	}
+	env.AssertOutputContains(string(out), "Use the deploy pipeline")
+
+	cmd = exec.Command("terraform", "apply")
+	cmd.Dir = env.ProjectDir
+	cmd.Env = env.EnvironWith("RIBBIN_BYPASS=1")
+	out, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("bypassed terraform failed: %v\n%s", err, out)
+	}
+	env.AssertOutputContains(string(out), "real terraform")
+
+	output := env.MustRunRibbin(env.ProjectDir, "verify")
+	env.AssertOutputNotContains(output, "not wrapped")
+
+	env.MustRunRibbin(env.ProjectDir, "unwrap")
+	env.AssertFileNotExists(terraformPath + ".ribbin-original")
+	env.AssertFileNotExists(terraformPath + ".ribbin-meta")
+	out, err = exec.Command(terraformPath).CombinedOutput()
+	if err != nil {
+		t.Fatalf("restored terraform failed: %v\n%s", err, out)
+	}
+	env.AssertOutputContains(string(out), "real terraform")
+}
+
+// TestWrapPathsFrom verifies wrapping binaries listed on stdin, under the
+// config's wrapper for their name or a block wrapper added for them
+func TestWrapPathsFrom(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	otherDir := env.CreateDir("other/bin")
+	npmPath := env.CreateMockBinary(env.BinDir, "npm")
+	otherNpmPath := env.CreateMockBinary(otherDir, "npm")
+	nodePath := env.CreateMockBinaryWithOutput(otherDir, "node", "real node")
+	configPath := env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "npm": { "action": "block", "message": "Use pnpm" }
+  }
+}`)
+
+	cmd := exec.Command(env.RibbinPath, "wrap", "--paths-from", "-", "--message", "Use the node from mise")
+	cmd.Dir = env.ProjectDir
+	cmd.Env = env.Environ()
+	cmd.Stdin = strings.NewReader(npmPath + "\n" + otherNpmPath + "\n# from which -a node\n" + nodePath + "\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("wrap --paths-from failed: %v\n%s", err, out)
+	}
+	env.AssertOutputContains(string(out), "Added a block wrapper for 'node'")
+	for _, path := range []string{npmPath, otherNpmPath, nodePath} {
+		env.AssertSymlink(path, env.RibbinPath)
+	}
+
+	cfg, err := config.LoadProjectConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, ok := cfg.Wrappers["node"]
+	if !ok || node.Action != "block" || node.Message != "Use the node from mise" || len(node.Paths) != 1 || node.Paths[0] != nodePath {
+		t.Errorf("expected a block wrapper for node, got %+v", cfg.Wrappers)
+	}
+	if len(cfg.Wrappers["npm"].Paths) != 0 {
+		t.Errorf("the existing npm wrapper should be unchanged, got %+v", cfg.Wrappers["npm"])
+	}
+
+	env.MustRunRibbin(env.ProjectDir, "activate", "--global")
+	cmd = exec.Command(nodePath)
+	cmd.Dir = env.ProjectDir
+	cmd.Env = env.Environ()
+	out, err = cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("node should be blocked, got: %s", out)
+	}
+	env.AssertOutputContains(string(out), "Use the node from mise")
+
+	output, err := env.RunRibbin(env.ProjectDir, "wrap", "--paths-from", filepath.Join(env.ProjectDir, "missing.txt"))
+	if err == nil {
+		t.Fatalf("a missing list should fail:\n%s", output)
+	}
+}
+
+// TestWrapHardLinkedBinary verifies that a binary with other hard links is
+// only wrapped with --force
+func TestWrapHardLinkedBinary(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	nodePath := env.CreateMockBinary(env.BinDir, "node")
+	storePath := filepath.Join(env.CreateDir("store"), "node")
+	if err := os.Link(nodePath, storePath); err != nil {
+		t.Fatal(err)
+	}
+	env.CreateBlockConfig(env.ProjectDir, "node", "Use the node from mise", []string{nodePath})
+
+	output, err := env.RunRibbin(env.ProjectDir, "wrap")
+	if err == nil {
+		t.Fatalf("wrapping a hard-linked binary should need --force:\n%s", output)
+	}
+	env.AssertOutputContains(output, "has 2 hard links")
+	env.AssertNotSymlink(nodePath)
+
+	output = env.MustRunRibbin(env.ProjectDir, "wrap", "--force")
+	env.AssertOutputContains(output, "only this one is wrapped")
+	env.AssertSymlink(nodePath, env.RibbinPath)
+	env.MustRunRibbin(env.ProjectDir, "unwrap")
+	env.AssertNotSymlink(nodePath)
+}
+
+// TestRenamedRibbin verifies that ribbin run under another name explains
+// itself, and that RIBBIN_FORCE_CLI=1 makes it the CLI
+func TestRenamedRibbin(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.BuildRibbin("")
+
+	renamed := filepath.Join(env.BinDir, "ribbin@1.2")
+	if err := os.Symlink(env.RibbinPath, renamed); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := env.RunCmd(env.ProjectDir, renamed, "status")
+	if err == nil {
+		t.Fatalf("a renamed ribbin should not run its commands:\n%s", output)
+	}
+	env.AssertOutputContains(output, "not a wrapper, but ribbin itself")
+	env.AssertOutputNotContains(output, "original binary not found")
+
+	cmd := exec.Command(renamed, "status")
+	cmd.Dir = env.ProjectDir
+	cmd.Env = env.EnvironWith("RIBBIN_FORCE_CLI=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("RIBBIN_FORCE_CLI=1 %s status failed: %v\n%s", renamed, err, out)
+	}
+	env.AssertOutputContains(string(out), "Ribbin Status")
+}
+
+func TestOnlyFrom(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	configPath := env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "tsc": {
+      "action": "passthrough",
+      "onlyFrom": ["buildtool"]
+    }
+  }
+}`)
+	registry := env.NewRegistry()
+	registry.GlobalActive = true
+	tscPath := env.CreateMockBinaryWithOutput(env.BinDir, "tsc", "compiled")
+	if err := wrap.Install(tscPath, env.RibbinPath, registry, configPath); err != nil {
+		t.Fatalf("failed to install shim: %v", err)
+	}
+	env.SaveRegistry(registry)
+	// A build tool runs tsc as a child, so it is one of tsc's ancestors
+	buildtool := filepath.Join(env.BinDir, "buildtool")
+	if err := os.WriteFile(buildtool, []byte("#!/bin/sh\ntsc \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	env.ChdirProject()
+
+	output, err := env.RunCmd(env.ProjectDir, "tsc")
+	if err == nil {
+		t.Fatalf("tsc run directly should be blocked, got: %s", output)
+	}
+	env.AssertOutputContains(output, "'tsc' may only be run by buildtool.")
+
+	output = env.MustRunCmd(env.ProjectDir, "buildtool")
+	env.AssertOutputContains(output, "compiled")
+
+	output = env.MustRunRibbin(env.ProjectDir, "which", "tsc")
+	env.AssertOutputContains(output, "no ancestor of this shell is buildtool")
+}
+
+func TestSchedule(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	configPath := env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "deploy": {
+      "action": "block",
+      "message": "Deploys are frozen",
+      "when": {"schedule": ["sun-sat"]}
+    },
+    "release": {
+      "action": "block",
+      "message": "Releases are frozen",
+      "when": {"schedule": ["2000-12-20..2001-01-02"], "timezone": "America/New_York"}
+    }
+  }
+}`)
+	registry := env.NewRegistry()
+	registry.GlobalActive = true
+	for _, name := range []string{"deploy", "release"} {
+		path := env.CreateMockBinaryWithOutput(env.BinDir, name, name+" ran")
+		if err := wrap.Install(path, env.RibbinPath, registry, configPath); err != nil {
+			t.Fatalf("failed to install shim: %v", err)
+		}
+	}
+	env.SaveRegistry(registry)
+	env.ChdirProject()
+
+	output, err := env.RunCmd(env.ProjectDir, "deploy")
+	if err == nil {
+		t.Fatalf("deploy should be blocked every day, got: %s", output)
+	}
+	env.AssertOutputContains(output, "Deploys are frozen")
+
+	output = env.MustRunCmd(env.ProjectDir, "release")
+	env.AssertOutputContains(output, "release ran")
+
+	output = env.MustRunRibbin(env.ProjectDir, "which", "release")
+	env.AssertOutputContains(output, "no entry matches")
+}
+
+func TestDirenvActivation(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	configPath := env.CreateConfig(env.ProjectDir, `{
+  "wrappers": {
+    "deploy": {
+      "action": "block",
+      "message": "Deploy through CI"
+    }
+  }
+}`)
+	registry := env.NewRegistry()
+	path := env.CreateMockBinaryWithOutput(env.BinDir, "deploy", "deploy ran")
+	if err := wrap.Install(path, env.RibbinPath, registry, configPath); err != nil {
+		t.Fatalf("failed to install shim: %v", err)
+	}
+	env.SaveRegistry(registry)
+	env.ChdirProject()
+
+	// Nothing is activated, so the command runs
+	output := env.MustRunCmd(env.ProjectDir, "deploy")
+	env.AssertOutputContains(output, "deploy ran")
+
+	env.MustRunRibbin(env.ProjectDir, "direnv", "--write")
+	envrc, err := os.ReadFile(filepath.Join(env.ProjectDir, ".envrc"))
+	if err != nil {
+		t.Fatalf("ribbin direnv --write didn't create .envrc: %v", err)
+	}
+
+	// Load the .envrc as direnv would, with stand-ins for its standard library
+	load := `has() { command -v "$1" >/dev/null; }
+watch_file() { :; }
+PATH_add() { PATH="$1:$PATH"; }
+ribbin() { "` + env.RibbinPath + `" "$@"; }
+` + string(envrc)
+	output, err = env.RunCmd(env.ProjectDir, "bash", "-c", load+"deploy")
+	if err == nil {
+		t.Fatalf("deploy should be blocked once the .envrc is loaded, got: %s", output)
+	}
+	env.AssertOutputContains(output, "Deploy through CI")
+
+	output = env.MustRunCmd(env.ProjectDir, "bash", "-c", load+"ribbin which deploy")
+	env.AssertOutputContains(output, "environment activation (RIBBIN_ACTIVATE)")
+}
+
+// TestRequiredWrappers tests that a required command run unwrapped, through
+// the sentinel 'ribbin direnv' sets up, says how to set up the project, and
+// that 'ribbin verify' fails until it is wrapped
+func TestRequiredWrappers(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	env.CreateConfig(env.ProjectDir, `{
+  "require": { "wrapped": ["tsc"] },
+  "wrappers": {
+    "tsc": { "action": "block", "message": "Use pnpm tsc" }
+  }
+}`)
+	env.CreateMockBinaryWithOutput(env.BinDir, "tsc", "tsc ran")
+	env.SaveRegistry(env.NewRegistry())
+	env.ChdirProject()
+
+	output, err := env.RunRibbin(env.ProjectDir, "verify")
+	if err == nil {
+		t.Fatalf("verify should fail while tsc isn't wrapped, got: %s", output)
+	}
+	env.AssertOutputContains(output, "tsc: required, not wrapped")
+
+	// The exports create the sentinel and put it on PATH
+	exports := env.MustRunRibbin(env.ProjectDir, "direnv", "--export")
+	load := `PATH_add() { PATH="$1:$PATH"; }
+watch_file() { :; }
+` + exports
+	output = env.MustRunCmd(env.ProjectDir, "bash", "-c", load+"tsc --version")
+	env.AssertOutputContains(output, "tsc ran")
+	env.AssertOutputContains(output, "tsc is not wrapped")
+	env.AssertOutputContains(output, "Run 'ribbin wrap' in "+env.ProjectDir)
+
+	// Once wrapped, the sentinel runs the wrapper without a word
+	env.MustRunRibbin(env.ProjectDir, "wrap")
+	output, err = env.RunCmd(env.ProjectDir, "bash", "-c", load+"tsc --version")
+	if err == nil {
+		t.Fatalf("tsc should be blocked once wrapped and activated by the exports, got: %s", output)
+	}
+	env.AssertOutputContains(output, "Use pnpm tsc")
+	env.AssertOutputNotContains(output, "is not wrapped")
+}
+
+func TestRescue(t *testing.T) {
+	env := testutil.SetupIntegrationEnv(t)
+	env.SetPathWithBinDir()
+	env.BuildRibbin("")
+
+	configPath := env.CreateConfig(env.ProjectDir, `{
+  "enforce": true,
+  "wrappers": {
+    "git": { "action": "block", "message": "a bad config" }
+  }
+}`)
+	registry := env.NewRegistry()
+	registry.GlobalActive = true
+	if err := wrap.Install(env.CreateMockBinaryWithOutput(env.BinDir, "git", "git ran"), env.RibbinPath, registry, configPath); err != nil {
+		t.Fatalf("failed to install shim: %v", err)
+	}
+	env.SaveRegistry(registry)
+	env.ChdirProject()
+
+	// Enforcement ignores RIBBIN_BYPASS, but not RIBBIN_RESCUE
+	cmd := exec.Command("git", "status")
+	cmd.Env = env.EnvironWith("RIBBIN_BYPASS=1")
+	if output, err := cmd.CombinedOutput(); err == nil {
+		t.Fatalf("git should be blocked under enforcement, got: %s", output)
+	}
+	cmd = exec.Command("git", "status")
+	cmd.Env = env.EnvironWith("RIBBIN_RESCUE=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("RIBBIN_RESCUE=1 should run git: %v\n%s", err, out)
+	}
+	output := string(out)
+	env.AssertOutputContains(output, "git ran")
+	env.AssertOutputContains(output, "RIBBIN_RESCUE=1 overrides enforcement by "+configPath)
+
+	output = env.MustRunRibbin(env.ProjectDir, "audit", "show", "--type", "rescue.used")
+	env.AssertOutputContains(output, "rescue.used")
+	env.AssertOutputContains(output, "args=status")
+	env.AssertOutputContains(output, "enforced_by="+configPath)
+	output = env.MustRunRibbin(env.ProjectDir, "audit", "show", "--type", "security.violation")
+	env.AssertOutputContains(output, "rescue_while_enforced")
+}
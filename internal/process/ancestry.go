@@ -0,0 +1,137 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ancestors caches the walk up the process tree for the life of the process.
+// A wrapper may match passthrough rules against its ancestors several times
+// per invocation, and each step of the walk reads /proc or spawns ps. The
+// tree is walked lazily, only as deep as callers have asked for.
+var ancestors struct {
+	sync.Mutex
+	started bool
+	done    bool     // reached the top of the tree (or a process we can't read)
+	next    int      // PID of the next ancestor to record
+	cmds    []string // command line of each ancestor, nearest first ("" if unreadable)
+}
+
+// GetAncestorCommands walks up the process tree and returns command strings.
+// maxDepth of 0 means unlimited. Returns commands from nearest (parent) to farthest.
+// Results are cached, so repeated calls only walk levels not yet seen.
+func GetAncestorCommands(maxDepth int) ([]string, error) {
+	ancestors.Lock()
+	defer ancestors.Unlock()
+
+	if !ancestors.started {
+		ancestors.started = true
+		ppid, err := getParentPID(os.Getpid())
+		if err != nil {
+			ancestors.done = true
+		}
+		ancestors.next = ppid
+	}
+
+	for !ancestors.done && (maxDepth <= 0 || len(ancestors.cmds) < maxDepth) {
+		pid := ancestors.next
+		if pid < 1 {
+			ancestors.done = true
+			break
+		}
+
+		ppid, cmd, err := processInfo(pid)
+		ancestors.cmds = append(ancestors.cmds, cmd)
+		if err != nil || pid == 1 {
+			ancestors.done = true
+		}
+		ancestors.next = ppid
+	}
+
+	levels := ancestors.cmds
+	if maxDepth > 0 && len(levels) > maxDepth {
+		levels = levels[:maxDepth]
+	}
+
+	var commands []string
+	for _, cmd := range levels {
+		if cmd != "" {
+			commands = append(commands, cmd)
+		}
+	}
+	return commands, nil
+}
+
+// resetAncestorCache discards the cached process tree walk. Used by tests.
+func resetAncestorCache() {
+	ancestors.Lock()
+	defer ancestors.Unlock()
+	ancestors.started = false
+	ancestors.done = false
+	ancestors.next = 0
+	ancestors.cmds = nil
+}
+
+// interpreters run a script named by their first non-flag argument. Their
+// command lines hide the tool actually running, e.g. pnpm shows up as
+// "node /usr/lib/node_modules/pnpm/bin/pnpm.cjs run build".
+var interpreters = map[string]bool{
+	"node": true, "nodejs": true, "bun": true, "deno": true,
+	"python": true, "python3": true, "ruby": true, "perl": true,
+}
+
+// shells run a command string with -c
+var shells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ash": true, "fish": true,
+}
+
+// scriptExtensions are stripped from script names revealed behind an interpreter
+var scriptExtensions = []string{".js", ".cjs", ".mjs", ".ts", ".py", ".rb", ".pl", ".sh"}
+
+// NormalizeCommand rewrites a process command line into the form a user would
+// have typed, so passthrough patterns like "pnpm run" match however the tool
+// was launched:
+//
+//	/usr/local/bin/pnpm run build                    -> pnpm run build
+//	node /usr/lib/node_modules/pnpm/bin/pnpm.cjs run -> pnpm run
+//	node /usr/lib/node_modules/npm/bin/npm-cli.js ci -> npm ci
+//	sh -c pnpm run build                             -> pnpm run build
+//	/bin/sh /usr/local/bin/buildtool --release       -> buildtool --release
+//	-bash                                            -> bash
+//
+// Returns the command unchanged if there is nothing to normalize.
+func NormalizeCommand(cmd string) string {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return cmd
+	}
+
+	// Login shells are shown as "-bash"
+	name := strings.TrimPrefix(filepath.Base(fields[0]), "-")
+	rest := fields[1:]
+
+	switch {
+	case shells[name] && len(rest) >= 2 && rest[0] == "-c":
+		return NormalizeCommand(strings.Join(rest[1:], " "))
+	case interpreters[name] || shells[name]:
+		for i, arg := range rest {
+			if strings.HasPrefix(arg, "-") {
+				continue
+			}
+			script := filepath.Base(arg)
+			for _, ext := range scriptExtensions {
+				if trimmed := strings.TrimSuffix(script, ext); trimmed != script {
+					script = trimmed
+					break
+				}
+			}
+			// npm's entry point is npm-cli.js
+			script = strings.TrimSuffix(script, "-cli")
+			return strings.Join(append([]string{script}, rest[i+1:]...), " ")
+		}
+	}
+
+	return strings.Join(append([]string{name}, rest...), " ")
+}
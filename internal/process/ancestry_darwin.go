@@ -99,31 +99,20 @@ func getCommandForPID(pid int) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-// GetAncestorCommands walks up the process tree and returns command strings.
-// maxDepth of 0 means unlimited. Returns commands from nearest (parent) to farthest.
-func GetAncestorCommands(maxDepth int) ([]string, error) {
-	var commands []string
-	currentPID := os.Getpid()
-	depth := 0
-
-	for currentPID > 1 {
-		parentPID, err := getParentPID(currentPID)
-		if err != nil {
-			break // Can't continue up the tree
-		}
-
-		cmd, err := getCommandForPID(parentPID)
-		if err == nil && cmd != "" {
-			commands = append(commands, cmd)
-		}
-
-		depth++
-		if maxDepth > 0 && depth >= maxDepth {
-			break
-		}
-
-		currentPID = parentPID
+// processInfo returns the parent PID and command line of pid with a single ps
+// call, since spawning ps dominates the cost of walking the process tree.
+// The command is empty if it can't be read; err reports whether the parent
+// could be found.
+func processInfo(pid int) (ppid int, cmd string, err error) {
+	output, err := exec.Command("ps", "-o", "ppid=,command=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return 0, "", err
 	}
 
-	return commands, nil
+	ppidStr, cmd, _ := strings.Cut(strings.TrimSpace(string(output)), " ")
+	ppid, err = strconv.Atoi(ppidStr)
+	if err != nil {
+		return 0, "", err
+	}
+	return ppid, strings.TrimSpace(cmd), nil
 }
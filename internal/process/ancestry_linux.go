@@ -106,31 +106,10 @@ func getCommandForPID(pid int) (string, error) {
 	return strings.TrimSpace(cmdline), nil
 }
 
-// GetAncestorCommands walks up the process tree and returns command strings.
-// maxDepth of 0 means unlimited. Returns commands from nearest (parent) to farthest.
-func GetAncestorCommands(maxDepth int) ([]string, error) {
-	var commands []string
-	currentPID := os.Getpid()
-	depth := 0
-
-	for currentPID > 1 {
-		parentPID, err := getParentPID(currentPID)
-		if err != nil {
-			break // Can't continue up the tree
-		}
-
-		cmd, err := getCommandForPID(parentPID)
-		if err == nil && cmd != "" {
-			commands = append(commands, cmd)
-		}
-
-		depth++
-		if maxDepth > 0 && depth >= maxDepth {
-			break
-		}
-
-		currentPID = parentPID
-	}
-
-	return commands, nil
+// processInfo returns the parent PID and command line of pid. The command is
+// empty if it can't be read; err reports whether the parent could be found.
+func processInfo(pid int) (ppid int, cmd string, err error) {
+	cmd, _ = getCommandForPID(pid)
+	ppid, err = getParentPID(pid)
+	return ppid, cmd, err
 }
@@ -4,6 +4,7 @@ package process
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	_ "github.com/happycollision/ribbin/internal/testsafety"
@@ -233,3 +234,60 @@ func TestGetCommandForPID(t *testing.T) {
 		}
 	})
 }
+
+func TestGetAncestorCommandsCache(t *testing.T) {
+	resetAncestorCache()
+	defer resetAncestorCache()
+
+	shallow, err := GetAncestorCommands(1)
+	if err != nil {
+		t.Fatalf("GetAncestorCommands(1) error: %v", err)
+	}
+	full, err := GetAncestorCommands(0)
+	if err != nil {
+		t.Fatalf("GetAncestorCommands(0) error: %v", err)
+	}
+	if len(shallow) > 0 && (len(full) == 0 || full[0] != shallow[0]) {
+		t.Errorf("unlimited walk %v should extend the depth-1 walk %v", full, shallow)
+	}
+
+	again, err := GetAncestorCommands(0)
+	if err != nil {
+		t.Fatalf("GetAncestorCommands(0) error: %v", err)
+	}
+	if strings.Join(again, "\n") != strings.Join(full, "\n") {
+		t.Errorf("cached walk %v differs from first walk %v", again, full)
+	}
+
+	limited, _ := GetAncestorCommands(1)
+	if strings.Join(limited, "\n") != strings.Join(shallow, "\n") {
+		t.Errorf("depth-1 walk from cache %v differs from %v", limited, shallow)
+	}
+}
+
+func TestNormalizeCommand(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"pnpm run build", "pnpm run build"},
+		{"/usr/local/bin/pnpm run build", "pnpm run build"},
+		{"node /usr/lib/node_modules/pnpm/bin/pnpm.cjs run typecheck", "pnpm run typecheck"},
+		{"node --enable-source-maps /repo/node_modules/.bin/tsc --noEmit", "tsc --noEmit"},
+		{"/bin/sh -c pnpm run build", "pnpm run build"},
+		{"sh -c node /usr/lib/node_modules/npm/bin/npm-cli.js test", "npm test"},
+		{"-bash", "bash"},
+		{"/bin/sh /usr/local/bin/buildtool --release", "buildtool --release"},
+		{"bash deploy.sh prod", "deploy prod"},
+		{"bash -l", "bash -l"},
+		{"python3 /usr/bin/poetry.py install", "poetry install"},
+		{"node", "node"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeCommand(tt.cmd); got != tt.want {
+			t.Errorf("NormalizeCommand(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+//go:build darwin
+
+package process
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// FileUsers returns the processes, other than this one, that are running the
+// file at path or have it open. It is best effort, using lsof, which only
+// sees another user's processes when run as root.
+func FileUsers(path string) ([]int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	out, err := exec.Command("lsof", "-t", "--", path).Output()
+	if err != nil {
+		// lsof exits 1 when nothing has the file open
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(out) == 0 {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	self := os.Getpid()
+	var pids []int
+	for _, field := range strings.Fields(string(out)) {
+		if pid, err := strconv.Atoi(field); err == nil && pid != self {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
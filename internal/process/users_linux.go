@@ -0,0 +1,54 @@
+//go:build linux
+
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// FileUsers returns the processes, other than this one, that are running the
+// file at path or have it open. It is best effort: /proc only shows another
+// user's open files to root.
+func FileUsers(path string) ([]int, error) {
+	target, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	self := os.Getpid()
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		if usesFile(filepath.Join("/proc", entry.Name()), target) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// usesFile reports whether the process at procDir is running target or has
+// it open
+func usesFile(procDir string, target os.FileInfo) bool {
+	if info, err := os.Stat(filepath.Join(procDir, "exe")); err == nil && os.SameFile(info, target) {
+		return true
+	}
+	fds, err := os.ReadDir(filepath.Join(procDir, "fd"))
+	if err != nil {
+		return false
+	}
+	for _, fd := range fds {
+		if info, err := os.Stat(filepath.Join(procDir, "fd", fd.Name())); err == nil && os.SameFile(info, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+//go:build linux || darwin
+
+package process
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestFileUsers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tool")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Holding the file open ourselves doesn't count
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if pids, err := FileUsers(path); err != nil || len(pids) != 0 {
+		t.Fatalf("FileUsers = %v, %v; want none", pids, err)
+	}
+
+	cmd := exec.Command("sh", "-c", "exec 3<\"$0\"; sleep 10", path)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pids, err := FileUsers(path)
+		if err != nil {
+			t.Fatalf("FileUsers error: %v", err)
+		}
+		if slices.Contains(pids, cmd.Process.Pid) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("FileUsers = %v, want to include %d", pids, cmd.Process.Pid)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if _, err := FileUsers(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,238 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// AcknowledgedDirsFileName records, in ribbin's state directory, the shared
+// directories the user has agreed to wrap in, with a listing of each taken
+// at the time
+const AcknowledgedDirsFileName = "acknowledged-dirs.json"
+
+// acknowledgedDirsTimeout bounds waiting for the acknowledgment state lock
+const acknowledgedDirsTimeout = 2 * time.Second
+
+// DirAcknowledgment is the user agreeing to wrap in a shared directory
+type DirAcknowledgment struct {
+	// AcknowledgedAt is when the user agreed
+	AcknowledgedAt time.Time `json:"acknowledged_at"`
+	// Listing is the directory's entries just before anything was wrapped
+	// there, to compare against later (see DiffDirListing)
+	Listing []DirEntryInfo `json:"listing"`
+}
+
+// DirEntryInfo describes one entry of a directory listing
+type DirEntryInfo struct {
+	Name string `json:"name"`
+	// Mode is the entry's file mode, as ls shows it
+	Mode string `json:"mode"`
+	Size int64  `json:"size"`
+	// Target is where a symlink points
+	Target string `json:"target,omitempty"`
+	// SHA256 is the content hash of a regular file
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+type acknowledgedDirsState struct {
+	Dirs map[string]DirAcknowledgment `json:"dirs"`
+}
+
+// AcknowledgedDirsPath returns the path of the acknowledgment state file
+func AcknowledgedDirsPath() (string, error) {
+	stateDir, err := GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, AcknowledgedDirsFileName), nil
+}
+
+// LoadAcknowledgedDirs returns the acknowledged directories by path
+func LoadAcknowledgedDirs() (map[string]DirAcknowledgment, error) {
+	statePath, err := AcknowledgedDirsPath()
+	if err != nil {
+		return nil, err
+	}
+	state, err := readAcknowledgedDirs(statePath)
+	if err != nil {
+		return nil, err
+	}
+	return state.Dirs, nil
+}
+
+func readAcknowledgedDirs(statePath string) (*acknowledgedDirsState, error) {
+	state := &acknowledgedDirsState{Dirs: map[string]DirAcknowledgment{}}
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("corrupt acknowledgment file %s: %w", statePath, err)
+	}
+	if state.Dirs == nil {
+		state.Dirs = map[string]DirAcknowledgment{}
+	}
+	return state, nil
+}
+
+// IsDirAcknowledged reports whether the user has agreed to wrap in dir. A
+// state file that can't be read counts as not agreed.
+func IsDirAcknowledged(dir string) bool {
+	dirs, err := LoadAcknowledgedDirs()
+	if err != nil {
+		return false
+	}
+	_, ok := dirs[filepath.Clean(dir)]
+	return ok
+}
+
+// AcknowledgeDir records that the user agreed to wrap in dir, with a
+// listing of it as it is now. Acknowledging a directory again keeps the
+// first listing.
+func AcknowledgeDir(dir string) (*DirAcknowledgment, error) {
+	dir = filepath.Clean(dir)
+	listing, err := ListDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := EnsureStateDir(); err != nil {
+		return nil, err
+	}
+	statePath, err := AcknowledgedDirsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	ack := DirAcknowledgment{AcknowledgedAt: time.Now().UTC(), Listing: listing}
+	err = WithLock(statePath, acknowledgedDirsTimeout, func() error {
+		state, err := readAcknowledgedDirs(statePath)
+		if err != nil {
+			return err
+		}
+		if existing, ok := state.Dirs[dir]; ok {
+			ack = existing
+			return nil
+		}
+		state.Dirs[dir] = ack
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(statePath, data, 0600)
+	})
+	if err != nil {
+		return nil, err
+	}
+	LogDirAcknowledged(dir, len(ack.Listing))
+	return &ack, nil
+}
+
+// ListDir lists the entries of dir, not descending into subdirectories,
+// sorted by name. Regular files are hashed.
+func ListDir(dir string) ([]DirEntryInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	listing := make([]DirEntryInfo, 0, len(entries))
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Lstat(path)
+		if err != nil {
+			continue
+		}
+		item := DirEntryInfo{Name: entry.Name(), Mode: info.Mode().String(), Size: info.Size()}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			item.Target, _ = os.Readlink(path)
+		case info.Mode().IsRegular():
+			item.SHA256, _ = hashFile(path)
+		}
+		listing = append(listing, item)
+	}
+	sort.Slice(listing, func(i, j int) bool { return listing[i].Name < listing[j].Name })
+	return listing, nil
+}
+
+// DirListingDiff is how a directory changed since it was listed
+type DirListingDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Empty reports whether nothing changed
+func (d DirListingDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffDirListing compares two listings of a directory by entry name
+func DiffDirListing(before, after []DirEntryInfo) DirListingDiff {
+	old := make(map[string]DirEntryInfo, len(before))
+	for _, entry := range before {
+		old[entry.Name] = entry
+	}
+	var diff DirListingDiff
+	for _, entry := range after {
+		previous, ok := old[entry.Name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, entry.Name)
+		case previous != entry:
+			diff.Changed = append(diff.Changed, entry.Name)
+		}
+		delete(old, entry.Name)
+	}
+	for name := range old {
+		diff.Removed = append(diff.Removed, name)
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// hashFile returns the hex SHA-256 of a file's content
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the
+// same directory
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
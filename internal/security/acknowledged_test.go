@@ -0,0 +1,68 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestAcknowledgeDir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "tsc"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("tsc", filepath.Join(dir, "tsc-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if IsDirAcknowledged(dir) {
+		t.Fatal("a new directory should not be acknowledged")
+	}
+	ack, err := AcknowledgeDir(dir)
+	if err != nil {
+		t.Fatalf("AcknowledgeDir error: %v", err)
+	}
+	if !IsDirAcknowledged(dir) {
+		t.Error("the directory should be acknowledged")
+	}
+	if len(ack.Listing) != 2 || ack.Listing[0].SHA256 == "" || ack.Listing[1].Target != "tsc" {
+		t.Errorf("unexpected listing: %+v", ack.Listing)
+	}
+
+	// Acknowledging again keeps the first listing
+	if err := os.WriteFile(filepath.Join(dir, "node"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	again, err := AcknowledgeDir(dir)
+	if err != nil {
+		t.Fatalf("AcknowledgeDir error: %v", err)
+	}
+	if !reflect.DeepEqual(again.Listing, ack.Listing) {
+		t.Errorf("acknowledging again should keep the first listing, got %+v", again.Listing)
+	}
+}
+
+func TestDiffDirListing(t *testing.T) {
+	before := []DirEntryInfo{
+		{Name: "node", Mode: "-rwxr-xr-x", Size: 10, SHA256: "aaa"},
+		{Name: "npm", Mode: "Lrwxrwxrwx", Target: "../lib/npm"},
+		{Name: "tsc", Mode: "-rwxr-xr-x", Size: 10, SHA256: "bbb"},
+	}
+	after := []DirEntryInfo{
+		{Name: "node", Mode: "-rwxr-xr-x", Size: 10, SHA256: "ccc"},
+		{Name: "npx", Mode: "Lrwxrwxrwx", Target: "../lib/npx"},
+		{Name: "tsc", Mode: "-rwxr-xr-x", Size: 10, SHA256: "bbb"},
+	}
+	diff := DiffDirListing(before, after)
+	want := DirListingDiff{Added: []string{"npx"}, Removed: []string{"npm"}, Changed: []string{"node"}}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("DiffDirListing = %+v, want %+v", diff, want)
+	}
+	if !DiffDirListing(before, before).Empty() {
+		t.Error("a listing compared with itself should not change")
+	}
+}
@@ -22,8 +22,21 @@ type SecurityConfig struct {
 	// These are directories that affect system-wide behavior.
 	SystemDirs []string
 
+	// SharedDirs are directories every user of the machine runs programs
+	// from, like /usr/local. Wrapping in one, or in a SystemDir, first needs
+	// a one-time acknowledgment per directory (see AcknowledgeDir).
+	SharedDirs []string
+
 	// CriticalBinaries are specific binaries that must never be shimmed
 	CriticalBinaries []string
+
+	// AllowedDirs are directories the user's security settings make safe
+	// to shim in, including inside SystemDirs (see LoadSecurityConfig)
+	AllowedDirs []string
+
+	// ForbiddenDirs are directories the user's security settings never
+	// allow shimming in, even inside AllowedDirs
+	ForbiddenDirs []string
 }
 
 // DefaultSecurityConfig returns the default security configuration
@@ -38,6 +51,11 @@ func DefaultSecurityConfig() *SecurityConfig {
 			"/System",
 		},
 
+		SharedDirs: []string{
+			"/usr/local",
+			"/opt",
+		},
+
 		CriticalBinaries: []string{
 			"bash", "sh", "zsh", "fish", // Shells
 			"sudo", "su", "doas", // Privilege escalation
@@ -65,36 +83,73 @@ func IsCriticalSystemBinary(path string) bool {
 
 // RequiresConfirmation checks if path needs user confirmation (is in a system directory)
 func RequiresConfirmation(path string) bool {
-	abs, err := filepath.Abs(filepath.Clean(path))
+	category, err := GetDirectoryCategory(path)
 	if err != nil {
 		return true // Err on side of caution
 	}
+	return category == CategoryRequiresConfirmation
+}
 
-	config := DefaultSecurityConfig()
-
-	for _, sysDir := range config.SystemDirs {
-		if isWithinDir(abs, sysDir) {
-			return true
-		}
+// RequiresAcknowledgment checks if wrapping at path first needs a one-time
+// acknowledgment of its directory (see SecurityConfig.SharedDirs)
+func RequiresAcknowledgment(path string) bool {
+	abs, err := filepath.Abs(filepath.Clean(path))
+	if err != nil {
+		return true
 	}
+	config, err := LoadSecurityConfig()
+	if err != nil {
+		return true
+	}
+	return config.RequiresAcknowledgment(abs)
+}
 
-	return false
+// RequiresAcknowledgment reports whether wrapping at the absolute path abs
+// first needs its directory acknowledged: it is in a system or shared
+// directory the user's settings don't allow
+func (c *SecurityConfig) RequiresAcknowledgment(abs string) bool {
+	switch c.Category(abs) {
+	case CategoryRequiresConfirmation:
+		return true
+	case CategoryAllowed:
+		return withinAny(abs, c.SharedDirs) && !withinAny(abs, c.AllowedDirs)
+	default:
+		return false
+	}
 }
 
-// GetDirectoryCategory returns the security category for a path
+// GetDirectoryCategory returns the security category for a path under the
+// effective rules (see LoadSecurityConfig)
 func GetDirectoryCategory(path string) (DirectoryCategory, error) {
 	abs, err := filepath.Abs(filepath.Clean(path))
 	if err != nil {
 		return CategoryForbidden, err
 	}
 
+	config, err := LoadSecurityConfig()
+	if err != nil {
+		return CategoryForbidden, err
+	}
+	return config.Category(abs), nil
+}
+
+// Category returns the security category for an absolute path. Forbidden
+// directories take precedence over allowed ones, and allowed ones over
+// system directories.
+func (c *SecurityConfig) Category(abs string) DirectoryCategory {
+	if withinAny(abs, c.ForbiddenDirs) {
+		return CategoryForbidden
+	}
+	if withinAny(abs, c.AllowedDirs) {
+		return CategoryAllowed
+	}
 	// Check if requires confirmation (system directory)
-	if RequiresConfirmation(abs) {
-		return CategoryRequiresConfirmation, nil
+	if withinAny(abs, c.SystemDirs) {
+		return CategoryRequiresConfirmation
 	}
 
 	// Default: allow all other directories
-	return CategoryAllowed, nil
+	return CategoryAllowed
 }
 
 // ValidateBinaryForShim performs comprehensive validation
@@ -129,11 +184,25 @@ func ValidateBinaryForShim(path string, allowConfirmed bool) error {
 		// Safe to proceed
 		return nil
 
+	case CategoryForbidden:
+		return fmt.Errorf("shimming in %s is forbidden by your security settings (%s)\n\nRun 'ribbin security show' to see the effective policy",
+			filepath.Dir(abs), SecurityFileName)
+
 	default:
 		return fmt.Errorf("unknown directory category")
 	}
 }
 
+// withinAny reports whether path is within any of dirs
+func withinAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if isWithinDir(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
 // isWithinDir checks if path is within dir (handling symlinks)
 func isWithinDir(path, dir string) bool {
 	absPath, err := filepath.Abs(path)
@@ -29,10 +29,14 @@ const (
 	EventShimInstall       = "shim.install"
 	EventShimUninstall     = "shim.uninstall"
 	EventBypassUsed        = "bypass.used"
+	EventOverrideUsed      = "override.used"
+	EventRescueUsed        = "rescue.used"
+	EventInvocationLogged  = "invocation.logged"
 	EventSecurityViolation = "security.violation"
 	EventPrivilegedOp      = "privileged.operation"
 	EventConfigLoad        = "config.load"
 	EventRegistryUpdate    = "registry.update"
+	EventDirAcknowledged   = "dir.acknowledged"
 )
 
 // GetAuditLogPath returns the path to the audit log.
@@ -139,6 +143,42 @@ func LogBypassUsage(command string, pid int) {
 	LogEvent(event)
 }
 
+// LogOverrideUsage logs when a blocked command is run anyway from the
+// interactive override prompt
+func LogOverrideUsage(binary string, details map[string]string) {
+	event := &AuditEvent{
+		Event:   EventOverrideUsed,
+		Binary:  binary,
+		Success: true,
+		Details: details,
+	}
+	LogEvent(event)
+}
+
+// LogRescueUsage logs when a wrapper runs its command unwrapped because
+// RIBBIN_RESCUE=1 is set
+func LogRescueUsage(binary string, details map[string]string) {
+	event := &AuditEvent{
+		Event:   EventRescueUsed,
+		Binary:  binary,
+		Success: true,
+		Details: details,
+	}
+	LogEvent(event)
+}
+
+// LogInvocation logs a run of a wrapped command whose wrapper redirects to
+// builtin:log-and-pass
+func LogInvocation(binary string, details map[string]string) {
+	event := &AuditEvent{
+		Event:   EventInvocationLogged,
+		Binary:  binary,
+		Success: true,
+		Details: details,
+	}
+	LogEvent(event)
+}
+
 // LogSecurityViolation logs a security policy violation
 func LogSecurityViolation(violation, path string, details map[string]string) {
 	event := &AuditEvent{
@@ -195,6 +235,20 @@ func LogRegistryUpdate(operation string, success bool, err error) {
 	LogEvent(event)
 }
 
+// LogDirAcknowledged logs the acknowledgment of wrapping in a shared
+// directory, and how many entries its recorded listing has
+func LogDirAcknowledged(dir string, entries int) {
+	event := &AuditEvent{
+		Event:   EventDirAcknowledged,
+		Path:    dir,
+		Success: true,
+		Details: map[string]string{
+			"entries": fmt.Sprintf("%d", entries),
+		},
+	}
+	LogEvent(event)
+}
+
 // Query and Analysis Functions
 
 // AuditQuery filters for querying audit events
@@ -271,6 +325,8 @@ type AuditSummary struct {
 	ElevatedOps        int
 	SecurityViolations int
 	BypassUsages       int
+	OverrideUsages     int
+	RescueUsages       int
 }
 
 // GetAuditSummary provides statistics about audit events
@@ -298,6 +354,12 @@ func GetAuditSummary(since *time.Time) (*AuditSummary, error) {
 		if event.Event == EventBypassUsed {
 			summary.BypassUsages++
 		}
+		if event.Event == EventOverrideUsed {
+			summary.OverrideUsages++
+		}
+		if event.Event == EventRescueUsed {
+			summary.RescueUsages++
+		}
 	}
 
 	return summary, nil
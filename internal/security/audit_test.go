@@ -365,6 +365,8 @@ func TestGetAuditSummary(t *testing.T) {
 	LogShimUninstall("/bin/test3", true, nil)
 	LogBypassUsage("/bin/test4", 1234)
 	LogBypassUsage("/bin/test5", 5678)
+	LogOverrideUsage("/bin/test6", map[string]string{"command": "test6"})
+	LogRescueUsage("/bin/test7", map[string]string{"command": "test7"})
 	LogSecurityViolation("path traversal", "/tmp/../etc", map[string]string{})
 
 	// Get summary
@@ -374,11 +376,11 @@ func TestGetAuditSummary(t *testing.T) {
 		t.Fatalf("GetAuditSummary() error = %v", err)
 	}
 
-	if summary.TotalEvents != 6 {
-		t.Errorf("TotalEvents = %d, want 6", summary.TotalEvents)
+	if summary.TotalEvents != 8 {
+		t.Errorf("TotalEvents = %d, want 8", summary.TotalEvents)
 	}
-	if summary.SuccessfulOps != 4 {
-		t.Errorf("SuccessfulOps = %d, want 4", summary.SuccessfulOps)
+	if summary.SuccessfulOps != 6 {
+		t.Errorf("SuccessfulOps = %d, want 6", summary.SuccessfulOps)
 	}
 	if summary.FailedOps != 2 {
 		t.Errorf("FailedOps = %d, want 2", summary.FailedOps)
@@ -389,6 +391,12 @@ func TestGetAuditSummary(t *testing.T) {
 	if summary.BypassUsages != 2 {
 		t.Errorf("BypassUsages = %d, want 2", summary.BypassUsages)
 	}
+	if summary.OverrideUsages != 1 {
+		t.Errorf("OverrideUsages = %d, want 1", summary.OverrideUsages)
+	}
+	if summary.RescueUsages != 1 {
+		t.Errorf("RescueUsages = %d, want 1", summary.RescueUsages)
+	}
 }
 
 func TestGetAuditSummaryEmpty(t *testing.T) {
@@ -127,6 +127,34 @@ func GetStateDir() (string, error) {
 	return filepath.Join(home, ".local", "state", "ribbin"), nil
 }
 
+// GetCacheDir returns a validated XDG cache directory for ribbin.
+// It follows the XDG Base Directory specification.
+func GetCacheDir() (string, error) {
+	// Check XDG_CACHE_HOME first
+	if cacheHome := os.Getenv("XDG_CACHE_HOME"); cacheHome != "" {
+		validated, err := ValidateEnvPath("XDG_CACHE_HOME")
+		if err != nil {
+			return "", fmt.Errorf("invalid XDG_CACHE_HOME: %w", err)
+		}
+
+		// Verify it exists or can be created
+		info, err := os.Stat(validated)
+		if err == nil && !info.IsDir() {
+			return "", fmt.Errorf("XDG_CACHE_HOME is not a directory: %s", validated)
+		}
+
+		return filepath.Join(validated, "ribbin"), nil
+	}
+
+	// Fall back to ~/.cache
+	home, err := ValidateHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".cache", "ribbin"), nil
+}
+
 // SafeExpandPath expands ~ prefix and validates the result.
 // It returns a canonicalized absolute path.
 func SafeExpandPath(path string) (string, error) {
@@ -185,14 +213,15 @@ func verifyOwnership(path string) error {
 	return nil
 }
 
-// ValidateRegistryPath returns a validated path for the ribbin registry file.
+// ValidateRegistryPath returns a validated path for the ribbin registry file,
+// in the active profile's directory.
 func ValidateRegistryPath() (string, error) {
-	configDir, err := GetConfigDir()
+	profileDir, err := GetProfileDir()
 	if err != nil {
 		return "", fmt.Errorf("cannot get config directory: %w", err)
 	}
 
-	return filepath.Join(configDir, "registry.json"), nil
+	return filepath.Join(profileDir, "registry.json"), nil
 }
 
 // EnsureConfigDir creates the ribbin config directory if it doesn't exist.
@@ -224,3 +253,18 @@ func EnsureStateDir() (string, error) {
 
 	return stateDir, nil
 }
+
+// EnsureCacheDir creates the ribbin cache directory if it doesn't exist.
+// It returns the validated path to the directory.
+func EnsureCacheDir() (string, error) {
+	cacheDir, err := GetCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return "", fmt.Errorf("cannot create cache directory: %w", err)
+	}
+
+	return cacheDir, nil
+}
@@ -170,6 +170,42 @@ func TestGetStateDir(t *testing.T) {
 	})
 }
 
+func TestGetCacheDir(t *testing.T) {
+	t.Run("default (no XDG)", func(t *testing.T) {
+		// Save and unset XDG_CACHE_HOME
+		original := os.Getenv("XDG_CACHE_HOME")
+		os.Unsetenv("XDG_CACHE_HOME")
+		defer func() {
+			if original != "" {
+				os.Setenv("XDG_CACHE_HOME", original)
+			}
+		}()
+
+		cacheDir, err := GetCacheDir()
+		if err != nil {
+			t.Fatalf("GetCacheDir() error = %v", err)
+		}
+		if !strings.Contains(cacheDir, ".cache/ribbin") {
+			t.Errorf("GetCacheDir() = %q, want to contain '.cache/ribbin'", cacheDir)
+		}
+	})
+
+	t.Run("with XDG_CACHE_HOME", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		os.Setenv("XDG_CACHE_HOME", tmpDir)
+		defer os.Unsetenv("XDG_CACHE_HOME")
+
+		cacheDir, err := GetCacheDir()
+		if err != nil {
+			t.Fatalf("GetCacheDir() error = %v", err)
+		}
+		expected := filepath.Join(tmpDir, "ribbin")
+		if cacheDir != expected {
+			t.Errorf("GetCacheDir() = %q, want %q", cacheDir, expected)
+		}
+	})
+}
+
 func TestSafeExpandPath(t *testing.T) {
 	home, _ := os.UserHomeDir()
 
@@ -1,12 +1,17 @@
 package security
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"syscall"
 	"time"
 )
 
+// ErrLockTimeout is returned when a lock isn't acquired within its timeout,
+// usually because another ribbin process is holding it.
+var ErrLockTimeout = errors.New("timeout acquiring lock")
+
 // Lock represents an advisory file lock.
 // Uses flock(2) for cross-process locking to prevent TOCTOU race conditions.
 type Lock struct {
@@ -43,6 +48,16 @@ func AcquireLock(path string, timeout time.Duration) (*Lock, error) {
 	for {
 		// Try exclusive lock (LOCK_EX | LOCK_NB for non-blocking)
 		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil && !isCurrentLockFile(file, lockPath) {
+			// The previous holder removed the lock file after we opened it
+			// (Release unlinks it). A lock on an unlinked file excludes
+			// nobody, so reopen the path and try again.
+			file.Close()
+			if file, err = os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600); err != nil {
+				return nil, fmt.Errorf("cannot create lock file: %w", err)
+			}
+			continue
+		}
 		if err == nil {
 			// Lock acquired
 			return &Lock{
@@ -55,7 +70,7 @@ func AcquireLock(path string, timeout time.Duration) (*Lock, error) {
 		// Check if timeout
 		if time.Now().After(deadline) {
 			file.Close()
-			return nil, fmt.Errorf("timeout acquiring lock on %s after %v", path, timeout)
+			return nil, fmt.Errorf("%w on %s after %v", ErrLockTimeout, path, timeout)
 		}
 
 		// Wait a bit and retry
@@ -90,6 +105,14 @@ func AcquireSharedLock(path string, timeout time.Duration) (*Lock, error) {
 	for {
 		// Try shared lock (LOCK_SH | LOCK_NB)
 		err = syscall.Flock(int(file.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+		if err == nil && !isCurrentLockFile(file, lockPath) {
+			// Lock file was removed and recreated under us; see AcquireLock
+			file.Close()
+			if file, err = os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600); err != nil {
+				return nil, fmt.Errorf("cannot create lock file: %w", err)
+			}
+			continue
+		}
 		if err == nil {
 			return &Lock{
 				file:     file,
@@ -100,13 +123,28 @@ func AcquireSharedLock(path string, timeout time.Duration) (*Lock, error) {
 
 		if time.Now().After(deadline) {
 			file.Close()
-			return nil, fmt.Errorf("timeout acquiring shared lock on %s after %v", path, timeout)
+			return nil, fmt.Errorf("%w on %s after %v (shared)", ErrLockTimeout, path, timeout)
 		}
 
 		time.Sleep(100 * time.Millisecond)
 	}
 }
 
+// isCurrentLockFile reports whether the open lock file is still the file at
+// lockPath. Release removes lock files, so a process that opened the file just
+// before it was removed may end up locking an orphaned inode.
+func isCurrentLockFile(file *os.File, lockPath string) bool {
+	openInfo, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	pathInfo, err := os.Stat(lockPath)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(openInfo, pathInfo)
+}
+
 // Release releases the file lock and removes the lock file.
 // Should be called via defer to ensure cleanup even on panic.
 //
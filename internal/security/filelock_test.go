@@ -1,6 +1,7 @@
 package security
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -65,6 +66,35 @@ func TestLock_Release(t *testing.T) {
 	defer lock2.Release()
 }
 
+// TestIsCurrentLockFile verifies that a lock file removed after being opened is
+// detected, so lockers don't end up holding a lock on an orphaned inode
+func TestIsCurrentLockFile(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "test.lock")
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if !isCurrentLockFile(file, lockPath) {
+		t.Fatal("freshly opened lock file should be current")
+	}
+
+	// Simulate another process releasing (unlinking) and a third recreating it
+	if err := os.Remove(lockPath); err != nil {
+		t.Fatal(err)
+	}
+	if isCurrentLockFile(file, lockPath) {
+		t.Error("removed lock file should not be current")
+	}
+	if err := os.WriteFile(lockPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if isCurrentLockFile(file, lockPath) {
+		t.Error("recreated lock file is a different inode and should not be current")
+	}
+}
+
 // TestLock_DoubleRelease verifies that releasing a lock twice returns an error
 func TestLock_DoubleRelease(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -547,6 +577,9 @@ func TestLockTimeout(t *testing.T) {
 		lock2.Release()
 		t.Fatal("expected lock to timeout")
 	}
+	if !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("error = %v, want ErrLockTimeout", err)
+	}
 
 	// Verify timeout duration is approximately correct
 	if elapsed < 300*time.Millisecond {
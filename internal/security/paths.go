@@ -52,8 +52,12 @@ func ValidateBinaryPath(path string) error {
 }
 
 // ValidConfigFileNames contains the allowed config file names.
-// ribbin.local.jsonc takes precedence over ribbin.jsonc when both exist.
-var ValidConfigFileNames = []string{"ribbin.jsonc", "ribbin.local.jsonc"}
+// ribbin.local.* takes precedence over ribbin.* when both exist.
+var ValidConfigFileNames = []string{
+	"ribbin.jsonc", "ribbin.local.jsonc",
+	"ribbin.toml", "ribbin.local.toml",
+	"ribbin.yaml", "ribbin.local.yaml",
+}
 
 // ValidateConfigPath ensures a config file is safe to load.
 // It verifies the filename is a valid ribbin config name and checks file permissions.
@@ -74,7 +78,7 @@ func ValidateConfigPath(path string) error {
 		}
 	}
 	if !isValidName {
-		return fmt.Errorf("config must be named ribbin.jsonc or ribbin.local.jsonc, got: %s", baseName)
+		return fmt.Errorf("config must be named ribbin.jsonc or ribbin.local.jsonc (or a .toml/.yaml variant), got: %s", baseName)
 	}
 
 	// Check file permissions (not world-writable)
@@ -187,6 +191,19 @@ func isWithinAllowedDirectory(path string) bool {
 		return false
 	}
 
+	// The user's security settings come first: forbidden directories are
+	// never allowed, and allowed ones are even among the dangerous prefixes
+	config, err := LoadSecurityConfig()
+	if err != nil {
+		return false
+	}
+	if withinAny(path, config.ForbiddenDirs) {
+		return false
+	}
+	if withinAny(path, config.AllowedDirs) {
+		return true
+	}
+
 	// Allow paths in /tmp and /app (for testing)
 	// These are commonly used for test binaries and CI/CD environments
 	// Check this BEFORE category checks to allow test paths
@@ -226,10 +243,7 @@ func isWithinAllowedDirectory(path string) bool {
 	}
 
 	// Check the directory category
-	category, err := GetDirectoryCategory(path)
-	if err != nil {
-		return false
-	}
+	category := config.Category(path)
 
 	// For path validation (not shimming), we only reject explicitly forbidden directories
 	// This allows paths in Allowed, RequiresConfirmation, and unlisted directories
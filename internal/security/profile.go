@@ -0,0 +1,193 @@
+package security
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A profile is a separate registry and settings file, for keeping the wraps
+// and activations of one client apart from another's on the same machine.
+// The default profile keeps them in ribbin's config directory, as before
+// profiles; any other lives in its profiles/<name> subdirectory. The config
+// directory's other files, like security.jsonc and the user config, are
+// shared by every profile.
+
+// ProfileEnvVar names the profile to use, overriding the one 'ribbin
+// profile switch' chose
+const ProfileEnvVar = "RIBBIN_PROFILE"
+
+// DefaultProfile is the profile whose files are in the config directory
+// itself
+const DefaultProfile = "default"
+
+// profilesDirName is the directory, in the config directory, holding the
+// profiles other than the default
+const profilesDirName = "profiles"
+
+// currentProfileFile records the profile 'ribbin profile switch' chose, in
+// the config directory
+const currentProfileFile = "profile"
+
+// profileNamePattern matches the names a profile may have
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// ValidateProfileName returns an error for a name a profile can't have
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '-' and '_', starting with a letter or digit", name)
+	}
+	return nil
+}
+
+// ActiveProfile returns the profile in use, and where it was chosen: by
+// RIBBIN_PROFILE, by 'ribbin profile switch', or by default.
+func ActiveProfile() (name, source string, err error) {
+	if name := os.Getenv(ProfileEnvVar); name != "" {
+		if err := ValidateProfileName(name); err != nil {
+			return "", "", fmt.Errorf("%s: %w", ProfileEnvVar, err)
+		}
+		return name, ProfileEnvVar, nil
+	}
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", "", err
+	}
+	data, err := os.ReadFile(filepath.Join(configDir, currentProfileFile))
+	if os.IsNotExist(err) {
+		return DefaultProfile, "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	name = strings.TrimSpace(string(data))
+	if name == "" {
+		return DefaultProfile, "", nil
+	}
+	if err := ValidateProfileName(name); err != nil {
+		return "", "", fmt.Errorf("%s: %w", filepath.Join(configDir, currentProfileFile), err)
+	}
+	return name, "ribbin profile switch", nil
+}
+
+// ProfileDir returns the directory holding the registry and settings of
+// the named profile
+func ProfileDir(name string) (string, error) {
+	if err := ValidateProfileName(name); err != nil {
+		return "", err
+	}
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	if name == DefaultProfile {
+		return configDir, nil
+	}
+	return filepath.Join(configDir, profilesDirName, name), nil
+}
+
+// GetProfileDir returns the directory of the active profile
+func GetProfileDir() (string, error) {
+	name, _, err := ActiveProfile()
+	if err != nil {
+		return "", err
+	}
+	return ProfileDir(name)
+}
+
+// EnsureProfileDir creates the active profile's directory if it doesn't
+// exist. It returns the validated path to the directory.
+func EnsureProfileDir() (string, error) {
+	profileDir, err := GetProfileDir()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(profileDir, 0755); err != nil {
+		return "", fmt.Errorf("cannot create profile directory: %w", err)
+	}
+
+	return profileDir, nil
+}
+
+// ProfileExists returns true for the default profile and for profiles that
+// have been created
+func ProfileExists(name string) (bool, error) {
+	if name == DefaultProfile {
+		return true, nil
+	}
+	dir, err := ProfileDir(name)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil && info.IsDir(), err
+}
+
+// ListProfiles returns the default profile and those created, sorted with
+// the default first
+func ListProfiles() ([]string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(configDir, profilesDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != DefaultProfile && ValidateProfileName(entry.Name()) == nil {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultProfile}, names...), nil
+}
+
+// CreateProfile creates the directory of a new profile
+func CreateProfile(name string) (string, error) {
+	if exists, err := ProfileExists(name); err != nil {
+		return "", err
+	} else if exists {
+		return "", fmt.Errorf("profile %q already exists", name)
+	}
+	dir, err := ProfileDir(name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("cannot create profile directory: %w", err)
+	}
+	return dir, nil
+}
+
+// SwitchProfile makes name the profile used when RIBBIN_PROFILE isn't set.
+// The profile must exist.
+func SwitchProfile(name string) error {
+	exists, err := ProfileExists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("profile %q doesn't exist; create it with 'ribbin profile create %s'", name, name)
+	}
+	configDir, err := EnsureConfigDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(configDir, currentProfileFile)
+	if name == DefaultProfile {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(path, []byte(name+"\n"), 0644)
+}
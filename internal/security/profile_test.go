@@ -0,0 +1,81 @@
+package security
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestValidateProfileName(t *testing.T) {
+	for _, name := range []string{"work", "client-a", "Acme_2"} {
+		if err := ValidateProfileName(name); err != nil {
+			t.Errorf("ValidateProfileName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "-x", "../etc", "a/b", ".hidden", "with space"} {
+		if err := ValidateProfileName(name); err == nil {
+			t.Errorf("ValidateProfileName(%q) should fail", name)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv(ProfileEnvVar, "")
+	configDir := filepath.Join(configHome, "ribbin")
+
+	if name, source, err := ActiveProfile(); err != nil || name != DefaultProfile || source != "" {
+		t.Fatalf("ActiveProfile = %q, %q, %v; want the default", name, source, err)
+	}
+	if path, err := ValidateRegistryPath(); err != nil || path != filepath.Join(configDir, "registry.json") {
+		t.Errorf("default registry path = %q, %v", path, err)
+	}
+
+	if err := SwitchProfile("work"); err == nil {
+		t.Error("switching to a profile that doesn't exist should fail")
+	}
+	dir, err := CreateProfile("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != filepath.Join(configDir, "profiles", "work") {
+		t.Errorf("profile dir = %q", dir)
+	}
+	if _, err := CreateProfile("work"); err == nil {
+		t.Error("creating a profile twice should fail")
+	}
+	if names, err := ListProfiles(); err != nil || !reflect.DeepEqual(names, []string{"default", "work"}) {
+		t.Errorf("ListProfiles = %v, %v", names, err)
+	}
+
+	if err := SwitchProfile("work"); err != nil {
+		t.Fatal(err)
+	}
+	if name, source, err := ActiveProfile(); err != nil || name != "work" || source != "ribbin profile switch" {
+		t.Errorf("ActiveProfile after switch = %q, %q, %v", name, source, err)
+	}
+	if path, err := ValidateRegistryPath(); err != nil || path != filepath.Join(dir, "registry.json") {
+		t.Errorf("work registry path = %q, %v", path, err)
+	}
+
+	t.Run("RIBBIN_PROFILE overrides the switch", func(t *testing.T) {
+		t.Setenv(ProfileEnvVar, "personal")
+		if name, source, err := ActiveProfile(); err != nil || name != "personal" || source != ProfileEnvVar {
+			t.Errorf("ActiveProfile = %q, %q, %v", name, source, err)
+		}
+		t.Setenv(ProfileEnvVar, "../work")
+		if _, err := GetProfileDir(); err == nil {
+			t.Error("an invalid RIBBIN_PROFILE should be refused")
+		}
+	})
+
+	if err := SwitchProfile(DefaultProfile); err != nil {
+		t.Fatal(err)
+	}
+	if name, _, err := ActiveProfile(); err != nil || name != DefaultProfile {
+		t.Errorf("ActiveProfile after switching back = %q, %v", name, err)
+	}
+}
@@ -0,0 +1,104 @@
+package security
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tailscale/hujson"
+)
+
+// SecurityFileName is the user's security settings file in ribbin's config
+// directory (~/.config/ribbin, or $XDG_CONFIG_HOME/ribbin). It extends the
+// built-in directory rules:
+//
+//	{
+//	  "allowedDirs": ["/srv/tools/bin"],
+//	  "forbiddenDirs": ["/opt/vendor/bin"],
+//	  "sharedDirs": ["/srv/shared/bin"]
+//	}
+//
+// The critical binaries can't be wrapped whatever it says.
+const SecurityFileName = "security.jsonc"
+
+// securitySettings is the contents of SecurityFileName
+type securitySettings struct {
+	AllowedDirs   []string `json:"allowedDirs"`
+	ForbiddenDirs []string `json:"forbiddenDirs"`
+	SharedDirs    []string `json:"sharedDirs"`
+}
+
+// SecuritySettingsPath returns the path of the user's security settings file
+func SecuritySettingsPath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, SecurityFileName), nil
+}
+
+// LoadSecurityConfig returns the effective security rules: the defaults,
+// extended by the user's security settings file if there is one. Because
+// the file can loosen the rules, it is refused if other users can write to
+// it, and unknown keys are errors rather than silently ignored.
+func LoadSecurityConfig() (*SecurityConfig, error) {
+	config := DefaultSecurityConfig()
+
+	settingsPath, err := SecuritySettingsPath()
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(settingsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read security settings: %w", err)
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return nil, fmt.Errorf("%s: security settings must not be writable by other users (mode %o)", settingsPath, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(settingsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read security settings: %w", err)
+	}
+	standardJSON, err := hujson.Standardize(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", settingsPath, err)
+	}
+	var settings securitySettings
+	decoder := json.NewDecoder(bytes.NewReader(standardJSON))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&settings); err != nil {
+		return nil, fmt.Errorf("%s: invalid security settings: %w", settingsPath, err)
+	}
+
+	for _, field := range []struct {
+		name string
+		dirs []string
+	}{{"allowedDirs", settings.AllowedDirs}, {"forbiddenDirs", settings.ForbiddenDirs}, {"sharedDirs", settings.SharedDirs}} {
+		for _, dir := range field.dirs {
+			if !filepath.IsAbs(dir) {
+				return nil, fmt.Errorf("%s: %s must be absolute paths, got %q", settingsPath, field.name, dir)
+			}
+			if filepath.Clean(dir) == string(filepath.Separator) {
+				return nil, fmt.Errorf("%s: %s can't include the root directory", settingsPath, field.name)
+			}
+		}
+	}
+
+	for _, dir := range settings.AllowedDirs {
+		config.AllowedDirs = append(config.AllowedDirs, filepath.Clean(dir))
+	}
+	for _, dir := range settings.ForbiddenDirs {
+		config.ForbiddenDirs = append(config.ForbiddenDirs, filepath.Clean(dir))
+	}
+	for _, dir := range settings.SharedDirs {
+		config.SharedDirs = append(config.SharedDirs, filepath.Clean(dir))
+	}
+	return config, nil
+}
@@ -0,0 +1,160 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestLoadSecurityConfig(t *testing.T) {
+	writeSettings := func(t *testing.T, content string, mode os.FileMode) {
+		t.Helper()
+		configHome := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", configHome)
+		if content == "" {
+			return
+		}
+		dir := filepath.Join(configHome, "ribbin")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, SecurityFileName)
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("no file uses the defaults", func(t *testing.T) {
+		writeSettings(t, "", 0)
+		config, err := LoadSecurityConfig()
+		if err != nil {
+			t.Fatalf("LoadSecurityConfig error: %v", err)
+		}
+		if len(config.AllowedDirs) != 0 || len(config.ForbiddenDirs) != 0 {
+			t.Errorf("expected no extensions, got %+v", config)
+		}
+		if len(config.SystemDirs) != len(DefaultSecurityConfig().SystemDirs) {
+			t.Errorf("expected the default system dirs, got %v", config.SystemDirs)
+		}
+	})
+
+	t.Run("extensions are added", func(t *testing.T) {
+		writeSettings(t, `{
+  // build hosts keep tools here
+  "allowedDirs": ["/srv/tools/bin/"],
+  "forbiddenDirs": ["/opt/vendor/bin"],
+}`, 0644)
+		config, err := LoadSecurityConfig()
+		if err != nil {
+			t.Fatalf("LoadSecurityConfig error: %v", err)
+		}
+		if len(config.AllowedDirs) != 1 || config.AllowedDirs[0] != "/srv/tools/bin" {
+			t.Errorf("AllowedDirs = %v", config.AllowedDirs)
+		}
+		if len(config.ForbiddenDirs) != 1 || config.ForbiddenDirs[0] != "/opt/vendor/bin" {
+			t.Errorf("ForbiddenDirs = %v", config.ForbiddenDirs)
+		}
+	})
+
+	errorTests := []struct {
+		name    string
+		content string
+		mode    os.FileMode
+		wantErr string
+	}{
+		{"writable by others", `{"allowedDirs": ["/srv/tools/bin"]}`, 0666, "writable by other users"},
+		{"unknown key", `{"allowDirs": ["/srv/tools/bin"]}`, 0644, "unknown field"},
+		{"relative path", `{"forbiddenDirs": ["vendor/bin"]}`, 0644, "must be absolute"},
+		{"root directory", `{"allowedDirs": ["/"]}`, 0644, "root directory"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeSettings(t, tt.content, tt.mode)
+			_, err := LoadSecurityConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if _, err := GetDirectoryCategory("/srv/tools/bin/tool"); err == nil {
+				t.Error("GetDirectoryCategory should fail with invalid security settings")
+			}
+		})
+	}
+}
+
+func TestSecurityConfigCategory(t *testing.T) {
+	config := DefaultSecurityConfig()
+	config.AllowedDirs = []string{"/usr/libexec/tools", "/srv/tools"}
+	config.ForbiddenDirs = []string{"/srv/tools/vendor", "/opt/vendor/bin"}
+
+	tests := []struct {
+		path     string
+		category DirectoryCategory
+	}{
+		{"/usr/libexec/tools/tsc", CategoryAllowed},
+		{"/usr/libexec/other/tsc", CategoryRequiresConfirmation},
+		{"/srv/tools/bin/tsc", CategoryAllowed},
+		{"/srv/tools/vendor/tsc", CategoryForbidden},
+		{"/opt/vendor/bin/tsc", CategoryForbidden},
+		{"/opt/other/bin/tsc", CategoryAllowed},
+	}
+	for _, tt := range tests {
+		if got := config.Category(tt.path); got != tt.category {
+			t.Errorf("Category(%s) = %v, want %v", tt.path, got, tt.category)
+		}
+	}
+}
+
+func TestSecurityConfigRequiresAcknowledgment(t *testing.T) {
+	config := DefaultSecurityConfig()
+	config.AllowedDirs = []string{"/opt/tools"}
+	config.SharedDirs = append(config.SharedDirs, "/srv/shared")
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/usr/local/bin/tsc", true},
+		{"/opt/homebrew/bin/tsc", true},
+		{"/srv/shared/bin/tsc", true},
+		{"/usr/bin/tsc", true},
+		{"/opt/tools/bin/tsc", false},
+		{"/home/dev/project/node_modules/.bin/tsc", false},
+	}
+	for _, tt := range tests {
+		if got := config.RequiresAcknowledgment(tt.path); got != tt.want {
+			t.Errorf("RequiresAcknowledgment(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestValidateBinaryForShim_SecuritySettings(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	binDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(configHome, "ribbin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	settings := `{"allowedDirs": ["/usr/bin"], "forbiddenDirs": ["` + binDir + `"]}`
+	if err := os.WriteFile(filepath.Join(configHome, "ribbin", SecurityFileName), []byte(settings), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidateBinaryForShim("/usr/bin/tsc", false); err != nil {
+		t.Errorf("an allowed directory should need no confirmation: %v", err)
+	}
+	if err := ValidateBinaryForShim("/usr/bin/sudo", false); err == nil || !strings.Contains(err.Error(), "critical system binary") {
+		t.Errorf("critical binaries can't be allowed, got %v", err)
+	}
+	if err := ValidateBinaryForShim(filepath.Join(binDir, "tsc"), true); err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("a forbidden directory should be refused even with confirmation, got %v", err)
+	}
+	if err := ValidateBinaryPath(filepath.Join(binDir, "tsc")); err == nil {
+		t.Error("ValidateBinaryPath should refuse a forbidden directory")
+	}
+}
@@ -0,0 +1,204 @@
+// Package selfupdate replaces the running ribbin binary with a release
+// downloaded from GitHub.
+//
+// Releases are published by goreleaser as
+// ribbin_<version>_<os>_<arch>.tar.gz archives next to a checksums.txt file
+// listing their SHA-256 checksums. An archive is only installed if its
+// checksum matches.
+package selfupdate
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Repo is the GitHub repository releases are fetched from
+const Repo = "happycollision/ribbin"
+
+// maxArchiveSize caps the size of a downloaded release archive
+const maxArchiveSize = 100 << 20
+
+// maxBinarySize caps the size of the binary extracted from an archive
+const maxBinarySize = 200 << 20
+
+// Endpoints, replaced by tests to talk to a local server
+var (
+	apiBase      = "https://api.github.com"
+	downloadBase = "https://github.com"
+	httpClient   = &http.Client{Timeout: 2 * time.Minute}
+)
+
+// ErrChecksumMismatch is returned when a downloaded archive doesn't match checksums.txt
+var ErrChecksumMismatch = errors.New("release checksum mismatch")
+
+// Release is a published ribbin release
+type Release struct {
+	// Tag is the git tag, e.g. "v0.1.0"
+	Tag string
+}
+
+// Version returns the release's version without the leading "v"
+func (r Release) Version() string {
+	return strings.TrimPrefix(r.Tag, "v")
+}
+
+// ArchiveName returns the name of the release archive for a platform
+func (r Release) ArchiveName(goos, goarch string) string {
+	return fmt.Sprintf("ribbin_%s_%s_%s.tar.gz", r.Version(), goos, goarch)
+}
+
+// IsCurrent reports whether the release is the given running version
+func (r Release) IsCurrent(version string) bool {
+	return r.Version() == strings.TrimPrefix(version, "v")
+}
+
+// LatestRelease asks GitHub for the latest release
+func LatestRelease() (Release, error) {
+	data, err := get(fmt.Sprintf("%s/repos/%s/releases/latest", apiBase, Repo), 1<<20)
+	if err != nil {
+		return Release{}, fmt.Errorf("cannot check latest release: %w", err)
+	}
+
+	var body struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return Release{}, fmt.Errorf("cannot parse latest release: %w", err)
+	}
+	if body.TagName == "" {
+		return Release{}, fmt.Errorf("latest release has no tag")
+	}
+	return Release{Tag: body.TagName}, nil
+}
+
+// Download fetches the release's binary for the running platform, verifying
+// the archive against the release's checksums.txt before extracting it.
+func Download(release Release) ([]byte, error) {
+	archiveName := release.ArchiveName(runtime.GOOS, runtime.GOARCH)
+	base := fmt.Sprintf("%s/%s/releases/download/%s", downloadBase, Repo, release.Tag)
+
+	checksums, err := get(base+"/checksums.txt", 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("cannot download checksums: %w", err)
+	}
+	want, err := findChecksum(checksums, archiveName)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := get(base+"/"+archiveName, maxArchiveSize)
+	if err != nil {
+		return nil, fmt.Errorf("cannot download %s: %w", archiveName, err)
+	}
+	sum := sha256.Sum256(archive)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("%w for %s: expected sha256 %s, got %s", ErrChecksumMismatch, archiveName, want, got)
+	}
+
+	return extractBinary(archive)
+}
+
+// findChecksum returns the checksum checksums.txt lists for name
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s in checksums.txt (is %s/%s supported?)", name, runtime.GOOS, runtime.GOARCH)
+}
+
+// extractBinary returns the ribbin binary from a release archive
+func extractBinary(archive []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("archive does not contain a ribbin binary")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg || filepath.Base(header.Name) != "ribbin" {
+			continue
+		}
+		if header.Size > maxBinarySize {
+			return nil, fmt.Errorf("binary in archive exceeds %d bytes", maxBinarySize)
+		}
+		return io.ReadAll(io.LimitReader(tr, maxBinarySize))
+	}
+}
+
+// get downloads url, refusing responses larger than limit
+func get(url string, limit int64) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("response exceeds %d bytes", limit)
+	}
+	return data, nil
+}
+
+// Install atomically replaces the file at target with binary. The new binary
+// is written next to target and run with --version first, so a binary that
+// can't run on this machine never replaces a working one.
+func Install(binary []byte, target string) error {
+	// A directory of our own next to target, so the binary can be named
+	// "ribbin" (anything else runs in wrapper mode) and renamed in place
+	tmpDir, err := os.MkdirTemp(filepath.Dir(target), ".ribbin-update-*")
+	if err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("permission denied writing to %s (try with sudo)", filepath.Dir(target))
+		}
+		return fmt.Errorf("cannot create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmpPath := filepath.Join(tmpDir, "ribbin")
+	if err := os.WriteFile(tmpPath, binary, 0755); err != nil {
+		return fmt.Errorf("cannot write new binary: %w", err)
+	}
+	if output, err := exec.Command(tmpPath, "--version").CombinedOutput(); err != nil {
+		return fmt.Errorf("new binary does not run: %v\n%s", err, output)
+	}
+
+	if err := os.Rename(tmpPath, target); err != nil {
+		return fmt.Errorf("cannot replace %s: %w", target, err)
+	}
+	return nil
+}
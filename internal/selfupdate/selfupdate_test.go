@@ -0,0 +1,157 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+// makeArchive builds a release archive holding a ribbin "binary"
+func makeArchive(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, body := range map[string]string{"README.md": "readme", "ribbin": content} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// serveRelease serves a fake GitHub API and release downloads for tag
+func serveRelease(t *testing.T, tag string, archive []byte, checksum string) {
+	t.Helper()
+	release := Release{Tag: tag}
+	archiveName := release.ArchiveName(runtime.GOOS, runtime.GOARCH)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/"+Repo+"/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name": %q}`, tag)
+	})
+	mux.HandleFunc("/"+Repo+"/releases/download/"+tag+"/checksums.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  ribbin_other_archive.tar.gz\n%s  %s\n", checksum, checksum, archiveName)
+	})
+	mux.HandleFunc("/"+Repo+"/releases/download/"+tag+"/"+archiveName, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	origAPI, origDownload := apiBase, downloadBase
+	apiBase, downloadBase = server.URL, server.URL
+	t.Cleanup(func() { apiBase, downloadBase = origAPI, origDownload })
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLatestRelease(t *testing.T) {
+	serveRelease(t, "v1.2.3", nil, "")
+
+	release, err := LatestRelease()
+	if err != nil {
+		t.Fatalf("LatestRelease error: %v", err)
+	}
+	if release.Tag != "v1.2.3" || release.Version() != "1.2.3" {
+		t.Errorf("release = %+v", release)
+	}
+	if !release.IsCurrent("1.2.3") || !release.IsCurrent("v1.2.3") || release.IsCurrent("1.2.2") {
+		t.Error("IsCurrent should compare versions with or without the v prefix")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	binary := "#!/bin/sh\necho ribbin 1.2.3\n"
+	archive := makeArchive(t, binary)
+
+	t.Run("verified archive", func(t *testing.T) {
+		serveRelease(t, "v1.2.3", archive, sha256Hex(archive))
+
+		got, err := Download(Release{Tag: "v1.2.3"})
+		if err != nil {
+			t.Fatalf("Download error: %v", err)
+		}
+		if string(got) != binary {
+			t.Errorf("binary = %q, want %q", got, binary)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		serveRelease(t, "v1.2.3", archive, sha256Hex([]byte("something else")))
+
+		_, err := Download(Release{Tag: "v1.2.3"})
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("expected ErrChecksumMismatch, got %v", err)
+		}
+	})
+
+	t.Run("unknown release", func(t *testing.T) {
+		serveRelease(t, "v1.2.3", archive, sha256Hex(archive))
+
+		if _, err := Download(Release{Tag: "v9.9.9"}); err == nil {
+			t.Error("expected error for a release that doesn't exist")
+		}
+	})
+}
+
+func TestInstall(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "ribbin")
+	if err := os.WriteFile(target, []byte("#!/bin/sh\necho old\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("replaces target", func(t *testing.T) {
+		if err := Install([]byte("#!/bin/sh\necho new\n"), target); err != nil {
+			t.Fatalf("Install error: %v", err)
+		}
+		content, _ := os.ReadFile(target)
+		if string(content) != "#!/bin/sh\necho new\n" {
+			t.Errorf("target = %q", content)
+		}
+		info, _ := os.Stat(target)
+		if info.Mode().Perm()&0111 == 0 {
+			t.Error("target should be executable")
+		}
+	})
+
+	t.Run("keeps target if new binary does not run", func(t *testing.T) {
+		if err := Install([]byte("#!/bin/sh\nexit 1\n"), target); err == nil {
+			t.Error("expected error for a binary that fails --version")
+		}
+		content, _ := os.ReadFile(target)
+		if string(content) != "#!/bin/sh\necho new\n" {
+			t.Errorf("target should be unchanged, got %q", content)
+		}
+	})
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the target left in %s, got %d entries", dir, len(entries))
+	}
+}
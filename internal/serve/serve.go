@@ -0,0 +1,217 @@
+// Package serve answers read-only HTTP queries about ribbin's state, so
+// editor plugins and dashboards can poll it without running ribbin each time.
+//
+// The server listens on the loopback interface only, and every request must
+// carry the token written to the token file (see TokenPath) when the server
+// starts, as "Authorization: Bearer <token>". Only the user running ribbin
+// can read that file, so other users of the machine, and web pages in a
+// browser, can't query it.
+package serve
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// TokenFileName is the file, in ribbin's state directory, holding the token
+// clients authenticate with
+const TokenFileName = "serve-token"
+
+// DefaultPort is the port the server listens on unless told otherwise
+const DefaultPort = 7471
+
+// DefaultAuditLimit is how many audit events /audit returns by default
+const DefaultAuditLimit = 50
+
+// Status is the response of /status
+type Status struct {
+	Version           string                                  `json:"version"`
+	GlobalActive      bool                                    `json:"global_active"`
+	ShellActivations  map[int]config.ShellActivationEntry     `json:"shell_activations"`
+	ConfigActivations map[string]config.ConfigActivationEntry `json:"config_activations"`
+	Snoozes           map[string]config.SnoozeEntry           `json:"snoozes"`
+	// Wrapped is how many binaries the registry has wrapped
+	Wrapped int `json:"wrapped"`
+}
+
+// TokenPath returns the path of the token file
+func TokenPath() (string, error) {
+	stateDir, err := security.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, TokenFileName), nil
+}
+
+// NewToken writes a new random token to the token file, readable only by
+// the user, and returns it
+func NewToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	if _, err := security.EnsureStateDir(); err != nil {
+		return "", err
+	}
+	tokenPath, err := TokenPath()
+	if err != nil {
+		return "", err
+	}
+	// Remove any earlier file first, so the new one is created with 0600
+	// whatever the old one's mode was
+	if err := os.Remove(tokenPath); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if err := os.WriteFile(tokenPath, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Handler serves the status endpoints to clients presenting token
+func Handler(token, version string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		registry, err := config.LoadRegistry()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		registry.PruneExpiredSnoozes(time.Now())
+		if registry.Snoozes == nil {
+			registry.Snoozes = map[string]config.SnoozeEntry{}
+		}
+		writeJSON(w, Status{
+			Version:           version,
+			GlobalActive:      registry.GlobalActive,
+			ShellActivations:  registry.ShellActivations,
+			ConfigActivations: registry.ConfigActivations,
+			Snoozes:           registry.Snoozes,
+			Wrapped:           len(registry.Wrappers),
+		})
+	})
+	mux.HandleFunc("/registry", func(w http.ResponseWriter, r *http.Request) {
+		registry, err := config.LoadRegistry()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, registry)
+	})
+	mux.HandleFunc("/audit", func(w http.ResponseWriter, r *http.Request) {
+		query, limit, err := auditQuery(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		events, err := security.QueryAuditLog(query)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(events) > limit {
+			events = events[len(events)-limit:]
+		}
+		if events == nil {
+			events = []*security.AuditEvent{}
+		}
+		writeJSON(w, events)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "unknown endpoint; use /status, /registry or /audit")
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A web page can point a name it controls at 127.0.0.1; refusing
+		// other Host headers keeps it from reaching the server that way
+		if !isLoopbackHost(r.Host) {
+			writeError(w, http.StatusForbidden, "only localhost may be used to reach this server")
+			return
+		}
+		if !validToken(r, token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or wrong token; send the token file's contents in an \"Authorization: Bearer\" header")
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, "the server is read-only")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// auditQuery reads the filters of an /audit request: since (a duration like
+// 24h, default 24h), type, and limit
+func auditQuery(r *http.Request) (*security.AuditQuery, int, error) {
+	values := r.URL.Query()
+	since := 24 * time.Hour
+	if value := values.Get("since"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return nil, 0, fmt.Errorf("invalid since %q: use a duration like 1h or 24h", value)
+		}
+		since = d
+	}
+	limit := DefaultAuditLimit
+	if value := values.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, 0, fmt.Errorf("invalid limit %q: use a positive number", value)
+		}
+		limit = n
+	}
+	start := time.Now().Add(-since)
+	return &security.AuditQuery{StartTime: &start, EventType: values.Get("type")}, limit, nil
+}
+
+// validToken reports whether r carries token, compared in constant time
+func validToken(r *http.Request, token string) bool {
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// isLoopbackHost reports whether a Host header names the local machine
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	data, _ := json.Marshal(map[string]string{"error": message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
@@ -0,0 +1,108 @@
+package serve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestHandler(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	err := config.UpdateRegistry(func(registry *config.Registry) error {
+		registry.GlobalActive = true
+		registry.AddWrapper(config.WrapperEntry{Original: "/usr/local/bin/npm", Config: "/project/ribbin.jsonc"})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	security.LogBypassUsage("npm", 42)
+
+	token, err := NewToken()
+	if err != nil {
+		t.Fatalf("NewToken error: %v", err)
+	}
+	tokenPath, err := TokenPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(tokenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("token file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	handler := Handler(token, "1.2.3")
+	get := func(method, target, host, auth string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		req.Host = host
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	bearer := "Bearer " + token
+
+	rec := get("GET", "/status", "127.0.0.1:7471", bearer)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/status = %d: %s", rec.Code, rec.Body)
+	}
+	var status Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if !status.GlobalActive || status.Wrapped != 1 || status.Version != "1.2.3" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	rec = get("GET", "/registry", "localhost:7471", bearer)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/usr/local/bin/npm") {
+		t.Errorf("/registry = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = get("GET", "/audit?type=bypass.used", "127.0.0.1:7471", bearer)
+	var events []security.AuditEvent
+	if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+		t.Fatalf("/audit = %d: %s", rec.Code, rec.Body)
+	}
+	if len(events) != 1 || events[0].Event != security.EventBypassUsed {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	refused := []struct {
+		name   string
+		method string
+		target string
+		host   string
+		auth   string
+		want   int
+	}{
+		{"no token", "GET", "/status", "127.0.0.1:7471", "", http.StatusUnauthorized},
+		{"wrong token", "GET", "/status", "127.0.0.1:7471", "Bearer nope", http.StatusUnauthorized},
+		{"other host", "GET", "/status", "attacker.example:7471", bearer, http.StatusForbidden},
+		{"write", "POST", "/registry", "127.0.0.1:7471", bearer, http.StatusMethodNotAllowed},
+		{"unknown endpoint", "GET", "/wrap", "127.0.0.1:7471", bearer, http.StatusNotFound},
+		{"bad limit", "GET", "/audit?limit=-1", "127.0.0.1:7471", bearer, http.StatusBadRequest},
+	}
+	for _, tt := range refused {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := get(tt.method, tt.target, tt.host, tt.auth); rec.Code != tt.want {
+				t.Errorf("got %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -221,6 +221,7 @@ func (env *IntegrationEnv) GitCommit(dir, message string) {
 // NewRegistry creates an empty registry.
 func (env *IntegrationEnv) NewRegistry() *config.Registry {
 	return &config.Registry{
+		Version:           config.RegistryVersion,
 		Wrappers:          make(map[string]config.WrapperEntry),
 		ShellActivations:  make(map[int]config.ShellActivationEntry),
 		ConfigActivations: make(map[string]config.ConfigActivationEntry),
@@ -0,0 +1,36 @@
+package wrap
+
+import (
+	"fmt"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// Adopt takes over an orphaned sidecar at binaryPath: the wrapper symlink is
+// recreated (or re-pointed) to ribbinPath, missing metadata is recorded from
+// the sidecar, and the wrapper is registered for configPath. A binary that
+// was reinstalled over the wrapper is left alone, since its sidecar is stale.
+func Adopt(binaryPath, ribbinPath, configPath string, registry *config.Registry) error {
+	if !HasSidecar(binaryPath) {
+		return fmt.Errorf("%s has no sidecar to adopt", binaryPath)
+	}
+
+	check, _, err := relink(binaryPath, ribbinPath, func(check RelinkCheck) bool {
+		return check.Status != RelinkReplaced && check.Status != RelinkNotNeeded
+	})
+	if err != nil {
+		return err
+	}
+	if check.Status == RelinkReplaced {
+		return fmt.Errorf("%s was replaced since it was wrapped; restore it instead to remove the stale sidecar", binaryPath)
+	}
+
+	if !HasMetadata(binaryPath) {
+		_ = recordMetadata(binaryPath, sidecarFor(binaryPath), ribbinPath)
+	}
+	registry.AddWrapper(config.WrapperEntry{
+		Original: binaryPath,
+		Config:   configPath,
+	})
+	return nil
+}
@@ -0,0 +1,104 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestAdopt(t *testing.T) {
+	// setup wraps dir/tool with a ribbin at dir/old/ribbin, drops it from the
+	// registry, and creates a new ribbin at dir/new/ribbin
+	setup := func(t *testing.T) (binaryPath, newRibbin string, registry *config.Registry) {
+		t.Helper()
+		dir := t.TempDir()
+		oldRibbin := filepath.Join(dir, "old", "ribbin")
+		newRibbin = filepath.Join(dir, "new", "ribbin")
+		for _, path := range []string{oldRibbin, newRibbin} {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		binaryPath = filepath.Join(dir, "tool")
+		if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho tool"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		registry = &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+		if err := Install(binaryPath, oldRibbin, registry, "/old/ribbin.jsonc"); err != nil {
+			t.Fatalf("Install error: %v", err)
+		}
+		registry.RemoveWrapper(binaryPath)
+		return binaryPath, newRibbin, registry
+	}
+
+	assertAdopted := func(t *testing.T, binaryPath, newRibbin string, registry *config.Registry) {
+		t.Helper()
+		target, err := os.Readlink(binaryPath)
+		if err != nil {
+			t.Fatalf("Readlink error: %v", err)
+		}
+		if target != newRibbin {
+			t.Errorf("wrapper points at %s, want %s", target, newRibbin)
+		}
+		if entry, _ := registry.Wrapper(binaryPath); entry.Original != binaryPath || entry.Config != "/project/ribbin.jsonc" {
+			t.Errorf("registry entry = %+v", entry)
+		}
+		if !HasMetadata(binaryPath) {
+			t.Error("expected metadata")
+		}
+	}
+
+	t.Run("re-points wrapper whose ribbin is gone", func(t *testing.T) {
+		binaryPath, newRibbin, registry := setup(t)
+		os.RemoveAll(filepath.Join(filepath.Dir(binaryPath), "old"))
+
+		if err := Adopt(binaryPath, newRibbin, "/project/ribbin.jsonc", registry); err != nil {
+			t.Fatalf("Adopt error: %v", err)
+		}
+		assertAdopted(t, binaryPath, newRibbin, registry)
+	})
+
+	t.Run("recreates missing wrapper and metadata", func(t *testing.T) {
+		binaryPath, newRibbin, registry := setup(t)
+		os.Remove(binaryPath)
+		os.Remove(MetadataPath(binaryPath))
+
+		if err := Adopt(binaryPath, newRibbin, "/project/ribbin.jsonc", registry); err != nil {
+			t.Fatalf("Adopt error: %v", err)
+		}
+		assertAdopted(t, binaryPath, newRibbin, registry)
+	})
+
+	t.Run("refuses binary reinstalled over wrapper", func(t *testing.T) {
+		binaryPath, newRibbin, registry := setup(t)
+		os.Remove(binaryPath)
+		if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho reinstalled"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := Adopt(binaryPath, newRibbin, "/project/ribbin.jsonc", registry); err == nil {
+			t.Fatal("expected error adopting a reinstalled binary")
+		}
+		if content, _ := os.ReadFile(binaryPath); string(content) != "#!/bin/sh\necho reinstalled" {
+			t.Error("reinstalled binary should be left alone")
+		}
+		if _, ok := registry.LookupWrapper("tool", ""); ok {
+			t.Error("reinstalled binary should not be registered")
+		}
+	})
+
+	t.Run("refuses binary without sidecar", func(t *testing.T) {
+		binaryPath, newRibbin, registry := setup(t)
+		os.Remove(binaryPath + ".ribbin-original")
+
+		if err := Adopt(binaryPath, newRibbin, "/project/ribbin.jsonc", registry); err == nil {
+			t.Fatal("expected error without a sidecar")
+		}
+	})
+}
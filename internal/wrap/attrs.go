@@ -0,0 +1,144 @@
+package wrap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// maxXattrSize is the largest extended attribute recorded; larger ones,
+// like a macOS resource fork, are left out of the metadata
+const maxXattrSize = 64 * 1024
+
+// FileAttributes are the attributes of an original binary recorded when it
+// was wrapped, so they can be put back when it is restored even if
+// something changed them in between
+type FileAttributes struct {
+	// Mode is the permission bits in octal, with the setuid, setgid and
+	// sticky bits, like "2755"
+	Mode    string    `json:"mode"`
+	UID     int       `json:"uid"`
+	GID     int       `json:"gid"`
+	ModTime time.Time `json:"mtime"`
+	// Xattrs are the extended attributes, like macOS's com.apple.quarantine
+	Xattrs map[string][]byte `json:"xattrs,omitempty"`
+}
+
+// captureAttributes returns the attributes of the regular file at path, or
+// nil if it isn't one
+func captureAttributes(path string) *FileAttributes {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	uid, gid := fileOwner(info)
+	attrs := &FileAttributes{
+		Mode:    strconv.FormatUint(uint64(unixMode(info.Mode())), 8),
+		UID:     uid,
+		GID:     gid,
+		ModTime: info.ModTime(),
+	}
+	names, _ := listXattrs(path)
+	for _, name := range names {
+		value, err := getXattr(path, name)
+		if err != nil || len(value) > maxXattrSize {
+			continue
+		}
+		if attrs.Xattrs == nil {
+			attrs.Xattrs = make(map[string][]byte)
+		}
+		attrs.Xattrs[name] = value
+	}
+	return attrs
+}
+
+// restoreAttributes puts back the attributes recorded in attrs on the file
+// at path, changing only those that differ. The owner is only changed when
+// running as root. Each attribute is tried even if another fails.
+func restoreAttributes(path string, attrs *FileAttributes) error {
+	if attrs == nil {
+		return nil
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	var errs []error
+	for name, value := range attrs.Xattrs {
+		if current, err := getXattr(path, name); err == nil && bytes.Equal(current, value) {
+			continue
+		}
+		if err := setXattr(path, name, value); err != nil {
+			errs = append(errs, fmt.Errorf("extended attribute %s: %w", name, err))
+		}
+	}
+
+	// Changing the owner clears the setuid and setgid bits, so it goes
+	// before the mode
+	if uid, gid := fileOwner(info); os.Geteuid() == 0 && (uid != attrs.UID || gid != attrs.GID) {
+		if err := os.Lchown(path, attrs.UID, attrs.GID); err != nil {
+			errs = append(errs, fmt.Errorf("owner: %w", err))
+		}
+	}
+	if mode, err := strconv.ParseUint(attrs.Mode, 8, 32); err != nil {
+		errs = append(errs, fmt.Errorf("mode: invalid mode %q", attrs.Mode))
+	} else if current, err := os.Lstat(path); err == nil && unixMode(current.Mode()) != uint32(mode) {
+		if err := os.Chmod(path, fileMode(uint32(mode))); err != nil {
+			errs = append(errs, fmt.Errorf("mode: %w", err))
+		}
+	}
+	if !attrs.ModTime.IsZero() && !info.ModTime().Equal(attrs.ModTime) {
+		if err := os.Chtimes(path, time.Time{}, attrs.ModTime); err != nil {
+			errs = append(errs, fmt.Errorf("modification time: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// restoreOriginalAttributes restores the attributes meta recorded for the
+// original now back at binaryPath, warning about those it can't
+func restoreOriginalAttributes(binaryPath string, meta *WrapperMetadata) {
+	if meta == nil {
+		return
+	}
+	if err := restoreAttributes(binaryPath, meta.OriginalAttributes); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: restored %s, but not all of its attributes: %v\n", binaryPath, err)
+	}
+}
+
+// unixMode returns the permission bits of mode as a Unix mode, like 02755
+func unixMode(mode os.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return bits
+}
+
+// fileMode converts a Unix mode, like 02755, to an os.FileMode
+func fileMode(bits uint32) os.FileMode {
+	mode := os.FileMode(bits & 0o777)
+	if bits&0o4000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if bits&0o2000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if bits&0o1000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
@@ -0,0 +1,164 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+// benchFixture is a project layout to measure wrapper decisions in
+type benchFixture struct {
+	name string
+	// files maps paths relative to the project to their contents
+	files map[string]string
+	// cwd is where the wrapped command runs, relative to the project
+	cwd string
+	// cmd is the wrapped command
+	cmd string
+}
+
+func benchWrappers(n int) string {
+	entries := make([]string, n)
+	for i := range entries {
+		entries[i] = fmt.Sprintf(`"cmd%d": {"action": "block", "message": "use something else"}`, i)
+	}
+	return "{" + strings.Join(entries, ",\n") + "}"
+}
+
+func benchFixtures() []benchFixture {
+	// Five scopes nested inside each other, each extending the one above
+	nested := []string{`"s0": {"path": "a", "extends": ["root"], "wrappers": {"cmd0": {"action": "passthrough"}}}`}
+	dir := "a"
+	for i := 1; i < 5; i++ {
+		dir += "/" + string(rune('a'+i))
+		nested = append(nested, fmt.Sprintf(`"s%d": {"path": %q, "extends": ["root.s%d"], "wrappers": {"cmd%d": {"action": "passthrough"}}}`, i, dir, i-1, i))
+	}
+
+	return []benchFixture{
+		{
+			name:  "10 wrappers",
+			files: map[string]string{"ribbin.jsonc": `{"wrappers": ` + benchWrappers(10) + `}`},
+			cwd:   ".",
+			cmd:   "cmd5",
+		},
+		{
+			name:  "100 wrappers",
+			files: map[string]string{"ribbin.jsonc": `{"wrappers": ` + benchWrappers(100) + `}`},
+			cwd:   ".",
+			cmd:   "cmd50",
+		},
+		{
+			name: "5 nested scopes",
+			files: map[string]string{
+				"ribbin.jsonc": `{"wrappers": ` + benchWrappers(10) + `, "scopes": {` + strings.Join(nested, ",\n") + `}}`,
+			},
+			cwd: dir,
+			cmd: "cmd9",
+		},
+		{
+			name: "external extends",
+			files: map[string]string{
+				"ribbin.jsonc": `{"scopes": {"app": {"path": "app", "extends": ["root", "./shared/team.jsonc#root.strict"]}}}`,
+				"shared/team.jsonc": `{
+					"wrappers": ` + benchWrappers(10) + `,
+					"scopes": {"strict": {"extends": ["../org.jsonc"], "wrappers": {"cmd0": {"action": "passthrough"}}}}
+				}`,
+				"org.jsonc": `{"wrappers": ` + benchWrappers(20) + `}`,
+			},
+			cwd: "app",
+			cmd: "cmd15",
+		},
+	}
+}
+
+// setupBenchFixture writes the fixture to a temp project with ribbin globally
+// active, and returns the directory to run in
+func setupBenchFixture(b *testing.B, fixture benchFixture) string {
+	b.Helper()
+	tmpDir, err := filepath.EvalSymlinks(b.TempDir())
+	if err != nil {
+		b.Fatalf("failed to resolve symlinks: %v", err)
+	}
+	b.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
+	b.Setenv("XDG_CACHE_HOME", filepath.Join(tmpDir, "cache"))
+
+	projectDir := filepath.Join(tmpDir, "project")
+	for name, content := range fixture.files {
+		path := filepath.Join(projectDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			b.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			b.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	cwd := filepath.Join(projectDir, fixture.cwd)
+	if err := os.MkdirAll(cwd, 0755); err != nil {
+		b.Fatalf("failed to create cwd: %v", err)
+	}
+
+	registry, err := config.LoadRegistry()
+	if err != nil {
+		b.Fatalf("failed to load registry: %v", err)
+	}
+	registry.GlobalActive = true
+	if err := config.SaveRegistry(registry); err != nil {
+		b.Fatalf("failed to save registry: %v", err)
+	}
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		b.Fatalf("failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(cwd); err != nil {
+		b.Fatalf("failed to chdir: %v", err)
+	}
+	b.Cleanup(func() { os.Chdir(origDir) })
+	return cwd
+}
+
+// BenchmarkShimDecision measures what a wrapper does before it execs:
+// loading the registry and finding the wrapper for the command. "uncached"
+// resolves the config every time; "cached" reads the decision cache.
+func BenchmarkShimDecision(b *testing.B) {
+	for _, fixture := range benchFixtures() {
+		for _, cached := range []bool{false, true} {
+			mode := "uncached"
+			if cached {
+				mode = "cached"
+			}
+			b.Run(fixture.name+"/"+mode, func(b *testing.B) {
+				cwd := setupBenchFixture(b, fixture)
+				if !cached {
+					b.Setenv("RIBBIN_DECISION_CACHE", "0")
+				}
+
+				decide := func() wrapperLookup {
+					registry, err := config.LoadRegistry()
+					if err != nil {
+						b.Fatalf("failed to load registry: %v", err)
+					}
+					return lookupWrapper(registry, cwd, fixture.cmd)
+				}
+
+				// Check the fixture resolves, and warm the cache
+				if lookup := decide(); !lookup.Exists {
+					b.Fatalf("expected a wrapper for %s, got: %s", fixture.cmd, lookup.Reason)
+				}
+				if cached && lookupDecision(cwd) == nil {
+					b.Fatal("expected decision to be cached")
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					decide()
+				}
+			})
+		}
+	}
+}
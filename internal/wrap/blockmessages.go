@@ -0,0 +1,134 @@
+package wrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// blockMessagesFileName records, in ribbin's state directory, when each
+// command's full block message was last shown in each terminal session
+const blockMessagesFileName = "block-messages.json"
+
+// blockMessagesTimeout bounds waiting for the block message state lock
+const blockMessagesTimeout = 500 * time.Millisecond
+
+// blockMessagesState maps "<session>:<command>" to when the command's full
+// block message was last shown in that terminal session
+type blockMessagesState struct {
+	Shown map[string]time.Time `json:"shown"`
+}
+
+// BlockMessagesPath returns the path of the block message state file
+func BlockMessagesPath() (string, error) {
+	stateDir, err := security.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, blockMessagesFileName), nil
+}
+
+func readBlockMessagesState(statePath string) (*blockMessagesState, error) {
+	state := &blockMessagesState{Shown: map[string]time.Time{}}
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("corrupt block message file %s: %w", statePath, err)
+	}
+	if state.Shown == nil {
+		state.Shown = map[string]time.Time{}
+	}
+	return state, nil
+}
+
+// blockMessageInterval returns how long to wait before showing a command's
+// full block message again in a session, from RIBBIN_BLOCK_MESSAGE_INTERVAL
+// or the user settings. An invalid variable is ignored.
+func blockMessageInterval() time.Duration {
+	seconds := config.DefaultBlockMessageInterval
+	if value := os.Getenv("RIBBIN_BLOCK_MESSAGE_INTERVAL"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			return time.Duration(n) * time.Second
+		}
+		verboseLog("ignoring RIBBIN_BLOCK_MESSAGE_INTERVAL=%s: not a number of seconds", value)
+	} else if settings, err := config.LoadSettings(); err == nil && settings.BlockMessageInterval != nil {
+		seconds = *settings.BlockMessageInterval
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// recentBlockMessage reports whether cmdName's full block message was shown
+// in this terminal session less than interval before now, and how long ago.
+// If not, the full message is about to be shown, and now is recorded.
+// Failing to read or record the state shows the full message, which is
+// never worse than before.
+func recentBlockMessage(cmdName string, now time.Time, interval time.Duration) (time.Duration, bool) {
+	if interval <= 0 {
+		return 0, false
+	}
+	if _, err := security.EnsureStateDir(); err != nil {
+		verboseLog("block messages: %v", err)
+		return 0, false
+	}
+	statePath, err := BlockMessagesPath()
+	if err != nil {
+		verboseLog("block messages: %v", err)
+		return 0, false
+	}
+
+	key := fmt.Sprintf("%d:%s", sessionID(), cmdName)
+	var ago time.Duration
+	recent := false
+	err = security.WithLock(statePath, blockMessagesTimeout, func() error {
+		state, err := readBlockMessagesState(statePath)
+		if err != nil {
+			return err
+		}
+		if shown, ok := state.Shown[key]; ok && now.Sub(shown) >= 0 && now.Sub(shown) < interval {
+			ago, recent = now.Sub(shown), true
+			return nil
+		}
+
+		// Entries past the interval no longer matter, so the file stays tiny
+		for k, shown := range state.Shown {
+			if now.Sub(shown) >= interval {
+				delete(state.Shown, k)
+			}
+		}
+		state.Shown[key] = now.UTC()
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(statePath, data, 0600)
+	})
+	if err != nil {
+		verboseLog("block messages: %v", err)
+		return 0, false
+	}
+	return ago, recent
+}
+
+// printBlockReminder prints the one-line form of a block message, for a
+// command blocked again soon after its full message was shown
+func printBlockReminder(cmd, message string, ago time.Duration) {
+	if message == "" {
+		message = "This command is blocked by ribbin."
+	}
+	if first, _, multiline := strings.Cut(message, "\n"); multiline {
+		message = first + " ..."
+	}
+	fmt.Fprintf(os.Stderr, "ribbin: '%s' is blocked: %s (full message shown %s ago)\n", cmd, message, ago.Round(time.Second))
+}
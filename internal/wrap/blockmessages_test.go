@@ -0,0 +1,98 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestRecentBlockMessage(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	start := time.Now()
+	interval := 30 * time.Second
+
+	if _, recent := recentBlockMessage("npm", start, interval); recent {
+		t.Error("the first block should show the full message")
+	}
+	ago, recent := recentBlockMessage("npm", start.Add(10*time.Second), interval)
+	if !recent || ago != 10*time.Second {
+		t.Errorf("a block 10s later should be a reminder, got recent=%v ago=%s", recent, ago)
+	}
+	if _, recent := recentBlockMessage("yarn", start.Add(10*time.Second), interval); recent {
+		t.Error("another command's first block should show the full message")
+	}
+	if _, recent := recentBlockMessage("npm", start.Add(31*time.Second), interval); recent {
+		t.Error("a block after the interval should show the full message again")
+	}
+	if _, recent := recentBlockMessage("npm", start.Add(40*time.Second), interval); !recent {
+		t.Error("the interval should restart from the last full message")
+	}
+	if _, recent := recentBlockMessage("npm", start.Add(41*time.Second), 0); recent {
+		t.Error("an interval of 0 should always show the full message")
+	}
+	recentBlockMessage("tsc", start.Add(2*time.Minute), interval)
+
+	statePath, err := BlockMessagesPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := readBlockMessagesState(statePath)
+	if err != nil {
+		t.Fatalf("readBlockMessagesState error: %v", err)
+	}
+	if len(state.Shown) != 1 {
+		t.Errorf("entries past the interval should be pruned, got %v", state.Shown)
+	}
+}
+
+func TestBlockMessageInterval(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	if got := blockMessageInterval(); got != 30*time.Second {
+		t.Errorf("default interval = %s, want 30s", got)
+	}
+
+	settingsPath := filepath.Join(configHome, "ribbin", "settings.jsonc")
+	if err := os.MkdirAll(filepath.Dir(settingsPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(settingsPath, []byte(`{"blockMessageInterval": 0}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := blockMessageInterval(); got != 0 {
+		t.Errorf("interval from settings = %s, want 0s", got)
+	}
+
+	t.Setenv("RIBBIN_BLOCK_MESSAGE_INTERVAL", "120")
+	if got := blockMessageInterval(); got != 2*time.Minute {
+		t.Errorf("interval from RIBBIN_BLOCK_MESSAGE_INTERVAL = %s, want 2m0s", got)
+	}
+}
+
+func TestPrintBlockReminder(t *testing.T) {
+	oldStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	printBlockReminder("npm", "Use pnpm instead.\nSee the contributing guide.", 12*time.Second)
+
+	w.Close()
+	os.Stderr = oldStderr
+
+	buf := make([]byte, 1024)
+	n, _ := r.Read(buf)
+	output := string(buf[:n])
+
+	want := "ribbin: 'npm' is blocked: Use pnpm instead. ... (full message shown 12s ago)\n"
+	if output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+	if strings.Count(output, "\n") != 1 {
+		t.Error("a reminder should be one line")
+	}
+}
@@ -0,0 +1,101 @@
+package wrap
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// A redirect to "builtin:<name>" runs a target built into ribbin inside the
+// wrapper, the same on every platform, in place of a script teams would
+// each write: builtin:trash takes the place of rm, while builtin:confirm
+// and builtin:log-and-pass run the original once they're done, as a
+// passthrough does.
+
+// runBuiltinRedirect runs the built-in redirect target name for cmdName.
+// message is the wrapper's message, shown by builtin:confirm. An unknown
+// target fails open, as other redirects do.
+func runBuiltinRedirect(name, cmdName, originalPath, configPath, message string, args []string) error {
+	target := config.BuiltinRedirectPrefix + name
+	switch name {
+	case config.BuiltinTrash:
+		verboseLogDecision(cmdName, "REDIRECT", target)
+		os.Exit(runTrash(cmdName, args))
+		return nil // unreachable, but satisfies compiler
+
+	case config.BuiltinConfirm:
+		if !confirmRun(cmdName, args, message) {
+			verboseLogDecision(cmdName, "BLOCKED", target+": not confirmed")
+			os.Exit(1)
+			return nil // unreachable, but satisfies compiler
+		}
+		verboseLogDecision(cmdName, "PASS", target+": confirmed")
+
+	case config.BuiltinLogAndPass:
+		logInvocation(cmdName, originalPath, configPath, args)
+		verboseLogDecision(cmdName, "PASS", target+": logged")
+
+	default:
+		// Fail-open: warn and passthrough
+		verboseLogDecision(cmdName, "PASS", fmt.Sprintf("unknown built-in redirect %q", target))
+		fmt.Fprintf(os.Stderr, "ribbin: redirect failed (%s), using original: unknown built-in redirect %q\n", cmdName, target)
+	}
+	return execOriginal(originalPath, args)
+}
+
+// confirmRun shows message, if any, and asks at the terminal whether to run
+// cmdName with args, reporting whether the answer was yes. Without a
+// terminal to ask on, the answer is no.
+func confirmRun(cmdName string, args []string, message string) bool {
+	if message != "" {
+		fmt.Fprintf(os.Stderr, "ribbin: %s\n", message)
+	}
+	commandLine := strings.Join(append([]string{cmdName}, args...), " ")
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stderr) {
+		fmt.Fprintf(os.Stderr, "ribbin: '%s' must be confirmed at a terminal; not running it\n", commandLine)
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "ribbin: run '%s'? [y/N] ", commandLine)
+	return readConfirmation(os.Stdin)
+}
+
+// readConfirmation reads a line from r, a byte at a time so that none of
+// the command's input is taken, and reports whether it is y or yes
+func readConfirmation(r io.Reader) bool {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		if n, err := r.Read(buf); n == 0 || err != nil || buf[0] == '\n' {
+			break
+		}
+		line = append(line, buf[0])
+	}
+	switch strings.ToLower(strings.TrimSpace(string(line))) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// logInvocation records in the audit log that cmdName ran through
+// builtin:log-and-pass
+func logInvocation(cmdName, originalPath, configPath string, args []string) {
+	cwd, _ := os.Getwd()
+	security.LogInvocation(originalPath, map[string]string{
+		"command": cmdName,
+		"args":    strings.Join(args, " "),
+		"cwd":     cwd,
+		"config":  configPath,
+		"pid":     fmt.Sprintf("%d", os.Getpid()),
+	})
+}
@@ -0,0 +1,58 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/security"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestReadConfirmation(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{" y \n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"yep\n", false},
+		{"", false},
+		{"y", true},
+	}
+	for _, tt := range tests {
+		if got := readConfirmation(strings.NewReader(tt.input)); got != tt.want {
+			t.Errorf("readConfirmation(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	t.Run("reads no further than the answer", func(t *testing.T) {
+		r := strings.NewReader("y\ninput for the command\n")
+		readConfirmation(r)
+		if r.Len() != len("input for the command\n") {
+			t.Errorf("%d bytes left, want the command's input untouched", r.Len())
+		}
+	})
+}
+
+func TestLogInvocation(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", home+"/state")
+
+	logInvocation("terraform", "/usr/local/bin/terraform.ribbin-original", "/work/ribbin.jsonc", []string{"apply", "-auto-approve"})
+
+	events, err := security.QueryAuditLog(&security.AuditQuery{EventType: security.EventInvocationLogged})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d invocation.logged events, want 1", len(events))
+	}
+	details := events[0].Details
+	if details["command"] != "terraform" || details["args"] != "apply -auto-approve" || details["config"] != "/work/ribbin.jsonc" || details["cwd"] == "" {
+		t.Errorf("details = %v", details)
+	}
+}
@@ -0,0 +1,34 @@
+package wrap
+
+import (
+	"os"
+
+	"github.com/happycollision/ribbin/internal/process"
+)
+
+// BinaryUsage is what else uses a binary that is about to be wrapped
+type BinaryUsage struct {
+	// Links is the number of hard links to the binary, counting its own path
+	Links uint64
+	// PIDs are the processes running the binary or holding it open, as far
+	// as they can be seen (see process.FileUsers)
+	PIDs []int
+}
+
+// InspectBinary reports the hard links to the binary at path and the
+// processes using it. Wrapping moves the binary to its sidecar, so its other
+// hard links stay unwrapped, and a package manager rewriting the file through
+// one of them changes the original behind ribbin's back. A process using it
+// may be an install that hasn't finished writing it. A symlink has neither
+// concern, since only the link is moved, so it reports one link and no
+// processes.
+func InspectBinary(path string) BinaryUsage {
+	usage := BinaryUsage{Links: 1}
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return usage
+	}
+	usage.Links = linkCount(info)
+	usage.PIDs, _ = process.FileUsers(path)
+	return usage
+}
@@ -0,0 +1,82 @@
+package wrap
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestInspectBinary(t *testing.T) {
+	dir := t.TempDir()
+	binaryPath := filepath.Join(dir, "node")
+	if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho node"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if usage := InspectBinary(binaryPath); usage.Links != 1 || len(usage.PIDs) != 0 {
+		t.Errorf("InspectBinary = %+v, want one link and no processes", usage)
+	}
+
+	storePath := filepath.Join(dir, "store-node")
+	if err := os.Link(binaryPath, storePath); err != nil {
+		t.Fatal(err)
+	}
+	if usage := InspectBinary(binaryPath); usage.Links != 2 {
+		t.Errorf("InspectBinary = %+v, want two links", usage)
+	}
+
+	linkPath := filepath.Join(dir, "node-link")
+	if err := os.Symlink(binaryPath, linkPath); err != nil {
+		t.Fatal(err)
+	}
+	if usage := InspectBinary(linkPath); usage.Links != 1 {
+		t.Errorf("a symlink should report one link, got %+v", usage)
+	}
+}
+
+func TestInstallRecordsHardLinks(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	ribbinPath := filepath.Join(dir, "ribbin")
+	binaryPath := filepath.Join(dir, "node")
+	storePath := filepath.Join(dir, "store-node")
+	for _, path := range []string{ribbinPath, binaryPath} {
+		if err := os.WriteFile(path, []byte("#!/bin/sh\necho "+filepath.Base(path)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(binaryPath, storePath); err != nil {
+		t.Fatal(err)
+	}
+	registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+
+	if err := Install(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc"); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	meta, err := LoadMetadata(binaryPath)
+	if err != nil || meta.OriginalLinks != 2 {
+		t.Fatalf("metadata should record two links, got %+v, %v", meta, err)
+	}
+
+	// The package manager replaces its copy
+	if err := os.Remove(storePath); err != nil {
+		t.Fatal(err)
+	}
+	oldStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	err = Uninstall(binaryPath, registry)
+	w.Close()
+	os.Stderr = oldStderr
+	if err != nil {
+		t.Fatalf("Uninstall error: %v", err)
+	}
+	output, _ := io.ReadAll(r)
+	if !strings.Contains(string(output), "had 2 hard links") {
+		t.Errorf("expected a divergence warning, got %q", output)
+	}
+}
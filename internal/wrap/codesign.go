@@ -0,0 +1,83 @@
+package wrap
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// CodeSignature is what macOS's codesign reports about a signed binary
+type CodeSignature struct {
+	Identifier string
+	// Format is like "app bundle with Mach-O universal (x86_64 arm64)"
+	Format string
+	// Authority is the signing certificate chain, the signer first
+	Authority []string
+	TeamID    string
+	// Adhoc is true for a signature without a certificate, as the linker
+	// gives every binary built for Apple silicon. Renaming those is safe.
+	Adhoc bool
+}
+
+// Signer names who signed the binary, for messages
+func (s *CodeSignature) Signer() string {
+	switch {
+	case len(s.Authority) > 0:
+		return s.Authority[0]
+	case s.TeamID != "":
+		return "team " + s.TeamID
+	case s.Adhoc:
+		return "an ad-hoc signature"
+	default:
+		return s.Identifier
+	}
+}
+
+// Gatekept reports whether the signature is one Gatekeeper checks, which
+// renaming the binary or running it from another path can upset
+func (s *CodeSignature) Gatekept() bool {
+	return s != nil && !s.Adhoc
+}
+
+// parseCodesign reads the output of 'codesign -dvv', returning nil when it
+// says the binary isn't signed
+func parseCodesign(output string) *CodeSignature {
+	if strings.Contains(output, "not signed at all") {
+		return nil
+	}
+	var sig CodeSignature
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Identifier":
+			sig.Identifier = value
+		case "Format":
+			sig.Format = value
+		case "Authority":
+			sig.Authority = append(sig.Authority, value)
+		case "TeamIdentifier":
+			if value != "not set" {
+				sig.TeamID = value
+			}
+		case "Signature":
+			sig.Adhoc = value == "adhoc"
+		}
+	}
+	if sig.Identifier == "" {
+		return nil
+	}
+	return &sig
+}
+
+// InAppBundle reports whether path is inside a macOS app bundle, like
+// /Applications/Visual Studio Code.app/Contents/Resources/app/bin/code
+func InAppBundle(path string) bool {
+	for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if filepath.Base(dir) == "Contents" && strings.HasSuffix(filepath.Dir(dir), ".app") {
+			return true
+		}
+	}
+	return false
+}
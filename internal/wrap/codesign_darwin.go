@@ -0,0 +1,20 @@
+//go:build darwin
+
+package wrap
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// CodeSignatureOf returns the code signature of the binary at path, or nil
+// if it isn't signed
+func CodeSignatureOf(path string) (*CodeSignature, error) {
+	// codesign writes what it finds to stderr, and fails for unsigned binaries
+	output, err := exec.Command("/usr/bin/codesign", "-dvv", path).CombinedOutput()
+	if err != nil && !strings.Contains(string(output), "not signed at all") {
+		return nil, fmt.Errorf("codesign: %s", strings.TrimSpace(string(output)))
+	}
+	return parseCodesign(string(output)), nil
+}
@@ -0,0 +1,68 @@
+package wrap
+
+import (
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestParseCodesign(t *testing.T) {
+	signed := `Executable=/Applications/Visual Studio Code.app/Contents/MacOS/Electron
+Identifier=com.microsoft.VSCode
+Format=app bundle with Mach-O universal (x86_64 arm64)
+CodeDirectory v=20500 size=758 flags=0x10000(runtime) hashes=13+7 location=embedded
+Signature size=9012
+Authority=Developer ID Application: Microsoft Corporation (UBF8T346G9)
+Authority=Developer ID Certification Authority
+Authority=Apple Root CA
+Timestamp=Oct 1, 2026 at 10:00:00
+TeamIdentifier=UBF8T346G9
+`
+	sig := parseCodesign(signed)
+	if sig == nil {
+		t.Fatal("parseCodesign returned nil for a signed binary")
+	}
+	if sig.Identifier != "com.microsoft.VSCode" || sig.TeamID != "UBF8T346G9" || len(sig.Authority) != 3 {
+		t.Errorf("parseCodesign = %+v", sig)
+	}
+	if !sig.Gatekept() || sig.Signer() != "Developer ID Application: Microsoft Corporation (UBF8T346G9)" {
+		t.Errorf("Gatekept() = %v, Signer() = %q", sig.Gatekept(), sig.Signer())
+	}
+
+	adhoc := parseCodesign(`Executable=/opt/homebrew/bin/jq
+Identifier=jq
+Format=Mach-O thin (arm64)
+CodeDirectory v=20400 size=2000 flags=0x20002(adhoc,linker-signed) hashes=59+0 location=embedded
+Signature=adhoc
+TeamIdentifier=not set
+`)
+	if adhoc == nil || !adhoc.Adhoc || adhoc.Gatekept() || adhoc.TeamID != "" {
+		t.Errorf("parseCodesign(adhoc) = %+v", adhoc)
+	}
+
+	if sig := parseCodesign("/usr/local/bin/tool: code object is not signed at all\n"); sig != nil {
+		t.Errorf("parseCodesign(unsigned) = %+v, want nil", sig)
+	}
+	var none *CodeSignature
+	if none.Gatekept() {
+		t.Error("a nil signature should not be gatekept")
+	}
+}
+
+func TestInAppBundle(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/Applications/Visual Studio Code.app/Contents/Resources/app/bin/code", true},
+		{"/Applications/Docker.app/Contents/MacOS/Docker", true},
+		{"/usr/local/bin/code", false},
+		{"/Users/me/app/Contents/bin/tool", false},
+		{"/opt/tools.app/bin/tool", false},
+	}
+	for _, tt := range tests {
+		if got := InAppBundle(tt.path); got != tt.want {
+			t.Errorf("InAppBundle(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package wrap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// decisionCacheVersion is bumped whenever the cached data or the way it is
+// computed changes, so entries written by an older ribbin are ignored.
+const decisionCacheVersion = 10
+
+// decision is what a wrapper needs from the project config to act in a
+// directory: which config governs it and the wrappers in effect there.
+// Working it out means walking up for a config, then parsing and resolving
+// it, which dominates the cost of a wrapped invocation. Wrappers therefore
+// cache it per directory and reuse it until anything it was computed from
+// changes.
+//
+// Only config-derived data is cached. Activation, snoozes, passthrough
+// rules and verify checks depend on the registry, the clock and the calling
+// process, and are still evaluated on every invocation.
+type decision struct {
+	Version       int    `json:"version"`
+	RibbinVersion string `json:"ribbin_version"`
+	Cwd           string `json:"cwd"`
+	// ConfigPath is the governing config, or "" if there is none
+	ConfigPath string `json:"config_path"`
+	// Dirs are the directories searched for configs. Creating or removing a
+	// config changes its directory's mtime.
+	Dirs []fileStamp `json:"dirs"`
+	// Files are the config files read to resolve Wrappers
+	Files []fileStamp `json:"files"`
+	// Absent are files that must not exist, like a user config that hasn't
+	// been created
+	Absent []string `json:"absent,omitempty"`
+	// Wrappers are the effective wrappers for Cwd, including the user
+	// config's
+	Wrappers map[string]config.ShimConfig `json:"wrappers,omitempty"`
+	// Scopes names the scope containing Cwd in ConfigPath and the parent
+	// configs it merges with, by config path (see matchedScopes)
+	Scopes map[string]string `json:"scopes,omitempty"`
+	// UserConfigPath is the user config, or "" if there is none
+	UserConfigPath string `json:"user_config_path,omitempty"`
+	// UserWrappers are the user config's wrappers alone, in effect when
+	// ribbin is active for the user config but not for ConfigPath
+	UserWrappers map[string]config.ShimConfig `json:"user_wrappers,omitempty"`
+
+	// cacheable is false if the decision depends on something that can't be
+	// checked cheaply, like a remote config
+	cacheable bool
+}
+
+// fileStamp identifies a version of a file or directory
+type fileStamp struct {
+	Path    string `json:"path"`
+	ModTime int64  `json:"mtime"`
+	Size    int64  `json:"size"`
+}
+
+func stampFile(path string) (fileStamp, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	return fileStamp{Path: path, ModTime: info.ModTime().UnixNano(), Size: info.Size()}, true
+}
+
+// current returns true if the file still matches the stamp
+func (s fileStamp) current() bool {
+	now, ok := stampFile(s.Path)
+	return ok && now == s
+}
+
+// decisionCacheEnabled returns false when RIBBIN_DECISION_CACHE=0
+func decisionCacheEnabled() bool {
+	return os.Getenv("RIBBIN_DECISION_CACHE") != "0"
+}
+
+// decisionCachePath returns where the decision for cwd is cached
+func decisionCachePath(cwd string) (string, error) {
+	cacheDir, err := security.GetCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(cwd))
+	return filepath.Join(cacheDir, "decisions", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// lookupDecision returns the cached decision for cwd, or nil if there is none
+// or anything it depends on has changed.
+func lookupDecision(cwd string) *decision {
+	if cwd == "" || !decisionCacheEnabled() {
+		return nil
+	}
+	path, err := decisionCachePath(cwd)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var d decision
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil
+	}
+	if d.Version != decisionCacheVersion || d.RibbinVersion != Version || d.Cwd != cwd {
+		return nil
+	}
+	for _, stamp := range append(d.Dirs, d.Files...) {
+		if !stamp.current() {
+			return nil
+		}
+	}
+	for _, path := range d.Absent {
+		if _, err := os.Lstat(path); err == nil {
+			return nil
+		}
+	}
+	return &d
+}
+
+// resolveDecision works out the decision for cwd under configPath from
+// scratch ("" if no config was found). It resolves with a fresh Resolver so
+// every config file read is recorded. Returns an error if the wrappers can't
+// be resolved.
+func resolveDecision(cwd, configPath string, projectConfig *config.ProjectConfig) (*decision, error) {
+	d := &decision{
+		Version:       decisionCacheVersion,
+		RibbinVersion: Version,
+		Cwd:           cwd,
+		ConfigPath:    configPath,
+		cacheable:     cwd != "",
+	}
+
+	// The directories searched for the config, and for parent configs it merges with
+	searchTop := string(filepath.Separator)
+	if configPath != "" {
+		resolver := config.NewResolver()
+		shims, err := resolveEffectiveShims(resolver, projectConfig, configPath, cwd)
+		if err != nil {
+			return nil, err
+		}
+		d.Wrappers = shims
+		d.Scopes = matchedScopes(projectConfig, configPath, cwd)
+
+		// Remote configs change without any local file changing
+		if resolver.UsedRemote() {
+			d.cacheable = false
+		}
+
+		// Until its policy is synced, a policy appearing changes no stamped file
+		if projectConfig.Sync != nil && config.LastSynced(configPath, projectConfig.Sync).IsZero() {
+			d.cacheable = false
+		}
+
+		// A package added to the workspace adds a scope
+		files := append([]string{configPath}, projectConfig.ImportedFiles()...)
+		files = append(files, projectConfig.WorkspaceFiles()...)
+		for _, path := range append(files, resolver.LoadedFiles()...) {
+			if stamp, ok := stampFile(path); ok {
+				d.Files = append(d.Files, stamp)
+			}
+		}
+
+		top, err := configChainTop(configPath, projectConfig)
+		if err != nil {
+			d.cacheable = false
+		} else if top != "" {
+			searchTop = filepath.Dir(top)
+		}
+	}
+
+	onConflict := ""
+	if projectConfig != nil {
+		onConflict = projectConfig.OnConflict
+	}
+	if err := addUserWrappers(d, onConflict); err != nil {
+		return nil, err
+	}
+
+	for dir := cwd; dir != "" && d.cacheable; {
+		stamp, ok := stampFile(dir)
+		if !ok {
+			d.cacheable = false
+			break
+		}
+		d.Dirs = append(d.Dirs, stamp)
+		parent := filepath.Dir(dir)
+		if dir == searchTop || parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return d, nil
+}
+
+// addUserWrappers adds the user config's wrappers to d, beneath the project
+// config's under its onConflict policy, and records what they were read
+// from. A user config that can't be loaded is left out rather than breaking
+// every project's wrappers, but a conflict the policy forbids is an error.
+func addUserWrappers(d *decision, onConflict string) error {
+	candidates, err := config.UserConfigCandidates()
+	if err != nil {
+		d.cacheable = false
+		return nil
+	}
+	userPath, err := config.FindUserConfig()
+	if err != nil {
+		verboseLog("user config: %v", err)
+		d.cacheable = false
+		return nil
+	}
+	for _, candidate := range candidates {
+		if candidate == userPath {
+			break
+		}
+		d.Absent = append(d.Absent, candidate)
+	}
+	if userPath == "" || userPath == d.ConfigPath {
+		return nil
+	}
+
+	resolver := config.NewResolver()
+	resolved, err := resolver.ResolveUserWrappers(userPath)
+	// Stamp the user config even if it failed to load, so fixing it is noticed
+	files := resolver.LoadedFiles()
+	if len(files) == 0 {
+		files = []string{userPath}
+	}
+	for _, path := range files {
+		if stamp, ok := stampFile(path); ok {
+			d.Files = append(d.Files, stamp)
+		}
+	}
+	if err != nil {
+		verboseLog("user config: %v", err)
+		return nil
+	}
+
+	d.UserConfigPath = userPath
+	d.UserWrappers = make(map[string]config.ShimConfig, len(resolved))
+	for name, r := range resolved {
+		d.UserWrappers[name] = r.Config
+	}
+	merged, err := config.MergeUserShims(d.UserWrappers, d.Wrappers, onConflict)
+	if err != nil {
+		return err
+	}
+	d.Wrappers = merged
+	return nil
+}
+
+// configChainTop returns the farthest config that configPath merges with
+// (configPath itself if it is a root), or "" if the chain ends in a config
+// that asks to merge with a parent but has none, so that creating one
+// anywhere above would change the result.
+func configChainTop(configPath string, projectConfig *config.ProjectConfig) (string, error) {
+	if projectConfig.IsRoot() {
+		return configPath, nil
+	}
+	chain, err := config.ConfigChain(configPath)
+	if err != nil {
+		return "", err
+	}
+	top := chain[len(chain)-1]
+	topConfig, err := config.LoadProjectConfig(top)
+	if err != nil {
+		return "", err
+	}
+	if !topConfig.IsRoot() {
+		return "", nil
+	}
+	return top, nil
+}
+
+// storeDecision caches d for later invocations in the same directory.
+// Failures are ignored: the cache only saves time.
+func storeDecision(d *decision) {
+	if !d.cacheable || !decisionCacheEnabled() {
+		return
+	}
+	path, err := decisionCachePath(d.Cwd)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+
+	// Write via a temp file and rename so concurrent wrappers never read a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".decision-*")
+	if err != nil {
+		return
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
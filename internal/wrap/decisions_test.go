@@ -0,0 +1,180 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestDecisionCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve symlinks: %v", err)
+	}
+	projectDir := filepath.Join(tmpDir, "project")
+	cwd := filepath.Join(projectDir, "src", "app")
+	if err := os.MkdirAll(cwd, 0755); err != nil {
+		t.Fatalf("failed to create dirs: %v", err)
+	}
+	configPath := filepath.Join(projectDir, "ribbin.jsonc")
+	basePath := filepath.Join(projectDir, "base.jsonc")
+
+	writes := 0
+	writeFile := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+		// Give every write a distinct mtime, even on filesystems with coarse timestamps
+		writes++
+		mtime := time.Now().Add(time.Duration(writes) * time.Second)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("failed to set mtime: %v", err)
+		}
+	}
+	writeFile(basePath, `{"wrappers": {"curl": {"action": "block"}}}`)
+	writeFile(configPath, `{
+		"wrappers": {"npm": {"action": "block", "message": "use pnpm"}},
+		"scopes": {"app": {"path": "src/app", "extends": ["root", "./base.jsonc"]}}
+	}`)
+
+	// resolve computes the decision for cwd the way Run does on a cache miss
+	resolve := func() *decision {
+		t.Helper()
+		projectConfig, err := config.LoadProjectConfig(configPath)
+		if err != nil {
+			t.Fatalf("LoadProjectConfig error: %v", err)
+		}
+		d, err := resolveDecision(cwd, configPath, projectConfig)
+		if err != nil {
+			t.Fatalf("resolveDecision error: %v", err)
+		}
+		return d
+	}
+
+	if lookupDecision(cwd) != nil {
+		t.Fatal("expected no cached decision before one is stored")
+	}
+
+	d := resolve()
+	if _, ok := d.Wrappers["npm"]; !ok {
+		t.Errorf("expected npm from root, got %v", d.Wrappers)
+	}
+	if _, ok := d.Wrappers["curl"]; !ok {
+		t.Errorf("expected curl from base.jsonc, got %v", d.Wrappers)
+	}
+	storeDecision(d)
+
+	cached := lookupDecision(cwd)
+	if cached == nil {
+		t.Fatal("expected cached decision")
+	}
+	if cached.ConfigPath != configPath || cached.Wrappers["npm"].Message != "use pnpm" {
+		t.Errorf("cached decision = %+v", cached)
+	}
+	if lookupDecision(projectDir) != nil {
+		t.Error("decisions are per directory")
+	}
+
+	t.Run("invalidated by config edit", func(t *testing.T) {
+		storeDecision(resolve())
+		writeFile(configPath, `{"wrappers": {"npm": {"action": "passthrough"}}}`)
+		if lookupDecision(cwd) != nil {
+			t.Error("expected cache miss after editing the config")
+		}
+	})
+
+	t.Run("invalidated by extends file edit", func(t *testing.T) {
+		writeFile(configPath, `{"scopes": {"app": {"path": "src/app", "extends": ["./base.jsonc"]}}}`)
+		storeDecision(resolve())
+		writeFile(basePath, `{"wrappers": {"curl": {"action": "passthrough"}, "wget": {"action": "block"}}}`)
+		if lookupDecision(cwd) != nil {
+			t.Error("expected cache miss after editing an extended file")
+		}
+	})
+
+	t.Run("invalidated by imported file edit", func(t *testing.T) {
+		writeFile(configPath, `{"imports": ["./base.jsonc"]}`)
+		storeDecision(resolve())
+		writeFile(basePath, `{"wrappers": {"curl": {"action": "block"}}}`)
+		if lookupDecision(cwd) != nil {
+			t.Error("expected cache miss after editing an imported file")
+		}
+	})
+
+	t.Run("invalidated by a nearer config", func(t *testing.T) {
+		storeDecision(resolve())
+		writeFile(filepath.Join(cwd, "ribbin.jsonc"), `{}`)
+		defer os.Remove(filepath.Join(cwd, "ribbin.jsonc"))
+		if lookupDecision(cwd) != nil {
+			t.Error("expected cache miss after adding a config closer to cwd")
+		}
+	})
+
+	t.Run("user config", func(t *testing.T) {
+		writeFile(configPath, `{"wrappers": {"npm": {"action": "block", "message": "use pnpm"}}}`)
+		storeDecision(resolve())
+
+		userDir := filepath.Join(configHome, "ribbin")
+		if err := os.MkdirAll(userDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		userPath := filepath.Join(userDir, "ribbin.jsonc")
+		writeFile(userPath, `{"wrappers": {
+			"npm": {"action": "block", "message": "user npm"},
+			"sudo": {"action": "block", "message": "user sudo"}
+		}}`)
+		defer os.Remove(userPath)
+		if lookupDecision(cwd) != nil {
+			t.Fatal("expected cache miss after creating the user config")
+		}
+
+		d := resolve()
+		if d.UserConfigPath != userPath {
+			t.Errorf("UserConfigPath = %q, want %q", d.UserConfigPath, userPath)
+		}
+		if d.Wrappers["npm"].Message != "use pnpm" || d.Wrappers["sudo"].Message != "user sudo" {
+			t.Errorf("the project's wrappers should be merged over the user's, got %v", d.Wrappers)
+		}
+		if d.UserWrappers["npm"].Message != "user npm" {
+			t.Errorf("UserWrappers should be the user config's alone, got %v", d.UserWrappers)
+		}
+
+		storeDecision(d)
+		writeFile(userPath, `{"wrappers": {}}`)
+		if lookupDecision(cwd) != nil {
+			t.Error("expected cache miss after editing the user config")
+		}
+	})
+
+	t.Run("no config", func(t *testing.T) {
+		outside := filepath.Join(tmpDir, "elsewhere")
+		if err := os.MkdirAll(outside, 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		d, err := resolveDecision(outside, "", nil)
+		if err != nil {
+			t.Fatalf("resolveDecision error: %v", err)
+		}
+		storeDecision(d)
+		if cached := lookupDecision(outside); cached == nil || cached.ConfigPath != "" {
+			t.Errorf("expected cached no-config decision, got %+v", cached)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		storeDecision(resolve())
+		t.Setenv("RIBBIN_DECISION_CACHE", "0")
+		if lookupDecision(cwd) != nil {
+			t.Error("RIBBIN_DECISION_CACHE=0 should disable the cache")
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// A redirect target can run wrapped commands itself, and those can redirect
+// in turn. When one of them leads back to a command already redirected, say
+// a script for tsc that runs "npx tsc" where npx redirects to a script that
+// runs tsc, the chain never ends. Each redirect passes its target
+// RIBBIN_DEPTH, one more than it was given, and RIBBIN_REDIRECT_CHAIN, the
+// commands redirected so far; a wrapper given a depth at the maximum refuses
+// to redirect again rather than run anything.
+//
+// The exit status of a chain is the status of the program at its end: each
+// redirect replaces the wrapper with its target, or when spawned exits the
+// way its target did. A wrapper that refuses to go deeper exits with
+// RedirectDepthExitCode, which the wrappers and scripts before it pass on
+// unless a script handles it.
+
+// RedirectDepthExitCode is the exit status of a wrapper that refuses to
+// redirect past the maximum depth. Like a shell's for a command it can't
+// run, it is distinct from the status a blocked command exits with.
+const RedirectDepthExitCode = 126
+
+// redirectDepth is how many redirects led to this wrapper, from RIBBIN_DEPTH.
+// Run sets it before redirecting.
+var redirectDepth int
+
+// RedirectDepthError is a redirect refused because the chain of redirects
+// leading to it is as long as allowed, or because RIBBIN_DEPTH is not a
+// depth
+type RedirectDepthError struct {
+	Command string
+	Depth   int
+	Max     int
+	// Chain lists the commands redirected before this one, outermost first
+	Chain []string
+	// Invalid is the value of RIBBIN_DEPTH or RIBBIN_MAX_DEPTH that isn't a
+	// number, if that is the problem
+	Invalid string
+}
+
+func (e *RedirectDepthError) Error() string {
+	if e.Invalid != "" {
+		return fmt.Sprintf("not redirecting '%s': %s is not a valid depth", e.Command, e.Invalid)
+	}
+	chain := append(append([]string{}, e.Chain...), e.Command)
+	return fmt.Sprintf("not redirecting '%s': %d redirects deep, the most allowed is %d (%s).\n"+
+		"A redirect target probably runs a wrapped command that redirects back to it; have it run\n"+
+		"$RIBBIN_ORIGINAL_BIN instead. For a chain this long on purpose, raise maxRedirectDepth in\n"+
+		"your settings or set RIBBIN_MAX_DEPTH.",
+		e.Command, e.Depth, e.Max, strings.Join(chain, " -> "))
+}
+
+// checkRedirectDepth reads how many redirects led to cmdName and returns a
+// *RedirectDepthError if it may not redirect again. The maximum is
+// RIBBIN_MAX_DEPTH, or else the settings' maxRedirectDepth.
+func checkRedirectDepth(cmdName string) (depth, maxDepth int, err error) {
+	if value := os.Getenv("RIBBIN_DEPTH"); value != "" {
+		depth, err = strconv.Atoi(value)
+		if err != nil || depth < 0 {
+			return 0, 0, &RedirectDepthError{Command: cmdName, Invalid: "RIBBIN_DEPTH=" + value}
+		}
+	}
+
+	maxDepth = config.DefaultMaxRedirectDepth
+	if value := os.Getenv("RIBBIN_MAX_DEPTH"); value != "" {
+		maxDepth, err = strconv.Atoi(value)
+		if err != nil || maxDepth < 1 {
+			return depth, 0, &RedirectDepthError{Command: cmdName, Invalid: "RIBBIN_MAX_DEPTH=" + value}
+		}
+	} else if settings, err := config.LoadSettings(); err == nil && settings.MaxRedirectDepth > 0 {
+		maxDepth = settings.MaxRedirectDepth
+	}
+
+	if depth >= maxDepth {
+		return depth, maxDepth, &RedirectDepthError{Command: cmdName, Depth: depth, Max: maxDepth, Chain: redirectChain()}
+	}
+	return depth, maxDepth, nil
+}
+
+// redirectChain returns the commands redirected before this wrapper, from
+// RIBBIN_REDIRECT_CHAIN
+func redirectChain() []string {
+	value := os.Getenv("RIBBIN_REDIRECT_CHAIN")
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ":")
+}
+
+// setEnv returns env with name set to value, replacing any earlier value.
+// Appending instead would leave both, and which one a program sees depends
+// on how it reads its environment.
+func setEnv(env []string, name, value string) []string {
+	result := make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, name+"=") {
+			result = append(result, kv)
+		}
+	}
+	return append(result, name+"="+value)
+}
@@ -0,0 +1,94 @@
+package wrap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestCheckRedirectDepth(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tests := []struct {
+		name      string
+		depth     string
+		max       string
+		wantDepth int
+		wantMax   int
+		wantErr   string
+	}{
+		{name: "outermost redirect", wantDepth: 0, wantMax: 8},
+		{name: "nested below the default", depth: "7", wantDepth: 7, wantMax: 8},
+		{name: "at the default", depth: "8", wantErr: "8 redirects deep, the most allowed is 8"},
+		{name: "RIBBIN_MAX_DEPTH raises it", depth: "8", max: "10", wantDepth: 8, wantMax: 10},
+		{name: "RIBBIN_MAX_DEPTH lowers it", depth: "1", max: "1", wantErr: "1 redirects deep, the most allowed is 1"},
+		{name: "invalid depth", depth: "deep", wantErr: "RIBBIN_DEPTH=deep is not a valid depth"},
+		{name: "negative depth", depth: "-1", wantErr: "RIBBIN_DEPTH=-1 is not a valid depth"},
+		{name: "invalid max", max: "0", wantErr: "RIBBIN_MAX_DEPTH=0 is not a valid depth"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RIBBIN_DEPTH", tt.depth)
+			t.Setenv("RIBBIN_MAX_DEPTH", tt.max)
+			t.Setenv("RIBBIN_REDIRECT_CHAIN", "")
+
+			depth, maxDepth, err := checkRedirectDepth("tsc")
+			if tt.wantErr != "" {
+				var depthErr *RedirectDepthError
+				if !errors.As(err, &depthErr) {
+					t.Fatalf("checkRedirectDepth() error = %v, want a *RedirectDepthError", err)
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkRedirectDepth() error = %v", err)
+			}
+			if depth != tt.wantDepth || maxDepth != tt.wantMax {
+				t.Errorf("checkRedirectDepth() = %d, %d, want %d, %d", depth, maxDepth, tt.wantDepth, tt.wantMax)
+			}
+		})
+	}
+
+	t.Run("maxRedirectDepth in settings", func(t *testing.T) {
+		configHome := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", configHome)
+		t.Setenv("RIBBIN_DEPTH", "3")
+		t.Setenv("RIBBIN_MAX_DEPTH", "")
+		settingsPath := filepath.Join(configHome, "ribbin", "settings.jsonc")
+		if err := os.MkdirAll(filepath.Dir(settingsPath), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(settingsPath, []byte(`{"maxRedirectDepth": 3}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := checkRedirectDepth("tsc"); err == nil {
+			t.Error("a depth of 3 should be refused with maxRedirectDepth 3")
+		}
+	})
+
+	t.Run("error names the chain", func(t *testing.T) {
+		t.Setenv("RIBBIN_DEPTH", "2")
+		t.Setenv("RIBBIN_MAX_DEPTH", "2")
+		t.Setenv("RIBBIN_REDIRECT_CHAIN", "tsc:npx")
+		_, _, err := checkRedirectDepth("tsc")
+		if err == nil || !strings.Contains(err.Error(), "(tsc -> npx -> tsc)") {
+			t.Errorf("error = %v, want the chain tsc -> npx -> tsc", err)
+		}
+	})
+}
+
+func TestSetEnv(t *testing.T) {
+	env := []string{"PATH=/bin", "RIBBIN_COMMAND=outer", "RIBBIN_COMMANDS=x"}
+	got := setEnv(env, "RIBBIN_COMMAND", "inner")
+	want := []string{"PATH=/bin", "RIBBIN_COMMANDS=x", "RIBBIN_COMMAND=inner"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("setEnv() = %q, want %q", got, want)
+	}
+}
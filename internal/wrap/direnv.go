@@ -0,0 +1,89 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// Environment activation: ribbin is active for the configs RIBBIN_ACTIVATE
+// lists (separated like PATH) in any process with it in its environment, as
+// in a shell inside a project once direnv loads 'ribbin direnv --export'.
+// RIBBIN_ACTIVATE_TAGS limits it to wrappers with one of its comma-separated
+// tags.
+const (
+	ActivateEnv     = "RIBBIN_ACTIVATE"
+	ActivateTagsEnv = "RIBBIN_ACTIVATE_TAGS"
+)
+
+// DirenvEnv is the environment 'ribbin direnv --export' gives a directory,
+// which direnv loads as the shell enters it and unloads as it leaves
+type DirenvEnv struct {
+	// ConfigPath is the config activated there, or "" if there is none
+	ConfigPath string
+	// Watch are the config files deciding the wrappers there, including
+	// imported, extended and user configs; direnv reloads the environment
+	// when one changes
+	Watch []string
+	// ShadowDir is the directory of wrappers shadowing binaries (see
+	// InstallShadow) to put first on PATH, or "" if nothing is shadowed
+	ShadowDir string
+	// SentinelDir is the directory of sentinels for the commands the
+	// configs require to be wrapped (see InstallSentinels), to put on PATH,
+	// or "" if they require none
+	SentinelDir string
+}
+
+// Direnv returns the environment for direnv to load in dir, creating the
+// sentinels of the commands its configs require to be wrapped
+func Direnv(dir string) (*DirenvEnv, error) {
+	env := &DirenvEnv{}
+	configPath, err := config.FindProjectConfigFrom(dir)
+	if err != nil || configPath == "" {
+		return env, err
+	}
+	projectConfig, err := config.LoadProjectConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	d, err := resolveDecision(dir, configPath, projectConfig)
+	if err != nil {
+		return nil, err
+	}
+	env.ConfigPath = configPath
+	seen := make(map[string]bool)
+	watch := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			env.Watch = append(env.Watch, path)
+		}
+	}
+	for _, file := range d.Files {
+		watch(file.Path)
+	}
+	if d.UserConfigPath != "" {
+		watch(d.UserConfigPath)
+	}
+	// Creating a config that takes precedence, like ribbin.local.jsonc,
+	// changes which config governs
+	for _, name := range config.ConfigFileNames {
+		if name == filepath.Base(configPath) {
+			break
+		}
+		watch(filepath.Join(filepath.Dir(configPath), name))
+	}
+
+	if required, ribbinPath := requiredCommands(configPath), runningRibbin(); len(required) > 0 && ribbinPath != "" {
+		if err := InstallSentinels(required, ribbinPath); err != nil {
+			return nil, err
+		}
+		env.SentinelDir, _ = SentinelDir()
+	}
+	if shadowDir, err := ShadowDir(); err == nil {
+		if _, err := os.Stat(shadowDir); err == nil {
+			env.ShadowDir = shadowDir
+		}
+	}
+	return env, nil
+}
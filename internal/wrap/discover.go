@@ -0,0 +1,133 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Discovery sources, in the order candidates are reported
+const (
+	SourceNodeModules = "node_modules"
+	SourcePath        = "PATH"
+	SourceMise        = "mise"
+	SourceAsdf        = "asdf"
+	SourceSearchPath  = "searchPaths"
+)
+
+// Candidate is a binary found by DiscoverCommand that a wrapper without
+// explicit paths could wrap.
+type Candidate struct {
+	// Path is the absolute path of the binary
+	Path string
+	// Source is where it was found: SourceNodeModules, SourcePath, SourceMise,
+	// SourceAsdf or SourceSearchPath
+	Source string
+}
+
+// DiscoverCommand finds every binary named name that a wrapper without paths
+// might mean: the project's node_modules/.bin (projectDir and its parents),
+// every PATH entry, the mise and asdf shim directories, and searchPaths
+// (see config.SearchPaths). ResolveCommand only returns the first PATH
+// match, which misses tools that are only run through a package manager or
+// version manager.
+//
+// Binaries reachable through several routes are reported once, from the
+// first source that found them. Binaries that are already wrapped are
+// included.
+func DiscoverCommand(name, projectDir string, searchPaths []string) []Candidate {
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	add := func(dir, source string) {
+		if dir == "" {
+			return
+		}
+		path := filepath.Join(dir, name)
+		if !filepath.IsAbs(path) {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return
+			}
+			path = abs
+		}
+		if !isExecutableFile(path) || IsSentinel(path) {
+			return
+		}
+
+		// Deduplicate on the real file, but keep wrapped binaries distinct
+		// from ribbin itself
+		key := path
+		if wrapped, _ := IsAlreadyShimmed(path); !wrapped {
+			if resolved, err := filepath.EvalSymlinks(path); err == nil {
+				key = resolved
+			}
+		}
+		if seen[key] || seen[path] {
+			return
+		}
+		seen[key] = true
+		seen[path] = true
+		candidates = append(candidates, Candidate{Path: path, Source: source})
+	}
+
+	if projectDir != "" {
+		for dir := projectDir; ; {
+			add(filepath.Join(dir, "node_modules", ".bin"), SourceNodeModules)
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		add(dir, SourcePath)
+	}
+
+	for _, dir := range miseShimDirs() {
+		add(dir, SourceMise)
+	}
+	for _, dir := range asdfShimDirs() {
+		add(dir, SourceAsdf)
+	}
+	for _, dir := range searchPaths {
+		add(dir, SourceSearchPath)
+	}
+
+	return candidates
+}
+
+// miseShimDirs returns where mise keeps its shims
+func miseShimDirs() []string {
+	if dir := os.Getenv("MISE_DATA_DIR"); dir != "" {
+		return []string{filepath.Join(dir, "shims")}
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return []string{filepath.Join(dir, "mise", "shims")}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return []string{filepath.Join(home, ".local", "share", "mise", "shims")}
+	}
+	return nil
+}
+
+// asdfShimDirs returns where asdf keeps its shims
+func asdfShimDirs() []string {
+	if dir := os.Getenv("ASDF_DATA_DIR"); dir != "" {
+		return []string{filepath.Join(dir, "shims")}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return []string{filepath.Join(home, ".asdf", "shims")}
+	}
+	return nil
+}
+
+// isExecutableFile returns true if path is a regular file (following
+// symlinks) with an execute bit set.
+func isExecutableFile(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	return info.Mode().Perm()&0111 != 0
+}
@@ -0,0 +1,74 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestDiscoverCommand(t *testing.T) {
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve symlinks: %v", err)
+	}
+
+	makeBin := func(path string, mode os.FileMode) string {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), mode); err != nil {
+			t.Fatalf("failed to create binary: %v", err)
+		}
+		return path
+	}
+
+	project := filepath.Join(tmpDir, "project")
+	app := filepath.Join(project, "apps", "web")
+	appBin := makeBin(filepath.Join(app, "node_modules", ".bin", "tsc"), 0755)
+	rootBin := makeBin(filepath.Join(project, "node_modules", ".bin", "tsc"), 0755)
+	pathBin := makeBin(filepath.Join(tmpDir, "bin", "tsc"), 0755)
+	makeBin(filepath.Join(tmpDir, "noexec", "tsc"), 0644)
+	miseBin := makeBin(filepath.Join(tmpDir, "mise", "shims", "tsc"), 0755)
+	asdfBin := makeBin(filepath.Join(tmpDir, "asdf", "shims", "tsc"), 0755)
+	toolsBin := makeBin(filepath.Join(tmpDir, "tools", "tsc"), 0755)
+
+	// A second PATH entry that links to a binary already found is reported once
+	linkDir := filepath.Join(tmpDir, "links")
+	if err := os.MkdirAll(linkDir, 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.Symlink(pathBin, filepath.Join(linkDir, "tsc")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	t.Setenv("PATH", filepath.Join(tmpDir, "bin")+string(filepath.ListSeparator)+
+		filepath.Join(tmpDir, "noexec")+string(filepath.ListSeparator)+linkDir)
+	t.Setenv("MISE_DATA_DIR", filepath.Join(tmpDir, "mise"))
+	t.Setenv("ASDF_DATA_DIR", filepath.Join(tmpDir, "asdf"))
+
+	got := DiscoverCommand("tsc", app, []string{filepath.Join(tmpDir, "tools"), filepath.Join(tmpDir, "bin")})
+	want := []Candidate{
+		{Path: appBin, Source: SourceNodeModules},
+		{Path: rootBin, Source: SourceNodeModules},
+		{Path: pathBin, Source: SourcePath},
+		{Path: miseBin, Source: SourceMise},
+		{Path: asdfBin, Source: SourceAsdf},
+		{Path: toolsBin, Source: SourceSearchPath},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("DiscoverCommand = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("candidate %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	t.Run("nothing found", func(t *testing.T) {
+		if got := DiscoverCommand("nonexistent-command-xyz123", app, nil); len(got) != 0 {
+			t.Errorf("expected no candidates, got %v", got)
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// enforcement is what EnforcedBy returned, once Run has checked. Bypass hints
+// are left out of block messages while it is set.
+var enforcement string
+
+// EnforcedBy returns what puts the current directory under enforcement, in
+// which wrappers ignore RIBBIN_BYPASS and snoozes: "RIBBIN_ENFORCE=1", the
+// path of a config that sets "enforce": true, or "" if neither does. Run
+// only checks when a bypass is attempted or the command is blocked, so it
+// stays off the hot path.
+func EnforcedBy() string {
+	if os.Getenv("RIBBIN_ENFORCE") == "1" {
+		return "RIBBIN_ENFORCE=1"
+	}
+	configPath, err := config.FindProjectConfig()
+	if err != nil || configPath == "" {
+		return ""
+	}
+	enforcing, _ := config.EnforcingConfig(configPath)
+	return enforcing
+}
+
+// refuseBypass reports and logs an attempt to bypass a wrapper under
+// enforcement. bypass names the attempt ("RIBBIN_BYPASS=1" or "snooze").
+func refuseBypass(cmdName, originalPath, bypass, enforcedBy string) {
+	security.LogSecurityViolation("bypass_while_enforced", originalPath, map[string]string{
+		"command":     cmdName,
+		"bypass":      bypass,
+		"enforced_by": enforcedBy,
+		"pid":         fmt.Sprintf("%d", os.Getpid()),
+	})
+	fmt.Fprintf(os.Stderr, "ribbin: ignoring %s for '%s': bypasses are disabled by %s\n", bypass, cmdName, enforcedBy)
+}
@@ -0,0 +1,230 @@
+package wrap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// execMode is how execArgv runs programs: config.ExecReplace or
+// config.ExecSpawn. It starts as the build's default (see the ribbin_spawn
+// build tag) and Run switches it to the wrapper's "exec" setting once the
+// wrapper is known.
+var execMode = defaultExecMode
+
+// redirectMode and redirectOnFailure are the wrapper's "redirectMode" and
+// "redirectOnFailure" settings. Run sets them once the wrapper is known.
+var redirectMode string
+var redirectOnFailure string
+
+// execEnv is the env of the wrapper being run (see config.WrapperConfig.Env).
+// Run sets it once the wrapper is known.
+var execEnv map[string]string
+
+// execEnvUnset and execEnvAllowOnly are the env "unset" and "allowOnly" of
+// the wrapper being run (see config.WrapperConfig.EnvUnset). Run sets them
+// once the wrapper is known.
+var execEnvUnset []string
+var execEnvAllowOnly []string
+
+// withExecEnv returns env scrubbed by execEnvUnset and execEnvAllowOnly,
+// with execEnv applied on top. Values are expanded against env first,
+// before scrubbing, so "PATH": "./bin:$PATH" prepends to the caller's PATH
+// and a variable can be set from one that is unset. RIBBIN_* variables are
+// never scrubbed, and reserved names (see config.ValidateEnvName) are never
+// set.
+func withExecEnv(env []string) []string {
+	if len(execEnv) == 0 && len(execEnvUnset) == 0 && execEnvAllowOnly == nil {
+		return env
+	}
+
+	lookup := make(map[string]string, len(env))
+	for _, kv := range env {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			lookup[name] = value
+		}
+	}
+
+	names := make([]string, 0, len(execEnv))
+	for name := range execEnv {
+		if config.ValidateEnvName(name) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	set := make(map[string]string, len(names))
+	for _, name := range names {
+		set[name] = os.Expand(execEnv[name], func(ref string) string { return lookup[ref] })
+	}
+
+	result := make([]string, 0, len(env)+len(names))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, replaced := set[name]; !replaced && !scrubbedEnv(name) {
+			result = append(result, kv)
+		}
+	}
+	for _, name := range names {
+		result = append(result, name+"="+set[name])
+	}
+	return result
+}
+
+// scrubbedEnv reports whether the caller's variable name is kept from the
+// program by execEnvUnset or execEnvAllowOnly
+func scrubbedEnv(name string) bool {
+	if strings.HasPrefix(strings.ToUpper(name), "RIBBIN_") {
+		return false
+	}
+	if execEnvAllowOnly != nil && !config.MatchEnvPattern(execEnvAllowOnly, name) {
+		return true
+	}
+	return config.MatchEnvPattern(execEnvUnset, name)
+}
+
+// describeEnvScrub describes how a wrapper scrubs the caller's environment,
+// for traces and 'ribbin explain'
+func describeEnvScrub(shim config.ShimConfig) string {
+	var parts []string
+	if shim.EnvAllowOnly != nil {
+		if len(shim.EnvAllowOnly) == 0 {
+			parts = append(parts, "passes on none of the caller's variables")
+		} else {
+			parts = append(parts, "passes on only "+strings.Join(shim.EnvAllowOnly, ", "))
+		}
+	}
+	if len(shim.EnvUnset) > 0 {
+		parts = append(parts, "unsets "+strings.Join(shim.EnvUnset, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// sortedEnvNames returns the variable names of a wrapper's env, sorted
+func sortedEnvNames(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// execArgv runs argv[0] with argv and env in place of the wrapper. In replace
+// mode the wrapper process becomes the program and this only returns on
+// error. In spawn mode the program runs as a child and the wrapper exits the
+// way the child did. The wrapper's hooks run around the program (see
+// runBeforeHook and runAfterHook); an after hook implies spawn mode, and so
+// does a timeout (see execTimeout).
+func execArgv(argv, env []string) error {
+	return execProgram(execMode, argv, env, "")
+}
+
+// execRedirectTarget runs a redirect target like execArgv, except that
+// redirectMode overrides execMode. A spawned target that exits non-zero
+// (rather than being killed by a signal) gets redirectOnFailure printed
+// after it.
+func execRedirectTarget(argv, env []string) error {
+	mode := execMode
+	if redirectMode != "" {
+		mode = redirectMode
+	}
+	return execProgram(mode, argv, env, redirectOnFailure)
+}
+
+// execProgram is execArgv in the given mode, printing onFailure (if set)
+// when a spawned program exits non-zero.
+func execProgram(mode string, argv, env []string, onFailure string) error {
+	runBeforeHook(env)
+	if mode != config.ExecSpawn && !execHooks.hasAfterHook() && execTimeout.Limit == 0 {
+		return replaceProcess(argv, env)
+	}
+
+	status, err := spawnTimed(argv, env, execTimeout)
+	if err != nil {
+		return err
+	}
+	if status.Code != 0 && status.Signal == nil && onFailure != "" {
+		fmt.Fprintln(os.Stderr, strings.ReplaceAll(onFailure, "{code}", strconv.Itoa(status.Code)))
+	}
+	runAfterHook(env, status)
+	exitWithStatus(status)
+	return nil // unreachable
+}
+
+// spawnStatus is how a spawned program ended
+type spawnStatus struct {
+	// Code is the exit code, 128+signal number if Signal is set
+	Code int
+	// Signal is the signal that killed the program, if any
+	Signal os.Signal
+}
+
+// spawnArgv runs argv as a child sharing the wrapper's stdin, stdout, stderr
+// and process group, so it keeps the terminal. Signals sent to the wrapper are
+// forwarded to the child until it exits, except those the terminal already
+// delivers to the whole foreground process group (see terminalSignals).
+func spawnArgv(argv, env []string) (spawnStatus, error) {
+	return spawnTimed(argv, env, programTimeout{})
+}
+
+// spawnTimed is spawnArgv, stopping the child if it runs past timeout
+func spawnTimed(argv, env []string, timeout programTimeout) (spawnStatus, error) {
+	cmd := exec.Command(argv[0])
+	cmd.Args = argv
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Catch signals before starting, so none kill the wrapper in between
+	signals := make(chan os.Signal, 8)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return spawnStatus{}, err
+	}
+	if timeout.Limit > 0 {
+		timer := time.AfterFunc(timeout.Limit, func() { timeout.stop(cmd.Process) })
+		defer timer.Stop()
+	}
+
+	skip := map[os.Signal]bool{}
+	if inForegroundOfTerminal() {
+		for _, sig := range terminalSignals {
+			skip[sig] = true
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if !skip[sig] {
+					cmd.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	close(done)
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return spawnStatus{}, err
+	}
+	return exitStatus(cmd.ProcessState), nil
+}
@@ -0,0 +1,8 @@
+//go:build !ribbin_spawn
+
+package wrap
+
+import "github.com/happycollision/ribbin/internal/config"
+
+// defaultExecMode replaces the wrapper process, unless built with -tags ribbin_spawn
+const defaultExecMode = config.ExecReplace
@@ -0,0 +1,8 @@
+//go:build ribbin_spawn
+
+package wrap
+
+import "github.com/happycollision/ribbin/internal/config"
+
+// defaultExecMode spawns programs as children in builds with -tags ribbin_spawn
+const defaultExecMode = config.ExecSpawn
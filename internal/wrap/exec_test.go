@@ -0,0 +1,133 @@
+package wrap
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestSpawnArgv(t *testing.T) {
+	env := os.Environ()
+
+	t.Run("exit code", func(t *testing.T) {
+		status, err := spawnArgv([]string{"/bin/sh", "-c", "exit 3"}, env)
+		if err != nil {
+			t.Fatalf("spawnArgv error: %v", err)
+		}
+		if status.Code != 3 || status.Signal != nil {
+			t.Errorf("status = %+v, want exit code 3", status)
+		}
+	})
+
+	t.Run("killed by signal", func(t *testing.T) {
+		status, err := spawnArgv([]string{"/bin/sh", "-c", "kill -TERM $$"}, env)
+		if err != nil {
+			t.Fatalf("spawnArgv error: %v", err)
+		}
+		if status.Signal != syscall.SIGTERM || status.Code != 128+int(syscall.SIGTERM) {
+			t.Errorf("status = %+v, want killed by SIGTERM", status)
+		}
+	})
+
+	t.Run("forwards signals", func(t *testing.T) {
+		// Signal the wrapper (this process) once the child has set its trap
+		go func() {
+			time.Sleep(500 * time.Millisecond)
+			syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		}()
+
+		script := `trap "exit 7" TERM; i=0; while [ $i -lt 100 ]; do sleep 0.1; i=$((i+1)); done; exit 1`
+		status, err := spawnArgv([]string{"/bin/sh", "-c", script}, env)
+		if err != nil {
+			t.Fatalf("spawnArgv error: %v", err)
+		}
+		if status.Code != 7 {
+			t.Errorf("status = %+v, want exit code 7 from the child's TERM trap", status)
+		}
+	})
+
+	t.Run("stops a program running past its timeout", func(t *testing.T) {
+		timeout := programTimeout{Limit: 200 * time.Millisecond, Signal: syscall.SIGINT, Message: "timed out"}
+		start := time.Now()
+		status, err := spawnTimed([]string{"/bin/sh", "-c", `trap "exit 9" INT; sleep 5 & wait`}, env, timeout)
+		if err != nil {
+			t.Fatalf("spawnTimed error: %v", err)
+		}
+		if status.Code != 9 {
+			t.Errorf("status = %+v, want exit code 9 from the child's INT trap", status)
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("the child ran %v despite a 200ms timeout", elapsed)
+		}
+	})
+
+	t.Run("timeout signal KILL", func(t *testing.T) {
+		timeout := programTimeout{Limit: 200 * time.Millisecond, Signal: os.Kill, Message: "timed out"}
+		status, err := spawnTimed([]string{"/bin/sh", "-c", `trap "" TERM; sleep 5`}, env, timeout)
+		if err != nil {
+			t.Fatalf("spawnTimed error: %v", err)
+		}
+		if status.Signal != syscall.SIGKILL {
+			t.Errorf("status = %+v, want killed by SIGKILL", status)
+		}
+	})
+
+	t.Run("missing program", func(t *testing.T) {
+		if _, err := spawnArgv([]string{"/nonexistent/program"}, env); err == nil {
+			t.Error("expected error for missing program")
+		}
+	})
+}
+
+func TestWithExecEnv(t *testing.T) {
+	defer func() { execEnv = nil }()
+
+	env := []string{"PATH=/usr/bin", "GIT_PAGER=less", "HOME=/home/me"}
+
+	execEnv = nil
+	if got := withExecEnv(env); !reflect.DeepEqual(got, env) {
+		t.Errorf("without env got %v", got)
+	}
+
+	execEnv = map[string]string{
+		"GIT_PAGER":       "cat",
+		"PATH":            "/project/bin:$PATH",
+		"NPM_CONFIG_FUND": "false",
+		"RIBBIN_BYPASS":   "1",
+	}
+	want := []string{"HOME=/home/me", "GIT_PAGER=cat", "NPM_CONFIG_FUND=false", "PATH=/project/bin:/usr/bin"}
+	if got := withExecEnv(env); !reflect.DeepEqual(got, want) {
+		t.Errorf("withExecEnv = %v, want %v", got, want)
+	}
+
+	t.Run("scrubbing", func(t *testing.T) {
+		defer func() { execEnvUnset, execEnvAllowOnly = nil, nil }()
+		env := []string{"PATH=/usr/bin", "HOME=/home/me", "AWS_PROFILE=prod", "AWS_REGION=us-east-1", "NPM_TOKEN=secret", "RIBBIN_DEPTH=1"}
+
+		execEnv = map[string]string{"TF_VAR_profile": "$AWS_PROFILE"}
+		execEnvUnset = []string{"AWS_*", "NPM_TOKEN"}
+		want := []string{"PATH=/usr/bin", "HOME=/home/me", "RIBBIN_DEPTH=1", "TF_VAR_profile=prod"}
+		if got := withExecEnv(env); !reflect.DeepEqual(got, want) {
+			t.Errorf("with unset, withExecEnv = %v, want %v", got, want)
+		}
+
+		execEnv = nil
+		execEnvUnset = []string{"AWS_REGION"}
+		execEnvAllowOnly = []string{"PATH", "AWS_*"}
+		want = []string{"PATH=/usr/bin", "AWS_PROFILE=prod", "RIBBIN_DEPTH=1"}
+		if got := withExecEnv(env); !reflect.DeepEqual(got, want) {
+			t.Errorf("with allowOnly, withExecEnv = %v, want %v", got, want)
+		}
+
+		execEnvUnset = nil
+		execEnvAllowOnly = []string{}
+		want = []string{"RIBBIN_DEPTH=1"}
+		if got := withExecEnv(env); !reflect.DeepEqual(got, want) {
+			t.Errorf("with an empty allowOnly, withExecEnv = %v, want %v", got, want)
+		}
+	})
+}
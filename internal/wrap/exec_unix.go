@@ -0,0 +1,113 @@
+//go:build unix
+
+package wrap
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// forwardedSignals are passed on to a spawned program
+var forwardedSignals = []os.Signal{
+	syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT,
+	syscall.SIGWINCH, syscall.SIGUSR1, syscall.SIGUSR2,
+}
+
+// terminalSignals are sent by the terminal to its whole foreground process
+// group, so a spawned program in that group already gets them. Forwarding
+// them too would deliver Ctrl-C twice.
+var terminalSignals = []os.Signal{syscall.SIGINT, syscall.SIGQUIT, syscall.SIGWINCH}
+
+// signalNamed returns the signal with name, one of config.TimeoutSignals,
+// or SIGTERM for any other
+func signalNamed(name string) os.Signal {
+	switch name {
+	case "INT":
+		return syscall.SIGINT
+	case "HUP":
+		return syscall.SIGHUP
+	case "QUIT":
+		return syscall.SIGQUIT
+	case "KILL":
+		return os.Kill
+	default:
+		return syscall.SIGTERM
+	}
+}
+
+// replaceProcess replaces the current process with argv
+func replaceProcess(argv, env []string) error {
+	return syscall.Exec(argv[0], argv, env)
+}
+
+// inForegroundOfTerminal returns true if the wrapper's process group is the
+// foreground group of the terminal on stdin, stdout or stderr
+func inForegroundOfTerminal() bool {
+	pgrp := syscall.Getpgrp()
+	for _, fd := range []uintptr{0, 1, 2} {
+		var foreground int32
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&foreground)))
+		if errno == 0 {
+			return int(foreground) == pgrp
+		}
+	}
+	return false
+}
+
+// exitStatus converts how a process ended into a spawnStatus
+func exitStatus(state *os.ProcessState) spawnStatus {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return spawnStatus{Code: 128 + int(ws.Signal()), Signal: ws.Signal()}
+	}
+	return spawnStatus{Code: state.ExitCode()}
+}
+
+// exitWithStatus ends the wrapper the way the spawned program ended. If it
+// was killed by a signal, the wrapper kills itself with the same signal so
+// callers (like a shell loop checking for SIGINT) see the same thing.
+func exitWithStatus(status spawnStatus) {
+	if sig, ok := status.Signal.(syscall.Signal); ok {
+		signal.Reset(sig)
+		syscall.Kill(os.Getpid(), sig)
+		// Signals that don't terminate by default fall through to the exit code
+		time.Sleep(100 * time.Millisecond)
+	}
+	os.Exit(status.Code)
+}
+
+// linkCount returns the number of hard links to the file described by info
+func linkCount(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Nlink)
+	}
+	return 1
+}
+
+// fileOwner returns the user and group IDs owning the file info describes
+func fileOwner(info os.FileInfo) (int, int) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(stat.Uid), int(stat.Gid)
+	}
+	return -1, -1
+}
+
+// canWrite reports whether this process may create and remove entries in
+// dir
+func canWrite(dir string) bool {
+	const wOK, xOK = 0x2, 0x1
+	return syscall.Access(dir, wOK|xOK) == nil
+}
+
+// sessionID returns the ID of the terminal session this process belongs to,
+// which every command started from one shell window shares, or 0 if it
+// can't be found
+func sessionID() int {
+	sid, _, errno := syscall.RawSyscall(syscall.SYS_GETSID, 0, 0, 0)
+	if errno != 0 {
+		return 0
+	}
+	return int(sid)
+}
@@ -0,0 +1,226 @@
+package wrap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/process"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// With metrics enabled, each interception counted is also appended to the
+// execution log, $XDG_STATE_HOME/ribbin/executions.jsonl, with the config
+// and the command that ran it, for 'ribbin status --stats'. Past
+// execLogMaxSize the log moves to executions.jsonl.1, replacing the one
+// there, so it never takes more than twice that.
+
+// execLogFileName is the execution log in ribbin's state directory
+const execLogFileName = "executions.jsonl"
+
+// execLogMaxSize is the size past which the execution log is rotated
+const execLogMaxSize = 1 << 20
+
+// execLogParentMax bounds the length of a parent command line in the log
+const execLogParentMax = 200
+
+// ExecRecord is one interception in the execution log
+type ExecRecord struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	// Outcome is blocked, pass or redirect
+	Outcome string `json:"outcome"`
+	// Config is the config whose wrapper applied
+	Config string `json:"config,omitempty"`
+	// Parent is the command line of the process that ran the command
+	Parent string `json:"parent,omitempty"`
+}
+
+// metricsConfig is the config of the wrapper being counted, set by Run
+// alongside metricsCommand
+var metricsConfig string
+
+// ExecLogPath returns the path of the execution log
+func ExecLogPath() (string, error) {
+	stateDir, err := security.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, execLogFileName), nil
+}
+
+// logExecution appends the interception of command to the execution log
+func logExecution(command, outcome, configPath string) error {
+	logPath, err := ExecLogPath()
+	if err != nil {
+		return err
+	}
+	record := ExecRecord{Time: time.Now().UTC(), Command: command, Outcome: outcome, Config: configPath}
+	if parents, err := process.GetAncestorCommands(1); err == nil && len(parents) > 0 {
+		record.Parent = parents[0]
+		if len(record.Parent) > execLogParentMax {
+			record.Parent = record.Parent[:execLogParentMax]
+		}
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	err = security.WithLock(logPath, metricsTimeout, func() error {
+		if info, err := os.Stat(logPath); err == nil && info.Size() > execLogMaxSize {
+			if err := os.Rename(logPath, logPath+".1"); err != nil {
+				return err
+			}
+		}
+		f, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = f.Write(append(data, '\n'))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cannot log execution: %w", err)
+	}
+	return nil
+}
+
+// LoadExecRecords reads the execution log, oldest first. Lines that can't
+// be parsed, as one cut short by a full disk, are skipped.
+func LoadExecRecords() ([]ExecRecord, error) {
+	logPath, err := ExecLogPath()
+	if err != nil {
+		return nil, err
+	}
+	var records []ExecRecord
+	for _, path := range []string{logPath + ".1", logPath} {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		reader := bufio.NewReader(f)
+		for {
+			line, err := reader.ReadBytes('\n')
+			var record ExecRecord
+			if json.Unmarshal(line, &record) == nil && record.Command != "" {
+				records = append(records, record)
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+		f.Close()
+	}
+	return records, nil
+}
+
+// resetExecLog deletes the execution log
+func resetExecLog() error {
+	logPath, err := ExecLogPath()
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{logPath, logPath + ".1"} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// WrapperStats are the interceptions of one command, under one config or
+// under all of them
+type WrapperStats struct {
+	Command string
+	// Config is "" for the command's stats across all configs
+	Config     string
+	Blocked    int64
+	Passed     int64
+	Redirected int64
+	LastHit    time.Time
+	// Parents are the commands that ran it most often when it was blocked,
+	// most first
+	Parents []ParentCount
+}
+
+// ParentCount is how many times a parent command ran a blocked command
+type ParentCount struct {
+	Command string
+	Count   int64
+}
+
+// statsParentLimit is how many parents WrapperStats keeps
+const statsParentLimit = 3
+
+// AggregateExecStats totals records by config and command, or with
+// perConfig false by command alone. The stats are sorted by config, then
+// command.
+func AggregateExecStats(records []ExecRecord, perConfig bool) []WrapperStats {
+	type key struct{ config, command string }
+	stats := make(map[key]*WrapperStats)
+	parents := make(map[key]map[string]int64)
+	for _, record := range records {
+		k := key{command: record.Command}
+		if perConfig {
+			k.config = record.Config
+		}
+		s := stats[k]
+		if s == nil {
+			s = &WrapperStats{Command: k.command, Config: k.config}
+			stats[k] = s
+			parents[k] = make(map[string]int64)
+		}
+		switch record.Outcome {
+		case "blocked":
+			s.Blocked++
+			if record.Parent != "" {
+				parents[k][record.Parent]++
+			}
+		case "redirect":
+			s.Redirected++
+		default:
+			s.Passed++
+		}
+		if record.Time.After(s.LastHit) {
+			s.LastHit = record.Time
+		}
+	}
+
+	result := make([]WrapperStats, 0, len(stats))
+	for k, s := range stats {
+		for parent, count := range parents[k] {
+			s.Parents = append(s.Parents, ParentCount{Command: parent, Count: count})
+		}
+		sort.Slice(s.Parents, func(i, j int) bool {
+			if s.Parents[i].Count != s.Parents[j].Count {
+				return s.Parents[i].Count > s.Parents[j].Count
+			}
+			return s.Parents[i].Command < s.Parents[j].Command
+		})
+		if len(s.Parents) > statsParentLimit {
+			s.Parents = s.Parents[:statsParentLimit]
+		}
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Config != result[j].Config {
+			return result[i].Config < result[j].Config
+		}
+		return result[i].Command < result[j].Command
+	})
+	return result
+}
@@ -0,0 +1,87 @@
+package wrap
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestExecLog(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	if err := os.MkdirAll(os.Getenv("XDG_STATE_HOME")+"/ribbin", 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := logExecution("npm", "blocked", "/work/a/ribbin.jsonc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := logExecution("npm", "pass", "/work/a/ribbin.jsonc"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := LoadExecRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Outcome != "blocked" || records[1].Config != "/work/a/ribbin.jsonc" || records[0].Parent == "" {
+		t.Fatalf("records = %+v", records)
+	}
+
+	t.Run("rotates past its size limit", func(t *testing.T) {
+		logPath, _ := ExecLogPath()
+		if err := os.WriteFile(logPath, []byte(strings.Repeat("x", execLogMaxSize+1)+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := logExecution("tsc", "redirect", ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(logPath + ".1"); err != nil {
+			t.Errorf("log wasn't rotated: %v", err)
+		}
+		records, err := LoadExecRecords()
+		if err != nil || len(records) != 1 || records[0].Command != "tsc" {
+			t.Errorf("records = %+v, %v; want the tsc one", records, err)
+		}
+	})
+
+	t.Run("reset deletes it", func(t *testing.T) {
+		if err := ResetMetricCounts(); err != nil {
+			t.Fatal(err)
+		}
+		if records, err := LoadExecRecords(); err != nil || len(records) != 0 {
+			t.Errorf("records after reset = %+v, %v", records, err)
+		}
+	})
+}
+
+func TestAggregateExecStats(t *testing.T) {
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	records := []ExecRecord{
+		{Time: start, Command: "npm", Outcome: "blocked", Config: "/a/ribbin.jsonc", Parent: "make build"},
+		{Time: start.Add(time.Minute), Command: "npm", Outcome: "blocked", Config: "/a/ribbin.jsonc", Parent: "make build"},
+		{Time: start.Add(2 * time.Minute), Command: "npm", Outcome: "blocked", Config: "/b/ribbin.jsonc", Parent: "bash"},
+		{Time: start.Add(3 * time.Minute), Command: "npm", Outcome: "pass", Config: "/a/ribbin.jsonc", Parent: "bash"},
+		{Time: start.Add(4 * time.Minute), Command: "tsc", Outcome: "redirect", Config: "/a/ribbin.jsonc"},
+	}
+
+	perConfig := AggregateExecStats(records, true)
+	if len(perConfig) != 3 {
+		t.Fatalf("per config stats = %+v", perConfig)
+	}
+	npm := perConfig[0]
+	if npm.Config != "/a/ribbin.jsonc" || npm.Command != "npm" || npm.Blocked != 2 || npm.Passed != 1 ||
+		!npm.LastHit.Equal(start.Add(3*time.Minute)) || len(npm.Parents) != 1 || npm.Parents[0] != (ParentCount{"make build", 2}) {
+		t.Errorf("npm in /a = %+v", npm)
+	}
+	if tsc := perConfig[1]; tsc.Command != "tsc" || tsc.Redirected != 1 {
+		t.Errorf("tsc in /a = %+v", tsc)
+	}
+
+	global := AggregateExecStats(records, false)
+	if len(global) != 2 || global[0].Config != "" || global[0].Blocked != 3 || len(global[0].Parents) != 2 ||
+		global[0].Parents[0].Command != "make build" {
+		t.Errorf("global stats = %+v", global)
+	}
+}
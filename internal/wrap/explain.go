@@ -0,0 +1,385 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// Explanation describes how a wrapped command would be handled if it were run
+// from the current directory, and why. It is built by Explain.
+type Explanation struct {
+	// Command is the command name that was explained
+	Command string
+	// BinaryPath is where the command lives: the first match on PATH, or the
+	// registry's path if it isn't on PATH
+	BinaryPath string
+	// Wrapped is true if BinaryPath is a ribbin wrapper with a sidecar
+	Wrapped bool
+	// SidecarPath is the sidecar that holds the original binary
+	SidecarPath string
+	// Registry is the registry entry for the command, if any
+	Registry *config.WrapperEntry
+
+	// ConfigPath is the ribbin config that governs the current directory
+	ConfigPath string
+	// Scope is the scope matching the current directory, or nil for root wrappers
+	Scope *config.MatchedScope
+	// Wrapper is the effective wrapper config for the command, if any
+	Wrapper *config.ResolvedShim
+
+	// Outcome is what the wrapper would do: PASS, BLOCKED or REDIRECT
+	// (see verboseLogDecision), or "NOT WRAPPED" if ribbin isn't involved
+	Outcome string
+	// Reason explains the outcome
+	Reason string
+	// Steps lists each check the wrapper makes, in order, up to the deciding one
+	Steps []ExplainStep
+}
+
+// ExplainStep is one check made while deciding what a wrapper does
+type ExplainStep struct {
+	Check  string
+	Result string
+}
+
+// Explain works out what running cmdName with args from the current
+// directory would do, following the same checks as Run without running
+// anything but a versionCheck's version command. Passthrough
+// rules are matched against the calling process's ancestors, so the answer is
+// for a command typed in the same shell.
+func Explain(cmdName string, args ...string) (*Explanation, error) {
+	ex := &Explanation{Command: cmdName}
+	step := func(check, format string, args ...interface{}) {
+		ex.Steps = append(ex.Steps, ExplainStep{Check: check, Result: fmt.Sprintf(format, args...)})
+	}
+	decide := func(outcome, reason string) (*Explanation, error) {
+		ex.Outcome = outcome
+		ex.Reason = reason
+		return ex, nil
+	}
+
+	// Locate the binary and its sidecar
+	if path, err := ResolveCommand(cmdName); err == nil {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		ex.BinaryPath = path
+	}
+
+	registry, registryErr := config.LoadRegistry()
+	if registryErr == nil {
+		if entry, ok := registry.LookupWrapper(cmdName, ex.BinaryPath); ok {
+			ex.Registry = &entry
+		}
+	}
+	if ex.BinaryPath == "" && ex.Registry != nil {
+		ex.BinaryPath = ex.Registry.Original
+	}
+	if ex.BinaryPath != "" && HasSidecar(ex.BinaryPath) {
+		ex.SidecarPath = sidecarFor(ex.BinaryPath)
+		ex.Wrapped, _ = IsAlreadyShimmed(ex.BinaryPath)
+	}
+
+	// Find the governing config and wrapper, for reporting even when unwrapped
+	configPath, err := config.FindProjectConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find config: %w", err)
+	}
+	userPath, err := config.FindUserConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user config: %w", err)
+	}
+	ex.ConfigPath = configPath
+
+	// Where ribbin is only active for the user config, only its wrappers apply
+	userOnly := configPath == "" && userPath != ""
+	if registryErr == nil {
+		ex.ConfigPath, _, userOnly = governingActivation(registry, configPath, userPath)
+		userOnly = userOnly || configPath == "" && userPath != ""
+	} else if userOnly {
+		ex.ConfigPath = userPath
+	}
+
+	var projectConfig *config.ProjectConfig
+	var configErr error
+	if configPath != "" && !userOnly {
+		projectConfig, configErr = config.LoadProjectConfig(configPath)
+		if configErr == nil {
+			ex.Scope, ex.Wrapper = explainWrapper(projectConfig, configPath, userPath, cmdName)
+		}
+	} else if userOnly {
+		_, ex.Wrapper = explainWrapper(nil, "", userPath, cmdName)
+	}
+
+	switch {
+	case ex.BinaryPath == "":
+		step("binary", "not found on PATH or in the registry")
+		return decide("NOT WRAPPED", "command not found")
+	case !ex.Wrapped && ex.SidecarPath != "":
+		step("binary", "%s has a sidecar but is no longer a wrapper", ex.BinaryPath)
+		return decide("NOT WRAPPED", "the wrapper was replaced, probably by an upgrade (run 'ribbin heal')")
+	case !ex.Wrapped:
+		step("binary", "%s is not wrapped", ex.BinaryPath)
+		return decide("NOT WRAPPED", "runs directly, ribbin is not involved (run 'ribbin wrap')")
+	}
+	step("binary", "%s is wrapped, original at %s", ex.BinaryPath, ex.SidecarPath)
+
+	// The checks below mirror Run, in order
+	if rescuing() {
+		if enforcedBy := EnforcedBy(); enforcedBy != "" {
+			step("RIBBIN_RESCUE", "set, overriding enforcement by %s", enforcedBy)
+		} else {
+			step("RIBBIN_RESCUE", "set")
+		}
+		return decide("PASS", "RIBBIN_RESCUE=1")
+	}
+	if os.Getenv("RIBBIN_BYPASS") == "1" {
+		if enforcedBy := EnforcedBy(); enforcedBy != "" {
+			step("RIBBIN_BYPASS", "set, ignored (enforced by %s)", enforcedBy)
+		} else {
+			step("RIBBIN_BYPASS", "set")
+			return decide("PASS", "RIBBIN_BYPASS=1")
+		}
+	} else {
+		step("RIBBIN_BYPASS", "not set")
+	}
+
+	if registryErr != nil {
+		step("registry", "cannot load: %v", registryErr)
+		return decide("PASS", "registry not found")
+	}
+
+	if ex.ConfigPath == "" {
+		if boundary := config.ConfigBoundary(cwdOrEmpty()); boundary != "" {
+			step("config", "none found from %s, stopping at %s", cwdOrEmpty(), filepath.Join(boundary, config.RootMarkerFileName))
+		} else {
+			step("config", "none found from %s", cwdOrEmpty())
+		}
+		return decide("PASS", "no ribbin.jsonc found")
+	}
+	if userOnly {
+		if configPath != "" {
+			step("config", "%s, not active", configPath)
+		}
+		step("config", "%s (user config)", userPath)
+	} else if chain, _ := config.ConfigChain(configPath); len(chain) > 1 {
+		step("config", "%s, merged with %s", configPath, strings.Join(chain[1:], ", "))
+	} else {
+		step("config", "%s", configPath)
+	}
+	if userPath != "" && !userOnly && userPath != configPath {
+		step("user config", "%s, beneath the project config", userPath)
+	}
+
+	act := activationFor(registry, ex.ConfigPath)
+	if act.Reason == "" {
+		step("activation", "not active (run 'ribbin activate')")
+		return decide("PASS", "ribbin not active")
+	}
+	step("activation", "active via %s", act)
+
+	if configErr != nil {
+		step("config", "cannot load: %v", configErr)
+		return decide("PASS", fmt.Sprintf("config load failed: %v", configErr))
+	}
+
+	scopeDesc := "root wrappers (no scope matches this directory)"
+	if ex.Scope != nil {
+		scopeDesc = fmt.Sprintf("scope %q", ex.Scope.Name)
+	}
+	step("scope", "%s", scopeDesc)
+
+	var shimConfig config.ShimConfig
+	exists := ex.Wrapper != nil
+	if exists {
+		shimConfig = ex.Wrapper.Config
+	} else if projectConfig != nil {
+		shimConfig, exists = getEffectiveShimConfig(projectConfig, configPath, cmdName)
+	}
+	if !exists {
+		step("wrapper", "%s has no wrapper in this config", cmdName)
+		return decide("PASS", "no shim configured")
+	}
+	if ex.Wrapper != nil {
+		step("wrapper", "action %q from %s#%s", shimConfig.Action, ex.Wrapper.Source.FilePath, ex.Wrapper.Source.Fragment)
+	} else {
+		step("wrapper", "action %q", shimConfig.Action)
+	}
+	expired := shimConfig.Expired(time.Now())
+	if expired {
+		step("expires", "expired on %s, acting as %q", shimConfig.Expires, shimConfig.ActionAfterExpiry())
+		shimConfig = shimConfig.Effective(time.Now())
+	} else if shimConfig.Expires != "" {
+		step("expires", "not until %s", shimConfig.Expires)
+	}
+
+	if len(shimConfig.ArgRules) > 0 && !expired {
+		if rule, ok := shimConfig.MatchArgRules(cmdName, args); ok {
+			step("argRules", "%s matches, acting as %q", rule, rule.Action)
+			shimConfig = shimConfig.WithArgRule(rule)
+		} else {
+			step("argRules", "none match")
+		}
+	}
+
+	if len(shimConfig.ArgPathPatterns) > 0 {
+		arg, ok := shimConfig.MatchArgPaths(args, filepath.Dir(ex.ConfigPath), cwdOrEmpty())
+		if !ok {
+			step("argPathPatterns", "no argument matches %s", strings.Join(shimConfig.ArgPathPatterns, ", "))
+			return decide("PASS", "no argument matches argPathPatterns")
+		}
+		step("argPathPatterns", "%q matches", arg)
+	}
+
+	if shimConfig.When != nil && len(shimConfig.When.Schedule) > 0 {
+		entry, ok := shimConfig.Scheduled(time.Now())
+		if !ok {
+			step("schedule", "no entry matches %s", scheduleClock(shimConfig.When))
+			return decide("PASS", "outside the wrapper's schedule")
+		}
+		step("schedule", "%q matches %s", entry, scheduleClock(shimConfig.When))
+	}
+
+	var scopes map[string]string
+	if projectConfig != nil {
+		scopes = matchedScopes(projectConfig, configPath, cwdOrEmpty())
+	}
+	if !act.covers(shimConfig, scopes) {
+		// The scope step above shows the scope an activation limited to scopes missed
+		switch {
+		case len(act.Tags) == 0:
+		case len(shimConfig.Tags) == 0:
+			step("tags", "wrapper has no tags")
+		default:
+			step("tags", "wrapper is tagged %s", strings.Join(shimConfig.Tags, ", "))
+		}
+		return decide("PASS", act.uncoveredReason())
+	}
+
+	if len(shimConfig.Env) > 0 {
+		step("env", "sets %s", strings.Join(sortedEnvNames(shimConfig.Env), ", "))
+	}
+	if shimConfig.ScrubsEnv() {
+		step("env", "%s", describeEnvScrub(shimConfig))
+	}
+
+	if shimConfig.Verify != "" && shimConfig.Verify != config.VerifyNone {
+		if err := VerifySidecar(ex.BinaryPath, shimConfig.Verify); err != nil {
+			step("verify", "%s check failed: %v", shimConfig.Verify, err)
+			return decide("BLOCKED", fmt.Sprintf("verify %s failed: %v", shimConfig.Verify, err))
+		}
+		step("verify", "%s check passed", shimConfig.Verify)
+	}
+
+	if vc := shimConfig.VersionCheck; vc != nil && shimConfig.Action != "block" {
+		version, err := checkVersion(ex.SidecarPath, cmdName, vc)
+		switch {
+		case err == nil:
+			step("versionCheck", "version %s is within policy", version)
+		case vc.Blocks():
+			step("versionCheck", "%v", err)
+			return decide("BLOCKED", fmt.Sprintf("version check failed: %v", err))
+		default:
+			step("versionCheck", "%v (warning only)", err)
+		}
+	}
+
+	if shimConfig.Passthrough != nil {
+		if shouldPassthrough(shimConfig.Passthrough) {
+			step("passthrough", "an ancestor of this shell matches")
+			return decide("PASS", "parent process matched passthrough rule")
+		}
+		step("passthrough", "no ancestor of this shell matches")
+	}
+
+	if snooze, ok := registry.ActiveSnooze(cmdName, time.Now()); ok {
+		remaining := snooze.Remaining(time.Now())
+		if enforcedBy := EnforcedBy(); enforcedBy != "" {
+			step("snooze", "%s left, ignored (enforced by %s)", remaining, enforcedBy)
+		} else {
+			step("snooze", "%s left", remaining)
+			return decide("PASS", fmt.Sprintf("snoozed (%s left)", remaining))
+		}
+	} else {
+		step("snooze", "none")
+	}
+
+	if len(shimConfig.OnlyFrom) > 0 {
+		caller, ok := allowedCaller(shimConfig.OnlyFrom)
+		if !ok {
+			step("onlyFrom", "no ancestor of this shell is %s", strings.Join(shimConfig.OnlyFrom, ", "))
+			return decide("BLOCKED", onlyFromMessage(cmdName, shimConfig.OnlyFrom))
+		}
+		step("onlyFrom", "run by %s", caller)
+	}
+
+	switch shimConfig.Action {
+	case "block":
+		vars := config.MessageVars{Command: cmdName, Args: args, ConfigPath: ex.ConfigPath, Dir: cwdOrEmpty()}
+		vars.Package, _ = config.EphemeralPackage(cmdName, args)
+		if ex.Scope != nil {
+			vars.Scope = ex.Scope.Name
+		}
+		if shimConfig.InteractiveOverride {
+			if enforcedBy := EnforcedBy(); enforcedBy != "" {
+				step("interactiveOverride", "not offered (enforced by %s)", enforcedBy)
+			} else {
+				step("interactiveOverride", "offered at a terminal")
+			}
+		}
+		return decide("BLOCKED", shimConfig.RenderMessage(messageLocale(), vars))
+	case "warn":
+		return decide("PASS", "warn action")
+	case "passthrough":
+		return decide("PASS", "explicit passthrough action")
+	case "redirect":
+		if !shimConfig.HasRedirect() {
+			return decide("PASS", "redirect action but no script configured")
+		}
+		return decide("REDIRECT", shimConfig.RedirectDisplay())
+	default:
+		return decide("PASS", "no action specified")
+	}
+}
+
+// explainWrapper returns the scope matching the current directory and the
+// wrapper cmdName resolves to there, with its provenance. The user config's
+// wrappers at userPath (if any) are merged beneath the project config's;
+// with no project config, only the user config's are considered.
+func explainWrapper(projectConfig *config.ProjectConfig, configPath, userPath, cmdName string) (*config.MatchedScope, *config.ResolvedShim) {
+	resolver := config.NewResolver()
+	var userShims map[string]config.ResolvedShim
+	if userPath != "" && userPath != configPath {
+		userShims, _ = resolver.ResolveUserWrappers(userPath)
+	}
+	shims := userShims
+	var matched *config.MatchedScope
+	if projectConfig != nil {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, nil
+		}
+		var projectShims map[string]config.ResolvedShim
+		matched, projectShims, err = resolver.ResolveForCwdWithProvenance(projectConfig, configPath, cwd)
+		if err != nil {
+			return matched, nil
+		}
+		if shims, err = config.MergeUserWrappers(userShims, projectShims, projectConfig.OnConflict); err != nil {
+			return matched, nil
+		}
+	}
+	if resolved, ok := shims[cmdName]; ok {
+		return matched, &resolved
+	}
+	return matched, nil
+}
+
+func cwdOrEmpty() string {
+	cwd, _ := os.Getwd()
+	return cwd
+}
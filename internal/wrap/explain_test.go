@@ -0,0 +1,291 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestExplain(t *testing.T) {
+	// setup creates a project with a wrapped "tool" on PATH and a config that
+	// blocks it, and changes into the project directory
+	setup := func(t *testing.T, configContent string) (projectDir, binaryPath string) {
+		t.Helper()
+		root := t.TempDir()
+		t.Setenv("HOME", filepath.Join(root, "home"))
+		t.Setenv("XDG_CONFIG_HOME", "")
+		t.Setenv("RIBBIN_BYPASS", "")
+		t.Setenv("RIBBIN_ENFORCE", "")
+		t.Setenv("RIBBIN_RESCUE", "")
+
+		binDir := filepath.Join(root, "bin")
+		projectDir = filepath.Join(root, "project")
+		for _, dir := range []string{binDir, projectDir, filepath.Join(root, "home")} {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		t.Setenv("PATH", binDir)
+
+		ribbinPath := filepath.Join(root, "ribbin")
+		if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		binaryPath = filepath.Join(binDir, "tool")
+		if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho tool"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		configPath := filepath.Join(projectDir, "ribbin.jsonc")
+		if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+		if err := Install(binaryPath, ribbinPath, registry, configPath); err != nil {
+			t.Fatalf("Install error: %v", err)
+		}
+		if err := config.SaveRegistry(registry); err != nil {
+			t.Fatalf("SaveRegistry error: %v", err)
+		}
+
+		originalWd, _ := os.Getwd()
+		if err := os.Chdir(projectDir); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.Chdir(originalWd) })
+		return projectDir, binaryPath
+	}
+
+	activate := func(t *testing.T) {
+		t.Helper()
+		err := config.UpdateRegistry(func(r *config.Registry) error {
+			r.GlobalActive = true
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	blockConfig := `{"wrappers": {"tool": {"action": "block", "message": "use other-tool"}}}`
+
+	t.Run("inactive config passes through", func(t *testing.T) {
+		_, binaryPath := setup(t, blockConfig)
+
+		ex, err := Explain("tool")
+		if err != nil {
+			t.Fatalf("Explain error: %v", err)
+		}
+		if !ex.Wrapped || ex.BinaryPath != binaryPath {
+			t.Errorf("Wrapped = %v, BinaryPath = %q; want true, %q", ex.Wrapped, ex.BinaryPath, binaryPath)
+		}
+		if ex.Outcome != "PASS" || ex.Reason != "ribbin not active" {
+			t.Errorf("outcome = %s (%s), want PASS (ribbin not active)", ex.Outcome, ex.Reason)
+		}
+		if ex.Wrapper == nil || ex.Wrapper.Config.Action != "block" {
+			t.Errorf("governing wrapper should be reported even when inactive, got %+v", ex.Wrapper)
+		}
+	})
+
+	t.Run("active config blocks with message", func(t *testing.T) {
+		setup(t, blockConfig)
+		activate(t)
+
+		ex, err := Explain("tool")
+		if err != nil {
+			t.Fatalf("Explain error: %v", err)
+		}
+		if ex.Outcome != "BLOCKED" || ex.Reason != "use other-tool" {
+			t.Errorf("outcome = %s (%s), want BLOCKED (use other-tool)", ex.Outcome, ex.Reason)
+		}
+		if ex.Wrapper.Source.Fragment != "root" {
+			t.Errorf("source fragment = %q, want root", ex.Wrapper.Source.Fragment)
+		}
+
+		var checks []string
+		for _, s := range ex.Steps {
+			checks = append(checks, s.Check)
+		}
+		if got := strings.Join(checks, ","); got != "binary,RIBBIN_BYPASS,config,activation,scope,wrapper,snooze" {
+			t.Errorf("checks = %s", got)
+		}
+	})
+
+	t.Run("scope and bypass", func(t *testing.T) {
+		projectDir, _ := setup(t, `{
+			"wrappers": {"tool": {"action": "block"}},
+			"scopes": {"web": {"path": "web", "wrappers": {"tool": {"action": "passthrough"}}}}
+		}`)
+		activate(t)
+		webDir := filepath.Join(projectDir, "web")
+		if err := os.MkdirAll(webDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chdir(webDir); err != nil {
+			t.Fatal(err)
+		}
+
+		ex, err := Explain("tool")
+		if err != nil {
+			t.Fatalf("Explain error: %v", err)
+		}
+		if ex.Scope == nil || ex.Scope.Name != "web" {
+			t.Errorf("scope = %+v, want web", ex.Scope)
+		}
+		if ex.Outcome != "PASS" || ex.Reason != "explicit passthrough action" {
+			t.Errorf("outcome = %s (%s), want PASS (explicit passthrough action)", ex.Outcome, ex.Reason)
+		}
+
+		t.Setenv("RIBBIN_BYPASS", "1")
+		ex, _ = Explain("tool")
+		if ex.Outcome != "PASS" || ex.Reason != "RIBBIN_BYPASS=1" {
+			t.Errorf("outcome = %s (%s), want PASS (RIBBIN_BYPASS=1)", ex.Outcome, ex.Reason)
+		}
+	})
+
+	t.Run("enforcement ignores bypass and snooze", func(t *testing.T) {
+		setup(t, `{"enforce": true, "wrappers": {"tool": {"action": "block"}}}`)
+		activate(t)
+		err := config.UpdateRegistry(func(r *config.Registry) error {
+			r.AddSnooze("tool", time.Hour)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("RIBBIN_BYPASS", "1")
+
+		ex, err := Explain("tool")
+		if err != nil {
+			t.Fatalf("Explain error: %v", err)
+		}
+		if ex.Outcome != "BLOCKED" {
+			t.Errorf("outcome = %s (%s), want BLOCKED", ex.Outcome, ex.Reason)
+		}
+		for _, s := range ex.Steps {
+			if (s.Check == "RIBBIN_BYPASS" || s.Check == "snooze") && !strings.Contains(s.Result, "ignored (enforced by "+ex.ConfigPath+")") {
+				t.Errorf("%s step = %q, want it ignored", s.Check, s.Result)
+			}
+		}
+	})
+
+	t.Run("RIBBIN_ENFORCE ignores bypass", func(t *testing.T) {
+		setup(t, blockConfig)
+		activate(t)
+		t.Setenv("RIBBIN_BYPASS", "1")
+		t.Setenv("RIBBIN_ENFORCE", "1")
+
+		ex, _ := Explain("tool")
+		if ex.Outcome != "BLOCKED" || ex.Steps[1].Result != "set, ignored (enforced by RIBBIN_ENFORCE=1)" {
+			t.Errorf("outcome = %s, steps = %+v", ex.Outcome, ex.Steps)
+		}
+	})
+
+	t.Run("RIBBIN_RESCUE passes through despite enforcement", func(t *testing.T) {
+		setup(t, blockConfig)
+		activate(t)
+		t.Setenv("RIBBIN_RESCUE", "1")
+		t.Setenv("RIBBIN_ENFORCE", "1")
+
+		ex, _ := Explain("tool")
+		if ex.Outcome != "PASS" || ex.Steps[1].Result != "set, overriding enforcement by RIBBIN_ENFORCE=1" {
+			t.Errorf("outcome = %s, steps = %+v", ex.Outcome, ex.Steps)
+		}
+	})
+
+	t.Run("activation limited to other tags passes through", func(t *testing.T) {
+		projectDir, _ := setup(t, `{"wrappers": {"tool": {"action": "block", "tags": ["node"]}}}`)
+		err := config.UpdateRegistry(func(r *config.Registry) error {
+			r.AddConfigActivation(filepath.Join(projectDir, "ribbin.jsonc"), "migration")
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ex, err := Explain("tool")
+		if err != nil {
+			t.Fatalf("Explain error: %v", err)
+		}
+		if ex.Outcome != "PASS" || ex.Reason != "activation is limited to tags migration" {
+			t.Errorf("outcome = %s (%s), want PASS for an untagged activation", ex.Outcome, ex.Reason)
+		}
+		last := ex.Steps[len(ex.Steps)-1]
+		if last.Check != "tags" || last.Result != "wrapper is tagged node" {
+			t.Errorf("last step = %+v", last)
+		}
+	})
+
+	t.Run("activation limited to a scope", func(t *testing.T) {
+		projectDir, _ := setup(t, `{
+			"wrappers": {"tool": {"action": "block"}},
+			"scopes": {
+				"backend": {"path": "apps/backend", "extends": ["root"]},
+				"web": {"path": "apps/web", "extends": ["root"]}
+			}
+		}`)
+		err := config.UpdateRegistry(func(r *config.Registry) error {
+			r.AddScopedConfigActivation(filepath.Join(projectDir, "ribbin.jsonc"), "backend")
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tt := range []struct{ dir, outcome string }{
+			{"apps/backend", "BLOCKED"},
+			{"apps/web", "PASS"},
+			{".", "PASS"},
+		} {
+			dir := filepath.Join(projectDir, tt.dir)
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chdir(dir); err != nil {
+				t.Fatal(err)
+			}
+			ex, err := Explain("tool")
+			if err != nil {
+				t.Fatalf("Explain error: %v", err)
+			}
+			if ex.Outcome != tt.outcome {
+				t.Errorf("in %s: outcome = %s (%s), want %s", tt.dir, ex.Outcome, ex.Reason, tt.outcome)
+			}
+			if tt.outcome == "PASS" && ex.Reason != "activation is limited to scopes backend" {
+				t.Errorf("in %s: reason = %q", tt.dir, ex.Reason)
+			}
+		}
+	})
+
+	t.Run("replaced wrapper is reported", func(t *testing.T) {
+		_, binaryPath := setup(t, blockConfig)
+		if err := os.Remove(binaryPath); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho v2"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		ex, _ := Explain("tool")
+		if ex.Wrapped || ex.Outcome != "NOT WRAPPED" || !strings.Contains(ex.Reason, "ribbin heal") {
+			t.Errorf("Wrapped = %v, outcome = %s (%s); want a replaced wrapper", ex.Wrapped, ex.Outcome, ex.Reason)
+		}
+	})
+
+	t.Run("unknown command", func(t *testing.T) {
+		setup(t, blockConfig)
+		ex, err := Explain("nonexistent-command")
+		if err != nil {
+			t.Fatalf("Explain error: %v", err)
+		}
+		if ex.Outcome != "NOT WRAPPED" || ex.BinaryPath != "" {
+			t.Errorf("outcome = %s, BinaryPath = %q", ex.Outcome, ex.BinaryPath)
+		}
+	})
+}
@@ -0,0 +1,257 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// HealStatus describes whether a wrapped binary survived an upgrade
+type HealStatus int
+
+const (
+	HealNotNeeded      HealStatus = iota // Shim in place and sidecar matches metadata
+	HealShimReplaced                     // Upgrade replaced the shim with a new binary
+	HealSidecarChanged                   // Upgrade rewrote the original behind the sidecar
+	HealMissing                          // Binary no longer exists; nothing to heal
+	HealRibbinMoved                      // Shim points at a ribbin binary that no longer exists (see Relink)
+)
+
+// String returns a short human-readable description of the status
+func (s HealStatus) String() string {
+	switch s {
+	case HealNotNeeded:
+		return "healthy"
+	case HealShimReplaced:
+		return "shim replaced by upgrade"
+	case HealSidecarChanged:
+		return "original changed since wrap"
+	case HealMissing:
+		return "binary missing"
+	case HealRibbinMoved:
+		return "ribbin binary moved"
+	default:
+		return "unknown"
+	}
+}
+
+// HealCheck is the result of inspecting a wrapped binary for upgrade damage
+type HealCheck struct {
+	BinaryPath   string
+	Status       HealStatus
+	CurrentHash  string // Hash of the binary a heal would wrap
+	OriginalHash string // Hash recorded in metadata at wrap time
+}
+
+// StalePath returns where a stale sidecar is archived during heal.
+// The name deliberately does not end in .ribbin-original so that archived
+// sidecars are never mistaken for live ones by the shim or FindSidecars.
+func StalePath(binaryPath string) string {
+	return binaryPath + ".ribbin-stale"
+}
+
+// CheckHeal inspects a wrapped binary for damage done by a package manager
+// upgrade (brew, apt, npm, ...). ribbinPath is the ribbin binary shims are
+// expected to point at.
+//
+// Upgrades typically either replace the shim symlink with the new binary, or
+// rewrite the file the sidecar points at. The first is detected by the shim
+// no longer resolving to ribbin; the second by the sidecar hash no longer
+// matching the metadata (see CheckHashConflict).
+func CheckHeal(binaryPath, ribbinPath string) HealCheck {
+	check := HealCheck{BinaryPath: binaryPath}
+
+	meta, _ := LoadMetadata(binaryPath)
+	if meta != nil {
+		check.OriginalHash = meta.OriginalHash
+	}
+
+	if _, err := os.Lstat(binaryPath); err != nil {
+		check.Status = HealMissing
+		return check
+	}
+
+	if CheckRelink(binaryPath, ribbinPath).Status == RelinkBroken {
+		check.Status = HealRibbinMoved
+		return check
+	}
+
+	if !isShim(binaryPath, ribbinPath, meta) {
+		check.Status = HealShimReplaced
+		check.CurrentHash, _ = hashFile(binaryPath)
+		return check
+	}
+
+	hasConflict, currentHash, _ := CheckHashConflict(binaryPath)
+	check.CurrentHash = currentHash
+	if hasConflict {
+		check.Status = HealSidecarChanged
+	}
+	return check
+}
+
+// isShim reports whether binaryPath resolves to ribbin, either the given
+// ribbinPath or the one recorded in metadata at wrap time, or is the copy of
+// ribbin recorded in metadata.
+func isShim(binaryPath, ribbinPath string, meta *WrapperMetadata) bool {
+	if isCopyShim(binaryPath, meta) {
+		return true
+	}
+	info, err := os.Lstat(binaryPath)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return false
+	}
+	target, err := os.Stat(binaryPath)
+	if err != nil {
+		return false
+	}
+	for _, candidate := range []string{ribbinPath, metaRibbinPath(meta)} {
+		if candidate == "" {
+			continue
+		}
+		if ribbinInfo, err := os.Stat(candidate); err == nil && os.SameFile(target, ribbinInfo) {
+			return true
+		}
+	}
+	return false
+}
+
+func metaRibbinPath(meta *WrapperMetadata) string {
+	if meta == nil {
+		return ""
+	}
+	return meta.RibbinPath
+}
+
+// Heal repairs a wrapped binary damaged by an upgrade, based on CheckHeal:
+//   - HealShimReplaced: the stale sidecar is archived to StalePath (or
+//     removed if discard is true) and the newly installed binary is wrapped
+//     again with Install, which also rewrites the metadata.
+//   - HealSidecarChanged: the sidecar already runs the new version, so only
+//     the metadata (and the copy kept next to a symlink target) is refreshed.
+//
+// Returns the check that was acted on. HealNotNeeded, HealMissing and
+// HealRibbinMoved are returned without changes.
+func Heal(binaryPath, ribbinPath string, registry *config.Registry, configPath string, discard bool) (HealCheck, error) {
+	check := CheckHeal(binaryPath, ribbinPath)
+
+	switch check.Status {
+	case HealShimReplaced:
+		return check, rewrapReplaced(binaryPath, ribbinPath, registry, configPath, discard)
+	case HealSidecarChanged:
+		return check, refreshSidecar(binaryPath, ribbinPath, discard)
+	default:
+		return check, nil
+	}
+}
+
+// rewrapReplaced retires the stale sidecar of a replaced shim and wraps the
+// new binary. If wrapping fails, an archived sidecar is put back so the state
+// is unchanged.
+func rewrapReplaced(binaryPath, ribbinPath string, registry *config.Registry, configPath string, discard bool) error {
+	sidecarPath := sidecarFor(binaryPath)
+	stalePath := StalePath(binaryPath)
+
+	security.LogPrivilegedOperation("heal_rewrap", binaryPath, true, nil)
+
+	archived := false
+	if _, err := os.Lstat(sidecarPath); err == nil {
+		if discard {
+			if err := os.Remove(sidecarPath); err != nil {
+				return fmt.Errorf("cannot remove stale sidecar: %w", err)
+			}
+		} else {
+			if err := os.Rename(sidecarPath, stalePath); err != nil {
+				return fmt.Errorf("cannot archive stale sidecar: %w", err)
+			}
+			archived = true
+		}
+	}
+
+	if err := Install(binaryPath, ribbinPath, registry, configPath); err != nil {
+		if archived {
+			if restoreErr := os.Rename(stalePath, sidecarPath); restoreErr != nil {
+				return fmt.Errorf("cannot re-wrap (and restoring stale sidecar failed: %v): %w", restoreErr, err)
+			}
+		}
+		return fmt.Errorf("cannot re-wrap: %w", err)
+	}
+	removeEmptySidecarDirs(sidecarPath)
+
+	return nil
+}
+
+// refreshSidecar records the current sidecar contents as the wrapped original.
+// When the binary was a symlink at wrap time, Install also left a copy of the
+// original next to the final target; that copy is now stale and is archived
+// (or removed) and recreated from the new version.
+func refreshSidecar(binaryPath, ribbinPath string, discard bool) error {
+	sidecarPath := sidecarFor(binaryPath)
+
+	security.LogPrivilegedOperation("heal_refresh", binaryPath, true, nil)
+
+	if target, err := filepath.EvalSymlinks(sidecarPath); err == nil && target != sidecarPath {
+		targetSidecar := target + ".ribbin-original"
+		if _, err := os.Lstat(targetSidecar); err == nil {
+			if discard {
+				err = os.Remove(targetSidecar)
+			} else {
+				err = os.Rename(targetSidecar, StalePath(target))
+			}
+			if err != nil {
+				return fmt.Errorf("cannot retire stale target sidecar: %w", err)
+			}
+			if err := copyFile(target, targetSidecar); err != nil {
+				return fmt.Errorf("cannot refresh target sidecar: %w", err)
+			}
+		}
+	}
+
+	return recordMetadata(binaryPath, sidecarPath, ribbinPath)
+}
+
+// recordMetadata hashes the sidecar and writes the .ribbin-meta file for binaryPath
+func recordMetadata(binaryPath, sidecarPath, ribbinPath string) error {
+	meta, err := originalMetadata(sidecarPath, ribbinPath)
+	if err != nil {
+		return err
+	}
+	if sidecarPath != binaryPath+sidecarSuffix {
+		meta.Sidecar = sidecarPath
+	}
+	if err := recordShim(meta, binaryPath); err != nil {
+		return err
+	}
+	return saveMetadata(binaryPath, meta)
+}
+
+// originalMetadata returns the metadata describing the original at
+// originalPath, wrapped now to run ribbinPath
+func originalMetadata(originalPath, ribbinPath string) (*WrapperMetadata, error) {
+	hash, err := hashFile(originalPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(originalPath)
+	if err != nil {
+		return nil, err
+	}
+	meta := &WrapperMetadata{
+		WrappedAt:     time.Now(),
+		OriginalHash:  hash,
+		OriginalSize:  info.Size(),
+		RibbinPath:    ribbinPath,
+		RibbinVersion: Version,
+	}
+	if info, err := os.Lstat(originalPath); err == nil && info.Mode().IsRegular() {
+		if links := linkCount(info); links > 1 {
+			meta.OriginalLinks = links
+		}
+	}
+	meta.OriginalAttributes = captureAttributes(originalPath)
+	return meta, nil
+}
@@ -0,0 +1,123 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestHeal(t *testing.T) {
+	// setup wraps a fresh binary and returns its path along with ribbin's path
+	setup := func(t *testing.T) (binaryPath, ribbinPath string, registry *config.Registry) {
+		t.Helper()
+		dir := t.TempDir()
+		ribbinPath = filepath.Join(dir, "ribbin")
+		if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+			t.Fatalf("failed to create ribbin: %v", err)
+		}
+		binaryPath = filepath.Join(dir, "tool")
+		if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho v1"), 0755); err != nil {
+			t.Fatalf("failed to create binary: %v", err)
+		}
+		registry = &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+		if err := Install(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc"); err != nil {
+			t.Fatalf("Install error: %v", err)
+		}
+		return binaryPath, ribbinPath, registry
+	}
+
+	// upgradeReplacingShim mimics a package manager installing over the shim
+	upgradeReplacingShim := func(t *testing.T, binaryPath string) {
+		t.Helper()
+		if err := os.Remove(binaryPath); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho v2"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("healthy wrapper needs nothing", func(t *testing.T) {
+		binaryPath, ribbinPath, _ := setup(t)
+		if check := CheckHeal(binaryPath, ribbinPath); check.Status != HealNotNeeded {
+			t.Errorf("status = %s, want %s", check.Status, HealNotNeeded)
+		}
+	})
+
+	t.Run("replaced shim is re-wrapped and stale sidecar archived", func(t *testing.T) {
+		binaryPath, ribbinPath, registry := setup(t)
+		upgradeReplacingShim(t, binaryPath)
+
+		if check := CheckHeal(binaryPath, ribbinPath); check.Status != HealShimReplaced {
+			t.Fatalf("status = %s, want %s", check.Status, HealShimReplaced)
+		}
+
+		registry.RemoveWrapper(binaryPath)
+		if _, err := Heal(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc", false); err != nil {
+			t.Fatalf("Heal error: %v", err)
+		}
+
+		if check := CheckHeal(binaryPath, ribbinPath); check.Status != HealNotNeeded {
+			t.Errorf("after heal status = %s, want %s", check.Status, HealNotNeeded)
+		}
+		content, _ := os.ReadFile(binaryPath + ".ribbin-original")
+		if string(content) != "#!/bin/sh\necho v2" {
+			t.Errorf("sidecar should hold the upgraded binary, got %q", content)
+		}
+		stale, err := os.ReadFile(StalePath(binaryPath))
+		if err != nil {
+			t.Fatalf("stale sidecar should be archived: %v", err)
+		}
+		if string(stale) != "#!/bin/sh\necho v1" {
+			t.Errorf("archived sidecar should hold the old binary, got %q", stale)
+		}
+		if _, ok := registry.Wrapper(binaryPath); !ok {
+			t.Error("heal should record the wrapper in the registry")
+		}
+	})
+
+	t.Run("discard deletes stale sidecar", func(t *testing.T) {
+		binaryPath, ribbinPath, registry := setup(t)
+		upgradeReplacingShim(t, binaryPath)
+
+		if _, err := Heal(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc", true); err != nil {
+			t.Fatalf("Heal error: %v", err)
+		}
+		if _, err := os.Lstat(StalePath(binaryPath)); !os.IsNotExist(err) {
+			t.Error("stale sidecar should not be archived with discard")
+		}
+	})
+
+	t.Run("changed sidecar gets fresh metadata", func(t *testing.T) {
+		binaryPath, ribbinPath, registry := setup(t)
+		if err := os.WriteFile(binaryPath+".ribbin-original", []byte("#!/bin/sh\necho v2"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if check := CheckHeal(binaryPath, ribbinPath); check.Status != HealSidecarChanged {
+			t.Fatalf("status = %s, want %s", check.Status, HealSidecarChanged)
+		}
+		if _, err := Heal(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc", false); err != nil {
+			t.Fatalf("Heal error: %v", err)
+		}
+		if hasConflict, _, _ := CheckHashConflict(binaryPath); hasConflict {
+			t.Error("metadata should match the sidecar after heal")
+		}
+	})
+
+	t.Run("missing binary is reported, not healed", func(t *testing.T) {
+		binaryPath, ribbinPath, registry := setup(t)
+		os.Remove(binaryPath)
+
+		check, err := Heal(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc", false)
+		if err != nil {
+			t.Fatalf("Heal error: %v", err)
+		}
+		if check.Status != HealMissing {
+			t.Errorf("status = %s, want %s", check.Status, HealMissing)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// hookContext is what the hooks of the wrapper being run need to know about
+// it. Run sets execHooks once the wrapper is known; until then (and for
+// wrappers without hooks) Hooks is nil and programs run without hooks.
+type hookContext struct {
+	Hooks      *config.HooksConfig
+	Command    string
+	Original   string
+	ConfigPath string
+	Args       []string
+}
+
+var execHooks hookContext
+
+// hasAfterHook reports whether an after hook needs ribbin to stay resident
+func (h hookContext) hasAfterHook() bool {
+	return h.Hooks != nil && h.Hooks.After != ""
+}
+
+// runBeforeHook runs the before hook, if there is one. If it can't run or
+// exits non-zero, the wrapper exits without running the program: with the
+// hook's exit code, or 1 if it couldn't run.
+func runBeforeHook(env []string) {
+	if execHooks.Hooks == nil || execHooks.Hooks.Before == "" {
+		return
+	}
+	status, err := runHook("before", execHooks.Hooks.Before, env)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ribbin: before hook for '%s' failed, not running it: %v\n", execHooks.Command, err)
+		os.Exit(1)
+	}
+	if status.Code != 0 {
+		verboseLogDecision(execHooks.Command, "BLOCKED", fmt.Sprintf("before hook exited %d", status.Code))
+		fmt.Fprintf(os.Stderr, "ribbin: before hook for '%s' exited %d, not running it\n", execHooks.Command, status.Code)
+		exitWithStatus(status)
+	}
+}
+
+// runAfterHook runs the after hook, if there is one, telling it how the
+// program ended. Its own failure is only reported: the wrapper still exits
+// the way the program did.
+func runAfterHook(env []string, programStatus spawnStatus) {
+	if !execHooks.hasAfterHook() {
+		return
+	}
+	env = append(env[:len(env):len(env)], "RIBBIN_EXIT_CODE="+strconv.Itoa(programStatus.Code))
+	status, err := runHook("after", execHooks.Hooks.After, env)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ribbin: after hook for '%s' failed: %v\n", execHooks.Command, err)
+	} else if status.Code != 0 {
+		fmt.Fprintf(os.Stderr, "ribbin: after hook for '%s' exited %d\n", execHooks.Command, status.Code)
+	}
+}
+
+// runHook runs a hook command as a child (see spawnArgv), with the same
+// placeholders as an inline redirect and ribbin's context in its env.
+func runHook(stage, hook string, env []string) (spawnStatus, error) {
+	words, err := config.ParseHookCommand(hook)
+	if err != nil {
+		return spawnStatus{}, err
+	}
+	cwd, _ := os.Getwd()
+	argv := interpolateRedirect(words, redirectContext{
+		Args:     execHooks.Args,
+		Cwd:      cwd,
+		Original: execHooks.Original,
+	})
+	if len(argv) == 0 || argv[0] == "" {
+		return spawnStatus{}, fmt.Errorf("hook command is empty after substitution")
+	}
+	program, err := resolveRedirectProgram(argv[0], execHooks.ConfigPath)
+	if err != nil {
+		return spawnStatus{}, err
+	}
+	argv[0] = program
+
+	env = append(env[:len(env):len(env)],
+		"RIBBIN_HOOK="+stage,
+		"RIBBIN_COMMAND="+execHooks.Command,
+		"RIBBIN_ORIGINAL_BIN="+execHooks.Original,
+		"RIBBIN_CONFIG="+execHooks.ConfigPath,
+	)
+	return spawnArgv(argv, env)
+}
@@ -0,0 +1,54 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestRunHook(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "ribbin.jsonc")
+	logPath := filepath.Join(dir, "hook.log")
+	script := "#!/bin/sh\necho \"$RIBBIN_HOOK $RIBBIN_COMMAND $RIBBIN_EXIT_CODE $*\" >> " + logPath + "\nexit \"${HOOK_EXIT:-0}\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "hook.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	orig := execHooks
+	t.Cleanup(func() { execHooks = orig })
+	execHooks = hookContext{
+		Hooks:      &config.HooksConfig{Before: "./hook.sh {arg0}", After: "./hook.sh"},
+		Command:    "kubectl",
+		Original:   "/usr/bin/kubectl.ribbin-original",
+		ConfigPath: configPath,
+		Args:       []string{"delete", "pod"},
+	}
+
+	status, err := runHook("before", execHooks.Hooks.Before, os.Environ())
+	if err != nil || status.Code != 0 {
+		t.Fatalf("runHook = %+v, %v", status, err)
+	}
+	status, err = runHook("after", execHooks.Hooks.After, append(os.Environ(), "RIBBIN_EXIT_CODE=2", "HOOK_EXIT=5"))
+	if err != nil {
+		t.Fatalf("runHook error: %v", err)
+	}
+	if status.Code != 5 {
+		t.Errorf("status = %+v, want the hook's exit code 5", status)
+	}
+
+	log, _ := os.ReadFile(logPath)
+	lines := strings.Split(strings.TrimSpace(string(log)), "\n")
+	want := []string{"before kubectl  delete", "after kubectl 2"}
+	if len(lines) != 2 || lines[0] != want[0] || lines[1] != want[1] {
+		t.Errorf("hook log = %q, want %q", lines, want)
+	}
+
+	if _, err := runHook("before", "./missing.sh", os.Environ()); err == nil {
+		t.Error("expected an error for a missing hook script")
+	}
+}
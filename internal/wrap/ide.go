@@ -0,0 +1,138 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// WorkspaceFormatVersion is the format of a WorkspaceReport. It only changes
+// when a field is removed or changes meaning; fields may be added to any
+// version, so editors should ignore the ones they don't know.
+const WorkspaceFormatVersion = 1
+
+// WorkspaceReport is what ribbin does with commands run in a directory, for
+// an editor to warn about a blocked command before it is run in a terminal
+// (see 'ribbin ide')
+type WorkspaceReport struct {
+	FormatVersion int `json:"format_version"`
+	// Path is the file or directory asked about, and Dir the directory
+	// commands would run in for it
+	Path string `json:"path"`
+	Dir  string `json:"dir"`
+	// Config is the governing project config, or "" if there is none
+	Config     string `json:"config,omitempty"`
+	UserConfig string `json:"user_config,omitempty"`
+	// Scope is the config's scope containing Dir, or "" for the root
+	Scope string `json:"scope,omitempty"`
+	// Activation describes what makes ribbin active in Dir, or is "" if
+	// nothing does. Shell activations count only for the shell ribbin runs in.
+	Activation string             `json:"activation,omitempty"`
+	Commands   []WorkspaceCommand `json:"commands"`
+}
+
+// WorkspaceCommand is the effective wrapper of one command in a directory
+type WorkspaceCommand struct {
+	Command string `json:"command"`
+	// Action is the wrapper's action today, after its "expires" date
+	Action string `json:"action"`
+	// Message is the block or warn message, with placeholders filled in
+	Message  string   `json:"message,omitempty"`
+	Redirect string   `json:"redirect,omitempty"`
+	DocsURL  string   `json:"docs_url,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Expires  string   `json:"expires,omitempty"`
+	// ArgRules is true when some invocations take other actions (see
+	// 'ribbin which <command> -- args')
+	ArgRules bool `json:"arg_rules,omitempty"`
+	// OnlyFrom names the commands allowed to run this one; run any other
+	// way, it is blocked
+	OnlyFrom []string `json:"only_from,omitempty"`
+	// OffSchedule is true when the wrapper's schedule doesn't cover the
+	// present time, so the command runs unwrapped until it does
+	OffSchedule bool `json:"off_schedule,omitempty"`
+	// Wrapped is true when some binary with this name is wrapped
+	Wrapped bool `json:"wrapped"`
+	// Active is true when an activation covers the wrapper in Dir. A command
+	// that is both wrapped and active takes its action when run there.
+	Active bool `json:"active"`
+}
+
+// DescribeWorkspace reports the effective wrappers for commands run in path,
+// or in the directory of path if it names a file. path doesn't need to
+// exist, so an editor can ask about a file that hasn't been saved yet.
+func DescribeWorkspace(path string, registry *config.Registry) (*WorkspaceReport, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	dir := abs
+	if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+		dir = filepath.Dir(abs)
+	}
+
+	report := &WorkspaceReport{FormatVersion: WorkspaceFormatVersion, Path: abs, Dir: dir, Commands: []WorkspaceCommand{}}
+	configPath, err := config.FindProjectConfigFrom(dir)
+	if err != nil {
+		return nil, err
+	}
+	var projectConfig *config.ProjectConfig
+	if configPath != "" {
+		if projectConfig, err = config.LoadProjectConfig(configPath); err != nil {
+			return nil, err
+		}
+	}
+	d, err := resolveDecision(dir, configPath, projectConfig)
+	if err != nil {
+		return nil, err
+	}
+	report.Config, report.UserConfig = d.ConfigPath, d.UserConfigPath
+	report.Scope = d.Scopes[configPath]
+	if d.ConfigPath == "" && d.UserConfigPath == "" {
+		return report, nil
+	}
+
+	_, act, userOnly := governingActivation(registry, d.ConfigPath, d.UserConfigPath)
+	report.Activation = act.String()
+	wrappers := d.Wrappers
+	if userOnly {
+		wrappers = d.UserWrappers
+	}
+
+	now := time.Now()
+	locale := messageLocale()
+	names := make([]string, 0, len(wrappers))
+	for name := range wrappers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		shim := wrappers[name]
+		effective := shim.Effective(now)
+		command := WorkspaceCommand{
+			Command:  name,
+			Action:   effective.Action,
+			Redirect: effective.RedirectDisplay(),
+			DocsURL:  effective.DocsURL,
+			Tags:     effective.Tags,
+			Expires:  effective.Expires,
+			ArgRules: len(effective.ArgRules) > 0,
+			OnlyFrom: effective.OnlyFrom,
+			Wrapped:  registry.HasCommand(name),
+			Active:   act.covers(shim, d.Scopes),
+		}
+		_, scheduled := effective.Scheduled(now)
+		command.OffSchedule = !scheduled
+		command.Message = effective.RenderMessage(locale, config.MessageVars{
+			Command:    name,
+			Scope:      report.Scope,
+			ConfigPath: configPath,
+			Dir:        dir,
+		})
+		report.Commands = append(report.Commands, command)
+	}
+	return report, nil
+}
@@ -0,0 +1,100 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestDescribeWorkspace(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("LANG", "")
+
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve symlinks: %v", err)
+	}
+	configPath := filepath.Join(tmpDir, "ribbin.jsonc")
+	content := `{
+  "wrappers": {
+    "npm": {"action": "block", "message": "Use pnpm ({scope})", "tags": ["node"]},
+    "yarn": {"action": "block", "expires": "2000-01-01"}
+  },
+  "scopes": {
+    "backend": {
+      "path": "backend",
+      "extends": ["root"],
+      "wrappers": {"tsc": {"action": "redirect", "redirect": "pnpm exec tsc {args}"}}
+    }
+  }
+}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "backend"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := &config.Registry{
+		Wrappers: map[string]config.WrapperEntry{
+			"/usr/bin/npm": {Original: "/usr/bin/npm", Config: configPath},
+		},
+		ShellActivations:  map[int]config.ShellActivationEntry{},
+		ConfigActivations: map[string]config.ConfigActivationEntry{},
+	}
+	registry.AddScopedConfigActivation(configPath, "backend")
+
+	// An unsaved file in the scope
+	report, err := DescribeWorkspace(filepath.Join(tmpDir, "backend", "new.ts"), registry)
+	if err != nil {
+		t.Fatalf("DescribeWorkspace error: %v", err)
+	}
+	if report.FormatVersion != WorkspaceFormatVersion || report.Dir != filepath.Join(tmpDir, "backend") {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if report.Config != configPath || report.Scope != "backend" || report.Activation == "" {
+		t.Errorf("config = %q, scope = %q, activation = %q", report.Config, report.Scope, report.Activation)
+	}
+	commands := make(map[string]WorkspaceCommand)
+	for _, c := range report.Commands {
+		commands[c.Command] = c
+	}
+	if len(commands) != 3 {
+		t.Fatalf("expected npm, tsc and yarn, got %+v", report.Commands)
+	}
+	if npm := commands["npm"]; npm.Action != "block" || npm.Message != "Use pnpm (backend)" || !npm.Wrapped || !npm.Active {
+		t.Errorf("npm = %+v", npm)
+	}
+	if tsc := commands["tsc"]; tsc.Action != "redirect" || tsc.Redirect == "" || tsc.Wrapped {
+		t.Errorf("tsc = %+v", tsc)
+	}
+	if yarn := commands["yarn"]; yarn.Action != "warn" {
+		t.Errorf("an expired block should warn, got %+v", yarn)
+	}
+
+	// Outside the scope the activation doesn't apply
+	report, err = DescribeWorkspace(configPath, registry)
+	if err != nil {
+		t.Fatalf("DescribeWorkspace error: %v", err)
+	}
+	if report.Scope != "" || len(report.Commands) != 2 {
+		t.Errorf("root: scope = %q, commands = %+v", report.Scope, report.Commands)
+	}
+	for _, c := range report.Commands {
+		if c.Active {
+			t.Errorf("%s should not be active outside the activated scope", c.Command)
+		}
+	}
+
+	// No config at all
+	report, err = DescribeWorkspace(string(filepath.Separator), registry)
+	if err != nil {
+		t.Fatalf("DescribeWorkspace error: %v", err)
+	}
+	if report.Config != "" || report.Commands == nil || len(report.Commands) != 0 {
+		t.Errorf("expected an empty report, got %+v", report)
+	}
+}
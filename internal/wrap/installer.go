@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/happycollision/ribbin/internal/config"
@@ -25,8 +26,39 @@ type WrapperMetadata struct {
 	OriginalSize  int64     `json:"original_size"`
 	RibbinPath    string    `json:"ribbin_path"`
 	RibbinVersion string    `json:"ribbin_version"`
+	// Sidecar is where the original is kept when it isn't next to the
+	// wrapper (config.SidecarLayoutDirectory)
+	Sidecar string `json:"sidecar,omitempty"`
+	// ShimMode is config.ShimModeCopy when the wrapper is a copy of the
+	// ribbin binary rather than a symlink to it. ShimHash and ShimSize
+	// identify the copy, so a binary reinstalled over it isn't mistaken for
+	// a wrapper.
+	ShimMode string `json:"shim_mode,omitempty"`
+	ShimHash string `json:"shim_hash,omitempty"`
+	ShimSize int64  `json:"shim_size,omitempty"`
+	// OriginalLinks is the number of hard links the original had when it
+	// was wrapped, if more than one, so Uninstall can tell when the other
+	// copies have since been replaced
+	OriginalLinks uint64 `json:"original_links,omitempty"`
+	// OriginalAttributes are the original's mode, owner, modification time
+	// and extended attributes, put back when it is restored
+	OriginalAttributes *FileAttributes `json:"original_attributes,omitempty"`
+	// Shadows is the binary a wrapper in the shadow directory runs in place
+	// of, which was left where it is (see InstallShadow)
+	Shadows string `json:"shadows,omitempty"`
 }
 
+// sidecarSuffix names an original kept next to its wrapper
+const sidecarSuffix = ".ribbin-original"
+
+// SidecarStoreDir is the directory, next to a wrapper, that holds originals
+// in the directory layout: .ribbin/sidecars/<hash>/<binary name>
+var SidecarStoreDir = filepath.Join(".ribbin", "sidecars")
+
+// ProjectSidecarDir is the directory, in a project config's directory, that
+// holds originals in the project layout: .ribbin/originals/<relative path>
+var ProjectSidecarDir = filepath.Join(".ribbin", "originals")
+
 // MetadataPath returns the metadata file path for a binary
 func MetadataPath(binaryPath string) string {
 	return binaryPath + ".ribbin-meta"
@@ -73,8 +105,11 @@ func copyFile(src, dst string) error {
 	}
 	defer dstFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		return err
+	}
+	// The umask may have left out some of the bits when the file was created
+	return dstFile.Chmod(srcInfo.Mode())
 }
 
 // LoadMetadata reads metadata from a .ribbin-meta file
@@ -113,6 +148,58 @@ func removeMetadata(binaryPath string) error {
 	return err
 }
 
+// IsCopyShim reports whether binaryPath is a wrapper made in the copy shim
+// mode (config.ShimModeCopy): a regular file that is still the copy of
+// ribbin its metadata records
+func IsCopyShim(binaryPath string) bool {
+	meta, err := LoadMetadata(binaryPath)
+	return err == nil && isCopyShim(binaryPath, meta)
+}
+
+func isCopyShim(binaryPath string, meta *WrapperMetadata) bool {
+	if meta == nil || meta.ShimMode != config.ShimModeCopy {
+		return false
+	}
+	info, err := os.Lstat(binaryPath)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != meta.ShimSize {
+		return false
+	}
+	hash, err := hashFile(binaryPath)
+	return err == nil && hash == meta.ShimHash
+}
+
+// recordShim notes in meta whether the wrapper at binaryPath is a copy of
+// ribbin, and what identifies the copy
+func recordShim(meta *WrapperMetadata, binaryPath string) error {
+	meta.ShimMode, meta.ShimHash, meta.ShimSize = "", "", 0
+	info, err := os.Lstat(binaryPath)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	hash, err := hashFile(binaryPath)
+	if err != nil {
+		return err
+	}
+	meta.ShimMode = config.ShimModeCopy
+	meta.ShimHash = hash
+	meta.ShimSize = info.Size()
+	return nil
+}
+
+// copyShim writes a copy of the ribbin binary to binaryPath. ribbin acts as
+// a wrapper whenever it runs under another name, so the copy works like a
+// symlink would.
+func copyShim(ribbinPath, binaryPath string) error {
+	if err := copyFile(ribbinPath, binaryPath); err != nil {
+		os.Remove(binaryPath)
+		return err
+	}
+	return nil
+}
+
 // ConflictResolution represents how a hash mismatch was resolved
 type ConflictResolution int
 
@@ -135,7 +222,7 @@ type UnwrapResult struct {
 // CheckHashConflict checks if the sidecar hash differs from what was recorded at wrap time.
 // Returns true if there's a conflict, false if no conflict or no metadata.
 func CheckHashConflict(binaryPath string) (hasConflict bool, currentHash string, originalHash string) {
-	sidecarPath := binaryPath + ".ribbin-original"
+	sidecarPath := sidecarFor(binaryPath)
 
 	// Load metadata
 	meta, err := LoadMetadata(binaryPath)
@@ -159,27 +246,231 @@ func CheckHashConflict(binaryPath string) (hasConflict bool, currentHash string,
 	return false, currentHash, meta.OriginalHash
 }
 
+// ErrSidecarModified is returned by VerifySidecar when the original binary no
+// longer matches what was recorded at wrap time
+var ErrSidecarModified = errors.New("original binary modified since it was wrapped")
+
+// VerifySidecar checks the sidecar of binaryPath against its wrap-time metadata
+// according to policy (config.VerifyHash, config.VerifySize or config.VerifyNone).
+// An empty policy means none. Verification fails closed: a sidecar without
+// metadata, or an unknown policy, is an error.
+func VerifySidecar(binaryPath, policy string) error {
+	if policy == "" || policy == config.VerifyNone {
+		return nil
+	}
+	if policy != config.VerifyHash && policy != config.VerifySize {
+		return fmt.Errorf("unknown verify policy %q", policy)
+	}
+
+	meta, err := LoadMetadata(binaryPath)
+	if err != nil {
+		return fmt.Errorf("cannot verify original: no metadata recorded at wrap time (%w)", err)
+	}
+
+	sidecarPath := sidecarFor(binaryPath)
+	if policy == config.VerifySize {
+		info, err := os.Stat(sidecarPath)
+		if err != nil {
+			return fmt.Errorf("cannot verify original: %w", err)
+		}
+		if info.Size() != meta.OriginalSize {
+			return fmt.Errorf("%w: size is %d bytes, expected %d", ErrSidecarModified, info.Size(), meta.OriginalSize)
+		}
+		return nil
+	}
+
+	hash, err := hashFile(sidecarPath)
+	if err != nil {
+		return fmt.Errorf("cannot verify original: %w", err)
+	}
+	if hash != meta.OriginalHash {
+		return fmt.Errorf("%w: hash is %s, expected %s", ErrSidecarModified, hash, meta.OriginalHash)
+	}
+	return nil
+}
+
 // SidecarPath returns the sidecar path for a binary
 func SidecarPath(binaryPath string) (string, error) {
 	// Validate binary path first
 	if err := security.ValidateBinaryPath(binaryPath); err != nil {
 		return "", fmt.Errorf("invalid binary path: %w", err)
 	}
-	return binaryPath + ".ribbin-original", nil
+	return sidecarFor(binaryPath), nil
+}
+
+// sidecarFor returns where the original of binaryPath is kept: the sidecar
+// its metadata points at in the directory and project layouts, otherwise
+// next to it. The metadata is only read when there is no sidecar next to the
+// binary, or its directory has a sidecar store, so the adjacent layout costs
+// two stats.
+func sidecarFor(binaryPath string) string {
+	adjacent := binaryPath + sidecarSuffix
+	_, adjacentErr := os.Stat(adjacent)
+	_, storeErr := os.Stat(filepath.Join(filepath.Dir(binaryPath), SidecarStoreDir))
+	if adjacentErr != nil || storeErr == nil {
+		if meta, err := LoadMetadata(binaryPath); err == nil && meta.Sidecar != "" {
+			return meta.Sidecar
+		}
+	}
+	return adjacent
+}
+
+// ProjectSidecarPath returns where the project layout keeps the original of
+// binaryPath for the config at configPath, or "" if the binary isn't under
+// the config's directory.
+func ProjectSidecarPath(configPath, binaryPath string) string {
+	configDir := filepath.Dir(configPath)
+	rel, err := filepath.Rel(configDir, binaryPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.Join(configDir, ProjectSidecarDir, rel)
+}
+
+// projectSidecarMarker is how a project-layout sidecar's path starts after
+// the config's directory
+var projectSidecarMarker = string(filepath.Separator) + ProjectSidecarDir + string(filepath.Separator)
+
+// storeSidecarPath returns where the directory layout keeps the original of
+// binaryPath, whose contents hash to hash ("sha256:...")
+func storeSidecarPath(binaryPath, hash string) string {
+	digest := strings.TrimPrefix(hash, "sha256:")
+	if len(digest) > 16 {
+		digest = digest[:16]
+	}
+	dir, name := filepath.Split(binaryPath)
+	return filepath.Join(dir, SidecarStoreDir, digest, name)
+}
+
+// IsSidecar reports whether path is a sidecar in any layout
+func IsSidecar(path string) bool {
+	if strings.HasSuffix(path, sidecarSuffix) || strings.Contains(path, projectSidecarMarker) {
+		return true
+	}
+	store := filepath.Dir(filepath.Dir(path))
+	return filepath.Base(store) == filepath.Base(SidecarStoreDir) &&
+		filepath.Base(filepath.Dir(store)) == filepath.Dir(SidecarStoreDir)
+}
+
+// BinaryForSidecar returns the wrapped binary whose original sidecarPath is
+func BinaryForSidecar(sidecarPath string) string {
+	if strings.HasSuffix(sidecarPath, sidecarSuffix) {
+		return strings.TrimSuffix(sidecarPath, sidecarSuffix)
+	}
+	if i := strings.LastIndex(sidecarPath, projectSidecarMarker); i >= 0 {
+		return filepath.Join(sidecarPath[:i], sidecarPath[i+len(projectSidecarMarker):])
+	}
+	binDir := filepath.Dir(filepath.Dir(filepath.Dir(filepath.Dir(sidecarPath))))
+	return filepath.Join(binDir, filepath.Base(sidecarPath))
+}
+
+// removeEmptySidecarDirs removes the store directories that held a
+// directory- or project-layout sidecar once they are empty. Directories that
+// still hold other originals are kept, as is a project's .ribbin directory.
+func removeEmptySidecarDirs(sidecarPath string) {
+	if strings.HasSuffix(sidecarPath, sidecarSuffix) {
+		return
+	}
+	dir := filepath.Dir(sidecarPath)
+	if i := strings.LastIndex(sidecarPath, projectSidecarMarker); i >= 0 {
+		root := sidecarPath[:i+len(projectSidecarMarker)-1]
+		for dir != root && strings.HasPrefix(dir, root) && os.Remove(dir) == nil {
+			dir = filepath.Dir(dir)
+		}
+		return
+	}
+	for i := 0; i < 3; i++ {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
 }
 
 // HasSidecar checks if a binary has a sidecar file (was shimmed)
 func HasSidecar(binaryPath string) bool {
-	sidecarPath := binaryPath + ".ribbin-original"
-	_, err := os.Stat(sidecarPath)
+	_, err := os.Stat(sidecarFor(binaryPath))
 	return err == nil
 }
 
+// shimMode returns how a binary newly wrapped should be replaced, from the
+// user's settings: config.ShimModeSymlink or config.ShimModeCopy
+func shimMode() (string, error) {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return "", err
+	}
+	if settings.ShimMode == "" {
+		return config.ShimModeSymlink, nil
+	}
+	return settings.ShimMode, nil
+}
+
+// sidecarLayout returns the layout for a binary newly wrapped for the config
+// at configPath: the config's sidecarLayout, else the user's settings. A
+// config that can't be loaded here has no say.
+func sidecarLayout(configPath string) (string, error) {
+	if configPath != "" {
+		if projectConfig, err := config.LoadProjectConfig(configPath); err == nil && projectConfig.SidecarLayout != "" {
+			return projectConfig.SidecarLayout, nil
+		}
+	}
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return "", err
+	}
+	if settings.SidecarLayout == "" {
+		return config.SidecarLayoutAdjacent, nil
+	}
+	return settings.SidecarLayout, nil
+}
+
+// ensureProjectSidecarDir creates the project layout's store for the config
+// at configPath, with a .gitignore so its originals are never committed
+func ensureProjectSidecarDir(configPath string) error {
+	ribbinDir := filepath.Join(filepath.Dir(configPath), filepath.Dir(ProjectSidecarDir))
+	if err := os.MkdirAll(filepath.Join(ribbinDir, filepath.Base(ProjectSidecarDir)), 0755); err != nil {
+		return err
+	}
+	gitignore := filepath.Join(ribbinDir, ".gitignore")
+	if _, err := os.Lstat(gitignore); os.IsNotExist(err) {
+		return os.WriteFile(gitignore, []byte("# Originals of binaries wrapped by ribbin\n*\n"), 0644)
+	}
+	return nil
+}
+
+// DiscardStaleProjectSidecar removes the original kept for binaryPath in the
+// project layout of the config at configPath when the binary has since been
+// reinstalled, so it can be wrapped again ('ribbin wrap --refresh'). Reports
+// whether there was one to discard.
+func DiscardStaleProjectSidecar(binaryPath, configPath string) (bool, error) {
+	stored := ProjectSidecarPath(configPath, binaryPath)
+	if stored == "" {
+		return false, nil
+	}
+	if _, err := os.Lstat(stored); err != nil {
+		return false, nil
+	}
+	if shimmed, err := IsAlreadyShimmed(binaryPath); err != nil || shimmed {
+		// Missing or still wrapped: the stored original is the only copy
+		return false, nil
+	}
+	security.LogPrivilegedOperation("discard_stale_sidecar", binaryPath, true, nil)
+	if err := os.Remove(stored); err != nil {
+		return false, fmt.Errorf("cannot remove stale original: %w", err)
+	}
+	removeEmptySidecarDirs(stored)
+	_ = removeMetadata(binaryPath)
+	return true, nil
+}
+
 // Install creates a shim for a binary:
 // 1. Acquire lock to prevent TOCTOU races
 // 2. Validate paths and check file state (including symlink validation)
-// 3. Rename original to {path}.ribbin-original
-// 4. Create symlink {path} -> ribbinPath
+// 3. Rename original to {path}.ribbin-original, or into the sidecar store
+// in the directory layout (see config.SidecarLayoutDirectory)
+// 4. Create symlink {path} -> ribbinPath, or copy ribbin to {path} in the
+// copy shim mode (see config.ShimModeCopy)
 // 5. Update registry
 func Install(binaryPath, ribbinPath string, registry *config.Registry, configPath string) error {
 	// Log privileged operations
@@ -244,9 +535,19 @@ func Install(binaryPath, ribbinPath string, registry *config.Registry, configPat
 		installErr = err
 		return installErr
 	}
+	layout, err := sidecarLayout(configPath)
+	if err != nil {
+		installErr = err
+		return installErr
+	}
+	mode, err := shimMode()
+	if err != nil {
+		installErr = err
+		return installErr
+	}
 
 	// 2b. ENSURE NO SYMLINKS IN SIDECAR PATH (prevent TOCTOU attacks)
-	if err := security.NoSymlinksInPath(filepath.Dir(sidecarPath)); err != nil {
+	if err := security.NoSymlinksInPath(filepath.Dir(binaryPath)); err != nil {
 		installErr = fmt.Errorf("unsafe parent directory (contains symlinks): %w", err)
 		return installErr
 	}
@@ -258,15 +559,79 @@ func Install(binaryPath, ribbinPath string, registry *config.Registry, configPat
 		return installErr
 	}
 
-	// 4. CHECK IF ALREADY SHIMMED (within lock)
+	// The project layout only covers binaries under the config's directory
+	if layout == config.SidecarLayoutProject && ProjectSidecarPath(configPath, binaryPath) == "" {
+		layout = config.SidecarLayoutAdjacent
+	}
+
+	// 4. CHECK IF ALREADY SHIMMED (within lock). A {path}.ribbin-original
+	// that ribbin didn't create, like the user's own backup, is only in the
+	// way of the adjacent layout.
 	if _, err := os.Lstat(sidecarPath); err == nil {
-		installErr = fmt.Errorf("binary %s is already shimmed (sidecar exists at %s)", binaryPath, sidecarPath)
-		return installErr
+		wrapped := (info != nil && info.Mode()&os.ModeSymlink != 0) || HasMetadata(binaryPath)
+		if wrapped {
+			installErr = fmt.Errorf("binary %s is already shimmed (sidecar exists at %s)", binaryPath, sidecarPath)
+			return installErr
+		}
+		if layout == config.SidecarLayoutAdjacent {
+			installErr = fmt.Errorf("%s exists but isn't a ribbin sidecar; move it aside, or set \"sidecarLayout\": %q in your settings to keep originals in %s",
+				sidecarPath, config.SidecarLayoutDirectory, SidecarStoreDir)
+			return installErr
+		}
 	} else if !os.IsNotExist(err) {
 		installErr = fmt.Errorf("failed to check sidecar path %s: %w", sidecarPath, err)
 		return installErr
 	}
 
+	// 4a. REFUSE TO WRAP RIBBIN ITSELF, which would run itself forever
+	if IsRibbin(binaryPath, ribbinPath) {
+		installErr = &SelfWrapError{Path: binaryPath}
+		return installErr
+	}
+
+	// 4b. PICK THE SIDECAR IN THE PROJECT LAYOUT, for binaries under the
+	// config's directory, refusing to replace an original kept from before a
+	// reinstall
+	if layout == config.SidecarLayoutProject {
+		sidecarPath = ProjectSidecarPath(configPath, binaryPath)
+		if _, err := os.Lstat(sidecarPath); err == nil {
+			installErr = fmt.Errorf("%s holds the original from an earlier wrap of %s; run 'ribbin wrap --refresh' to wrap the reinstalled binary", sidecarPath, binaryPath)
+			return installErr
+		}
+		if err := ensureProjectSidecarDir(configPath); err != nil {
+			installErr = fmt.Errorf("cannot create sidecar directory: %w", err)
+			return installErr
+		}
+		if err := os.MkdirAll(filepath.Dir(sidecarPath), 0755); err != nil {
+			installErr = fmt.Errorf("cannot create sidecar directory: %w", err)
+			return installErr
+		}
+		if err := security.NoSymlinksInPath(filepath.Dir(sidecarPath)); err != nil {
+			removeEmptySidecarDirs(sidecarPath)
+			installErr = fmt.Errorf("unsafe sidecar directory (contains symlinks): %w", err)
+			return installErr
+		}
+	}
+
+	// 4c. PICK A CONTENT-ADDRESSED SIDECAR IN THE DIRECTORY LAYOUT
+	if layout == config.SidecarLayoutDirectory {
+		hash, err := hashFile(binaryPath)
+		if err != nil {
+			installErr = fmt.Errorf("cannot hash binary: %w", err)
+			return installErr
+		}
+		sidecarPath = storeSidecarPath(binaryPath, hash)
+		if err := os.MkdirAll(filepath.Dir(sidecarPath), 0755); err != nil {
+			installErr = fmt.Errorf("cannot create sidecar directory: %w", err)
+			return installErr
+		}
+		if err := security.NoSymlinksInPath(filepath.Dir(sidecarPath)); err != nil {
+			removeEmptySidecarDirs(sidecarPath)
+			installErr = fmt.Errorf("unsafe sidecar directory (contains symlinks): %w", err)
+			return installErr
+		}
+	}
+
 	// 5. VERIFY BINARY UNCHANGED (prevent race)
 	if err := security.VerifyFileUnchanged(binaryPath, binaryInfo); err != nil {
 		installErr = fmt.Errorf("binary changed during operation: %w", err)
@@ -274,7 +639,8 @@ func Install(binaryPath, ribbinPath string, registry *config.Registry, configPat
 	}
 
 	// 6. ATOMIC RENAME (using O_EXCL)
-	if err := security.AtomicRename(binaryPath, sidecarPath); err != nil {
+	if err := moveToSidecar(binaryPath, sidecarPath); err != nil {
+		removeEmptySidecarDirs(sidecarPath)
 		if os.IsPermission(err) {
 			// Provide context-aware error message based on directory category
 			if security.IsCriticalSystemBinary(binaryPath) {
@@ -290,46 +656,55 @@ func Install(binaryPath, ribbinPath string, registry *config.Registry, configPat
 				return installErr
 			}
 
-			cmdName := filepath.Base(binaryPath)
-			installErr = fmt.Errorf("permission denied: %s\n\nIf you understand the security implications:\n  sudo ribbin shim %s --confirm-system-dir",
-				binaryPath, cmdName)
+			installErr = fmt.Errorf("permission denied: %s\n\nTo make the changes there through sudo:\n  ribbin wrap --sudo --confirm-system-dir",
+				binaryPath)
 			return installErr
 		}
 		installErr = fmt.Errorf("cannot rename binary to sidecar: %w", err)
 		return installErr
 	}
 
-	// 7. CREATE SYMLINK (rollback on failure)
-	if err := os.Symlink(ribbinPath, binaryPath); err != nil {
+	// 7. CREATE SYMLINK, OR COPY (rollback on failure)
+	shimKind := "symlink"
+	var shimErr error
+	if mode == config.ShimModeCopy {
+		shimKind = "copy of ribbin"
+		shimErr = copyShim(ribbinPath, binaryPath)
+	} else {
+		shimErr = os.Symlink(ribbinPath, binaryPath)
+	}
+	if err := shimErr; err != nil {
 		// ROLLBACK: restore original
 		rollbackErr := os.Rename(sidecarPath, binaryPath)
 		if rollbackErr != nil {
-			installErr = fmt.Errorf("cannot create symlink (and rollback failed: %v): %w", rollbackErr, err)
+			installErr = fmt.Errorf("cannot create %s (and rollback failed: %v): %w", shimKind, rollbackErr, err)
 			return installErr
 		}
+		removeEmptySidecarDirs(sidecarPath)
 		if os.IsPermission(err) {
-			installErr = fmt.Errorf("permission denied: cannot create symlink at %s (try with sudo)", binaryPath)
+			installErr = fmt.Errorf("permission denied: cannot create %s at %s (try with sudo)", shimKind, binaryPath)
 			return installErr
 		}
-		installErr = fmt.Errorf("failed to create symlink at %s: %w", binaryPath, err)
+		if mode == config.ShimModeCopy {
+			installErr = fmt.Errorf("failed to create %s at %s: %w", shimKind, binaryPath, err)
+			return installErr
+		}
+		installErr = fmt.Errorf("failed to create symlink at %s: %w\n\nIf this filesystem doesn't support symlinks, set \"shimMode\": %q in your settings", binaryPath, err, config.ShimModeCopy)
 		return installErr
 	}
 
-	// 7a. CREATE METADATA FILE (best effort - don't fail if this fails)
-	hash, hashErr := hashFile(sidecarPath)
-	if hashErr == nil {
-		sidecarInfo, statErr := os.Stat(sidecarPath)
-		if statErr == nil {
-			meta := &WrapperMetadata{
-				WrappedAt:     time.Now(),
-				OriginalHash:  hash,
-				OriginalSize:  sidecarInfo.Size(),
-				RibbinPath:    ribbinPath,
-				RibbinVersion: Version,
-			}
-			// Best effort - don't fail installation if metadata write fails
-			_ = saveMetadata(binaryPath, meta)
+	// 7a. CREATE METADATA FILE (best effort - don't fail if this fails),
+	// except that the directory layout can't find its sidecar without it,
+	// and a copy isn't recognized as a wrapper without it
+	if err := recordMetadata(binaryPath, sidecarPath, ribbinPath); err != nil && (layout != config.SidecarLayoutAdjacent || mode == config.ShimModeCopy) {
+		os.Remove(binaryPath)
+		if rollbackErr := os.Rename(sidecarPath, binaryPath); rollbackErr != nil {
+			installErr = fmt.Errorf("cannot record metadata (and rollback failed: %v): %w", rollbackErr, err)
+			return installErr
 		}
+		removeEmptySidecarDirs(sidecarPath)
+		installErr = fmt.Errorf("cannot record metadata: %w", err)
+		return installErr
 	}
 
 	// 7b. CREATE SECOND SIDECAR AT FINAL TARGET (if binary was a symlink)
@@ -348,19 +723,38 @@ func Install(binaryPath, ribbinPath string, registry *config.Registry, configPat
 	}
 
 	// 8. UPDATE REGISTRY (within lock)
-	commandName := filepath.Base(binaryPath)
-	registry.Wrappers[commandName] = config.WrapperEntry{
+	registry.AddWrapper(config.WrapperEntry{
 		Original: binaryPath,
 		Config:   configPath,
-	}
+	})
 
 	// Lock automatically released by defer
 	return nil
 }
 
+// moveToSidecar moves the binary at binaryPath to sidecarPath. A relative
+// symlink, like npm's node_modules/.bin entries, would break if moved to
+// another directory, so there it is re-created pointing at its absolute
+// target instead.
+func moveToSidecar(binaryPath, sidecarPath string) error {
+	if filepath.Dir(sidecarPath) != filepath.Dir(binaryPath) {
+		if target, err := os.Readlink(binaryPath); err == nil && !filepath.IsAbs(target) {
+			if err := os.Symlink(filepath.Join(filepath.Dir(binaryPath), target), sidecarPath); err != nil {
+				return err
+			}
+			if err := os.Remove(binaryPath); err != nil {
+				os.Remove(sidecarPath)
+				return err
+			}
+			return nil
+		}
+	}
+	return security.AtomicRename(binaryPath, sidecarPath)
+}
+
 // Uninstall removes a shim:
 // 1. Acquire lock to prevent concurrent operations
-// 2. Remove symlink at {path}
+// 2. Remove the symlink, or the copy of ribbin, at {path}
 // 3. Rename {path}.ribbin-original back to {path}
 // 4. Remove from registry
 func Uninstall(binaryPath string, registry *config.Registry) error {
@@ -389,20 +783,26 @@ func Uninstall(binaryPath string, registry *config.Registry) error {
 		return uninstallErr
 	}
 
+	// A wrapper shadowing a binary only has itself to remove
+	if ShadowedBinary(binaryPath) != "" {
+		uninstallErr = uninstallShadow(binaryPath, registry)
+		return uninstallErr
+	}
+
 	sidecarPath, err := SidecarPath(binaryPath)
 	if err != nil {
 		uninstallErr = err
 		return uninstallErr
 	}
 
-	// Verify it's a shim (check symlink)
+	// Verify it's a shim (check symlink, or the copy its metadata records)
 	info, err := os.Lstat(binaryPath)
 	if err != nil {
 		uninstallErr = fmt.Errorf("cannot stat binary: %w", err)
 		return uninstallErr
 	}
-	if info.Mode()&os.ModeSymlink == 0 {
-		uninstallErr = fmt.Errorf("%s is not a shim (not a symlink)", binaryPath)
+	if info.Mode()&os.ModeSymlink == 0 && !IsCopyShim(binaryPath) {
+		uninstallErr = fmt.Errorf("%s is not a shim (not a symlink or a copy of ribbin)", binaryPath)
 		return uninstallErr
 	}
 
@@ -411,14 +811,15 @@ func Uninstall(binaryPath string, registry *config.Registry) error {
 		uninstallErr = fmt.Errorf("sidecar not found: %s", sidecarPath)
 		return uninstallErr
 	}
+	warnDivergedLinks(binaryPath, sidecarPath)
 
-	// Remove symlink
+	// Remove the wrapper
 	if err := os.Remove(binaryPath); err != nil {
 		if os.IsPermission(err) {
-			uninstallErr = fmt.Errorf("permission denied: cannot remove symlink at %s (try with sudo)", binaryPath)
+			uninstallErr = fmt.Errorf("permission denied: cannot remove wrapper at %s (try 'ribbin unwrap --sudo')", binaryPath)
 			return uninstallErr
 		}
-		uninstallErr = fmt.Errorf("cannot remove symlink: %w", err)
+		uninstallErr = fmt.Errorf("cannot remove wrapper: %w", err)
 		return uninstallErr
 	}
 
@@ -431,21 +832,45 @@ func Uninstall(binaryPath string, registry *config.Registry) error {
 		uninstallErr = fmt.Errorf("cannot restore original binary: %w", err)
 		return uninstallErr
 	}
+	removeEmptySidecarDirs(sidecarPath)
+	meta, _ := LoadMetadata(binaryPath)
+	restoreOriginalAttributes(binaryPath, meta)
 
 	// Clean up metadata file (best effort)
 	_ = removeMetadata(binaryPath)
 
 	// Update registry
-	commandName := filepath.Base(binaryPath)
-	delete(registry.Wrappers, commandName)
+	registry.RemoveWrapper(binaryPath)
 
 	return nil
 }
 
+// warnDivergedLinks warns when the original about to be restored had other
+// hard links when it was wrapped and some have since gone, most likely
+// replaced by a package manager, so those copies may now differ from it
+func warnDivergedLinks(binaryPath, sidecarPath string) {
+	meta, err := LoadMetadata(binaryPath)
+	if err != nil || meta.OriginalLinks <= 1 {
+		return
+	}
+	info, err := os.Lstat(sidecarPath)
+	if err != nil {
+		return
+	}
+	if links := linkCount(info); links < meta.OriginalLinks {
+		fmt.Fprintf(os.Stderr, "Warning: %s had %d hard links when it was wrapped and now has %d; other copies may have been replaced and differ from the original being restored\n",
+			binaryPath, meta.OriginalLinks, links)
+	}
+}
+
 // CleanupSidecarFiles removes sidecar and metadata files without restoring the original.
 // Used when the user chooses to keep the current binary during conflict resolution.
 func CleanupSidecarFiles(binaryPath string, registry *config.Registry) error {
-	sidecarPath := binaryPath + ".ribbin-original"
+	// Without its sidecar, a wrapper shadowing a binary has no use
+	if ShadowedBinary(binaryPath) != "" {
+		return uninstallShadow(binaryPath, registry)
+	}
+	sidecarPath := sidecarFor(binaryPath)
 
 	// Log cleanup operation for audit trail
 	security.LogPrivilegedOperation("cleanup_sidecar", binaryPath, true, nil)
@@ -455,18 +880,18 @@ func CleanupSidecarFiles(binaryPath string, registry *config.Registry) error {
 		security.LogPrivilegedOperation("cleanup_sidecar", binaryPath, false, err)
 		return fmt.Errorf("cannot remove sidecar: %w", err)
 	}
+	removeEmptySidecarDirs(sidecarPath)
 
 	// Remove metadata file
 	_ = removeMetadata(binaryPath)
 
 	// Update registry
-	commandName := filepath.Base(binaryPath)
-	delete(registry.Wrappers, commandName)
+	registry.RemoveWrapper(binaryPath)
 
 	return nil
 }
 
-// FindSidecars searches directories for .ribbin-original files
+// FindSidecars searches directories for sidecars in either layout
 func FindSidecars(searchPaths []string) ([]string, error) {
 	var sidecars []string
 	var errs []error
@@ -492,14 +917,17 @@ func FindSidecars(searchPaths []string) ([]string, error) {
 			continue
 		}
 
-		pattern := filepath.Join(searchPath, "*.ribbin-original")
-		matches, err := filepath.Glob(pattern)
-		if err != nil {
-			errs = append(errs, fmt.Errorf("failed to glob pattern %s: %w", pattern, err))
-			continue
+		for _, pattern := range []string{
+			filepath.Join(searchPath, "*"+sidecarSuffix),
+			filepath.Join(searchPath, SidecarStoreDir, "*", "*"),
+		} {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to glob pattern %s: %w", pattern, err))
+				continue
+			}
+			sidecars = append(sidecars, matches...)
 		}
-
-		sidecars = append(sidecars, matches...)
 	}
 
 	if len(errs) > 0 && len(sidecars) == 0 {
@@ -1,9 +1,12 @@
 package wrap
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/happycollision/ribbin/internal/testsafety"
 
@@ -117,7 +120,7 @@ func TestInstall(t *testing.T) {
 		}
 
 		// Check registry updated
-		entry, exists := registry.Wrappers["test-binary"]
+		entry, exists := registry.LookupWrapper("test-binary", "")
 		if !exists {
 			t.Error("registry should have entry for test-binary")
 		}
@@ -238,7 +241,7 @@ func TestUninstall(t *testing.T) {
 
 		registry := &config.Registry{
 			Wrappers: map[string]config.WrapperEntry{
-				"uninstall-test": {Original: binaryPath, Config: "/project/ribbin.jsonc"},
+				binaryPath: {Original: binaryPath, Config: "/project/ribbin.jsonc"},
 			},
 			ShellActivations:  make(map[int]config.ShellActivationEntry),
 			ConfigActivations: make(map[string]config.ConfigActivationEntry),
@@ -269,7 +272,7 @@ func TestUninstall(t *testing.T) {
 		}
 
 		// Registry should be updated
-		if _, exists := registry.Wrappers["uninstall-test"]; exists {
+		if _, exists := registry.LookupWrapper("uninstall-test", ""); exists {
 			t.Error("registry entry should be removed after uninstall")
 		}
 	})
@@ -631,7 +634,7 @@ func TestUninstallRemovesMetadata(t *testing.T) {
 
 	registry := &config.Registry{
 		Wrappers: map[string]config.WrapperEntry{
-			"uninstall-test": {Original: binaryPath, Config: "/project/ribbin.jsonc"},
+			binaryPath: {Original: binaryPath, Config: "/project/ribbin.jsonc"},
 		},
 		ShellActivations:  make(map[int]config.ShellActivationEntry),
 		ConfigActivations: make(map[string]config.ConfigActivationEntry),
@@ -648,6 +651,81 @@ func TestUninstallRemovesMetadata(t *testing.T) {
 	}
 }
 
+func TestUninstallRestoresAttributes(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "ribbin-attrs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	binaryPath := filepath.Join(tmpDir, "test-binary")
+	if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho original"), 0755); err != nil {
+		t.Fatalf("failed to create binary: %v", err)
+	}
+	if err := os.Chmod(binaryPath, 0750|os.ModeSetgid); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(binaryPath, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	// Not every filesystem takes user extended attributes
+	hasXattr := setXattr(binaryPath, "user.ribbin.test", []byte("kept")) == nil
+
+	ribbinPath := filepath.Join(tmpDir, "ribbin")
+	if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+		t.Fatalf("failed to create ribbin: %v", err)
+	}
+	registry := &config.Registry{
+		Wrappers:          make(map[string]config.WrapperEntry),
+		ShellActivations:  make(map[int]config.ShellActivationEntry),
+		ConfigActivations: make(map[string]config.ConfigActivationEntry),
+	}
+	if err := Install(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc"); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	meta, err := LoadMetadata(binaryPath)
+	if err != nil {
+		t.Fatalf("LoadMetadata error: %v", err)
+	}
+	if meta.OriginalAttributes == nil || meta.OriginalAttributes.Mode != "2750" {
+		t.Fatalf("OriginalAttributes = %+v, want mode 2750", meta.OriginalAttributes)
+	}
+
+	// Change the original while it is wrapped
+	sidecarPath := binaryPath + ".ribbin-original"
+	if err := os.Chmod(sidecarPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(sidecarPath, time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if hasXattr {
+		if err := setXattr(sidecarPath, "user.ribbin.test", []byte("changed")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Uninstall(binaryPath, registry); err != nil {
+		t.Fatalf("Uninstall error: %v", err)
+	}
+	info, err := os.Stat(binaryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != 0750|os.ModeSetgid {
+		t.Errorf("mode = %v, want %v", info.Mode(), 0750|os.ModeSetgid)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v, want %v", info.ModTime(), mtime)
+	}
+	if hasXattr {
+		if value, err := getXattr(binaryPath, "user.ribbin.test"); err != nil || string(value) != "kept" {
+			t.Errorf("xattr = %q, %v, want \"kept\"", value, err)
+		}
+	}
+}
+
 func TestCleanupSidecarFiles(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -665,7 +743,7 @@ func TestCleanupSidecarFiles(t *testing.T) {
 
 	registry := &config.Registry{
 		Wrappers: map[string]config.WrapperEntry{
-			"cleanup-test": {Original: binaryPath},
+			binaryPath: {Original: binaryPath},
 		},
 		ShellActivations:  make(map[int]config.ShellActivationEntry),
 		ConfigActivations: make(map[string]config.ConfigActivationEntry),
@@ -687,7 +765,274 @@ func TestCleanupSidecarFiles(t *testing.T) {
 	}
 
 	// Verify registry updated
-	if _, exists := registry.Wrappers["cleanup-test"]; exists {
+	if _, exists := registry.LookupWrapper("cleanup-test", ""); exists {
 		t.Error("registry entry should be removed")
 	}
 }
+
+func TestVerifySidecar(t *testing.T) {
+	// setup wraps a binary and returns its path
+	setup := func(t *testing.T) string {
+		t.Helper()
+		dir := t.TempDir()
+		ribbinPath := filepath.Join(dir, "ribbin")
+		if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		binaryPath := filepath.Join(dir, "tool")
+		if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho v1"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+		if err := Install(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc"); err != nil {
+			t.Fatalf("Install error: %v", err)
+		}
+		return binaryPath
+	}
+
+	t.Run("unmodified original passes every policy", func(t *testing.T) {
+		binaryPath := setup(t)
+		for _, policy := range []string{"", config.VerifyNone, config.VerifySize, config.VerifyHash} {
+			if err := VerifySidecar(binaryPath, policy); err != nil {
+				t.Errorf("VerifySidecar(%q) error = %v", policy, err)
+			}
+		}
+	})
+
+	t.Run("same-size change is caught by hash only", func(t *testing.T) {
+		binaryPath := setup(t)
+		if err := os.WriteFile(binaryPath+".ribbin-original", []byte("#!/bin/sh\necho v2"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := VerifySidecar(binaryPath, config.VerifySize); err != nil {
+			t.Errorf("size policy error = %v, want nil", err)
+		}
+		if err := VerifySidecar(binaryPath, config.VerifyHash); !errors.Is(err, ErrSidecarModified) {
+			t.Errorf("hash policy error = %v, want ErrSidecarModified", err)
+		}
+		if err := VerifySidecar(binaryPath, config.VerifyNone); err != nil {
+			t.Errorf("none policy error = %v, want nil", err)
+		}
+	})
+
+	t.Run("size change is caught by size", func(t *testing.T) {
+		binaryPath := setup(t)
+		if err := os.WriteFile(binaryPath+".ribbin-original", []byte("#!/bin/sh\necho tampered"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifySidecar(binaryPath, config.VerifySize); !errors.Is(err, ErrSidecarModified) {
+			t.Errorf("size policy error = %v, want ErrSidecarModified", err)
+		}
+	})
+
+	t.Run("missing metadata fails closed", func(t *testing.T) {
+		binaryPath := setup(t)
+		if err := os.Remove(MetadataPath(binaryPath)); err != nil {
+			t.Fatal(err)
+		}
+		err := VerifySidecar(binaryPath, config.VerifyHash)
+		if err == nil || errors.Is(err, ErrSidecarModified) {
+			t.Errorf("error = %v, want a non-tamper error", err)
+		}
+	})
+
+	t.Run("unknown policy fails closed", func(t *testing.T) {
+		binaryPath := setup(t)
+		if err := VerifySidecar(binaryPath, "sha1"); err == nil {
+			t.Error("expected error for unknown policy")
+		}
+	})
+}
+
+func TestInstallDirectoryLayout(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	setLayout := func(layout string) {
+		t.Helper()
+		settingsDir := filepath.Join(configHome, "ribbin")
+		if err := os.MkdirAll(settingsDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(settingsDir, config.SettingsFileName), []byte(`{"sidecarLayout": "`+layout+`"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tmpDir := t.TempDir()
+	ribbinPath := filepath.Join(tmpDir, "ribbin")
+	binaryPath := filepath.Join(tmpDir, "terraform")
+	content := []byte("#!/bin/sh\necho terraform")
+	for _, path := range []string{ribbinPath, binaryPath} {
+		if err := os.WriteFile(path, content, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The user's own backup, in the way of the adjacent layout
+	backup := binaryPath + ".ribbin-original"
+	if err := os.WriteFile(backup, []byte("my backup"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+
+	setLayout(config.SidecarLayoutAdjacent)
+	err := Install(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc")
+	if err == nil || !strings.Contains(err.Error(), "isn't a ribbin sidecar") {
+		t.Fatalf("expected a collision error, got %v", err)
+	}
+
+	setLayout(config.SidecarLayoutDirectory)
+	if err := Install(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc"); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+
+	if data, _ := os.ReadFile(backup); string(data) != "my backup" {
+		t.Errorf("backup was changed: %q", data)
+	}
+	sidecarPath, err := SidecarPath(binaryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(filepath.Dir(sidecarPath)) != filepath.Join(tmpDir, SidecarStoreDir) || filepath.Base(sidecarPath) != "terraform" {
+		t.Errorf("sidecar at %s, want in the sidecar store", sidecarPath)
+	}
+	if data, _ := os.ReadFile(sidecarPath); string(data) != string(content) {
+		t.Errorf("sidecar holds %q", data)
+	}
+	if !IsSidecar(sidecarPath) || BinaryForSidecar(sidecarPath) != binaryPath {
+		t.Errorf("BinaryForSidecar(%s) = %s", sidecarPath, BinaryForSidecar(sidecarPath))
+	}
+	if err := VerifySidecar(binaryPath, config.VerifyHash); err != nil {
+		t.Errorf("VerifySidecar error: %v", err)
+	}
+	if found, _ := FindSidecars([]string{tmpDir}); len(found) != 2 {
+		t.Errorf("FindSidecars = %v, want the backup and the stored sidecar", found)
+	}
+
+	if err := Install(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc"); err == nil || !strings.Contains(err.Error(), "already shimmed") {
+		t.Errorf("expected already shimmed, got %v", err)
+	}
+
+	if err := Uninstall(binaryPath, registry); err != nil {
+		t.Fatalf("Uninstall error: %v", err)
+	}
+	if data, _ := os.ReadFile(binaryPath); string(data) != string(content) {
+		t.Errorf("original not restored: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".ribbin")); !os.IsNotExist(err) {
+		t.Error("empty sidecar store should be removed")
+	}
+	if data, _ := os.ReadFile(backup); string(data) != "my backup" {
+		t.Errorf("backup was changed: %q", data)
+	}
+}
+
+func TestProjectSidecarPath(t *testing.T) {
+	configPath := "/repo/ribbin.jsonc"
+
+	stored := ProjectSidecarPath(configPath, "/repo/node_modules/.bin/tsc")
+	if stored != "/repo/.ribbin/originals/node_modules/.bin/tsc" {
+		t.Errorf("ProjectSidecarPath = %s", stored)
+	}
+	if !IsSidecar(stored) || BinaryForSidecar(stored) != "/repo/node_modules/.bin/tsc" {
+		t.Errorf("BinaryForSidecar(%s) = %s", stored, BinaryForSidecar(stored))
+	}
+	if got := ProjectSidecarPath(configPath, "/usr/local/bin/tsc"); got != "" {
+		t.Errorf("binaries outside the project have no project sidecar, got %s", got)
+	}
+	if IsSidecar("/repo/.ribbin/.gitignore") {
+		t.Error(".gitignore isn't a sidecar")
+	}
+}
+
+func TestInstallCopyShimMode(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	settingsDir := filepath.Join(configHome, "ribbin")
+	if err := os.MkdirAll(settingsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(settingsDir, config.SettingsFileName), []byte(`{"shimMode": "copy"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpDir := t.TempDir()
+	ribbinPath := filepath.Join(tmpDir, "ribbin")
+	binaryPath := filepath.Join(tmpDir, "terraform")
+	if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin v1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho terraform"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+
+	if err := Install(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc"); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	info, err := os.Lstat(binaryPath)
+	if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+		t.Fatalf("wrapper should be an executable file, got %v, %v", info, err)
+	}
+	if data, _ := os.ReadFile(binaryPath); string(data) != "#!/bin/sh\necho ribbin v1" {
+		t.Errorf("wrapper should be a copy of ribbin, got %q", data)
+	}
+	meta, err := LoadMetadata(binaryPath)
+	if err != nil || meta.ShimMode != config.ShimModeCopy {
+		t.Fatalf("metadata should record the copy, got %+v, %v", meta, err)
+	}
+	if shimmed, _ := IsAlreadyShimmed(binaryPath); !shimmed {
+		t.Error("a copy should count as shimmed")
+	}
+	if check := CheckHeal(binaryPath, ribbinPath); check.Status != HealNotNeeded {
+		t.Errorf("CheckHeal = %v, want ok", check.Status)
+	}
+	if err := Install(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc"); err == nil || !strings.Contains(err.Error(), "already shimmed") {
+		t.Errorf("expected already shimmed, got %v", err)
+	}
+
+	// Upgrading ribbin leaves the copy behind until it is relinked
+	if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin v2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	check, relinked, err := Relink(binaryPath, ribbinPath)
+	if err != nil || !relinked || !check.Copy || check.Status != RelinkOtherRibbin {
+		t.Fatalf("expected the copy to be refreshed, got %+v, %v, %v", check, relinked, err)
+	}
+	if data, _ := os.ReadFile(binaryPath); string(data) != "#!/bin/sh\necho ribbin v2" {
+		t.Errorf("wrapper should be a copy of the new ribbin, got %q", data)
+	}
+	if check := CheckRelink(binaryPath, ribbinPath); check.Status != RelinkNotNeeded {
+		t.Errorf("CheckRelink after relink = %v", check.Status)
+	}
+
+	if err := Uninstall(binaryPath, registry); err != nil {
+		t.Fatalf("Uninstall error: %v", err)
+	}
+	if data, _ := os.ReadFile(binaryPath); string(data) != "#!/bin/sh\necho terraform" {
+		t.Errorf("original not restored: %q", data)
+	}
+	if HasSidecar(binaryPath) || HasMetadata(binaryPath) {
+		t.Error("sidecar and metadata should be removed")
+	}
+
+	// A binary reinstalled over the copy isn't a wrapper, even with metadata
+	if err := Install(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc"); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	if err := os.WriteFile(binaryPath+".new", []byte("#!/bin/sh\necho terraform 2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(binaryPath+".new", binaryPath); err != nil {
+		t.Fatal(err)
+	}
+	if IsCopyShim(binaryPath) {
+		t.Error("a reinstalled binary isn't a copy shim")
+	}
+	if check := CheckHeal(binaryPath, ribbinPath); check.Status != HealShimReplaced {
+		t.Errorf("CheckHeal = %v, want shim replaced", check.Status)
+	}
+	if err := Uninstall(binaryPath, registry); err == nil || !strings.Contains(err.Error(), "not a shim") {
+		t.Errorf("expected not a shim, got %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// Package managers don't always run binaries the way a shell would. `pnpm
+// exec tsc` and npm scripts may run a wrapper by bare name without its
+// directory in PATH, or through a symlink chain that ends somewhere other
+// than where the wrapper was installed. The shim then has to work out which
+// wrapper it is from what the package manager leaves behind: npm and pnpm
+// export npm_config_local_prefix (the project running the script) and
+// npm_execpath (the package manager's own entry point), and the project's
+// binaries live in node_modules/.bin.
+
+// Sidecar lookup strategies, in the order locateSidecar tries them
+const (
+	viaInvokedPath = "invoked path"
+	viaWorkingDir  = "relative to the working directory"
+	viaExecutable  = "next to the ribbin executable"
+	viaLocalPrefix = "npm_config_local_prefix"
+	viaNodeModules = "nearest node_modules/.bin"
+	viaExecPath    = "npm_execpath"
+	viaRegistry    = "registry"
+)
+
+// SidecarAttempt is one place a shim looked for its original binary
+type SidecarAttempt struct {
+	// Via is the strategy that suggested the place
+	Via string
+	// Path is the sidecar looked for. Empty when the strategy had nowhere
+	// to look, in which case Note says why.
+	Path string
+	Note string
+}
+
+// SidecarNotFoundError is returned by Run when no strategy finds the
+// original binary. It lists everything that was tried.
+type SidecarNotFoundError struct {
+	Command string
+	Tried   []SidecarAttempt
+}
+
+func (e *SidecarNotFoundError) Error() string {
+	var b strings.Builder
+	b.WriteString("original binary not found (no .ribbin-original sidecar found)\n")
+	fmt.Fprintf(&b, "Looked for %s's original:\n", e.Command)
+	for _, attempt := range e.Tried {
+		if attempt.Path == "" {
+			fmt.Fprintf(&b, "  %s: %s\n", attempt.Via, attempt.Note)
+		} else {
+			fmt.Fprintf(&b, "  %s (%s)\n", attempt.Path, attempt.Via)
+		}
+	}
+	fmt.Fprintf(&b, "If %s was uninstalled or its sidecar deleted, reinstall it and run 'ribbin heal %s'.", e.Command, e.Command)
+	return b.String()
+}
+
+// RenamedRibbinError is returned by Run when the file run is ribbin itself
+// under another name, like Homebrew's ribbin@1.2, rather than a wrapper.
+// ribbin only acts as the CLI when run as "ribbin".
+type RenamedRibbinError struct {
+	Name string
+}
+
+func (e *RenamedRibbinError) Error() string {
+	return fmt.Sprintf("not a wrapper, but ribbin itself, which only runs its commands when it is named 'ribbin'.\n"+
+		"Run it through a link named ribbin (e.g. ln -s \"$(command -v %s)\" ~/.local/bin/ribbin), "+
+		"or set RIBBIN_FORCE_CLI=1 to use it as %s.", e.Name, e.Name)
+}
+
+// isRenamedRibbin reports whether argv0 is the running ribbin binary, by a
+// link or a copy, and nothing suggests it was ever a wrapper: no metadata
+// next to it and no registry entry. A wrapper that lost its sidecar still
+// gets the SidecarNotFoundError.
+func isRenamedRibbin(argv0 string) bool {
+	exePath, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	exeInfo, err := os.Stat(exePath)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(argv0)
+	if err != nil || !os.SameFile(info, exeInfo) || HasMetadata(argv0) {
+		return false
+	}
+	if registry, err := config.LoadRegistryReadOnly(); err == nil {
+		if _, known := registry.Wrapper(argv0); known {
+			return false
+		}
+	}
+	return true
+}
+
+// ResolveInvokedPath turns the argv0 a shim was run with into a path. A bare
+// name is looked up in PATH; when that fails, as under `pnpm exec`, it is
+// taken relative to the working directory and locateSidecar works out the
+// rest.
+func ResolveInvokedPath(argv0 string) string {
+	if filepath.IsAbs(argv0) {
+		return argv0
+	}
+	if resolved, err := exec.LookPath(argv0); err == nil {
+		return resolved
+	}
+	if absPath, err := filepath.Abs(argv0); err == nil {
+		return absPath
+	}
+	return argv0
+}
+
+// locateSidecar finds the sidecar holding the original of the wrapper run as
+// argv0. It tries, in order:
+//  1. Next to argv0 (or the sidecar its metadata points at in the directory
+//     layout)
+//  2. argv0 resolved against the working directory, if it is relative
+//  3. Next to the ribbin executable (for symlink chains)
+//  4. The node_modules/.bin of npm_config_local_prefix
+//  5. The nearest node_modules/.bin above the working directory
+//  6. The bin directories of the package manager in npm_execpath
+//  7. Where the registry says a wrapper of that name was installed
+//
+// It returns the sidecar, or "" and every place it looked.
+func locateSidecar(argv0 string) (string, []SidecarAttempt) {
+	cmdName := filepath.Base(argv0)
+	var tried []SidecarAttempt
+	seen := make(map[string]bool)
+	try := func(via, sidecarPath string) bool {
+		if seen[sidecarPath] {
+			return false
+		}
+		seen[sidecarPath] = true
+		tried = append(tried, SidecarAttempt{Via: via, Path: sidecarPath})
+		_, err := os.Stat(sidecarPath)
+		return err == nil
+	}
+	note := func(via, format string, args ...interface{}) {
+		tried = append(tried, SidecarAttempt{Via: via, Note: fmt.Sprintf(format, args...)})
+	}
+
+	if sidecarPath := sidecarFor(argv0); try(viaInvokedPath, sidecarPath) {
+		return sidecarPath, nil
+	}
+
+	if !filepath.IsAbs(argv0) {
+		if absPath, err := filepath.Abs(argv0); err == nil {
+			if sidecarPath := sidecarFor(absPath); try(viaWorkingDir, sidecarPath) {
+				return sidecarPath, nil
+			}
+		}
+	}
+
+	if exePath, err := os.Executable(); err == nil {
+		sidecarPath := filepath.Join(filepath.Dir(exePath), cmdName+sidecarSuffix)
+		if try(viaExecutable, sidecarPath) {
+			return sidecarPath, nil
+		}
+	}
+
+	if prefix := os.Getenv("npm_config_local_prefix"); prefix != "" {
+		binary := filepath.Join(prefix, "node_modules", ".bin", cmdName)
+		if sidecarPath := sidecarFor(binary); try(viaLocalPrefix, sidecarPath) {
+			return sidecarPath, nil
+		}
+	} else {
+		note(viaLocalPrefix, "not set")
+	}
+
+	if binDir := nearestNodeModulesBin(); binDir != "" {
+		binary := filepath.Join(binDir, cmdName)
+		if sidecarPath := sidecarFor(binary); try(viaNodeModules, sidecarPath) {
+			return sidecarPath, nil
+		}
+	} else {
+		note(viaNodeModules, "none above the working directory")
+	}
+
+	if execPath := os.Getenv("npm_execpath"); execPath != "" {
+		dirs := packageManagerBinDirs(execPath)
+		if len(dirs) == 0 {
+			note(viaExecPath, "%s is not inside a node_modules directory", execPath)
+		}
+		for _, dir := range dirs {
+			if sidecarPath := sidecarFor(filepath.Join(dir, cmdName)); try(viaExecPath, sidecarPath) {
+				return sidecarPath, nil
+			}
+		}
+	} else {
+		note(viaExecPath, "not set")
+	}
+
+	registry, err := config.LoadRegistryReadOnly()
+	if err != nil {
+		note(viaRegistry, "cannot load: %v", err)
+		return "", tried
+	}
+	entries := registry.WrappersNamed(cmdName)
+	if len(entries) == 0 {
+		note(viaRegistry, "no wrapper named %s", cmdName)
+	}
+	for _, entry := range entries {
+		if sidecarPath := sidecarFor(entry.Original); try(viaRegistry, sidecarPath) {
+			return sidecarPath, nil
+		}
+	}
+
+	return "", tried
+}
+
+// nearestNodeModulesBin returns the node_modules/.bin closest above the
+// working directory, or "" if there is none
+func nearestNodeModulesBin() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		binDir := filepath.Join(dir, "node_modules", ".bin")
+		if info, err := os.Stat(binDir); err == nil && info.IsDir() {
+			return binDir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// packageManagerBinDirs returns the directories a package manager whose
+// entry point is execPath (e.g. /usr/local/lib/node_modules/pnpm/bin/pnpm.cjs)
+// installs binaries into: the .bin of the node_modules it lives in, and for
+// a global install under <prefix>/lib/node_modules, <prefix>/bin.
+func packageManagerBinDirs(execPath string) []string {
+	for dir := filepath.Dir(execPath); ; {
+		if filepath.Base(dir) == "node_modules" {
+			dirs := []string{filepath.Join(dir, ".bin")}
+			if lib := filepath.Dir(dir); filepath.Base(lib) == "lib" {
+				dirs = append(dirs, filepath.Join(filepath.Dir(lib), "bin"))
+			}
+			return dirs
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
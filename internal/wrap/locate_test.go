@@ -0,0 +1,150 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestLocateSidecar(t *testing.T) {
+	// setup isolates the registry and the package manager environment, and
+	// returns a project with a wrapped node_modules/.bin/tsc
+	setup := func(t *testing.T) (projectDir, sidecarPath string) {
+		t.Helper()
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		t.Setenv("npm_config_local_prefix", "")
+		t.Setenv("npm_execpath", "")
+		projectDir, err := filepath.EvalSymlinks(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		binDir := filepath.Join(projectDir, "node_modules", ".bin")
+		os.MkdirAll(binDir, 0755)
+		sidecarPath = filepath.Join(binDir, "tsc"+sidecarSuffix)
+		os.WriteFile(sidecarPath, []byte("#!/bin/sh\necho tsc"), 0755)
+		return projectDir, sidecarPath
+	}
+	// elsewhere is where pnpm exec might leave argv0 pointing
+	elsewhere := func(t *testing.T) string {
+		return filepath.Join(t.TempDir(), "tsc")
+	}
+
+	t.Run("npm_config_local_prefix", func(t *testing.T) {
+		projectDir, sidecarPath := setup(t)
+		t.Setenv("npm_config_local_prefix", projectDir)
+		got, _ := locateSidecar(elsewhere(t))
+		if got != sidecarPath {
+			t.Errorf("locateSidecar = %q, want %q", got, sidecarPath)
+		}
+	})
+
+	t.Run("nearest node_modules/.bin", func(t *testing.T) {
+		projectDir, sidecarPath := setup(t)
+		subDir := filepath.Join(projectDir, "packages", "app")
+		os.MkdirAll(subDir, 0755)
+		origDir, _ := os.Getwd()
+		defer os.Chdir(origDir)
+		os.Chdir(subDir)
+
+		got, _ := locateSidecar(elsewhere(t))
+		if got != sidecarPath {
+			t.Errorf("locateSidecar = %q, want %q", got, sidecarPath)
+		}
+	})
+
+	t.Run("npm_execpath", func(t *testing.T) {
+		prefix, err := filepath.EvalSymlinks(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		setup(t)
+		t.Setenv("npm_execpath", filepath.Join(prefix, "lib", "node_modules", "pnpm", "bin", "pnpm.cjs"))
+		globalBin := filepath.Join(prefix, "bin")
+		os.MkdirAll(globalBin, 0755)
+		sidecarPath := filepath.Join(globalBin, "tsc"+sidecarSuffix)
+		os.WriteFile(sidecarPath, []byte("#!/bin/sh\necho tsc"), 0755)
+
+		got, _ := locateSidecar(elsewhere(t))
+		if got != sidecarPath {
+			t.Errorf("locateSidecar = %q, want %q", got, sidecarPath)
+		}
+	})
+
+	t.Run("not found lists what was tried", func(t *testing.T) {
+		setup(t)
+		argv0 := elsewhere(t)
+		got, tried := locateSidecar(argv0)
+		if got != "" {
+			t.Fatalf("locateSidecar = %q, want nothing", got)
+		}
+
+		msg := (&SidecarNotFoundError{Command: "tsc", Tried: tried}).Error()
+		for _, want := range []string{
+			"original binary not found",
+			argv0 + sidecarSuffix + " (invoked path)",
+			"npm_config_local_prefix: not set",
+			"npm_execpath: not set",
+			"registry: no wrapper named tsc",
+			"ribbin heal tsc",
+		} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("error should mention %q, got:\n%s", want, msg)
+			}
+		}
+	})
+}
+
+func TestPackageManagerBinDirs(t *testing.T) {
+	tests := []struct {
+		execPath string
+		want     []string
+	}{
+		{"/usr/local/lib/node_modules/npm/bin/npm-cli.js", []string{"/usr/local/lib/node_modules/.bin", "/usr/local/bin"}},
+		{"/work/app/node_modules/pnpm/bin/pnpm.cjs", []string{"/work/app/node_modules/.bin"}},
+		{"/opt/pnpm/pnpm.cjs", nil},
+	}
+	for _, tt := range tests {
+		got := packageManagerBinDirs(tt.execPath)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("packageManagerBinDirs(%q) = %v, want %v", tt.execPath, got, tt.want)
+		}
+	}
+}
+
+func TestRunRenamedRibbin(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("npm_config_local_prefix", "")
+	t.Setenv("npm_execpath", "")
+	exePath, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	// A link to the running binary under another name is ribbin renamed
+	renamed := filepath.Join(dir, "ribbin@1.2")
+	if err := os.Symlink(exePath, renamed); err != nil {
+		t.Fatal(err)
+	}
+	err = Run(renamed, nil)
+	if _, ok := err.(*RenamedRibbinError); !ok {
+		t.Fatalf("Run = %v, want a RenamedRibbinError", err)
+	}
+	if !strings.Contains(err.Error(), "RIBBIN_FORCE_CLI=1") {
+		t.Errorf("error should mention RIBBIN_FORCE_CLI, got: %v", err)
+	}
+
+	// A wrapper whose sidecar was deleted still says what it looked for
+	wrapper := filepath.Join(dir, "tsc")
+	if err := os.Symlink(exePath, wrapper); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(MetadataPath(wrapper), []byte(`{}`), 0644)
+	if _, ok := Run(wrapper, nil).(*SidecarNotFoundError); !ok {
+		t.Errorf("Run on a wrapper without its sidecar should return a SidecarNotFoundError")
+	}
+}
@@ -0,0 +1,348 @@
+package wrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// Interception metrics are opt-in (see config.MetricsSettings). Once enabled,
+// each invocation of a wrapper that applies is counted by command and
+// outcome in $XDG_STATE_HOME/ribbin/metrics.json, and sent to whichever
+// exporters the settings name. Invocations where no wrapper applies (not
+// wrapped here, not active, RIBBIN_BYPASS) aren't interceptions and aren't
+// counted. Metrics never stop or fail the command.
+
+// metricsFileName is the counts file in ribbin's state directory
+const metricsFileName = "metrics.json"
+
+// metricsTimeout bounds the time spent exporting an interception
+const metricsTimeout = 500 * time.Millisecond
+
+// metricName is the counter's name in each export format
+const metricName = "ribbin_interceptions_total"
+
+// MetricCounts are the interceptions counted since Since
+type MetricCounts struct {
+	Since time.Time `json:"since"`
+	// Counts maps command to outcome (blocked, pass, redirect) to count
+	Counts map[string]map[string]int64 `json:"counts"`
+}
+
+// MetricSample is one command/outcome count
+type MetricSample struct {
+	Command string
+	Outcome string
+	Count   int64
+}
+
+// Samples returns the counts sorted by command and outcome
+func (c *MetricCounts) Samples() []MetricSample {
+	var samples []MetricSample
+	for command, outcomes := range c.Counts {
+		for outcome, count := range outcomes {
+			samples = append(samples, MetricSample{Command: command, Outcome: outcome, Count: count})
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].Command != samples[j].Command {
+			return samples[i].Command < samples[j].Command
+		}
+		return samples[i].Outcome < samples[j].Outcome
+	})
+	return samples
+}
+
+// metricsCommand is the command to count the decision for, set by Run once a
+// wrapper applies. It's cleared once counted, so each invocation counts once.
+var metricsCommand string
+
+// MetricsPath returns the path of the counts file
+func MetricsPath() (string, error) {
+	stateDir, err := security.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, metricsFileName), nil
+}
+
+// LoadMetricCounts reads the counts file. Before anything is counted the
+// counts are empty.
+func LoadMetricCounts() (*MetricCounts, error) {
+	metricsPath, err := MetricsPath()
+	if err != nil {
+		return nil, err
+	}
+	return readMetricCounts(metricsPath)
+}
+
+func readMetricCounts(metricsPath string) (*MetricCounts, error) {
+	counts := &MetricCounts{Counts: map[string]map[string]int64{}}
+	data, err := os.ReadFile(metricsPath)
+	if os.IsNotExist(err) {
+		return counts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, counts); err != nil {
+		return nil, fmt.Errorf("corrupt metrics file %s: %w", metricsPath, err)
+	}
+	if counts.Counts == nil {
+		counts.Counts = map[string]map[string]int64{}
+	}
+	return counts, nil
+}
+
+// ResetMetricCounts deletes the counts and the execution log, so counting
+// starts again from zero
+func ResetMetricCounts() error {
+	metricsPath, err := MetricsPath()
+	if err != nil {
+		return err
+	}
+	err = security.WithLock(metricsPath, 2*time.Second, func() error {
+		if err := os.Remove(metricsPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return resetExecLog()
+}
+
+// recordMetric counts the decision for the wrapper being run, if metrics
+// are enabled. Failures are only reported with RIBBIN_VERBOSE=1.
+func recordMetric(action string) {
+	command, configPath := metricsCommand, metricsConfig
+	if command == "" {
+		return
+	}
+	metricsCommand, metricsConfig = "", ""
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		verboseLog("metrics: %v", err)
+		return
+	}
+	if !settings.MetricsEnabled() {
+		return
+	}
+	if err := countInterception(settings.Metrics, command, strings.ToLower(action)); err != nil {
+		verboseLog("metrics: %v", err)
+	}
+	if err := logExecution(command, strings.ToLower(action), configPath); err != nil {
+		verboseLog("metrics: %v", err)
+	}
+}
+
+// countInterception adds one to the command's count for outcome and exports
+// it. Every exporter is tried; the first error is returned.
+func countInterception(m *config.MetricsSettings, command, outcome string) error {
+	if _, err := security.EnsureStateDir(); err != nil {
+		return err
+	}
+	metricsPath, err := MetricsPath()
+	if err != nil {
+		return err
+	}
+
+	var counts *MetricCounts
+	err = security.WithLock(metricsPath, metricsTimeout, func() error {
+		if counts, err = readMetricCounts(metricsPath); err != nil {
+			return err
+		}
+		if counts.Since.IsZero() {
+			counts.Since = time.Now().UTC()
+		}
+		if counts.Counts[command] == nil {
+			counts.Counts[command] = map[string]int64{}
+		}
+		counts.Counts[command][outcome]++
+		data, err := json.MarshalIndent(counts, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(metricsPath, data, 0600)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot count interception: %w", err)
+	}
+
+	var errs []error
+	if m.Statsd != "" {
+		errs = append(errs, sendStatsd(m.Statsd, command, outcome))
+	}
+	if m.OTLP != "" {
+		errs = append(errs, postOTLP(m.OTLPMetricsURL(), counts))
+	}
+	if m.PrometheusTextfile != "" {
+		errs = append(errs, WritePrometheusTextfile(m.PrometheusTextfile, counts))
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the
+// same directory, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// metricSegment makes s safe as a statsd name segment
+func metricSegment(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// statsdLine is the counter increment sent to statsd, in the plain format
+// every statsd accepts: ribbin.interceptions.<command>.<outcome>:1|c
+func statsdLine(command, outcome string) string {
+	return fmt.Sprintf("ribbin.interceptions.%s.%s:1|c", metricSegment(command), metricSegment(outcome))
+}
+
+// sendStatsd sends one increment to the statsd daemon at addr
+func sendStatsd(addr, command, outcome string) error {
+	conn, err := net.DialTimeout("udp", addr, metricsTimeout)
+	if err != nil {
+		return fmt.Errorf("statsd: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(statsdLine(command, outcome))); err != nil {
+		return fmt.Errorf("statsd: %w", err)
+	}
+	return nil
+}
+
+// FormatPrometheus renders the counts in the Prometheus text format
+func FormatPrometheus(counts *MetricCounts) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# HELP %s Invocations of ribbin wrappers, by command and outcome.\n", metricName)
+	fmt.Fprintf(&buf, "# TYPE %s counter\n", metricName)
+	for _, sample := range counts.Samples() {
+		fmt.Fprintf(&buf, "%s{command=%s,outcome=%s} %d\n", metricName,
+			strconv.Quote(sample.Command), strconv.Quote(sample.Outcome), sample.Count)
+	}
+	return buf.Bytes()
+}
+
+// WritePrometheusTextfile replaces path with the counts in the Prometheus
+// text format. The file is replaced atomically, as node_exporter requires.
+func WritePrometheusTextfile(path string, counts *MetricCounts) error {
+	if err := writeFileAtomic(path, FormatPrometheus(counts), 0644); err != nil {
+		return fmt.Errorf("prometheus textfile: %w", err)
+	}
+	return nil
+}
+
+// otlpAttribute is a key/value attribute in OTLP's JSON encoding
+type otlpAttribute struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+func otlpString(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: map[string]string{"stringValue": value}}
+}
+
+// otlpPayload renders the counts as an OTLP/HTTP JSON export request with
+// one cumulative, monotonic sum
+func otlpPayload(counts *MetricCounts, now time.Time) ([]byte, error) {
+	type dataPoint struct {
+		Attributes        []otlpAttribute `json:"attributes"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		TimeUnixNano      string          `json:"timeUnixNano"`
+		AsInt             string          `json:"asInt"`
+	}
+	var points []dataPoint
+	for _, sample := range counts.Samples() {
+		points = append(points, dataPoint{
+			Attributes:        []otlpAttribute{otlpString("command", sample.Command), otlpString("outcome", sample.Outcome)},
+			StartTimeUnixNano: strconv.FormatInt(counts.Since.UnixNano(), 10),
+			TimeUnixNano:      strconv.FormatInt(now.UnixNano(), 10),
+			AsInt:             strconv.FormatInt(sample.Count, 10),
+		})
+	}
+
+	resource := []otlpAttribute{otlpString("service.name", "ribbin")}
+	if hostname, err := os.Hostname(); err == nil {
+		resource = append(resource, otlpString("host.name", hostname))
+	}
+	payload := map[string]any{
+		"resourceMetrics": []any{map[string]any{
+			"resource": map[string]any{"attributes": resource},
+			"scopeMetrics": []any{map[string]any{
+				"scope": map[string]string{"name": "ribbin", "version": Version},
+				"metrics": []any{map[string]any{
+					"name":        "ribbin.interceptions",
+					"description": "Invocations of ribbin wrappers, by command and outcome",
+					"unit":        "1",
+					"sum": map[string]any{
+						// AGGREGATION_TEMPORALITY_CUMULATIVE
+						"aggregationTemporality": 2,
+						"isMonotonic":            true,
+						"dataPoints":             points,
+					},
+				}},
+			}},
+		}},
+	}
+	return json.Marshal(payload)
+}
+
+// otlpClient posts to the collector; a variable so tests can replace it
+var otlpClient = &http.Client{Timeout: metricsTimeout}
+
+// postOTLP exports the counts to the OTLP/HTTP collector at metricsURL
+func postOTLP(metricsURL string, counts *MetricCounts) error {
+	body, err := otlpPayload(counts, time.Now())
+	if err != nil {
+		return fmt.Errorf("otlp: %w", err)
+	}
+	resp, err := otlpClient.Post(metricsURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("otlp: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: %s returned %s", metricsURL, resp.Status)
+	}
+	return nil
+}
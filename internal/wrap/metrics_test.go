@@ -0,0 +1,164 @@
+package wrap
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestCountInterception(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+
+	statsd, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { statsd.Close() })
+
+	var otlpBody []byte
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		otlpBody, _ = io.ReadAll(r.Body)
+	}))
+	t.Cleanup(collector.Close)
+
+	textfile := filepath.Join(dir, "ribbin.prom")
+	settings := &config.MetricsSettings{
+		Enabled:            true,
+		Statsd:             statsd.LocalAddr().String(),
+		OTLP:               collector.URL,
+		PrometheusTextfile: textfile,
+	}
+
+	for _, outcome := range []string{"blocked", "blocked", "pass"} {
+		if err := countInterception(settings, "npm", outcome); err != nil {
+			t.Fatalf("countInterception error: %v", err)
+		}
+	}
+	if err := countInterception(settings, "tsc", "redirect"); err != nil {
+		t.Fatalf("countInterception error: %v", err)
+	}
+
+	counts, err := LoadMetricCounts()
+	if err != nil {
+		t.Fatalf("LoadMetricCounts error: %v", err)
+	}
+	if counts.Counts["npm"]["blocked"] != 2 || counts.Counts["npm"]["pass"] != 1 || counts.Counts["tsc"]["redirect"] != 1 {
+		t.Errorf("unexpected counts: %v", counts.Counts)
+	}
+	if counts.Since.IsZero() {
+		t.Error("Since should be set")
+	}
+
+	t.Run("statsd", func(t *testing.T) {
+		statsd.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 512)
+		n, _, err := statsd.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("no statsd packet: %v", err)
+		}
+		if got := string(buf[:n]); got != "ribbin.interceptions.npm.blocked:1|c" {
+			t.Errorf("statsd packet = %q", got)
+		}
+	})
+
+	t.Run("prometheus textfile", func(t *testing.T) {
+		data, err := os.ReadFile(textfile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			"# TYPE ribbin_interceptions_total counter",
+			`ribbin_interceptions_total{command="npm",outcome="blocked"} 2`,
+			`ribbin_interceptions_total{command="tsc",outcome="redirect"} 1`,
+		} {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("textfile missing %q:\n%s", want, data)
+			}
+		}
+	})
+
+	t.Run("otlp", func(t *testing.T) {
+		var payload struct {
+			ResourceMetrics []struct {
+				ScopeMetrics []struct {
+					Metrics []struct {
+						Name string `json:"name"`
+						Sum  struct {
+							DataPoints []struct {
+								AsInt string `json:"asInt"`
+							} `json:"dataPoints"`
+						} `json:"sum"`
+					} `json:"metrics"`
+				} `json:"scopeMetrics"`
+			} `json:"resourceMetrics"`
+		}
+		if err := json.Unmarshal(otlpBody, &payload); err != nil {
+			t.Fatalf("invalid OTLP payload: %v\n%s", err, otlpBody)
+		}
+		metric := payload.ResourceMetrics[0].ScopeMetrics[0].Metrics[0]
+		if metric.Name != "ribbin.interceptions" || len(metric.Sum.DataPoints) != 3 {
+			t.Errorf("unexpected OTLP metric: %+v", metric)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		if err := ResetMetricCounts(); err != nil {
+			t.Fatalf("ResetMetricCounts error: %v", err)
+		}
+		counts, err := LoadMetricCounts()
+		if err != nil || len(counts.Counts) != 0 {
+			t.Errorf("counts after reset = %v, %v", counts, err)
+		}
+	})
+}
+
+func TestRecordMetricOnlyWhenEnabled(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	settingsDir := filepath.Join(configHome, "ribbin")
+	if err := os.MkdirAll(settingsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	record := func() {
+		metricsCommand = "npm"
+		recordMetric("BLOCKED")
+		// A second decision in the same invocation isn't counted again
+		recordMetric("BLOCKED")
+	}
+
+	record()
+	metricsPath, _ := MetricsPath()
+	if _, err := os.Stat(metricsPath); !os.IsNotExist(err) {
+		t.Fatal("nothing should be counted without settings")
+	}
+
+	os.WriteFile(filepath.Join(settingsDir, config.SettingsFileName), []byte(`{
+  // opt in
+  "metrics": { "enabled": true }
+}`), 0644)
+	record()
+	counts, err := LoadMetricCounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := counts.Counts["npm"]["blocked"]; got != 1 {
+		t.Errorf("npm blocked = %d, want 1", got)
+	}
+}
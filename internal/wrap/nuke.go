@@ -0,0 +1,121 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// ForceUnwrapOutcome describes how ForceUnwrap left a binary
+type ForceUnwrapOutcome int
+
+const (
+	ForceUnwrapRestored    ForceUnwrapOutcome = iota // Wrapper removed and original restored
+	ForceUnwrapRecreated                             // Binary was missing; original restored from the sidecar
+	ForceUnwrapKeptCurrent                           // Binary was reinstalled over the wrapper; kept it and removed the sidecar
+	ForceUnwrapCleaned                               // Nothing to restore; only leftover files were removed
+)
+
+// String returns a short human-readable description of the outcome
+func (o ForceUnwrapOutcome) String() string {
+	switch o {
+	case ForceUnwrapRestored:
+		return "restored original"
+	case ForceUnwrapRecreated:
+		return "restored missing original"
+	case ForceUnwrapKeptCurrent:
+		return "kept reinstalled binary"
+	case ForceUnwrapCleaned:
+		return "removed leftover files"
+	default:
+		return "unknown"
+	}
+}
+
+// ForceUnwrap removes every trace of ribbin from binaryPath without asking,
+// whatever state the wrapper is in, and drops it from the registry. Unlike
+// the interactive unwrap, a binary reinstalled over the wrapper is kept
+// (its sidecar is the stale one). Fails only if the original can't be put
+// back, such as a wrapper whose sidecar is gone.
+func ForceUnwrap(binaryPath string, registry *config.Registry) (ForceUnwrapOutcome, error) {
+	// A wrapper shadowing a binary left the binary alone, even if it is gone
+	if ShadowedBinary(binaryPath) != "" {
+		if err := uninstallShadow(binaryPath, registry); err != nil {
+			return 0, err
+		}
+		return ForceUnwrapRestored, nil
+	}
+
+	sidecarPath := sidecarFor(binaryPath)
+	_, sidecarErr := os.Stat(sidecarPath)
+	hasSidecar := sidecarErr == nil
+
+	info, err := os.Lstat(binaryPath)
+	exists := err == nil
+	isWrapper := exists && (info.Mode()&os.ModeSymlink != 0 || IsCopyShim(binaryPath))
+
+	switch {
+	case hasSidecar && isWrapper:
+		if err := Uninstall(binaryPath, registry); err != nil {
+			return 0, err
+		}
+		return ForceUnwrapRestored, nil
+
+	case hasSidecar && !exists:
+		if err := security.AtomicRename(sidecarPath, binaryPath); err != nil {
+			return 0, fmt.Errorf("cannot restore original binary: %w", err)
+		}
+		removeEmptySidecarDirs(sidecarPath)
+		meta, _ := LoadMetadata(binaryPath)
+		restoreOriginalAttributes(binaryPath, meta)
+		_ = removeMetadata(binaryPath)
+		registry.RemoveWrapper(binaryPath)
+		return ForceUnwrapRecreated, nil
+
+	case hasSidecar:
+		if err := CleanupSidecarFiles(binaryPath, registry); err != nil {
+			return 0, err
+		}
+		return ForceUnwrapKeptCurrent, nil
+
+	case isWrapper && HasMetadata(binaryPath):
+		return 0, fmt.Errorf("original binary for %s is missing (no %s); reinstall it, then remove the wrapper", binaryPath, filepath.Base(sidecarPath))
+	}
+
+	if err := removeMetadata(binaryPath); err != nil {
+		return 0, fmt.Errorf("cannot remove metadata: %w", err)
+	}
+	registry.RemoveWrapper(binaryPath)
+	return ForceUnwrapCleaned, nil
+}
+
+// FindLeftovers searches directories for binaries with a ribbin sidecar or
+// metadata file and returns their paths, sorted. Unreadable directories
+// are skipped.
+func FindLeftovers(searchPaths []string) []string {
+	seen := make(map[string]bool)
+	for _, dir := range searchPaths {
+		for _, suffix := range []string{sidecarSuffix, ".ribbin-meta"} {
+			matches, _ := filepath.Glob(filepath.Join(dir, "*"+suffix))
+			for _, match := range matches {
+				seen[strings.TrimSuffix(match, suffix)] = true
+			}
+		}
+		stored, _ := filepath.Glob(filepath.Join(dir, SidecarStoreDir, "*", "*"))
+		for _, sidecar := range stored {
+			seen[BinaryForSidecar(sidecar)] = true
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
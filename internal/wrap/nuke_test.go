@@ -0,0 +1,109 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestForceUnwrap(t *testing.T) {
+	// setup wraps dir/tool and returns its path and registry
+	setup := func(t *testing.T) (string, *config.Registry) {
+		t.Helper()
+		dir := t.TempDir()
+		ribbinPath := filepath.Join(dir, "ribbin")
+		if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		binaryPath := filepath.Join(dir, "tool")
+		if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho original"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+		if err := Install(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc"); err != nil {
+			t.Fatalf("Install error: %v", err)
+		}
+		return binaryPath, registry
+	}
+
+	assertClean := func(t *testing.T, binaryPath string, registry *config.Registry, wantContent string) {
+		t.Helper()
+		content, err := os.ReadFile(binaryPath)
+		if err != nil {
+			t.Fatalf("binary not readable: %v", err)
+		}
+		if string(content) != wantContent {
+			t.Errorf("binary content = %q, want %q", content, wantContent)
+		}
+		for _, leftover := range []string{binaryPath + ".ribbin-original", MetadataPath(binaryPath)} {
+			if _, err := os.Lstat(leftover); !os.IsNotExist(err) {
+				t.Errorf("%s should be removed", leftover)
+			}
+		}
+		if _, ok := registry.LookupWrapper("tool", ""); ok {
+			t.Error("registry entry should be removed")
+		}
+	}
+
+	t.Run("restores wrapped binary", func(t *testing.T) {
+		binaryPath, registry := setup(t)
+		outcome, err := ForceUnwrap(binaryPath, registry)
+		if err != nil || outcome != ForceUnwrapRestored {
+			t.Fatalf("ForceUnwrap = %v, %v; want %v", outcome, err, ForceUnwrapRestored)
+		}
+		assertClean(t, binaryPath, registry, "#!/bin/sh\necho original")
+	})
+
+	t.Run("restores original when wrapper is missing", func(t *testing.T) {
+		binaryPath, registry := setup(t)
+		os.Remove(binaryPath)
+		outcome, err := ForceUnwrap(binaryPath, registry)
+		if err != nil || outcome != ForceUnwrapRecreated {
+			t.Fatalf("ForceUnwrap = %v, %v; want %v", outcome, err, ForceUnwrapRecreated)
+		}
+		assertClean(t, binaryPath, registry, "#!/bin/sh\necho original")
+	})
+
+	t.Run("keeps binary reinstalled over wrapper", func(t *testing.T) {
+		binaryPath, registry := setup(t)
+		os.Remove(binaryPath)
+		if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho reinstalled"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		outcome, err := ForceUnwrap(binaryPath, registry)
+		if err != nil || outcome != ForceUnwrapKeptCurrent {
+			t.Fatalf("ForceUnwrap = %v, %v; want %v", outcome, err, ForceUnwrapKeptCurrent)
+		}
+		assertClean(t, binaryPath, registry, "#!/bin/sh\necho reinstalled")
+	})
+
+	t.Run("fails when wrapper has lost its original", func(t *testing.T) {
+		binaryPath, registry := setup(t)
+		os.Remove(binaryPath + ".ribbin-original")
+		if _, err := ForceUnwrap(binaryPath, registry); err == nil {
+			t.Fatal("expected error when the original is gone")
+		}
+		if _, ok := registry.LookupWrapper("tool", ""); !ok {
+			t.Error("registry entry should be kept when nothing could be restored")
+		}
+	})
+}
+
+func TestFindLeftovers(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.ribbin-original", "a.ribbin-meta", "b.ribbin-meta", "c", "d.lock"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := FindLeftovers([]string{dir, filepath.Join(dir, "missing")})
+	want := []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindLeftovers = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+package wrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// onboardingFileName records, in ribbin's state directory, the configs
+// whose onboarding message the user has seen
+const onboardingFileName = "onboarding.json"
+
+// onboardingTimeout bounds waiting for the onboarding state lock
+const onboardingTimeout = 500 * time.Millisecond
+
+// onboardingState maps config paths to when their onboarding was shown
+type onboardingState struct {
+	Shown map[string]time.Time `json:"shown"`
+}
+
+// OnboardingPath returns the path of the onboarding state file
+func OnboardingPath() (string, error) {
+	stateDir, err := security.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, onboardingFileName), nil
+}
+
+func readOnboardingState(statePath string) (*onboardingState, error) {
+	state := &onboardingState{Shown: map[string]time.Time{}}
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("corrupt onboarding file %s: %w", statePath, err)
+	}
+	if state.Shown == nil {
+		state.Shown = map[string]time.Time{}
+	}
+	return state, nil
+}
+
+// markOnboarded records that the user has seen configPath's onboarding, and
+// reports whether this is the first time. Failing to record it counts as
+// seen, so a broken state directory doesn't repeat the message every time.
+func markOnboarded(configPath string) bool {
+	if _, err := security.EnsureStateDir(); err != nil {
+		verboseLog("onboarding: %v", err)
+		return false
+	}
+	statePath, err := OnboardingPath()
+	if err != nil {
+		verboseLog("onboarding: %v", err)
+		return false
+	}
+
+	first := false
+	err = security.WithLock(statePath, onboardingTimeout, func() error {
+		state, err := readOnboardingState(statePath)
+		if err != nil {
+			return err
+		}
+		if _, seen := state.Shown[configPath]; seen {
+			return nil
+		}
+		state.Shown[configPath] = time.Now().UTC()
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(statePath, data, 0600); err != nil {
+			return err
+		}
+		first = true
+		return nil
+	})
+	if err != nil {
+		verboseLog("onboarding: %v", err)
+		return false
+	}
+	return first
+}
+
+// showOnboarding prints the onboarding message of the config at configPath
+// the first time the user is blocked by one of its wrappers
+func showOnboarding(configPath string) {
+	projectConfig, err := config.LoadProjectConfig(configPath)
+	if err != nil || projectConfig.Onboarding == nil || projectConfig.Onboarding.Message == "" {
+		return
+	}
+	if !markOnboarded(configPath) {
+		return
+	}
+	fmt.Fprint(os.Stderr, onboardingText(projectConfig.Onboarding))
+}
+
+// onboardingText formats a project's onboarding message for a new contributor
+func onboardingText(onboarding *config.OnboardingConfig) string {
+	text := "\nNew to this project? It uses ribbin to steer some commands to the project's tools.\n\n"
+	text += onboarding.Message + "\n"
+	if onboarding.DocsURL != "" {
+		text += "\nMore: " + onboarding.DocsURL + "\n"
+	}
+	text += "\n(Shown once. Later blocks only show the short message.)\n\n"
+	return text
+}
@@ -0,0 +1,50 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestMarkOnboarded(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	if !markOnboarded("/work/app/ribbin.jsonc") {
+		t.Error("the first block in a project should show the onboarding")
+	}
+	if markOnboarded("/work/app/ribbin.jsonc") {
+		t.Error("the onboarding should only be shown once per project")
+	}
+	if !markOnboarded("/work/other/ribbin.jsonc") {
+		t.Error("another project's onboarding should still be shown")
+	}
+
+	statePath, err := OnboardingPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := readOnboardingState(statePath)
+	if err != nil {
+		t.Fatalf("readOnboardingState error: %v", err)
+	}
+	if len(state.Shown) != 2 {
+		t.Errorf("expected 2 projects recorded, got %v", state.Shown)
+	}
+}
+
+func TestOnboardingText(t *testing.T) {
+	text := onboardingText(&config.OnboardingConfig{
+		Message: "We use pnpm and mise.",
+		DocsURL: "https://example.com/contributing",
+	})
+	for _, want := range []string{"New to this project?", "We use pnpm and mise.", "More: https://example.com/contributing", "Shown once"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("onboarding text is missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(onboardingText(&config.OnboardingConfig{Message: "Hi"}), "More:") {
+		t.Error("no docs link should be shown without docsUrl")
+	}
+}
@@ -0,0 +1,110 @@
+package wrap
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// overrideTimeout is how long a blocked command waits for the override key
+const overrideTimeout = 5 * time.Second
+
+// offerOverride asks the user at the terminal whether to run a blocked
+// command anyway, for a wrapper with "interactiveOverride": true, and
+// reports whether they pressed 'o' in time. It returns false without asking
+// unless stdin and stderr are terminals.
+func offerOverride() bool {
+	if info, err := os.Stderr.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	// stty fails unless stdin is a terminal
+	saved, err := stty("-g")
+	if err != nil {
+		return false
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return false
+	}
+	restore := func() { stty(saved) }
+	defer restore()
+	defer restoreOnSignal(restore, os.Exit)()
+
+	fmt.Fprintf(os.Stderr, "ribbin: blocked - press 'o' within %ds to override (logged) ", int(overrideTimeout.Seconds()))
+	ok := waitForOverride(os.Stdin, overrideTimeout)
+	fmt.Fprintln(os.Stderr)
+	return ok
+}
+
+// promptSignals are the signals that end the wrapper while the override
+// prompt has the terminal
+var promptSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
+
+// restoreOnSignal catches promptSignals until the returned stop is called.
+// On one, it calls restore to give the terminal back as it was, then exit
+// with the shell's status for the signal: a signal's default action would
+// kill the wrapper before a deferred restore runs, leaving echo off.
+func restoreOnSignal(restore func(), exit func(int)) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, promptSignals...)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			restore()
+			fmt.Fprintln(os.Stderr)
+			exit(128 + int(sig.(syscall.Signal)))
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// waitForOverride reports whether the first key read from r within timeout
+// is 'o'. A read still pending at the timeout is abandoned: the blocked
+// command exits right after.
+func waitForOverride(r io.Reader, timeout time.Duration) bool {
+	key := make(chan byte, 1)
+	go func() {
+		buf := make([]byte, 1)
+		if n, _ := r.Read(buf); n == 0 {
+			buf[0] = 0
+		}
+		key <- buf[0]
+	}()
+
+	select {
+	case k := <-key:
+		return k == 'o' || k == 'O'
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// stty runs stty on the terminal at stdin and returns its output
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	output, err := cmd.Output()
+	return strings.TrimSpace(string(output)), err
+}
+
+// logOverride records in the audit log that cmdName was run anyway from the
+// override prompt. The log entry carries the user and time.
+func logOverride(cmdName, originalPath, configPath string, args []string) {
+	security.LogOverrideUsage(originalPath, map[string]string{
+		"command": cmdName,
+		"args":    strings.Join(args, " "),
+		"config":  configPath,
+		"pid":     fmt.Sprintf("%d", os.Getpid()),
+	})
+}
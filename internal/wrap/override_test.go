@@ -0,0 +1,83 @@
+package wrap
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestWaitForOverride(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{"o overrides", "o", true},
+		{"O overrides", "O", true},
+		{"another key", "x", false},
+		{"only the first key counts", "xo", false},
+		{"end of input", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := waitForOverride(strings.NewReader(tt.input), time.Second); got != tt.want {
+				t.Errorf("waitForOverride(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("times out", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		defer w.Close()
+		if waitForOverride(r, 50*time.Millisecond) {
+			t.Error("waitForOverride without a key should time out")
+		}
+	})
+}
+
+func TestRestoreOnSignal(t *testing.T) {
+	for _, sig := range []syscall.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP} {
+		t.Run(sig.String(), func(t *testing.T) {
+			restored := make(chan struct{}, 1)
+			exited := make(chan int, 1)
+			stop := restoreOnSignal(func() { restored <- struct{}{} }, func(code int) { exited <- code })
+			defer stop()
+
+			if err := syscall.Kill(os.Getpid(), sig); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case code := <-exited:
+				select {
+				case <-restored:
+				default:
+					t.Error("the terminal should be restored before exiting")
+				}
+				if want := 128 + int(sig); code != want {
+					t.Errorf("exit status = %d, want %d", code, want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("the signal was not caught")
+			}
+		})
+	}
+
+	t.Run("stopped", func(t *testing.T) {
+		called := make(chan struct{}, 2)
+		stop := restoreOnSignal(func() { called <- struct{}{} }, func(int) { called <- struct{}{} })
+		stop()
+		select {
+		case <-called:
+			t.Error("restore and exit should not run without a signal")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
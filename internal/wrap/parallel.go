@@ -0,0 +1,216 @@
+package wrap
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// Wrapping a monorepo's config can mean hundreds of node_modules/.bin
+// binaries, and each takes a lock, a look for processes using it and a few
+// renames, most of it waiting on the filesystem. A Pool works through them
+// on several goroutines. Two binaries in the same directory, or whose
+// symlinks end in the same directory, are never worked on at once: sidecar
+// directories are created and removed per directory, and a symlink's target
+// gets a sidecar of its own.
+
+// DefaultWorkers returns how many binaries a Pool works on at once unless
+// told otherwise. The work mostly waits on the filesystem, so it is a few
+// per CPU.
+func DefaultWorkers() int {
+	workers := 4 * runtime.NumCPU()
+	if workers > 32 {
+		workers = 32
+	}
+	return workers
+}
+
+// Pool runs an operation over many binaries at once
+type Pool struct {
+	// Workers is how many binaries are worked on at once; DefaultWorkers if
+	// less than 1
+	Workers int
+	// StopOnError stops starting new binaries after one fails. Those
+	// already started finish.
+	StopOnError bool
+	// Progress, if set, is called after each binary with how many are done
+	// out of the total. Calls never overlap.
+	Progress func(done, total int)
+	// Verb names the operation in a BatchError, e.g. "wrap"
+	Verb string
+}
+
+// BatchFailure is a binary a Pool's operation failed on
+type BatchFailure struct {
+	Path string
+	Err  error
+}
+
+// BatchError is returned by Pool.Run when the operation failed on any
+// binary. Failures are in the order the binaries were given.
+type BatchError struct {
+	Verb     string
+	Failures []BatchFailure
+}
+
+func (e *BatchError) Error() string {
+	verb := e.Verb
+	if verb == "" {
+		verb = "process"
+	}
+	if len(e.Failures) == 1 {
+		return fmt.Sprintf("failed to %s '%s': %v", verb, e.Failures[0].Path, e.Failures[0].Err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "failed to %s %d binaries:", verb, len(e.Failures))
+	for _, failure := range e.Failures {
+		fmt.Fprintf(&b, "\n  %s: %v", failure.Path, failure.Err)
+	}
+	return b.String()
+}
+
+// Unwrap returns the error of each failure, for errors.Is and errors.As
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure.Err
+	}
+	return errs
+}
+
+// ErrFor returns the error path failed with, or nil if it didn't fail
+func (e *BatchError) ErrFor(path string) error {
+	for _, failure := range e.Failures {
+		if failure.Path == path {
+			return failure.Err
+		}
+	}
+	return nil
+}
+
+// Run calls fn for each path, on up to p.Workers goroutines, and returns a
+// *BatchError listing the paths it failed on, or nil. With StopOnError, the
+// paths not yet started when one fails are left alone and not reported.
+func (p *Pool) Run(paths []string, fn func(path string) error) error {
+	workers := p.Workers
+	if workers < 1 {
+		workers = DefaultWorkers()
+	}
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+
+	var (
+		mu      sync.Mutex
+		errs    = make([]error, len(paths))
+		done    int
+		stopped bool
+	)
+	var locks dirLocks
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				unlock := locks.lock(paths[i])
+				err := fn(paths[i])
+				unlock()
+
+				mu.Lock()
+				errs[i] = err
+				if err != nil && p.StopOnError {
+					stopped = true
+				}
+				done++
+				if p.Progress != nil {
+					p.Progress(done, len(paths))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range paths {
+		mu.Lock()
+		stop := stopped
+		mu.Unlock()
+		if stop {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	batchErr := &BatchError{Verb: p.Verb}
+	for i, err := range errs {
+		if err != nil {
+			batchErr.Failures = append(batchErr.Failures, BatchFailure{Path: paths[i], Err: err})
+		}
+	}
+	if len(batchErr.Failures) == 0 {
+		return nil
+	}
+	return batchErr
+}
+
+// dirLocks keeps binaries in the same directory from being worked on at once
+type dirLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock locks the directory of path and, if path is a symlink, the directory
+// of its final target, in a fixed order so two binaries can't deadlock.
+// Returns the function that unlocks them.
+func (d *dirLocks) lock(path string) (unlock func()) {
+	dirs := []string{filepath.Dir(path)}
+	if target, err := filepath.EvalSymlinks(path); err == nil && filepath.Dir(target) != dirs[0] {
+		dirs = append(dirs, filepath.Dir(target))
+	}
+	sort.Strings(dirs)
+
+	d.mu.Lock()
+	if d.locks == nil {
+		d.locks = make(map[string]*sync.Mutex)
+	}
+	held := make([]*sync.Mutex, len(dirs))
+	for i, dir := range dirs {
+		if d.locks[dir] == nil {
+			d.locks[dir] = &sync.Mutex{}
+		}
+		held[i] = d.locks[dir]
+	}
+	d.mu.Unlock()
+
+	for _, m := range held {
+		m.Lock()
+	}
+	return func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i].Unlock()
+		}
+	}
+}
+
+// UninstallAll unwraps paths on pool, removing each one unwrapped from
+// registry. Returns a *BatchError for the paths that failed, or nil.
+func UninstallAll(paths []string, registry *config.Registry, pool *Pool) error {
+	var mu sync.Mutex
+	return pool.Run(paths, func(path string) error {
+		// Uninstall records into its own registry so unwraps can run at once
+		if err := Uninstall(path, &config.Registry{}); err != nil {
+			return err
+		}
+		mu.Lock()
+		registry.RemoveWrapper(path)
+		mu.Unlock()
+		return nil
+	})
+}
@@ -0,0 +1,210 @@
+package wrap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestPool(t *testing.T) {
+	t.Run("runs every path and reports failures in order", func(t *testing.T) {
+		var paths []string
+		for i := 0; i < 20; i++ {
+			paths = append(paths, fmt.Sprintf("/dir%d/bin", i))
+		}
+		errBroken := errors.New("broken")
+		var calls, progressCalls int32
+		pool := &Pool{
+			Workers:  4,
+			Verb:     "wrap",
+			Progress: func(done, total int) { atomic.AddInt32(&progressCalls, 1) },
+		}
+		err := pool.Run(paths, func(path string) error {
+			atomic.AddInt32(&calls, 1)
+			if path == "/dir3/bin" || path == "/dir11/bin" {
+				return errBroken
+			}
+			return nil
+		})
+
+		if calls != 20 || progressCalls != 20 {
+			t.Errorf("calls = %d, progress calls = %d, want 20 each", calls, progressCalls)
+		}
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) {
+			t.Fatalf("Run() error = %v, want a *BatchError", err)
+		}
+		if len(batchErr.Failures) != 2 || batchErr.Failures[0].Path != "/dir3/bin" || batchErr.Failures[1].Path != "/dir11/bin" {
+			t.Errorf("Failures = %+v, want /dir3/bin then /dir11/bin", batchErr.Failures)
+		}
+		if !errors.Is(err, errBroken) {
+			t.Error("errors.Is should find a failure's error")
+		}
+		if batchErr.ErrFor("/dir3/bin") != errBroken || batchErr.ErrFor("/dir4/bin") != nil {
+			t.Error("ErrFor should return each path's error")
+		}
+	})
+
+	t.Run("returns nil when nothing fails", func(t *testing.T) {
+		pool := &Pool{}
+		if err := pool.Run([]string{"/a/one", "/b/two"}, func(string) error { return nil }); err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+		if err := pool.Run(nil, func(string) error { return nil }); err != nil {
+			t.Errorf("Run() with no paths error = %v", err)
+		}
+	})
+
+	t.Run("stops starting paths after a failure", func(t *testing.T) {
+		var paths []string
+		for i := 0; i < 100; i++ {
+			paths = append(paths, fmt.Sprintf("/dir%d/bin", i))
+		}
+		var calls int32
+		pool := &Pool{Workers: 1, StopOnError: true}
+		err := pool.Run(paths, func(path string) error {
+			atomic.AddInt32(&calls, 1)
+			if path == "/dir0/bin" {
+				return errors.New("broken")
+			}
+			return nil
+		})
+		if err == nil {
+			t.Fatal("Run() should fail")
+		}
+		if calls > 2 {
+			t.Errorf("%d paths were run after the first failed", calls-1)
+		}
+	})
+
+	t.Run("never runs two paths in the same directory at once", func(t *testing.T) {
+		var paths []string
+		for i := 0; i < 10; i++ {
+			paths = append(paths, fmt.Sprintf("/shared/bin%d", i), fmt.Sprintf("/own%d/bin", i))
+		}
+		var inShared, overlaps, running, maxRunning int32
+		pool := &Pool{Workers: 8}
+		pool.Run(paths, func(path string) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			if filepath.Dir(path) == "/shared" {
+				if atomic.AddInt32(&inShared, 1) > 1 {
+					atomic.AddInt32(&overlaps, 1)
+				}
+				time.Sleep(2 * time.Millisecond)
+				atomic.AddInt32(&inShared, -1)
+			} else {
+				time.Sleep(2 * time.Millisecond)
+			}
+			atomic.AddInt32(&running, -1)
+			return nil
+		})
+		if overlaps > 0 {
+			t.Errorf("paths in /shared ran at once %d times", overlaps)
+		}
+		if maxRunning < 2 {
+			t.Error("paths in different directories should run at once")
+		}
+	})
+}
+
+func TestDirLocksSymlinkTarget(t *testing.T) {
+	dir := t.TempDir()
+	binDir := filepath.Join(dir, "bin")
+	pkgDir := filepath.Join(dir, "pkg")
+	for _, d := range []string{binDir, pkgDir} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	target := filepath.Join(pkgDir, "cli.js")
+	if err := os.WriteFile(target, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(binDir, "cli")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	var locks dirLocks
+	unlock := locks.lock(link)
+
+	// The target's directory is locked along with the link's
+	locked := make(chan struct{})
+	go func() {
+		release := locks.lock(filepath.Join(pkgDir, "other"))
+		close(locked)
+		release()
+	}()
+	select {
+	case <-locked:
+		t.Fatal("the symlink target's directory should be locked")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+}
+
+func TestParallelInstallAndUninstall(t *testing.T) {
+	dir := t.TempDir()
+	ribbinPath := filepath.Join(dir, "ribbin")
+	if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for i := 0; i < 6; i++ {
+		pkgBin := filepath.Join(dir, fmt.Sprintf("pkg%d", i), "node_modules", ".bin")
+		if err := os.MkdirAll(pkgBin, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"tsc", "eslint"} {
+			path := filepath.Join(pkgBin, name)
+			if err := os.WriteFile(path, []byte("#!/bin/sh\necho original"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			paths = append(paths, path)
+		}
+	}
+
+	registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+	tx := NewTransaction(registry, ribbinPath)
+	pool := &Pool{Workers: 4}
+	if err := pool.Run(paths, func(path string) error {
+		return tx.Install(path, "/project/ribbin.jsonc")
+	}); err != nil {
+		t.Fatalf("installing: %v", err)
+	}
+	if len(registry.Wrappers) != len(paths) || len(tx.Installed()) != len(paths) {
+		t.Fatalf("registry has %d wrappers and the transaction %d, want %d", len(registry.Wrappers), len(tx.Installed()), len(paths))
+	}
+	for _, path := range paths {
+		if !HasSidecar(path) {
+			t.Errorf("%s should be wrapped", path)
+		}
+	}
+
+	if err := UninstallAll(paths, registry, pool); err != nil {
+		t.Fatalf("UninstallAll() error: %v", err)
+	}
+	if len(registry.Wrappers) != 0 {
+		t.Errorf("registry still has %d wrappers", len(registry.Wrappers))
+	}
+	for _, path := range paths {
+		if HasSidecar(path) {
+			t.Errorf("%s should be unwrapped", path)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package wrap
+
+import (
+	"sort"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// ActiveWrappers returns the commands ribbin governs in cwd, sorted: the
+// wrappers in effect there that are installed and covered by an activation
+// of the governing config (or of the user config alone). It reads only the
+// decision cache and the registry, never a config, so it is cheap enough to
+// run for every shell prompt. ok is false if there is no up-to-date cached decision for cwd,
+// which is the case until a wrapped command runs there after the config
+// last changed.
+func ActiveWrappers(registry *config.Registry, cwd string) (names []string, ok bool) {
+	d := lookupDecision(cwd)
+	if d == nil {
+		return nil, false
+	}
+	if d.ConfigPath == "" && d.UserConfigPath == "" {
+		return nil, true
+	}
+	_, act, userOnly := governingActivation(registry, d.ConfigPath, d.UserConfigPath)
+	wrappers := d.Wrappers
+	if userOnly {
+		wrappers = d.UserWrappers
+	}
+	for name, shim := range wrappers {
+		if registry.HasCommand(name) && act.covers(shim, d.Scopes) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
@@ -0,0 +1,59 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestActiveWrappers(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve symlinks: %v", err)
+	}
+	configPath := filepath.Join(tmpDir, "ribbin.jsonc")
+	content := `{"wrappers": {"npm": {"action": "block"}, "tsc": {"action": "block"}, "curl": {"action": "warn"}}}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	registry := &config.Registry{
+		Wrappers: map[string]config.WrapperEntry{
+			"/usr/bin/npm": {Original: "/usr/bin/npm", Config: configPath},
+			"/usr/bin/tsc": {Original: "/usr/bin/tsc", Config: configPath},
+		},
+		ShellActivations:  map[int]config.ShellActivationEntry{},
+		ConfigActivations: map[string]config.ConfigActivationEntry{},
+	}
+
+	if _, ok := ActiveWrappers(registry, tmpDir); ok {
+		t.Fatal("expected no answer without a cached decision")
+	}
+
+	projectConfig, err := config.LoadProjectConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig error: %v", err)
+	}
+	d, err := resolveDecision(tmpDir, configPath, projectConfig)
+	if err != nil {
+		t.Fatalf("resolveDecision error: %v", err)
+	}
+	storeDecision(d)
+
+	names, ok := ActiveWrappers(registry, tmpDir)
+	if !ok || len(names) != 0 {
+		t.Errorf("inactive: names = %v, ok = %v, want none", names, ok)
+	}
+
+	registry.AddConfigActivation(configPath)
+	names, ok = ActiveWrappers(registry, tmpDir)
+	if want := []string{"npm", "tsc"}; !ok || !reflect.DeepEqual(names, want) {
+		t.Errorf("active: names = %v, ok = %v, want %v (curl isn't wrapped)", names, ok, want)
+	}
+}
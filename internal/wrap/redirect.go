@@ -3,7 +3,9 @@ package wrap
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 )
 
 // resolveRedirectScript resolves a redirect script path relative to the config file
@@ -49,3 +51,53 @@ func validateExecutable(path string) (string, error) {
 
 	return path, nil
 }
+
+// redirectContext holds the values available to inline redirect placeholders
+type redirectContext struct {
+	Args     []string
+	Cwd      string
+	Original string
+}
+
+// interpolateRedirect substitutes placeholders in an inline redirect command.
+// A word that is exactly "{args}" expands to all arguments as separate words
+// (or to nothing when there are none); elsewhere {args} is replaced by the
+// arguments joined with spaces.
+func interpolateRedirect(words []string, ctx redirectContext) []string {
+	arg0 := ""
+	if len(ctx.Args) > 0 {
+		arg0 = ctx.Args[0]
+	}
+
+	replacer := strings.NewReplacer(
+		"{args}", strings.Join(ctx.Args, " "),
+		"{arg0}", arg0,
+		"{cwd}", ctx.Cwd,
+		"{original}", ctx.Original,
+	)
+
+	argv := make([]string, 0, len(words)+len(ctx.Args))
+	for _, word := range words {
+		if word == "{args}" {
+			argv = append(argv, ctx.Args...)
+			continue
+		}
+		argv = append(argv, replacer.Replace(word))
+	}
+	return argv
+}
+
+// resolveRedirectProgram finds the executable for an inline redirect command.
+// Names containing a slash are resolved like redirect scripts (relative to the
+// config directory); bare names are looked up on PATH.
+func resolveRedirectProgram(program string, configPath string) (string, error) {
+	if strings.Contains(program, "/") {
+		return resolveRedirectScript(program, configPath)
+	}
+
+	path, err := exec.LookPath(program)
+	if err != nil {
+		return "", fmt.Errorf("redirect command not found on PATH: %s", program)
+	}
+	return path, nil
+}
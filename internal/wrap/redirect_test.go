@@ -3,6 +3,7 @@ package wrap
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	_ "github.com/happycollision/ribbin/internal/testsafety"
@@ -122,3 +123,71 @@ func TestValidateExecutable(t *testing.T) {
 		}
 	})
 }
+
+func TestInterpolateRedirect(t *testing.T) {
+	ctx := redirectContext{
+		Args:     []string{"install", "left pad"},
+		Cwd:      "/work",
+		Original: "/usr/bin/npm.ribbin-original",
+	}
+
+	tests := []struct {
+		name  string
+		words []string
+		args  []string
+		want  []string
+	}{
+		{"args word expands to separate args", []string{"pnpm", "{args}"}, ctx.Args, []string{"pnpm", "install", "left pad"}},
+		{"args word with no args expands to nothing", []string{"pnpm", "{args}"}, nil, []string{"pnpm"}},
+		{"args inside a word joins with spaces", []string{"sh", "-c", "echo {args}"}, ctx.Args, []string{"sh", "-c", "echo install left pad"}},
+		{"arg0 is the first argument", []string{"pnpm", "{arg0}"}, ctx.Args, []string{"pnpm", "install"}},
+		{"cwd and original", []string{"{original}", "--prefix={cwd}"}, ctx.Args, []string{"/usr/bin/npm.ribbin-original", "--prefix=/work"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := ctx
+			c.Args = tt.args
+			got := interpolateRedirect(tt.words, c)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("interpolateRedirect(%q) = %q, want %q", tt.words, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveRedirectProgram(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "ribbin.jsonc")
+
+	script := filepath.Join(tmpDir, "run.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\n"), 0755)
+
+	t.Run("path with slash resolves from config dir", func(t *testing.T) {
+		got, err := resolveRedirectProgram("./run.sh", configPath)
+		if err != nil {
+			t.Fatalf("should resolve: %v", err)
+		}
+		if got != script {
+			t.Errorf("expected %s, got %s", script, got)
+		}
+	})
+
+	t.Run("bare name is looked up on PATH", func(t *testing.T) {
+		t.Setenv("PATH", tmpDir)
+		got, err := resolveRedirectProgram("run.sh", configPath)
+		if err != nil {
+			t.Fatalf("should resolve: %v", err)
+		}
+		if got != script {
+			t.Errorf("expected %s, got %s", script, got)
+		}
+	})
+
+	t.Run("missing bare name errors", func(t *testing.T) {
+		t.Setenv("PATH", tmpDir)
+		if _, err := resolveRedirectProgram("definitely-not-here", configPath); err == nil {
+			t.Error("should error on missing program")
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// RelinkStatus describes where a wrapper symlink points relative to the
+// ribbin binary it should point at
+type RelinkStatus int
+
+const (
+	RelinkNotNeeded   RelinkStatus = iota // Wrapper already points at the ribbin binary
+	RelinkBroken                          // Wrapper points at a ribbin binary that no longer exists
+	RelinkOtherRibbin                     // Wrapper points at the ribbin binary it was wrapped with, elsewhere
+	RelinkReplaced                        // Wrapper was replaced by something other than ribbin (see CheckHeal)
+	RelinkNotWrapped                      // Binary is missing or has no sidecar; nothing to relink
+)
+
+// String returns a short human-readable description of the status
+func (s RelinkStatus) String() string {
+	switch s {
+	case RelinkNotNeeded:
+		return "ok"
+	case RelinkBroken:
+		return "ribbin binary missing"
+	case RelinkOtherRibbin:
+		return "points at another ribbin binary"
+	case RelinkReplaced:
+		return "wrapper replaced"
+	case RelinkNotWrapped:
+		return "not wrapped"
+	default:
+		return "unknown"
+	}
+}
+
+// RelinkCheck is the result of inspecting a wrapper symlink
+type RelinkCheck struct {
+	BinaryPath string
+	Status     RelinkStatus
+	Target     string // Absolute path the wrapper symlink points at, or the ribbin binary a copy was made from
+	Recorded   string // Ribbin binary recorded in metadata at wrap time
+	Copy       bool   // The wrapper is a copy of ribbin (config.ShimModeCopy)
+}
+
+// CheckRelink inspects the wrapper at binaryPath to see whether it points at
+// ribbinPath. A symlink to a file that no longer exists is assumed to be a
+// wrapper whose ribbin binary moved, since package managers replace wrappers
+// with working binaries or symlinks. A wrapper that is a copy of ribbin
+// needs relinking when it isn't a copy of ribbinPath, such as after an
+// upgrade.
+func CheckRelink(binaryPath, ribbinPath string) RelinkCheck {
+	check := RelinkCheck{BinaryPath: binaryPath}
+
+	meta, _ := LoadMetadata(binaryPath)
+	check.Recorded = metaRibbinPath(meta)
+
+	info, err := os.Lstat(binaryPath)
+	if err != nil || !HasSidecar(binaryPath) {
+		check.Status = RelinkNotWrapped
+		return check
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		if !isCopyShim(binaryPath, meta) {
+			check.Status = RelinkReplaced
+			return check
+		}
+		check.Copy = true
+		check.Target = check.Recorded
+		if hash, err := hashFile(ribbinPath); err == nil && hash == meta.ShimHash {
+			check.Status = RelinkNotNeeded
+		} else {
+			check.Status = RelinkOtherRibbin
+		}
+		return check
+	}
+
+	target, err := os.Readlink(binaryPath)
+	if err != nil {
+		check.Status = RelinkNotWrapped
+		return check
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(binaryPath), target)
+	}
+	check.Target = target
+
+	targetInfo, err := os.Stat(target)
+	switch {
+	case err != nil:
+		check.Status = RelinkBroken
+	case sameFile(targetInfo, ribbinPath):
+		check.Status = RelinkNotNeeded
+	case target == check.Recorded:
+		check.Status = RelinkOtherRibbin
+	default:
+		check.Status = RelinkReplaced
+	}
+	return check
+}
+
+func sameFile(info os.FileInfo, path string) bool {
+	other, err := os.Stat(path)
+	return err == nil && os.SameFile(info, other)
+}
+
+// Relink re-points the wrapper at binaryPath to ribbinPath if it is broken
+// (RelinkBroken) or points at the ribbin binary it was wrapped with
+// (RelinkOtherRibbin). Replaced wrappers are left for Heal. Returns the check
+// that was acted on and whether the wrapper was relinked.
+func Relink(binaryPath, ribbinPath string) (RelinkCheck, bool, error) {
+	return relink(binaryPath, ribbinPath, func(check RelinkCheck) bool {
+		return check.Status == RelinkBroken || check.Status == RelinkOtherRibbin
+	})
+}
+
+// RelinkShim points the wrapper at binaryPath to ribbinPath, for when the
+// ribbin binary moves (see 'ribbin self-update --path'). Only wrappers that
+// point at oldRibbinPath, or at a ribbin that no longer exists at the path
+// recorded in their metadata, are changed, so wrappers made by another ribbin
+// install are left alone. Returns true if the wrapper was relinked.
+func RelinkShim(binaryPath, ribbinPath, oldRibbinPath string) (bool, error) {
+	_, relinked, err := relink(binaryPath, ribbinPath, func(check RelinkCheck) bool {
+		switch check.Status {
+		case RelinkBroken:
+			return check.Target == oldRibbinPath || check.Target == check.Recorded
+		case RelinkOtherRibbin, RelinkReplaced:
+			return check.Target == oldRibbinPath
+		default:
+			return false
+		}
+	})
+	return relinked, err
+}
+
+// relink swaps the wrapper symlink at binaryPath over to ribbinPath if
+// shouldRelink approves of its current state. A wrapper that is a copy of
+// ribbin is replaced with a fresh copy, and a missing one is recreated as
+// the user's settings say (see config.ShimModeCopy).
+func relink(binaryPath, ribbinPath string, shouldRelink func(RelinkCheck) bool) (RelinkCheck, bool, error) {
+	check := RelinkCheck{BinaryPath: binaryPath}
+
+	// The wrapper itself may point at a ribbin that is gone, so validate its
+	// directory through the sidecar, and the ribbin it will point at
+	sidecarPath, err := SidecarPath(binaryPath)
+	if err != nil {
+		return check, false, err
+	}
+	if err := security.ValidateBinaryPath(sidecarPath); err != nil {
+		return check, false, fmt.Errorf("invalid binary path: %w", err)
+	}
+	if err := security.ValidateBinaryPath(ribbinPath); err != nil {
+		return check, false, fmt.Errorf("invalid ribbin path: %w", err)
+	}
+
+	lock, err := security.AcquireLock(binaryPath, 10*time.Second)
+	if err != nil {
+		return check, false, fmt.Errorf("cannot acquire lock: %w", err)
+	}
+	defer lock.Release()
+
+	check = CheckRelink(binaryPath, ribbinPath)
+	if !shouldRelink(check) {
+		return check, false, nil
+	}
+
+	copyMode := check.Copy
+	if _, err := os.Lstat(binaryPath); os.IsNotExist(err) {
+		mode, err := shimMode()
+		if err != nil {
+			return check, false, err
+		}
+		copyMode = mode == config.ShimModeCopy
+	}
+
+	// Swap the wrapper atomically so the command never goes missing
+	tmpLink := binaryPath + ".ribbin-relink"
+	os.Remove(tmpLink)
+	if copyMode {
+		if err := copyShim(ribbinPath, tmpLink); err != nil {
+			return check, false, fmt.Errorf("cannot copy ribbin: %w", err)
+		}
+	} else if err := os.Symlink(ribbinPath, tmpLink); err != nil {
+		return check, false, fmt.Errorf("cannot create symlink: %w", err)
+	}
+	if err := os.Rename(tmpLink, binaryPath); err != nil {
+		os.Remove(tmpLink)
+		return check, false, fmt.Errorf("cannot replace wrapper: %w", err)
+	}
+
+	// A copy isn't recognized as a wrapper unless its metadata matches it
+	if meta, err := LoadMetadata(binaryPath); err == nil && meta != nil {
+		meta.RibbinPath = ribbinPath
+		err := recordShim(meta, binaryPath)
+		if err == nil {
+			err = saveMetadata(binaryPath, meta)
+		}
+		if err != nil && copyMode {
+			return check, true, fmt.Errorf("cannot record metadata: %w", err)
+		}
+	}
+	return check, true, nil
+}
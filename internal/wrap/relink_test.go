@@ -0,0 +1,167 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestRelinkShim(t *testing.T) {
+	// setup wraps a binary with a ribbin at dir/old/ribbin, and creates a new
+	// ribbin at dir/new/ribbin
+	setup := func(t *testing.T) (binaryPath, oldRibbin, newRibbin string) {
+		t.Helper()
+		dir := t.TempDir()
+		oldRibbin = filepath.Join(dir, "old", "ribbin")
+		newRibbin = filepath.Join(dir, "new", "ribbin")
+		for _, path := range []string{oldRibbin, newRibbin} {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+				t.Fatalf("failed to create ribbin: %v", err)
+			}
+		}
+		binaryPath = filepath.Join(dir, "tool")
+		if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho tool"), 0755); err != nil {
+			t.Fatalf("failed to create binary: %v", err)
+		}
+		registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+		if err := Install(binaryPath, oldRibbin, registry, "/project/ribbin.jsonc"); err != nil {
+			t.Fatalf("Install error: %v", err)
+		}
+		return binaryPath, oldRibbin, newRibbin
+	}
+
+	assertTarget := func(t *testing.T, binaryPath, want string) {
+		t.Helper()
+		got, err := os.Readlink(binaryPath)
+		if err != nil {
+			t.Fatalf("Readlink error: %v", err)
+		}
+		if got != want {
+			t.Errorf("wrapper points at %s, want %s", got, want)
+		}
+	}
+
+	t.Run("relinks wrapper pointing at the previous ribbin", func(t *testing.T) {
+		binaryPath, oldRibbin, newRibbin := setup(t)
+
+		changed, err := RelinkShim(binaryPath, newRibbin, oldRibbin)
+		if err != nil {
+			t.Fatalf("RelinkShim error: %v", err)
+		}
+		if !changed {
+			t.Error("expected wrapper to be relinked")
+		}
+		assertTarget(t, binaryPath, newRibbin)
+
+		meta, err := LoadMetadata(binaryPath)
+		if err != nil {
+			t.Fatalf("LoadMetadata error: %v", err)
+		}
+		if meta.RibbinPath != newRibbin {
+			t.Errorf("metadata ribbin path = %s, want %s", meta.RibbinPath, newRibbin)
+		}
+		if _, err := os.Lstat(binaryPath + ".ribbin-relink"); !os.IsNotExist(err) {
+			t.Error("temporary symlink should not be left behind")
+		}
+	})
+
+	t.Run("relinks wrapper whose recorded ribbin is gone", func(t *testing.T) {
+		binaryPath, oldRibbin, newRibbin := setup(t)
+		os.Remove(oldRibbin)
+
+		changed, err := RelinkShim(binaryPath, newRibbin, "/somewhere/else/ribbin")
+		if err != nil {
+			t.Fatalf("RelinkShim error: %v", err)
+		}
+		if !changed {
+			t.Error("expected broken wrapper to be relinked")
+		}
+		assertTarget(t, binaryPath, newRibbin)
+	})
+
+	t.Run("leaves wrappers of another ribbin alone", func(t *testing.T) {
+		binaryPath, oldRibbin, newRibbin := setup(t)
+
+		changed, err := RelinkShim(binaryPath, newRibbin, "/somewhere/else/ribbin")
+		if err != nil {
+			t.Fatalf("RelinkShim error: %v", err)
+		}
+		if changed {
+			t.Error("wrapper pointing at a working ribbin should not be relinked")
+		}
+		assertTarget(t, binaryPath, oldRibbin)
+	})
+
+	t.Run("ignores binaries that are not wrapped", func(t *testing.T) {
+		dir := t.TempDir()
+		binaryPath := filepath.Join(dir, "plain")
+		if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		changed, err := RelinkShim(binaryPath, filepath.Join(dir, "ribbin"), "")
+		if err != nil {
+			t.Fatalf("RelinkShim error: %v", err)
+		}
+		if changed {
+			t.Error("unwrapped binary should not be touched")
+		}
+	})
+
+	t.Run("Relink re-points wrappers of the recorded ribbin", func(t *testing.T) {
+		binaryPath, oldRibbin, newRibbin := setup(t)
+
+		if check := CheckRelink(binaryPath, newRibbin); check.Status != RelinkOtherRibbin {
+			t.Fatalf("status = %s, want %s", check.Status, RelinkOtherRibbin)
+		}
+		check, changed, err := Relink(binaryPath, newRibbin)
+		if err != nil {
+			t.Fatalf("Relink error: %v", err)
+		}
+		if !changed || check.Target != oldRibbin {
+			t.Errorf("expected relink from %s, got changed=%v check=%+v", oldRibbin, changed, check)
+		}
+		assertTarget(t, binaryPath, newRibbin)
+		if check := CheckRelink(binaryPath, newRibbin); check.Status != RelinkNotNeeded {
+			t.Errorf("after relink status = %s, want %s", check.Status, RelinkNotNeeded)
+		}
+	})
+
+	t.Run("Relink re-points broken wrappers", func(t *testing.T) {
+		binaryPath, oldRibbin, newRibbin := setup(t)
+		os.Remove(oldRibbin)
+
+		if check := CheckRelink(binaryPath, newRibbin); check.Status != RelinkBroken {
+			t.Fatalf("status = %s, want %s", check.Status, RelinkBroken)
+		}
+		if check := CheckHeal(binaryPath, newRibbin); check.Status != HealRibbinMoved {
+			t.Errorf("heal status = %s, want %s", check.Status, HealRibbinMoved)
+		}
+		if _, changed, err := Relink(binaryPath, newRibbin); err != nil || !changed {
+			t.Fatalf("Relink changed=%v err=%v", changed, err)
+		}
+		assertTarget(t, binaryPath, newRibbin)
+	})
+
+	t.Run("Relink leaves replaced wrappers for heal", func(t *testing.T) {
+		binaryPath, _, newRibbin := setup(t)
+		os.Remove(binaryPath)
+		if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho v2"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		check, changed, err := Relink(binaryPath, newRibbin)
+		if err != nil {
+			t.Fatalf("Relink error: %v", err)
+		}
+		if changed || check.Status != RelinkReplaced {
+			t.Errorf("expected replaced wrapper to be left alone, got changed=%v status=%s", changed, check.Status)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// A config's "require": {"wrapped": [...]} names commands that must be
+// wrapped. Someone who clones the project and never runs 'ribbin wrap', or
+// reinstalls node_modules, runs them unwrapped, so ribbin keeps a sentinel
+// for each: a symlink to ribbin in a directory the shell hook and 'ribbin
+// direnv' put on PATH. A sentinel runs the next command of its name on
+// PATH, first printing how to set the project up if that command isn't
+// wrapped and the project requires it.
+
+// SentinelDirName is the directory, in ribbin's state directory, holding
+// the sentinels of required commands
+const SentinelDirName = "sentinel"
+
+// SentinelDir returns the directory holding the sentinels of required commands
+func SentinelDir() (string, error) {
+	stateDir, err := security.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, SentinelDirName), nil
+}
+
+// IsSentinel reports whether path is in the sentinel directory
+func IsSentinel(path string) bool {
+	dir, err := SentinelDir()
+	return err == nil && filepath.Dir(path) == dir
+}
+
+// InstallSentinels creates a sentinel linking to ribbinPath for each of
+// names that has none
+func InstallSentinels(names []string, ribbinPath string) error {
+	dir, err := SentinelDir()
+	if err != nil {
+		return err
+	}
+	if _, err := security.EnsureStateDir(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create sentinel directory: %w", err)
+	}
+
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if target, err := os.Readlink(path); err == nil && target == ribbinPath {
+			continue
+		}
+		os.Remove(path)
+		if err := os.Symlink(ribbinPath, path); err != nil {
+			return fmt.Errorf("cannot create sentinel for %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// lookPathSkipping finds the executable name on PATH like exec.LookPath,
+// skipping the directory skip
+func lookPathSkipping(name, skip string) (string, error) {
+	if strings.Contains(name, string(filepath.Separator)) {
+		return exec.LookPath(name)
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" || filepath.Clean(dir) == skip {
+			continue
+		}
+		if path := filepath.Join(dir, name); isExecutableFile(path) {
+			return path, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// runSentinel runs the command the sentinel at argv0 stands in for: the
+// next one of its name on PATH. If that isn't wrapped and a config here
+// requires it to be, it first prints how to set up the project.
+func runSentinel(argv0 string, args []string) error {
+	name := filepath.Base(argv0)
+	path, err := ResolveCommand(name)
+	if err != nil {
+		return fmt.Errorf("command not found")
+	}
+	if !isWrapper(path) && !IsShadowed(path) {
+		if configPath := requiringConfig(name); configPath != "" {
+			fmt.Fprint(os.Stderr, requireNudge(name, path, configPath))
+		}
+	}
+	return execArgv(append([]string{path}, args...), os.Environ())
+}
+
+// requiringConfig returns the config governing the working directory, or
+// a parent config it merges with, that requires name to be wrapped, or ""
+func requiringConfig(name string) string {
+	configPath, err := config.FindProjectConfig()
+	if err != nil || configPath == "" {
+		return ""
+	}
+	chain, _ := config.ConfigChain(configPath)
+	for _, path := range chain {
+		if projectConfig, err := config.LoadProjectConfig(path); err == nil && projectConfig.RequiresWrapped(name) {
+			return path
+		}
+	}
+	return ""
+}
+
+// requiredCommands returns the commands the config at configPath, and the
+// parent configs it merges with, require to be wrapped
+func requiredCommands(configPath string) []string {
+	var names []string
+	chain, _ := config.ConfigChain(configPath)
+	for _, path := range chain {
+		if projectConfig, err := config.LoadProjectConfig(path); err == nil && projectConfig.Require != nil {
+			names = append(names, projectConfig.Require.Wrapped...)
+		}
+	}
+	return names
+}
+
+// requireNudge tells someone running a required command unwrapped how to
+// set up the project
+func requireNudge(name, path, configPath string) string {
+	return fmt.Sprintf("ribbin: %s is not wrapped (%s), but %s requires it.\n"+
+		"  Run 'ribbin wrap' in %s to set up the project's wrappers.\n",
+		name, path, configPath, filepath.Dir(configPath))
+}
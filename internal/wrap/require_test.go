@@ -0,0 +1,73 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestSentinels(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	ribbinPath := filepath.Join(dir, "ribbin")
+	binDir := filepath.Join(dir, "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{ribbinPath, filepath.Join(binDir, "tsc")} {
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := InstallSentinels([]string{"tsc"}, ribbinPath); err != nil {
+		t.Fatal(err)
+	}
+	// Installing again leaves the sentinel as it is
+	if err := InstallSentinels([]string{"tsc"}, ribbinPath); err != nil {
+		t.Fatal(err)
+	}
+	sentinelDir, _ := SentinelDir()
+	sentinel := filepath.Join(sentinelDir, "tsc")
+	if target, err := os.Readlink(sentinel); err != nil || target != ribbinPath {
+		t.Fatalf("sentinel links to %q (%v), want %s", target, err, ribbinPath)
+	}
+	if !IsSentinel(sentinel) || IsSentinel(filepath.Join(binDir, "tsc")) {
+		t.Error("IsSentinel should only match the sentinel directory")
+	}
+
+	t.Run("ResolveCommand passes over sentinels", func(t *testing.T) {
+		t.Setenv("PATH", sentinelDir+string(filepath.ListSeparator)+binDir)
+		if got, err := ResolveCommand("tsc"); err != nil || got != filepath.Join(binDir, "tsc") {
+			t.Errorf("ResolveCommand(tsc) = %q, %v; want %s", got, err, filepath.Join(binDir, "tsc"))
+		}
+		if _, err := ResolveCommand("ribbin-no-such-command"); err == nil {
+			t.Error("ResolveCommand should fail for a command that isn't installed")
+		}
+	})
+
+	t.Run("only a requiring config nudges", func(t *testing.T) {
+		project := t.TempDir()
+		configPath := filepath.Join(project, "ribbin.jsonc")
+		if err := os.WriteFile(configPath, []byte(`{"require": {"wrapped": ["tsc"]}, "wrappers": {}}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		originalWd, _ := os.Getwd()
+		if err := os.Chdir(project); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.Chdir(originalWd) })
+		if got := requiringConfig("tsc"); got != configPath {
+			t.Errorf("requiringConfig(tsc) = %q, want %s", got, configPath)
+		}
+		if got := requiringConfig("npm"); got != "" {
+			t.Errorf("requiringConfig(npm) = %q, want none", got)
+		}
+		if nudge := requireNudge("tsc", filepath.Join(binDir, "tsc"), configPath); !strings.Contains(nudge, "Run 'ribbin wrap' in "+project) {
+			t.Errorf("nudge doesn't say where to run ribbin wrap:\n%s", nudge)
+		}
+	})
+}
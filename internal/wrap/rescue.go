@@ -0,0 +1,46 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// RIBBIN_RESCUE=1 gets a machine back when a bad config blocks something
+// essential. Unlike RIBBIN_BYPASS nothing turns it off, not even
+// enforcement, so wrappers check it before reading the registry or any
+// config. Every use is logged, and under enforcement it is also logged as a
+// security violation and announced as overriding it.
+
+// rescueEnvVar makes every wrapper run its command unwrapped
+const rescueEnvVar = "RIBBIN_RESCUE"
+
+// rescuing reports whether RIBBIN_RESCUE=1 is set
+func rescuing() bool {
+	return os.Getenv(rescueEnvVar) == "1"
+}
+
+// logRescue records in the audit log that cmdName runs unwrapped for
+// RIBBIN_RESCUE, and says so on stderr
+func logRescue(cmdName, originalPath string, args []string) {
+	details := map[string]string{
+		"command": cmdName,
+		"args":    strings.Join(args, " "),
+		"pid":     fmt.Sprintf("%d", os.Getpid()),
+	}
+	enforcedBy := EnforcedBy()
+	if enforcedBy != "" {
+		details["enforced_by"] = enforcedBy
+		security.LogSecurityViolation("rescue_while_enforced", originalPath, map[string]string{
+			"command":     cmdName,
+			"enforced_by": enforcedBy,
+			"pid":         details["pid"],
+		})
+		fmt.Fprintf(os.Stderr, "ribbin: RIBBIN_RESCUE=1 overrides enforcement by %s: running '%s' unwrapped, and logging it as a security violation\n", enforcedBy, cmdName)
+	} else {
+		fmt.Fprintf(os.Stderr, "ribbin: RIBBIN_RESCUE=1: running '%s' unwrapped, and logging it to the audit log\n", cmdName)
+	}
+	security.LogRescueUsage(originalPath, details)
+}
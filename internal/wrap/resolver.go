@@ -6,10 +6,15 @@ import (
 	"path/filepath"
 )
 
-// ResolveCommand finds the path to a command using exec.LookPath.
+// ResolveCommand finds the path to a command using exec.LookPath, passing
+// over the sentinels of required commands (see SentinelDir).
 // Returns the absolute path to the command or an error if not found.
 func ResolveCommand(name string) (string, error) {
-	return exec.LookPath(name)
+	path, err := exec.LookPath(name)
+	if err == nil && IsSentinel(path) {
+		return lookPathSkipping(name, filepath.Dir(path))
+	}
+	return path, err
 }
 
 // ResolveCommands resolves multiple command names to their paths.
@@ -27,7 +32,8 @@ func ResolveCommands(names []string) map[string]string {
 }
 
 // IsAlreadyShimmed checks if the binary at the given path is a symlink
-// pointing to ribbin. Returns true if the binary is already shimmed.
+// pointing to ribbin, or a copy of ribbin made in the copy shim mode (see
+// IsCopyShim). Returns true if the binary is already shimmed.
 func IsAlreadyShimmed(path string) (bool, error) {
 	// Check if path is a symlink using os.Lstat
 	info, err := os.Lstat(path)
@@ -37,7 +43,7 @@ func IsAlreadyShimmed(path string) (bool, error) {
 
 	// Check if it's a symlink
 	if info.Mode()&os.ModeSymlink == 0 {
-		return false, nil
+		return IsCopyShim(path), nil
 	}
 
 	// Read the symlink target using os.Readlink (not SafeReadlink)
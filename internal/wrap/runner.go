@@ -1,146 +1,313 @@
 package wrap
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
-	"syscall"
+	"time"
 
 	"github.com/happycollision/ribbin/internal/config"
 	"github.com/happycollision/ribbin/internal/process"
 	"github.com/happycollision/ribbin/internal/security"
 )
 
-// findSidecar attempts to locate the .ribbin-original sidecar file.
-// It checks multiple locations in order:
-// 1. Next to argv0 (e.g., if argv0 is "/path/to/tsc", checks "/path/to/tsc.ribbin-original")
-// 2. Resolved to absolute path if argv0 is relative
-// 3. Next to the executable (for dual-sidecar support with symlink chains)
-// 4. Registry lookup (handles cases where argv0 doesn't match wrapped location)
-// Returns the path to the sidecar, or empty string if not found.
-func findSidecar(argv0 string) string {
-	cmdName := filepath.Base(argv0)
-
-	// Strategy 1: Check next to argv0
-	sidecarPath := argv0 + ".ribbin-original"
-	if _, err := os.Stat(sidecarPath); err == nil {
-		return sidecarPath
-	}
-
-	// Strategy 2: If argv0 is relative or just a command name, resolve to absolute
-	if !filepath.IsAbs(argv0) {
-		if absPath, err := filepath.Abs(argv0); err == nil {
-			sidecarPath = absPath + ".ribbin-original"
-			if _, err := os.Stat(sidecarPath); err == nil {
-				return sidecarPath
-			}
-		}
-	}
-
-	// Strategy 3: Check next to the executable (for symlink chains)
-	if exePath, err := os.Executable(); err == nil {
-		exeDir := filepath.Dir(exePath)
-		sidecarPath = filepath.Join(exeDir, cmdName+".ribbin-original")
-		if _, err := os.Stat(sidecarPath); err == nil {
-			return sidecarPath
-		}
-	}
-
-	// Strategy 4: Look up in registry to find where this command was wrapped
-	// This handles cases like `pnpm exec tsc` where argv0 doesn't match the wrapped location
-	if registry, err := config.LoadRegistry(); err == nil {
-		if entry, ok := registry.Wrappers[cmdName]; ok {
-			sidecarPath = entry.Original + ".ribbin-original"
-			if _, err := os.Stat(sidecarPath); err == nil {
-				return sidecarPath
-			}
-		}
-	}
-
-	return ""
-}
-
 // Run is the main entry point for shim mode.
 // argv0 is the path to the symlink (e.g., /usr/local/bin/cat)
 // args are the command-line arguments (os.Args[1:])
 func Run(argv0 string, args []string) error {
+	// A sentinel of a required command isn't a wrapper; it runs the command
+	// it stands in for
+	if IsSentinel(argv0) {
+		return runSentinel(argv0, args)
+	}
+
 	// 1. Find the sidecar file
-	// It could be at argv0 + ".ribbin-original" OR next to the actual executable
-	sidecarPath := findSidecar(argv0)
+	// It could be at argv0 + ".ribbin-original", next to the actual
+	// executable, or wherever a package manager ran the wrapper from
+	sidecarPath, tried := locateSidecar(argv0)
 	if sidecarPath == "" {
-		return fmt.Errorf("original binary not found (no .ribbin-original sidecar found)")
+		if isRenamedRibbin(argv0) {
+			return &RenamedRibbinError{Name: filepath.Base(argv0)}
+		}
+		return &SidecarNotFoundError{Command: filepath.Base(argv0), Tried: tried}
 	}
 
-	// 2. Use sidecar as original path (may be a symlink, which is fine)
+	// 2. Use sidecar as original path (may be a symlink, which is fine),
+	// except that a shadowed binary runs at its own path, as a signed app's
+	// binary must
 	originalPath := sidecarPath
+	if shadowed := ShadowedBinary(BinaryForSidecar(sidecarPath)); shadowed != "" {
+		originalPath = shadowed
+	}
 
 	// Extract command name from argv0 (needed for verbose logging)
 	cmdName := extractCommandName(argv0)
+	startTrace(argv0, args, cmdName, originalPath)
+
+	// RIBBIN_RESCUE=1 -> passthrough, always, before anything a bad registry
+	// or config could break
+	if rescuing() {
+		traceStep("RIBBIN_RESCUE", "set")
+		logRescue(cmdName, originalPath, args)
+		verboseLogDecision(cmdName, "PASS", "RIBBIN_RESCUE=1")
+		return execOriginal(originalPath, args)
+	}
+
+	// 3. Optionally refresh a sidecar an upgrade has rewritten (RIBBIN_AUTO_HEAL=1)
+	if os.Getenv("RIBBIN_AUTO_HEAL") == "1" {
+		autoHeal(sidecarPath, cmdName)
+	}
 
-	// 4. Check RIBBIN_BYPASS=1 -> passthrough
+	// 4. Check RIBBIN_BYPASS=1 -> passthrough, unless bypasses are disabled
 	if os.Getenv("RIBBIN_BYPASS") == "1" {
-		// Log bypass usage
-		security.LogBypassUsage(originalPath, os.Getpid())
-		verboseLogDecision(cmdName, "PASS", "RIBBIN_BYPASS=1")
-		return execOriginal(originalPath, args)
+		if enforcement = EnforcedBy(); enforcement != "" {
+			traceStep("RIBBIN_BYPASS", "set, ignored (enforced by %s)", enforcement)
+			refuseBypass(cmdName, originalPath, "RIBBIN_BYPASS=1", enforcement)
+		} else {
+			traceStep("RIBBIN_BYPASS", "set")
+			// Log bypass usage
+			security.LogBypassUsage(originalPath, os.Getpid())
+			verboseLogDecision(cmdName, "PASS", "RIBBIN_BYPASS=1")
+			return execOriginal(originalPath, args)
+		}
+	} else {
+		traceStep("RIBBIN_BYPASS", "not set")
 	}
 
-	// 4. Load registry
-	registry, err := config.LoadRegistry()
+	// 4. Load registry. A corrupt one is moved aside, so this and later
+	// commands run unwrapped rather than failing on it.
+	registry, err := config.LoadRegistryReadOnly()
+	if errors.Is(err, config.ErrRegistryCorrupt) {
+		traceStep("registry", "corrupt: %v", err)
+		warnCorruptRegistry(cmdName)
+		verboseLogDecision(cmdName, "PASS", "registry corrupt")
+		return execOriginal(originalPath, args)
+	}
 	if err != nil {
 		// If we can't load registry, passthrough
+		traceStep("registry", "cannot load: %v", err)
 		verboseLogDecision(cmdName, "PASS", "registry not found")
 		return execOriginal(originalPath, args)
 	}
 
-	// 5. Find nearest ribbin.jsonc (needed for activation check)
-	configPath, err := config.FindProjectConfig()
-	if err != nil || configPath == "" {
-		// No config found -> passthrough
-		verboseLogDecision(cmdName, "PASS", "no ribbin.jsonc found")
+	// 5-8. Find the config and the wrapper for this command in the current directory
+	cwd, _ := os.Getwd()
+	lookup := lookupWrapper(registry, cwd, cmdName)
+	traceRegistry(registry, cmdName, BinaryForSidecar(sidecarPath))
+	traceLookup(registry, lookup)
+	if !lookup.Exists {
+		if len(registry.Wrappers) == 0 && config.RegistryQuarantined() {
+			fmt.Fprintf(os.Stderr, "ribbin: the registry was quarantined as corrupt, so '%s' runs unwrapped; run 'ribbin registry repair'\n", cmdName)
+		}
+		verboseLogDecision(cmdName, "PASS", lookup.Reason)
 		return execOriginal(originalPath, args)
 	}
+	configPath := lookup.ConfigPath
+	shimConfig := lookup.Shim
+
+	// An expired wrapper takes its expiredAction instead of its own
+	var expiredOn string
+	if shimConfig.Expired(time.Now()) {
+		expiredOn = shimConfig.Expires
+		traceStep("expires", "expired on %s, acting as %q", shimConfig.Expires, shimConfig.ActionAfterExpiry())
+		shimConfig = shimConfig.Effective(time.Now())
+	} else if shimConfig.Expires != "" {
+		traceStep("expires", "not until %s", shimConfig.Expires)
+	}
 
-	// 6. Check if active using three-tier activation model
-	if !isActive(registry, configPath) {
-		verboseLogDecision(cmdName, "PASS", "ribbin not active")
-		return execOriginal(originalPath, args)
+	// An argRule matching the invocation replaces the wrapper's action,
+	// unless the whole wrapper has expired
+	if len(shimConfig.ArgRules) > 0 && expiredOn == "" {
+		if rule, ok := shimConfig.MatchArgRules(cmdName, args); ok {
+			traceStep("argRules", "%s matches, acting as %q", rule, rule.Action)
+			shimConfig = shimConfig.WithArgRule(rule)
+		} else {
+			traceStep("argRules", "none match")
+		}
 	}
 
-	// 7. Load project config
-	projectConfig, err := config.LoadProjectConfig(configPath)
-	if err != nil {
-		// Can't load config -> passthrough
-		verboseLogDecision(cmdName, "PASS", fmt.Sprintf("config load failed: %v", err))
-		return execOriginal(originalPath, args)
+	// 8a. A wrapper limited by argPathPatterns only applies when an argument names a matching file
+	if len(shimConfig.ArgPathPatterns) > 0 {
+		arg, ok := shimConfig.MatchArgPaths(args, filepath.Dir(configPath), cwd)
+		if !ok {
+			traceStep("argPathPatterns", "no argument matches %s", strings.Join(shimConfig.ArgPathPatterns, ", "))
+			verboseLogDecision(cmdName, "PASS", "no argument matches argPathPatterns")
+			return execOriginal(originalPath, args)
+		}
+		traceStep("argPathPatterns", "%q matches", arg)
 	}
 
-	// 8. Determine effective shims based on scope matching
-	shimConfig, exists := getEffectiveShimConfig(projectConfig, configPath, cmdName)
-	if !exists {
-		// Command not in config -> passthrough
-		verboseLogDecision(cmdName, "PASS", "no shim configured")
-		return execOriginal(originalPath, args)
+	// 8b. A wrapper with a schedule only applies at the times it lists
+	if shimConfig.When != nil && len(shimConfig.When.Schedule) > 0 {
+		entry, ok := shimConfig.Scheduled(time.Now())
+		if !ok {
+			traceStep("schedule", "no entry matches %s", scheduleClock(shimConfig.When))
+			verboseLogDecision(cmdName, "PASS", "outside the wrapper's schedule")
+			return execOriginal(originalPath, args)
+		}
+		traceStep("schedule", "%q matches %s", entry, scheduleClock(shimConfig.When))
+	}
+
+	metricsCommand, metricsConfig = cmdName, configPath
+	if shimConfig.Exec != "" {
+		execMode = shimConfig.Exec
+	}
+	execEnv = shimConfig.Env
+	execEnvUnset, execEnvAllowOnly = shimConfig.EnvUnset, shimConfig.EnvAllowOnly
+	redirectMode = shimConfig.RedirectMode
+	redirectOnFailure = shimConfig.RedirectOnFailure
+	execHooks = hookContext{
+		Hooks:      shimConfig.Hooks,
+		Command:    cmdName,
+		Original:   originalPath,
+		ConfigPath: configPath,
+		Args:       args,
+	}
+	execTimeout = timeoutFor(shimConfig, config.MessageVars{Command: cmdName, Args: args, ConfigPath: configPath, Dir: cwd})
+	if execTimeout.Limit > 0 {
+		traceStep("timeout", "%s, then SIG%s", shimConfig.Timeout, shimConfig.TimeoutSignalName())
+	}
+	if hooks := shimConfig.Hooks; hooks != nil && (hooks.Before != "" || hooks.After != "") {
+		traceStep("hooks", "before %q, after %q", hooks.Before, hooks.After)
+	}
+	if len(shimConfig.Env) > 0 {
+		traceStep("env", "sets %s", strings.Join(sortedEnvNames(shimConfig.Env), ", "))
+	}
+	if shimConfig.ScrubsEnv() {
+		traceStep("env", "%s", describeEnvScrub(shimConfig))
+	}
+
+	// 8c. Refuse to run an original that changed since it was wrapped, if the wrapper asks
+	if err := VerifySidecar(BinaryForSidecar(sidecarPath), shimConfig.Verify); err != nil {
+		verboseLogDecision(cmdName, "BLOCKED", fmt.Sprintf("verify %s failed: %v", shimConfig.Verify, err))
+		security.LogSecurityViolation("sidecar_verification_failed", sidecarPath, map[string]string{
+			"command": cmdName,
+			"policy":  shimConfig.Verify,
+			"error":   err.Error(),
+		})
+		traceStep("verify", "%s check failed: %v", shimConfig.Verify, err)
+		printTamperWarning(cmdName, sidecarPath, err)
+		os.Exit(1)
+		return nil // unreachable, but satisfies compiler
+	}
+
+	if shimConfig.Verify != "" && shimConfig.Verify != config.VerifyNone {
+		traceStep("verify", "%s check passed", shimConfig.Verify)
+	}
+
+	// 8d. Refuse to run an original whose version is out of policy
+	if vc := shimConfig.VersionCheck; vc != nil && shimConfig.Action != "block" {
+		version, err := checkVersion(originalPath, cmdName, vc)
+		if err != nil {
+			traceStep("versionCheck", "%v", err)
+			if vc.Blocks() {
+				enforcement = EnforcedBy()
+				explainDecision("BLOCKED", fmt.Sprintf("version check failed: %v", err))
+			}
+			printVersionPolicy(cmdName, vc, err)
+			if vc.Blocks() {
+				showOnboarding(configPath)
+				verboseLogDecision(cmdName, "BLOCKED", fmt.Sprintf("version check failed: %v", err))
+				os.Exit(1)
+				return nil // unreachable, but satisfies compiler
+			}
+		} else {
+			traceStep("versionCheck", "version %s is within policy", version)
+			verboseLog("version %s of %s is within policy", version, cmdName)
+		}
 	}
 
 	// 9. Check passthrough conditions
 	if shimConfig.Passthrough != nil {
 		if shouldPassthrough(shimConfig.Passthrough) {
+			traceStep("passthrough", "an ancestor matches")
 			verboseLogDecision(cmdName, "PASS", "parent process matched passthrough rule")
 			return execOriginal(originalPath, args)
 		}
+		traceStep("passthrough", "no ancestor matches")
+	}
+
+	// 10. Check for a snooze (time-boxed bypass via 'ribbin snooze'), unless
+	// bypasses are disabled
+	if snooze, ok := registry.ActiveSnooze(cmdName, time.Now()); ok {
+		remaining := snooze.Remaining(time.Now())
+		if enforcement = EnforcedBy(); enforcement != "" {
+			traceStep("snooze", "%s left, ignored (enforced by %s)", remaining, enforcement)
+			refuseBypass(cmdName, originalPath, "snooze", enforcement)
+		} else {
+			traceStep("snooze", "%s left", remaining)
+			verboseLogDecision(cmdName, "PASS", fmt.Sprintf("snoozed (%s left)", remaining))
+			fmt.Fprintf(os.Stderr, "ribbin: '%s' is snoozed (%s left), running original. Run 'ribbin snooze --clear' to re-enable.\n", cmdName, remaining)
+			return execOriginal(originalPath, args)
+		}
+	} else {
+		traceStep("snooze", "none")
 	}
 
-	// 10. Handle action based on config
+	// 10a. A wrapper with onlyFrom blocks unless one of those commands ran it
+	if len(shimConfig.OnlyFrom) > 0 {
+		caller, ok := allowedCaller(shimConfig.OnlyFrom)
+		if !ok {
+			traceStep("onlyFrom", "no ancestor is %s", strings.Join(shimConfig.OnlyFrom, ", "))
+			enforcement = EnforcedBy()
+			explainDecision("BLOCKED", onlyFromMessage(cmdName, shimConfig.OnlyFrom))
+			message := blockMessage(shimConfig, cmdName, args, configPath, cwd)
+			if message == "" {
+				message = onlyFromMessage(cmdName, shimConfig.OnlyFrom)
+			}
+			printBlockMessage(cmdName, message)
+			showOnboarding(configPath)
+			verboseLogDecision(cmdName, "BLOCKED", "not run by an onlyFrom command")
+			os.Exit(1)
+			return nil // unreachable, but satisfies compiler
+		}
+		traceStep("onlyFrom", "run by %s", caller)
+	}
+
+	// 11. Handle action based on config
 	switch shimConfig.Action {
 	case "block":
-		verboseLogDecision(cmdName, "BLOCKED", shimConfig.Message)
-		printBlockMessage(cmdName, shimConfig.Message)
+		message := blockMessage(shimConfig, cmdName, args, configPath, cwd)
+		enforcement = EnforcedBy()
+		explainDecision("BLOCKED", "action \"block\"")
+		// A command blocked again and again, as by a retrying build script,
+		// gets the full message once per interval and one line in between
+		if ago, recent := recentBlockMessage(cmdName, time.Now(), blockMessageInterval()); recent {
+			traceStep("blockMessage", "full message shown %s ago, printing a reminder", ago.Round(time.Second))
+			printBlockReminder(cmdName, message, ago)
+		} else {
+			printBlockMessage(cmdName, message)
+		}
+		showOnboarding(configPath)
+		if shimConfig.InteractiveOverride {
+			switch {
+			case enforcement != "":
+				traceStep("interactiveOverride", "not offered (enforced by %s)", enforcement)
+			case offerOverride():
+				traceStep("interactiveOverride", "taken")
+				logOverride(cmdName, originalPath, configPath, args)
+				verboseLogDecision(cmdName, "PASS", "interactive override")
+				return execOriginal(originalPath, args)
+			default:
+				traceStep("interactiveOverride", "not taken, or no terminal")
+			}
+		}
+		verboseLogDecision(cmdName, "BLOCKED", message)
 		os.Exit(1)
 		return nil // unreachable, but satisfies compiler
 
+	case "warn":
+		message := blockMessage(shimConfig, cmdName, args, configPath, cwd)
+		printWarnMessage(cmdName, message, expiredOn)
+		verboseLogDecision(cmdName, "PASS", "warn action")
+		return execOriginal(originalPath, args)
+
 	case "passthrough":
 		// Explicit passthrough action - execute original binary
 		verboseLogDecision(cmdName, "PASS", "explicit passthrough action")
@@ -148,12 +315,47 @@ func Run(argv0 string, args []string) error {
 
 	case "redirect":
 		// Validate redirect field is not empty
-		if shimConfig.Redirect == "" {
+		if !shimConfig.HasRedirect() {
 			verboseLogDecision(cmdName, "PASS", "redirect action but no script configured")
 			fmt.Fprintf(os.Stderr, "ribbin: redirect action specified but no redirect script configured for '%s', using original\n", cmdName)
 			return execOriginal(originalPath, args)
 		}
 
+		// A target built into ribbin runs in this process: no hooks, no
+		// timeout, and nothing further to redirect
+		if name, ok := shimConfig.BuiltinRedirect(); ok {
+			message := blockMessage(shimConfig, cmdName, args, configPath, cwd)
+			return runBuiltinRedirect(name, cmdName, originalPath, configPath, message, args)
+		}
+
+		// Refuse to redirect a chain of redirects this long, most likely a
+		// loop, rather than run anything (see checkRedirectDepth)
+		depth, maxDepth, err := checkRedirectDepth(cmdName)
+		if err != nil {
+			traceStep("depth", "%v", err)
+			explainDecision("BLOCKED", "too many nested redirects")
+			verboseLogDecision(cmdName, "BLOCKED", err.Error())
+			fmt.Fprintf(os.Stderr, "ribbin: %v\n", err)
+			os.Exit(RedirectDepthExitCode)
+			return nil // unreachable, but satisfies compiler
+		}
+		traceStep("depth", "%d redirects deep, at most %d", depth, maxDepth)
+		redirectDepth = depth
+
+		// Inline command: build argv from the template and exec it directly
+		if shimConfig.IsInlineRedirect() {
+			argv, err := buildInlineRedirect(shimConfig, originalPath, args, configPath)
+			if err != nil {
+				// Fail-open: warn and passthrough
+				verboseLogDecision(cmdName, "PASS", fmt.Sprintf("redirect failed: %v", err))
+				fmt.Fprintf(os.Stderr, "ribbin: redirect failed (%s), using original: %v\n", cmdName, err)
+				return execOriginal(originalPath, args)
+			}
+
+			verboseLogDecision(cmdName, "REDIRECT", strings.Join(argv, " "))
+			return execRedirectArgv(argv, originalPath, cmdName, configPath)
+		}
+
 		// Resolve redirect script path
 		scriptPath, err := resolveRedirectScript(shimConfig.Redirect, configPath)
 		if err != nil {
@@ -174,63 +376,481 @@ func Run(argv0 string, args []string) error {
 	}
 }
 
-// isActive checks if ribbin is active using three-tier activation priority:
+// wrapperLookup is the wrapper that applies to a command in a directory, if any
+type wrapperLookup struct {
+	ConfigPath string
+	Shim       config.ShimConfig
+	// Exists is false if the original should run as is, for Reason
+	Exists bool
+	Reason string
+	// Cached is true if the decision cache answered the lookup
+	Cached bool
+}
+
+// warnCorruptRegistry quarantines the corrupt registry and prints the one
+// line saying cmdName runs unwrapped because of it
+func warnCorruptRegistry(cmdName string) {
+	moved, err := config.QuarantineRegistry()
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "ribbin: the registry is corrupt and can't be moved aside (%v), so '%s' runs unwrapped; run 'ribbin registry repair'\n", err, cmdName)
+	case moved != "":
+		fmt.Fprintf(os.Stderr, "ribbin: the registry is corrupt, so '%s' runs unwrapped; moved it to %s, run 'ribbin registry repair'\n", cmdName, moved)
+	default:
+		fmt.Fprintf(os.Stderr, "ribbin: the registry was corrupt, so '%s' runs unwrapped; run 'ribbin registry repair'\n", cmdName)
+	}
+}
+
+// lookupWrapper finds the wrapper for cmdName in cwd: the nearest config,
+// whether ribbin is active for it, and the effective wrapper after scope
+// matching, with the user config's wrappers beneath. Where ribbin isn't
+// active for the project config (or there is none) but is for the user
+// config, only the user config's wrappers apply. This is the per-invocation
+// decision cost of a wrapper.
+func lookupWrapper(registry *config.Registry, cwd, cmdName string) wrapperLookup {
+	// 5. Find nearest ribbin.jsonc (needed for activation check). A cached
+	// decision for this directory answers this and step 8 without reading
+	// any config, as long as nothing it was computed from has changed.
+	d := lookupDecision(cwd)
+	cached := d != nil
+	var configPath, userPath string
+	var err error
+	if cached {
+		configPath, userPath = d.ConfigPath, d.UserConfigPath
+	} else {
+		if configPath, err = config.FindProjectConfig(); err != nil {
+			configPath = ""
+		}
+		if userPath, err = config.FindUserConfig(); err != nil {
+			userPath = ""
+		}
+	}
+	if configPath == "" && userPath == "" {
+		// No config found -> passthrough
+		if !cached {
+			if d, err := resolveDecision(cwd, "", nil); err == nil {
+				storeDecision(d)
+			}
+		}
+		return wrapperLookup{Reason: "no ribbin.jsonc found"}
+	}
+
+	// 6. Check if active using three-tier activation model, falling back to
+	// the user config's own activation
+	governing, act, userOnly := governingActivation(registry, configPath, userPath)
+	if act.Reason == "" {
+		return wrapperLookup{ConfigPath: governing, Reason: "ribbin not active"}
+	}
+
+	lookup := wrapperLookup{ConfigPath: governing, Reason: "no shim configured", Cached: cached}
+	if !cached {
+		// 7. Load project config
+		var projectConfig *config.ProjectConfig
+		if configPath != "" {
+			if projectConfig, err = config.LoadProjectConfig(configPath); err != nil {
+				// Can't load config -> passthrough
+				return wrapperLookup{ConfigPath: configPath, Reason: fmt.Sprintf("config load failed: %v", err)}
+			}
+		}
+
+		// 8. Determine effective shims based on scope matching
+		if d, err = resolveDecision(cwd, configPath, projectConfig); err == nil {
+			storeDecision(d)
+		} else if projectConfig != nil && !userOnly {
+			// Resolution failed: fall back to root wrappers, uncached
+			lookup.Shim, lookup.Exists = getEffectiveShimConfig(projectConfig, configPath, cmdName)
+		}
+	}
+	if d != nil {
+		if userOnly {
+			lookup.Shim, lookup.Exists = d.UserWrappers[cmdName]
+		} else {
+			lookup.Shim, lookup.Exists = d.Wrappers[cmdName]
+		}
+	}
+
+	// An activation limited to tags or scopes leaves other wrappers inactive
+	var scopes map[string]string
+	if d != nil {
+		scopes = d.Scopes
+	}
+	if lookup.Exists && !act.covers(lookup.Shim, scopes) {
+		lookup.Exists = false
+		lookup.Reason = act.uncoveredReason()
+	}
+	return lookup
+}
+
+// autoHeal refreshes the metadata of a wrapped binary whose original was
+// rewritten by a package manager upgrade. Only the sidecar-changed case can be
+// healed from here: if the upgrade replaced the shim itself, ribbin is no
+// longer invoked and 'ribbin heal' has to be run instead. Failures are
+// reported but never block the command.
+func autoHeal(sidecarPath, cmdName string) {
+	binaryPath := BinaryForSidecar(sidecarPath)
+	if hasConflict, _, _ := CheckHashConflict(binaryPath); !hasConflict {
+		return
+	}
+
+	// Never accept a changed original on behalf of a wrapper that verifies it
+	if wrapperVerifies(cmdName, binaryPath) {
+		return
+	}
+
+	meta, err := LoadMetadata(binaryPath)
+	if err != nil {
+		return
+	}
+	if err := refreshSidecar(binaryPath, meta.RibbinPath, false); err != nil {
+		fmt.Fprintf(os.Stderr, "ribbin: '%s' was upgraded but auto-heal failed: %v\n", cmdName, err)
+		return
+	}
+	verboseLog("auto-healed %s: sidecar changed since wrap, metadata refreshed", binaryPath)
+}
+
+// wrapperVerifies reports whether the config that wrapped cmdName at
+// binaryPath sets a verify policy for it, in the root wrappers or any scope.
+// Errors count as verifying, so auto-heal errs on the side of leaving
+// metadata alone.
+func wrapperVerifies(cmdName, binaryPath string) bool {
+	registry, err := config.LoadRegistryReadOnly()
+	if err != nil {
+		return true
+	}
+	entry, ok := registry.LookupWrapper(cmdName, binaryPath)
+	if !ok || entry.Config == "" {
+		return false
+	}
+	projectConfig, err := config.LoadProjectConfig(entry.Config)
+	if err != nil {
+		return true
+	}
+
+	verifies := func(w config.WrapperConfig, ok bool) bool {
+		return ok && w.Verify != "" && w.Verify != config.VerifyNone
+	}
+	if w, ok := projectConfig.Wrappers[cmdName]; verifies(w, ok) {
+		return true
+	}
+	for _, scope := range projectConfig.Scopes {
+		if w, ok := scope.Wrappers[cmdName]; verifies(w, ok) {
+			return true
+		}
+	}
+	return false
+}
+
+// printTamperWarning explains why a verified original was not run
+func printTamperWarning(cmdName, sidecarPath string, err error) {
+	fmt.Fprintf(os.Stderr, "ribbin: refusing to run '%s': %v\n", cmdName, err)
+	fmt.Fprintf(os.Stderr, "  Original: %s\n", sidecarPath)
+	if errors.Is(err, ErrSidecarModified) {
+		fmt.Fprintf(os.Stderr, "  If this is an upgrade you trust, run 'ribbin heal %s' to accept it.\n", cmdName)
+	} else {
+		fmt.Fprintf(os.Stderr, "  Run 'ribbin unwrap' and 'ribbin wrap' to record it again.\n")
+	}
+}
+
+// activation is what makes ribbin active for a config (see activationFor)
+type activation struct {
+	// Reason names the activation tier, or is "" if ribbin is not active
+	Reason string
+	// Tags limits the activation to wrappers carrying one of them (see
+	// 'ribbin activate --tag'). Empty means every wrapper is active.
+	Tags []string
+	// Scopes limits the activation to directories in the named scopes of a
+	// config (see 'ribbin activate --scope'). A wrapper is active if it
+	// passes either limit.
+	Scopes []scopeLimit
+}
+
+// scopeLimit is an activation of a config limited to some of its scopes
+type scopeLimit struct {
+	Config string
+	Names  []string
+}
+
+// covers reports whether the activation makes shim active in a directory
+// where scopes name the matching scope of each config (see matchedScopes)
+func (a activation) covers(shim config.ShimConfig, scopes map[string]string) bool {
+	if a.Reason == "" {
+		return false
+	}
+	if len(a.Tags) == 0 && len(a.Scopes) == 0 {
+		return true
+	}
+	if len(a.Tags) > 0 && shim.HasAnyTag(a.Tags) {
+		return true
+	}
+	for _, limit := range a.Scopes {
+		if name, ok := scopes[limit.Config]; ok && slices.Contains(limit.Names, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// coversSomewhere reports whether the activation makes shim active in at
+// least some directories, so an activation limited to scopes counts
+func (a activation) coversSomewhere(shim config.ShimConfig) bool {
+	return a.Reason != "" && (len(a.Scopes) > 0 || a.covers(shim, nil))
+}
+
+// String describes the activation, including the tags and scopes it is
+// limited to
+func (a activation) String() string {
+	if len(a.Tags) == 0 && len(a.Scopes) == 0 {
+		return a.Reason
+	}
+	return fmt.Sprintf("%s, %s", a.Reason, a.limits())
+}
+
+// uncoveredReason explains why a wrapper the activation doesn't cover passes through
+func (a activation) uncoveredReason() string {
+	return fmt.Sprintf("activation is limited to %s", a.limits())
+}
+
+// limits describes the tags and scopes the activation is limited to
+func (a activation) limits() string {
+	var parts []string
+	if len(a.Tags) > 0 {
+		parts = append(parts, "tags "+strings.Join(a.Tags, ", "))
+	}
+	var names []string
+	for _, limit := range a.Scopes {
+		names = mergeTags(names, limit.Names)
+	}
+	if len(names) > 0 {
+		parts = append(parts, "scopes "+strings.Join(names, ", "))
+	}
+	return strings.Join(parts, " or ")
+}
+
+// isActive checks if ribbin is active using four-tier activation priority:
 // Priority 1: GlobalActive - fires everything everywhere
 // Priority 2: ShellActivations - all configs fire for descendant processes
-// Priority 3: ConfigActivations - specific config fires for all shells
+// Priority 3: RIBBIN_ACTIVATE - listed configs fire wherever it is set
+// Priority 4: ConfigActivations - specific config fires for all shells
 func isActive(registry *config.Registry, configPath string) bool {
+	return activationFor(registry, configPath).Reason != ""
+}
+
+// activationReason describes which activation tier makes ribbin active for
+// configPath (see isActive), or returns "" if it is not active.
+func activationReason(registry *config.Registry, configPath string) string {
+	return activationFor(registry, configPath).String()
+}
+
+// activationFor returns what makes ribbin active for configPath (see
+// isActive). An activation of every wrapper wins over ones limited to tags
+// or scopes; limited activations combine.
+func activationFor(registry *config.Registry, configPath string) activation {
 	// Priority 1: Global overrides everything
 	if registry.GlobalActive {
-		return true
+		return activation{Reason: "global activation"}
+	}
+
+	var tagged activation
+	consider := func(reason string, tags []string, scopes scopeLimit) bool {
+		if len(tags) == 0 && len(scopes.Names) == 0 {
+			tagged = activation{Reason: reason}
+			return true
+		}
+		if tagged.Reason == "" {
+			tagged.Reason = reason
+		}
+		if len(tags) > 0 {
+			tagged.Tags = mergeTags(tagged.Tags, tags)
+		}
+		if len(scopes.Names) > 0 {
+			tagged.Scopes = append(tagged.Scopes, scopes)
+		}
+		return false
 	}
 
 	// Priority 2: Shell activation (any config fires for descendants)
 	registry.PruneDeadShellActivations()
+	pids := make([]int, 0, len(registry.ShellActivations))
 	for pid := range registry.ShellActivations {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	for _, pid := range pids {
 		isDescendant, err := process.IsDescendantOf(pid)
 		if err == nil && isDescendant {
-			return true
+			if consider(fmt.Sprintf("shell activation (PID %d)", pid), registry.ShellActivations[pid].Tags, scopeLimit{}) {
+				return tagged
+			}
+		}
+	}
+
+	// Priority 3: Environment activation (RIBBIN_ACTIVATE, as 'ribbin
+	// direnv' sets while the shell is in a project), of this config or of a
+	// parent config it merges with
+	if configPath != "" && envActivates(configPath) {
+		if consider("environment activation ("+ActivateEnv+")", envActivationTags(), scopeLimit{}) {
+			return tagged
 		}
 	}
 
-	// Priority 3: Config-specific activation
+	// Priority 4: Config-specific activation, of this config or of a parent
+	// config it merges with ("root": false)
 	if configPath != "" {
-		if _, ok := registry.ConfigActivations[configPath]; ok {
-			return true
+		if entry, ok := registry.ConfigActivation(configPath); ok {
+			if consider("config activation", entry.Tags, scopeLimit{Config: configPath, Names: entry.Scopes}) {
+				return tagged
+			}
+		}
+		chain, _ := config.ConfigChain(configPath)
+		for _, parent := range chain[1:] {
+			if entry, ok := registry.ConfigActivation(parent); ok {
+				if consider(fmt.Sprintf("config activation of %s", parent), entry.Tags, scopeLimit{Config: parent, Names: entry.Scopes}) {
+					return tagged
+				}
+			}
 		}
 	}
 
+	return tagged
+}
+
+// envActivates reports whether RIBBIN_ACTIVATE lists configPath, or a
+// parent config it merges with
+func envActivates(configPath string) bool {
+	value := os.Getenv(ActivateEnv)
+	if value == "" {
+		return false
+	}
+	activated := filepath.SplitList(value)
+	if slices.Contains(activated, configPath) {
+		return true
+	}
+	chain, _ := config.ConfigChain(configPath)
+	for _, parent := range chain[1:] {
+		if slices.Contains(activated, parent) {
+			return true
+		}
+	}
 	return false
 }
 
-// execOriginal uses syscall.Exec to replace the current process with the original command
+// envActivationTags returns the tags RIBBIN_ACTIVATE_TAGS limits an
+// environment activation to
+func envActivationTags() []string {
+	var tags []string
+	for _, tag := range strings.Split(os.Getenv(ActivateTagsEnv), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// governingActivation returns the config whose activation applies in a
+// directory governed by configPath (or no project config, if "") with the
+// user config at userPath (or none, if ""), and that activation. When ribbin
+// is only active for the user config, userOnly is true and only the user
+// config's wrappers apply. act.Reason is "" if ribbin isn't active.
+func governingActivation(registry *config.Registry, configPath, userPath string) (governing string, act activation, userOnly bool) {
+	if configPath != "" {
+		if act = activationFor(registry, configPath); act.Reason != "" || userPath == "" {
+			return configPath, act, false
+		}
+	}
+	if userPath == "" {
+		return "", activation{}, false
+	}
+	if act = activationFor(registry, userPath); act.Reason == "" {
+		if configPath != "" {
+			return configPath, act, false
+		}
+		return userPath, act, false
+	}
+	return userPath, act, true
+}
+
+// mergeTags returns the sorted union of two tag lists
+func mergeTags(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var merged []string
+	for _, tag := range append(append([]string{}, a...), b...) {
+		if !seen[tag] {
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// execOriginal runs the original command in place of the wrapper (see execArgv)
 func execOriginal(path string, args []string) error {
+	// Refuse an original that is ribbin, or a loop of wrappers
+	depth, err := checkShimRecursion(path)
+	if err != nil {
+		return err
+	}
+
 	// Build argv: first element is the program path, followed by all arguments
 	argv := append([]string{path}, args...)
 
-	// Get current environment
-	env := os.Environ()
+	// Get current environment, with the wrapper's env applied
+	env := withExecEnv(os.Environ())
+	env = setEnv(env, "RIBBIN_PASSTHROUGH_DEPTH", strconv.Itoa(depth))
 
 	// Replace current process with the original command
-	return syscall.Exec(path, argv, env)
+	return execArgv(argv, env)
 }
 
 // execRedirect executes a redirect script with ribbin environment context
 func execRedirect(scriptPath, originalPath, cmdName string, args []string, configPath string) error {
 	// Build argv: first element is the script path, followed by all arguments
 	argv := append([]string{scriptPath}, args...)
+	return execRedirectArgv(argv, originalPath, cmdName, configPath)
+}
 
-	// Build environment with ribbin-specific variables
-	env := os.Environ()
-	env = append(env,
-		"RIBBIN_ORIGINAL_BIN="+originalPath,
-		"RIBBIN_COMMAND="+cmdName,
-		"RIBBIN_CONFIG="+configPath,
-		"RIBBIN_ACTION=redirect",
-	)
+// buildInlineRedirect turns an inline redirect command into a ready-to-exec argv,
+// with placeholders interpolated and argv[0] resolved to an executable path.
+func buildInlineRedirect(shimConfig config.ShimConfig, originalPath string, args []string, configPath string) ([]string, error) {
+	words, err := shimConfig.RedirectCommand()
+	if err != nil {
+		return nil, err
+	}
 
-	// Replace current process with the redirect script
-	return syscall.Exec(scriptPath, argv, env)
+	cwd, _ := os.Getwd()
+	argv := interpolateRedirect(words, redirectContext{
+		Args:     args,
+		Cwd:      cwd,
+		Original: originalPath,
+	})
+	if len(argv) == 0 || argv[0] == "" {
+		return nil, fmt.Errorf("redirect command is empty after substitution")
+	}
+
+	program, err := resolveRedirectProgram(argv[0], configPath)
+	if err != nil {
+		return nil, err
+	}
+	argv[0] = program
+	return argv, nil
+}
+
+// execRedirectArgv runs argv in place of the wrapper (see execRedirectTarget),
+// adding ribbin environment context. argv[0] must be the path of the program
+// to run.
+func execRedirectArgv(argv []string, originalPath, cmdName string, configPath string) error {
+	// Build environment with the wrapper's env and ribbin-specific variables
+	// Each is set rather than appended, as a redirect nested in another
+	// inherits the outer one's
+	env := withExecEnv(os.Environ())
+	env = setEnv(env, "RIBBIN_ORIGINAL_BIN", originalPath)
+	env = setEnv(env, "RIBBIN_COMMAND", cmdName)
+	env = setEnv(env, "RIBBIN_CONFIG", configPath)
+	env = setEnv(env, "RIBBIN_ACTION", "redirect")
+	env = setEnv(env, "RIBBIN_DEPTH", strconv.Itoa(redirectDepth+1))
+	env = setEnv(env, "RIBBIN_REDIRECT_CHAIN", strings.Join(append(redirectChain(), cmdName), ":"))
+
+	return execRedirectTarget(argv, env)
 }
 
 // extractCommandName extracts the command name from a path
@@ -243,6 +863,47 @@ func extractCommandName(path string) string {
 	return base
 }
 
+// blockMessage renders the message of a wrapper blocking cmdName with args,
+// in the user's locale, for the config at configPath in cwd. The config is
+// only loaded again if the message names the {scope}.
+func blockMessage(shimConfig config.ShimConfig, cmdName string, args []string, configPath, cwd string) string {
+	locale := messageLocale()
+	vars := config.MessageVars{Command: cmdName, Args: args, ConfigPath: configPath, Dir: cwd}
+	vars.Package, _ = config.EphemeralPackage(cmdName, args)
+	if strings.Contains(shimConfig.LocalizedMessage(locale), "{scope}") {
+		if projectConfig, err := config.LoadProjectConfig(configPath); err == nil {
+			if matched := config.FindMatchingScope(projectConfig, filepath.Dir(configPath), cwd); matched != nil {
+				vars.Scope = matched.Name
+			}
+		}
+	}
+	return shimConfig.RenderMessage(locale, vars)
+}
+
+// messageLocale returns the locale to show messages in, from the POSIX
+// locale variables in order of precedence
+func messageLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
+
+// printWarnMessage prints the message of a "warn" wrapper before the
+// original runs. expiredOn is the date the wrapper expired, if it is only
+// warning because it expired.
+func printWarnMessage(cmd, message, expiredOn string) {
+	if message == "" {
+		message = fmt.Sprintf("'%s' is discouraged by ribbin.", cmd)
+	}
+	fmt.Fprintf(os.Stderr, "ribbin: warning: '%s': %s\n", cmd, message)
+	if expiredOn != "" {
+		fmt.Fprintf(os.Stderr, "ribbin: the '%s' wrapper expired on %s, so it only warns now\n", cmd, expiredOn)
+	}
+}
+
 // printBlockMessage prints a nicely formatted error box
 func printBlockMessage(cmd, message string) {
 	// Default message if none provided
@@ -253,6 +914,9 @@ func printBlockMessage(cmd, message string) {
 	// Build the message lines
 	errorLine := fmt.Sprintf("ERROR: Direct use of '%s' is blocked.", cmd)
 	bypassLine := fmt.Sprintf("Bypass: RIBBIN_BYPASS=1 %s ...", cmd)
+	if enforcement != "" {
+		bypassLine = fmt.Sprintf("Bypasses are disabled by %s", enforcement)
+	}
 
 	// Calculate the maximum line width
 	lines := []string{errorLine, "", message, "", bypassLine}
@@ -301,6 +965,8 @@ func printBoxLine(content string, width int) {
 }
 
 // shouldPassthrough checks if any ancestor process invocation matches passthrough conditions.
+// Each ancestor is matched both as-is and normalized (see process.NormalizeCommand), so
+// "pnpm run" matches pnpm however it was launched, e.g. "node .../pnpm.cjs run build".
 // Returns true if the shim should pass through to the original command.
 func shouldPassthrough(pt *config.PassthroughConfig) bool {
 	// Determine max depth (0 = unlimited)
@@ -315,29 +981,84 @@ func shouldPassthrough(pt *config.PassthroughConfig) bool {
 		return false
 	}
 
+	var regexps []*regexp.Regexp
+	for _, pattern := range pt.InvocationRegexp {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			// Invalid regex, skip it
+			continue
+		}
+		regexps = append(regexps, re)
+	}
+
 	// Check each ancestor against patterns
-	for _, cmd := range ancestorCmds {
-		// Check exact matches
-		for _, pattern := range pt.Invocation {
-			if strings.Contains(cmd, pattern) {
-				return true
+	for _, ancestor := range ancestorCmds {
+		for _, cmd := range []string{ancestor, process.NormalizeCommand(ancestor)} {
+			// Check exact matches
+			for _, pattern := range pt.Invocation {
+				if strings.Contains(cmd, pattern) {
+					return true
+				}
+			}
+
+			// Check regexp matches
+			for _, re := range regexps {
+				if re.MatchString(cmd) {
+					return true
+				}
 			}
 		}
+	}
 
-		// Check regexp matches
-		for _, pattern := range pt.InvocationRegexp {
-			re, err := regexp.Compile(pattern)
-			if err != nil {
-				// Invalid regex, skip it
+	return false
+}
+
+// scheduleClock describes the time now in the schedule's time zone, like
+// "Mon 18:42 EST", for traces
+func scheduleClock(when *config.WhenConfig) string {
+	loc, err := when.Location()
+	if err != nil {
+		loc = time.Local
+	}
+	return time.Now().In(loc).Format("Mon 15:04 MST")
+}
+
+// allowedCaller returns the name of the nearest ancestor process that is
+// one of the commands in onlyFrom
+func allowedCaller(onlyFrom []string) (string, bool) {
+	ancestorCmds, err := process.GetAncestorCommands(0)
+	if err != nil {
+		return "", false
+	}
+	return matchCaller(onlyFrom, ancestorCmds)
+}
+
+// matchCaller returns the name of the first of ancestorCmds whose command
+// name, as launched or normalized (see process.NormalizeCommand), is in
+// onlyFrom. Only the name counts, so "make" doesn't match cmake or a path
+// containing "make".
+func matchCaller(onlyFrom []string, ancestorCmds []string) (string, bool) {
+	for _, ancestor := range ancestorCmds {
+		for _, cmd := range []string{ancestor, process.NormalizeCommand(ancestor)} {
+			fields := strings.Fields(cmd)
+			if len(fields) == 0 {
 				continue
 			}
-			if re.MatchString(cmd) {
-				return true
+			name := strings.TrimPrefix(filepath.Base(fields[0]), "-")
+			for _, allowed := range onlyFrom {
+				if name == allowed {
+					return name, true
+				}
 			}
 		}
 	}
+	return "", false
+}
 
-	return false
+// onlyFromMessage is the block message of an onlyFrom wrapper without a
+// message of its own
+func onlyFromMessage(cmdName string, onlyFrom []string) string {
+	return fmt.Sprintf("'%s' may only be run by %s.", cmdName, strings.Join(onlyFrom, ", "))
 }
 
 // getEffectiveShimConfig determines the effective shim configuration for a command
@@ -351,12 +1072,7 @@ func getEffectiveShimConfig(projectConfig *config.ProjectConfig, configPath stri
 		return shimConfig, exists
 	}
 
-	// Find the best matching scope
-	matchingScope := findBestMatchingScope(projectConfig, configPath, cwd)
-
-	// Use Resolver to get effective shims
-	resolver := config.NewResolver()
-	effectiveShims, err := resolver.ResolveEffectiveShims(projectConfig, configPath, matchingScope)
+	effectiveShims, err := resolveEffectiveShims(config.NewResolver(), projectConfig, configPath, cwd)
 	if err != nil {
 		// If resolution fails, fall back to root wrappers
 		shimConfig, exists := projectConfig.Wrappers[cmdName]
@@ -367,9 +1083,35 @@ func getEffectiveShimConfig(projectConfig *config.ProjectConfig, configPath stri
 	return shimConfig, exists
 }
 
+// resolveEffectiveShims returns every wrapper in effect in cwd: the best
+// matching scope's, merged with parent configs when this one isn't a root.
+func resolveEffectiveShims(resolver *config.Resolver, projectConfig *config.ProjectConfig, configPath, cwd string) (map[string]config.ShimConfig, error) {
+	if !projectConfig.IsRoot() {
+		_, resolved, err := resolver.ResolveForCwdWithProvenance(projectConfig, configPath, cwd)
+		if err != nil {
+			return nil, err
+		}
+		shims := make(map[string]config.ShimConfig, len(resolved))
+		for name, r := range resolved {
+			shims[name] = r.Config
+		}
+		return shims, nil
+	}
+
+	matchingScope := findBestMatchingScope(projectConfig, configPath, cwd)
+	return resolver.ResolveEffectiveShims(projectConfig, configPath, matchingScope)
+}
+
 // findBestMatchingScope finds the scope with the deepest path that contains the CWD.
 // Returns nil if no scope matches (meaning root shims should be used).
 func findBestMatchingScope(projectConfig *config.ProjectConfig, configPath string, cwd string) *config.ScopeConfig {
+	_, scope := findBestMatchingScopeName(projectConfig, configPath, cwd)
+	return scope
+}
+
+// findBestMatchingScopeName is findBestMatchingScope, also returning the
+// scope's name ("" if no scope matches)
+func findBestMatchingScopeName(projectConfig *config.ProjectConfig, configPath string, cwd string) (string, *config.ScopeConfig) {
 	configDir := filepath.Dir(configPath)
 
 	// Resolve symlinks in CWD to handle macOS /var -> /private/var symlink
@@ -380,9 +1122,10 @@ func findBestMatchingScope(projectConfig *config.ProjectConfig, configPath strin
 	resolvedCwd = filepath.Clean(resolvedCwd)
 
 	var bestMatch *config.ScopeConfig
+	var bestName string
 	bestMatchDepth := -1
 
-	for _, scope := range projectConfig.Scopes {
+	for name, scope := range projectConfig.Scopes {
 		scopePath := scope.Path
 		if scopePath == "" {
 			scopePath = "."
@@ -411,11 +1154,37 @@ func findBestMatchingScope(projectConfig *config.ProjectConfig, configPath strin
 				bestMatchDepth = depth
 				scopeCopy := scope
 				bestMatch = &scopeCopy
+				bestName = name
 			}
 		}
 	}
 
-	return bestMatch
+	return bestName, bestMatch
+}
+
+// matchedScopes returns the name of the scope containing cwd in the config at
+// configPath and in each parent config it merges with, by config path.
+// Configs where no scope contains cwd are left out. Activations limited to
+// scopes (see 'ribbin activate --scope') are checked against it.
+func matchedScopes(projectConfig *config.ProjectConfig, configPath, cwd string) map[string]string {
+	scopes := make(map[string]string)
+	if name, _ := findBestMatchingScopeName(projectConfig, configPath, cwd); name != "" {
+		scopes[configPath] = name
+	}
+	if projectConfig.IsRoot() {
+		return scopes
+	}
+	chain, _ := config.ConfigChain(configPath)
+	for _, parent := range chain[1:] {
+		parentConfig, err := config.LoadProjectConfig(parent)
+		if err != nil {
+			continue
+		}
+		if name, _ := findBestMatchingScopeName(parentConfig, parent, cwd); name != "" {
+			scopes[parent] = name
+		}
+	}
+	return scopes
 }
 
 // isPathWithin checks if targetPath is within or equal to basePath.
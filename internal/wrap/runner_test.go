@@ -174,6 +174,140 @@ func TestIsActive(t *testing.T) {
 	})
 }
 
+func TestActivationTags(t *testing.T) {
+	testConfigPath := "/test/project/ribbin.jsonc"
+	npm := config.ShimConfig{Action: "block", Tags: []string{"node", "migration"}}
+	rm := config.ShimConfig{Action: "redirect", Tags: []string{"safety"}}
+	tsc := config.ShimConfig{Action: "block"}
+
+	newRegistry := func() *config.Registry {
+		return &config.Registry{
+			Wrappers:          make(map[string]config.WrapperEntry),
+			ShellActivations:  make(map[int]config.ShellActivationEntry),
+			ConfigActivations: make(map[string]config.ConfigActivationEntry),
+		}
+	}
+
+	t.Run("config activation limited to tags", func(t *testing.T) {
+		registry := newRegistry()
+		registry.AddConfigActivation(testConfigPath, "migration")
+
+		act := activationFor(registry, testConfigPath)
+		if !act.covers(npm, nil) || act.covers(rm, nil) || act.covers(tsc, nil) {
+			t.Errorf("activation %q should only cover wrappers tagged migration", act)
+		}
+		if got := activationReason(registry, testConfigPath); got != "config activation, tags migration" {
+			t.Errorf("activationReason = %q", got)
+		}
+	})
+
+	t.Run("tagged activations combine", func(t *testing.T) {
+		registry := newRegistry()
+		registry.AddShellActivation(1, "safety")
+		registry.AddConfigActivation(testConfigPath, "node")
+
+		act := activationFor(registry, testConfigPath)
+		if !act.covers(npm, nil) || !act.covers(rm, nil) || act.covers(tsc, nil) {
+			t.Errorf("activation %q should cover wrappers tagged node or safety", act)
+		}
+	})
+
+	t.Run("untagged activation covers everything", func(t *testing.T) {
+		registry := newRegistry()
+		registry.AddShellActivation(1, "safety")
+		registry.AddConfigActivation(testConfigPath)
+
+		act := activationFor(registry, testConfigPath)
+		if !act.covers(npm, nil) || !act.covers(rm, nil) || !act.covers(tsc, nil) {
+			t.Errorf("activation %q should cover every wrapper", act)
+		}
+	})
+
+	t.Run("config activation limited to scopes", func(t *testing.T) {
+		registry := newRegistry()
+		registry.AddScopedConfigActivation(testConfigPath, "backend")
+
+		act := activationFor(registry, testConfigPath)
+		if !act.covers(tsc, map[string]string{testConfigPath: "backend"}) {
+			t.Errorf("activation %q should cover wrappers in the backend scope", act)
+		}
+		if act.covers(tsc, map[string]string{testConfigPath: "frontend"}) || act.covers(tsc, nil) {
+			t.Errorf("activation %q should not cover wrappers outside the backend scope", act)
+		}
+		if !act.coversSomewhere(tsc) {
+			t.Errorf("activation %q should cover tsc somewhere", act)
+		}
+		if got := activationReason(registry, testConfigPath); got != "config activation, scopes backend" {
+			t.Errorf("activationReason = %q", got)
+		}
+	})
+
+	t.Run("scoped and tagged activations combine", func(t *testing.T) {
+		registry := newRegistry()
+		registry.AddShellActivation(1, "safety")
+		registry.AddScopedConfigActivation(testConfigPath, "backend")
+
+		act := activationFor(registry, testConfigPath)
+		if !act.covers(rm, nil) || act.covers(tsc, nil) || !act.covers(tsc, map[string]string{testConfigPath: "backend"}) {
+			t.Errorf("activation %q should cover wrappers tagged safety or in the backend scope", act)
+		}
+		if got := act.uncoveredReason(); got != "activation is limited to tags safety or scopes backend" {
+			t.Errorf("uncoveredReason = %q", got)
+		}
+	})
+
+	t.Run("environment activation limited to tags", func(t *testing.T) {
+		t.Setenv(ActivateEnv, "/other/ribbin.jsonc"+string(filepath.ListSeparator)+testConfigPath)
+		t.Setenv(ActivateTagsEnv, "safety, migration")
+
+		act := activationFor(newRegistry(), testConfigPath)
+		if !act.covers(npm, nil) || !act.covers(rm, nil) || act.covers(tsc, nil) {
+			t.Errorf("activation %q should cover wrappers tagged safety or migration", act)
+		}
+		if got := activationReason(newRegistry(), testConfigPath); got != "environment activation (RIBBIN_ACTIVATE), tags migration, safety" {
+			t.Errorf("activationReason = %q", got)
+		}
+	})
+
+	t.Run("environment activation of another config", func(t *testing.T) {
+		t.Setenv(ActivateEnv, "/other/ribbin.jsonc")
+
+		if act := activationFor(newRegistry(), testConfigPath); act.Reason != "" {
+			t.Errorf("activation %q should not cover another config", act)
+		}
+	})
+}
+
+func TestMatchedScopes(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "ribbin.jsonc")
+	content := `{
+  "scopes": {
+    "backend": {"path": "apps/backend"},
+    "legacy": {"path": "apps/backend/legacy"}
+  }
+}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	projectConfig, err := config.LoadProjectConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		dir:                                    "",
+		filepath.Join(dir, "apps/backend"):     "backend",
+		filepath.Join(dir, "apps/backend/src"): "backend",
+		filepath.Join(dir, "apps/backend/legacy"): "legacy",
+	}
+	for cwd, want := range tests {
+		if got := matchedScopes(projectConfig, configPath, cwd)[configPath]; got != want {
+			t.Errorf("scope in %s = %q, want %q", cwd, got, want)
+		}
+	}
+}
+
 // Note: Run() uses syscall.Exec which replaces the current process,
 // making it difficult to test directly. We test the helper functions
 // and integration tests cover the full flow.
@@ -331,6 +465,40 @@ func TestShouldPassthrough(t *testing.T) {
 	})
 }
 
+func TestMatchCaller(t *testing.T) {
+	ancestors := []string{
+		"/bin/sh -c tsc -p .",
+		"/usr/bin/make build",
+		"-bash",
+	}
+	tests := []struct {
+		name     string
+		onlyFrom []string
+		want     string
+		wantOK   bool
+	}{
+		{"nearest matching ancestor", []string{"just", "make"}, "make", true},
+		{"login shell", []string{"bash"}, "bash", true},
+		{"name only, not a substring", []string{"mak", "cmake"}, "", false},
+		{"no ancestor", []string{"just"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := matchCaller(tt.onlyFrom, ancestors)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("matchCaller(%v) = %q, %v, want %q, %v", tt.onlyFrom, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	t.Run("script run by an interpreter", func(t *testing.T) {
+		got, ok := matchCaller([]string{"npm"}, []string{"node /usr/lib/node_modules/npm/bin/npm-cli.js run build"})
+		if !ok || got != "npm" {
+			t.Errorf("matchCaller = %q, %v, want npm", got, ok)
+		}
+	})
+}
+
 func TestPrintBlockMessage(t *testing.T) {
 	// Capture stderr output is tricky, just verify it doesn't panic
 	t.Run("prints with custom message", func(t *testing.T) {
@@ -0,0 +1,128 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// A wrapper runs ribbin, which runs the original. If the "original" is ribbin
+// itself, say because a config lists "ribbin" or the path to it, wrapping it
+// would leave a symlink to ribbin where ribbin was, and every run would start
+// ribbin again until the system ran out of processes. Install refuses to wrap
+// ribbin, and a wrapper refuses to run an original that is ribbin.
+//
+// A loop can also go through other programs: an original that is a script
+// running its own command by name finds the wrapper on PATH again. Each
+// passthrough tells the original RIBBIN_PASSTHROUGH_DEPTH, one more than it
+// was given, and a wrapper given MaxPassthroughDepth stops the loop.
+
+// MaxPassthroughDepth is how many wrappers in a row may run their original
+// before ribbin assumes a loop. Legitimate nesting, like a package manager
+// running a wrapped tool that runs another, stays far below it.
+const MaxPassthroughDepth = 40
+
+// SelfWrapError is an attempt to wrap the ribbin binary itself
+type SelfWrapError struct {
+	Path string
+}
+
+func (e *SelfWrapError) Error() string {
+	return fmt.Sprintf("refusing to wrap %s: it is ribbin itself, so the wrapper would run ribbin in a loop, or as the CLI; remove it from your wrappers", e.Path)
+}
+
+// ShimRecursionError is a wrapper refusing to run its original, because the
+// original is ribbin or the wrappers have been running each other in a loop
+type ShimRecursionError struct {
+	Command  string
+	Original string
+	// Depth is RIBBIN_PASSTHROUGH_DEPTH, when the loop was found by depth
+	Depth int
+}
+
+func (e *ShimRecursionError) Error() string {
+	if e.Depth > 0 {
+		return fmt.Sprintf("not running %s: %d wrappers in a row ran their originals, so something is running '%s' in a loop. "+
+			"If the original runs '%s' by name, have it run the original's path instead.", e.Original, e.Depth, e.Command, e.Command)
+	}
+	return fmt.Sprintf("not running %s: the original of '%s' is ribbin itself, so it would run forever. "+
+		"Restore the real binary with 'ribbin recover', or reinstall it.", e.Original, e.Command)
+}
+
+// IsRibbin reports whether path is ribbin itself once symlinks are followed:
+// the same file as ribbinPath or the ribbin running, or a binary named like
+// ribbin, which always runs as the CLI and so could never act as a wrapper.
+// Copies of ribbin made by the copy shim mode are wrappers, which
+// IsAlreadyShimmed recognizes.
+func IsRibbin(path, ribbinPath string) bool {
+	if name := filepath.Base(path); name == "ribbin" || name == "ribbin-next" {
+		return true
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	candidates := []string{ribbinPath}
+	if self := runningRibbin(); self != "" {
+		candidates = append(candidates, self)
+	}
+	for _, candidate := range candidates {
+		candidateInfo, err := os.Stat(candidate)
+		if err != nil {
+			continue
+		}
+		if os.SameFile(info, candidateInfo) {
+			return true
+		}
+	}
+	return false
+}
+
+// runningRibbin returns the path of the ribbin binary running, with symlinks
+// resolved, or "" if it can't be found
+func runningRibbin() string {
+	execPath, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	resolved, err := filepath.EvalSymlinks(execPath)
+	if err != nil {
+		return ""
+	}
+	return resolved
+}
+
+// checkShimRecursion returns a *ShimRecursionError if running originalPath
+// would start a loop, or else the RIBBIN_PASSTHROUGH_DEPTH to give the
+// original. Only the running binary is compared, not copies of it, as this
+// runs every time a wrapper passes through.
+func checkShimRecursion(originalPath string) (int, error) {
+	cmdName := filepath.Base(BinaryForSidecar(originalPath))
+	depth, _ := strconv.Atoi(os.Getenv("RIBBIN_PASSTHROUGH_DEPTH"))
+	if depth < 0 {
+		depth = 0
+	}
+	if depth >= MaxPassthroughDepth {
+		err := &ShimRecursionError{Command: cmdName, Original: originalPath, Depth: depth}
+		security.LogSecurityViolation("shim_recursion", originalPath, map[string]string{
+			"command": cmdName,
+			"depth":   strconv.Itoa(depth),
+		})
+		return 0, err
+	}
+
+	if execPath, err := os.Executable(); err == nil {
+		self, selfErr := os.Stat(execPath)
+		original, originalErr := os.Stat(originalPath)
+		if selfErr == nil && originalErr == nil && os.SameFile(self, original) {
+			security.LogSecurityViolation("shim_recursion", originalPath, map[string]string{
+				"command": cmdName,
+			})
+			return 0, &ShimRecursionError{Command: cmdName, Original: originalPath}
+		}
+	}
+	return depth + 1, nil
+}
@@ -0,0 +1,127 @@
+package wrap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestIsRibbin(t *testing.T) {
+	dir := t.TempDir()
+	ribbinPath := filepath.Join(dir, "ribbin")
+	if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "rb")
+	if err := os.Symlink(ribbinPath, link); err != nil {
+		t.Fatal(err)
+	}
+	hardLink := filepath.Join(dir, "ribbin-link")
+	if err := os.Link(ribbinPath, hardLink); err != nil {
+		t.Fatal(err)
+	}
+	renamed := filepath.Join(dir, "bin", "ribbin")
+	if err := os.MkdirAll(filepath.Dir(renamed), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(renamed, []byte("#!/bin/sh\necho another ribbin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "tool")
+	if err := os.WriteFile(other, []byte("#!/bin/sh\necho tool!"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{ribbinPath, true},
+		{link, true},
+		{hardLink, true},
+		{renamed, true},
+		{other, false},
+		{filepath.Join(dir, "missing"), false},
+	}
+	for _, tt := range tests {
+		if got := IsRibbin(tt.path, ribbinPath); got != tt.want {
+			t.Errorf("IsRibbin(%s) = %v, want %v", filepath.Base(tt.path), got, tt.want)
+		}
+	}
+
+	// The running binary counts too, whatever ribbinPath says
+	if self := runningRibbin(); self == "" || !IsRibbin(self, ribbinPath) {
+		t.Errorf("the running binary %q should be ribbin", self)
+	}
+}
+
+func TestInstallRefusesRibbin(t *testing.T) {
+	dir := t.TempDir()
+	ribbinPath := filepath.Join(dir, "ribbin")
+	if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+
+	err := Install(ribbinPath, ribbinPath, registry, "")
+	var selfErr *SelfWrapError
+	if !errors.As(err, &selfErr) {
+		t.Fatalf("Install(ribbin) error = %v, want a *SelfWrapError", err)
+	}
+	if info, err := os.Lstat(ribbinPath); err != nil || !info.Mode().IsRegular() || HasSidecar(ribbinPath) {
+		t.Error("ribbin should be left alone")
+	}
+	if len(registry.Wrappers) != 0 {
+		t.Error("nothing should be registered")
+	}
+}
+
+func TestCheckShimRecursion(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "tsc.ribbin-original")
+	if err := os.WriteFile(original, []byte("#!/bin/sh\necho tsc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("passes on one more than it was given", func(t *testing.T) {
+		t.Setenv("RIBBIN_PASSTHROUGH_DEPTH", "3")
+		depth, err := checkShimRecursion(original)
+		if err != nil || depth != 4 {
+			t.Errorf("checkShimRecursion() = %d, %v, want 4", depth, err)
+		}
+	})
+
+	t.Run("starts at one", func(t *testing.T) {
+		t.Setenv("RIBBIN_PASSTHROUGH_DEPTH", "")
+		if depth, err := checkShimRecursion(original); err != nil || depth != 1 {
+			t.Errorf("checkShimRecursion() = %d, %v, want 1", depth, err)
+		}
+	})
+
+	t.Run("stops a loop", func(t *testing.T) {
+		t.Setenv("RIBBIN_PASSTHROUGH_DEPTH", strconv.Itoa(MaxPassthroughDepth))
+		_, err := checkShimRecursion(original)
+		var recursionErr *ShimRecursionError
+		if !errors.As(err, &recursionErr) || recursionErr.Command != "tsc" || recursionErr.Depth != MaxPassthroughDepth {
+			t.Errorf("checkShimRecursion() error = %v, want a loop found for tsc", err)
+		}
+	})
+
+	t.Run("refuses an original that is ribbin", func(t *testing.T) {
+		t.Setenv("RIBBIN_PASSTHROUGH_DEPTH", "")
+		self := filepath.Join(dir, "node.ribbin-original")
+		if err := os.Symlink(runningRibbin(), self); err != nil {
+			t.Fatal(err)
+		}
+		_, err := checkShimRecursion(self)
+		var recursionErr *ShimRecursionError
+		if !errors.As(err, &recursionErr) || recursionErr.Command != "node" || recursionErr.Depth != 0 {
+			t.Errorf("checkShimRecursion() error = %v, want the original found to be ribbin", err)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// Renaming a signed macOS binary, or running it from a path outside its app
+// bundle, can make Gatekeeper prompt or check its notarization again. Those
+// binaries can instead be wrapped by shadowing: the binary stays where it
+// is, and a wrapper with its name goes in ribbin's shadow directory, which
+// the shell hook puts at the front of PATH. The wrapper's sidecar is a
+// symlink to the binary, and the wrapper runs the binary at its own path.
+
+// ShadowDirName is the directory, in ribbin's state directory, holding the
+// wrappers of shadowed binaries
+const ShadowDirName = "bin"
+
+// ShadowDir returns the directory holding the wrappers of shadowed binaries
+func ShadowDir() (string, error) {
+	stateDir, err := security.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, ShadowDirName), nil
+}
+
+// ShadowPath returns where the wrapper shadowing binaryPath goes
+func ShadowPath(binaryPath string) (string, error) {
+	dir, err := ShadowDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(binaryPath)), nil
+}
+
+// ShadowedBinary returns the binary the wrapper at wrapperPath shadows, or
+// "" if it doesn't shadow one
+func ShadowedBinary(wrapperPath string) string {
+	dir, err := ShadowDir()
+	if err != nil || filepath.Dir(wrapperPath) != dir {
+		return ""
+	}
+	meta, err := LoadMetadata(wrapperPath)
+	if err != nil {
+		return ""
+	}
+	return meta.Shadows
+}
+
+// IsShadowed reports whether binaryPath is wrapped by shadowing
+func IsShadowed(binaryPath string) bool {
+	shadowPath, err := ShadowPath(binaryPath)
+	return err == nil && ShadowedBinary(shadowPath) == binaryPath
+}
+
+// ShadowFirstOnPath reports whether the shadow directory comes before the
+// directory of binaryPath on PATH, so the shadowing wrapper runs in its place
+func ShadowFirstOnPath(binaryPath string) bool {
+	dir, err := ShadowDir()
+	if err != nil {
+		return false
+	}
+	for _, entry := range filepath.SplitList(os.Getenv("PATH")) {
+		switch filepath.Clean(entry) {
+		case dir:
+			return true
+		case filepath.Dir(binaryPath):
+			return false
+		}
+	}
+	return false
+}
+
+// InstallShadow wraps binaryPath by shadowing it, leaving the binary as it
+// is:
+// 1. Create the shadow directory and acquire the wrapper's lock
+// 2. Refuse if another binary with the name is shadowed already
+// 3. Symlink {shadow dir}/{name}.ribbin-original -> binaryPath
+// 4. Symlink {shadow dir}/{name} -> ribbinPath
+// 5. Record metadata naming binaryPath, and update the registry under the
+// wrapper's path
+func InstallShadow(binaryPath, ribbinPath string, registry *config.Registry, configPath string) error {
+	var installErr error
+	shadowPath, err := ShadowPath(binaryPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		security.LogShimInstall(shadowPath, installErr == nil, installErr)
+	}()
+
+	if err := security.ValidateBinaryPath(binaryPath); err != nil {
+		installErr = fmt.Errorf("invalid binary path: %w", err)
+		return installErr
+	}
+	if IsRibbin(binaryPath, ribbinPath) {
+		installErr = &SelfWrapError{Path: binaryPath}
+		return installErr
+	}
+
+	// 1. CREATE THE SHADOW DIRECTORY, AND LOCK
+	if _, err := security.EnsureStateDir(); err != nil {
+		installErr = err
+		return installErr
+	}
+	if err := os.MkdirAll(filepath.Dir(shadowPath), 0755); err != nil {
+		installErr = fmt.Errorf("cannot create shadow directory: %w", err)
+		return installErr
+	}
+	lock, err := security.AcquireLock(shadowPath, 10*time.Second)
+	if err != nil {
+		installErr = fmt.Errorf("cannot acquire lock: %w", err)
+		return installErr
+	}
+	defer lock.Release()
+
+	// 2. ONE BINARY PER NAME
+	if _, err := os.Lstat(shadowPath); err == nil {
+		if shadowed := ShadowedBinary(shadowPath); shadowed != "" {
+			installErr = fmt.Errorf("%s already shadows %s; unwrap it first, as only one binary named %s can be shadowed",
+				shadowPath, shadowed, filepath.Base(binaryPath))
+		} else {
+			installErr = fmt.Errorf("%s exists but isn't a ribbin wrapper; remove it to shadow %s", shadowPath, binaryPath)
+		}
+		return installErr
+	}
+
+	// 3-4. SIDECAR AND WRAPPER SYMLINKS (rollback on failure)
+	sidecarPath := shadowPath + sidecarSuffix
+	os.Remove(sidecarPath)
+	if err := os.Symlink(binaryPath, sidecarPath); err != nil {
+		installErr = fmt.Errorf("cannot create sidecar: %w", err)
+		return installErr
+	}
+	if err := os.Symlink(ribbinPath, shadowPath); err != nil {
+		os.Remove(sidecarPath)
+		installErr = fmt.Errorf("failed to create symlink at %s: %w", shadowPath, err)
+		return installErr
+	}
+
+	// 5. METADATA, WITHOUT WHICH THE WRAPPER DOESN'T KNOW IT SHADOWS
+	meta, err := originalMetadata(binaryPath, ribbinPath)
+	if err == nil {
+		// Nothing is moved, so there is nothing to restore
+		meta.OriginalAttributes = nil
+		meta.Shadows = binaryPath
+		err = saveMetadata(shadowPath, meta)
+	}
+	if err != nil {
+		os.Remove(shadowPath)
+		os.Remove(sidecarPath)
+		installErr = fmt.Errorf("cannot record metadata: %w", err)
+		return installErr
+	}
+
+	registry.AddWrapper(config.WrapperEntry{
+		Original: shadowPath,
+		Config:   configPath,
+	})
+	return nil
+}
+
+// uninstallShadow removes the wrapper at shadowPath shadowing a binary,
+// with its sidecar symlink and metadata. The binary itself is untouched.
+func uninstallShadow(shadowPath string, registry *config.Registry) error {
+	if err := os.Remove(shadowPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove wrapper: %w", err)
+	}
+	if err := os.Remove(shadowPath + sidecarSuffix); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove sidecar: %w", err)
+	}
+	_ = removeMetadata(shadowPath)
+	registry.RemoveWrapper(shadowPath)
+	return nil
+}
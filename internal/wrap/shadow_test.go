@@ -0,0 +1,99 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+func TestInstallShadow(t *testing.T) {
+	stateHome := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", stateHome)
+	tmpDir := t.TempDir()
+
+	appDir := filepath.Join(tmpDir, "Tool.app", "Contents", "MacOS")
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	binaryPath := filepath.Join(appDir, "tool")
+	if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho original"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ribbinPath := filepath.Join(tmpDir, "ribbin")
+	if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+
+	if err := InstallShadow(binaryPath, ribbinPath, registry, "/project/ribbin.jsonc"); err != nil {
+		t.Fatalf("InstallShadow error: %v", err)
+	}
+	shadowPath := filepath.Join(stateHome, "ribbin", ShadowDirName, "tool")
+
+	// The binary is left as it is
+	if info, err := os.Lstat(binaryPath); err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("binary was changed: %v, %v", info, err)
+	}
+	if target, err := os.Readlink(shadowPath); err != nil || target != ribbinPath {
+		t.Errorf("wrapper links to %q, %v, want %q", target, err, ribbinPath)
+	}
+	if got := sidecarFor(shadowPath); got != shadowPath+sidecarSuffix {
+		t.Errorf("sidecarFor = %q", got)
+	}
+	if got := ShadowedBinary(shadowPath); got != binaryPath {
+		t.Errorf("ShadowedBinary = %q, want %q", got, binaryPath)
+	}
+	if !IsShadowed(binaryPath) {
+		t.Error("IsShadowed = false after InstallShadow")
+	}
+	if _, ok := registry.Wrappers[shadowPath]; !ok {
+		t.Errorf("registry has no entry for %s: %v", shadowPath, registry.Wrappers)
+	}
+
+	// A second binary with the same name can't be shadowed too
+	otherPath := filepath.Join(tmpDir, "tool")
+	if err := os.WriteFile(otherPath, []byte("#!/bin/sh\necho other"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := InstallShadow(otherPath, ribbinPath, registry, "/project/ribbin.jsonc"); err == nil || !strings.Contains(err.Error(), "already shadows") {
+		t.Errorf("InstallShadow of a second tool = %v, want an already shadows error", err)
+	}
+
+	if err := Uninstall(shadowPath, registry); err != nil {
+		t.Fatalf("Uninstall error: %v", err)
+	}
+	for _, path := range []string{shadowPath, shadowPath + sidecarSuffix, MetadataPath(shadowPath)} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after Uninstall", path)
+		}
+	}
+	if data, err := os.ReadFile(binaryPath); err != nil || !strings.Contains(string(data), "original") {
+		t.Errorf("binary after Uninstall: %q, %v", data, err)
+	}
+	if len(registry.Wrappers) != 0 {
+		t.Errorf("registry still has %v", registry.Wrappers)
+	}
+}
+
+func TestShadowFirstOnPath(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir, err := ShadowDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	binaryPath := "/Applications/Tool.app/Contents/MacOS/tool"
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+filepath.Dir(binaryPath))
+	if !ShadowFirstOnPath(binaryPath) {
+		t.Error("ShadowFirstOnPath = false with the shadow directory first")
+	}
+	t.Setenv("PATH", filepath.Dir(binaryPath)+string(os.PathListSeparator)+dir)
+	if ShadowFirstOnPath(binaryPath) {
+		t.Error("ShadowFirstOnPath = true with the shadow directory last")
+	}
+}
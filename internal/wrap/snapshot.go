@@ -0,0 +1,312 @@
+package wrap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// Sidecars undo one wrapper at a time. Before a wrap touching many binaries
+// in one directory, ribbin also records what the directory held, as a
+// snapshot in $XDG_STATE_HOME/ribbin/snapshots: each entry's name and mode,
+// and the hash of a file or the target of a symlink. 'ribbin
+// restore-snapshot' brings the directory back to it, unwrapping what was
+// wrapped since and recreating symlinks.
+
+// SnapshotDirName is the directory, in ribbin's state directory, holding
+// snapshots
+const SnapshotDirName = "snapshots"
+
+// snapshotKeep is how many snapshots are kept; older ones are deleted as
+// new ones are taken
+const snapshotKeep = 20
+
+// ErrSnapshotNotFound is returned for a snapshot ID that doesn't exist
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// Snapshot is what a directory held before a bulk wrap
+type Snapshot struct {
+	ID      string          `json:"id"`
+	Dir     string          `json:"dir"`
+	TakenAt time.Time       `json:"taken_at"`
+	Reason  string          `json:"reason,omitempty"`
+	Entries []SnapshotEntry `json:"entries"`
+}
+
+// SnapshotEntry is one file or symlink in a snapshot's directory.
+// Subdirectories aren't recorded.
+type SnapshotEntry struct {
+	Name string      `json:"name"`
+	Mode os.FileMode `json:"mode"`
+	// Hash is the contents of a file, as "sha256:..."
+	Hash string `json:"hash,omitempty"`
+	// Target is where a symlink points, as written
+	Target string `json:"target,omitempty"`
+}
+
+// SnapshotDir returns the directory holding snapshots
+func SnapshotDir() (string, error) {
+	stateDir, err := security.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, SnapshotDirName), nil
+}
+
+// BulkDirs returns the directories holding more than threshold of paths,
+// sorted. A threshold of 0 returns none.
+func BulkDirs(paths []string, threshold int) []string {
+	if threshold <= 0 {
+		return nil
+	}
+	counts := make(map[string]int)
+	for _, path := range paths {
+		counts[filepath.Dir(path)]++
+	}
+	var dirs []string
+	for dir, count := range counts {
+		if count > threshold {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// snapshotEntry records the file or symlink at path
+func snapshotEntry(path string, info os.FileInfo) (SnapshotEntry, error) {
+	entry := SnapshotEntry{Name: info.Name(), Mode: info.Mode()}
+	var err error
+	if info.Mode()&os.ModeSymlink != 0 {
+		entry.Target, err = os.Readlink(path)
+	} else {
+		entry.Hash, err = hashFile(path)
+	}
+	return entry, err
+}
+
+// TakeSnapshot records what dir holds and saves it, deleting the oldest
+// snapshots beyond snapshotKeep
+func TakeSnapshot(dir, reason string) (*Snapshot, error) {
+	names, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	digest := sha256.Sum256([]byte(dir))
+	snap := &Snapshot{
+		ID:      now.Format("20060102-150405") + "-" + hex.EncodeToString(digest[:3]),
+		Dir:     dir,
+		TakenAt: now,
+		Reason:  reason,
+		Entries: []SnapshotEntry{},
+	}
+	for _, name := range names {
+		path := filepath.Join(dir, name.Name())
+		info, err := os.Lstat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		entry, err := snapshotEntry(path, info)
+		if err != nil {
+			return nil, fmt.Errorf("cannot snapshot %s: %w", path, err)
+		}
+		snap.Entries = append(snap.Entries, entry)
+	}
+
+	snapDir, err := SnapshotDir()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := security.EnsureStateDir(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(snapDir, 0700); err != nil {
+		return nil, fmt.Errorf("cannot create snapshot directory: %w", err)
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(filepath.Join(snapDir, snap.ID+".json"), data, 0600); err != nil {
+		return nil, fmt.Errorf("cannot save snapshot: %w", err)
+	}
+
+	if snapshots, err := ListSnapshots(); err == nil && len(snapshots) > snapshotKeep {
+		for _, old := range snapshots[:len(snapshots)-snapshotKeep] {
+			os.Remove(filepath.Join(snapDir, old.ID+".json"))
+		}
+	}
+	return snap, nil
+}
+
+// LoadSnapshot reads the snapshot with the given ID
+func LoadSnapshot(id string) (*Snapshot, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("%w: %q", ErrSnapshotNotFound, id)
+	}
+	snapDir, err := SnapshotDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(snapDir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %q (see 'ribbin restore-snapshot' for the list)", ErrSnapshotNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("corrupt snapshot %s: %w", id, err)
+	}
+	return &snap, nil
+}
+
+// ListSnapshots returns the saved snapshots, oldest first
+func ListSnapshots() ([]*Snapshot, error) {
+	snapDir, err := SnapshotDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(snapDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []*Snapshot
+	for _, file := range files {
+		id, ok := strings.CutSuffix(file.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if snap, err := LoadSnapshot(id); err == nil {
+			snapshots = append(snapshots, snap)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].TakenAt.Before(snapshots[j].TakenAt)
+	})
+	return snapshots, nil
+}
+
+// SnapshotRestore is what restoring a snapshot did, or in a dry run would do
+type SnapshotRestore struct {
+	// Restored are the entries brought back to the snapshot
+	Restored []string
+	// Unchanged counts the entries that already matched it
+	Unchanged int
+	// Failed are the entries that couldn't be restored, with why
+	Failed []string
+	// Extra are files added since the snapshot, which are left in place
+	Extra []string
+}
+
+// matchesEntry reports whether path holds what entry recorded, and whether
+// only its mode differs
+func matchesEntry(path string, entry SnapshotEntry) (matches, modeOnly bool) {
+	info, err := os.Lstat(path)
+	if err != nil || info.IsDir() {
+		return false, false
+	}
+	current, err := snapshotEntry(path, info)
+	if err != nil || current.Hash != entry.Hash || current.Target != entry.Target {
+		return false, false
+	}
+	if current.Mode != entry.Mode {
+		return false, true
+	}
+	return true, false
+}
+
+// RestoreSnapshot brings the snapshot's directory back to what it recorded:
+// binaries wrapped since are unwrapped, symlinks are recreated and modes
+// reset. A file whose contents changed, other than by wrapping, can't be
+// restored, as a snapshot doesn't keep contents. Files added since are
+// only reported. With dryRun, nothing is changed.
+func RestoreSnapshot(snap *Snapshot, registry *config.Registry, dryRun bool) *SnapshotRestore {
+	result := &SnapshotRestore{}
+	recorded := make(map[string]bool)
+	for _, entry := range snap.Entries {
+		recorded[entry.Name] = true
+		path := filepath.Join(snap.Dir, entry.Name)
+		matches, modeOnly := matchesEntry(path, entry)
+		if matches {
+			result.Unchanged++
+			continue
+		}
+		if err := restoreEntry(path, entry, modeOnly, registry, dryRun); err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		result.Restored = append(result.Restored, path)
+	}
+
+	// What's left of wrappers is gone once they are unwrapped
+	if names, err := os.ReadDir(snap.Dir); err == nil {
+		for _, name := range names {
+			path := filepath.Join(snap.Dir, name.Name())
+			if recorded[name.Name()] || name.IsDir() || IsSidecar(path) || strings.HasSuffix(path, ".ribbin-meta") {
+				continue
+			}
+			result.Extra = append(result.Extra, path)
+		}
+	}
+	return result
+}
+
+// restoreEntry brings path back to entry
+func restoreEntry(path string, entry SnapshotEntry, modeOnly bool, registry *config.Registry, dryRun bool) error {
+	if modeOnly {
+		if dryRun {
+			return nil
+		}
+		return os.Chmod(path, entry.Mode.Perm())
+	}
+
+	if isWrapper(path) || ShadowedBinary(path) != "" {
+		if dryRun {
+			return nil
+		}
+		if err := Uninstall(path, registry); err != nil {
+			return fmt.Errorf("cannot unwrap: %w", err)
+		}
+		if matches, modeOnly := matchesEntry(path, entry); matches {
+			return nil
+		} else if modeOnly {
+			return os.Chmod(path, entry.Mode.Perm())
+		}
+	}
+
+	if entry.Target == "" {
+		return fmt.Errorf("changed since the snapshot, whose contents aren't kept")
+	}
+	// Only a symlink is replaced, never a file put there since
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("was a symlink to %s, but a file has replaced it", entry.Target)
+		}
+		if dryRun {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	if dryRun {
+		return nil
+	}
+	return os.Symlink(entry.Target, path)
+}
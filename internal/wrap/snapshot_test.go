@@ -0,0 +1,97 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestBulkDirs(t *testing.T) {
+	paths := []string{"/a/one", "/a/two", "/a/three", "/b/one"}
+	if got := BulkDirs(paths, 2); !reflect.DeepEqual(got, []string{"/a"}) {
+		t.Errorf("BulkDirs(2) = %v, want [/a]", got)
+	}
+	if got := BulkDirs(paths, 0); got != nil {
+		t.Errorf("BulkDirs(0) = %v, want none", got)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	ribbinPath := filepath.Join(t.TempDir(), "ribbin")
+	if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"one", "two", "edited"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\necho "+name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("one", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := TakeSnapshot(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Entries) != 4 {
+		t.Fatalf("snapshot entries = %+v, want 4", snap.Entries)
+	}
+	if loaded, err := LoadSnapshot(snap.ID); err != nil || loaded.Dir != dir {
+		t.Fatalf("LoadSnapshot = %+v, %v", loaded, err)
+	}
+	if snapshots, err := ListSnapshots(); err != nil || len(snapshots) != 1 {
+		t.Errorf("ListSnapshots = %v, %v; want the one", snapshots, err)
+	}
+	if _, err := LoadSnapshot("../registry"); err == nil {
+		t.Error("LoadSnapshot should refuse a path")
+	}
+
+	// Wrap two binaries, replace the link, edit a file and add another
+	registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+	for _, name := range []string{"one", "two"} {
+		if err := Install(filepath.Join(dir, name), ribbinPath, registry, "/project/ribbin.jsonc"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Remove(filepath.Join(dir, "link"))
+	if err := os.Symlink("two", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "edited"), []byte("changed"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "added"), []byte("new"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	dry := RestoreSnapshot(snap, registry, true)
+	if len(dry.Restored) != 3 || len(dry.Failed) != 1 || !isWrapper(filepath.Join(dir, "one")) {
+		t.Fatalf("dry run = %+v, or it changed something", dry)
+	}
+
+	result := RestoreSnapshot(snap, registry, false)
+	if len(result.Restored) != 3 || result.Unchanged != 0 || len(result.Failed) != 1 {
+		t.Errorf("restore = %+v; want 3 restored and edited failed", result)
+	}
+	if !reflect.DeepEqual(result.Extra, []string{filepath.Join(dir, "added")}) {
+		t.Errorf("extra = %v, want just added", result.Extra)
+	}
+	for _, name := range []string{"one", "two"} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != "#!/bin/sh\necho "+name {
+			t.Errorf("%s = %q, %v; want the original", name, data, err)
+		}
+		if _, ok := registry.Wrapper(filepath.Join(dir, name)); ok {
+			t.Errorf("%s is still in the registry", name)
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "link")); err != nil || target != "one" {
+		t.Errorf("link -> %q, %v; want one", target, err)
+	}
+}
@@ -0,0 +1,269 @@
+package wrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// Wrapping a binary in a directory only root can write, like /usr/local/bin,
+// takes three changes there: renaming the original to its sidecar, creating
+// the symlink to ribbin, and writing the metadata. Rather than running all of
+// ribbin as root, with --sudo ribbin works out those changes itself, shows
+// them, and runs just them through sudo as plain mv, ln and tee commands.
+// Everything else, including the registry, stays the user's.
+//
+// Only the adjacent sidecar layout and symlink shims are supported this way:
+// the other layouts and the copy shim mode need more than these commands.
+
+// PrivilegedStep is one filesystem change run through sudo
+type PrivilegedStep struct {
+	// Op names the change in the audit log, e.g. "sudo_rename"
+	Op string
+	// Path is the file the step changes
+	Path string
+	// Argv is the command run as root, without sudo
+	Argv []string
+	// Stdin is written to the command's standard input, if set
+	Stdin []byte
+}
+
+// String returns the command as it can be typed into a shell
+func (s PrivilegedStep) String() string {
+	quoted := make([]string, len(s.Argv))
+	for i, arg := range s.Argv {
+		quoted[i] = ShellQuote(arg)
+	}
+	command := "sudo " + strings.Join(quoted, " ")
+	if s.Stdin != nil {
+		command += "  (metadata on stdin)"
+	}
+	return command
+}
+
+// SudoPlan is the privileged steps that wrap or unwrap one binary
+type SudoPlan struct {
+	BinaryPath string
+	Steps      []PrivilegedStep
+	// undo restores the binary if a step after the first fails
+	undo []PrivilegedStep
+}
+
+// runPrivileged runs a step through sudo, which asks for a password on the
+// terminal if it needs one. Tests replace it.
+var runPrivileged = func(step PrivilegedStep) error {
+	cmd := exec.Command("sudo", append([]string{"--"}, step.Argv...)...)
+	if step.Stdin != nil {
+		cmd.Stdin = bytes.NewReader(step.Stdin)
+	} else {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// NeedsSudo reports whether wrapping or unwrapping binaryPath changes a
+// directory this process can't write
+func NeedsSudo(binaryPath string) bool {
+	return !canWrite(filepath.Dir(binaryPath))
+}
+
+// PlanSudoInstall works out the privileged steps that wrap binaryPath with a
+// symlink to ribbinPath, checking what Install would first. The original is
+// hashed for its metadata now, while it is still in place.
+func PlanSudoInstall(binaryPath, ribbinPath, configPath string) (*SudoPlan, error) {
+	if err := security.ValidateBinaryPath(binaryPath); err != nil {
+		return nil, fmt.Errorf("invalid binary path: %w", err)
+	}
+	if err := security.ValidateBinaryPath(ribbinPath); err != nil {
+		return nil, fmt.Errorf("invalid ribbin path: %w", err)
+	}
+	if err := security.NoSymlinksInPath(filepath.Dir(binaryPath)); err != nil {
+		return nil, fmt.Errorf("unsafe parent directory (contains symlinks): %w", err)
+	}
+
+	layout, err := sidecarLayout(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if layout == config.SidecarLayoutProject && ProjectSidecarPath(configPath, binaryPath) == "" {
+		layout = config.SidecarLayoutAdjacent
+	}
+	if layout != config.SidecarLayoutAdjacent {
+		return nil, fmt.Errorf("--sudo keeps the original next to %s, but the %q sidecar layout is configured", binaryPath, layout)
+	}
+	mode, err := shimMode()
+	if err != nil {
+		return nil, err
+	}
+	if mode != config.ShimModeSymlink {
+		return nil, fmt.Errorf("--sudo only creates symlink shims, but \"shimMode\" is %q", mode)
+	}
+
+	info, err := os.Lstat(binaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat binary: %w", err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil, fmt.Errorf("%s is a symlink; --sudo only wraps regular files", binaryPath)
+	}
+	if IsRibbin(binaryPath, ribbinPath) {
+		return nil, &SelfWrapError{Path: binaryPath}
+	}
+	sidecarPath := binaryPath + sidecarSuffix
+	if _, err := os.Lstat(sidecarPath); err == nil {
+		return nil, fmt.Errorf("binary %s is already shimmed (sidecar exists at %s)", binaryPath, sidecarPath)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to check sidecar path %s: %w", sidecarPath, err)
+	}
+
+	meta, err := originalMetadata(binaryPath, ribbinPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot hash binary: %w", err)
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	metaPath := MetadataPath(binaryPath)
+	return &SudoPlan{
+		BinaryPath: binaryPath,
+		Steps: []PrivilegedStep{
+			{Op: "sudo_rename", Path: binaryPath, Argv: []string{"mv", "-n", "--", binaryPath, sidecarPath}},
+			{Op: "sudo_symlink", Path: binaryPath, Argv: []string{"ln", "-s", "--", ribbinPath, binaryPath}},
+			{Op: "sudo_metadata", Path: metaPath, Argv: []string{"tee", "--", metaPath}, Stdin: data},
+		},
+		undo: []PrivilegedStep{
+			{Op: "sudo_rollback", Path: binaryPath, Argv: []string{"mv", "-n", "--", sidecarPath, binaryPath}},
+		},
+	}, nil
+}
+
+// PlanSudoUninstall works out the privileged steps that unwrap binaryPath, a
+// symlink shim with its original next to it
+func PlanSudoUninstall(binaryPath string) (*SudoPlan, error) {
+	if err := security.ValidateBinaryPath(binaryPath); err != nil {
+		return nil, fmt.Errorf("invalid binary path: %w", err)
+	}
+	info, err := os.Lstat(binaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat binary: %w", err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return nil, fmt.Errorf("%s is not a symlink shim; --sudo only unwraps those", binaryPath)
+	}
+	sidecarPath := binaryPath + sidecarSuffix
+	if _, err := os.Lstat(sidecarPath); err != nil {
+		return nil, fmt.Errorf("sidecar not found: %s", sidecarPath)
+	}
+
+	steps := []PrivilegedStep{
+		{Op: "sudo_remove", Path: binaryPath, Argv: []string{"rm", "--", binaryPath}},
+		{Op: "sudo_rename", Path: binaryPath, Argv: []string{"mv", "-n", "--", sidecarPath, binaryPath}},
+	}
+	if HasMetadata(binaryPath) {
+		metaPath := MetadataPath(binaryPath)
+		steps = append(steps, PrivilegedStep{Op: "sudo_remove_metadata", Path: metaPath, Argv: []string{"rm", "-f", "--", metaPath}})
+	}
+	target, _ := os.Readlink(binaryPath)
+	return &SudoPlan{
+		BinaryPath: binaryPath,
+		Steps:      steps,
+		undo: []PrivilegedStep{
+			{Op: "sudo_rollback", Path: binaryPath, Argv: []string{"ln", "-s", "--", target, binaryPath}},
+		},
+	}, nil
+}
+
+// SudoInstall runs a plan from PlanSudoInstall and records the wrapper in
+// registry. If the symlink can't be created the original is moved back; the
+// metadata, as with Install, is best effort.
+func SudoInstall(plan *SudoPlan, registry *config.Registry, configPath string) error {
+	var installErr error
+	defer func() {
+		security.LogShimInstall(plan.BinaryPath, installErr == nil, installErr)
+	}()
+
+	if installErr = plan.run(); installErr != nil {
+		return installErr
+	}
+	registry.AddWrapper(config.WrapperEntry{
+		Original: plan.BinaryPath,
+		Config:   configPath,
+	})
+	return nil
+}
+
+// SudoUninstall runs a plan from PlanSudoUninstall and removes the wrapper
+// from registry
+func SudoUninstall(plan *SudoPlan, registry *config.Registry) error {
+	var uninstallErr error
+	defer func() {
+		security.LogShimUninstall(plan.BinaryPath, uninstallErr == nil, uninstallErr)
+	}()
+
+	if uninstallErr = plan.run(); uninstallErr != nil {
+		return uninstallErr
+	}
+	registry.RemoveWrapper(plan.BinaryPath)
+	return nil
+}
+
+// run runs the plan's steps in order, logging each to the audit log. The
+// first two steps make the change, so if the second fails the first is
+// undone; a later step failing only warns.
+func (p *SudoPlan) run() error {
+	for i, step := range p.Steps {
+		err := runStep(step)
+		if err == nil {
+			continue
+		}
+		switch i {
+		case 0:
+			return err
+		case 1:
+			for _, undo := range p.undo {
+				if undoErr := runStep(undo); undoErr != nil {
+					return fmt.Errorf("%w (and rollback failed: %v)", err, undoErr)
+				}
+			}
+			return err
+		default:
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// runStep runs one privileged step and logs it. mv -n succeeds without
+// moving anything when the destination exists, so a move is checked by
+// looking for its source afterwards.
+func runStep(step PrivilegedStep) error {
+	err := runPrivileged(step)
+	if err == nil && step.Argv[0] == "mv" {
+		if _, statErr := os.Lstat(step.Argv[len(step.Argv)-2]); statErr == nil {
+			err = fmt.Errorf("%s already exists", step.Argv[len(step.Argv)-1])
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("'%s' failed: %w", step, err)
+	}
+	security.LogPrivilegedOperation(step.Op, step.Path, err == nil, err)
+	return err
+}
+
+// ShellQuote quotes s for a POSIX shell if it needs quoting
+func ShellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=+:@") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
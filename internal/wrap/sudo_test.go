@@ -0,0 +1,188 @@
+package wrap
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+// runStepsDirectly makes privileged steps run without sudo for a test,
+// recording them, and fail for any command named in fail
+func runStepsDirectly(t *testing.T, fail ...string) *[]PrivilegedStep {
+	t.Helper()
+	var ran []PrivilegedStep
+	original := runPrivileged
+	runPrivileged = func(step PrivilegedStep) error {
+		ran = append(ran, step)
+		for _, name := range fail {
+			if step.Argv[0] == name {
+				return errors.New("exit status 1")
+			}
+		}
+		cmd := exec.Command(step.Argv[0], step.Argv[1:]...)
+		if step.Stdin != nil {
+			cmd.Stdin = bytes.NewReader(step.Stdin)
+		}
+		return cmd.Run()
+	}
+	t.Cleanup(func() { runPrivileged = original })
+	return &ran
+}
+
+func TestSudoInstall(t *testing.T) {
+	setup := func(t *testing.T) (binaryPath, ribbinPath string) {
+		t.Helper()
+		dir := t.TempDir()
+		ribbinPath = filepath.Join(dir, "ribbin")
+		if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		binDir := filepath.Join(dir, "bin")
+		if err := os.Mkdir(binDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		binaryPath = filepath.Join(binDir, "node")
+		if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho original"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return binaryPath, ribbinPath
+	}
+
+	t.Run("plans a rename, a symlink and the metadata", func(t *testing.T) {
+		binaryPath, ribbinPath := setup(t)
+		plan, err := PlanSudoInstall(binaryPath, ribbinPath, "")
+		if err != nil {
+			t.Fatalf("PlanSudoInstall() error: %v", err)
+		}
+		var commands []string
+		for _, step := range plan.Steps {
+			commands = append(commands, strings.Join(step.Argv, " "))
+		}
+		want := []string{
+			"mv -n -- " + binaryPath + " " + binaryPath + ".ribbin-original",
+			"ln -s -- " + ribbinPath + " " + binaryPath,
+			"tee -- " + binaryPath + ".ribbin-meta",
+		}
+		if strings.Join(commands, "\n") != strings.Join(want, "\n") {
+			t.Errorf("steps =\n%s\nwant\n%s", strings.Join(commands, "\n"), strings.Join(want, "\n"))
+		}
+		if !bytes.Contains(plan.Steps[2].Stdin, []byte(`"original_hash"`)) {
+			t.Errorf("metadata step should write the original's hash, got %s", plan.Steps[2].Stdin)
+		}
+		if _, err := os.Lstat(binaryPath + ".ribbin-original"); !os.IsNotExist(err) {
+			t.Error("planning should change nothing")
+		}
+	})
+
+	t.Run("wraps and unwraps through the steps", func(t *testing.T) {
+		binaryPath, ribbinPath := setup(t)
+		ran := runStepsDirectly(t)
+		registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+
+		plan, err := PlanSudoInstall(binaryPath, ribbinPath, "/project/ribbin.jsonc")
+		if err != nil {
+			t.Fatalf("PlanSudoInstall() error: %v", err)
+		}
+		if err := SudoInstall(plan, registry, "/project/ribbin.jsonc"); err != nil {
+			t.Fatalf("SudoInstall() error: %v", err)
+		}
+		if target, _ := os.Readlink(binaryPath); target != ribbinPath {
+			t.Errorf("%s should link to ribbin, links to %q", binaryPath, target)
+		}
+		if !HasSidecar(binaryPath) || !HasMetadata(binaryPath) {
+			t.Error("the original and metadata should be next to the binary")
+		}
+		if hasConflict, _, _ := CheckHashConflict(binaryPath); hasConflict {
+			t.Error("the metadata should match the original")
+		}
+		if _, ok := registry.Wrapper(binaryPath); !ok {
+			t.Error("the wrapper should be in the registry")
+		}
+		if len(*ran) != 3 {
+			t.Errorf("ran %d steps, want 3", len(*ran))
+		}
+
+		plan, err = PlanSudoUninstall(binaryPath)
+		if err != nil {
+			t.Fatalf("PlanSudoUninstall() error: %v", err)
+		}
+		if err := SudoUninstall(plan, registry); err != nil {
+			t.Fatalf("SudoUninstall() error: %v", err)
+		}
+		if info, err := os.Lstat(binaryPath); err != nil || !info.Mode().IsRegular() {
+			t.Errorf("%s should be the original again", binaryPath)
+		}
+		if HasSidecar(binaryPath) || HasMetadata(binaryPath) {
+			t.Error("the sidecar and metadata should be gone")
+		}
+		if _, ok := registry.Wrapper(binaryPath); ok {
+			t.Error("the wrapper should be gone from the registry")
+		}
+	})
+
+	t.Run("moves the original back when the symlink fails", func(t *testing.T) {
+		binaryPath, ribbinPath := setup(t)
+		runStepsDirectly(t, "ln")
+		registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+
+		plan, err := PlanSudoInstall(binaryPath, ribbinPath, "")
+		if err != nil {
+			t.Fatalf("PlanSudoInstall() error: %v", err)
+		}
+		if err := SudoInstall(plan, registry, ""); err == nil {
+			t.Fatal("SudoInstall() should fail")
+		}
+		if info, err := os.Lstat(binaryPath); err != nil || !info.Mode().IsRegular() {
+			t.Errorf("%s should be the original again", binaryPath)
+		}
+		if HasSidecar(binaryPath) || len(registry.Wrappers) != 0 {
+			t.Error("nothing should be left wrapped")
+		}
+	})
+
+	t.Run("refuses a sidecar that is already there", func(t *testing.T) {
+		binaryPath, ribbinPath := setup(t)
+		if err := os.WriteFile(binaryPath+".ribbin-original", []byte("backup"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := PlanSudoInstall(binaryPath, ribbinPath, ""); err == nil {
+			t.Error("PlanSudoInstall() should refuse to replace an existing sidecar")
+		}
+	})
+
+	t.Run("transaction rollback unwraps through sudo", func(t *testing.T) {
+		binaryPath, ribbinPath := setup(t)
+		runStepsDirectly(t)
+		registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+		tx := NewTransaction(registry, ribbinPath)
+
+		plan, err := PlanSudoInstall(binaryPath, ribbinPath, "")
+		if err != nil {
+			t.Fatalf("PlanSudoInstall() error: %v", err)
+		}
+		if err := tx.InstallSudo(plan, ""); err != nil {
+			t.Fatalf("InstallSudo() error: %v", err)
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Rollback() error: %v", err)
+		}
+		if info, err := os.Lstat(binaryPath); err != nil || !info.Mode().IsRegular() || HasSidecar(binaryPath) {
+			t.Errorf("%s should be unwrapped", binaryPath)
+		}
+	})
+}
+
+func TestPrivilegedStepString(t *testing.T) {
+	step := PrivilegedStep{Argv: []string{"mv", "-n", "--", "/opt/my tools/x", "/opt/it's"}}
+	want := `sudo mv -n -- '/opt/my tools/x' '/opt/it'\''s'`
+	if got := step.String(); got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// timeoutKillGrace is how long a program stopped for running past its
+// timeout has to exit before it is killed
+const timeoutKillGrace = 10 * time.Second
+
+// programTimeout is how long the wrapper's program may run (see the
+// wrapper's "timeout"). A zero Limit means it may run as long as it likes.
+type programTimeout struct {
+	Limit  time.Duration
+	Signal os.Signal
+	// Message is printed as the program is stopped
+	Message string
+}
+
+// execTimeout is the timeout of the wrapper being run. Run sets it once the
+// wrapper is known.
+var execTimeout programTimeout
+
+// timeoutFor returns the timeout of shimConfig, or none if it has none or
+// it is invalid
+func timeoutFor(shimConfig config.ShimConfig, vars config.MessageVars) programTimeout {
+	if shimConfig.Timeout == "" {
+		return programTimeout{}
+	}
+	limit, err := config.ParseTimeout(shimConfig.Timeout)
+	if err != nil {
+		return programTimeout{}
+	}
+	return programTimeout{
+		Limit:   limit,
+		Signal:  signalNamed(shimConfig.TimeoutSignalName()),
+		Message: shimConfig.RenderTimeoutMessage(vars),
+	}
+}
+
+// stop ends a program that ran past the limit: it prints the message and
+// sends the signal, then kills the program if it is still running
+// timeoutKillGrace later
+func (t programTimeout) stop(process *os.Process) {
+	fmt.Fprintln(os.Stderr, t.Message)
+	process.Signal(t.Signal)
+	if t.Signal != os.Kill {
+		time.AfterFunc(timeoutKillGrace, func() { process.Kill() })
+	}
+}
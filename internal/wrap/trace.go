@@ -0,0 +1,344 @@
+package wrap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// Trace is a record of how a wrapper decided what to do with one invocation.
+// With RIBBIN_TRACE=<file>, Run appends one per invocation to the file as a
+// line of JSON, just before it acts on the decision. See 'ribbin trace explain'.
+type Trace struct {
+	Time          time.Time `json:"time"`
+	RibbinVersion string    `json:"ribbin_version"`
+	PID           int       `json:"pid"`
+	PPID          int       `json:"ppid"`
+	Cwd           string    `json:"cwd"`
+	// Argv is the command line the wrapper was invoked with
+	Argv []string `json:"argv"`
+	// Command is the wrapped command's name
+	Command string `json:"command"`
+	// ShimPath is the wrapper symlink that was run
+	ShimPath string `json:"shim_path"`
+	// OriginalPath is the sidecar holding the original binary
+	OriginalPath string `json:"original_path"`
+	// Registry is the part of the registry that bears on the decision
+	Registry *TraceRegistry `json:"registry,omitempty"`
+	// ConfigPath is the governing config, if one was found
+	ConfigPath string `json:"config_path,omitempty"`
+	// DecisionCache is "hit" if the wrapper came from the decision cache
+	DecisionCache string `json:"decision_cache,omitempty"`
+	// Scope is the scope matching Cwd, if any
+	Scope *TraceScope `json:"scope,omitempty"`
+	// Wrapper is the effective wrapper for Command
+	Wrapper *config.ShimConfig `json:"wrapper,omitempty"`
+	// Provenance is where Wrapper was defined, followed by each definition it overrode
+	Provenance []TraceSource `json:"provenance,omitempty"`
+	// Steps are the checks made, in order, up to the deciding one
+	Steps []TraceStep `json:"steps"`
+	// Outcome is BLOCKED, PASS or REDIRECT (see verboseLogDecision)
+	Outcome string `json:"outcome"`
+	Reason  string `json:"reason"`
+}
+
+// TraceRegistry is a snapshot of the registry state a decision depends on
+type TraceRegistry struct {
+	GlobalActive     bool                 `json:"global_active"`
+	ActiveConfigs    []string             `json:"active_configs,omitempty"`
+	ShellActivations []int                `json:"shell_activations,omitempty"`
+	Wrapper          *config.WrapperEntry `json:"wrapper,omitempty"`
+	Snooze           *config.SnoozeEntry  `json:"snooze,omitempty"`
+}
+
+// TraceScope identifies a matched scope
+type TraceScope struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// TraceSource is one place a wrapper was defined
+type TraceSource struct {
+	File     string `json:"file"`
+	Fragment string `json:"fragment"`
+}
+
+// TraceStep is one check made while deciding
+type TraceStep struct {
+	Check  string `json:"check"`
+	Result string `json:"result"`
+}
+
+// tracer is the trace of the running invocation, or nil when neither
+// RIBBIN_TRACE nor RIBBIN_EXPLAIN is set
+var tracer *Trace
+
+// tracePath is the file tracer is appended to, if any
+var tracePath string
+
+// explaining is true until the decision has been explained with
+// RIBBIN_EXPLAIN=1 (see explainDecision)
+var explaining bool
+
+// startTrace begins tracing this invocation if RIBBIN_TRACE or
+// RIBBIN_EXPLAIN is set
+func startTrace(argv0 string, args []string, cmdName, originalPath string) {
+	tracePath = os.Getenv("RIBBIN_TRACE")
+	explaining = os.Getenv("RIBBIN_EXPLAIN") == "1"
+	if tracePath == "" && !explaining {
+		return
+	}
+	cwd, _ := os.Getwd()
+	tracer = &Trace{
+		Time:          time.Now(),
+		RibbinVersion: Version,
+		PID:           os.Getpid(),
+		PPID:          os.Getppid(),
+		Cwd:           cwd,
+		Argv:          append([]string{argv0}, args...),
+		Command:       cmdName,
+		ShimPath:      argv0,
+		OriginalPath:  originalPath,
+		Steps:         []TraceStep{},
+	}
+}
+
+// traceStep records a check in the trace
+func traceStep(check, format string, args ...interface{}) {
+	if tracer == nil {
+		return
+	}
+	tracer.Steps = append(tracer.Steps, TraceStep{Check: check, Result: fmt.Sprintf(format, args...)})
+}
+
+// traceRegistry records the registry state that bears on cmdName, wrapped at
+// binaryPath
+func traceRegistry(registry *config.Registry, cmdName, binaryPath string) {
+	if tracer == nil {
+		return
+	}
+	snapshot := &TraceRegistry{GlobalActive: registry.GlobalActive}
+	for path := range registry.ConfigActivations {
+		snapshot.ActiveConfigs = append(snapshot.ActiveConfigs, path)
+	}
+	sort.Strings(snapshot.ActiveConfigs)
+	for pid := range registry.ShellActivations {
+		snapshot.ShellActivations = append(snapshot.ShellActivations, pid)
+	}
+	sort.Ints(snapshot.ShellActivations)
+	if entry, ok := registry.LookupWrapper(cmdName, binaryPath); ok {
+		snapshot.Wrapper = &entry
+	}
+	if snooze, ok := registry.ActiveSnooze(cmdName, time.Now()); ok {
+		snapshot.Snooze = &snooze
+	}
+	tracer.Registry = snapshot
+}
+
+// traceLookup records the config, scope and wrapper found for the command,
+// and where the wrapper was defined. Provenance isn't cached, so it is
+// resolved again here, only when tracing.
+func traceLookup(registry *config.Registry, lookup wrapperLookup) {
+	if tracer == nil {
+		return
+	}
+	tracer.ConfigPath = lookup.ConfigPath
+	if lookup.Cached {
+		tracer.DecisionCache = "hit"
+	}
+	if lookup.ConfigPath == "" {
+		traceStep("config", "none found from %s", tracer.Cwd)
+		return
+	}
+	userPath, _ := config.FindUserConfig()
+	userOnly := config.IsUserConfig(lookup.ConfigPath)
+	switch {
+	case userOnly:
+		traceStep("config", "%s (user config)", lookup.ConfigPath)
+	case userPath != "" && userPath != lookup.ConfigPath:
+		traceStep("config", "%s, over user config %s", lookup.ConfigPath, userPath)
+	default:
+		traceStep("config", "%s", lookup.ConfigPath)
+	}
+
+	reason := activationReason(registry, lookup.ConfigPath)
+	if reason == "" {
+		traceStep("activation", "not active")
+		return
+	}
+	traceStep("activation", "active via %s", reason)
+
+	var projectConfig *config.ProjectConfig
+	var err error
+	if !userOnly {
+		projectConfig, err = config.LoadProjectConfig(lookup.ConfigPath)
+	}
+	if err == nil {
+		projectPath := lookup.ConfigPath
+		if userOnly {
+			projectPath = ""
+		}
+		matched, resolved := explainWrapper(projectConfig, projectPath, userPath, tracer.Command)
+		if userOnly {
+			traceStep("scope", "user config wrappers")
+		} else if matched != nil {
+			tracer.Scope = &TraceScope{Name: matched.Name, Path: matched.Config.Path}
+			traceStep("scope", "%q", matched.Name)
+		} else {
+			traceStep("scope", "root wrappers (no scope matches this directory)")
+		}
+		if resolved != nil {
+			for source := &resolved.Source; source != nil; source = source.Overrode {
+				tracer.Provenance = append(tracer.Provenance, TraceSource{File: source.FilePath, Fragment: source.Fragment})
+			}
+		}
+	}
+
+	if !lookup.Exists {
+		traceStep("wrapper", "%s", lookup.Reason)
+		return
+	}
+	shim := lookup.Shim
+	tracer.Wrapper = &shim
+	traceStep("wrapper", "action %q", shim.Action)
+}
+
+// traceDecision records the outcome, explains it if RIBBIN_EXPLAIN=1, and
+// appends the trace to its file. Failures are reported but never stop the
+// command.
+func traceDecision(outcome, reason string) {
+	if tracer == nil {
+		return
+	}
+	explainDecision(outcome, reason)
+	if tracePath == "" {
+		tracer = nil
+		return
+	}
+	tracer.Outcome = outcome
+	tracer.Reason = reason
+
+	data, err := json.Marshal(tracer)
+	tracer = nil
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ribbin: cannot write trace: %v\n", err)
+		return
+	}
+	f, err := os.OpenFile(tracePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ribbin: cannot write trace: %v\n", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "ribbin: cannot write trace: %v\n", err)
+	}
+}
+
+// explainDecision prints the paragraph RIBBIN_EXPLAIN=1 asks for to stderr,
+// once per invocation. Run calls it before printing a block message, so the
+// explanation comes first; otherwise traceDecision does.
+func explainDecision(outcome, reason string) {
+	if !explaining || tracer == nil {
+		return
+	}
+	explaining = false
+	trace := *tracer
+	trace.Outcome = outcome
+	trace.Reason = reason
+	fmt.Fprintln(os.Stderr, explanationText(&trace))
+}
+
+// explanationText summarizes a trace in one paragraph: the decision, the
+// config and scope that matched, the definitions the wrapper came from, and
+// the checks that led to the decision
+func explanationText(trace *Trace) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ribbin explain: %s -> %s", trace.Command, trace.Outcome)
+	if trace.Reason != "" {
+		fmt.Fprintf(&b, " (%s)", trace.Reason)
+	}
+	b.WriteString(".")
+
+	// The config, scope and wrapper steps are told in prose; the rest are
+	// listed as checks
+	var checks []string
+	var activation, scope string
+	for _, step := range trace.Steps {
+		switch step.Check {
+		case "config", "wrapper":
+		case "activation":
+			activation = step.Result
+		case "scope":
+			scope = step.Result
+		default:
+			checks = append(checks, step.Check+" "+step.Result)
+		}
+	}
+
+	if trace.ConfigPath == "" {
+		fmt.Fprintf(&b, " No ribbin config applies in %s.", trace.Cwd)
+	} else {
+		fmt.Fprintf(&b, " Config %s", trace.ConfigPath)
+		if activation != "" {
+			fmt.Fprintf(&b, " is %s", activation)
+		}
+		switch {
+		case trace.Scope != nil:
+			fmt.Fprintf(&b, ", scope %q (%s) matches", trace.Scope.Name, trace.Scope.Path)
+		case scope != "":
+			fmt.Fprintf(&b, ", %s", scope)
+		}
+		if trace.DecisionCache != "" {
+			fmt.Fprintf(&b, " (decision cache %s)", trace.DecisionCache)
+		}
+		b.WriteString(".")
+	}
+
+	if trace.Wrapper != nil {
+		fmt.Fprintf(&b, " The wrapper's action is %q", trace.Wrapper.Action)
+		for i, source := range trace.Provenance {
+			if i == 0 {
+				fmt.Fprintf(&b, ", defined in %s#%s", source.File, source.Fragment)
+			} else {
+				fmt.Fprintf(&b, ", overriding %s#%s", source.File, source.Fragment)
+			}
+		}
+		b.WriteString(".")
+	}
+
+	if len(checks) > 0 {
+		fmt.Fprintf(&b, " Checks: %s.", strings.Join(checks, "; "))
+	}
+	return b.String()
+}
+
+// ReadTraces reads the traces appended to a RIBBIN_TRACE file, oldest first
+func ReadTraces(path string) ([]Trace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var traces []Trace
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var trace Trace
+		if err := json.Unmarshal(scanner.Bytes(), &trace); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid trace: %w", path, line, err)
+		}
+		traces = append(traces, trace)
+	}
+	return traces, scanner.Err()
+}
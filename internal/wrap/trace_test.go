@@ -0,0 +1,126 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestTrace(t *testing.T) {
+	defer func() { tracer = nil }()
+	tracePath := filepath.Join(t.TempDir(), "trace.json")
+
+	t.Run("disabled without RIBBIN_TRACE", func(t *testing.T) {
+		t.Setenv("RIBBIN_TRACE", "")
+		startTrace("/bin/tsc", nil, "tsc", "/bin/tsc.ribbin-original")
+		traceStep("RIBBIN_BYPASS", "not set")
+		traceDecision("PASS", "no ribbin.jsonc found")
+		if tracer != nil {
+			t.Error("tracer should be nil")
+		}
+	})
+
+	t.Run("appends one trace per decision", func(t *testing.T) {
+		t.Setenv("RIBBIN_TRACE", tracePath)
+
+		registry := &config.Registry{
+			Wrappers:          map[string]config.WrapperEntry{"/bin/tsc": {Original: "/bin/tsc", Config: "/p/ribbin.jsonc"}},
+			ConfigActivations: map[string]config.ConfigActivationEntry{"/p/ribbin.jsonc": {}},
+			ShellActivations:  map[int]config.ShellActivationEntry{42: {}},
+			Snoozes:           map[string]config.SnoozeEntry{"tsc": {Until: time.Now().Add(time.Hour)}},
+		}
+		for _, outcome := range []string{"BLOCKED", "PASS"} {
+			startTrace("/bin/tsc", []string{"--noEmit"}, "tsc", "/bin/tsc.ribbin-original")
+			traceRegistry(registry, "tsc", "/bin/tsc")
+			traceStep("RIBBIN_BYPASS", "not set")
+			traceDecision(outcome, "because")
+			if tracer != nil {
+				t.Error("tracer should be reset once written")
+			}
+		}
+
+		traces, err := ReadTraces(tracePath)
+		if err != nil {
+			t.Fatalf("ReadTraces error: %v", err)
+		}
+		if len(traces) != 2 {
+			t.Fatalf("expected 2 traces, got %d", len(traces))
+		}
+		first := traces[0]
+		if first.Outcome != "BLOCKED" || first.Reason != "because" || traces[1].Outcome != "PASS" {
+			t.Errorf("outcomes = %s, %s", first.Outcome, traces[1].Outcome)
+		}
+		if len(first.Argv) != 2 || first.Argv[1] != "--noEmit" || first.Command != "tsc" || first.PID != os.Getpid() {
+			t.Errorf("trace = %+v", first)
+		}
+		if len(first.Steps) != 1 || first.Steps[0].Check != "RIBBIN_BYPASS" {
+			t.Errorf("steps = %+v", first.Steps)
+		}
+		r := first.Registry
+		if r == nil || r.Wrapper == nil || r.Snooze == nil || len(r.ActiveConfigs) != 1 || len(r.ShellActivations) != 1 {
+			t.Errorf("registry snapshot = %+v", r)
+		}
+	})
+
+	t.Run("reports invalid lines", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bad.json")
+		os.WriteFile(path, []byte("{}\nnot json\n"), 0644)
+		if _, err := ReadTraces(path); err == nil {
+			t.Error("expected error for an invalid line")
+		}
+	})
+}
+
+func TestExplanationText(t *testing.T) {
+	trace := &Trace{
+		Command:    "tsc",
+		Cwd:        "/p/app",
+		ConfigPath: "/p/ribbin.jsonc",
+		Scope:      &TraceScope{Name: "app", Path: "app"},
+		Wrapper:    &config.ShimConfig{Action: "block"},
+		Provenance: []TraceSource{{File: "/p/team.jsonc", Fragment: "root"}, {File: "/p/ribbin.jsonc", Fragment: "root"}},
+		Steps: []TraceStep{
+			{Check: "RIBBIN_BYPASS", Result: "not set"},
+			{Check: "config", Result: "/p/ribbin.jsonc"},
+			{Check: "activation", Result: "active via global activation"},
+			{Check: "scope", Result: `"app"`},
+			{Check: "wrapper", Result: `action "block"`},
+			{Check: "snooze", Result: "none"},
+		},
+		Outcome: "BLOCKED",
+		Reason:  `action "block"`,
+	}
+	want := `ribbin explain: tsc -> BLOCKED (action "block"). Config /p/ribbin.jsonc is active via global activation, scope "app" (app) matches. ` +
+		`The wrapper's action is "block", defined in /p/team.jsonc#root, overriding /p/ribbin.jsonc#root. Checks: RIBBIN_BYPASS not set; snooze none.`
+	if got := explanationText(trace); got != want {
+		t.Errorf("explanationText =\n%s\nwant\n%s", got, want)
+	}
+
+	none := &Trace{Command: "tsc", Cwd: "/tmp", Outcome: "PASS", Reason: "no ribbin.jsonc found"}
+	if got := explanationText(none); got != "ribbin explain: tsc -> PASS (no ribbin.jsonc found). No ribbin config applies in /tmp." {
+		t.Errorf("explanationText = %s", got)
+	}
+}
+
+func TestExplainWithoutTraceFile(t *testing.T) {
+	defer func() { tracer = nil }()
+	t.Setenv("RIBBIN_TRACE", "")
+	t.Setenv("RIBBIN_EXPLAIN", "1")
+
+	startTrace("/bin/tsc", nil, "tsc", "/bin/tsc.ribbin-original")
+	if tracer == nil {
+		t.Fatal("RIBBIN_EXPLAIN should collect the trace")
+	}
+	explainDecision("BLOCKED", "because")
+	if explaining {
+		t.Error("the decision should only be explained once")
+	}
+	traceDecision("BLOCKED", "because")
+	if tracer != nil {
+		t.Error("tracer should be reset without a trace file")
+	}
+}
@@ -0,0 +1,189 @@
+package wrap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// Transaction groups a batch of Install calls so that either all of them take
+// effect or none do. Each successful install is recorded; Rollback undoes them
+// in reverse order and restores the registry entries they replaced. Install
+// may be called from several goroutines at once, as a Pool does.
+//
+// Example:
+//
+//	tx := wrap.NewTransaction(registry, ribbinPath)
+//	for _, path := range paths {
+//	    if err := tx.Install(path, configPath); err != nil {
+//	        tx.Rollback()
+//	        return err
+//	    }
+//	}
+//	tx.Commit()
+type Transaction struct {
+	mu         sync.Mutex
+	registry   *config.Registry
+	ribbinPath string
+	installed  []installRecord
+}
+
+// installRecord remembers what an Install changed so it can be undone
+type installRecord struct {
+	binaryPath string
+	// previousEntry is the registry entry Install overwrote, if any
+	previousEntry *config.WrapperEntry
+	// createdTargetSidecar is the extra sidecar Install creates next to a
+	// symlink's final target, if it did not exist before
+	createdTargetSidecar string
+	// sudo is set for binaries wrapped through sudo, unwrapped through it too
+	sudo bool
+}
+
+// NewTransaction starts a transaction that installs shims pointing at ribbinPath
+// and records them in registry.
+func NewTransaction(registry *config.Registry, ribbinPath string) *Transaction {
+	return &Transaction{
+		registry:   registry,
+		ribbinPath: ribbinPath,
+	}
+}
+
+// Install wraps binaryPath as part of the transaction.
+func (tx *Transaction) Install(binaryPath, configPath string) error {
+	record := installRecord{binaryPath: binaryPath}
+
+	tx.mu.Lock()
+	if entry, ok := tx.registry.Wrapper(binaryPath); ok {
+		record.previousEntry = &entry
+	}
+	tx.mu.Unlock()
+
+	// Install copies the sidecar to a symlink's final target when missing;
+	// note whether that will happen so rollback can remove it again
+	if target, err := filepath.EvalSymlinks(binaryPath); err == nil && target != binaryPath {
+		targetSidecar := target + ".ribbin-original"
+		if _, err := os.Lstat(targetSidecar); os.IsNotExist(err) {
+			record.createdTargetSidecar = targetSidecar
+		}
+	}
+
+	// Install records into a registry of its own, merged under the lock, so
+	// installs can run at once
+	installed := &config.Registry{}
+	if err := Install(binaryPath, tx.ribbinPath, installed, configPath); err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	for _, entry := range installed.Wrappers {
+		tx.registry.AddWrapper(entry)
+	}
+	tx.installed = append(tx.installed, record)
+	return nil
+}
+
+// InstallShadow wraps binaryPath by shadowing it (see InstallShadow) as
+// part of the transaction. Rollback removes the shadowing wrapper.
+func (tx *Transaction) InstallShadow(binaryPath, configPath string) error {
+	shadowPath, err := ShadowPath(binaryPath)
+	if err != nil {
+		return err
+	}
+	record := installRecord{binaryPath: shadowPath}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if entry, ok := tx.registry.Wrapper(shadowPath); ok {
+		record.previousEntry = &entry
+	}
+	if err := InstallShadow(binaryPath, tx.ribbinPath, tx.registry, configPath); err != nil {
+		return err
+	}
+	tx.installed = append(tx.installed, record)
+	return nil
+}
+
+// InstallSudo wraps a binary as part of the transaction by running plan, from
+// PlanSudoInstall, through sudo. Rollback unwraps it through sudo as well.
+func (tx *Transaction) InstallSudo(plan *SudoPlan, configPath string) error {
+	record := installRecord{binaryPath: plan.BinaryPath, sudo: true}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if entry, ok := tx.registry.Wrapper(plan.BinaryPath); ok {
+		record.previousEntry = &entry
+	}
+	if err := SudoInstall(plan, tx.registry, configPath); err != nil {
+		return err
+	}
+	tx.installed = append(tx.installed, record)
+	return nil
+}
+
+// Installed returns the binary paths wrapped so far, in install order.
+func (tx *Transaction) Installed() []string {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	paths := make([]string, len(tx.installed))
+	for i, record := range tx.installed {
+		paths[i] = record.binaryPath
+	}
+	return paths
+}
+
+// Rollback unwraps everything installed by the transaction, newest first, and
+// restores the registry entries they replaced. It keeps going after errors so
+// as much as possible is restored, and returns all errors joined together.
+func (tx *Transaction) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	var errs []error
+
+	for i := len(tx.installed) - 1; i >= 0; i-- {
+		record := tx.installed[i]
+
+		if err := record.uninstall(tx.registry); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", record.binaryPath, err))
+			continue
+		}
+
+		if record.createdTargetSidecar != "" {
+			if err := os.Remove(record.createdTargetSidecar); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, fmt.Errorf("%s: cannot remove target sidecar: %w", record.binaryPath, err))
+			}
+		}
+
+		if record.previousEntry != nil {
+			tx.registry.AddWrapper(*record.previousEntry)
+		}
+	}
+
+	tx.installed = nil
+	return errors.Join(errs...)
+}
+
+// uninstall unwraps the record's binary the way it was wrapped
+func (record installRecord) uninstall(registry *config.Registry) error {
+	if !record.sudo {
+		return Uninstall(record.binaryPath, registry)
+	}
+	plan, err := PlanSudoUninstall(record.binaryPath)
+	if err != nil {
+		return err
+	}
+	return SudoUninstall(plan, registry)
+}
+
+// Commit ends the transaction, keeping everything installed. Rollback after
+// Commit is a no-op.
+func (tx *Transaction) Commit() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.installed = nil
+}
@@ -0,0 +1,147 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestTransaction(t *testing.T) {
+	setup := func(t *testing.T) (dir, ribbinPath string, registry *config.Registry) {
+		t.Helper()
+		dir = t.TempDir()
+		ribbinPath = filepath.Join(dir, "ribbin")
+		if err := os.WriteFile(ribbinPath, []byte("#!/bin/sh\necho ribbin"), 0755); err != nil {
+			t.Fatalf("failed to create ribbin: %v", err)
+		}
+		registry = &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+		return dir, ribbinPath, registry
+	}
+	createBinary := func(t *testing.T, path string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("#!/bin/sh\necho original"), 0755); err != nil {
+			t.Fatalf("failed to create binary: %v", err)
+		}
+	}
+	assertUnwrapped := func(t *testing.T, path string) {
+		t.Helper()
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatalf("binary missing after rollback: %v", err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			t.Errorf("%s should be restored to a regular file", path)
+		}
+		if HasSidecar(path) {
+			t.Errorf("%s should have no sidecar after rollback", path)
+		}
+		if HasMetadata(path) {
+			t.Errorf("%s should have no metadata after rollback", path)
+		}
+	}
+
+	t.Run("rollback restores every installed binary", func(t *testing.T) {
+		dir, ribbinPath, registry := setup(t)
+		tx := NewTransaction(registry, ribbinPath)
+
+		var paths []string
+		for _, name := range []string{"one", "two", "three"} {
+			path := filepath.Join(dir, name)
+			createBinary(t, path)
+			if err := tx.Install(path, "/project/ribbin.jsonc"); err != nil {
+				t.Fatalf("Install(%s) error: %v", name, err)
+			}
+			paths = append(paths, path)
+		}
+
+		// Fourth install fails: the binary doesn't exist
+		if err := tx.Install(filepath.Join(dir, "missing"), "/project/ribbin.jsonc"); err == nil {
+			t.Fatal("expected install of missing binary to fail")
+		}
+		if got := len(tx.Installed()); got != 3 {
+			t.Fatalf("Installed() = %d entries, want 3", got)
+		}
+
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Rollback error: %v", err)
+		}
+		for _, path := range paths {
+			assertUnwrapped(t, path)
+		}
+		if len(registry.Wrappers) != 0 {
+			t.Errorf("registry should be empty after rollback, got %v", registry.Wrappers)
+		}
+	})
+
+	t.Run("rollback restores replaced registry entries", func(t *testing.T) {
+		dir, ribbinPath, registry := setup(t)
+		path := filepath.Join(dir, "tool")
+		createBinary(t, path)
+		previous := config.WrapperEntry{Original: path, Config: "/other/ribbin.jsonc"}
+		registry.AddWrapper(previous)
+		other := config.WrapperEntry{Original: "/elsewhere/tool", Config: "/other/ribbin.jsonc"}
+		registry.AddWrapper(other)
+
+		tx := NewTransaction(registry, ribbinPath)
+		if err := tx.Install(path, "/project/ribbin.jsonc"); err != nil {
+			t.Fatalf("Install error: %v", err)
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Rollback error: %v", err)
+		}
+
+		if entry, _ := registry.Wrapper(path); entry != previous {
+			t.Errorf("registry entry = %+v, want %+v", entry, previous)
+		}
+		if entry, _ := registry.Wrapper(other.Original); entry != other {
+			t.Errorf("another binary with the same name should be untouched, got %+v", entry)
+		}
+	})
+
+	t.Run("rollback removes sidecar created at symlink target", func(t *testing.T) {
+		dir, ribbinPath, registry := setup(t)
+		target := filepath.Join(dir, "real-tool")
+		createBinary(t, target)
+		link := filepath.Join(dir, "tool")
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+
+		tx := NewTransaction(registry, ribbinPath)
+		if err := tx.Install(link, "/project/ribbin.jsonc"); err != nil {
+			t.Fatalf("Install error: %v", err)
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Rollback error: %v", err)
+		}
+
+		if _, err := os.Lstat(target + ".ribbin-original"); !os.IsNotExist(err) {
+			t.Error("target sidecar created by the transaction should be removed")
+		}
+		if got, err := os.Readlink(link); err != nil || got != target {
+			t.Errorf("symlink should point back at %s, got %q (%v)", target, got, err)
+		}
+	})
+
+	t.Run("commit keeps installs and makes rollback a no-op", func(t *testing.T) {
+		dir, ribbinPath, registry := setup(t)
+		path := filepath.Join(dir, "kept")
+		createBinary(t, path)
+
+		tx := NewTransaction(registry, ribbinPath)
+		if err := tx.Install(path, "/project/ribbin.jsonc"); err != nil {
+			t.Fatalf("Install error: %v", err)
+		}
+		tx.Commit()
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Rollback error: %v", err)
+		}
+
+		if !HasSidecar(path) {
+			t.Error("committed install should remain wrapped")
+		}
+	})
+}
@@ -0,0 +1,302 @@
+package wrap
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// "redirect": "builtin:trash" on an rm wrapper moves what rm would delete to
+// the trash, so teams don't each write their own safe-rm script. The
+// wrapper reads the command line as rm would and moves each file, symlink
+// or directory with rename, never copying: the trash in the home directory
+// when the file is on the same filesystem, and otherwise a trash at the top
+// of the file's filesystem. Where neither works the file is left alone and
+// reported; it is never deleted.
+
+// errNoSuchFile is the error for a path that doesn't exist, which -f ignores
+var errNoSuchFile = errors.New("no such file or directory")
+
+// trashOptions are the rm options that change what is moved to the trash
+type trashOptions struct {
+	force     bool
+	recursive bool
+	dirs      bool
+	verbose   bool
+	paths     []string
+}
+
+// parseTrashArgs reads an rm command line. Options that only make rm ask
+// before deleting, or keep it on one filesystem, are accepted and ignored:
+// nothing is deleted, and nothing moves between filesystems.
+func parseTrashArgs(args []string) (trashOptions, error) {
+	var opts trashOptions
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			opts.paths = append(opts.paths, args[i+1:]...)
+			return opts, nil
+		case strings.HasPrefix(arg, "--"):
+			switch name, _, _ := strings.Cut(arg, "="); name {
+			case "--force":
+				opts.force = true
+			case "--recursive":
+				opts.recursive = true
+			case "--dir":
+				opts.dirs = true
+			case "--verbose":
+				opts.verbose = true
+			case "--interactive", "--one-file-system", "--preserve-root", "--no-preserve-root":
+			default:
+				return opts, fmt.Errorf("unrecognized option '%s'", arg)
+			}
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			for _, flag := range arg[1:] {
+				switch flag {
+				case 'f':
+					opts.force = true
+				case 'r', 'R':
+					opts.recursive = true
+				case 'd':
+					opts.dirs = true
+				case 'v':
+					opts.verbose = true
+				case 'i', 'I':
+				default:
+					return opts, fmt.Errorf("invalid option -- '%c'", flag)
+				}
+			}
+		default:
+			opts.paths = append(opts.paths, arg)
+		}
+	}
+	return opts, nil
+}
+
+// runTrash moves the files an rm command line names to the trash, printing
+// errors as rm does under cmdName. Returns 1 if any could not be moved.
+func runTrash(cmdName string, args []string) int {
+	opts, err := parseTrashArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmdName, err)
+		return 1
+	}
+	if len(opts.paths) == 0 {
+		if opts.force {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "%s: missing operand\n", cmdName)
+		return 1
+	}
+
+	home, err := homeTrashCan()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: cannot find the trash: %v\n", cmdName, err)
+		return 1
+	}
+	status := 0
+	for _, path := range opts.paths {
+		dest, err := trashPath(path, opts, home)
+		switch {
+		case err == nil:
+			if opts.verbose {
+				fmt.Printf("moved '%s' to the trash (%s)\n", path, dest)
+			}
+		case opts.force && errors.Is(err, errNoSuchFile):
+		default:
+			fmt.Fprintf(os.Stderr, "%s: cannot remove '%s': %v\n", cmdName, path, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// trashPath checks path as rm would, then moves it to the trash, returning
+// where it went
+func trashPath(path string, opts trashOptions, home trashCan) (string, error) {
+	switch filepath.Base(strings.TrimRight(path, string(filepath.Separator))) {
+	case ".", "..":
+		return "", fmt.Errorf("refusing to remove '.' or '..' directory")
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if abs == string(filepath.Separator) {
+		return "", fmt.Errorf("refusing to remove the root directory")
+	}
+
+	info, err := os.Lstat(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", errNoSuchFile
+		}
+		return "", err
+	}
+	if info.IsDir() && !opts.recursive {
+		if !opts.dirs {
+			return "", fmt.Errorf("is a directory")
+		}
+		if entries, err := os.ReadDir(abs); err != nil {
+			return "", err
+		} else if len(entries) > 0 {
+			return "", fmt.Errorf("directory not empty")
+		}
+	}
+
+	can, err := trashCanFor(abs, home)
+	if err != nil {
+		return "", err
+	}
+	if within(abs, can.files) {
+		return "", fmt.Errorf("already in the trash")
+	}
+	if within(can.files, abs) {
+		return "", fmt.Errorf("refusing to remove a directory holding the trash (%s)", can.files)
+	}
+	return can.put(abs)
+}
+
+// within reports whether path is dir or inside it
+func within(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// trashCan is a trash directory
+type trashCan struct {
+	// files is where trashed files go
+	files string
+	// info is where a .trashinfo file recording the trashed file's path and
+	// deletion time goes, or "" where the trash has none
+	info string
+	// top is the directory the recorded paths are relative to, or "" if
+	// they are absolute
+	top string
+}
+
+// trashCanFor returns the trash abs goes to: home when it's on the same
+// filesystem, and otherwise the trash at the top of abs's filesystem
+func trashCanFor(abs string, home trashCan) (trashCan, error) {
+	if err := os.MkdirAll(home.files, 0700); err != nil {
+		return trashCan{}, fmt.Errorf("cannot create the trash: %w", err)
+	}
+	if home.info != "" {
+		if err := os.MkdirAll(home.info, 0700); err != nil {
+			return trashCan{}, fmt.Errorf("cannot create the trash: %w", err)
+		}
+	}
+	homeDev, err := deviceOf(home.files)
+	if err != nil {
+		return trashCan{}, err
+	}
+	dev, err := deviceOf(filepath.Dir(abs))
+	if err != nil {
+		return trashCan{}, err
+	}
+	if dev == homeDev {
+		return home, nil
+	}
+
+	top := filepath.Dir(abs)
+	for parent := filepath.Dir(top); parent != top; parent = filepath.Dir(top) {
+		if parentDev, err := deviceOf(parent); err != nil || parentDev != dev {
+			break
+		}
+		top = parent
+	}
+	can := volumeTrashCan(top)
+	for _, dir := range []string{can.files, can.info} {
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return trashCan{}, fmt.Errorf("cannot create a trash on its filesystem: %w", err)
+		}
+	}
+	return can, nil
+}
+
+// deviceOf returns the device of the filesystem holding path
+func deviceOf(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("cannot tell the filesystem of %s", path)
+	}
+	return uint64(st.Dev), nil
+}
+
+// put moves abs into the trash under a name not yet taken, recording where
+// it came from where the trash keeps that, and returns where it went
+func (c trashCan) put(abs string) (string, error) {
+	base := filepath.Base(abs)
+	ext := filepath.Ext(base)
+	if ext == base {
+		ext = ""
+	}
+	stem := strings.TrimSuffix(base, ext)
+
+	for n := 1; n < 10000; n++ {
+		name := base
+		if n > 1 {
+			name = fmt.Sprintf("%s.%d%s", stem, n, ext)
+		}
+		dest := filepath.Join(c.files, name)
+		if _, err := os.Lstat(dest); err == nil {
+			continue
+		}
+
+		// Creating the .trashinfo file exclusively claims the name
+		var infoPath string
+		if c.info != "" {
+			infoPath = filepath.Join(c.info, name+".trashinfo")
+			f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+			if os.IsExist(err) {
+				continue
+			}
+			if err != nil {
+				return "", err
+			}
+			_, err = f.WriteString(trashInfo(abs, c.top))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(infoPath)
+				return "", err
+			}
+		}
+
+		if err := os.Rename(abs, dest); err != nil {
+			if infoPath != "" {
+				os.Remove(infoPath)
+			}
+			return "", fmt.Errorf("cannot move to the trash: %w", err)
+		}
+		return dest, nil
+	}
+	return "", fmt.Errorf("cannot find a free name for it in the trash")
+}
+
+// trashInfo returns the .trashinfo contents for abs, as the freedesktop.org
+// trash specification has them, with its path relative to top when top is
+// set
+func trashInfo(abs, top string) string {
+	recorded := abs
+	if top != "" {
+		if rel, err := filepath.Rel(top, abs); err == nil {
+			recorded = rel
+		}
+	}
+	escaped := (&url.URL{Path: filepath.ToSlash(recorded)}).EscapedPath()
+	return fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n", escaped, time.Now().Format("2006-01-02T15:04:05"))
+}
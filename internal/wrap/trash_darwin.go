@@ -0,0 +1,24 @@
+//go:build darwin
+
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// homeTrashCan returns ~/.Trash, the Finder's trash
+func homeTrashCan() (trashCan, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return trashCan{}, err
+	}
+	return trashCan{files: filepath.Join(home, ".Trash")}, nil
+}
+
+// volumeTrashCan returns the user's trash on another volume,
+// $top/.Trashes/$uid
+func volumeTrashCan(top string) trashCan {
+	return trashCan{files: filepath.Join(top, ".Trashes", fmt.Sprintf("%d", os.Getuid()))}
+}
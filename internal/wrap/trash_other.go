@@ -0,0 +1,31 @@
+//go:build !darwin
+
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// homeTrashCan returns the home trash of the freedesktop.org trash
+// specification, $XDG_DATA_HOME/Trash
+func homeTrashCan() (trashCan, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" || !filepath.IsAbs(dataHome) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return trashCan{}, err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	trash := filepath.Join(dataHome, "Trash")
+	return trashCan{files: filepath.Join(trash, "files"), info: filepath.Join(trash, "info")}, nil
+}
+
+// volumeTrashCan returns the user's trash at the top of a filesystem,
+// $top/.Trash-$uid, whose trash info records paths relative to top
+func volumeTrashCan(top string) trashCan {
+	trash := filepath.Join(top, fmt.Sprintf(".Trash-%d", os.Getuid()))
+	return trashCan{files: filepath.Join(trash, "files"), info: filepath.Join(trash, "info"), top: top}
+}
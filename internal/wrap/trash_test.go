@@ -0,0 +1,118 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestParseTrashArgs(t *testing.T) {
+	opts, err := parseTrashArgs([]string{"-rfv", "--interactive=never", "a", "--", "-b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.recursive || !opts.force || !opts.verbose || opts.dirs {
+		t.Errorf("opts = %+v", opts)
+	}
+	if strings.Join(opts.paths, " ") != "a -b" {
+		t.Errorf("paths = %v, want [a -b]", opts.paths)
+	}
+
+	for _, args := range [][]string{{"-x", "a"}, {"--shred", "a"}} {
+		if _, err := parseTrashArgs(args); err == nil {
+			t.Errorf("parseTrashArgs(%v) should fail", args)
+		}
+	}
+}
+
+func TestRunTrash(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+	can, err := homeTrashCan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	work := t.TempDir()
+	write := func(name string) string {
+		t.Helper()
+		path := filepath.Join(work, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("moves files, renaming on collision", func(t *testing.T) {
+		first := write("notes.txt")
+		if code := runTrash("rm", []string{first}); code != 0 {
+			t.Fatalf("exit %d", code)
+		}
+		second := write("notes.txt")
+		if code := runTrash("rm", []string{"-f", second}); code != 0 {
+			t.Fatalf("exit %d", code)
+		}
+		if _, err := os.Lstat(first); !os.IsNotExist(err) {
+			t.Errorf("notes.txt should be gone: %v", err)
+		}
+		for _, name := range []string{"notes.txt", "notes.2.txt"} {
+			if _, err := os.Stat(filepath.Join(can.files, name)); err != nil {
+				t.Errorf("%s should be in the trash: %v", name, err)
+			}
+			if can.info == "" {
+				continue
+			}
+			info, err := os.ReadFile(filepath.Join(can.info, name+".trashinfo"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(info), "Path="+first+"\n") || !strings.Contains(string(info), "DeletionDate=") {
+				t.Errorf("%s.trashinfo = %q", name, info)
+			}
+		}
+	})
+
+	t.Run("directories need -r, or -d when empty", func(t *testing.T) {
+		dir := filepath.Dir(write("build/out.js"))
+		if code := runTrash("rm", []string{dir}); code != 1 {
+			t.Errorf("rm of a directory: exit %d, want 1", code)
+		}
+		if code := runTrash("rm", []string{"-d", dir}); code != 1 {
+			t.Errorf("rm -d of a full directory: exit %d, want 1", code)
+		}
+		if code := runTrash("rm", []string{"-r", dir}); code != 0 {
+			t.Errorf("rm -r: exit %d, want 0", code)
+		}
+		if _, err := os.Stat(filepath.Join(can.files, "build", "out.js")); err != nil {
+			t.Errorf("build should be in the trash whole: %v", err)
+		}
+	})
+
+	t.Run("missing files and refusals", func(t *testing.T) {
+		missing := filepath.Join(work, "missing")
+		if code := runTrash("rm", []string{missing}); code != 1 {
+			t.Errorf("rm of a missing file: exit %d, want 1", code)
+		}
+		if code := runTrash("rm", []string{"-f", missing}); code != 0 {
+			t.Errorf("rm -f of a missing file: exit %d, want 0", code)
+		}
+		if code := runTrash("rm", nil); code != 1 {
+			t.Errorf("rm with no operand: exit %d, want 1", code)
+		}
+		if code := runTrash("rm", []string{"-rf", work + "/.."}); code != 1 {
+			t.Errorf("rm -rf ..: exit %d, want 1", code)
+		}
+		if code := runTrash("rm", []string{"-rf", filepath.Join(can.files, "notes.txt")}); code != 1 {
+			t.Errorf("rm of a file in the trash: exit %d, want 1", code)
+		}
+		if code := runTrash("rm", []string{"-rf", home}); code != 1 {
+			t.Errorf("rm of a directory holding the trash: exit %d, want 1", code)
+		}
+	})
+}
@@ -15,8 +15,11 @@ func verboseLog(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, "[ribbin] %s\n", msg)
 }
 
-// verboseLogDecision logs a shim decision in the standard format.
+// verboseLogDecision logs a shim decision in the standard format, finishes
+// the trace if RIBBIN_TRACE is set, and counts it if metrics are enabled.
 // action should be one of: BLOCKED, PASS, REDIRECT
 func verboseLogDecision(cmd, action, reason string) {
 	verboseLog("%s -> %s: %s", cmd, action, reason)
+	traceDecision(action, reason)
+	recordMetric(action)
 }
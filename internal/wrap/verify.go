@@ -0,0 +1,181 @@
+package wrap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/happycollision/ribbin/internal/config"
+)
+
+// Target statuses in a CoverageReport
+const (
+	TargetWrapped    = "wrapped"     // wrapped, and ribbin is active for it
+	TargetNotWrapped = "not wrapped" // the binary exists but has no wrapper
+	TargetInactive   = "inactive"    // wrapped, but no activation covers it
+	TargetNotFound   = "not found"   // not installed, so there is nothing to wrap
+)
+
+// CoverageReport says whether a config's wrappers are in effect: whether
+// every binary they target is wrapped, and ribbin is active for them.
+type CoverageReport struct {
+	Config string `json:"config"`
+	// Activation describes what makes ribbin active for the config, or is
+	// empty if nothing does
+	Activation string           `json:"activation,omitempty"`
+	Targets    []TargetCoverage `json:"targets"`
+	// OK is true when no target is TargetNotWrapped or TargetInactive
+	OK bool `json:"ok"`
+}
+
+// TargetCoverage is the state of one binary a wrapper targets
+type TargetCoverage struct {
+	Command string `json:"command"`
+	Path    string `json:"path,omitempty"`
+	Status  string `json:"status"`
+	// Required is set when the config requires the command to be wrapped
+	// ("require": {"wrapped": [...]}), so it fails the check even when it
+	// isn't installed
+	Required bool `json:"required,omitempty"`
+}
+
+// Failing returns the targets that keep the report from being OK
+func (r *CoverageReport) Failing() []TargetCoverage {
+	var failing []TargetCoverage
+	for _, target := range r.Targets {
+		if target.Status == TargetNotWrapped || target.Status == TargetInactive ||
+			(target.Required && target.Status == TargetNotFound) {
+			failing = append(failing, target)
+		}
+	}
+	return failing
+}
+
+// CheckCoverage reports whether the wrappers of the config at configPath,
+// root and scoped, are in effect on this machine. A wrapper's targets are
+// its paths, or the command on PATH if it has none, plus any binary the
+// registry has wrapped for the config under its name (like ones found by
+// 'ribbin wrap --auto'). Binaries that aren't installed don't fail the
+// check, unless the config, or a parent config it merges with, requires
+// the command to be wrapped.
+func CheckCoverage(configPath string, registry *config.Registry) (*CoverageReport, error) {
+	projectConfig, err := config.LoadProjectConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappers := configWrappers(projectConfig)
+	required := make(map[string]bool)
+	for _, name := range requiredCommands(configPath) {
+		required[name] = true
+		if _, ok := wrappers[name]; !ok {
+			wrappers[name] = config.WrapperConfig{}
+		}
+	}
+
+	act := activationFor(registry, configPath)
+	report := &CoverageReport{Config: configPath, Activation: act.String(), OK: true}
+	for _, name := range sortedWrapperNames(wrappers) {
+		for _, path := range coverageTargets(name, wrappers[name], configPath, registry) {
+			target := TargetCoverage{Command: name, Path: path, Required: required[name]}
+			switch {
+			case path == "":
+				target.Status = TargetNotFound
+			case !isWrapper(path):
+				if _, err := os.Stat(path); err != nil {
+					target.Status = TargetNotFound
+				} else {
+					target.Status = TargetNotWrapped
+				}
+			case !act.coversSomewhere(wrappers[name]):
+				target.Status = TargetInactive
+			default:
+				target.Status = TargetWrapped
+			}
+			report.Targets = append(report.Targets, target)
+		}
+	}
+	report.OK = len(report.Failing()) == 0
+	return report, nil
+}
+
+// configWrappers returns a config's root and scoped wrappers by name, a
+// scope's definition taking the place of the root's
+func configWrappers(projectConfig *config.ProjectConfig) map[string]config.WrapperConfig {
+	wrappers := make(map[string]config.WrapperConfig)
+	for name, w := range projectConfig.Wrappers {
+		wrappers[name] = w
+	}
+	for _, scope := range projectConfig.Scopes {
+		for name, w := range scope.Wrappers {
+			wrappers[name] = w
+		}
+	}
+	return wrappers
+}
+
+func sortedWrapperNames(wrappers map[string]config.WrapperConfig) []string {
+	names := make([]string, 0, len(wrappers))
+	for name := range wrappers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// coverageTargets returns the binaries the wrapper for name targets, or a
+// single "" if it has no paths and the command isn't on PATH
+func coverageTargets(name string, w config.WrapperConfig, configPath string, registry *config.Registry) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	if len(w.Paths) == 0 {
+		if path, err := ResolveCommand(name); err == nil {
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			add(path)
+		}
+	}
+	for _, p := range w.Paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(configPath), p)
+		}
+		add(filepath.Clean(p))
+	}
+	for _, entry := range registry.WrappersNamed(name) {
+		if entry.Config == configPath {
+			add(entry.Original)
+		}
+	}
+
+	if len(paths) == 0 {
+		return []string{""}
+	}
+	return paths
+}
+
+// isWrapper reports whether path is a ribbin wrapper with its original in place
+func isWrapper(path string) bool {
+	shimmed, err := IsAlreadyShimmed(path)
+	return err == nil && shimmed && HasSidecar(path)
+}
+
+// String summarizes a target for a report line
+func (t TargetCoverage) String() string {
+	status := t.Status
+	if t.Required {
+		status = "required, " + status
+	}
+	if t.Path == "" {
+		return fmt.Sprintf("%s: %s on PATH", t.Command, status)
+	}
+	return fmt.Sprintf("%s: %s (%s)", t.Command, status, t.Path)
+}
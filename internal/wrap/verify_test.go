@@ -0,0 +1,113 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestCheckCoverage(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	binDir := filepath.Join(dir, "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	ribbinPath := filepath.Join(dir, "ribbin")
+	for _, path := range []string{ribbinPath, filepath.Join(binDir, "npm"), filepath.Join(binDir, "tsc")} {
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(dir, "ribbin.jsonc")
+	if err := os.WriteFile(configPath, []byte(`{
+  "wrappers": {
+    "npm": { "action": "block", "paths": ["bin/npm"] },
+    "tsc": { "action": "block", "paths": ["bin/tsc"], "tags": ["node"] },
+    "yarn": { "action": "block", "paths": ["bin/yarn"] }
+  }
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := &config.Registry{
+		Wrappers:          make(map[string]config.WrapperEntry),
+		ShellActivations:  make(map[int]config.ShellActivationEntry),
+		ConfigActivations: make(map[string]config.ConfigActivationEntry),
+	}
+	if err := Install(filepath.Join(binDir, "npm"), ribbinPath, registry, configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := func() map[string]string {
+		t.Helper()
+		report, err := CheckCoverage(configPath, registry)
+		if err != nil {
+			t.Fatalf("CheckCoverage error: %v", err)
+		}
+		got := make(map[string]string)
+		for _, target := range report.Targets {
+			got[target.Command] = target.Status
+		}
+		if report.OK != (len(report.Failing()) == 0) {
+			t.Errorf("OK = %v with failing targets %v", report.OK, report.Failing())
+		}
+		return got
+	}
+
+	got := statuses()
+	if got["npm"] != TargetInactive || got["tsc"] != TargetNotWrapped || got["yarn"] != TargetNotFound {
+		t.Errorf("before activating: %v", got)
+	}
+
+	if err := Install(filepath.Join(binDir, "tsc"), ribbinPath, registry, configPath); err != nil {
+		t.Fatal(err)
+	}
+	registry.AddConfigActivation(configPath, "node")
+	got = statuses()
+	if got["npm"] != TargetInactive || got["tsc"] != TargetWrapped {
+		t.Errorf("activated for tag node: %v", got)
+	}
+
+	registry.GlobalActive = true
+	got = statuses()
+	if got["npm"] != TargetWrapped || got["tsc"] != TargetWrapped || got["yarn"] != TargetNotFound {
+		t.Errorf("globally active: %v", got)
+	}
+}
+
+func TestCheckCoverageRequired(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("PATH", "")
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "ribbin.jsonc")
+	if err := os.WriteFile(configPath, []byte(`{
+  "require": { "wrapped": ["tsc"] },
+  "wrappers": {
+    "yarn": { "action": "block", "paths": ["bin/yarn"] }
+  }
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	registry := &config.Registry{
+		Wrappers:          make(map[string]config.WrapperEntry),
+		ShellActivations:  make(map[int]config.ShellActivationEntry),
+		ConfigActivations: make(map[string]config.ConfigActivationEntry),
+		GlobalActive:      true,
+	}
+
+	report, err := CheckCoverage(configPath, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := report.Failing()
+	if report.OK || len(failing) != 1 || failing[0].Command != "tsc" || !failing[0].Required {
+		t.Fatalf("a required command that isn't installed should fail alone, got %+v", report.Targets)
+	}
+	if got := failing[0].String(); got != "tsc: required, not found on PATH" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,176 @@
+package wrap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// versionCheckTimeout bounds how long the original may take to print its version
+const versionCheckTimeout = 5 * time.Second
+
+// versionCacheEntry is a version output cached for one build of an original
+type versionCacheEntry struct {
+	Stamp  fileStamp `json:"stamp"`
+	Args   []string  `json:"args"`
+	Output string    `json:"output"`
+}
+
+// checkVersion runs the original's version command and matches the output
+// against the wrapper's version policy. Returns the version found and an error
+// if it is out of policy or couldn't be determined.
+func checkVersion(originalPath, cmdName string, vc *config.VersionCheckConfig) (string, error) {
+	output, err := versionOutput(originalPath, cmdName, vc.VersionArgs())
+	if err != nil {
+		return "", err
+	}
+	return vc.Check(output)
+}
+
+// versionOutput returns what the original prints when run with args. The
+// output is cached per binary, keyed on its size and mtime, so the original
+// is only run again after it changes. Version manager shims (scripts, hard
+// links, or binaries like mise that dispatch on the name they are run as)
+// pick a version per directory, so they are run every time.
+func versionOutput(originalPath, cmdName string, args []string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(originalPath)
+	if err != nil {
+		return "", err
+	}
+
+	stamp, ok := stampFile(resolved)
+	cachePath := ""
+	if ok && versionCacheable(resolved, cmdName) {
+		if path, err := versionCachePath(resolved, args); err == nil {
+			cachePath = path
+		}
+	}
+	if cachePath != "" {
+		if entry := lookupVersion(cachePath); entry != nil && entry.Stamp == stamp && slices.Equal(entry.Args, args) {
+			return entry.Output, nil
+		}
+	}
+
+	output, err := runVersionCommand(originalPath, cmdName, args)
+	if err != nil {
+		return "", err
+	}
+	if cachePath != "" {
+		storeVersion(cachePath, versionCacheEntry{Stamp: stamp, Args: args, Output: output})
+	}
+	return output, nil
+}
+
+// runVersionCommand runs the original with args and returns its output
+func runVersionCommand(originalPath, cmdName string, args []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), versionCheckTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, originalPath, args...)
+	// Anything the original runs itself shouldn't be wrapped again
+	cmd.Env = append(withExecEnv(os.Environ()), "RIBBIN_BYPASS=1")
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("'%s %s' timed out after %s", cmdName, strings.Join(args, " "), versionCheckTimeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("'%s %s' failed: %v", cmdName, strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// versionCacheable reports whether the version output of the binary at
+// resolved depends only on the binary: not a script, and named after the
+// command rather than dispatching on the name it was run as. Hard-linked
+// binaries are skipped too, since that is how rustup installs its proxies.
+func versionCacheable(resolved, cmdName string) bool {
+	if filepath.Base(resolved) != cmdName {
+		return false
+	}
+	f, err := os.Open(resolved)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || linkCount(info) > 1 {
+		return false
+	}
+	header := make([]byte, 2)
+	if n, _ := f.Read(header); n == 2 && string(header) == "#!" {
+		return false
+	}
+	return true
+}
+
+// versionCachePath returns where the version output of a binary is cached.
+// The wrapper's env is part of the key, since it can change the version a
+// binary reports (RUSTUP_TOOLCHAIN, for one).
+func versionCachePath(resolved string, args []string) (string, error) {
+	cacheDir, err := security.GetCacheDir()
+	if err != nil {
+		return "", err
+	}
+	// fmt prints maps with sorted keys
+	key := resolved + "\x00" + strings.Join(args, "\x00") + "\x00" + fmt.Sprint(execEnv, execEnvUnset, execEnvAllowOnly)
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(cacheDir, "versions", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+func lookupVersion(path string) *versionCacheEntry {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry versionCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// storeVersion caches a version output. Failures are ignored: the cache only
+// saves time.
+func storeVersion(path string, entry versionCacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".version-*")
+	if err != nil {
+		return
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// printVersionPolicy explains why the original's version is out of policy
+func printVersionPolicy(cmdName string, vc *config.VersionCheckConfig, err error) {
+	if vc.Blocks() {
+		fmt.Fprintf(os.Stderr, "ribbin: refusing to run '%s': %v\n", cmdName, err)
+	} else {
+		fmt.Fprintf(os.Stderr, "ribbin: warning: '%s': %v\n", cmdName, err)
+	}
+	if vc.Message != "" {
+		fmt.Fprintf(os.Stderr, "  %s\n", vc.Message)
+	}
+	if vc.Blocks() && enforcement == "" {
+		fmt.Fprintf(os.Stderr, "  Bypass: RIBBIN_BYPASS=1 %s ...\n", cmdName)
+	}
+}
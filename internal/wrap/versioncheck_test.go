@@ -0,0 +1,119 @@
+package wrap
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestCheckVersion(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	// script writes a fake original that prints version
+	script := func(t *testing.T, version string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "node")
+		if err := os.WriteFile(path, []byte("#!/bin/sh\necho "+version+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("in range", func(t *testing.T) {
+		version, err := checkVersion(script(t, "v20.11.1"), "node", &config.VersionCheckConfig{Range: ">=20"})
+		if err != nil {
+			t.Fatalf("checkVersion error: %v", err)
+		}
+		if version != "20.11.1" {
+			t.Errorf("version = %q, want 20.11.1", version)
+		}
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		_, err := checkVersion(script(t, "v18.19.0"), "node", &config.VersionCheckConfig{Range: ">=20"})
+		if err == nil || !strings.Contains(err.Error(), "not in range") {
+			t.Errorf("expected out of range error, got %v", err)
+		}
+	})
+
+	t.Run("custom args", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "python")
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n[ \"$1\" = -V ] && echo Python 3.12.1\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := checkVersion(path, "python", &config.VersionCheckConfig{Args: []string{"-V"}, Range: "^3.12"}); err != nil {
+			t.Errorf("checkVersion error: %v", err)
+		}
+	})
+
+	t.Run("failing version command", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "node")
+		if err := os.WriteFile(path, []byte("#!/bin/sh\nexit 3\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := checkVersion(path, "node", &config.VersionCheckConfig{Range: ">=20"}); err == nil {
+			t.Error("expected error when the version command fails")
+		}
+	})
+}
+
+func TestVersionOutputCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	echoPath, err := exec.LookPath("echo")
+	if err != nil {
+		t.Skip("echo not found")
+	}
+	resolved, err := filepath.EvalSymlinks(echoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(resolved)
+	if err != nil || strings.HasPrefix(string(content), "#!") {
+		t.Skip("echo is not a binary here")
+	}
+	binary := filepath.Join(t.TempDir(), "echo")
+	if err := os.WriteFile(binary, content, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"v20.1.0"}
+	output, err := versionOutput(binary, "echo", args)
+	if err != nil {
+		t.Fatalf("versionOutput error: %v", err)
+	}
+	if strings.TrimSpace(output) != "v20.1.0" {
+		t.Fatalf("output = %q", output)
+	}
+
+	// The second call must come from the cache: tamper with it to tell
+	cachePath, _ := versionCachePath(binary, args)
+	entry := lookupVersion(cachePath)
+	if entry == nil {
+		t.Fatal("version output should be cached")
+	}
+	entry.Output = "cached"
+	storeVersion(cachePath, *entry)
+	if output, _ := versionOutput(binary, "echo", args); output != "cached" {
+		t.Errorf("output = %q, want cached output", output)
+	}
+
+	// A script is never cached, since version managers pick versions per directory
+	if versionCacheable(binary, "other") {
+		t.Error("binary run under another name should not be cacheable")
+	}
+	proxy := filepath.Join(t.TempDir(), "cargo")
+	if err := os.Link(binary, proxy); err == nil && versionCacheable(proxy, "cargo") {
+		t.Error("hard-linked binaries should not be cacheable")
+	}
+	shim := filepath.Join(t.TempDir(), "node")
+	os.WriteFile(shim, []byte("#!/bin/sh\necho v20\n"), 0755)
+	if versionCacheable(shim, "node") {
+		t.Error("scripts should not be cacheable")
+	}
+}
@@ -0,0 +1,143 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	"github.com/happycollision/ribbin/internal/security"
+)
+
+// Watcher notices when a package manager may have recreated binaries that
+// configs wrap, for 'ribbin watch'. It polls the modification times of the
+// directories holding the configs' targets, the configs' node_modules/.bin,
+// the mise and asdf shim directories and ~/.local/bin, and of the config
+// files themselves, since installs add and replace entries there.
+type Watcher struct {
+	configPaths []string
+	seen        map[string]time.Time
+}
+
+// NewWatcher returns a Watcher for the configs at configPaths
+func NewWatcher(configPaths []string) *Watcher {
+	return &Watcher{configPaths: configPaths}
+}
+
+// Changed reports whether any watched path was modified, created or removed
+// since the last call. The first call always reports a change.
+func (w *Watcher) Changed(registry *config.Registry) bool {
+	current := make(map[string]time.Time)
+	for _, path := range w.paths(registry) {
+		var modTime time.Time
+		if info, err := os.Stat(path); err == nil {
+			modTime = info.ModTime()
+		}
+		current[path] = modTime
+	}
+
+	changed := w.seen == nil || len(current) != len(w.seen)
+	for path, modTime := range current {
+		if previous, ok := w.seen[path]; !ok || !previous.Equal(modTime) {
+			changed = true
+		}
+	}
+	w.seen = current
+	return changed
+}
+
+// paths returns the files and directories the watcher polls, sorted
+func (w *Watcher) paths(registry *config.Registry) []string {
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if path != "" {
+			seen[path] = true
+		}
+	}
+
+	for _, configPath := range w.configPaths {
+		add(configPath)
+		add(filepath.Join(filepath.Dir(configPath), "node_modules", ".bin"))
+		for _, target := range configTargets(configPath, registry) {
+			add(filepath.Dir(target.Path))
+		}
+	}
+	for _, dir := range miseShimDirs() {
+		add(dir)
+	}
+	for _, dir := range asdfShimDirs() {
+		add(dir)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		add(filepath.Join(home, ".local", "bin"))
+	}
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Rewrapped is a binary that RewrapConfig wrapped again, or failed to
+type Rewrapped struct {
+	Command string
+	Path    string
+	Err     error
+}
+
+// RewrapConfig wraps every installed binary the config at configPath targets
+// that isn't wrapped, the way 'ribbin wrap --refresh' would after a
+// reinstall: an original kept from before in the project layout is
+// discarded, and a stale sidecar next to the binary is archived as in Heal.
+// Targets are found as by CheckCoverage. Binaries in system directories are
+// never wrapped.
+func RewrapConfig(configPath, ribbinPath string, registry *config.Registry) ([]Rewrapped, error) {
+	if _, err := config.LoadProjectConfig(configPath); err != nil {
+		return nil, err
+	}
+
+	var results []Rewrapped
+	for _, target := range configTargets(configPath, registry) {
+		if _, err := os.Stat(target.Path); err != nil {
+			continue
+		}
+		if shimmed, err := IsAlreadyShimmed(target.Path); err != nil || shimmed {
+			continue
+		}
+
+		result := Rewrapped{Command: target.Command, Path: target.Path}
+		if err := security.ValidateBinaryForShim(target.Path, false); err != nil {
+			result.Err = err
+		} else if _, err := DiscardStaleProjectSidecar(target.Path, configPath); err != nil {
+			result.Err = err
+		} else if _, err := Heal(target.Path, ribbinPath, registry, configPath, false); err != nil {
+			result.Err = err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// configTargets returns the binaries the wrappers of the config at
+// configPath target, installed or not, leaving out commands that aren't on
+// PATH. A config that fails to load has none.
+func configTargets(configPath string, registry *config.Registry) []TargetCoverage {
+	projectConfig, err := config.LoadProjectConfig(configPath)
+	if err != nil {
+		return nil
+	}
+	wrappers := configWrappers(projectConfig)
+
+	var targets []TargetCoverage
+	for _, name := range sortedWrapperNames(wrappers) {
+		for _, path := range coverageTargets(name, wrappers[name], configPath, registry) {
+			if path != "" {
+				targets = append(targets, TargetCoverage{Command: name, Path: path})
+			}
+		}
+	}
+	return targets
+}
@@ -0,0 +1,106 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/happycollision/ribbin/internal/config"
+	_ "github.com/happycollision/ribbin/internal/testsafety"
+)
+
+func TestRewrapConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	binDir := filepath.Join(dir, "node_modules", ".bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	ribbinPath := filepath.Join(dir, "ribbin")
+	tscPath := filepath.Join(binDir, "tsc")
+	for _, path := range []string{ribbinPath, tscPath} {
+		if err := os.WriteFile(path, []byte("#!/bin/sh\necho v1"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(dir, "ribbin.jsonc")
+	if err := os.WriteFile(configPath, []byte(`{
+  "wrappers": {
+    "tsc": { "action": "block", "paths": ["node_modules/.bin/tsc"] },
+    "yarn": { "action": "block", "paths": ["node_modules/.bin/yarn"] }
+  }
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+
+	results, err := RewrapConfig(configPath, ribbinPath, registry)
+	if err != nil {
+		t.Fatalf("RewrapConfig error: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != tscPath || results[0].Err != nil {
+		t.Fatalf("expected tsc to be wrapped, got %+v", results)
+	}
+	if !isWrapper(tscPath) {
+		t.Fatal("tsc should be wrapped")
+	}
+
+	if results, _ := RewrapConfig(configPath, ribbinPath, registry); len(results) != 0 {
+		t.Errorf("wrapped binaries should be left alone, got %+v", results)
+	}
+
+	// A reinstall replaces the wrapper with the new binary
+	if err := os.Remove(tscPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tscPath, []byte("#!/bin/sh\necho v2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	results, err = RewrapConfig(configPath, ribbinPath, registry)
+	if err != nil || len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("expected tsc to be wrapped again, got %+v, %v", results, err)
+	}
+	content, _ := os.ReadFile(tscPath + ".ribbin-original")
+	if string(content) != "#!/bin/sh\necho v2" {
+		t.Errorf("sidecar should hold the reinstalled binary, got %q", content)
+	}
+}
+
+func TestWatcherChanged(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	binDir := filepath.Join(dir, "node_modules", ".bin")
+	configPath := filepath.Join(dir, "ribbin.jsonc")
+	if err := os.WriteFile(configPath, []byte(`{"wrappers": {"tsc": {"action": "block", "paths": ["node_modules/.bin/tsc"]}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	registry := &config.Registry{Wrappers: make(map[string]config.WrapperEntry)}
+
+	w := NewWatcher([]string{configPath})
+	if !w.Changed(registry) {
+		t.Error("the first poll should report a change")
+	}
+	if w.Changed(registry) {
+		t.Error("nothing changed since the last poll")
+	}
+
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if !w.Changed(registry) {
+		t.Error("creating node_modules/.bin should be a change")
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(binDir, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if !w.Changed(registry) {
+		t.Error("modifying node_modules/.bin should be a change")
+	}
+	if w.Changed(registry) {
+		t.Error("nothing changed since the last poll")
+	}
+}
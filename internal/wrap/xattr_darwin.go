@@ -0,0 +1,41 @@
+//go:build darwin
+
+package wrap
+
+import (
+	"encoding/hex"
+	"os/exec"
+	"strings"
+)
+
+// The syscall package has no extended attribute calls on macOS, so these
+// use the xattr tool that ships with it, as the process package uses ps.
+
+// listXattrs returns the names of the extended attributes of path
+func listXattrs(path string) ([]string, error) {
+	output, err := exec.Command("/usr/bin/xattr", path).Output()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range strings.Split(string(output), "\n") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// getXattr returns the value of the extended attribute name of path
+func getXattr(path, name string) ([]byte, error) {
+	output, err := exec.Command("/usr/bin/xattr", "-px", name, path).Output()
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(strings.Join(strings.Fields(string(output)), ""))
+}
+
+// setXattr sets the extended attribute name of path to value
+func setXattr(path, name string, value []byte) error {
+	return exec.Command("/usr/bin/xattr", "-wx", name, hex.EncodeToString(value), path).Run()
+}
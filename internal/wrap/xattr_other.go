@@ -0,0 +1,20 @@
+//go:build !linux && !darwin
+
+package wrap
+
+import "errors"
+
+// errNoXattrs is returned where ribbin can't read extended attributes
+var errNoXattrs = errors.New("extended attributes are not supported on this system")
+
+func listXattrs(path string) ([]string, error) {
+	return nil, nil
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	return nil, errNoXattrs
+}
+
+func setXattr(path, name string, value []byte) error {
+	return errNoXattrs
+}